@@ -0,0 +1,483 @@
+// Package clusterlite replicates a shared pinset between a small set of
+// mutually trusting kubo nodes.
+//
+// Members gossip the pinset over pubsub as a last-writer-wins CRDT and each
+// member pins the CIDs allocated to it. Allocations are computed locally with
+// rendezvous hashing over the configured members, so all members agree on
+// them without coordination.
+package clusterlite
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	"github.com/ipfs/go-merkledag"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("clusterlite")
+
+// Options configures a Service.
+type Options struct {
+	// Name identifies the cluster; it is part of the pubsub topic.
+	Name string
+	// Peers are the trusted members besides the local node.
+	Peers []peer.ID
+	// ReplicationFactor is the number of members each CID is pinned on.
+	ReplicationFactor int
+	// RebroadcastInterval is how often the full pinset is gossiped.
+	RebroadcastInterval time.Duration
+	// PinTimeout bounds the fetching and pinning of each CID, which is
+	// retried at the next rebroadcast when it fails.
+	PinTimeout time.Duration
+}
+
+// PinInfo describes a CID of the shared pinset.
+type PinInfo struct {
+	Entry
+	Allocations []peer.ID
+	Local       bool // pinned by this node on behalf of the cluster
+}
+
+// Member describes a member of the cluster.
+type Member struct {
+	ID       peer.ID
+	Self     bool
+	LastSeen time.Time
+}
+
+// Service is a running cluster-lite member.
+type Service struct {
+	self       peer.ID
+	members    []peer.ID
+	rf         int
+	interval   time.Duration
+	pinTimeout time.Duration
+
+	ps    *pubsub.PubSub
+	topic string
+
+	state *state
+	local ds.Datastore // CIDs pinned by this node on behalf of the cluster
+
+	pinner pin.Pinner
+	dag    ipld.DAGService
+	locker bstore.GCLocker
+
+	mu       sync.Mutex
+	lastSeen map[peer.ID]time.Time
+
+	reconcileCh chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	t           *pubsub.Topic
+}
+
+// New loads the local replica of the pinset. Call Start to join the cluster.
+func New(ctx context.Context, self peer.ID, ps *pubsub.PubSub, d ds.Datastore, pinner pin.Pinner, dag ipld.DAGService, locker bstore.GCLocker, opts Options) (*Service, error) {
+	if opts.Name == "" {
+		return nil, fmt.Errorf("cluster name must not be empty")
+	}
+	if opts.ReplicationFactor < 1 {
+		return nil, fmt.Errorf("replication factor must be positive")
+	}
+	if opts.RebroadcastInterval <= 0 {
+		return nil, fmt.Errorf("rebroadcast interval must be positive")
+	}
+	if opts.PinTimeout <= 0 {
+		return nil, fmt.Errorf("pin timeout must be positive")
+	}
+
+	members := []peer.ID{self}
+	for _, p := range opts.Peers {
+		if p != self {
+			members = append(members, p)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i] < members[j] })
+
+	root := namespace.Wrap(d, ds.NewKey("/clusterlite/"+opts.Name))
+	st, err := loadState(ctx, namespace.Wrap(root, ds.NewKey("pins")))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		self:        self,
+		members:     members,
+		rf:          opts.ReplicationFactor,
+		interval:    opts.RebroadcastInterval,
+		pinTimeout:  opts.PinTimeout,
+		ps:          ps,
+		topic:       "/kubo/cluster-lite/" + opts.Name,
+		state:       st,
+		local:       namespace.Wrap(root, ds.NewKey("local")),
+		pinner:      pinner,
+		dag:         dag,
+		locker:      locker,
+		lastSeen:    make(map[peer.ID]time.Time),
+		reconcileCh: make(chan struct{}, 1),
+	}, nil
+}
+
+// Start joins the cluster topic and starts gossiping and pinning.
+func (s *Service) Start() error {
+	t, err := s.ps.Join(s.topic)
+	if err != nil {
+		return err
+	}
+	sub, err := t.Subscribe()
+	if err != nil {
+		t.Close()
+		return err
+	}
+	s.t = t
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.wg.Add(3)
+	go s.readLoop(sub)
+	go s.rebroadcastLoop()
+	go s.reconcileLoop()
+	s.triggerReconcile()
+	return nil
+}
+
+// Stop leaves the cluster topic and waits for background work to finish.
+func (s *Service) Stop() error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	s.wg.Wait()
+	return s.t.Close()
+}
+
+// Add adds a CID to the shared pinset.
+func (s *Service) Add(ctx context.Context, c cid.Cid, name string) error {
+	return s.update(ctx, c, name, true)
+}
+
+// Rm removes a CID from the shared pinset.
+func (s *Service) Rm(ctx context.Context, c cid.Cid) error {
+	e, ok := s.state.get(c)
+	if !ok || !e.Pinned {
+		return fmt.Errorf("%s is not in the shared pinset", c)
+	}
+	return s.update(ctx, c, e.Name, false)
+}
+
+func (s *Service) update(ctx context.Context, c cid.Cid, name string, pinned bool) error {
+	e, err := s.state.set(ctx, s.self, c, name, pinned)
+	if err != nil {
+		return err
+	}
+	s.triggerReconcile()
+	return s.publish(ctx, []Entry{e})
+}
+
+// Disown forgets that c was pinned on behalf of the cluster, once the user
+// pinned it too: the cluster no longer unpins it.
+func (s *Service) Disown(ctx context.Context, c cid.Cid) error {
+	return s.local.Delete(ctx, ds.NewKey(c.String()))
+}
+
+// Pins lists the CIDs of the shared pinset, excluding removed ones.
+func (s *Service) Pins(ctx context.Context) ([]PinInfo, error) {
+	var out []PinInfo
+	for _, e := range s.state.all() {
+		if !e.Pinned {
+			continue
+		}
+		local, err := s.local.Has(ctx, ds.NewKey(e.Cid.String()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, PinInfo{Entry: e, Allocations: s.allocations(e.Cid), Local: local})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cid.KeyString() < out[j].Cid.KeyString() })
+	return out, nil
+}
+
+// Members lists the cluster members and when they were last heard from.
+func (s *Service) Members() []Member {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Member, 0, len(s.members))
+	for _, p := range s.members {
+		out = append(out, Member{ID: p, Self: p == s.self, LastSeen: s.lastSeen[p]})
+	}
+	return out
+}
+
+// allocations returns the members responsible for pinning c: the
+// ReplicationFactor members with the highest hash(c, member) score.
+func (s *Service) allocations(c cid.Cid) []peer.ID {
+	return allocate(c, s.members, s.rf)
+}
+
+func allocate(c cid.Cid, members []peer.ID, rf int) []peer.ID {
+	type scored struct {
+		p     peer.ID
+		score []byte
+	}
+	scores := make([]scored, len(members))
+	for i, p := range members {
+		h := sha256.New()
+		h.Write(c.Bytes())
+		h.Write([]byte(p))
+		scores[i] = scored{p, h.Sum(nil)}
+	}
+	sort.Slice(scores, func(i, j int) bool { return bytes.Compare(scores[i].score, scores[j].score) > 0 })
+	if rf > len(scores) {
+		rf = len(scores)
+	}
+	out := make([]peer.ID, rf)
+	for i := range out {
+		out[i] = scores[i].p
+	}
+	return out
+}
+
+// maxEntriesPerMessage keeps rebroadcasts well below the pubsub message size
+// limit.
+const maxEntriesPerMessage = 1000
+
+type message struct {
+	Entries []Entry
+}
+
+func (s *Service) publish(ctx context.Context, entries []Entry) error {
+	if s.t == nil {
+		return nil
+	}
+	b, err := json.Marshal(message{Entries: entries})
+	if err != nil {
+		return err
+	}
+	return s.t.Publish(ctx, b)
+}
+
+func (s *Service) isMember(p peer.ID) bool {
+	for _, m := range s.members {
+		if m == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Service) readLoop(sub *pubsub.Subscription) {
+	defer s.wg.Done()
+	defer sub.Cancel()
+	for {
+		msg, err := sub.Next(s.ctx)
+		if err != nil {
+			return
+		}
+		from := msg.GetFrom()
+		if from == s.self {
+			continue
+		}
+		if !s.isMember(from) {
+			log.Debugf("ignoring pinset update from untrusted peer %s", from)
+			continue
+		}
+
+		var m message
+		if err := json.Unmarshal(msg.Data, &m); err != nil {
+			log.Warnf("invalid pinset update from %s: %s", from, err)
+			continue
+		}
+
+		s.mu.Lock()
+		s.lastSeen[from] = time.Now()
+		s.mu.Unlock()
+
+		changed, err := s.state.merge(s.ctx, m.Entries)
+		if err != nil {
+			log.Errorf("failed to merge pinset update from %s: %s", from, err)
+		}
+		if len(changed) > 0 {
+			s.triggerReconcile()
+		}
+	}
+}
+
+func (s *Service) rebroadcastLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Always publish, even an empty pinset, so members learn we
+			// are alive.
+			entries := s.state.all()
+			for len(entries) > maxEntriesPerMessage {
+				if err := s.publish(s.ctx, entries[:maxEntriesPerMessage]); err != nil && s.ctx.Err() == nil {
+					log.Warnf("failed to rebroadcast pinset: %s", err)
+				}
+				entries = entries[maxEntriesPerMessage:]
+			}
+			if err := s.publish(s.ctx, entries); err != nil && s.ctx.Err() == nil {
+				log.Warnf("failed to rebroadcast pinset: %s", err)
+			}
+			// Retry failed pins.
+			s.triggerReconcile()
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) triggerReconcile() {
+	select {
+	case s.reconcileCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Service) reconcileLoop() {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.reconcileCh:
+			s.reconcile(s.ctx)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcileWorkers is the number of CIDs fetched and pinned at once.
+const reconcileWorkers = 4
+
+// reconcile pins the CIDs allocated to this node and unpins the ones it
+// pinned earlier but is no longer responsible for. The CIDs failing to pin
+// within the pin timeout are retried at the next reconciliation.
+func (s *Service) reconcile(ctx context.Context) {
+	entries := make(chan Entry)
+	var wg sync.WaitGroup
+	wg.Add(reconcileWorkers)
+	for i := 0; i < reconcileWorkers; i++ {
+		go func() {
+			defer wg.Done()
+			for e := range entries {
+				s.reconcileEntry(ctx, e)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(entries)
+
+	for _, e := range s.state.all() {
+		select {
+		case entries <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Service) reconcileEntry(ctx context.Context, e Entry) {
+	key := ds.NewKey(e.Cid.String())
+	local, err := s.local.Has(ctx, key)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	want := e.Pinned && containsPeer(s.allocations(e.Cid), s.self)
+
+	switch {
+	case want && !local:
+		ctx, cancel := context.WithTimeout(ctx, s.pinTimeout)
+		defer cancel()
+		if err := s.pin(ctx, e.Cid); err != nil {
+			log.Warnf("failed to pin %s, retrying later: %s", e.Cid, err)
+		}
+	case !want && local:
+		if err := s.unpin(ctx, e.Cid); err != nil {
+			log.Warnf("failed to unpin %s: %s", e.Cid, err)
+		}
+	}
+}
+
+func (s *Service) pin(ctx context.Context, c cid.Cid) error {
+	if pinned, err := s.pinnedByUser(ctx, c); err != nil || pinned {
+		return err
+	}
+
+	// The DAG is fetched before taking the pin lock, so that GC isn't
+	// blocked while it downloads.
+	if err := merkledag.FetchGraph(ctx, c, s.dag); err != nil {
+		return err
+	}
+	nd, err := s.dag.Get(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	defer s.locker.PinLock(ctx).Unlock(ctx)
+	// the user may have pinned c during the fetch
+	if pinned, err := s.pinnedByUser(ctx, c); err != nil || pinned {
+		return err
+	}
+	if err := s.pinner.Pin(ctx, nd, true); err != nil {
+		return err
+	}
+	if err := s.pinner.Flush(ctx); err != nil {
+		return err
+	}
+	log.Infof("pinned %s", c)
+	return s.local.Put(ctx, ds.NewKey(c.String()), nil)
+}
+
+// pinnedByUser tells whether c is pinned already: the cluster doesn't take
+// ownership of the pins created by the user.
+func (s *Service) pinnedByUser(ctx context.Context, c cid.Cid) (bool, error) {
+	_, pinned, err := s.pinner.IsPinnedWithType(ctx, c, pin.Recursive)
+	return pinned, err
+}
+
+// unpin unpins c, if the cluster pinned it: once disowned, the pin belongs to
+// the user.
+func (s *Service) unpin(ctx context.Context, c cid.Cid) error {
+	defer s.locker.PinLock(ctx).Unlock(ctx)
+	// the user may have pinned c since the reconciliation started
+	if local, err := s.local.Has(ctx, ds.NewKey(c.String())); err != nil || !local {
+		return err
+	}
+	if err := s.pinner.Unpin(ctx, c, true); err != nil && !errors.Is(err, pin.ErrNotPinned) {
+		return err
+	}
+	if err := s.pinner.Flush(ctx); err != nil {
+		return err
+	}
+	log.Infof("unpinned %s", c)
+	return s.local.Delete(ctx, ds.NewKey(c.String()))
+}
+
+func containsPeer(peers []peer.ID, p peer.ID) bool {
+	for _, q := range peers {
+		if q == p {
+			return true
+		}
+	}
+	return false
+}
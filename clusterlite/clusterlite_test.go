@@ -0,0 +1,179 @@
+package clusterlite
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func testCid(t *testing.T, s string) cid.Cid {
+	h, err := mh.Sum([]byte(s), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cid.NewCidV1(cid.Raw, h)
+}
+
+func TestStateMerge(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	st, err := loadState(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the entries are persisted with valid peer IDs, peerB winning the ties
+	peerA, peerB := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	if peerA > peerB {
+		peerA, peerB = peerB, peerA
+	}
+
+	c := testCid(t, "a")
+	local, err := st.set(ctx, peerA, c, "a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// older remote update is ignored
+	changed, err := st.merge(ctx, []Entry{{Cid: c, Pinned: false, Clock: 0, Peer: peerB}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 0 {
+		t.Fatal("stale entry should not change the state")
+	}
+
+	// same clock, higher peer ID wins
+	changed, err = st.merge(ctx, []Entry{{Cid: c, Pinned: false, Clock: local.Clock, Peer: peerB}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 1 {
+		t.Fatal("expected tie to be broken by peer ID")
+	}
+	if e, _ := st.get(c); e.Pinned {
+		t.Fatal("expected tombstone")
+	}
+
+	// local clock moves past merged entries
+	e, err := st.set(ctx, peerA, c, "a", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Clock <= local.Clock {
+		t.Fatal("expected clock to advance")
+	}
+
+	// state survives a reload
+	st2, err := loadState(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e2, ok := st2.get(c); !ok || e2 != e {
+		t.Fatalf("expected %+v after reload, got %+v", e, e2)
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	members := []peer.ID{"a", "b", "c", "d"}
+	counts := make(map[peer.ID]int)
+	for i := 0; i < 200; i++ {
+		c := testCid(t, string(rune(i)))
+		alloc := allocate(c, members, 2)
+		if len(alloc) != 2 || alloc[0] == alloc[1] {
+			t.Fatalf("unexpected allocation %v", alloc)
+		}
+		// allocations do not depend on member order
+		again := allocate(c, []peer.ID{"d", "c", "b", "a"}, 2)
+		if alloc[0] != again[0] || alloc[1] != again[1] {
+			t.Fatalf("allocation depends on member order: %v != %v", alloc, again)
+		}
+		for _, p := range alloc {
+			counts[p]++
+		}
+	}
+	for _, p := range members {
+		if counts[p] == 0 {
+			t.Fatalf("member %s was never allocated", p)
+		}
+	}
+
+	if alloc := allocate(testCid(t, "x"), members, 10); len(alloc) != len(members) {
+		t.Fatalf("expected all members, got %v", alloc)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	dag := mdtest.Mock()
+	pinner, err := dspinner.New(ctx, d, dag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(ctx, test.RandPeerIDFatal(t), nil, d, pinner, dag, bstore.NewGCLocker(), Options{
+		Name:                "test",
+		ReplicationFactor:   1,
+		RebroadcastInterval: time.Minute,
+		PinTimeout:          time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owned := merkledag.NodeWithData([]byte("owned"))
+	disowned := merkledag.NodeWithData([]byte("disowned"))
+	for _, nd := range []ipld.Node{owned, disowned} {
+		if err := dag.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// missing blocks fail to pin without blocking the other CIDs
+	missing := testCid(t, "missing")
+	for _, c := range []cid.Cid{missing, owned.Cid(), disowned.Cid()} {
+		if err := s.Add(ctx, c, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.reconcile(ctx)
+
+	pins, err := s.Pins(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range pins {
+		if p.Local == p.Cid.Equals(missing) {
+			t.Fatalf("unexpected pin %+v", p)
+		}
+	}
+
+	// once the user pinned it, the cluster leaves the pin in place
+	if err := s.Disown(ctx, disowned.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []cid.Cid{owned.Cid(), disowned.Cid()} {
+		if err := s.Rm(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.reconcile(ctx)
+
+	if _, pinned, err := pinner.IsPinned(ctx, owned.Cid()); err != nil || pinned {
+		t.Fatalf("expected the pin of the cluster to be removed, got %t, %v", pinned, err)
+	}
+	if _, pinned, err := pinner.IsPinned(ctx, disowned.Cid()); err != nil || !pinned {
+		t.Fatalf("expected the pin of the user to be kept, got %t, %v", pinned, err)
+	}
+}
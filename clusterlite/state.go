@@ -0,0 +1,136 @@
+package clusterlite
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Entry is the state of a single CID in the shared pinset. The pinset is a
+// last-writer-wins map: for each CID, the entry with the highest Clock wins,
+// ties being broken by the writer peer ID. Removed pins are kept as
+// tombstones (Pinned == false) so that removals propagate.
+type Entry struct {
+	Cid    cid.Cid
+	Name   string `json:",omitempty"`
+	Pinned bool
+	Clock  uint64
+	Peer   peer.ID
+}
+
+// newer reports whether e supersedes o.
+func (e Entry) newer(o Entry) bool {
+	if e.Clock != o.Clock {
+		return e.Clock > o.Clock
+	}
+	return e.Peer > o.Peer
+}
+
+// state is the local replica of the shared pinset, persisted in the
+// datastore.
+type state struct {
+	mu      sync.RWMutex
+	entries map[cid.Cid]Entry
+	clock   uint64
+
+	ds ds.Datastore
+}
+
+func loadState(ctx context.Context, d ds.Datastore) (*state, error) {
+	s := &state{
+		entries: make(map[cid.Cid]Entry),
+		ds:      d,
+	}
+
+	res, err := d.Query(ctx, query.Query{})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var e Entry
+		if err := json.Unmarshal(r.Value, &e); err != nil {
+			log.Errorf("skipping corrupted pinset entry %s: %s", r.Key, err)
+			continue
+		}
+		s.entries[e.Cid] = e
+		if e.Clock > s.clock {
+			s.clock = e.Clock
+		}
+	}
+	return s, nil
+}
+
+// set records a local change and returns the new entry.
+func (s *state) set(ctx context.Context, self peer.ID, c cid.Cid, name string, pinned bool) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock++
+	e := Entry{Cid: c, Name: name, Pinned: pinned, Clock: s.clock, Peer: self}
+	if err := s.put(ctx, e); err != nil {
+		return Entry{}, err
+	}
+	return e, nil
+}
+
+// merge applies remote entries and returns the ones that changed the state.
+func (s *state) merge(ctx context.Context, entries []Entry) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed []Entry
+	for _, e := range entries {
+		if !e.Cid.Defined() {
+			continue
+		}
+		if e.Clock > s.clock {
+			s.clock = e.Clock
+		}
+		if cur, ok := s.entries[e.Cid]; ok && !e.newer(cur) {
+			continue
+		}
+		if err := s.put(ctx, e); err != nil {
+			return changed, err
+		}
+		changed = append(changed, e)
+	}
+	return changed, nil
+}
+
+func (s *state) put(ctx context.Context, e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := s.ds.Put(ctx, ds.NewKey(e.Cid.String()), b); err != nil {
+		return err
+	}
+	s.entries[e.Cid] = e
+	return nil
+}
+
+func (s *state) get(c cid.Cid) (Entry, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	e, ok := s.entries[c]
+	return e, ok
+}
+
+func (s *state) all() []Entry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		out = append(out, e)
+	}
+	return out
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"sync"
@@ -23,6 +24,7 @@ import (
 	"github.com/ipfs/kubo/core"
 	commands "github.com/ipfs/kubo/core/commands"
 	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/coregrpc"
 	corehttp "github.com/ipfs/kubo/core/corehttp"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
 	libp2p "github.com/ipfs/kubo/core/node/libp2p"
@@ -71,6 +73,8 @@ const (
 	enableIPNSPubSubKwd       = "enable-namesys-pubsub"
 	enableMultiplexKwd        = "enable-mplex-experiment"
 	agentVersionSuffix        = "agent-version-suffix"
+	readReplicaKwd            = "read-replica"
+	companionProxyKwd         = "companion-proxy"
 	// apiAddrKwd    = "address-api"
 	// swarmAddrKwd  = "address-swarm"
 )
@@ -136,6 +140,30 @@ or send a SIGTERM signal to it (e.g. with 'kill'). It may take a while for the
 daemon to shutdown gracefully, but it can be killed forcibly by sending a
 second signal.
 
+Replacing a running daemon
+
+To upgrade the ipfs binary or apply configuration changes that require a
+restart, start the new daemon with --replace. The running daemon hands over
+its API and gateway listeners, stops accepting connections, drains the
+requests in flight and releases the repo, after which the new daemon starts.
+Incoming connections wait in the listen queue meanwhile instead of being
+refused. Listeners for addresses removed from the configuration are closed,
+and new addresses are listened on.
+
+  ipfs daemon --replace
+
+Read replicas
+
+Daemons started with --read-replica serve the blocks of a repo written by
+another daemon, e.g. gateways sharing the repo of a writer on network storage,
+without copying it. Any number of replicas can run next to the daemon holding
+the repo lock. A replica verifies the blocks it reads, keeps its pins, MFS root
+and other state in memory, doesn't store the blocks it fetches, and runs with
+an ephemeral peer ID. The blocks must be in a flatfs datastore. The writer
+refuses to migrate the repo while replicas are open.
+
+  ipfs daemon --read-replica
+
 IPFS_PATH environment variable
 
 ipfs uses a repository in the local file system. By default, the repo is
@@ -159,7 +187,7 @@ Headers.
 	Options: []cmds.Option{
 		cmds.BoolOption(initOptionKwd, "Initialize ipfs with default settings if not already initialized"),
 		cmds.StringOption(initConfigOptionKwd, "Path to existing configuration file to be loaded during --init"),
-		cmds.StringOption(initProfileOptionKwd, "Configuration profiles to apply for --init. See ipfs init --help for more"),
+		cmds.StringOption(initProfileOptionKwd, "Configuration profiles to apply for --init, separated by ',' and applied in order. See ipfs init --help for more"),
 		cmds.StringOption(routingOptionKwd, "Overrides the routing option").WithDefault(routingOptionDefaultKwd),
 		cmds.BoolOption(mountKwd, "Mounts IPFS to the filesystem using FUSE (experimental)"),
 		cmds.BoolOption(writableKwd, "Enable legacy Gateway.Writable (deprecated)"),
@@ -174,6 +202,9 @@ Headers.
 		cmds.BoolOption(enableIPNSPubSubKwd, "Enable IPNS over pubsub. Implicitly enables pubsub, overrides Ipns.UsePubsub config."),
 		cmds.BoolOption(enableMultiplexKwd, "DEPRECATED"),
 		cmds.StringOption(agentVersionSuffix, "Optional suffix to the AgentVersion presented by `ipfs id` and also advertised through BitSwap."),
+		cmds.BoolOption(replaceKwd, "Replace the daemon running on the repo, taking over its API and gateway listeners. Not supported on Windows."),
+		cmds.BoolOption(readReplicaKwd, "Open the repo as a read replica, serving its blocks while another daemon writes them."),
+		cmds.BoolOption(companionProxyKwd, "Serve an HTTP proxy on Addresses.CompanionProxy, loading the ipfs:// and ipns:// URLs and the /ipfs paths of any host from the node."),
 
 		// TODO: add way to override addresses. tricky part: updating the config if also --init.
 		// cmds.StringOption(apiAddrKwd, "Address for the daemon rpc API (overrides config)"),
@@ -269,13 +300,44 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 	var cacheMigrations, pinMigrations bool
 	var fetcher migrations.Fetcher
 
+	readReplica, _ := req.Options[readReplicaKwd].(bool)
+	replace, _ := req.Options[replaceKwd].(bool)
+	if readReplica {
+		if replace {
+			return fmt.Errorf("--%s can't be used with --%s", readReplicaKwd, replaceKwd)
+		}
+		if enableGC, _ := req.Options[enableGCKwd].(bool); enableGC {
+			return fmt.Errorf("read replicas can't collect garbage, the writer of the repo does")
+		}
+	}
+
+	if replace {
+		if err := requestHandover(cctx.ConfigRoot); err != nil {
+			return err
+		}
+	}
+
 	// acquire the repo lock _before_ constructing a node. we need to make
 	// sure we are permitted to access the resources (datastore, etc.)
-	repo, err := fsrepo.Open(cctx.ConfigRoot)
+	// The read replicas take a replica lock instead.
+	openRepo := fsrepo.Open
+	if readReplica {
+		openRepo = fsrepo.OpenReadReplica
+	}
+	repo, err := openRepo(cctx.ConfigRoot)
 	switch err {
 	default:
 		return err
 	case fsrepo.ErrNeedMigration:
+		if readReplica {
+			return fmt.Errorf("fs-repo requires migration, which its writer runs")
+		}
+		if replicas, err := fsrepo.ReadReplicas(cctx.ConfigRoot); err != nil {
+			return err
+		} else if replicas > 0 {
+			return fmt.Errorf("fs-repo requires migration, but %d read replicas are open", replicas)
+		}
+
 		domigrate, found := req.Options[migrateKwd].(bool)
 		fmt.Println("Found outdated fs-repo, migrations need to be run.")
 
@@ -419,6 +481,9 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		)
 	case routingOptionDHTClientKwd:
 		ncfg.Routing = libp2p.DHTClientOption
+		if cfg.Internal.MemoryBudget != nil {
+			ncfg.Routing = libp2p.BoundedDHTClientOption
+		}
 	case routingOptionDHTKwd:
 		ncfg.Routing = libp2p.DHTOption
 	case routingOptionDHTServerKwd:
@@ -447,10 +512,18 @@ func daemonFunc(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment
 		return err
 	}
 	node.IsDaemon = true
+	// the pin jobs only run in the daemon, which lives long enough to finish
+	// them
+	node.PinQueue.Start()
 
 	if node.PNetFingerprint != nil {
 		fmt.Println("Swarm is limited to private network of peers with the swarm key")
 		fmt.Printf("Swarm key fingerprint: %x\n", node.PNetFingerprint)
+		if node.PNetKeys != nil {
+			for _, fp := range node.PNetKeys.Fingerprints()[1:] {
+				fmt.Printf("Swarm key fingerprint (accepted): %x\n", fp)
+			}
+		}
 	}
 
 	if (pnet.ForcePrivateNetwork || node.PNetFingerprint != nil) && routingOption == routingOptionAutoKwd {
@@ -549,6 +622,18 @@ take effect.
 		return err
 	}
 
+	// construct the gRPC API, if enabled
+	grpcErrc, err := serveGRPC(cctx)
+	if err != nil {
+		return err
+	}
+
+	// construct the companion proxy, if enabled
+	proxyErrc, err := serveCompanionProxy(req, cctx)
+	if err != nil {
+		return err
+	}
+
 	// Add ipfs version info to prometheus metrics
 	var ipfsInfoMetric = promauto.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "ipfs_info",
@@ -568,6 +653,12 @@ take effect.
 	// start MFS pinning thread
 	startPinMFS(daemonConfigPollInterval, cctx, &ipfsPinMFSNode{node})
 
+	// the inherited listeners no longer configured
+	daemonListeners.closeUnused()
+	if err := serveHandover(cctx.ConfigRoot, node); err != nil {
+		log.Errorf("serving the daemon handover: %s", err)
+	}
+
 	// The daemon is *finally* ready.
 	fmt.Printf("Daemon is ready\n")
 	notifyReady()
@@ -616,7 +707,7 @@ take effect.
 	// collect long-running errors and block for shutdown
 	// TODO(cryptix): our fuse currently doesn't follow this pattern for graceful shutdown
 	var errs error
-	for err := range merge(apiErrc, gwErrc, gcErrc) {
+	for err := range merge(apiErrc, gwErrc, grpcErrc, proxyErrc, gcErrc) {
 		if err != nil {
 			errs = multierror.Append(errs, err)
 		}
@@ -659,7 +750,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 			continue
 		}
 
-		apiLis, err := manet.Listen(apiMaddr)
+		apiLis, err := daemonListeners.listen(apiMaddr)
 		if err != nil {
 			return nil, fmt.Errorf("serveHTTPApi: manet.Listen(%s) failed: %s", apiMaddr, err)
 		}
@@ -683,7 +774,11 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	// only the webui objects are allowed.
 	// if you know what you're doing, go ahead and pass --unrestricted-api.
 	unrestricted, _ := req.Options[unrestrictedAPIAccessKwd].(bool)
-	gatewayOpt := corehttp.GatewayOption(false, corehttp.WebUIPaths...)
+	webuiOpt, webuiPaths, err := corehttp.WebUIConfigOption(cfg.API.WebUI)
+	if err != nil {
+		return nil, fmt.Errorf("serveHTTPApi: %w", err)
+	}
+	gatewayOpt := corehttp.GatewayOption(false, webuiPaths...)
 	if unrestricted {
 		gatewayOpt = corehttp.GatewayOption(true, "/ipfs", "/ipns")
 	}
@@ -694,7 +789,8 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		corehttp.MetricsOpenCensusDefaultPrometheusRegistry(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsOption(*cctx),
-		corehttp.WebUIOption,
+		corehttp.DagUploadOption(filepath.Join(cctx.ConfigRoot, "dag-uploads")),
+		webuiOpt,
 		gatewayOpt,
 		corehttp.VersionOption(),
 		defaultMux("/debug/vars"),
@@ -706,6 +802,11 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 		corehttp.LogOption(),
 	}
 
+	if h3 := cfg.API.HTTP3; h3 != nil && h3.Enabled.WithDefault(false) {
+		// first, to serve all the other options over HTTP/3
+		opts = append([]corehttp.ServeOption{corehttp.HTTP3Option(h3, cctx.ConfigRoot)}, opts...)
+	}
+
 	if len(cfg.Gateway.RootRedirect) > 0 {
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
@@ -722,6 +823,7 @@ func serveHTTPApi(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error
 	errc := make(chan error)
 	var wg sync.WaitGroup
 	for _, apiLis := range listeners {
+		daemonListeners.serve(apiLis)
 		wg.Add(1)
 		go func(lis manet.Listener) {
 			defer wg.Done()
@@ -754,6 +856,9 @@ func rewriteMaddrToUseLocalhostIfItsAny(maddr ma.Multiaddr) ma.Multiaddr {
 func printSwarmAddrs(node *core.IpfsNode) {
 	if !node.IsOnline {
 		fmt.Println("Swarm not listening, running in offline mode.")
+		if cfg, err := node.Repo.Config(); err == nil && cfg.Offline.DelegatedRouting.WithDefault(false) {
+			fmt.Println("Delegated HTTP routing is enabled (Offline.DelegatedRouting).")
+		}
 		return
 	}
 
@@ -819,7 +924,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 			continue
 		}
 
-		gwLis, err := manet.Listen(gatewayMaddr)
+		gwLis, err := daemonListeners.listen(gatewayMaddr)
 		if err != nil {
 			return nil, fmt.Errorf("serveHTTPGateway: manet.Listen(%s) failed: %s", gatewayMaddr, err)
 		}
@@ -847,12 +952,18 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 		corehttp.VersionOption(),
 		corehttp.CheckVersionOption(),
 		corehttp.CommandsROOption(cmdctx),
+		corehttp.IPNIOption(),
 	}
 
 	if cfg.Experimental.P2pHttpProxy {
 		opts = append(opts, corehttp.P2PProxyOption())
 	}
 
+	if h3 := cfg.Gateway.HTTP3; h3 != nil && h3.Enabled.WithDefault(false) {
+		// first, to serve all the other options over HTTP/3
+		opts = append([]corehttp.ServeOption{corehttp.HTTP3Option(h3, cctx.ConfigRoot)}, opts...)
+	}
+
 	if len(cfg.Gateway.RootRedirect) > 0 {
 		opts = append(opts, corehttp.RedirectOption("", cfg.Gateway.RootRedirect))
 	}
@@ -879,6 +990,7 @@ func serveHTTPGateway(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, e
 	errc := make(chan error)
 	var wg sync.WaitGroup
 	for _, lis := range listeners {
+		daemonListeners.serve(lis)
 		wg.Add(1)
 		go func(lis manet.Listener) {
 			defer wg.Done()
@@ -925,6 +1037,114 @@ func mountFuse(req *cmds.Request, cctx *oldcmds.Context) error {
 	return nil
 }
 
+// serveGRPC serves the gRPC API on the addresses of Addresses.GRPC.
+func serveGRPC(cctx *oldcmds.Context) (<-chan error, error) {
+	cfg, err := cctx.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("serveGRPC: GetConfig() failed: %s", err)
+	}
+	if len(cfg.Addresses.GRPC) == 0 {
+		return nil, nil
+	}
+	if len(cfg.API.Authorizations) > 0 {
+		return nil, fmt.Errorf("serveGRPC: %w", coregrpc.ErrAuthorizations)
+	}
+
+	listeners := make([]manet.Listener, 0, len(cfg.Addresses.GRPC))
+	for _, addr := range cfg.Addresses.GRPC {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("serveGRPC: invalid gRPC address: %q (err: %s)", addr, err)
+		}
+		lis, err := daemonListeners.listen(maddr)
+		if err != nil {
+			return nil, fmt.Errorf("serveGRPC: manet.Listen(%s) failed: %s", maddr, err)
+		}
+		listeners = append(listeners, lis)
+	}
+
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveGRPC: ConstructNode() failed: %s", err)
+	}
+
+	errc := make(chan error)
+	var wg sync.WaitGroup
+	for _, lis := range listeners {
+		fmt.Printf("gRPC API server listening on %s\n", lis.Multiaddr())
+		daemonListeners.serve(lis)
+		wg.Add(1)
+		go func(lis manet.Listener) {
+			defer wg.Done()
+			errc <- coregrpc.Serve(node, manet.NetListener(lis), cctx.ConfigRoot)
+		}(lis)
+	}
+
+	go func() {
+		wg.Wait()
+		close(errc)
+	}()
+
+	return errc, nil
+}
+
+// serveCompanionProxy serves the companion proxy on the addresses of
+// Addresses.CompanionProxy, with --companion-proxy.
+func serveCompanionProxy(req *cmds.Request, cctx *oldcmds.Context) (<-chan error, error) {
+	if enabled, _ := req.Options[companionProxyKwd].(bool); !enabled {
+		return nil, nil
+	}
+	cfg, err := cctx.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("serveCompanionProxy: GetConfig() failed: %s", err)
+	}
+	addrs := cfg.Addresses.CompanionProxy
+	if len(addrs) == 0 {
+		addrs = config.Strings{config.DefaultCompanionProxyAddress}
+	}
+
+	listeners := make([]manet.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("serveCompanionProxy: invalid companion proxy address: %q (err: %s)", addr, err)
+		}
+		// anyone reaching the proxy could use it to reach any host
+		if !manet.IsIPLoopback(maddr) {
+			return nil, fmt.Errorf("serveCompanionProxy: %s is not a loopback address", maddr)
+		}
+		lis, err := daemonListeners.listen(maddr)
+		if err != nil {
+			return nil, fmt.Errorf("serveCompanionProxy: manet.Listen(%s) failed: %s", maddr, err)
+		}
+		listeners = append(listeners, lis)
+	}
+
+	node, err := cctx.ConstructNode()
+	if err != nil {
+		return nil, fmt.Errorf("serveCompanionProxy: ConstructNode() failed: %s", err)
+	}
+
+	errc := make(chan error)
+	var wg sync.WaitGroup
+	for _, lis := range listeners {
+		fmt.Printf("Companion proxy listening on %s\n", lis.Multiaddr())
+		daemonListeners.serve(lis)
+		wg.Add(1)
+		go func(lis manet.Listener) {
+			defer wg.Done()
+			errc <- corehttp.ServeCompanionProxy(node, manet.NetListener(lis))
+		}(lis)
+	}
+
+	go func() {
+		wg.Wait()
+		close(errc)
+	}()
+
+	return errc, nil
+}
+
 func maybeRunGC(req *cmds.Request, node *core.IpfsNode) (<-chan error, error) {
 	enableGC, _ := req.Options[enableGCKwd].(bool)
 	if !enableGC {
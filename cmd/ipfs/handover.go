@@ -0,0 +1,69 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	replaceKwd = "replace"
+
+	// handoverSocketFile is the unix socket, in the repo, on which the
+	// running daemon hands over its listeners to 'ipfs daemon --replace'.
+	handoverSocketFile = "handover.sock"
+
+	// handoverTimeout bounds the time the new daemon waits for the running
+	// daemon to drain its requests and release the repo lock.
+	handoverTimeout = 2 * time.Minute
+)
+
+func handoverSocketPath(repoRoot string) string {
+	return filepath.Join(repoRoot, handoverSocketFile)
+}
+
+// daemonListeners are the HTTP listeners of the daemon: the ones inherited
+// from the replaced daemon, and the ones handed over to the daemon replacing
+// this one.
+var daemonListeners handoverListeners
+
+type handoverListeners struct {
+	mu sync.Mutex
+	// inherited listeners not yet used, by multiaddr
+	inherited map[string]manet.Listener
+	// serving listeners, handed over on replacement
+	serving []manet.Listener
+}
+
+// listen returns the listener inherited for addr, or listens on addr.
+func (h *handoverListeners) listen(addr ma.Multiaddr) (manet.Listener, error) {
+	h.mu.Lock()
+	lis, ok := h.inherited[string(addr.Bytes())]
+	delete(h.inherited, string(addr.Bytes()))
+	h.mu.Unlock()
+	if ok {
+		return lis, nil
+	}
+	return manet.Listen(addr)
+}
+
+// serve registers a listener to hand over.
+func (h *handoverListeners) serve(lis manet.Listener) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.serving = append(h.serving, lis)
+}
+
+// closeUnused closes the inherited listeners that the configuration no
+// longer listens on.
+func (h *handoverListeners) closeUnused() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for addr, lis := range h.inherited {
+		lis.Close()
+		delete(h.inherited, addr)
+	}
+}
@@ -0,0 +1,190 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	core "github.com/ipfs/kubo/core"
+	goprocess "github.com/jbenet/goprocess"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// maxHandoverListeners is the maximum number of listeners handed over.
+const maxHandoverListeners = 64
+
+// handoverHeader is sent along the file descriptors of the listeners, in the
+// same order.
+type handoverHeader struct {
+	Addrs []string
+}
+
+// requestHandover asks the daemon running on the repo to hand over its
+// listeners, and waits for it to shut down and release the repo lock.
+func requestHandover(repoRoot string) error {
+	conn, err := net.DialTimeout("unix", handoverSocketPath(repoRoot), 5*time.Second)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+		fmt.Println("No running daemon to replace")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to the running daemon: %w", err)
+	}
+	uc := conn.(*net.UnixConn)
+	defer uc.Close()
+	if err := uc.SetDeadline(time.Now().Add(handoverTimeout)); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64<<10)
+	oob := make([]byte, syscall.CmsgSpace(maxHandoverListeners*4))
+	n, oobn, _, _, err := uc.ReadMsgUnix(buf, oob)
+	if err != nil {
+		return fmt.Errorf("receiving the listeners of the running daemon: %w", err)
+	}
+	var fds []int
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return err
+	}
+	for i := range msgs {
+		rights, err := syscall.ParseUnixRights(&msgs[i])
+		if err != nil {
+			return err
+		}
+		fds = append(fds, rights...)
+	}
+
+	var header handoverHeader
+	if err := json.Unmarshal(buf[:n], &header); err != nil || len(header.Addrs) != len(fds) {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+		return errors.New("invalid handover from the running daemon")
+	}
+
+	inherited := make(map[string]manet.Listener, len(fds))
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), header.Addrs[i])
+		nl, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("inheriting the listener on %s: %w", header.Addrs[i], err)
+		}
+		lis, err := manet.WrapNetListener(nl)
+		if err != nil {
+			nl.Close()
+			return fmt.Errorf("inheriting the listener on %s: %w", header.Addrs[i], err)
+		}
+		inherited[string(lis.Multiaddr().Bytes())] = lis
+	}
+	daemonListeners.mu.Lock()
+	daemonListeners.inherited = inherited
+	daemonListeners.mu.Unlock()
+
+	// the connection is closed once the running daemon has drained its
+	// requests and closed the repo
+	fmt.Printf("Inherited %d listeners, waiting for the running daemon to shut down...\n", len(inherited))
+	if _, err := io.Copy(io.Discard, uc); err != nil {
+		return fmt.Errorf("waiting for the running daemon to shut down: %w", err)
+	}
+	return nil
+}
+
+// serveHandover hands over the listeners of the daemon to the first
+// 'ipfs daemon --replace', and then shuts down the node.
+func serveHandover(repoRoot string, node *core.IpfsNode) error {
+	p := handoverSocketPath(repoRoot)
+	// the socket of a daemon which didn't shut down cleanly, as we hold the
+	// repo lock
+	_ = os.Remove(p)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: p, Net: "unix"})
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(p, 0o600); err != nil {
+		l.Close()
+		return err
+	}
+	node.Process.AddChild(goprocess.WithTeardown(l.Close))
+
+	go func() {
+		for {
+			conn, err := l.AcceptUnix()
+			if err != nil {
+				return
+			}
+			if err := handOver(conn, node); err != nil {
+				log.Errorf("handing over to the new daemon: %s", err)
+				conn.Close()
+				continue
+			}
+			return
+		}
+	}()
+	return nil
+}
+
+func handOver(conn *net.UnixConn, node *core.IpfsNode) error {
+	daemonListeners.mu.Lock()
+	listeners := append([]manet.Listener(nil), daemonListeners.serving...)
+	daemonListeners.mu.Unlock()
+
+	var header handoverHeader
+	var fds []int
+	for _, lis := range listeners {
+		nl := manet.NetListener(lis)
+		if ul, ok := nl.(*net.UnixListener); ok {
+			// the new daemon listens on the socket file
+			ul.SetUnlinkOnClose(false)
+		}
+		fl, ok := nl.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		// Fd would switch the listener to blocking mode
+		rc, err := f.SyscallConn()
+		if err != nil {
+			return err
+		}
+		if err := rc.Control(func(fd uintptr) { fds = append(fds, int(fd)) }); err != nil {
+			return err
+		}
+		header.Addrs = append(header.Addrs, lis.Multiaddr().String())
+	}
+	if len(fds) > maxHandoverListeners {
+		return fmt.Errorf("can't hand over more than %d listeners", maxHandoverListeners)
+	}
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if _, _, err := conn.WriteMsgUnix(b, syscall.UnixRights(fds...), nil); err != nil {
+		return err
+	}
+
+	fmt.Println("Handing over to the new daemon, shutting down...")
+	notifyStopping()
+	go func() {
+		// the servers drain their requests before the node closes
+		defer conn.Close()
+		if err := node.Close(); err != nil {
+			log.Errorf("error while shutting down the daemon: %s", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,18 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"errors"
+
+	core "github.com/ipfs/kubo/core"
+)
+
+func requestHandover(repoRoot string) error {
+	return errors.New("ipfs daemon --replace is not supported on Windows")
+}
+
+func serveHandover(repoRoot string, node *core.IpfsNode) error {
+	return nil
+}
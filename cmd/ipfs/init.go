@@ -46,6 +46,13 @@ Initializes ipfs configuration files and generates a new keypair.
 If you are going to run IPFS in server environment, you may want to
 initialize it using 'server' profile.
 
+Multiple profiles can be given separated by ',', e.g. --profile=server,badgerds.
+They are applied from left to right: when profiles change the same settings,
+the last one wins. To set up a public gateway, use the 'gateway-public'
+profile. A profile exported from another node with 'ipfs config profile
+export' is applied by giving the path of its file, e.g.
+--profile=server,./fleet.json.
+
 For the list of available profiles see 'ipfs config profile --help'
 
 ipfs uses a repository in the local file system. By default, the repo is
@@ -127,19 +134,28 @@ environment variable:
 	},
 }
 
+// applyProfiles applies the comma separated profiles in order, so that the
+// settings of a profile override those of the profiles before it.
 func applyProfiles(conf *config.Config, profiles string) error {
 	if profiles == "" {
 		return nil
 	}
 
+	applied := make(map[string]bool)
 	for _, profile := range strings.Split(profiles, ",") {
-		transformer, ok := config.Profiles[profile]
-		if !ok {
-			return fmt.Errorf("invalid configuration profile: %s", profile)
+		profile = strings.TrimSpace(profile)
+		if applied[profile] {
+			return fmt.Errorf("configuration profile %s given more than once", profile)
+		}
+		applied[profile] = true
+
+		transformer, err := config.LookupProfile(profile)
+		if err != nil {
+			return fmt.Errorf("invalid configuration profile: %w", err)
 		}
 
 		if err := transformer.Transform(conf); err != nil {
-			return err
+			return fmt.Errorf("applying configuration profile %s: %w", profile, err)
 		}
 	}
 	return nil
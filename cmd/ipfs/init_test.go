@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+)
+
+func TestApplyProfiles(t *testing.T) {
+	conf := new(config.Config)
+	if err := applyProfiles(conf, "server, local-discovery"); err != nil {
+		t.Fatal(err)
+	}
+	// local-discovery is applied last and undoes server
+	if !conf.Discovery.MDNS.Enabled || len(conf.Swarm.AddrFilters) != 0 {
+		t.Fatal("expected the last profile to win")
+	}
+
+	conf = new(config.Config)
+	if err := applyProfiles(conf, "gateway-public"); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Gateway.RateLimit == nil || conf.Gateway.Writable != config.False || conf.Swarm.ResourceMgr.Enabled != config.True {
+		t.Fatal("gateway-public profile not applied")
+	}
+
+	for _, profiles := range []string{"server,server", "nope", "server,"} {
+		if err := applyProfiles(new(config.Config), profiles); err == nil {
+			t.Errorf("expected %q to be rejected", profiles)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	corecmds "github.com/ipfs/kubo/core/commands"
+)
+
+// progressOptionName is the option of the commands reporting their progress,
+// e.g. 'ipfs add --progress'.
+const progressOptionName = "progress"
+
+// withMachineOutput makes the executors of the commands run with --machine
+// output the values of the commands as JSON, one value per line.
+//
+// The CLI PostRun of the commands with structured output, which formats
+// them for humans and draws progress bars, is skipped: the values are
+// encoded as they are emitted, and their schema is shown by
+// 'ipfs commands schema'. The commands outputting raw data, e.g. 'ipfs cat'
+// or 'ipfs get', are unchanged.
+func withMachineOutput(makeExecutor cmds.MakeExecutor) cmds.MakeExecutor {
+	return func(req *cmds.Request, env interface{}) (cmds.Executor, error) {
+		exe, err := makeExecutor(req, env)
+		if err != nil {
+			return nil, err
+		}
+		if machine, _ := req.Options[corecmds.MachineOption].(bool); !machine {
+			return exe, nil
+		}
+
+		req.Options[cmds.EncLong] = cmds.JSON
+		if _, set := req.Options[progressOptionName]; !set && hasOption(req.Command, progressOptionName) {
+			req.Options[progressOptionName] = false
+		}
+		return &machineExecutor{exe}, nil
+	}
+}
+
+func hasOption(cmd *cmds.Command, name string) bool {
+	for _, opt := range cmd.Options {
+		for _, n := range opt.Names() {
+			if n == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type machineExecutor struct {
+	cmds.Executor
+}
+
+func (x *machineExecutor) Execute(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+	if req.Command.Type != nil {
+		re = machineEmitter{re}
+	}
+	return x.Executor.Execute(req, re, env)
+}
+
+// machineEmitter hides the type of the CLI response emitter it wraps, so that
+// the CLI PostRun of the command isn't run.
+type machineEmitter struct {
+	cmds.ResponseEmitter
+}
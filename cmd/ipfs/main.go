@@ -14,6 +14,7 @@ import (
 
 	"github.com/ipfs/kubo/cmd/ipfs/util"
 	oldcmds "github.com/ipfs/kubo/commands"
+	config "github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core"
 	corecmds "github.com/ipfs/kubo/core/commands"
 	"github.com/ipfs/kubo/core/corehttp"
@@ -185,7 +186,7 @@ func mainRet() (exitCode int) {
 		}, nil
 	}
 
-	err = cli.Run(ctx, Root, os.Args, os.Stdin, os.Stdout, os.Stderr, buildEnv, makeExecutor)
+	err = cli.Run(ctx, Root, os.Args, os.Stdin, os.Stdout, os.Stderr, buildEnv, withMachineOutput(makeExecutor))
 	if err != nil {
 		return 1
 	}
@@ -294,25 +295,49 @@ func makeExecutor(req *cmds.Request, env interface{}) (cmds.Executor, error) {
 		opts = append(opts, cmdhttp.ClientWithFallback(exe))
 	}
 
+	var transport http.RoundTripper = http.DefaultTransport
 	switch network {
 	case "tcp", "tcp4", "tcp6":
 	case "unix":
 		path := host
 		host = "unix"
-		opts = append(opts, cmdhttp.ClientWithHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
-					return net.Dial("unix", path)
-				},
+		transport = &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", path)
 			},
-		}))
+		}
 	default:
 		return nil, fmt.Errorf("unsupported API address: %s", apiAddr)
 	}
 
+	// The credentials go in the Authorization header, not in the query
+	// string with the other options.
+	if secret, _ := req.Options[corecmds.ApiAuthOption].(string); secret != "" {
+		delete(req.Options, corecmds.ApiAuthOption)
+		transport = &authTransport{
+			authorization: config.ConvertAuthSecret(secret),
+			next:          transport,
+		}
+	}
+	if transport != http.DefaultTransport {
+		opts = append(opts, cmdhttp.ClientWithHTTPClient(&http.Client{Transport: transport}))
+	}
+
 	return cmdhttp.NewClient(host, opts...), nil
 }
 
+// authTransport sets the Authorization header of the RPC API requests.
+type authTransport struct {
+	authorization string
+	next          http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", t.authorization)
+	return t.next.RoundTrip(r)
+}
+
 func getRepoPath(req *cmds.Request) (string, error) {
 	repoOpt, found := req.Options[corecmds.RepoDirOption].(string)
 	if found && repoOpt != "" {
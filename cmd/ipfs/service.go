@@ -0,0 +1,166 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	oldcmds "github.com/ipfs/kubo/commands"
+	commands "github.com/ipfs/kubo/core/commands"
+)
+
+const (
+	serviceLogFileOptionName = "log-file"
+	serviceDaemonArgsName    = "daemon-args"
+
+	// serviceShutdownTimeout is the time given to the daemon to shut down
+	// gracefully when the service is stopped, before it is killed.
+	serviceShutdownTimeout = time.Minute
+)
+
+var errServiceUnsupported = errors.New("ipfs service is only supported on Windows and macOS, see misc/systemd for systemd units")
+
+// serviceConfig is the configuration of the daemon run as a service.
+type serviceConfig struct {
+	// exe is the absolute path to the ipfs binary
+	exe string
+	// repo is the absolute path to the repository
+	repo string
+	// logFile receives the output of the daemon
+	logFile string
+	// args are the arguments of 'ipfs daemon'
+	args []string
+}
+
+var serviceCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Run the daemon as a service of the operating system.",
+		ShortDescription: `
+Installs the daemon as a Windows service, or as a launchd agent on macOS, so
+it starts with the system and restarts when it crashes. On Linux, use the
+systemd units in misc/systemd instead.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"install":   serviceInstallCmd,
+		"uninstall": serviceUninstallCmd,
+		"run":       serviceRunCmd,
+	},
+}
+
+var serviceInstallCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Install the daemon as a service.",
+		ShortDescription: `
+Installs and starts the daemon as a service using the current ipfs binary and
+repository. The arguments are passed to 'ipfs daemon', e.g.:
+
+    ipfs service install -- --enable-gc --migrate
+
+The output of the daemon is written to the file given with --log-file,
+<repo>/logs/daemon.log by default. When the service is stopped, the daemon is
+given a minute to shut down gracefully.
+
+On Windows, the service is installed for the whole system, which requires an
+elevated prompt, and its lifecycle is reported in the Windows Event Log. On
+macOS, a launchd agent is installed for the current user.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg(serviceDaemonArgsName, false, true, "Arguments of 'ipfs daemon'."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(serviceLogFileOptionName, "File receiving the output of the daemon. Default: <repo>/logs/daemon.log"),
+	},
+	NoRemote: true,
+	Extra:    commands.CreateCmdExtras(commands.SetDoesNotUseRepo(true)),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfg, err := getServiceConfig(req, env)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(cfg.logFile), 0o700); err != nil {
+			return err
+		}
+		if err := installService(cfg); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stdout, "installed the ipfs service for %s, logging to %s\n", cfg.repo, cfg.logFile)
+		return nil
+	},
+}
+
+var serviceUninstallCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stop and uninstall the daemon service.",
+	},
+	NoRemote: true,
+	Extra:    commands.CreateCmdExtras(commands.SetDoesNotUseRepo(true)),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if err := uninstallService(); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stdout, "uninstalled the ipfs service")
+		return nil
+	},
+}
+
+var serviceRunCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Run the daemon under the Windows service manager.",
+		ShortDescription: `
+Runs the daemon as a Windows service. It is started by the service manager of
+the service installed with 'ipfs service install', and isn't meant to be run
+by hand.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg(serviceDaemonArgsName, false, true, "Arguments of 'ipfs daemon'."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(serviceLogFileOptionName, "File receiving the output of the daemon. Default: <repo>/logs/daemon.log"),
+	},
+	NoRemote: true,
+	Extra:    commands.CreateCmdExtras(commands.SetDoesNotUseRepo(true)),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfg, err := getServiceConfig(req, env)
+		if err != nil {
+			return err
+		}
+		return runService(cfg)
+	},
+}
+
+func getServiceConfig(req *cmds.Request, env cmds.Environment) (*serviceConfig, error) {
+	cctx := env.(*oldcmds.Context)
+
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return nil, err
+	}
+	repo, err := filepath.Abs(cctx.ConfigRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	logFile, _ := req.Options[serviceLogFileOptionName].(string)
+	if logFile == "" {
+		logFile = filepath.Join(repo, "logs", "daemon.log")
+	}
+	if logFile, err = filepath.Abs(logFile); err != nil {
+		return nil, err
+	}
+
+	return &serviceConfig{
+		exe:     exe,
+		repo:    repo,
+		logFile: logFile,
+		args:    req.Arguments,
+	}, nil
+}
@@ -0,0 +1,134 @@
+//go:build darwin
+// +build darwin
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+// launchdLabel is the label of the launchd agent, shared with
+// misc/launchd/io.ipfs.ipfs-daemon.plist.
+const launchdLabel = "io.ipfs.ipfs-daemon"
+
+// launchd sends SIGTERM to stop the daemon, which shuts down gracefully, and
+// SIGKILL after ExitTimeOut. KeepAlive restarts the daemon when it crashes,
+// but not when it exits cleanly, e.g. after 'ipfs shutdown'.
+var launchdPlist = template.Must(template.New("plist").Funcs(template.FuncMap{
+	"xml": func(s string) (string, error) {
+		var b bytes.Buffer
+		err := xml.EscapeText(&b, []byte(s))
+		return b.String(), err
+	},
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+  <dict>
+    <key>Label</key>
+    <string>{{.Label}}</string>
+    <key>ProgramArguments</key>
+    <array>
+      <string>{{xml .Exe}}</string>
+      <string>daemon</string>
+{{- range .Args}}
+      <string>{{xml .}}</string>
+{{- end}}
+    </array>
+    <key>EnvironmentVariables</key>
+    <dict>
+      <key>IPFS_PATH</key>
+      <string>{{xml .Repo}}</string>
+    </dict>
+    <key>StandardOutPath</key>
+    <string>{{xml .LogFile}}</string>
+    <key>StandardErrorPath</key>
+    <string>{{xml .LogFile}}</string>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <dict>
+      <key>SuccessfulExit</key>
+      <false/>
+    </dict>
+    <key>ExitTimeOut</key>
+    <integer>{{.ExitTimeOut}}</integer>
+    <key>ProcessType</key>
+    <string>Background</string>
+  </dict>
+</plist>
+`))
+
+func launchdPlistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %w: %s", args[0], err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func installService(cfg *serviceConfig) error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); err == nil {
+		return fmt.Errorf("the ipfs service is already installed at %s, run 'ipfs service uninstall' first", p)
+	}
+
+	var b bytes.Buffer
+	err = launchdPlist.Execute(&b, map[string]interface{}{
+		"Label":       launchdLabel,
+		"Exe":         cfg.exe,
+		"Args":        cfg.args,
+		"Repo":        cfg.repo,
+		"LogFile":     cfg.logFile,
+		"ExitTimeOut": int(serviceShutdownTimeout.Seconds()),
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, b.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := launchctl("load", "-w", p); err != nil {
+		_ = os.Remove(p)
+		return err
+	}
+	return nil
+}
+
+func uninstallService() error {
+	p, err := launchdPlistPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(p); errors.Is(err, os.ErrNotExist) {
+		return errors.New("the ipfs service is not installed")
+	}
+	if err := launchctl("unload", "-w", p); err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func runService(cfg *serviceConfig) error {
+	return errors.New("ipfs service run is only used by Windows services, launchd runs 'ipfs daemon'")
+}
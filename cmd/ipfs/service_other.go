@@ -0,0 +1,16 @@
+//go:build !windows && !darwin
+// +build !windows,!darwin
+
+package main
+
+func installService(cfg *serviceConfig) error {
+	return errServiceUnsupported
+}
+
+func uninstallService() error {
+	return errServiceUnsupported
+}
+
+func runService(cfg *serviceConfig) error {
+	return errServiceUnsupported
+}
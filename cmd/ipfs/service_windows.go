@@ -0,0 +1,184 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	commands "github.com/ipfs/kubo/core/commands"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsServiceName is the name of the service, and the source of its
+// events in the Windows Event Log.
+const windowsServiceName = "ipfs"
+
+func installService(cfg *serviceConfig) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager, is the prompt elevated? %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(windowsServiceName); err == nil {
+		s.Close()
+		return errors.New("the ipfs service is already installed, run 'ipfs service uninstall' first")
+	}
+
+	args := []string{"--" + commands.RepoDirOption, cfg.repo, "service", "run", "--" + serviceLogFileOptionName, cfg.logFile}
+	if len(cfg.args) > 0 {
+		args = append(append(args, "--"), cfg.args...)
+	}
+	s, err := m.CreateService(windowsServiceName, cfg.exe, mgr.Config{
+		DisplayName:      "IPFS daemon (kubo)",
+		Description:      "Runs the IPFS daemon for the repository at " + cfg.repo,
+		StartType:        mgr.StartAutomatic,
+		DelayedAutoStart: true,
+	}, args...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	// restart the daemon when it crashes, backing off
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		_ = s.Delete()
+		return err
+	}
+
+	err = eventlog.InstallAsEventCreate(windowsServiceName, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil {
+		_ = s.Delete()
+		return err
+	}
+
+	return s.Start()
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to the service manager, is the prompt elevated? %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(windowsServiceName)
+	if err != nil {
+		return errors.New("the ipfs service is not installed")
+	}
+	defer s.Close()
+
+	if status, err := s.Query(); err == nil && status.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return err
+		}
+		// the service is deleted once stopped
+	}
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	return eventlog.Remove(windowsServiceName)
+}
+
+func runService(cfg *serviceConfig) error {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		return err
+	}
+	if !isService {
+		return errors.New("ipfs service run must be started by the Windows service manager, use 'ipfs daemon' instead")
+	}
+
+	elog, err := eventlog.Open(windowsServiceName)
+	if err != nil {
+		return err
+	}
+	defer elog.Close()
+
+	return svc.Run(windowsServiceName, &windowsService{cfg: cfg, elog: elog})
+}
+
+// windowsService supervises 'ipfs daemon', stopping it gracefully with
+// 'ipfs shutdown' when the service is stopped.
+type windowsService struct {
+	cfg  *serviceConfig
+	elog *eventlog.Log
+}
+
+func (ws *windowsService) command(args ...string) *exec.Cmd {
+	return exec.Command(ws.cfg.exe, append([]string{"--" + commands.RepoDirOption, ws.cfg.repo}, args...)...)
+}
+
+func (ws *windowsService) Execute(_ []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	logFile, err := os.OpenFile(ws.cfg.logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		_ = ws.elog.Error(1, fmt.Sprintf("opening the log file: %s", err))
+		return true, 1
+	}
+	defer logFile.Close()
+
+	daemon := ws.command(append([]string{"daemon"}, ws.cfg.args...)...)
+	daemon.Stdout = logFile
+	daemon.Stderr = logFile
+	if err := daemon.Start(); err != nil {
+		_ = ws.elog.Error(1, fmt.Sprintf("starting the daemon: %s", err))
+		return true, 1
+	}
+	done := make(chan error, 1)
+	go func() { done <- daemon.Wait() }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	_ = ws.elog.Info(1, fmt.Sprintf("started the daemon for %s, logging to %s", ws.cfg.repo, ws.cfg.logFile))
+
+	for {
+		select {
+		case err := <-done:
+			// a non zero exit code lets the recovery actions restart the
+			// daemon
+			_ = ws.elog.Error(1, fmt.Sprintf("the daemon exited: %v", err))
+			return true, 1
+
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				changes <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				ws.stop(daemon, done)
+				return false, 0
+			default:
+				_ = ws.elog.Warning(1, fmt.Sprintf("unexpected service control request #%d", c.Cmd))
+			}
+		}
+	}
+}
+
+// stop shuts down the daemon gracefully, and kills it if it doesn't exit in
+// time.
+func (ws *windowsService) stop(daemon *exec.Cmd, done <-chan error) {
+	if out, err := ws.command("shutdown").CombinedOutput(); err != nil {
+		_ = ws.elog.Warning(1, fmt.Sprintf("ipfs shutdown: %s: %s", err, out))
+	}
+	select {
+	case <-done:
+		_ = ws.elog.Info(1, "stopped the daemon")
+	case <-time.After(serviceShutdownTimeout):
+		_ = ws.elog.Warning(1, "the daemon didn't shut down in time, killing it")
+		_ = daemon.Process.Kill()
+		<-done
+	}
+}
@@ -26,7 +26,10 @@ type Context struct {
 
 	Plugins *loader.PluginLoader
 
-	Gateway       bool
+	Gateway bool
+	// RPC is set when the commands are served over the RPC API, rather than
+	// run by the CLI.
+	RPC           bool
 	api           coreiface.CoreAPI
 	node          *core.IpfsNode
 	ConstructNode func() (*core.IpfsNode, error)
@@ -1,5 +1,9 @@
 package config
 
+// DefaultCompanionProxyAddress is the address of the companion proxy of
+// 'ipfs daemon --companion-proxy', when Addresses.CompanionProxy is empty.
+const DefaultCompanionProxyAddress = "/ip4/127.0.0.1/tcp/8082"
+
 // Addresses stores the (string) multiaddr addresses for the node.
 type Addresses struct {
 	Swarm          []string // addresses for the swarm to listen on
@@ -8,4 +12,6 @@ type Addresses struct {
 	NoAnnounce     []string // swarm addresses not to announce to the network
 	API            Strings  // address for the local API (RPC)
 	Gateway        Strings  // address to listen on for IPFS HTTP object gateway
+	GRPC           Strings  `json:",omitempty"` // addresses for the gRPC API, disabled if empty
+	CompanionProxy Strings  `json:",omitempty"` // loopback addresses of the proxy of 'ipfs daemon --companion-proxy'
 }
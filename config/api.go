@@ -1,5 +1,110 @@
 package config
 
+import (
+	"encoding/base64"
+	"strings"
+)
+
 type API struct {
 	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+
+	// WebUI configures which WebUI build is served at /webui.
+	WebUI WebUI `json:",omitempty"`
+
+	// FetchBudget bounds the blocks fetched by each request to the RPC API.
+	FetchBudget *FetchBudget `json:",omitempty"`
+
+	// Admin configures the signed batches of operations run by
+	// 'ipfs admin exec'.
+	Admin *Admin `json:",omitempty"`
+
+	// Audit configures the audit log of the RPC calls changing the node.
+	Audit *APIAudit `json:",omitempty"`
+
+	// Authorizations are the users of the RPC API, by name. Once one is set,
+	// the calls must authenticate with the secret of a user.
+	Authorizations map[string]*RPCAuthScope `json:",omitempty"`
+
+	// HTTP3 configures the HTTP/3 server of the RPC API.
+	HTTP3 *HTTP3 `json:",omitempty"`
+}
+
+// RPCAuthScope is a user of the RPC API.
+type RPCAuthScope struct {
+	// AuthSecret is the secret of the user, "bearer:<token>" or
+	// "basic:<username>:<password>".
+	AuthSecret string
+
+	// AllowedPaths are the prefixes of the RPC paths the user can call, such
+	// as "/api/v0/pin". All the paths are allowed when empty.
+	AllowedPaths []string `json:",omitempty"`
+
+	// Space scopes the user to a space: the pins, the MFS root and the keys
+	// of the user are the ones of the space, and the user can't see or change
+	// the ones of the node nor of the other users.
+	Space *OptionalString `json:",omitempty"`
+}
+
+// ConvertAuthSecret returns the value of the Authorization header sent with
+// the secret of an RPCAuthScope: "bearer:<token>" is sent as a bearer token,
+// "basic:<username>:<password>" and "<username>:<password>" with the basic
+// scheme, anything else as is.
+func ConvertAuthSecret(secret string) string {
+	switch {
+	case strings.HasPrefix(secret, "bearer:"):
+		return "Bearer " + strings.TrimPrefix(secret, "bearer:")
+	case strings.HasPrefix(secret, "basic:"):
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(strings.TrimPrefix(secret, "basic:")))
+	case strings.Contains(secret, ":"):
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(secret))
+	default:
+		return secret
+	}
+}
+
+// APIAudit configures the audit log of the RPC calls changing the node, such
+// as 'ipfs add', 'ipfs pin', 'ipfs config', 'ipfs key' and the writes of
+// 'ipfs files'.
+type APIAudit struct {
+	// Enabled turns the audit log on.
+	Enabled Flag `json:",omitempty"`
+
+	// Path is the file of the log, relative to the repo.
+	Path *OptionalString `json:",omitempty"`
+
+	// MaxSize is the size past which the file is rotated, e.g. "100MiB".
+	MaxSize *OptionalString `json:",omitempty"`
+
+	// MaxFiles is the number of rotated files kept.
+	MaxFiles *OptionalInteger `json:",omitempty"`
+}
+
+const (
+	DefaultAPIAuditPath     = "api-audit.log"
+	DefaultAPIAuditMaxSize  = "100MiB"
+	DefaultAPIAuditMaxFiles = 10
+)
+
+// Admin configures the signed batches of operations with which a fleet of
+// nodes is managed. The batches are refused when no key is authorized.
+type Admin struct {
+	// AuthorizedKeys are the peer IDs of the keys allowed to sign batches.
+	AuthorizedKeys []string `json:",omitempty"`
+
+	// AuditLog is the file the batches received are logged to, relative to
+	// the repo.
+	AuditLog *OptionalString `json:",omitempty"`
+}
+
+const DefaultAdminAuditLog = "admin-audit.log"
+
+// WebUI configures the WebUI served on the RPC API port.
+type WebUI struct {
+	// Path is the content path (/ipfs/{cid}) of the WebUI build to serve
+	// instead of the version bundled with this release.
+	Path *OptionalString `json:",omitempty"`
+
+	// Dir is a local directory with a WebUI build. When set, the WebUI is
+	// served from disk and nothing is fetched from the network.
+	Dir *OptionalString `json:",omitempty"`
 }
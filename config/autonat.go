@@ -2,8 +2,13 @@ package config
 
 import (
 	"fmt"
+	"time"
 )
 
+// DefaultAutoNATV2Interval is the interval between two checks of the
+// addresses with AutoNAT v2.
+const DefaultAutoNATV2Interval = 15 * time.Minute
+
 // AutoNATServiceMode configures the ipfs node's AutoNAT service.
 type AutoNATServiceMode int
 
@@ -62,6 +67,11 @@ type AutoNATConfig struct {
 	// By default, the limits will be a total of 30 dialbacks, with a
 	// per-peer max of 3 peer, resetting every minute.
 	Throttle *AutoNATThrottleConfig `json:",omitempty"`
+
+	// V2 enables AutoNAT v2: the node checks the reachability of each of
+	// its addresses, and stops advertising the addresses found private.
+	// The node serves AutoNAT v2 requests unless ServiceMode is disabled.
+	V2 Flag `json:",omitempty"`
 }
 
 // AutoNATThrottleConfig configures the throttle limites
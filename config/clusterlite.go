@@ -0,0 +1,41 @@
+package config
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	DefaultClusterLiteName                = "default"
+	DefaultClusterLiteReplicationFactor   = 2
+	DefaultClusterLiteRebroadcastInterval = time.Minute
+	DefaultClusterLitePinTimeout          = 10 * time.Minute
+)
+
+// ClusterLite configures the experimental replication of a shared pinset
+// between mutually trusting peers.
+type ClusterLite struct {
+	// Enabled turns on the cluster-lite subsystem. Requires Pubsub.Enabled.
+	Enabled Flag `json:",omitempty"`
+
+	// Name identifies the cluster. Only peers using the same name share a
+	// pinset.
+	Name *OptionalString `json:",omitempty"`
+
+	// Peers lists the trusted members of the cluster, besides this node.
+	// Updates from other peers are ignored.
+	Peers []peer.ID
+
+	// ReplicationFactor is the number of members each pin is stored on.
+	// Values larger than the number of members pin everything everywhere.
+	ReplicationFactor *OptionalInteger `json:",omitempty"`
+
+	// RebroadcastInterval is how often the full pinset is gossiped to the
+	// other members.
+	RebroadcastInterval *OptionalDuration `json:",omitempty"`
+
+	// PinTimeout bounds the fetching and pinning of each CID allocated to
+	// this node. The CIDs failing to pin are retried at the next rebroadcast.
+	PinTimeout *OptionalDuration `json:",omitempty"`
+}
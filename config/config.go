@@ -30,12 +30,16 @@ type Config struct {
 	Peering   Peering
 	DNS       DNS
 	Migration Migration
+	Offline   Offline  // subsystems enabled in offline mode
+	Webhooks  Webhooks // HTTP callbacks for node events
 
 	Provider     Provider
 	Reprovider   Reprovider
 	Experimental Experiments
 	Plugins      Plugins
 	Pinning      Pinning
+	DagImport    DagImport   // server-side 'dag import' policies
+	ClusterLite  ClusterLite // experimental shared pinset between trusted peers
 
 	Internal Internal // experimental/unstable options
 }
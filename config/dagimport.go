@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DagImportPinRootsClient lets the client decide whether roots are
+	// pinned, with the --pin-roots option.
+	DagImportPinRootsClient = "client"
+	// DagImportPinRootsAlways pins roots regardless of the client request.
+	DagImportPinRootsAlways = "always"
+	// DagImportPinRootsNever never pins roots.
+	DagImportPinRootsNever = "never"
+
+	DefaultDagImportPinRoots     = DagImportPinRootsClient
+	DefaultDagImportUploadExpiry = 24 * time.Hour
+)
+
+// DagImport configures server-side policies of 'ipfs dag import' and of the
+// resumable CAR upload endpoint of the RPC API.
+type DagImport struct {
+	// PinRoots is the root pinning policy: "client", "always" or "never".
+	PinRoots *OptionalString `json:",omitempty"`
+
+	// MaxUploadSize is the maximum size, in bytes, of a single resumable
+	// CAR upload. Zero means no limit.
+	MaxUploadSize *OptionalInteger `json:",omitempty"`
+
+	// UploadExpiry is how long an incomplete resumable upload is kept
+	// after its last chunk was received.
+	UploadExpiry *OptionalDuration `json:",omitempty"`
+}
+
+// ResolvePinRoots applies the PinRoots policy to the pinning requested by the
+// client.
+func (d DagImport) ResolvePinRoots(requested bool) (bool, error) {
+	switch policy := d.PinRoots.WithDefault(DefaultDagImportPinRoots); policy {
+	case DagImportPinRootsClient:
+		return requested, nil
+	case DagImportPinRootsAlways:
+		return true, nil
+	case DagImportPinRootsNever:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid DagImport.PinRoots policy %q, expected %q, %q or %q",
+			policy, DagImportPinRootsClient, DagImportPinRootsAlways, DagImportPinRootsNever)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"time"
 )
 
 // DefaultDataStoreDirectory is the directory to store all the local IPFS data.
@@ -23,6 +24,59 @@ type Datastore struct {
 
 	HashOnRead      bool
 	BloomFilterSize int
+
+	// Quotas limits the space taken by pinned data, MFS and the cache.
+	Quotas DatastoreQuotas
+
+	// CacheEviction keeps the cache under Quotas.Cache between GC runs.
+	CacheEviction CacheEviction
+
+	// Scrub slowly verifies the blocks in the background.
+	Scrub DatastoreScrub
+}
+
+// DatastoreQuotas limits the space taken by each namespace of the repo, in
+// B, kB, kiB, MB, ... Blocks are attributed to pins first, then to MFS, and
+// the remaining blocks are the cache.
+type DatastoreQuotas struct {
+	Pins  *OptionalString `json:",omitempty"`
+	MFS   *OptionalString `json:",omitempty"`
+	Cache *OptionalString `json:",omitempty"`
+}
+
+const (
+	// CacheEvictionNone disables cache eviction.
+	CacheEvictionNone = "none"
+	// CacheEvictionLRU evicts the least recently used blocks first.
+	CacheEvictionLRU = "lru"
+
+	DefaultCacheEvictionPolicy   = CacheEvictionNone
+	DefaultCacheEvictionInterval = time.Minute
+)
+
+// CacheEviction configures the eviction of unpinned blocks.
+type CacheEviction struct {
+	// Policy is either "none" or "lru".
+	Policy *OptionalString `json:",omitempty"`
+	// Interval is the time between two checks of the cache size.
+	Interval *OptionalDuration `json:",omitempty"`
+}
+
+const (
+	DefaultScrubInterval = 7 * 24 * time.Hour
+	DefaultScrubRate     = "1MiB"
+)
+
+// DatastoreScrub configures the background scrubber, which re-reads the
+// blocks, verifies their hash, and records the health of each shard of the
+// blockstore.
+type DatastoreScrub struct {
+	Enabled Flag `json:",omitempty"`
+	// Interval is the minimum time between two checks of a shard.
+	Interval *OptionalDuration `json:",omitempty"`
+	// Rate is the maximum number of bytes read per second, in B, kB, kiB,
+	// MB, ...
+	Rate *OptionalString `json:",omitempty"`
 }
 
 // DataStorePath returns the default data store path given a configuration root
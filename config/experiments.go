@@ -9,4 +9,5 @@ type Experiments struct {
 	P2pHttpProxy         bool //nolint
 	StrategicProviding   bool
 	AcceleratedDHTClient bool
+	TrafficShaping       bool
 }
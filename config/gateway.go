@@ -1,6 +1,12 @@
 package config
 
-const DefaultInlineDNSLink = false
+const (
+	DefaultInlineDNSLink         = false
+	DefaultDeserializedResponses = true
+	DefaultDirectoryPageSize     = 1000
+	DefaultPopularityTopN        = 1000
+	DefaultCapabilityKey         = "self"
+)
 
 type GatewaySpec struct {
 	// Paths is explicit list of path prefixes that should be handled by
@@ -25,6 +31,100 @@ type GatewaySpec struct {
 	// (FQDN) into a single DNS label in order to interop with wildcard TLS certs
 	// and Origin per CID isolation provided by rules like https://publicsuffix.org
 	InlineDNSLink Flag
+
+	// RootPath configures this hostname to serve a fixed content path
+	// (/ipfs/{cid} or /ipns/{name}) for every request, turning the hostname
+	// into a static website. When set, Paths, UseSubdomains and DNSLink are
+	// ignored for this hostname.
+	RootPath string `json:",omitempty"`
+
+	// HTTPHeaders configures additional headers returned for requests to this
+	// hostname. Values set here take precedence over Gateway.HTTPHeaders.
+	HTTPHeaders map[string][]string `json:",omitempty"`
+
+	// DeserializedResponses configures whether this hostname returns
+	// deserialized responses (files, directory listings, etc). When disabled,
+	// only verifiable responses (raw blocks, CARs, IPNS records) are allowed.
+	DeserializedResponses Flag `json:",omitempty"`
+
+	// RateLimit configures a limit on the number of requests served for this
+	// hostname. Requests over the limit are rejected with HTTP 429.
+	RateLimit *GatewayRateLimit `json:",omitempty"`
+}
+
+// GatewayRateLimit configures a token bucket shared by all requests to a
+// single hostname.
+type GatewayRateLimit struct {
+	// RequestsPerSecond is the sustained rate of requests allowed.
+	RequestsPerSecond *OptionalInteger `json:",omitempty"`
+
+	// Burst is the maximum number of requests allowed at once.
+	Burst *OptionalInteger `json:",omitempty"`
+}
+
+// FetchBudget bounds the blocks fetched from the network by a single request,
+// protecting the node from the requests of deep or wide DAGs. The cost of the
+// requests is logged, even without a limit.
+type FetchBudget struct {
+	// MaxBlocks is the maximum number of blocks fetched by a request.
+	MaxBlocks *OptionalInteger `json:",omitempty"`
+
+	// MaxBytes is the maximum size of the blocks fetched by a request, e.g.
+	// "512MiB".
+	MaxBytes *OptionalString `json:",omitempty"`
+}
+
+// GatewayLimits bounds the shape of the DAGs resolved by the gateway,
+// protecting public gateways from crafted DAGs. 0 disables a limit.
+type GatewayLimits struct {
+	// MaxPathDepth is the maximum number of segments of the content paths,
+	// after the root.
+	MaxPathDepth *OptionalInteger `json:",omitempty"`
+
+	// MaxDirectoryLinks is the maximum number of entries rendered in the
+	// listings of directories.
+	MaxDirectoryLinks *OptionalInteger `json:",omitempty"`
+
+	// MaxHAMTFanout is the maximum fanout of the shards of HAMT-sharded
+	// directories traversed.
+	MaxHAMTFanout *OptionalInteger `json:",omitempty"`
+
+	// MaxBlockSize is the maximum size of the blocks read, e.g. "1MiB".
+	MaxBlockSize *OptionalString `json:",omitempty"`
+}
+
+// GatewayCapabilities configures the content paths only served to the
+// requests with a capability token, issued by 'ipfs key share'.
+type GatewayCapabilities struct {
+	// Key is the name of the ed25519 key of the keystore signing the tokens,
+	// "self" by default.
+	Key *OptionalString `json:",omitempty"`
+
+	// Paths are the protected content paths, e.g. /ipfs/{cid} or
+	// /ipns/{name}/private, along with their subpaths.
+	Paths []string
+}
+
+// GatewayCompression configures the compression of the responses of the
+// gateway.
+type GatewayCompression struct {
+	// Enabled turns the compression on.
+	Enabled Flag `json:",omitempty"`
+
+	// Encodings are the content codings offered, in order of preference:
+	// "zstd", "br" and "gzip".
+	Encodings []string `json:",omitempty"`
+
+	// Level is the compression level: "fastest", "default" or "best".
+	Level *OptionalString `json:",omitempty"`
+
+	// MinSize is the size under which the responses of a known length are
+	// sent as is, e.g. "1KiB".
+	MinSize *OptionalString `json:",omitempty"`
+
+	// MaxConcurrent bounds the responses compressed at once, the others are
+	// sent as is. The number of CPUs by default.
+	MaxConcurrent *OptionalInteger `json:",omitempty"`
 }
 
 // Gateway contains options for the HTTP gateway server.
@@ -57,6 +157,45 @@ type Gateway struct {
 	// This flag can be overridden per FQDN in PublicGateways.
 	NoDNSLink bool
 
+	// DirectoryPageSize is the maximum number of entries in the listings of
+	// HAMT-sharded directories. The next pages are requested with the
+	// "offset" query parameter. 0 disables the paging.
+	DirectoryPageSize *OptionalInteger `json:",omitempty"`
+
+	// PopularityTopN is the number of paths whose requests and bytes served
+	// are counted, reported by 'ipfs stats gateway'. 0 disables the
+	// counting.
+	PopularityTopN *OptionalInteger `json:",omitempty"`
+
+	// RateLimit configures a limit on the number of requests served by the
+	// gateway, for all hostnames. Limits configured in PublicGateways apply
+	// on top of it.
+	RateLimit *GatewayRateLimit `json:",omitempty"`
+
+	// EarlyHints enables 103 Early Hints responses preloading the
+	// stylesheets and scripts of the HTML documents served by the gateway.
+	EarlyHints Flag `json:",omitempty"`
+
+	// Capabilities configures the paths requiring a capability token.
+	Capabilities *GatewayCapabilities `json:",omitempty"`
+
+	// Authorizers are the names of the authorizers, registered by plugins,
+	// deciding in order whether the requests are served, denied or require
+	// a payment.
+	Authorizers []string `json:",omitempty"`
+
+	// FetchBudget bounds the blocks fetched by each request to the gateway.
+	FetchBudget *FetchBudget `json:",omitempty"`
+
+	// Limits bounds the shape of the DAGs resolved by the gateway.
+	Limits *GatewayLimits `json:",omitempty"`
+
+	// HTTP3 configures the HTTP/3 server of the gateway.
+	HTTP3 *HTTP3 `json:",omitempty"`
+
+	// Compression configures the compression of the responses.
+	Compression *GatewayCompression `json:",omitempty"`
+
 	// PublicGateways configures behavior of known public gateways.
 	// Each key is a fully qualified domain name (FQDN).
 	PublicGateways map[string]*GatewaySpec
@@ -0,0 +1,23 @@
+package config
+
+import "time"
+
+// DefaultHTTP3AltSvcMaxAge is the default duration clients remember that a
+// server is reachable over HTTP/3.
+const DefaultHTTP3AltSvcMaxAge = 24 * time.Hour
+
+// HTTP3 configures the HTTP/3 server of the gateway or of the RPC API,
+// listening over QUIC on the UDP ports of their TCP addresses.
+type HTTP3 struct {
+	// Enabled turns the HTTP/3 server on.
+	Enabled Flag `json:",omitempty"`
+
+	// CertFile and KeyFile are the PEM files of the TLS certificate of the
+	// server, and of its private key, relative to the repo.
+	CertFile *OptionalString `json:",omitempty"`
+	KeyFile  *OptionalString `json:",omitempty"`
+
+	// AltSvcMaxAge is how long clients remember the HTTP/3 server
+	// advertised in the Alt-Svc header of the responses.
+	AltSvcMaxAge *OptionalDuration `json:",omitempty"`
+}
@@ -5,6 +5,12 @@ type Internal struct {
 	Bitswap                     *InternalBitswap `json:",omitempty"`
 	UnixFSShardingSizeThreshold *OptionalString  `json:",omitempty"`
 	Libp2pForceReachability     *OptionalString  `json:",omitempty"`
+	// MemoryBudget is the memory the daemon tries to stay within, e.g.
+	// "512MiB", by tuning the garbage collector and dropping its caches
+	MemoryBudget *OptionalString `json:",omitempty"`
+	// MFSFlushInterval batches the flushes of the MFS root of the changes
+	// made without flushing, e.g. 'ipfs files write --flush=false'
+	MFSFlushInterval *OptionalDuration `json:",omitempty"`
 }
 
 type InternalBitswap struct {
@@ -13,4 +19,6 @@ type InternalBitswap struct {
 	EngineTaskWorkerCount       OptionalInteger
 	MaxOutstandingBytesPerPeer  OptionalInteger
 	ProviderSearchDelay         OptionalDuration
+	BroadcastFanOut             OptionalInteger
+	UnresponsivePeerThreshold   OptionalInteger
 }
@@ -0,0 +1,11 @@
+package config
+
+// Offline configures which subsystems stay enabled when the node runs in
+// offline mode (ipfs daemon --offline). Everything that talks to the network
+// is disabled by default.
+type Offline struct {
+	// DelegatedRouting keeps HTTP delegated routers enabled while offline.
+	// When Routing.Type is "custom", routers of type "http" from
+	// Routing.Routers are used, otherwise the default HTTP routers are used.
+	DelegatedRouting Flag `json:",omitempty"`
+}
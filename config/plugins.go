@@ -3,9 +3,34 @@ package config
 type Plugins struct {
 	Plugins map[string]Plugin
 	// TODO: Loader Path? Leaving that out for now due to security concerns.
+
+	// Remote lists plugins running in their own process, keyed by plugin
+	// name. Their config is read from Plugins like for any other plugin.
+	Remote map[string]RemotePlugin `json:",omitempty"`
 }
 
 type Plugin struct {
 	Disabled bool
 	Config   interface{}
 }
+
+// RemotePluginsSelector is the config key of the remote plugins, which can't
+// be changed over the API: their executables are run by the daemon.
+var RemotePluginsSelector = []string{"Plugins", "Remote"}
+
+const (
+	RemotePluginDatastore = "datastore"
+	RemotePluginRouting   = "routing"
+)
+
+// RemotePlugin describes a plugin executable Kubo talks to over gRPC.
+type RemotePlugin struct {
+	// Type of the plugin, "datastore" or "routing".
+	Type string
+
+	// Path to the plugin executable.
+	Path string
+
+	// Args are passed to the plugin executable.
+	Args []string `json:",omitempty"`
+}
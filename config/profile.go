@@ -49,13 +49,7 @@ var Profiles = map[string]Profile{
 		Description: `Disables local host discovery, recommended when
 running IPFS on machines with public IPv4 addresses.`,
 
-		Transform: func(c *Config) error {
-			c.Addresses.NoAnnounce = appendSingle(c.Addresses.NoAnnounce, defaultServerFilters)
-			c.Swarm.AddrFilters = appendSingle(c.Swarm.AddrFilters, defaultServerFilters)
-			c.Discovery.MDNS.Enabled = false
-			c.Swarm.DisableNatPortMap = true
-			return nil
-		},
+		Transform: serverTransform,
 	},
 
 	"local-discovery": {
@@ -173,18 +167,59 @@ This profile may only be applied when first initializing the node.`,
 functionality - performance of content discovery and data
 fetching may be degraded.
 `,
+		Transform: lowPowerTransform,
+	},
+	"lowpower-v2": {
+		Description: `Applies the lowpower profile, and keeps the daemon within a
+memory budget of 512MiB, for devices such as the Raspberry Pi or a NAS: the
+garbage collector is tuned to the budget, the block caches are disabled, and
+the bitswap queues, the DHT routing table, the connections and the memory of
+the resource manager are reduced.
+`,
+		Transform: func(c *Config) error {
+			if err := lowPowerTransform(c); err != nil {
+				return err
+			}
+			c.Internal.MemoryBudget = NewOptionalString("512MiB")
+			c.Datastore.BloomFilterSize = 0
+
+			if c.Internal.Bitswap == nil {
+				c.Internal.Bitswap = new(InternalBitswap)
+			}
+			c.Internal.Bitswap.TaskWorkerCount = *NewOptionalInteger(2)
+			c.Internal.Bitswap.EngineTaskWorkerCount = *NewOptionalInteger(2)
+			c.Internal.Bitswap.EngineBlockstoreWorkerCount = *NewOptionalInteger(16)
+			c.Internal.Bitswap.MaxOutstandingBytesPerPeer = *NewOptionalInteger(256 << 10)
+
+			c.Swarm.ConnMgr.LowWater = NewOptionalInteger(10)
+			c.Swarm.ConnMgr.HighWater = NewOptionalInteger(20)
+			c.Swarm.ResourceMgr.Enabled = True
+			c.Swarm.ResourceMgr.MaxMemory = NewOptionalString("128MiB")
+			return nil
+		},
+	},
+	"gateway-public": {
+		Description: `Hardens the node for serving a public gateway: applies the
+server filters, fetches content from the network, disables the writable
+gateway, keeps the RPC API on localhost, rate limits the requests and enables
+the resource manager.`,
+
 		Transform: func(c *Config) error {
-			c.Routing.Type = NewOptionalString("dhtclient") // TODO: https://github.com/ipfs/kubo/issues/9480
-			c.AutoNAT.ServiceMode = AutoNATServiceDisabled
-			c.Reprovider.Interval = NewOptionalDuration(0)
-
-			lowWater := int64(20)
-			highWater := int64(40)
-			gracePeriod := time.Minute
-			c.Swarm.ConnMgr.Type = NewOptionalString("basic")
-			c.Swarm.ConnMgr.LowWater = &OptionalInteger{value: &lowWater}
-			c.Swarm.ConnMgr.HighWater = &OptionalInteger{value: &highWater}
-			c.Swarm.ConnMgr.GracePeriod = &OptionalDuration{&gracePeriod}
+			if err := serverTransform(c); err != nil {
+				return err
+			}
+			c.Addresses.API = Strings{"/ip4/127.0.0.1/tcp/5001"}
+			c.Addresses.Gateway = Strings{"/ip4/0.0.0.0/tcp/8080", "/ip6/::/tcp/8080"}
+
+			c.Gateway.NoFetch = false
+			c.Gateway.Writable = False
+			c.Gateway.RateLimit = &GatewayRateLimit{
+				RequestsPerSecond: NewOptionalInteger(100),
+				Burst:             NewOptionalInteger(200),
+			}
+
+			c.Swarm.ResourceMgr.Enabled = True
+			c.Swarm.ResourceMgr.MaxMemory = NewOptionalString("4GB")
 			return nil
 		},
 	},
@@ -251,3 +286,30 @@ func mapKeys(m map[string]struct{}) []string {
 	}
 	return out
 }
+
+// serverTransform applies the server profile, also applied by the
+// gateway-public profile.
+func serverTransform(c *Config) error {
+	c.Addresses.NoAnnounce = appendSingle(c.Addresses.NoAnnounce, defaultServerFilters)
+	c.Swarm.AddrFilters = appendSingle(c.Swarm.AddrFilters, defaultServerFilters)
+	c.Discovery.MDNS.Enabled = false
+	c.Swarm.DisableNatPortMap = true
+	return nil
+}
+
+// lowPowerTransform applies the lowpower profile, also applied by the
+// lowpower-v2 profile.
+func lowPowerTransform(c *Config) error {
+	c.Routing.Type = NewOptionalString("dhtclient") // TODO: https://github.com/ipfs/kubo/issues/9480
+	c.AutoNAT.ServiceMode = AutoNATServiceDisabled
+	c.Reprovider.Interval = NewOptionalDuration(0)
+
+	lowWater := int64(20)
+	highWater := int64(40)
+	gracePeriod := time.Minute
+	c.Swarm.ConnMgr.Type = NewOptionalString("basic")
+	c.Swarm.ConnMgr.LowWater = &OptionalInteger{value: &lowWater}
+	c.Swarm.ConnMgr.HighWater = &OptionalInteger{value: &highWater}
+	c.Swarm.ConnMgr.GracePeriod = &OptionalDuration{&gracePeriod}
+	return nil
+}
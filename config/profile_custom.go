@@ -0,0 +1,191 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// CustomProfileExt is the extension of the files of the custom profiles,
+// which LookupProfile loads.
+const CustomProfileExt = ".json"
+
+// CustomProfile is a profile exported from the config of a node, to set up
+// other nodes the same way. Config holds the settings of the node differing
+// from the defaults, with null for the settings it removed.
+type CustomProfile struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Config      map[string]interface{}
+}
+
+// profileExcludedSelectors are the settings left out of the exported profiles
+// on top of the SecretSelectors, being specific to the node.
+var profileExcludedSelectors = [][]string{
+	{IdentityTag},
+	{"Pinning", "RemoteServices"},
+	{"API", "Authorizations"},
+}
+
+// ExportProfile returns the settings of cfg differing from the defaults as a
+// profile. The identity, the remote pinning services and the users of the RPC
+// API are left out, being specific to the node, and so are the
+// SecretSelectors.
+func ExportProfile(cfg *Config, name, description string) (*CustomProfile, error) {
+	defaults, err := InitWithIdentity(Identity{})
+	if err != nil {
+		return nil, err
+	}
+	defaultsMap, err := ToMap(defaults)
+	if err != nil {
+		return nil, err
+	}
+	cfgMap, err := ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range []map[string]interface{}{defaultsMap, cfgMap} {
+		for _, sel := range profileExcludedSelectors {
+			scrubSelector(m, sel)
+		}
+		for _, sel := range SecretSelectors {
+			scrubSelector(m, sel)
+		}
+	}
+
+	return &CustomProfile{
+		Name:        name,
+		Description: description,
+		Config:      configDelta(defaultsMap, cfgMap),
+	}, nil
+}
+
+// scrubSelector removes the values matched by sel from v, a config decoded
+// by ToMap, recursing into the objects and the lists.
+func scrubSelector(v interface{}, sel []string) {
+	if len(sel) == 0 {
+		return
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, sub := range v {
+			if sel[0] != "*" && !strings.EqualFold(k, sel[0]) {
+				continue
+			}
+			if len(sel) == 1 {
+				delete(v, k)
+				continue
+			}
+			scrubSelector(sub, sel[1:])
+		}
+	case []interface{}:
+		if sel[0] != "*" {
+			return
+		}
+		for _, sub := range v {
+			scrubSelector(sub, sel[1:])
+		}
+	}
+}
+
+// configDelta returns the values of cfg differing from those of defaults,
+// recursing into the objects.
+func configDelta(defaults, cfg map[string]interface{}) map[string]interface{} {
+	delta := make(map[string]interface{})
+	for k, v := range cfg {
+		d, ok := defaults[k]
+		vm, vIsMap := v.(map[string]interface{})
+		dm, dIsMap := d.(map[string]interface{})
+		if vIsMap && dIsMap {
+			if sub := configDelta(dm, vm); len(sub) > 0 {
+				delta[k] = sub
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(d, v) {
+			delta[k] = v
+		}
+	}
+	for k := range defaults {
+		if _, ok := cfg[k]; !ok {
+			delta[k] = nil
+		}
+	}
+	return delta
+}
+
+// mergeConfig sets the values of delta in m, recursing into the objects, and
+// removes those set to null.
+func mergeConfig(m, delta map[string]interface{}) {
+	for k, v := range delta {
+		if v == nil {
+			delete(m, k)
+			continue
+		}
+		vm, vIsMap := v.(map[string]interface{})
+		mm, mIsMap := m[k].(map[string]interface{})
+		if vIsMap && mIsMap {
+			mergeConfig(mm, vm)
+			continue
+		}
+		m[k] = v
+	}
+}
+
+// Profile returns the profile applying the settings of p.
+func (p *CustomProfile) Profile() Profile {
+	_, datastore := p.Config["Datastore"]
+	return Profile{
+		Description: p.Description,
+		InitOnly:    datastore,
+		Transform: func(c *Config) error {
+			m, err := ToMap(c)
+			if err != nil {
+				return err
+			}
+			mergeConfig(m, p.Config)
+			newCfg, err := FromMap(m)
+			if err != nil {
+				return err
+			}
+			*c = *newCfg
+			return nil
+		},
+	}
+}
+
+// LoadProfile reads the custom profile exported to the file at path.
+func LoadProfile(path string) (*CustomProfile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p CustomProfile
+	if err := json.NewDecoder(f).Decode(&p); err != nil {
+		return nil, fmt.Errorf("failed to decode the profile %s: %w", path, err)
+	}
+	if p.Config == nil {
+		return nil, fmt.Errorf("the profile %s has no Config", path)
+	}
+	return &p, nil
+}
+
+// LookupProfile returns the built-in profile name or, if name is the path of
+// a file with the CustomProfileExt extension, the custom profile it holds.
+func LookupProfile(name string) (Profile, error) {
+	if profile, ok := Profiles[name]; ok {
+		return profile, nil
+	}
+	if !strings.HasSuffix(name, CustomProfileExt) {
+		return Profile{}, fmt.Errorf("%s is not a profile", name)
+	}
+	p, err := LoadProfile(name)
+	if err != nil {
+		return Profile{}, err
+	}
+	return p.Profile(), nil
+}
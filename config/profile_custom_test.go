@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportProfile(t *testing.T) {
+	cfg, err := InitWithIdentity(Identity{PeerID: "node"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Profiles["server"].Transform(cfg); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Gateway.Writable = True
+	delete(cfg.Gateway.HTTPHeaders, "Access-Control-Allow-Methods")
+	cfg.Pinning.RemoteServices = map[string]RemotePinningService{"svc": {}}
+	cfg.API.Authorizations = map[string]*RPCAuthScope{"user": {AuthSecret: "bearer:secret"}}
+	cfg.Webhooks.Endpoints = []WebhookEndpoint{{URL: "https://hooks.example.com", Headers: map[string]string{"Authorization": "secret"}}}
+
+	p, err := ExportProfile(cfg, "fleet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Config[IdentityTag]; ok {
+		t.Fatal("the identity was exported")
+	}
+	if _, ok := p.Config["Pinning"]; ok {
+		t.Fatal("the remote pinning services were exported")
+	}
+	if _, ok := p.Config["API"]; ok {
+		t.Fatal("the users of the RPC API were exported")
+	}
+	endpoints := p.Config["Webhooks"].(map[string]interface{})["Endpoints"].([]interface{})
+	if endpoint := endpoints[0].(map[string]interface{}); endpoint["URL"] != "https://hooks.example.com" || endpoint["Headers"] != nil {
+		t.Fatalf("unexpected webhook endpoint %v", endpoint)
+	}
+	if _, ok := p.Config["Datastore"]; ok {
+		t.Fatal("the default datastore was exported")
+	}
+
+	path := filepath.Join(t.TempDir(), "fleet"+CustomProfileExt)
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := LookupProfile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := InitWithIdentity(Identity{PeerID: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := profile.Transform(other); err != nil {
+		t.Fatal(err)
+	}
+	if other.Identity.PeerID != "other" {
+		t.Fatal("the identity was changed")
+	}
+	if !reflect.DeepEqual(other.Swarm.AddrFilters, cfg.Swarm.AddrFilters) ||
+		!reflect.DeepEqual(other.Gateway.HTTPHeaders, cfg.Gateway.HTTPHeaders) ||
+		other.Gateway.Writable != True || !other.Swarm.DisableNatPortMap {
+		t.Fatal("the settings of the profile weren't applied")
+	}
+
+	if _, err := LookupProfile("nope"); err == nil {
+		t.Fatal("expected an error for an unknown profile")
+	}
+}
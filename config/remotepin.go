@@ -1,5 +1,12 @@
 package config
 
+import "time"
+
+const (
+	DefaultRemotePinningGroupMaxRetries   = 2
+	DefaultRemotePinningGroupRetryBackoff = 5 * time.Second
+)
+
 var (
 	RemoteServicesPath     = "Pinning.RemoteServices"
 	PinningConcealSelector = []string{"Pinning", "RemoteServices", "*", "API", "Key"}
@@ -7,6 +14,7 @@ var (
 
 type Pinning struct {
 	RemoteServices map[string]RemotePinningService
+	RemoteGroups   map[string]RemotePinningGroup `json:",omitempty"`
 }
 
 type RemotePinningService struct {
@@ -31,3 +39,19 @@ type RemotePinningServiceMFSPolicy struct {
 	// RepinInterval determines the repin interval when the policy is enabled. In ns, us, ms, s, m, h.
 	RepinInterval string
 }
+
+// RemotePinningGroup is a set of remote pinning services pins are replicated
+// to. Pins are requested from the services in order, moving on to the next
+// service when one fails, until Replication services confirmed the pin.
+type RemotePinningGroup struct {
+	// Services are the names of services from Pinning.RemoteServices.
+	Services []string
+	// Replication is the number of services each pin should be stored on.
+	// Defaults to all of Services.
+	Replication *OptionalInteger `json:",omitempty"`
+	// MaxRetries is the number of times a failed pin request is retried on
+	// the same service before failing over to the next one.
+	MaxRetries *OptionalInteger `json:",omitempty"`
+	// RetryBackoff is the delay between two attempts on the same service.
+	RetryBackoff *OptionalDuration `json:",omitempty"`
+}
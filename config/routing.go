@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
+	"time"
 )
 
 // Routing defines configuration options for libp2p routing
@@ -18,8 +19,80 @@ type Routing struct {
 	Routers Routers
 
 	Methods Methods
+
+	// Announce announces the provider records to HTTP routers as well.
+	Announce RoutingAnnounce
+
+	// IPNI publishes the provider records to IPNI indexers as a chain of
+	// advertisements.
+	IPNI RoutingIPNI
+
+	// PrivateLabels are the pin labels of the private content, only
+	// provided on the LAN DHT.
+	PrivateLabels []string `json:",omitempty"`
+}
+
+// RoutingIPNI configures the publication of the content provided by the node
+// to IPNI indexers, as a chain of signed advertisements served by the
+// gateway.
+type RoutingIPNI struct {
+	// Enabled publishes the advertisements.
+	Enabled Flag `json:",omitempty"`
+
+	// Indexers are the base URLs of the indexers the advertisements are
+	// announced to.
+	Indexers []string `json:",omitempty"`
+
+	// PublisherAddrs are the public HTTP multiaddrs of the gateway, which
+	// the indexers fetch the advertisements from.
+	PublisherAddrs []string `json:",omitempty"`
+
+	// EntriesChunkSize is the maximum number of multihashes per block of
+	// the entries of an advertisement.
+	EntriesChunkSize *OptionalInteger `json:",omitempty"`
+
+	// Interval is how long the CIDs provided are collected before being
+	// published in an advertisement.
+	Interval *OptionalDuration `json:",omitempty"`
+}
+
+// RoutingAnnounce configures the announcement of the provider records to
+// HTTP routers (IPIP-337), such as IPNI indexers, in addition to the routers
+// of Routing.Type.
+type RoutingAnnounce struct {
+	// Endpoints are the base URLs of the HTTP routers.
+	Endpoints []string `json:",omitempty"`
+
+	// Exclusive announces the provider records to the endpoints only, and
+	// not to the DHT nor the other routers.
+	Exclusive Flag `json:",omitempty"`
+
+	// BatchSize is the maximum number of CIDs announced per request.
+	BatchSize *OptionalInteger `json:",omitempty"`
+
+	// BatchDelay is how long the CIDs provided one by one are collected
+	// before being announced in a batch.
+	BatchDelay *OptionalDuration `json:",omitempty"`
+
+	// MaxRetries is the number of times the announcement of a batch is
+	// retried, with an exponential backoff, before being dropped until the
+	// next reprovide.
+	MaxRetries *OptionalInteger `json:",omitempty"`
 }
 
+const (
+	DefaultRoutingAnnounceBatchSize  = 100
+	DefaultRoutingAnnounceBatchDelay = 5 * time.Second
+	DefaultRoutingAnnounceMaxRetries = 5
+
+	DefaultRoutingIPNIEntriesChunkSize = 16384
+	DefaultRoutingIPNIInterval         = time.Minute
+)
+
+// DefaultRoutingIPNIIndexers are the indexers the advertisements are
+// announced to by default.
+var DefaultRoutingIPNIIndexers = []string{"https://cid.contact"}
+
 type Router struct {
 
 	// Router type ID. See RouterType for more info.
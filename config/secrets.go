@@ -0,0 +1,37 @@
+package config
+
+import "strings"
+
+// SecretSelectors are the config keys of the settings holding secrets, "*"
+// matching any name of a map or index of a list. They are kept out of the
+// audit log of the RPC API and of the exported profiles.
+var SecretSelectors = [][]string{
+	{IdentityTag, PrivKeyTag},
+	PinningConcealSelector,
+	{"API", "Authorizations", "*", "AuthSecret"},
+	{"Webhooks", "Endpoints", "*", "Headers"},
+}
+
+// IsSecretKey tells whether the config key, such as Webhooks.Endpoints, holds
+// or is held by one of the SecretSelectors. Keys are matched regardless of
+// case, as by 'ipfs config'.
+func IsSecretKey(key string) bool {
+	parts := strings.Split(key, ".")
+	for _, sel := range SecretSelectors {
+		if matchesSelector(parts, sel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSelector tells whether the first parts of key match those of sel,
+// up to the shorter of both.
+func matchesSelector(key, sel []string) bool {
+	for i := 0; i < len(key) && i < len(sel); i++ {
+		if sel[i] != "*" && !strings.EqualFold(key[i], sel[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestIsSecretKey(t *testing.T) {
+	for key, secret := range map[string]bool{
+		"Identity":                              true,
+		"Identity.PeerID":                       false,
+		"identity.privkey":                      true,
+		"Pinning.RemoteServices.svc.API.Key":    true,
+		"Pinning.RemoteServices.svc.API":        true,
+		"Pinning.RemoteGroups":                  false,
+		"API":                                   true,
+		"api.authorizations.user.authsecret":    true,
+		"API.Authorizations.user.AllowedPaths":  false,
+		"API.HTTPHeaders":                       false,
+		"Webhooks.Endpoints":                    true,
+		"Webhooks.Endpoints.0.Headers.X-Secret": true,
+		"Webhooks.GatewayErrors.Threshold":      false,
+		"Gateway.NoFetch":                       false,
+	} {
+		if got := IsSecretKey(key); got != secret {
+			t.Errorf("%s: expected secret %t, got %t", key, secret, got)
+		}
+	}
+}
+
+// secretFieldName matches the names of the fields likely to hold secrets.
+var secretFieldName = regexp.MustCompile(`(?i)(secret|token|password|privkey|^key$|headers)`)
+
+// notSecretKeys are the keys of the fields named like secrets which aren't.
+var notSecretKeys = map[string]bool{
+	"API.HTTPHeaders":                      true,
+	"Gateway.HTTPHeaders":                  true,
+	"Gateway.PublicGateways.*.HTTPHeaders": true,
+	// the name of a key of the keystore
+	"Gateway.Capabilities.Key": true,
+}
+
+// TestSecretSelectorsCoverConfig walks the fields of Config, so that the new
+// settings named like secrets are either added to SecretSelectors, which are
+// scrubbed from the audit log and the exported profiles, or to notSecretKeys.
+func TestSecretSelectorsCoverConfig(t *testing.T) {
+	var walk func(key []string, typ reflect.Type)
+	walk = func(key []string, typ reflect.Type) {
+		if len(key) > 10 {
+			return
+		}
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if len(key) > 0 && secretFieldName.MatchString(key[len(key)-1]) {
+			k := strings.Join(key, ".")
+			if !IsSecretKey(k) && !notSecretKeys[k] {
+				t.Errorf("%s is named like a secret: add it to SecretSelectors or to notSecretKeys", k)
+			}
+		}
+		switch typ.Kind() {
+		case reflect.Map, reflect.Slice:
+			if typ.Elem().Kind() == reflect.Struct || typ.Elem().Kind() == reflect.Ptr {
+				walk(append(key, "*"), typ.Elem())
+			}
+		case reflect.Struct:
+			for i := 0; i < typ.NumField(); i++ {
+				f := typ.Field(i)
+				if !f.IsExported() || f.Tag.Get("json") == "-" {
+					continue
+				}
+				name := f.Name
+				if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); tag != "" {
+					name = tag
+				}
+				walk(append(append([]string(nil), key...), name), f.Type)
+			}
+		}
+	}
+	walk(nil, reflect.TypeOf(Config{}))
+}
@@ -1,6 +1,10 @@
 package config
 
-import rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+import (
+	"time"
+
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+)
 
 type SwarmConfig struct {
 	// AddrFilters specifies a set libp2p addresses that we should never
@@ -12,6 +16,13 @@ type SwarmConfig struct {
 	// flag.
 	DisableBandwidthMetrics bool
 
+	// BandwidthHistory keeps a rolling history of the bandwidth metrics.
+	BandwidthHistory BandwidthHistory
+
+	// PersistentPeerstore keeps the addresses of the peers the node dialed
+	// across restarts.
+	PersistentPeerstore PersistentPeerstore
+
 	// DisableNatPortMap turns off NAT port mapping (UPnP, etc.).
 	DisableNatPortMap bool
 
@@ -49,6 +60,9 @@ type SwarmConfig struct {
 	// Transports contains flags to enable/disable libp2p transports.
 	Transports Transports
 
+	// Dialing configures how the addresses of a peer are dialed.
+	Dialing Dialing
+
 	// ConnMgr configures the connection manager.
 	ConnMgr ConnMgr
 
@@ -137,6 +151,61 @@ type ConnMgr struct {
 	GracePeriod *OptionalDuration `json:",omitempty"`
 }
 
+// BandwidthHistory defines how the history of the bandwidth metrics is kept
+type BandwidthHistory struct {
+	// Retention is how long samples are kept, 0 disables the history.
+	Retention *OptionalDuration `json:",omitempty"`
+	// Interval is the time between two samples.
+	Interval *OptionalDuration `json:",omitempty"`
+	// MaxPeers is the number of busiest peers recorded in each sample.
+	MaxPeers *OptionalInteger `json:",omitempty"`
+}
+
+const (
+	DefaultBandwidthHistoryRetention = time.Hour
+	DefaultBandwidthHistoryInterval  = 10 * time.Second
+	DefaultBandwidthHistoryMaxPeers  = 20
+)
+
+// Dialing defines how the addresses of a peer are dialed. They are dialed in
+// parallel, the fallback addresses waiting for FallbackDelay with PreferQUIC
+// or PreferIPv4.
+type Dialing struct {
+	// PreferQUIC delays the dials of the TCP and websocket addresses of the
+	// peers with a QUIC address.
+	PreferQUIC Flag `json:",omitempty"`
+	// PreferIPv4 delays the dials of the IPv6 addresses of the peers with an
+	// IPv4 address, for networks with a broken IPv6.
+	PreferIPv4 Flag `json:",omitempty"`
+	// FallbackDelay is the head start of the preferred addresses, for each
+	// preference.
+	FallbackDelay *OptionalDuration `json:",omitempty"`
+	// MaxParallelDials is the number of addresses of a peer dialed at once.
+	MaxParallelDials *OptionalInteger `json:",omitempty"`
+}
+
+const (
+	DefaultDialingFallbackDelay    = 250 * time.Millisecond
+	DefaultDialingMaxParallelDials = 8
+)
+
+// PersistentPeerstore defines how the addresses of the peers the node dialed
+// are kept across restarts
+type PersistentPeerstore struct {
+	// Enabled persists the addresses of the peers dialed successfully, along
+	// with the history of their dials.
+	Enabled Flag `json:",omitempty"`
+	// MaxPeers is the number of peers remembered.
+	MaxPeers *OptionalInteger `json:",omitempty"`
+	// ReconnectPeers is the number of remembered peers redialed on startup.
+	ReconnectPeers *OptionalInteger `json:",omitempty"`
+}
+
+const (
+	DefaultPersistentPeerstoreMaxPeers       = 1000
+	DefaultPersistentPeerstoreReconnectPeers = 32
+)
+
 // ResourceMgr defines configuration options for the libp2p Network Resource Manager
 // <https://github.com/libp2p/go-libp2p/tree/master/p2p/host/resource-manager#readme>
 type ResourceMgr struct {
@@ -0,0 +1,55 @@
+package config
+
+import "time"
+
+const (
+	DefaultWebhookMaxRetries     = 5
+	DefaultWebhookRetryBackoff   = time.Second
+	DefaultWebhookTimeout        = 10 * time.Second
+	DefaultGatewayErrorThreshold = 3
+	DefaultGatewayErrorWindow    = time.Minute
+)
+
+// Webhooks configures HTTP callbacks POSTed when internal node events occur.
+type Webhooks struct {
+	// Endpoints is the list of URLs events are delivered to.
+	Endpoints []WebhookEndpoint `json:",omitempty"`
+
+	// GatewayErrors configures when repeated gateway server errors for a
+	// single CID are reported as a gateway.error event.
+	GatewayErrors GatewayErrors
+}
+
+// WebhookEndpoint is a single webhook receiver.
+type WebhookEndpoint struct {
+	// URL is the HTTP(S) URL the JSON encoded events are POSTed to.
+	URL string
+
+	// Events lists the event types (or type prefixes, like "pin") sent to
+	// this endpoint. Empty means all events.
+	Events []string `json:",omitempty"`
+
+	// Headers are additional HTTP headers sent with every request, for
+	// example an Authorization header.
+	Headers map[string]string `json:",omitempty"`
+
+	// MaxRetries is the number of times a failed delivery is retried.
+	MaxRetries *OptionalInteger `json:",omitempty"`
+
+	// RetryBackoff is the delay before the first retry. It doubles after
+	// every failed attempt.
+	RetryBackoff *OptionalDuration `json:",omitempty"`
+
+	// Timeout is the timeout of a single delivery attempt.
+	Timeout *OptionalDuration `json:",omitempty"`
+}
+
+// GatewayErrors configures reporting of repeated gateway 5xx responses.
+type GatewayErrors struct {
+	// Threshold is the number of 5xx responses for the same CID within
+	// Window after which a gateway.error event is emitted.
+	Threshold *OptionalInteger `json:",omitempty"`
+
+	// Window is the period over which 5xx responses are counted.
+	Window *OptionalDuration `json:",omitempty"`
+}
@@ -0,0 +1,265 @@
+// Package adminbatch implements the signed batches of operations run by
+// 'ipfs admin exec', with which a fleet of nodes is managed through their RPC
+// API.
+//
+// A batch is a JSON document listing operations, signed by a key the node
+// authorizes in API.Admin.AuthorizedKeys. Its operations are applied
+// atomically: when one fails, those already applied are undone.
+package adminbatch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// Version is the version of the batch format.
+const Version = 1
+
+// MaxTTL is the longest validity of a batch, bounding the batches the nodes
+// remember to refuse their replays.
+const MaxTTL = 24 * time.Hour
+
+// MaxClockSkew is how far in the future the creation of a batch may be, for
+// the clocks of the signer and of the nodes which differ.
+const MaxClockSkew = 5 * time.Minute
+
+// The types of the operations.
+const (
+	// PinAdd pins Path, recursively unless Direct.
+	PinAdd = "pin/add"
+	// PinRm unpins Path.
+	PinRm = "pin/rm"
+	// ConfigSet sets the config Key to Value, see ReloadSafe.
+	ConfigSet = "config/set"
+	// PeeringAdd adds Peer at Addrs to the peering subsystem.
+	PeeringAdd = "peering/add"
+	// PeeringRm removes Peer from the peering subsystem.
+	PeeringRm = "peering/rm"
+)
+
+var (
+	// ErrUnsigned is returned when verifying a batch without signature.
+	ErrUnsigned = errors.New("the admin batch is not signed")
+	// ErrBadSignature is returned when the signature of a batch doesn't
+	// match its content or its signer.
+	ErrBadSignature = errors.New("invalid signature of the admin batch")
+	// ErrExpired is returned when verifying a batch past its expiry.
+	ErrExpired = errors.New("the admin batch has expired")
+	// ErrNotValidYet is returned when verifying a batch created further in
+	// the future than MaxClockSkew.
+	ErrNotValidYet = errors.New("the admin batch is not valid yet")
+)
+
+// Op is an operation of a batch. The fields used depend on its Type.
+type Op struct {
+	Type string
+
+	Path   string `json:",omitempty"`
+	Direct bool   `json:",omitempty"`
+
+	Key   string          `json:",omitempty"`
+	Value json.RawMessage `json:",omitempty"`
+
+	Peer  string   `json:",omitempty"`
+	Addrs []string `json:",omitempty"`
+}
+
+// Batch is a signed list of operations.
+type Batch struct {
+	Version int
+	Created time.Time
+	Expires time.Time
+	Ops     []Op
+
+	// Signer is the peer ID of the signing key, and PublicKey its protobuf
+	// encoded public key. Signature covers the JSON encoding of the batch
+	// without the signature.
+	Signer    string `json:",omitempty"`
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// reloadSafeKeys are the config keys, and their subkeys, which batches may
+// set. Gateway and Routing aren't listed as a whole: Gateway.Writable, the
+// capabilities and authorizers of the gateway, and the routers, which may
+// send the requests of the node to any HTTP endpoint, stay local.
+var reloadSafeKeys = []string{
+	"Bootstrap",
+	"Datastore.GCPeriod",
+	"Datastore.StorageGCWatermark",
+	"Datastore.StorageMax",
+	"Discovery",
+	"Gateway.Compression",
+	"Gateway.DirectoryPageSize",
+	"Gateway.EarlyHints",
+	"Gateway.FetchBudget",
+	"Gateway.Limits",
+	"Gateway.NoFetch",
+	"Gateway.PopularityTopN",
+	"Gateway.RateLimit",
+	"Ipns",
+	"Peering",
+	"Reprovider",
+	"Swarm.ConnMgr",
+	"Swarm.ResourceMgr",
+}
+
+// ReloadSafe returns whether batches may set the config key: the keys which
+// hold no secrets, don't grant access to the node or its gateway, and don't
+// break its repo. Like with 'ipfs config', the changes take effect when the
+// daemon restarts.
+func ReloadSafe(key string) bool {
+	for _, k := range reloadSafeKeys {
+		if key == k || strings.HasPrefix(key, k+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// New returns an unsigned batch of ops, valid for ttl.
+func New(ops []Op, ttl time.Duration) *Batch {
+	now := time.Now().UTC().Truncate(time.Second)
+	return &Batch{
+		Version: Version,
+		Created: now,
+		Expires: now.Add(ttl),
+		Ops:     ops,
+	}
+}
+
+// ID identifies a batch by the bytes covered by its signature, for the audit
+// log and to refuse its replays: the signatures aren't hashed, since a batch
+// may have several valid ones.
+func (b *Batch) ID() (string, error) {
+	data, err := b.signedBytes()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:8]), nil
+}
+
+// signedBytes returns the bytes covered by the signature.
+func (b *Batch) signedBytes() ([]byte, error) {
+	unsigned := *b
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
+
+// Sign signs the batch with k.
+func (b *Batch) Sign(k crypto.PrivKey) error {
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.MarshalPublicKey(k.GetPublic())
+	if err != nil {
+		return err
+	}
+	b.Signer = id.String()
+	b.PublicKey = pub
+	data, err := b.signedBytes()
+	if err != nil {
+		return err
+	}
+	b.Signature, err = k.Sign(data)
+	return err
+}
+
+// Verify checks the signature and the validity of the batch at now, and
+// returns its signer.
+func (b *Batch) Verify(now time.Time) (peer.ID, error) {
+	if len(b.Signature) == 0 {
+		return "", ErrUnsigned
+	}
+	pub, err := crypto.UnmarshalPublicKey(b.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	if id.String() != b.Signer {
+		return "", ErrBadSignature
+	}
+	data, err := b.signedBytes()
+	if err != nil {
+		return "", err
+	}
+	if ok, err := pub.Verify(data, b.Signature); err != nil || !ok {
+		return "", ErrBadSignature
+	}
+	if now.After(b.Expires) {
+		return "", ErrExpired
+	}
+	if b.Created.After(now.Add(MaxClockSkew)) {
+		return "", ErrNotValidYet
+	}
+	return id, nil
+}
+
+// Read decodes and validates a batch, without checking its signature.
+func Read(r io.Reader) (*Batch, error) {
+	b := new(Batch)
+	if err := json.NewDecoder(r).Decode(b); err != nil {
+		return nil, fmt.Errorf("invalid admin batch: %w", err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("unsupported admin batch version %d", b.Version)
+	}
+	if b.Expires.Sub(b.Created) > MaxTTL {
+		return nil, fmt.Errorf("invalid admin batch: valid for more than %s", MaxTTL)
+	}
+	if len(b.Ops) == 0 {
+		return nil, errors.New("invalid admin batch: no operation")
+	}
+	for i, op := range b.Ops {
+		if err := op.validate(); err != nil {
+			return nil, fmt.Errorf("invalid admin batch: operation %d: %w", i, err)
+		}
+	}
+	return b, nil
+}
+
+func (op *Op) validate() error {
+	switch op.Type {
+	case PinAdd, PinRm:
+		if op.Path == "" {
+			return fmt.Errorf("%s without Path", op.Type)
+		}
+	case ConfigSet:
+		if !ReloadSafe(op.Key) {
+			return fmt.Errorf("the config key %q may not be set by a batch", op.Key)
+		}
+		if len(op.Value) == 0 || !json.Valid(op.Value) {
+			return fmt.Errorf("invalid Value of %s", op.Key)
+		}
+	case PeeringAdd, PeeringRm:
+		if _, err := peer.Decode(op.Peer); err != nil {
+			return fmt.Errorf("invalid Peer: %w", err)
+		}
+		if op.Type == PeeringAdd && len(op.Addrs) == 0 {
+			return fmt.Errorf("%s without Addrs", op.Type)
+		}
+		for _, a := range op.Addrs {
+			if _, err := ma.NewMultiaddr(a); err != nil {
+				return fmt.Errorf("invalid address %q: %w", a, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown type %q", op.Type)
+	}
+	return nil
+}
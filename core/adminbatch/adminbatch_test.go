@@ -0,0 +1,223 @@
+package adminbatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSignVerify(t *testing.T) {
+	k, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New([]Op{
+		{Type: PinAdd, Path: "/ipfs/bafkqaaa"},
+		{Type: ConfigSet, Key: "Swarm.ConnMgr.HighWater", Value: json.RawMessage(`400`)},
+	}, time.Hour)
+	if _, err := b.Verify(time.Now()); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("expected %s, got %v", ErrUnsigned, err)
+	}
+	if err := b.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := read.Verify(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := peer.IDFromPrivateKey(k); signer != id {
+		t.Fatalf("expected signer %s, got %s", id, signer)
+	}
+	if _, err := read.Verify(time.Now().Add(2 * time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected %s, got %v", ErrExpired, err)
+	}
+
+	future := New(b.Ops, time.Hour)
+	future.Created = future.Created.Add(time.Hour)
+	future.Expires = future.Expires.Add(time.Hour)
+	if err := future.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := future.Verify(time.Now()); !errors.Is(err, ErrNotValidYet) {
+		t.Fatalf("expected %s, got %v", ErrNotValidYet, err)
+	}
+	if _, err := future.Verify(time.Now().Add(time.Hour - MaxClockSkew/2)); err != nil {
+		t.Fatalf("expected the batch to be valid within the clock skew, got %v", err)
+	}
+
+	read.Ops[1].Value = json.RawMessage(`4000`)
+	if _, err := read.Verify(time.Now()); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected %s, got %v", ErrBadSignature, err)
+	}
+}
+
+func TestID(t *testing.T) {
+	k, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := New([]Op{{Type: PinAdd, Path: "/ipfs/bafkqaaa"}}, time.Hour)
+	if err := b.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+	id, err := b.ID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another signature of the same batch has the same ID
+	b.Signature = append([]byte(nil), b.Signature...)
+	b.Signature[0] ^= 0xff
+	if other, _ := b.ID(); other != id {
+		t.Fatalf("expected the ID %s whatever the signature, got %s", id, other)
+	}
+	b.Ops[0].Direct = true
+	if other, _ := b.ID(); other == id {
+		t.Fatal("expected another ID for another batch")
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	valid := `"Created":"2023-01-01T00:00:00Z","Expires":"2023-01-01T01:00:00Z"`
+	for _, data := range []string{
+		`{"Version":2,` + valid + `,"Ops":[{"Type":"pin/add","Path":"/ipfs/bafkqaaa"}]}`,
+		`{"Version":1,` + valid + `,"Ops":[]}`,
+		`{"Version":1,"Created":"2023-01-01T00:00:00Z","Expires":"2023-02-01T00:00:00Z","Ops":[{"Type":"pin/add","Path":"/ipfs/bafkqaaa"}]}`,
+		`{"Version":1,` + valid + `,"Ops":[{"Type":"repo/gc"}]}`,
+		`{"Version":1,` + valid + `,"Ops":[{"Type":"pin/rm"}]}`,
+		`{"Version":1,` + valid + `,"Ops":[{"Type":"config/set","Key":"Identity.PrivKey","Value":"x"}]}`,
+		`{"Version":1,` + valid + `,"Ops":[{"Type":"config/set","Key":"API.HTTPHeaders","Value":{}}]}`,
+		`{"Version":1,` + valid + `,"Ops":[{"Type":"peering/add","Peer":"nope","Addrs":["/ip4/1.2.3.4/tcp/4001"]}]}`,
+	} {
+		if _, err := Read(bytes.NewReader([]byte(data))); err == nil {
+			t.Errorf("expected an error reading %s", data)
+		}
+	}
+}
+
+func TestReloadSafe(t *testing.T) {
+	for key, safe := range map[string]bool{
+		"Gateway":                  false,
+		"Gateway.NoFetch":          true,
+		"Gateway.RateLimit.Burst":  true,
+		"Gateway.Writable":         false,
+		"Gateway.Capabilities.Key": false,
+		"Gateway.Authorizers":      false,
+		"Routing.Routers":          false,
+		"Swarm.ConnMgr.HighWater":  true,
+		"GatewayX":                 false,
+		"Swarm.Transports":         false,
+		"Addresses.API":            false,
+		"Pinning.RemoteServices.x": false,
+		"Datastore.StorageMax":     true,
+		"Datastore.Spec":           false,
+		"Identity":                 false,
+	} {
+		if ReloadSafe(key) != safe {
+			t.Errorf("expected ReloadSafe(%q) to be %t", key, safe)
+		}
+	}
+}
+
+func TestApplyRollback(t *testing.T) {
+	ops := []Op{{Type: PinAdd}, {Type: PinRm}, {Type: ConfigSet}}
+	var applied, undone []string
+	apply := func(_ context.Context, op Op) (Undo, error) {
+		if op.Type == ConfigSet {
+			return nil, errors.New("boom")
+		}
+		applied = append(applied, op.Type)
+		return func() error {
+			undone = append(undone, op.Type)
+			return nil
+		}, nil
+	}
+	err := Apply(context.Background(), ops, apply)
+	if err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("expected a rolled back error, got %v", err)
+	}
+	if strings.Join(undone, ",") != PinRm+","+PinAdd {
+		t.Fatalf("expected the operations undone in reverse order, got %v", undone)
+	}
+
+}
+
+func TestGuard(t *testing.T) {
+	ctx := context.Background()
+	d := syncds.MutexWrap(ds.NewMapDatastore())
+	apply := func(context.Context, Op) (Undo, error) { return nil, nil }
+
+	b := New([]Op{{Type: PinAdd}}, time.Hour)
+	b.Signature = []byte("sig")
+	if err := NewGuard().Run(ctx, d, b, apply); err != nil {
+		t.Fatal(err)
+	}
+	// the batches run are remembered in the datastore, across the restarts
+	if err := NewGuard().Run(ctx, d, b, apply); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("expected %s, got %v", ErrReplayed, err)
+	}
+	b.Signature = []byte("another sig")
+	if err := NewGuard().Run(ctx, d, b, apply); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("expected %s with another signature, got %v", ErrReplayed, err)
+	}
+
+	// and forgotten once expired
+	expired := New([]Op{{Type: PinRm}}, time.Hour)
+	expired.Expires = time.Now().Add(-time.Second)
+	if err := NewGuard().Run(ctx, d, expired, apply); err != nil {
+		t.Fatal(err)
+	}
+	if err := pruneSeen(ctx, d, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := b.ID()
+	expiredID, _ := expired.ID()
+	if ok, _ := d.Has(ctx, SeenPrefix.ChildString(id)); !ok {
+		t.Error("expected the batch to be remembered until it expires")
+	}
+	if ok, _ := d.Has(ctx, SeenPrefix.ChildString(expiredID)); ok {
+		t.Error("expected the expired batch to be forgotten")
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a := NewAuditLog(path)
+	for _, outcome := range []string{OutcomeRejected, OutcomeApplied} {
+		if err := a.Record(AuditEntry{Time: time.Now(), Batch: "b", Outcome: outcome}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 entries, got %q", data)
+	}
+	var e AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e.Outcome != OutcomeApplied {
+		t.Fatalf("unexpected entry %s: %v", lines[1], err)
+	}
+}
@@ -0,0 +1,125 @@
+package adminbatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// ErrReplayed is returned by Guard.Run for a batch already run.
+var ErrReplayed = errors.New("the admin batch was already run")
+
+// Undo undoes an applied operation.
+type Undo func() error
+
+// ApplyFunc applies an operation, and returns how to undo it. A nil Undo
+// means there is nothing to undo, e.g. pinning a CID already pinned.
+type ApplyFunc func(ctx context.Context, op Op) (Undo, error)
+
+// Apply applies the ops in order. When one fails, those already applied are
+// undone in reverse order, and the error returned tells whether undoing them
+// failed as well.
+func Apply(ctx context.Context, ops []Op, apply ApplyFunc) error {
+	undos := make([]Undo, 0, len(ops))
+	for i, op := range ops {
+		undo, err := apply(ctx, op)
+		if err == nil {
+			undos = append(undos, undo)
+			continue
+		}
+		err = fmt.Errorf("operation %d (%s): %w", i, op.Type, err)
+
+		var undoErrs []error
+		for j := len(undos) - 1; j >= 0; j-- {
+			if undos[j] == nil {
+				continue
+			}
+			if uerr := undos[j](); uerr != nil {
+				undoErrs = append(undoErrs, fmt.Errorf("undoing operation %d (%s): %w", j, ops[j].Type, uerr))
+			}
+		}
+		if len(undoErrs) > 0 {
+			return fmt.Errorf("%w; the batch was partially rolled back: %v", err, undoErrs)
+		}
+		return fmt.Errorf("%w; the batch was rolled back", err)
+	}
+	return nil
+}
+
+// SeenPrefix is the prefix of the IDs of the batches run, in the datastore of
+// the repo. They are kept until the batches expire, so that the restarts of
+// the node don't allow their replays.
+var SeenPrefix = ds.NewKey("/local/adminbatches")
+
+// Guard runs the batches one at a time, and refuses their replays until they
+// expire.
+type Guard struct {
+	mu sync.Mutex
+}
+
+// NewGuard returns a Guard.
+func NewGuard() *Guard {
+	return &Guard{}
+}
+
+// Run applies the ops of b with apply, unless b was already run according to
+// the datastore d.
+func (g *Guard) Run(ctx context.Context, d ds.Datastore, b *Batch, apply ApplyFunc) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := pruneSeen(ctx, d, time.Now()); err != nil {
+		return err
+	}
+	id, err := b.ID()
+	if err != nil {
+		return err
+	}
+	key := SeenPrefix.ChildString(id)
+	seen, err := d.Has(ctx, key)
+	if err != nil {
+		return err
+	}
+	if seen {
+		return ErrReplayed
+	}
+	// failed batches aren't run again either, they are signed anew
+	expires, err := b.Expires.MarshalText()
+	if err != nil {
+		return err
+	}
+	if err := d.Put(ctx, key, expires); err != nil {
+		return err
+	}
+	if err := d.Sync(ctx, key); err != nil {
+		return err
+	}
+	return Apply(ctx, b.Ops, apply)
+}
+
+// pruneSeen removes the IDs of the batches expired at now from d.
+func pruneSeen(ctx context.Context, d ds.Datastore, now time.Time) error {
+	res, err := d.Query(ctx, query.Query{Prefix: SeenPrefix.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		var expires time.Time
+		if err := expires.UnmarshalText(e.Value); err == nil && !now.After(expires) {
+			continue
+		}
+		if err := d.Delete(ctx, ds.RawKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
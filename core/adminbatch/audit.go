@@ -0,0 +1,61 @@
+package adminbatch
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("admin")
+
+// The outcomes of the batches in the audit log.
+const (
+	OutcomeApplied  = "applied"
+	OutcomeFailed   = "failed"
+	OutcomeRejected = "rejected"
+)
+
+// AuditEntry is an entry of the audit log, for each batch received.
+type AuditEntry struct {
+	Time    time.Time
+	Batch   string `json:",omitempty"`
+	Signer  string `json:",omitempty"`
+	Ops     []Op   `json:",omitempty"`
+	Outcome string
+	Error   string `json:",omitempty"`
+}
+
+// AuditLog appends the entries as JSON lines to a file, and logs them.
+type AuditLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewAuditLog returns the audit log of the file at path, created as needed.
+func NewAuditLog(path string) *AuditLog {
+	return &AuditLog{path: path}
+}
+
+// Record logs e, and appends it to the file.
+func (a *AuditLog) Record(e AuditEntry) error {
+	log.Infow("admin batch", "batch", e.Batch, "signer", e.Signer, "ops", len(e.Ops), "outcome", e.Outcome, "error", e.Error)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
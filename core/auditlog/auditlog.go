@@ -0,0 +1,78 @@
+// Package auditlog implements append-only logs of JSON entries, rotated by
+// size, for the audit of the operations on a node.
+package auditlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// mu serializes the appends and the rotations of the logs, which may share
+// their files.
+var mu sync.Mutex
+
+// Log appends entries to the file at its path, one JSON document per line.
+// Once the file exceeds MaxSize, it is renamed with the suffix .1, the
+// previous .1 file to .2 and so on, keeping MaxFiles rotated files.
+type Log struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+}
+
+// Append appends entry to the log, rotating the file first if it's full.
+func (l *Log) Append(entry interface{}) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err := l.rotate(int64(len(b))); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// rotate rotates the file if writing n more bytes makes it exceed MaxSize.
+func (l *Log) rotate(n int64) error {
+	if l.MaxSize <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(l.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 || fi.Size()+n <= l.MaxSize {
+		return nil
+	}
+	if l.MaxFiles <= 0 {
+		return os.Remove(l.Path)
+	}
+	for i := l.MaxFiles - 1; i > 0; i-- {
+		err := os.Rename(l.rotated(i), l.rotated(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Rename(l.Path, l.rotated(1))
+}
+
+func (l *Log) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", l.Path, i)
+}
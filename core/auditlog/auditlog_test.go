@@ -0,0 +1,36 @@
+package auditlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l := &Log{Path: path, MaxSize: 30, MaxFiles: 2}
+	// each entry is 18 bytes, so that each file holds one
+	for _, e := range []string{"entry-number-1", "entry-number-2", "entry-number-3", "entry-number-4"} {
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, want := range map[string]string{
+		path:        "entry-number-4",
+		path + ".1": "entry-number-3",
+		path + ".2": "entry-number-2",
+	} {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(string(b)); got != `"`+want+`"` {
+			t.Errorf("expected %s in %s, got %s", want, file, got)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only %d rotated files", l.MaxFiles)
+	}
+}
@@ -0,0 +1,117 @@
+package autonatv2
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestMessages(t *testing.T) {
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	for _, m := range []*message{
+		{dialRequest: &dialRequest{addrs: [][]byte{addr.Bytes(), addr.Bytes()}, nonce: 1 << 60}},
+		{dialResponse: &dialResponse{status: responseOK, addrIdx: 1, dialStatus: dialStatusDialError}},
+		{dialDataRequest: &dialDataRequest{addrIdx: 2, numBytes: 50000}},
+		{dialDataResponse: &dialDataResponse{data: []byte("data")}},
+	} {
+		var buf bytes.Buffer
+		if err := writeMsg(&buf, m.marshal()); err != nil {
+			t.Fatal(err)
+		}
+		b, err := readMsg(bufio.NewReader(&buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got message
+		if err := got.unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.marshal(), m.marshal()) {
+			t.Errorf("expected %+v, got %+v", m, got)
+		}
+	}
+
+	var db dialBack
+	if err := db.unmarshal((&dialBack{nonce: 42}).marshal()); err != nil || db.nonce != 42 {
+		t.Errorf("unexpected dial back %+v: %v", db, err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeMsg(&buf, make([]byte, maxMsgSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readMsg(bufio.NewReader(&buf)); err == nil {
+		t.Error("expected an error for a message too large")
+	}
+}
+
+func TestResults(t *testing.T) {
+	public := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	blocked := ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+	gone := ma.StringCast("/ip4/5.6.7.8/tcp/4001")
+	r := NewResults()
+	all := []ma.Multiaddr{public, blocked}
+	r.Filter(append(all, gone))
+
+	now := time.Now()
+	r.record(public, "server", dialStatusOK, now)
+	r.record(blocked, "server", dialStatusDialError, now)
+	r.record(gone, "server", dialStatusOK, now)
+	if res, _ := r.Get(blocked); res.Reachability != network.ReachabilityUnknown {
+		t.Errorf("expected a single failure to leave the reachability unknown, got %s", res.Reachability)
+	}
+	r.record(blocked, "other", dialStatusDialError, now)
+	if res, _ := r.Get(blocked); res.Reachability != network.ReachabilityPrivate {
+		t.Errorf("expected the address to be private, got %s", res.Reachability)
+	}
+
+	if got := r.Filter(all); len(got) != 1 || !got[0].Equal(public) {
+		t.Errorf("expected only the public address to be advertised, got %v", got)
+	}
+	if got := r.Addrs(); len(got) != 2 {
+		t.Errorf("expected the addresses before filtering, got %v", got)
+	}
+
+	r.retain(r.Addrs())
+	results := r.All()
+	if len(results) != 2 || !results[0].Addr.Equal(public) || results[0].Reachability != network.ReachabilityPublic {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s := NewServer(nil, nil)
+	now := time.Now()
+	ok, done := s.admit("a", now)
+	if !ok {
+		t.Fatal("expected the first request to be admitted")
+	}
+	if ok, _ := s.admit("a", now); ok {
+		t.Error("expected a concurrent request of the same peer to be rejected")
+	}
+	done()
+	for i := 1; i < peerRequestsPerMinute; i++ {
+		ok, done := s.admit("a", now)
+		if !ok {
+			t.Fatalf("expected request %d to be admitted", i)
+		}
+		done()
+	}
+	if ok, _ := s.admit("a", now); ok {
+		t.Error("expected the requests over the peer limit to be rejected")
+	}
+	if ok, _ := s.admit("a", now.Add(2*time.Minute)); !ok {
+		t.Error("expected the limit to reset after a minute")
+	}
+
+	if !sameIP(ma.StringCast("/ip4/1.2.3.4/tcp/1"), ma.StringCast("/ip4/1.2.3.4/udp/2/quic-v1")) {
+		t.Error("expected the same IP")
+	}
+	if sameIP(ma.StringCast("/ip4/1.2.3.4/tcp/1"), ma.StringCast("/ip4/1.2.3.5/tcp/1")) {
+		t.Error("expected different IPs")
+	}
+}
@@ -0,0 +1,294 @@
+// Package autonatv2 implements the AutoNAT v2 protocol, with which a node
+// learns the reachability of each of its addresses, rather than a global
+// reachability: the client asks a server to dial one of its addresses, and
+// the server reports whether the dial succeeded, proven by a nonce sent back
+// on the new connection.
+//
+// The addresses found private are not advertised anymore, see
+// Results.Filter.
+package autonatv2
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+var log = logging.Logger("autonatv2")
+
+const (
+	DialProtocol     = "/libp2p/autonat/2/dial-request"
+	DialBackProtocol = "/libp2p/autonat/2/dial-back"
+)
+
+const (
+	// streamTimeout bounds a dial request, dial back included.
+	streamTimeout = time.Minute
+	// maxDialData is the maximum amount of data sent to a server asking for
+	// it before dialing an address.
+	maxDialData = 100000
+	// dialDataChunk is the size of the chunks of the data sent.
+	dialDataChunk = 4000
+	// checkAttempts is the number of servers asked to check an address in a
+	// round, if they refuse.
+	checkAttempts = 3
+)
+
+// Client checks the reachability of the addresses of the node with the
+// AutoNAT v2 servers it is connected to.
+type Client struct {
+	h        host.Host
+	results  *Results
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending map[uint64]chan struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewClient returns a client of h, recording its results in results, which
+// checks the addresses of the node every interval once started.
+func NewClient(h host.Host, results *Results, interval time.Duration) *Client {
+	return &Client{
+		h:        h,
+		results:  results,
+		interval: interval,
+		pending:  make(map[uint64]chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start handles the dial backs, and checks the addresses of the node in the
+// background until Stop is called.
+func (c *Client) Start() {
+	c.h.SetStreamHandler(DialBackProtocol, c.handleDialBack)
+	go func() {
+		defer close(c.done)
+		// the first round waits for connections to servers
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-timer.C:
+				c.round()
+				timer.Reset(c.interval)
+			}
+		}
+	}()
+}
+
+// Stop stops the checks.
+func (c *Client) Stop() {
+	c.h.RemoveStreamHandler(DialBackProtocol)
+	close(c.stop)
+	<-c.done
+}
+
+// Results returns the results of the checks.
+func (c *Client) Results() *Results {
+	return c.results
+}
+
+// round checks each public address of the node with a server, trying other
+// servers if it refuses.
+func (c *Client) round() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	all := c.results.Addrs()
+	c.results.retain(all)
+	servers := c.servers()
+	if len(servers) == 0 {
+		log.Debug("no AutoNAT v2 server to check the addresses with")
+		return
+	}
+	var next int
+	for _, a := range all {
+		if !manet.IsPublicAddr(a) || isRelayed(a) {
+			continue
+		}
+		for i := 0; i < checkAttempts && i < len(servers); i++ {
+			server := servers[next%len(servers)]
+			next++
+			status, err := c.Check(ctx, server, a)
+			if err != nil {
+				log.Debugw("address check failed", "addr", a, "server", server, "error", err)
+				continue
+			}
+			c.results.record(a, server, status, time.Now())
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// servers returns the connected peers supporting the protocol, shuffled.
+func (c *Client) servers() []peer.ID {
+	var servers []peer.ID
+	for _, p := range c.h.Network().Peers() {
+		if protos, err := c.h.Peerstore().SupportsProtocols(p, DialProtocol); err == nil && len(protos) > 0 {
+			servers = append(servers, p)
+		}
+	}
+	mrand.Shuffle(len(servers), func(i, j int) { servers[i], servers[j] = servers[j], servers[i] })
+	return servers
+}
+
+// Check asks server to dial addr, and returns the outcome of the dial.
+func (c *Client) Check(ctx context.Context, server peer.ID, addr ma.Multiaddr) (dialStatus, error) {
+	var nb [8]byte
+	if _, err := rand.Read(nb[:]); err != nil {
+		return dialStatusUnused, err
+	}
+	nonce := binary.LittleEndian.Uint64(nb[:])
+	dialedBack := make(chan struct{}, 1)
+	c.mu.Lock()
+	c.pending[nonce] = dialedBack
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, nonce)
+		c.mu.Unlock()
+	}()
+
+	s, err := c.h.NewStream(ctx, server, DialProtocol)
+	if err != nil {
+		return dialStatusUnused, err
+	}
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(streamTimeout)); err != nil {
+		s.Reset()
+		return dialStatusUnused, err
+	}
+
+	req := &message{dialRequest: &dialRequest{addrs: [][]byte{addr.Bytes()}, nonce: nonce}}
+	if err := writeMsg(s, req.marshal()); err != nil {
+		s.Reset()
+		return dialStatusUnused, err
+	}
+	r := bufio.NewReader(s)
+	var resp *dialResponse
+	for resp == nil {
+		b, err := readMsg(r)
+		if err != nil {
+			s.Reset()
+			return dialStatusUnused, err
+		}
+		var m message
+		if err := m.unmarshal(b); err != nil {
+			s.Reset()
+			return dialStatusUnused, err
+		}
+		switch {
+		case m.dialDataRequest != nil:
+			if err := sendDialData(s, m.dialDataRequest.numBytes); err != nil {
+				s.Reset()
+				return dialStatusUnused, err
+			}
+		case m.dialResponse != nil:
+			resp = m.dialResponse
+		default:
+			s.Reset()
+			return dialStatusUnused, errUnexpectedMsg
+		}
+	}
+
+	if resp.status != responseOK {
+		return dialStatusUnused, fmt.Errorf("dial request refused with status %d", resp.status)
+	}
+	if resp.dialStatus == dialStatusOK {
+		// the nonce is sent back before the response
+		select {
+		case <-dialedBack:
+		default:
+			return dialStatusUnused, errors.New("the server reported a dial back which wasn't received")
+		}
+	}
+	return resp.dialStatus, nil
+}
+
+// sendDialData sends n bytes of data, which the server asks for before
+// dialing an address of another IP than the one of the client, so that
+// requests don't amplify attacks on third parties.
+func sendDialData(s network.Stream, n uint64) error {
+	if n > maxDialData {
+		return fmt.Errorf("the server asked for %d bytes of dial data", n)
+	}
+	chunk := make([]byte, dialDataChunk)
+	for n > 0 {
+		size := uint64(len(chunk))
+		if n < size {
+			size = n
+		}
+		m := &message{dialDataResponse: &dialDataResponse{data: chunk[:size]}}
+		if err := writeMsg(s, m.marshal()); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
+
+// handleDialBack receives the nonces the servers dial back with.
+func (c *Client) handleDialBack(s network.Stream) {
+	defer s.Close()
+	if err := s.SetDeadline(time.Now().Add(streamTimeout)); err != nil {
+		s.Reset()
+		return
+	}
+	b, err := readMsg(bufio.NewReader(s))
+	if err != nil {
+		s.Reset()
+		return
+	}
+	var m dialBack
+	if err := m.unmarshal(b); err != nil {
+		s.Reset()
+		return
+	}
+	c.mu.Lock()
+	dialedBack, ok := c.pending[m.nonce]
+	c.mu.Unlock()
+	if ok {
+		select {
+		case dialedBack <- struct{}{}:
+		default:
+		}
+	}
+	if err := writeMsg(s, dialBackResponseOK); err != nil {
+		s.Reset()
+	}
+}
+
+// isRelayed returns whether a is a relayed address.
+func isRelayed(a ma.Multiaddr) bool {
+	_, err := a.ValueForProtocol(ma.P_CIRCUIT)
+	return err == nil
+}
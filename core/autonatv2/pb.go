@@ -0,0 +1,254 @@
+package autonatv2
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages of the protocol, see
+// https://github.com/libp2p/specs/blob/master/autonat/autonat-v2.md. They
+// are encoded by hand: there are few, and they don't change.
+
+// maxMsgSize is the maximum size of a message.
+const maxMsgSize = 8192
+
+// dialStatus is the outcome of the dial of an address.
+type dialStatus uint64
+
+const (
+	dialStatusUnused        dialStatus = 0
+	dialStatusDialError     dialStatus = 100
+	dialStatusDialBackError dialStatus = 101
+	dialStatusOK            dialStatus = 200
+)
+
+// responseStatus is the outcome of a dial request.
+type responseStatus uint64
+
+const (
+	responseInternalError   responseStatus = 0
+	responseRequestRejected responseStatus = 100
+	responseDialRefused     responseStatus = 101
+	responseOK              responseStatus = 200
+)
+
+type dialRequest struct {
+	addrs [][]byte
+	nonce uint64
+}
+
+type dialResponse struct {
+	status     responseStatus
+	addrIdx    uint32
+	dialStatus dialStatus
+}
+
+type dialDataRequest struct {
+	addrIdx  uint32
+	numBytes uint64
+}
+
+type dialDataResponse struct {
+	data []byte
+}
+
+// message is one of the messages of the dial-request protocol.
+type message struct {
+	dialRequest      *dialRequest
+	dialResponse     *dialResponse
+	dialDataRequest  *dialDataRequest
+	dialDataResponse *dialDataResponse
+}
+
+// dialBack is the message of the dial-back protocol, answered by a
+// dialBackResponse whose only status is OK.
+type dialBack struct {
+	nonce uint64
+}
+
+func (m *message) marshal() []byte {
+	var b []byte
+	switch {
+	case m.dialRequest != nil:
+		var f []byte
+		for _, a := range m.dialRequest.addrs {
+			f = protowire.AppendTag(f, 1, protowire.BytesType)
+			f = protowire.AppendBytes(f, a)
+		}
+		f = protowire.AppendTag(f, 2, protowire.Fixed64Type)
+		f = protowire.AppendFixed64(f, m.dialRequest.nonce)
+		b = appendMessage(b, 1, f)
+	case m.dialResponse != nil:
+		var f []byte
+		f = appendVarint(f, 1, uint64(m.dialResponse.status))
+		f = appendVarint(f, 2, uint64(m.dialResponse.addrIdx))
+		f = appendVarint(f, 3, uint64(m.dialResponse.dialStatus))
+		b = appendMessage(b, 2, f)
+	case m.dialDataRequest != nil:
+		var f []byte
+		f = appendVarint(f, 1, uint64(m.dialDataRequest.addrIdx))
+		f = appendVarint(f, 2, m.dialDataRequest.numBytes)
+		b = appendMessage(b, 3, f)
+	case m.dialDataResponse != nil:
+		var f []byte
+		f = protowire.AppendTag(f, 1, protowire.BytesType)
+		f = protowire.AppendBytes(f, m.dialDataResponse.data)
+		b = appendMessage(b, 4, f)
+	}
+	return b
+}
+
+func (m *message) unmarshal(b []byte) error {
+	*m = message{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			m.dialRequest = &dialRequest{}
+			return consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					m.dialRequest.addrs = append(m.dialRequest.addrs, v)
+				case num == 2 && typ == protowire.Fixed64Type:
+					m.dialRequest.nonce = binary.LittleEndian.Uint64(v)
+				}
+				return nil
+			})
+		case 2:
+			m.dialResponse = &dialResponse{}
+			return consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				n, _ := protowire.ConsumeVarint(v)
+				switch {
+				case typ != protowire.VarintType:
+				case num == 1:
+					m.dialResponse.status = responseStatus(n)
+				case num == 2:
+					m.dialResponse.addrIdx = uint32(n)
+				case num == 3:
+					m.dialResponse.dialStatus = dialStatus(n)
+				}
+				return nil
+			})
+		case 3:
+			m.dialDataRequest = &dialDataRequest{}
+			return consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				n, _ := protowire.ConsumeVarint(v)
+				switch {
+				case typ != protowire.VarintType:
+				case num == 1:
+					m.dialDataRequest.addrIdx = uint32(n)
+				case num == 2:
+					m.dialDataRequest.numBytes = n
+				}
+				return nil
+			})
+		case 4:
+			m.dialDataResponse = &dialDataResponse{}
+			return consumeFields(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num == 1 && typ == protowire.BytesType {
+					m.dialDataResponse.data = v
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+func (m *dialBack) marshal() []byte {
+	b := protowire.AppendTag(nil, 1, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, m.nonce)
+}
+
+func (m *dialBack) unmarshal(b []byte) error {
+	*m = dialBack{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num == 1 && typ == protowire.Fixed64Type {
+			m.nonce = binary.LittleEndian.Uint64(v)
+		}
+		return nil
+	})
+}
+
+// dialBackResponseOK is the encoded dialBackResponse with the status OK, the
+// default value.
+var dialBackResponseOK = []byte{}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// consumeFields calls f with the fields of the message b. The values of the
+// varint fields are passed encoded, and those of the fixed64 fields as 8
+// little endian bytes.
+func consumeFields(b []byte, f func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		var v []byte
+		switch typ {
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			if n >= 0 {
+				v = b[:n]
+			}
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := f(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMsg writes b prefixed by its length.
+func writeMsg(w io.Writer, b []byte) error {
+	buf := protowire.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(b)), uint64(len(b)))
+	_, err := w.Write(append(buf, b...))
+	return err
+}
+
+// readMsg reads a message prefixed by its length.
+func readMsg(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxMsgSize {
+		return nil, fmt.Errorf("message of %d bytes is too large", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+var errUnexpectedMsg = errors.New("unexpected message")
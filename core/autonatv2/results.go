@@ -0,0 +1,132 @@
+package autonatv2
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// privateAfter is the number of consecutive failed dials, by different
+// servers, for an address to be private. A single server failing to dial
+// may be down to its own network.
+const privateAfter = 2
+
+// Result is the reachability of an address of the node.
+type Result struct {
+	Addr         ma.Multiaddr
+	Reachability network.Reachability
+	// Checked is the time of the last check, and Server the peer which
+	// made it.
+	Checked time.Time
+	Server  peer.ID
+}
+
+type result struct {
+	Result
+	failures int
+}
+
+// Results holds the reachability of the addresses of the node. It filters
+// the addresses the node advertises, see Filter.
+type Results struct {
+	mu      sync.Mutex
+	results map[string]*result
+	// addrs are the addresses of the node, before Filter
+	addrs []ma.Multiaddr
+}
+
+// NewResults returns empty Results.
+func NewResults() *Results {
+	return &Results{results: make(map[string]*result)}
+}
+
+// Filter returns addrs without the addresses known to be private. addrs are
+// the addresses of the node, which the client checks.
+func (r *Results) Filter(addrs []ma.Multiaddr) []ma.Multiaddr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.addrs = append(r.addrs[:0], addrs...)
+	out := make([]ma.Multiaddr, 0, len(addrs))
+	for _, a := range addrs {
+		if res, ok := r.results[string(a.Bytes())]; ok && res.Reachability == network.ReachabilityPrivate {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// Addrs returns the addresses of the node last passed to Filter.
+func (r *Results) Addrs() []ma.Multiaddr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ma.Multiaddr(nil), r.addrs...)
+}
+
+// All returns the results of the addresses checked, sorted by address.
+func (r *Results) All() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Result, 0, len(r.results))
+	for _, res := range r.results {
+		out = append(out, res.Result)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Addr.String() < out[j].Addr.String()
+	})
+	return out
+}
+
+// Get returns the result of addr.
+func (r *Results) Get(addr ma.Multiaddr) (Result, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.results[string(addr.Bytes())]
+	if !ok {
+		return Result{}, false
+	}
+	return res.Result, true
+}
+
+// record records the outcome of the dial of addr by server. The dials which
+// failed to dial back leave the reachability as is.
+func (r *Results) record(addr ma.Multiaddr, server peer.ID, status dialStatus, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	k := string(addr.Bytes())
+	res, ok := r.results[k]
+	if !ok {
+		res = &result{Result: Result{Addr: addr, Reachability: network.ReachabilityUnknown}}
+		r.results[k] = res
+	}
+	res.Checked = now
+	res.Server = server
+	switch status {
+	case dialStatusOK:
+		res.Reachability = network.ReachabilityPublic
+		res.failures = 0
+	case dialStatusDialError:
+		if res.failures++; res.failures >= privateAfter {
+			res.Reachability = network.ReachabilityPrivate
+		}
+	}
+}
+
+// retain forgets the results of the addresses not in addrs.
+func (r *Results) retain(addrs []ma.Multiaddr) {
+	keep := make(map[string]struct{}, len(addrs))
+	for _, a := range addrs {
+		keep[string(a.Bytes())] = struct{}{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k := range r.results {
+		if _, ok := keep[k]; !ok {
+			delete(r.results, k)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package autonatv2
+
+import (
+	"bufio"
+	"context"
+	mrand "math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/transport"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	// dialBackTimeout bounds the dial back of an address.
+	dialBackTimeout = 15 * time.Second
+	// The dial data asked for before dialing an address of another IP than
+	// the one of the client, between minDialData and maxDialData bytes.
+	minDialData = 30000
+)
+
+// The dial requests served per minute, by all peers and by each peer. Each
+// peer has one request served at a time.
+const (
+	globalRequestsPerMinute = 60
+	peerRequestsPerMinute   = 12
+)
+
+// Server serves the dial requests of the clients of h, dialing their
+// addresses back with dialer, a host of another peer ID so that the dials
+// make new connections.
+type Server struct {
+	h      host.Host
+	dialer host.Host
+
+	mu          sync.Mutex
+	windowStart time.Time
+	global      int
+	peers       map[peer.ID]int
+	active      map[peer.ID]struct{}
+}
+
+// NewServer returns a server of h dialing back with dialer.
+func NewServer(h, dialer host.Host) *Server {
+	return &Server{
+		h:      h,
+		dialer: dialer,
+		peers:  make(map[peer.ID]int),
+		active: make(map[peer.ID]struct{}),
+	}
+}
+
+// Start serves the dial requests until Close is called.
+func (s *Server) Start() {
+	s.h.SetStreamHandler(DialProtocol, s.handleDialRequest)
+}
+
+// Close stops serving the dial requests, and closes the dialer.
+func (s *Server) Close() error {
+	s.h.RemoveStreamHandler(DialProtocol)
+	return s.dialer.Close()
+}
+
+// admit returns whether a request of p is within the limits, and marks it
+// active until done is called.
+func (s *Server) admit(p peer.ID, now time.Time) (ok bool, done func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.windowStart) > time.Minute {
+		s.windowStart = now
+		s.global = 0
+		s.peers = make(map[peer.ID]int)
+	}
+	if _, busy := s.active[p]; busy || s.global >= globalRequestsPerMinute || s.peers[p] >= peerRequestsPerMinute {
+		return false, nil
+	}
+	s.global++
+	s.peers[p]++
+	s.active[p] = struct{}{}
+	return true, func() {
+		s.mu.Lock()
+		delete(s.active, p)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Server) handleDialRequest(st network.Stream) {
+	defer st.Close()
+	if err := st.SetDeadline(time.Now().Add(streamTimeout)); err != nil {
+		st.Reset()
+		return
+	}
+	r := bufio.NewReader(st)
+	b, err := readMsg(r)
+	if err != nil {
+		st.Reset()
+		return
+	}
+	var m message
+	if err := m.unmarshal(b); err != nil || m.dialRequest == nil {
+		st.Reset()
+		return
+	}
+	respond := func(resp *dialResponse) {
+		if err := writeMsg(st, (&message{dialResponse: resp}).marshal()); err != nil {
+			st.Reset()
+		}
+	}
+
+	p := st.Conn().RemotePeer()
+	ok, done := s.admit(p, time.Now())
+	if !ok {
+		respond(&dialResponse{status: responseRequestRejected})
+		return
+	}
+	defer done()
+
+	idx, addr := s.selectAddr(m.dialRequest.addrs)
+	if addr == nil {
+		respond(&dialResponse{status: responseDialRefused})
+		return
+	}
+	if !sameIP(addr, st.Conn().RemoteMultiaddr()) {
+		numBytes := uint64(minDialData + mrand.Intn(maxDialData-minDialData+1))
+		req := &message{dialDataRequest: &dialDataRequest{addrIdx: uint32(idx), numBytes: numBytes}}
+		if err := writeMsg(st, req.marshal()); err != nil {
+			st.Reset()
+			return
+		}
+		if err := receiveDialData(r, numBytes); err != nil {
+			st.Reset()
+			return
+		}
+	}
+
+	status := s.dialBack(p, addr, m.dialRequest.nonce)
+	respond(&dialResponse{status: responseOK, addrIdx: uint32(idx), dialStatus: status})
+}
+
+// selectAddr returns the first of addrs the server dials: a public address
+// of a transport of the dialer.
+func (s *Server) selectAddr(addrs [][]byte) (int, ma.Multiaddr) {
+	tpts, _ := s.dialer.Network().(interface {
+		TransportForDialing(ma.Multiaddr) transport.Transport
+	})
+	for i, b := range addrs {
+		a, err := ma.NewMultiaddrBytes(b)
+		if err != nil || !manet.IsPublicAddr(a) || isRelayed(a) {
+			continue
+		}
+		if tpts != nil {
+			if t := tpts.TransportForDialing(a); t == nil || !t.CanDial(a) {
+				continue
+			}
+		}
+		return i, a
+	}
+	return 0, nil
+}
+
+// receiveDialData reads the dialDataResponses until n bytes are received.
+func receiveDialData(r *bufio.Reader, n uint64) error {
+	var received uint64
+	for received < n {
+		b, err := readMsg(r)
+		if err != nil {
+			return err
+		}
+		var m message
+		if err := m.unmarshal(b); err != nil {
+			return err
+		}
+		if m.dialDataResponse == nil {
+			return errUnexpectedMsg
+		}
+		received += uint64(len(m.dialDataResponse.data))
+	}
+	return nil
+}
+
+// dialBack dials p at addr only, and sends it nonce.
+func (s *Server) dialBack(p peer.ID, addr ma.Multiaddr, nonce uint64) dialStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), dialBackTimeout)
+	defer cancel()
+	ctx = network.WithForceDirectDial(ctx, "autonatv2")
+
+	s.dialer.Peerstore().AddAddr(p, addr, peerstore.TempAddrTTL)
+	defer func() {
+		s.dialer.Network().ClosePeer(p)
+		s.dialer.Peerstore().ClearAddrs(p)
+	}()
+	if _, err := s.dialer.Network().DialPeer(ctx, p); err != nil {
+		return dialStatusDialError
+	}
+
+	st, err := s.dialer.NewStream(ctx, p, DialBackProtocol)
+	if err != nil {
+		return dialStatusDialBackError
+	}
+	defer st.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = st.SetDeadline(deadline)
+	}
+	if err := writeMsg(st, (&dialBack{nonce: nonce}).marshal()); err != nil {
+		st.Reset()
+		return dialStatusDialBackError
+	}
+	if _, err := readMsg(bufio.NewReader(st)); err != nil {
+		st.Reset()
+		return dialStatusDialBackError
+	}
+	return dialStatusOK
+}
+
+// sameIP returns whether a and b have the same IP.
+func sameIP(a, b ma.Multiaddr) bool {
+	ipa, err := manet.ToIP(a)
+	if err != nil {
+		return false
+	}
+	ipb, err := manet.ToIP(b)
+	return err == nil && ipa.Equal(ipb)
+}
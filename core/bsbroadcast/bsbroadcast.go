@@ -0,0 +1,235 @@
+// Package bsbroadcast limits how widely bitswap broadcasts its WANT-HAVE
+// requests, and scores the peers on their answers, so that nodes with
+// thousands of connections don't waste bandwidth asking peers that never
+// answer.
+//
+// It wraps the bitswap network: the WANT-HAVE entries of the outgoing
+// messages are dropped past the fan-out, and the incoming messages are
+// counted as the answers of the peers.
+package bsbroadcast
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	pb "github.com/ipfs/go-libipfs/bitswap/message/pb"
+	bsnet "github.com/ipfs/go-libipfs/bitswap/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	// ProbeEvery is the ratio of the WANT-HAVE requests still sent to the
+	// unresponsive peers, so that they can redeem themselves.
+	ProbeEvery = 16
+
+	// wantTTL is how long a WANT-HAVE counts against the fan-out if it isn't
+	// cancelled.
+	wantTTL       = 10 * time.Minute
+	sweepInterval = time.Minute
+)
+
+// PeerStats counts the requests sent to a peer and its answers.
+type PeerStats struct {
+	WantHaves  uint64
+	WantBlocks uint64
+	// WANT-HAVE requests not sent to the peer
+	Dropped   uint64
+	Haves     uint64
+	DontHaves uint64
+	Blocks    uint64
+}
+
+// HitRate is the ratio of the requests sent to the peer that it answered
+// with a HAVE or a block.
+func (s PeerStats) HitRate() float64 {
+	if s.WantHaves+s.WantBlocks == 0 {
+		return 0
+	}
+	return float64(s.Haves+s.Blocks) / float64(s.WantHaves+s.WantBlocks)
+}
+
+func (s PeerStats) answers() uint64 {
+	return s.Haves + s.DontHaves + s.Blocks
+}
+
+type want struct {
+	peers int
+	since time.Time
+}
+
+// Network is a bitswap network limiting the fan-out of the WANT-HAVE
+// requests.
+type Network struct {
+	bsnet.BitSwapNetwork
+	fanOut            int
+	unresponsiveAfter uint64
+
+	mu        sync.Mutex
+	wants     map[cid.Cid]*want
+	peers     map[peer.ID]*PeerStats
+	lastSweep time.Time
+}
+
+// Wrap wraps n. A WANT-HAVE for a block is sent to at most fanOut peers
+// until it is cancelled, 0 for no limit, besides the peers that answered
+// requests before: they are always asked. Peers that didn't answer any of
+// unresponsiveAfter WANT-HAVE requests are unresponsive: they are only sent
+// one request in ProbeEvery, 0 disables it.
+func Wrap(n bsnet.BitSwapNetwork, fanOut int, unresponsiveAfter uint64) *Network {
+	return &Network{
+		BitSwapNetwork:    n,
+		fanOut:            fanOut,
+		unresponsiveAfter: unresponsiveAfter,
+		wants:             make(map[cid.Cid]*want),
+		peers:             make(map[peer.ID]*PeerStats),
+	}
+}
+
+// Unresponsive returns true if s is the stats of an unresponsive peer.
+func (n *Network) Unresponsive(s PeerStats) bool {
+	return n.unresponsiveAfter > 0 && s.WantHaves >= n.unresponsiveAfter && s.answers() == 0
+}
+
+// Peers returns the stats of the connected peers.
+func (n *Network) Peers() map[peer.ID]PeerStats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	out := make(map[peer.ID]PeerStats, len(n.peers))
+	for p, s := range n.peers {
+		out[p] = *s
+	}
+	return out
+}
+
+func (n *Network) stats(p peer.ID) *PeerStats {
+	s, ok := n.peers[p]
+	if !ok {
+		s = new(PeerStats)
+		n.peers[p] = s
+	}
+	return s
+}
+
+// filter drops the WANT-HAVE entries of msg that shouldn't be sent to p, and
+// returns false if nothing is left to send.
+func (n *Network) filter(p peer.ID, msg bsmsg.BitSwapMessage) bool {
+	entries := msg.Wantlist()
+	if len(entries) == 0 {
+		return true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	if now.Sub(n.lastSweep) > sweepInterval {
+		for c, w := range n.wants {
+			if now.Sub(w.since) > wantTTL {
+				delete(n.wants, c)
+			}
+		}
+		n.lastSweep = now
+	}
+
+	s := n.stats(p)
+	unresponsive := n.Unresponsive(*s)
+	for _, e := range entries {
+		switch {
+		case e.Cancel:
+			delete(n.wants, e.Cid)
+			continue
+		case e.WantType == pb.Message_Wantlist_Block:
+			s.WantBlocks++
+			continue
+		}
+		w, ok := n.wants[e.Cid]
+		if !ok {
+			w = &want{since: now}
+			n.wants[e.Cid] = w
+		}
+		// peers that answered before are always asked
+		drop := s.answers() == 0 && n.fanOut > 0 && w.peers >= n.fanOut
+		drop = drop || (unresponsive && (s.WantHaves+s.Dropped)%ProbeEvery != 0)
+		if drop {
+			msg.Remove(e.Cid)
+			s.Dropped++
+			continue
+		}
+		w.peers++
+		s.WantHaves++
+	}
+	return !msg.Empty()
+}
+
+func (n *Network) received(p peer.ID, msg bsmsg.BitSwapMessage) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s := n.stats(p)
+	s.Haves += uint64(len(msg.Haves()))
+	s.DontHaves += uint64(len(msg.DontHaves()))
+	s.Blocks += uint64(len(msg.Blocks()))
+}
+
+// SendMessage implements bsnet.BitSwapNetwork.
+func (n *Network) SendMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) error {
+	if !n.filter(p, msg) {
+		return nil
+	}
+	return n.BitSwapNetwork.SendMessage(ctx, p, msg)
+}
+
+// NewMessageSender implements bsnet.BitSwapNetwork.
+func (n *Network) NewMessageSender(ctx context.Context, p peer.ID, opts *bsnet.MessageSenderOpts) (bsnet.MessageSender, error) {
+	ms, err := n.BitSwapNetwork.NewMessageSender(ctx, p, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &messageSender{MessageSender: ms, n: n, p: p}, nil
+}
+
+// Start implements bsnet.BitSwapNetwork.
+func (n *Network) Start(rs ...bsnet.Receiver) {
+	wrapped := make([]bsnet.Receiver, len(rs))
+	for i, r := range rs {
+		// each message is delivered to every receiver: count it once
+		wrapped[i] = &receiver{Receiver: r, n: n, count: i == 0}
+	}
+	n.BitSwapNetwork.Start(wrapped...)
+}
+
+type messageSender struct {
+	bsnet.MessageSender
+	n *Network
+	p peer.ID
+}
+
+func (ms *messageSender) SendMsg(ctx context.Context, msg bsmsg.BitSwapMessage) error {
+	if !ms.n.filter(ms.p, msg) {
+		return nil
+	}
+	return ms.MessageSender.SendMsg(ctx, msg)
+}
+
+type receiver struct {
+	bsnet.Receiver
+	n     *Network
+	count bool
+}
+
+func (r *receiver) ReceiveMessage(ctx context.Context, p peer.ID, msg bsmsg.BitSwapMessage) {
+	if r.count {
+		r.n.received(p, msg)
+	}
+	r.Receiver.ReceiveMessage(ctx, p, msg)
+}
+
+func (r *receiver) PeerDisconnected(p peer.ID) {
+	if r.count {
+		r.n.mu.Lock()
+		delete(r.n.peers, p)
+		r.n.mu.Unlock()
+	}
+	r.Receiver.PeerDisconnected(p)
+}
@@ -0,0 +1,71 @@
+package bsbroadcast
+
+import (
+	"testing"
+
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	pb "github.com/ipfs/go-libipfs/bitswap/message/pb"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func wantHave(b blocks.Block) bsmsg.BitSwapMessage {
+	msg := bsmsg.New(false)
+	msg.AddEntry(b.Cid(), 1, pb.Message_Wantlist_Have, true)
+	return msg
+}
+
+func TestFanOut(t *testing.T) {
+	n := Wrap(nil, 2, 0)
+	b := blocks.NewBlock([]byte("block"))
+
+	sent := 0
+	for _, p := range []peer.ID{"a", "b", "c", "d"} {
+		if n.filter(p, wantHave(b)) {
+			sent++
+		}
+	}
+	if sent != 2 {
+		t.Fatalf("expected the want to be sent to 2 peers, got %d", sent)
+	}
+
+	// peers that answered are always asked
+	have := bsmsg.New(false)
+	have.AddHave(b.Cid())
+	n.received("c", have)
+	if !n.filter("c", wantHave(b)) {
+		t.Fatal("expected the want to be sent to a peer that answered")
+	}
+
+	// a cancel resets the fan-out
+	cancel := bsmsg.New(false)
+	cancel.Cancel(b.Cid())
+	if !n.filter("a", cancel) {
+		t.Fatal("expected the cancel to be sent")
+	}
+	if !n.filter("d", wantHave(b)) {
+		t.Fatal("expected the want to be sent after the cancel")
+	}
+
+	s := n.Peers()["c"]
+	if s.HitRate() != 1 {
+		t.Fatalf("expected a hit rate of 1, got %f", s.HitRate())
+	}
+}
+
+func TestUnresponsive(t *testing.T) {
+	n := Wrap(nil, 0, 10)
+	sent := 0
+	for i := 0; i < 10+3*ProbeEvery; i++ {
+		b := blocks.NewBlock([]byte{byte(i)})
+		if n.filter("p", wantHave(b)) {
+			sent++
+		}
+	}
+	if sent != 13 {
+		t.Fatalf("expected the unresponsive peer to be probed 3 times, got %d requests", sent)
+	}
+	if !n.Unresponsive(n.Peers()["p"]) {
+		t.Fatal("expected the peer to be unresponsive")
+	}
+}
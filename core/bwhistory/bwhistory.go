@@ -0,0 +1,141 @@
+// Package bwhistory keeps a short rolling history of the bandwidth metrics,
+// broken down by protocol and by peer, so that recent traffic spikes can be
+// inspected after the fact.
+package bwhistory
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// Sample is a snapshot of the bandwidth metrics.
+type Sample struct {
+	Time      time.Time
+	Totals    metrics.Stats
+	Protocols map[protocol.ID]metrics.Stats `json:",omitempty"`
+	// Peers only holds the busiest peers at the time of the sample.
+	Peers map[peer.ID]metrics.Stats `json:",omitempty"`
+}
+
+// Reporter is the subset of *metrics.BandwidthCounter sampled by History.
+type Reporter interface {
+	GetBandwidthTotals() metrics.Stats
+	GetBandwidthByProtocol() map[protocol.ID]metrics.Stats
+	GetBandwidthByPeer() map[peer.ID]metrics.Stats
+}
+
+// History samples a Reporter at a regular interval and keeps the samples for
+// the retention period.
+type History struct {
+	reporter  Reporter
+	interval  time.Duration
+	retention time.Duration
+	maxPeers  int
+
+	mu      sync.Mutex
+	samples []Sample // oldest first
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a History of reporter. Call Start to begin sampling.
+func New(reporter Reporter, interval, retention time.Duration, maxPeers int) *History {
+	return &History{
+		reporter:  reporter,
+		interval:  interval,
+		retention: retention,
+		maxPeers:  maxPeers,
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Interval returns the time between two samples.
+func (h *History) Interval() time.Duration {
+	return h.interval
+}
+
+// Retention returns how long samples are kept.
+func (h *History) Retention() time.Duration {
+	return h.retention
+}
+
+// Start samples the reporter in the background until Stop is called.
+func (h *History) Start() {
+	go func() {
+		defer close(h.done)
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.stop:
+				return
+			case now := <-ticker.C:
+				h.record(now)
+			}
+		}
+	}()
+}
+
+// Stop stops sampling.
+func (h *History) Stop() {
+	close(h.stop)
+	<-h.done
+}
+
+func (h *History) record(now time.Time) {
+	s := Sample{
+		Time:      now,
+		Totals:    h.reporter.GetBandwidthTotals(),
+		Protocols: h.reporter.GetBandwidthByProtocol(),
+		Peers:     busiestPeers(h.reporter.GetBandwidthByPeer(), h.maxPeers),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.samples = append(h.samples, s)
+	cutoff := now.Add(-h.retention)
+	i := 0
+	for i < len(h.samples) && h.samples[i].Time.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		h.samples = append(h.samples[:0], h.samples[i:]...)
+	}
+}
+
+// busiestPeers returns the n peers with the highest current rates.
+func busiestPeers(peers map[peer.ID]metrics.Stats, n int) map[peer.ID]metrics.Stats {
+	if len(peers) <= n {
+		return peers
+	}
+	ids := make([]peer.ID, 0, len(peers))
+	for p := range peers {
+		ids = append(ids, p)
+	}
+	rate := func(s metrics.Stats) float64 { return s.RateIn + s.RateOut }
+	sort.Slice(ids, func(i, j int) bool {
+		return rate(peers[ids[i]]) > rate(peers[ids[j]])
+	})
+	out := make(map[peer.ID]metrics.Stats, n)
+	for _, p := range ids[:n] {
+		out[p] = peers[p]
+	}
+	return out
+}
+
+// Samples returns the samples taken at or after since, oldest first.
+func (h *History) Samples(since time.Time) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.Search(len(h.samples), func(i int) bool {
+		return !h.samples[i].Time.Before(since)
+	})
+	return append([]Sample(nil), h.samples[i:]...)
+}
@@ -0,0 +1,64 @@
+package bwhistory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+type fakeReporter struct {
+	peers map[peer.ID]metrics.Stats
+}
+
+func (r *fakeReporter) GetBandwidthTotals() metrics.Stats {
+	return metrics.Stats{TotalIn: 10, TotalOut: 20}
+}
+
+func (r *fakeReporter) GetBandwidthByProtocol() map[protocol.ID]metrics.Stats {
+	return map[protocol.ID]metrics.Stats{"/ipfs/bitswap/1.2.0": {TotalIn: 10}}
+}
+
+func (r *fakeReporter) GetBandwidthByPeer() map[peer.ID]metrics.Stats {
+	return r.peers
+}
+
+func TestHistory(t *testing.T) {
+	r := &fakeReporter{peers: map[peer.ID]metrics.Stats{
+		"slow":   {RateIn: 1},
+		"fast":   {RateIn: 100},
+		"medium": {RateOut: 10},
+	}}
+	h := New(r, time.Second, time.Minute, 2)
+
+	start := time.Now()
+	for i := 0; i < 90; i++ {
+		h.record(start.Add(time.Duration(i) * time.Second))
+	}
+
+	samples := h.Samples(time.Time{})
+	if len(samples) != 61 {
+		t.Fatalf("expected the samples of the last minute, got %d", len(samples))
+	}
+	if !samples[0].Time.Equal(start.Add(29 * time.Second)) {
+		t.Fatalf("unexpected oldest sample at %s", samples[0].Time.Sub(start))
+	}
+
+	last := samples[len(samples)-1]
+	if len(last.Peers) != 2 {
+		t.Fatalf("expected the 2 busiest peers, got %d", len(last.Peers))
+	}
+	if _, ok := last.Peers["slow"]; ok {
+		t.Fatal("expected the slowest peer to be left out")
+	}
+	if last.Protocols["/ipfs/bitswap/1.2.0"].TotalIn != 10 {
+		t.Fatal("expected the protocol breakdown")
+	}
+
+	recent := h.Samples(start.Add(80 * time.Second))
+	if len(recent) != 10 {
+		t.Fatalf("expected 10 recent samples, got %d", len(recent))
+	}
+}
@@ -0,0 +1,214 @@
+// Package capability issues and verifies the capability tokens granting the
+// access to the protected paths of the gateway, so that a node can share a
+// file with a link that expires.
+//
+// The tokens are UCAN-style JWTs signed with an Ed25519 key: the issuer is the
+// did:key of the key, and the attenuations list the content paths which can
+// be read, along with their subpaths. The tokens are bearer tokens, they have
+// no audience.
+package capability
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	pathpkg "path"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multibase"
+)
+
+// Ability is the ability granted on the paths of a token.
+const Ability = "gateway/read"
+
+// ucanVersion is the version of the UCAN specification the tokens follow.
+const ucanVersion = "0.9.0"
+
+// ed25519PubCodec is the varint of the ed25519-pub multicodec, prefixing the
+// public key in a did:key.
+var ed25519PubCodec = []byte{0xed, 0x01}
+
+var (
+	// ErrInvalid is returned for malformed tokens, and for tokens not signed
+	// by the key of the verifier.
+	ErrInvalid = errors.New("invalid capability token")
+
+	// ErrExpired is returned for tokens past their expiry, or not valid yet.
+	ErrExpired = errors.New("expired capability token")
+
+	// ErrNotGranted is returned for valid tokens not granting the access to
+	// the requested path.
+	ErrNotGranted = errors.New("the capability token does not grant access to this path")
+)
+
+// Attenuation is a capability granted by a token.
+type Attenuation struct {
+	With string `json:"with"`
+	Can  string `json:"can"`
+}
+
+// Claims is the payload of a token.
+type Claims struct {
+	Issuer       string        `json:"iss"`
+	Attenuations []Attenuation `json:"att"`
+	NotBefore    int64         `json:"nbf,omitempty"`
+	Expiry       int64         `json:"exp"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Ucv string `json:"ucv"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// DID returns the did:key of an Ed25519 public key.
+func DID(pk crypto.PubKey) (string, error) {
+	if pk.Type() != crypto.Ed25519 {
+		return "", fmt.Errorf("capability tokens are signed with ed25519 keys, not %s keys", strings.ToLower(pk.Type().String()))
+	}
+	raw, err := pk.Raw()
+	if err != nil {
+		return "", err
+	}
+	id, err := multibase.Encode(multibase.Base58BTC, append(append([]byte(nil), ed25519PubCodec...), raw...))
+	if err != nil {
+		return "", err
+	}
+	return "did:key:" + id, nil
+}
+
+// CleanPath returns the content path p in the form matched by the tokens. The
+// root CID of an /ipfs/ path is normalized to the CIDv1 in base32 of its
+// multihash, and the peer ID of an /ipns/ path to its CIDv1 in base32, so that
+// a path matches whatever the encoding of the CID it is requested with, on the
+// path or the subdomain gateways. The other roots, such as DNSLink names, are
+// kept as they are.
+func CleanPath(p string) string {
+	p = pathpkg.Clean("/" + p)
+	parts := strings.SplitN(p, "/", 4)
+	if len(parts) < 3 {
+		return p
+	}
+	switch parts[1] {
+	case "ipfs":
+		if c, err := cid.Decode(parts[2]); err == nil {
+			parts[2] = cid.NewCidV1(cid.Raw, c.Hash()).String()
+		}
+	case "ipns":
+		if id, err := peer.Decode(parts[2]); err == nil {
+			parts[2] = peer.ToCid(id).String()
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Grants returns whether the path with grants the access to the path p: p is
+// with or one of its subpaths.
+func Grants(with, p string) bool {
+	with, p = CleanPath(with), CleanPath(p)
+	return p == with || with == "/" || strings.HasPrefix(p, with+"/")
+}
+
+// Issue returns a token signed with sk granting the read access to paths and
+// their subpaths until expiry.
+func Issue(sk crypto.PrivKey, paths []string, expiry time.Time) (string, error) {
+	iss, err := DID(sk.GetPublic())
+	if err != nil {
+		return "", err
+	}
+	if len(paths) == 0 {
+		return "", errors.New("no path to grant access to")
+	}
+	claims := Claims{Issuer: iss, Expiry: expiry.Unix()}
+	for _, p := range paths {
+		claims.Attenuations = append(claims.Attenuations, Attenuation{With: CleanPath(p), Can: Ability})
+	}
+
+	h, err := json.Marshal(header{Alg: "EdDSA", Typ: "JWT", Ucv: ucanVersion})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	sig, err := sk.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + b64.EncodeToString(sig), nil
+}
+
+// Verifier verifies the tokens signed by a key.
+type Verifier struct {
+	pk  crypto.PubKey
+	did string
+	now func() time.Time
+}
+
+// NewVerifier returns a verifier of the tokens signed with the private key of
+// pk.
+func NewVerifier(pk crypto.PubKey) (*Verifier, error) {
+	did, err := DID(pk)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{pk: pk, did: did, now: time.Now}, nil
+}
+
+// Parse verifies the signature and the validity period of a token, and
+// returns its claims.
+func (v *Verifier) Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	if ok, err := v.pk.Verify([]byte(parts[0]+"."+parts[1]), sig); err != nil || !ok {
+		return nil, ErrInvalid
+	}
+
+	var h header
+	if data, err := b64.DecodeString(parts[0]); err != nil || json.Unmarshal(data, &h) != nil || h.Alg != "EdDSA" {
+		return nil, ErrInvalid
+	}
+	var claims Claims
+	if data, err := b64.DecodeString(parts[1]); err != nil || json.Unmarshal(data, &claims) != nil {
+		return nil, ErrInvalid
+	}
+	if claims.Issuer != v.did {
+		return nil, ErrInvalid
+	}
+
+	now := v.now().Unix()
+	if now >= claims.Expiry || now < claims.NotBefore {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+// Verify returns nil if the token is valid and grants the read access to the
+// content path p.
+func (v *Verifier) Verify(token, p string) error {
+	claims, err := v.Parse(token)
+	if err != nil {
+		return err
+	}
+	for _, att := range claims.Attenuations {
+		if att.Can == Ability && Grants(att.With, p) {
+			return nil
+		}
+	}
+	return ErrNotGranted
+}
@@ -0,0 +1,149 @@
+package capability
+
+import (
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func genKey(t *testing.T) crypto.PrivKey {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk
+}
+
+func TestVerify(t *testing.T) {
+	sk := genKey(t)
+	v, err := NewVerifier(sk.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+
+	token, err := Issue(sk, []string{"/ipfs/bafydir/private/", "/ipns/example.com/a"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Issue(genKey(t), []string{"/ipfs/bafydir"}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := Issue(sk, []string{"/ipfs/bafydir"}, now.Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	tampered := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+
+	for _, c := range []struct {
+		token, path string
+		err         error
+	}{
+		{token, "/ipfs/bafydir/private", nil},
+		{token, "/ipfs/bafydir/private/file.txt", nil},
+		{token, "/ipns/example.com/a/b", nil},
+		{token, "/ipfs/bafydir/private/../public", ErrNotGranted},
+		{token, "/ipfs/bafydir/privately", ErrNotGranted},
+		{token, "/ipfs/bafydir", ErrNotGranted},
+		{other, "/ipfs/bafydir", ErrInvalid},
+		{expired, "/ipfs/bafydir", ErrExpired},
+		{tampered, "/ipfs/bafydir", ErrInvalid},
+		{"not a token", "/ipfs/bafydir", ErrInvalid},
+	} {
+		if err := v.Verify(c.token, c.path); !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", c.path, c.err, err)
+		}
+	}
+}
+
+func TestDID(t *testing.T) {
+	sk, _, err := crypto.GenerateRSAKeyPair(2048, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DID(sk.GetPublic()); err == nil {
+		t.Error("expected an error for an RSA key")
+	}
+
+	did, err := DID(genKey(t).GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the did:key of all the ed25519 keys start with z6Mk
+	if !strings.HasPrefix(did, "did:key:z6Mk") {
+		t.Errorf("unexpected did: %s", did)
+	}
+}
+
+func TestGrantsAcrossEncodings(t *testing.T) {
+	hash, err := mh.Sum([]byte("private"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v0 := cid.NewCidV0(hash)
+	v1 := cid.NewCidV1(cid.DagProtobuf, hash)
+	base36, err := v1.StringOfBase(multibase.Base36)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := cid.NewCidV1(cid.Raw, hash).String()
+	other, err := mh.Sum([]byte("public"), mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := peer.IDFromPrivateKey(genKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idBase36, err := peer.ToCid(id).StringOfBase(multibase.Base36)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		with, path string
+		granted    bool
+	}{
+		{"/ipfs/" + v0.String(), "/ipfs/" + v1.String() + "/a.txt", true},
+		{"/ipfs/" + v1.String(), "/ipfs/" + v0.String(), true},
+		{"/ipfs/" + v0.String() + "/a", "/ipfs/" + base36 + "/a/b.txt", true},
+		{"/ipfs/" + base36, "/ipfs/" + raw, true},
+		{"/ipfs/" + v0.String() + "/a", "/ipfs/" + v1.String() + "/b", false},
+		{"/ipfs/" + v0.String(), "/ipfs/" + cid.NewCidV0(other).String(), false},
+		{"/ipns/" + id.String(), "/ipns/" + peer.ToCid(id).String() + "/a", true},
+		{"/ipns/" + idBase36, "/ipns/" + id.String(), true},
+		{"/ipns/example.com", "/ipns/example.com/a", true},
+		{"/ipfs/bafynotacid", "/ipfs/bafynotacid/a", true},
+	} {
+		if granted := Grants(c.with, c.path); granted != c.granted {
+			t.Errorf("%s grants %s: expected %t, got %t", c.with, c.path, c.granted, granted)
+		}
+	}
+
+	// the tokens granting a CIDv0 are valid for the CIDv1 of the subdomain
+	// gateways
+	sk := genKey(t)
+	v, err := NewVerifier(sk.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := Issue(sk, []string{"/ipfs/" + v0.String()}, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(token, "/ipfs/"+v1.String()+"/a.txt"); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,22 +1,35 @@
 package commands
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"strings"
+	"time"
 
+	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreunix"
+	"github.com/ipfs/kubo/core/encryption"
 
 	"github.com/cheggaaa/pb"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	keystore "github.com/ipfs/go-ipfs-keystore"
 	ipld "github.com/ipfs/go-ipld-format"
 	"github.com/ipfs/go-libipfs/files"
 	mfs "github.com/ipfs/go-mfs"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
+	ipath "github.com/ipfs/interface-go-ipfs-core/path"
+	car "github.com/ipld/go-car"
+	"github.com/libp2p/go-libp2p/core/crypto"
 	mh "github.com/multiformats/go-multihash"
 )
 
@@ -28,6 +41,52 @@ type AddEvent struct {
 	Hash  string `json:",omitempty"`
 	Bytes int64  `json:",omitempty"`
 	Size  string `json:",omitempty"`
+
+	// set with --progress-events
+	Event      string `json:",omitempty"` // "progress" or "added"
+	TotalBytes int64  `json:",omitempty"` // hashed over all the files
+	TotalSize  int64  `json:",omitempty"` // of all the files, if known
+	ETA        int64  `json:",omitempty"` // in seconds, if the total size is known
+
+	// Car is a chunk of the CAR of --output-car, sent after the other events
+	Car []byte `json:",omitempty"`
+}
+
+const (
+	addEventProgress = "progress"
+	addEventAdded    = "added"
+)
+
+// addProgress tracks the bytes hashed over all the files of an add, to
+// annotate the events streamed with --progress-events.
+type addProgress struct {
+	start     time.Time
+	totalSize int64
+
+	lastFile  string
+	lastBytes int64
+	prevFiles int64
+}
+
+func (p *addProgress) annotate(ev *AddEvent) {
+	if ev.Hash != "" {
+		ev.Event = addEventAdded
+	} else {
+		ev.Event = addEventProgress
+		if ev.Name != p.lastFile || ev.Bytes < p.lastBytes {
+			p.prevFiles += p.lastBytes
+			p.lastFile = ev.Name
+		}
+		p.lastBytes = ev.Bytes
+	}
+
+	ev.TotalBytes = p.prevFiles + p.lastBytes
+	ev.TotalSize = p.totalSize
+	if ev.TotalBytes > 0 && p.totalSize > ev.TotalBytes {
+		elapsed := time.Since(p.start)
+		remaining := time.Duration(float64(elapsed) * float64(p.totalSize-ev.TotalBytes) / float64(ev.TotalBytes))
+		ev.ETA = int64(remaining.Round(time.Second) / time.Second)
+	}
 }
 
 const (
@@ -48,6 +107,20 @@ const (
 	inlineOptionName      = "inline"
 	inlineLimitOptionName = "inline-limit"
 	toFilesOptionName     = "to-files"
+	cidProfileOptionName  = "cid-profile"
+	encryptOptionName     = "encrypt"
+)
+
+// options of 'ipfs add --progress-events'
+const (
+	progressEventsOptionName = "progress-events"
+	totalSizeOptionName      = "total-size"
+)
+
+// options of 'ipfs add --output-car'
+const (
+	outputCarOptionName    = "output-car"
+	noBlockstoreOptionName = "no-blockstore"
 )
 
 const adderOutChanSize = 8
@@ -96,6 +169,10 @@ to find it in the future:
 See 'ipfs files --help' to learn more about using MFS
 for keeping track of added files and directories.
 
+With '--space', the files are pinned in a space instead of the pinset of the
+node, and '--to-files' refers to the MFS root of the space. See
+'ipfs space --help'.
+
 The chunker option, '-s', specifies the chunking strategy that dictates
 how to break files into blocks. Blocks with same content can
 be deduplicated. Different chunking strategies will produce different
@@ -131,9 +208,60 @@ new flags may be added in the future. It is not guaranteed for the implicit
 defaults of 'ipfs add' to remain the same in future Kubo releases, or for other
 IPFS software to use the same import parameters as Kubo.
 
+To get identical CIDs for identical files across teams and Kubo versions,
+use '--cid-profile' to pin down every parameter affecting CIDs. Options
+explicitly set must agree with the profile. Available profiles:
+
+  unixfs-v0-2015  size-262144 chunks, balanced DAG, CIDv0, sha2-256, no raw leaves
+  unixfs-v1-2023  size-1048576 chunks, balanced DAG, CIDv1, sha2-256, raw leaves
+
+  > ipfs add --cid-profile=unixfs-v1-2023 example.jpg
+
 If you need to back up or transport content-addressed data using a non-IPFS
 medium, CID can be preserved with CAR files.
 See 'dag export' and 'dag import' for more information.
+
+Passing '--output-car' also writes the added DAGs to a CAR file, with one
+root per added argument. Combined with '--no-blockstore', the data is only
+chunked and hashed, and the blocks are written to the CAR without being
+stored in the repo, for example to upload the CAR to a storage provider:
+
+  > ipfs add --output-car=example.car --no-blockstore example.jpg
+  added QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH example.jpg
+
+The CAR is sent to the client with the output of the command, and written to
+the path by the 'ipfs' CLI, also when a daemon is running. Over the RPC API,
+it is sent after the other events, in the base64-encoded Car field of the
+last ones.
+
+Passing '--progress-events' replaces the progress bar with one JSON object
+per line, for upload UIs showing the progress of recursive adds. Progress
+events report the path of the file being hashed, its bytes hashed so far, the
+bytes hashed over all the files and, when the total size is known, an
+estimate of the seconds left. Added events carry the CID of each file and
+directory as soon as it is added:
+
+  > ipfs add -r --progress-events photos
+  {"Name":"photos/a.jpg","Bytes":262144,"Event":"progress","TotalBytes":262144,"TotalSize":1048576,"ETA":3}
+  {"Name":"photos/a.jpg","Hash":"Qm...","Size":"524302","Event":"added","TotalBytes":524288,"TotalSize":1048576,"ETA":2}
+
+RPC clients can pass '--total-size' to get the estimate of the time left.
+
+Passing '--encrypt' encrypts the content of the files with a key of the
+keystore before adding them, so that private data can be stored and provided
+by public nodes. The DAGs are regular UnixFS DAGs of the ciphertext; the names
+of the files and directories are not encrypted. Use a dedicated key, and keep
+a copy of it with 'ipfs key export': the files can not be decrypted without
+it.
+
+  > ipfs key gen backup
+  > ipfs add --encrypt=backup secret.txt
+  added QmXa... secret.txt
+  > ipfs cat --decrypt=backup QmXa...
+
+The files are encrypted in the age format, to a passphrase derived from the
+keystore key: the content is sealed with ChaCha20-Poly1305 in chunks of 64KiB,
+with a random key for each file.
 `,
 	},
 
@@ -151,19 +279,26 @@ See 'dag export' and 'dag import' for more information.
 		cmds.BoolOption(quieterOptionName, "Q", "Write only final hash."),
 		cmds.BoolOption(silentOptionName, "Write no output."),
 		cmds.BoolOption(progressOptionName, "p", "Stream progress data."),
+		cmds.BoolOption(progressEventsOptionName, "Stream progress as newline-delimited JSON events, with the CID of each file once added and an estimate of the time left. Implies --progress."),
+		cmds.Int64Option(totalSizeOptionName, "Total size of the files in bytes, used to estimate the time left with --progress-events. Computed by the CLI."),
 		cmds.BoolOption(trickleOptionName, "t", "Use trickle-dag format for dag generation."),
 		cmds.BoolOption(onlyHashOptionName, "n", "Only chunk and hash - do not write to disk."),
 		cmds.BoolOption(wrapOptionName, "w", "Wrap files with a directory object."),
-		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max] or buzhash").WithDefault("size-262144"),
+		cmds.StringOption(chunkerOptionName, "s", "Chunking algorithm, size-[bytes], rabin-[min]-[avg]-[max] or buzhash. Default: "+defaultAddChunker+"."),
 		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes."),
 		cmds.BoolOption(noCopyOptionName, "Add the file using filestore. Implies raw-leaves. (experimental)"),
 		cmds.BoolOption(fstoreCacheOptionName, "Check the filestore for pre-existing blocks. (experimental)"),
 		cmds.IntOption(cidVersionOptionName, "CID version. Defaults to 0 unless an option that depends on CIDv1 is passed. Passing version 1 will cause the raw-leaves option to default to true."),
-		cmds.StringOption(hashOptionName, "Hash function to use. Implies CIDv1 if not sha2-256. (experimental)").WithDefault("sha2-256"),
+		cmds.StringOption(hashOptionName, "Hash function to use, e.g. sha2-256 or blake3. Implies CIDv1 if not sha2-256. (experimental) Default: "+defaultAddHash+"."),
 		cmds.BoolOption(inlineOptionName, "Inline small blocks into CIDs. (experimental)"),
 		cmds.IntOption(inlineLimitOptionName, "Maximum block size to inline. (experimental)").WithDefault(32),
 		cmds.BoolOption(pinOptionName, "Pin locally to protect added files from garbage collection.").WithDefault(true),
 		cmds.StringOption(toFilesOptionName, "Add reference to Files API (MFS) at the provided path."),
+		cmds.StringOption(cidProfileOptionName, "Use the chunker, DAG layout, CID version, hash function and raw leaves setting of a named profile, for reproducible CIDs."),
+		cmds.StringOption(encryptOptionName, "Encrypt the content of the files with the named key of the keystore before adding them."),
+		cmds.StringOption(outputCarOptionName, "Write the added DAGs to a CAR file at the provided path."),
+		cmds.BoolOption(noBlockstoreOptionName, "Do not store blocks in the repo, only write them to --output-car. Implies --pin=false."),
+		cmdenv.OptionSpace,
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
 		quiet, _ := req.Options[quietOptionName].(bool)
@@ -172,6 +307,19 @@ See 'dag export' and 'dag import' for more information.
 
 		silent, _ := req.Options[silentOptionName].(bool)
 
+		if progressEvents, _ := req.Options[progressEventsOptionName].(bool); progressEvents {
+			req.Options[progressOptionName] = true
+			if _, found := req.Options[totalSizeOptionName].(int64); !found {
+				// the files are local, tell the daemon their size for the ETA
+				if size, err := req.Files.Size(); err == nil {
+					req.Options[totalSizeOptionName] = size
+				} else {
+					log.Warnf("error getting files size: %s", err)
+				}
+			}
+			return nil
+		}
+
 		if quiet || silent {
 			return nil
 		}
@@ -190,6 +338,12 @@ See 'dag export' and 'dag import' for more information.
 			return err
 		}
 
+		if profile, ok := req.Options[cidProfileOptionName].(string); ok {
+			if err := applyCidProfile(profile, req.Options); err != nil {
+				return err
+			}
+		}
+
 		progress, _ := req.Options[progressOptionName].(bool)
 		trickle, _ := req.Options[trickleOptionName].(bool)
 		wrap, _ := req.Options[wrapOptionName].(bool)
@@ -205,6 +359,40 @@ See 'dag export' and 'dag import' for more information.
 		inline, _ := req.Options[inlineOptionName].(bool)
 		inlineLimit, _ := req.Options[inlineLimitOptionName].(int)
 		toFilesStr, toFilesSet := req.Options[toFilesOptionName].(string)
+		progressEvents, _ := req.Options[progressEventsOptionName].(bool)
+		totalSize, _ := req.Options[totalSizeOptionName].(int64)
+		outputCar, _ := req.Options[outputCarOptionName].(string)
+		noBlockstore, _ := req.Options[noBlockstoreOptionName].(bool)
+		encryptKey, _ := req.Options[encryptOptionName].(string)
+
+		if encryptKey != "" && nocopy {
+			// the filestore would reference the plaintext files
+			return fmt.Errorf("%s can not be used with %s", encryptOptionName, noCopyOptionName)
+		}
+
+		if noBlockstore {
+			switch {
+			case outputCar == "":
+				return fmt.Errorf("%s requires %s", noBlockstoreOptionName, outputCarOptionName)
+			case hash:
+				return fmt.Errorf("%s can not be used with %s", noBlockstoreOptionName, onlyHashOptionName)
+			case nocopy:
+				return fmt.Errorf("%s can not be used with %s", noBlockstoreOptionName, noCopyOptionName)
+			case toFilesSet:
+				return fmt.Errorf("%s can not be used with %s", noBlockstoreOptionName, toFilesOptionName)
+			}
+			// nothing to pin, the blocks are not stored
+			dopin = false
+		} else if outputCar != "" && hash {
+			return fmt.Errorf("%s can not be used with %s, use %s", outputCarOptionName, onlyHashOptionName, noBlockstoreOptionName)
+		}
+
+		if chunker == "" {
+			chunker = defaultAddChunker
+		}
+		if hashFunStr == "" {
+			hashFunStr = defaultAddHash
+		}
 
 		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
 		if !ok {
@@ -223,6 +411,26 @@ See 'dag export' and 'dag import' for more information.
 			})
 		}
 
+		ipfsNode, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		filesRoot, err := getFilesRoot(req, ipfsNode)
+		if err != nil {
+			return err
+		}
+
+		// the roots are pinned in the space once added
+		space := cmdenv.GetSpace(req)
+		spacePin := space != "" && dopin && !hash
+		if spacePin {
+			if err := ipfsNode.Spaces.Check(req.Context, space); err != nil {
+				return err
+			}
+			dopin = false
+		}
+
 		opts := []options.UnixfsAddOption{
 			options.Unixfs.Hash(hashFunCode),
 
@@ -254,10 +462,29 @@ See 'dag export' and 'dag import' for more information.
 
 		opts = append(opts, nil) // events option placeholder
 
-		ipfsNode, err := cmdenv.GetNode(env)
-		if err != nil {
-			return err
+		if encryptKey != "" {
+			sk, err := encryptionKey(ipfsNode, encryptKey)
+			if err != nil {
+				return err
+			}
+			toadd = encryption.EncryptNode(toadd, sk).(files.Directory)
 		}
+
+		var carWriter *coreunix.CarWriter
+		if noBlockstore {
+			carWriter, err = coreunix.NewCarWriter()
+			if err != nil {
+				return err
+			}
+			defer carWriter.Close()
+		}
+
+		var progressTracker *addProgress
+		if progressEvents {
+			progressTracker = &addProgress{start: time.Now(), totalSize: totalSize}
+		}
+
+		var roots []cid.Cid
 		var added int
 		var fileAddedToMFS bool
 		addit := toadd.Entries()
@@ -270,11 +497,24 @@ See 'dag export' and 'dag import' for more information.
 			go func() {
 				var err error
 				defer close(events)
-				pathAdded, err := api.Unixfs().Add(req.Context, addit.Node(), opts...)
+				var pathAdded ipath.Resolved
+				if carWriter != nil {
+					pathAdded, err = addToCar(req.Context, carWriter, addit.Node(), opts...)
+				} else {
+					pathAdded, err = api.Unixfs().Add(req.Context, addit.Node(), opts...)
+				}
 				if err != nil {
 					errCh <- err
 					return
 				}
+				roots = append(roots, pathAdded.Cid())
+
+				if spacePin {
+					if err := ipfsNode.Spaces.Pin(req.Context, space, pathAdded.Cid(), true); err != nil {
+						errCh <- err
+						return
+					}
+				}
 
 				// creating MFS pointers when optional --to-files is set
 				if toFilesSet {
@@ -289,7 +529,7 @@ See 'dag export' and 'dag import' for more information.
 					dstAsDir := toFilesDst[len(toFilesDst)-1] == '/'
 
 					if dstAsDir {
-						mfsNode, err := mfs.Lookup(ipfsNode.FilesRoot, toFilesDst)
+						mfsNode, err := mfs.Lookup(filesRoot, toFilesDst)
 						// confirm dst exists
 						if err != nil {
 							errCh <- fmt.Errorf("%s: MFS destination directory %q does not exist: %w", toFilesOptionName, toFilesDst, err)
@@ -310,7 +550,7 @@ See 'dag export' and 'dag import' for more information.
 						return
 					}
 
-					_, err = mfs.Lookup(ipfsNode.FilesRoot, path.Dir(toFilesDst))
+					_, err = mfs.Lookup(filesRoot, path.Dir(toFilesDst))
 					if err != nil {
 						errCh <- fmt.Errorf("%s: MFS destination parent %q %q does not exist: %w", toFilesOptionName, toFilesDst, path.Dir(toFilesDst), err)
 						return
@@ -322,7 +562,7 @@ See 'dag export' and 'dag import' for more information.
 						errCh <- err
 						return
 					}
-					err = mfs.PutNode(ipfsNode.FilesRoot, toFilesDst, nodeAdded)
+					err = mfs.PutNode(filesRoot, toFilesDst, nodeAdded)
 					if err != nil {
 						errCh <- fmt.Errorf("%s: cannot put node in path %q: %w", toFilesOptionName, toFilesDst, err)
 						return
@@ -349,12 +589,16 @@ See 'dag export' and 'dag import' for more information.
 					output.Name = path.Join(addit.Name(), output.Name)
 				}
 
-				if err := res.Emit(&AddEvent{
+				ev := &AddEvent{
 					Name:  output.Name,
 					Hash:  h,
 					Bytes: output.Bytes,
 					Size:  output.Size,
-				}); err != nil {
+				}
+				if progressTracker != nil {
+					progressTracker.annotate(ev)
+				}
+				if err := res.Emit(ev); err != nil {
 					return err
 				}
 			}
@@ -373,10 +617,23 @@ See 'dag export' and 'dag import' for more information.
 			return fmt.Errorf("expected a file argument")
 		}
 
-		return nil
+		if outputCar == "" {
+			return nil
+		}
+		// the CAR is sent to the client, which writes it to --output-car
+		out := bufio.NewWriterSize(&carEmitter{res: res}, carChunkSize)
+		if carWriter != nil {
+			err = carWriter.Finalize(req.Context, roots, out)
+		} else {
+			err = car.WriteCar(req.Context, api.Dag(), roots, out)
+		}
+		if err != nil {
+			return err
+		}
+		return out.Flush()
 	},
 	PostRun: cmds.PostRunMap{
-		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) (err error) {
 			sizeChan := make(chan int64, 1)
 			outChan := make(chan interface{})
 			req := res.Request()
@@ -402,6 +659,23 @@ See 'dag export' and 'dag import' for more information.
 
 				progress, _ := req.Options[progressOptionName].(bool)
 
+				if progressEvents, _ := req.Options[progressEventsOptionName].(bool); progressEvents {
+					enc := json.NewEncoder(os.Stdout)
+					for {
+						select {
+						case out, ok := <-outChan:
+							if !ok {
+								return
+							}
+							if err := enc.Encode(out); err != nil {
+								log.Errorf("writing progress event: %s", err)
+							}
+						case <-req.Context.Done():
+							return
+						}
+					}
+				}
+
 				var bar *pb.ProgressBar
 				if progress {
 					bar = pb.New64(0).SetUnits(pb.U_BYTES)
@@ -491,6 +765,23 @@ See 'dag export' and 'dag import' for more information.
 				return e
 			}
 
+			var carFile *os.File
+			if outputCar, _ := req.Options[outputCarOptionName].(string); outputCar != "" {
+				carFile, err = os.Create(outputCar)
+				if err != nil {
+					close(outChan)
+					return err
+				}
+				defer func() {
+					if cerr := carFile.Close(); err == nil {
+						err = cerr
+					}
+					if err != nil {
+						os.Remove(outputCar)
+					}
+				}()
+			}
+
 			wait := make(chan struct{})
 			go progressBar(wait)
 
@@ -507,6 +798,16 @@ See 'dag export' and 'dag import' for more information.
 					return err
 				}
 
+				if ev, ok := v.(*AddEvent); ok && ev.Car != nil {
+					if carFile == nil {
+						return fmt.Errorf("unexpected CAR without %s", outputCarOptionName)
+					}
+					if _, err := carFile.Write(ev.Car); err != nil {
+						return err
+					}
+					continue
+				}
+
 				select {
 				case outChan <- v:
 				case <-req.Context.Done():
@@ -517,3 +818,57 @@ See 'dag export' and 'dag import' for more information.
 	},
 	Type: AddEvent{},
 }
+
+// encryptionKey returns the key of the keystore used by --encrypt and
+// --decrypt.
+func encryptionKey(n *core.IpfsNode, name string) (crypto.PrivKey, error) {
+	if name == "self" {
+		return nil, errors.New("the 'self' key can not be used for encryption, generate a dedicated key with 'ipfs key gen'")
+	}
+	sk, err := n.Repo.Keystore().Get(name)
+	if err != nil {
+		if err == keystore.ErrNoSuchKey {
+			return nil, fmt.Errorf("key with name '%s' doesn't exist", name)
+		}
+		return nil, err
+	}
+	return sk, nil
+}
+
+// addToCar adds the files to the CAR writer instead of the repo.
+func addToCar(ctx context.Context, w *coreunix.CarWriter, node files.Node, opts ...options.UnixfsAddOption) (ipath.Resolved, error) {
+	settings, prefix, err := options.UnixfsAddOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+	adder, err := coreunix.NewAdder(ctx, nil, blockstore.NewGCLocker(), w)
+	if err != nil {
+		return nil, err
+	}
+	if err := adder.Configure(settings, prefix); err != nil {
+		return nil, err
+	}
+	nd, err := adder.AddAllAndPin(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	return ipath.IpfsPath(nd.Cid()), nil
+}
+
+// carChunkSize is the size of the chunks of the CAR of --output-car.
+const carChunkSize = 256 << 10
+
+// carEmitter sends the CAR written to it to the client, in the Car field of
+// the events.
+type carEmitter struct {
+	res cmds.ResponseEmitter
+}
+
+func (e *carEmitter) Write(b []byte) (int, error) {
+	chunk := make([]byte, len(b))
+	copy(chunk, b)
+	if err := e.res.Emit(&AddEvent{Car: chunk}); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
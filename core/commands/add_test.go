@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestCarEmitter(t *testing.T) {
+	req := &cmds.Request{Context: context.Background()}
+	re, res := cmds.NewChanResponsePair(req)
+
+	data := bytes.Repeat([]byte("car section "), carChunkSize/4)
+	go func() {
+		out := bufio.NewWriterSize(&carEmitter{res: re}, carChunkSize)
+		for i := 0; i < len(data); i += 1000 {
+			end := i + 1000
+			if end > len(data) {
+				end = len(data)
+			}
+			if _, err := out.Write(data[i:end]); err != nil {
+				re.CloseWithError(err)
+				return
+			}
+		}
+		re.CloseWithError(out.Flush())
+	}()
+
+	var got []byte
+	var chunks int
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ev, ok := v.(*AddEvent)
+		if !ok || ev.Car == nil || ev.Hash != "" {
+			t.Fatalf("expected a CAR chunk, got %#v", v)
+		}
+		if len(ev.Car) > carChunkSize {
+			t.Fatalf("expected chunks of at most %d bytes, got %d", carChunkSize, len(ev.Car))
+		}
+		got = append(got, ev.Car...)
+		chunks++
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("the CAR chunks don't add up to the CAR written")
+	}
+	if chunks != 3 {
+		t.Fatalf("expected 3 chunks, got %d", chunks)
+	}
+}
+
+func TestAddProgress(t *testing.T) {
+	p := &addProgress{start: time.Now().Add(-2 * time.Second), totalSize: 300}
+
+	for _, tc := range []struct {
+		in         AddEvent
+		event      string
+		totalBytes int64
+		eta        int64
+	}{
+		{AddEvent{Name: "dir/a", Bytes: 50}, addEventProgress, 50, 10},
+		{AddEvent{Name: "dir/a", Bytes: 100}, addEventProgress, 100, 4},
+		{AddEvent{Name: "dir/a", Hash: "QmA", Size: "111"}, addEventAdded, 100, 4},
+		{AddEvent{Name: "dir/b", Bytes: 150}, addEventProgress, 250, 0},
+		{AddEvent{Name: "dir/b", Bytes: 200}, addEventProgress, 300, 0},
+		{AddEvent{Name: "dir", Hash: "QmDir", Size: "400"}, addEventAdded, 300, 0},
+	} {
+		ev := tc.in
+		p.annotate(&ev)
+		if ev.Event != tc.event || ev.TotalBytes != tc.totalBytes || ev.TotalSize != 300 {
+			t.Fatalf("%+v: expected a %s event with %d bytes out of 300, got %+v", tc.in, tc.event, tc.totalBytes, ev)
+		}
+		// the estimate only depends on the elapsed time, which grows with
+		// the test
+		if ev.ETA > tc.eta || (tc.eta > 0 && ev.ETA < tc.eta-1) {
+			t.Fatalf("%+v: expected an ETA of %ds, got %ds", tc.in, tc.eta, ev.ETA)
+		}
+	}
+
+	// without the total size, the time left isn't known
+	p = &addProgress{start: time.Now()}
+	ev := AddEvent{Name: "a", Bytes: 10}
+	p.annotate(&ev)
+	if ev.TotalBytes != 10 || ev.TotalSize != 0 || ev.ETA != 0 {
+		t.Fatalf("expected no estimate, got %+v", ev)
+	}
+}
@@ -0,0 +1,390 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-libipfs/files"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/adminbatch"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+const (
+	adminSignKeyOptionName = "key"
+	adminSignTTLOptionName = "ttl"
+)
+
+// adminBatches runs the batches of 'ipfs admin exec' one at a time, and
+// refuses their replays.
+var adminBatches = adminbatch.NewGuard()
+
+var AdminCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage nodes remotely with signed batches of operations.",
+		ShortDescription: `
+'ipfs admin' signs and runs batches of operations, with which a fleet of nodes
+is managed through their RPC API rather than on each host.
+`,
+		LongDescription: `
+'ipfs admin' signs and runs batches of operations, with which a fleet of nodes
+is managed through their RPC API rather than on each host.
+
+A batch lists operations of the following types:
+
+  pin/add      pins Path, recursively unless Direct is true
+  pin/rm       unpins Path
+  config/set   sets the config Key to the JSON Value, for the keys which are
+               safe to change remotely, e.g. Gateway.RateLimit or
+               Swarm.ConnMgr, but not Gateway.Writable or Routing
+  peering/add  adds Peer at Addrs to the peering subsystem
+  peering/rm   removes Peer from the peering subsystem
+
+For example:
+
+  {
+    "Ops": [
+      {"Type": "pin/add", "Path": "/ipfs/bafy..."},
+      {"Type": "config/set", "Key": "Swarm.ConnMgr.HighWater", "Value": 400},
+      {"Type": "peering/add", "Peer": "12D3KooW...", "Addrs": ["/dns4/..."]}
+    ]
+  }
+
+A node only runs the batches signed by a key listed in
+API.Admin.AuthorizedKeys, and logs them to API.Admin.AuditLog. The operations
+of a batch are applied atomically: when one fails, those already applied are
+undone.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"sign": adminSignCmd,
+		"exec": adminExecCmd,
+	},
+}
+
+var adminSignCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Sign a batch of operations.",
+		ShortDescription: `
+Signs the batch of operations read from the file, see 'ipfs admin --help', to
+be run by the nodes authorizing the key with 'ipfs admin exec'. The signed
+batch is valid for --ttl, and is run once by each node.
+
+Example:
+  $ ipfs admin sign --key=fleet ops.json > batch.json
+  $ ipfs --api=/dns4/node-1/tcp/5001 admin exec batch.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("batch", true, false, "The operations to sign.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(adminSignKeyOptionName, "k", "Name of the key signing the batch, as listed by 'ipfs key list'.").WithDefault("self"),
+		cmds.StringOption(adminSignTTLOptionName, "How long the batch is valid for.").WithDefault("1h"),
+	},
+	Type: adminbatch.Batch{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		ttlStr, _ := req.Options[adminSignTTLOptionName].(string)
+		ttl, err := time.ParseDuration(ttlStr)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", adminSignTTLOptionName, err)
+		}
+		if ttl <= 0 || ttl > adminbatch.MaxTTL {
+			return fmt.Errorf("--%s must be positive and at most %s", adminSignTTLOptionName, adminbatch.MaxTTL)
+		}
+
+		file, err := adminBatchFile(req)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		var unsigned struct{ Ops []adminbatch.Op }
+		if err := json.NewDecoder(file).Decode(&unsigned); err != nil {
+			return fmt.Errorf("invalid admin batch: %w", err)
+		}
+
+		b := adminbatch.New(unsigned.Ops, ttl)
+		keyName, _ := req.Options[adminSignKeyOptionName].(string)
+		k := n.PrivateKey
+		if keyName != "" && keyName != "self" {
+			if k, err = n.Repo.Keystore().Get(keyName); err != nil {
+				return err
+			}
+		}
+		if err := b.Sign(k); err != nil {
+			return err
+		}
+		// catch the invalid operations before the batch is sent to the nodes
+		buf, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		if _, err := adminbatch.Read(bytes.NewReader(buf)); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, b)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, b *adminbatch.Batch) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(b)
+		}),
+	},
+}
+
+// AdminExecOutput is the outcome of a batch run by 'ipfs admin exec'.
+type AdminExecOutput struct {
+	Batch   string
+	Signer  string
+	Applied int
+}
+
+var adminExecCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Run a signed batch of operations.",
+		ShortDescription: `
+Runs a batch of operations signed with 'ipfs admin sign' by a key listed in
+API.Admin.AuthorizedKeys. The operations are applied atomically: when one
+fails, those already applied are undone. Each batch is run once, and logged to
+API.Admin.AuditLog whether it's run or refused.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("batch", true, false, "The signed batch to run.").EnableStdin(),
+	},
+	Type: AdminExecOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		var admin config.Admin
+		if cfg.API.Admin != nil {
+			admin = *cfg.API.Admin
+		}
+		if len(admin.AuthorizedKeys) == 0 {
+			return errors.New("admin batches are disabled, no key is listed in API.Admin.AuthorizedKeys")
+		}
+		auditPath := admin.AuditLog.WithDefault(config.DefaultAdminAuditLog)
+		if !filepath.IsAbs(auditPath) {
+			auditPath = filepath.Join(cfgRoot, auditPath)
+		}
+		audit := adminbatch.NewAuditLog(auditPath)
+
+		file, err := adminBatchFile(req)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		b, err := adminbatch.Read(file)
+		if err != nil {
+			return err
+		}
+
+		id, err := b.ID()
+		if err != nil {
+			return err
+		}
+		entry := adminbatch.AuditEntry{Time: time.Now(), Batch: id, Signer: b.Signer, Ops: b.Ops}
+		record := func(outcome string, err error) error {
+			entry.Outcome = outcome
+			if err != nil {
+				entry.Error = err.Error()
+			}
+			if aerr := audit.Record(entry); aerr != nil {
+				log.Errorf("failed to write the admin audit log: %s", aerr)
+			}
+			return err
+		}
+
+		signer, err := b.Verify(time.Now())
+		if err != nil {
+			return record(adminbatch.OutcomeRejected, err)
+		}
+		if !adminAuthorized(admin.AuthorizedKeys, signer) {
+			return record(adminbatch.OutcomeRejected, fmt.Errorf("%s is not listed in API.Admin.AuthorizedKeys", signer))
+		}
+		for _, op := range b.Ops {
+			if (op.Type == adminbatch.PeeringAdd || op.Type == adminbatch.PeeringRm) && n.Peering == nil {
+				return record(adminbatch.OutcomeRejected, errors.New("the peering operations require a running daemon"))
+			}
+		}
+
+		err = adminBatches.Run(req.Context, n.Repo.Datastore(), b, func(ctx context.Context, op adminbatch.Op) (adminbatch.Undo, error) {
+			return applyAdminOp(ctx, n, api, op)
+		})
+		switch {
+		case errors.Is(err, adminbatch.ErrReplayed):
+			return record(adminbatch.OutcomeRejected, err)
+		case err != nil:
+			return record(adminbatch.OutcomeFailed, err)
+		}
+		if err := record(adminbatch.OutcomeApplied, nil); err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &AdminExecOutput{Batch: id, Signer: b.Signer, Applied: len(b.Ops)})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *AdminExecOutput) error {
+			_, err := fmt.Fprintf(w, "applied the %d operations of batch %s signed by %s\n", out.Applied, out.Batch, out.Signer)
+			return err
+		}),
+	},
+}
+
+// applyAdminOp applies op to n, and returns how to undo it.
+func applyAdminOp(ctx context.Context, n *core.IpfsNode, api coreiface.CoreAPI, op adminbatch.Op) (adminbatch.Undo, error) {
+	switch op.Type {
+	case adminbatch.PinAdd, adminbatch.PinRm:
+		rp, err := api.ResolvePath(ctx, path.New(op.Path))
+		if err != nil {
+			return nil, err
+		}
+		mode, pinned, err := n.Pinning.IsPinned(ctx, rp.Cid())
+		if err != nil {
+			return nil, err
+		}
+		wasRecursive := mode == "recursive"
+		if op.Type == adminbatch.PinRm {
+			if !pinned {
+				return nil, fmt.Errorf("%s is not pinned", op.Path)
+			}
+			if err := api.Pin().Rm(ctx, rp, options.Pin.RmRecursive(wasRecursive)); err != nil {
+				return nil, err
+			}
+			return func() error {
+				return api.Pin().Add(context.Background(), rp, options.Pin.Recursive(wasRecursive))
+			}, nil
+		}
+		if wasRecursive || (op.Direct && mode == "direct") {
+			return nil, nil
+		}
+		if err := api.Pin().Add(ctx, rp, options.Pin.Recursive(!op.Direct)); err != nil {
+			return nil, err
+		}
+		return func() error {
+			if err := api.Pin().Rm(context.Background(), rp, options.Pin.RmRecursive(!op.Direct)); err != nil {
+				return err
+			}
+			// pinning recursively replaces the direct pin
+			if mode == "direct" {
+				return api.Pin().Add(context.Background(), rp, options.Pin.Recursive(false))
+			}
+			return nil
+		}, nil
+
+	case adminbatch.ConfigSet:
+		old, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		if old, err = old.Clone(); err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+		if err := n.Repo.SetConfigKey(op.Key, value); err != nil {
+			return nil, fmt.Errorf("failed to set %s: %w", op.Key, err)
+		}
+		return func() error {
+			return n.Repo.SetConfig(old)
+		}, nil
+
+	case adminbatch.PeeringAdd, adminbatch.PeeringRm:
+		id, err := peer.Decode(op.Peer)
+		if err != nil {
+			return nil, err
+		}
+		var prev *peer.AddrInfo
+		for _, ai := range n.Peering.ListPeers() {
+			if ai.ID == id {
+				ai := ai
+				prev = &ai
+			}
+		}
+		restore := func() error {
+			if prev == nil {
+				n.Peering.RemovePeer(id)
+			} else {
+				n.Peering.AddPeer(*prev)
+			}
+			return nil
+		}
+		if op.Type == adminbatch.PeeringRm {
+			if prev == nil {
+				return nil, nil
+			}
+			n.Peering.RemovePeer(id)
+			return restore, nil
+		}
+		info := peer.AddrInfo{ID: id}
+		for _, a := range op.Addrs {
+			addr, err := ma.NewMultiaddr(a)
+			if err != nil {
+				return nil, err
+			}
+			info.Addrs = append(info.Addrs, addr)
+		}
+		n.Peering.AddPeer(info)
+		return restore, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Type)
+}
+
+func adminAuthorized(keys []string, signer peer.ID) bool {
+	for _, k := range keys {
+		if id, err := peer.Decode(k); err == nil && id == signer {
+			return true
+		}
+	}
+	return false
+}
+
+func adminBatchFile(req *cmds.Request) (files.File, error) {
+	it := req.Files.Entries()
+	if !it.Next() {
+		if it.Err() != nil {
+			return nil, it.Err()
+		}
+		return nil, errors.New("missing batch")
+	}
+	file := files.FileFromEntry(it)
+	if file == nil {
+		return nil, errors.New("expected a file")
+	}
+	return file, nil
+}
@@ -26,6 +26,7 @@ var BitswapCmd = &cmds.Command{
 		"wantlist":  showWantlistCmd,
 		"ledger":    ledgerCmd,
 		"reprovide": reprovideCmd,
+		"scores":    bitswapScoresCmd,
 	},
 }
 
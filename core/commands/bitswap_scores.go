@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// BitswapPeerScore is the score of a peer in 'ipfs bitswap scores'.
+type BitswapPeerScore struct {
+	Peer         string
+	WantHaves    uint64
+	WantBlocks   uint64
+	Dropped      uint64
+	Haves        uint64
+	DontHaves    uint64
+	Blocks       uint64
+	HitRate      float64
+	Unresponsive bool
+}
+
+// BitswapScores is the output of 'ipfs bitswap scores'.
+type BitswapScores struct {
+	Peers []BitswapPeerScore
+}
+
+var bitswapScoresCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show how the connected peers answer bitswap requests.",
+		ShortDescription: `
+'ipfs bitswap scores' lists, for each connected peer, the bitswap requests sent
+to it and its answers, sorted by hit rate: the ratio of the requests answered
+with a HAVE or a block.
+
+WANT-HAVE requests are broadcast to the connected peers when looking for a
+block. Internal.Bitswap.BroadcastFanOut limits how many peers receive them,
+and Internal.Bitswap.UnresponsivePeerThreshold marks the peers that never
+answer as unresponsive: they only receive one request in 16. The requests not
+sent are counted as dropped.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.BitswapBroadcast == nil {
+			return fmt.Errorf("bitswap is not running")
+		}
+
+		out := BitswapScores{Peers: []BitswapPeerScore{}}
+		for p, s := range nd.BitswapBroadcast.Peers() {
+			out.Peers = append(out.Peers, BitswapPeerScore{
+				Peer:         p.String(),
+				WantHaves:    s.WantHaves,
+				WantBlocks:   s.WantBlocks,
+				Dropped:      s.Dropped,
+				Haves:        s.Haves,
+				DontHaves:    s.DontHaves,
+				Blocks:       s.Blocks,
+				HitRate:      s.HitRate(),
+				Unresponsive: nd.BitswapBroadcast.Unresponsive(s),
+			})
+		}
+		sort.Slice(out.Peers, func(i, j int) bool {
+			if out.Peers[i].HitRate != out.Peers[j].HitRate {
+				return out.Peers[i].HitRate > out.Peers[j].HitRate
+			}
+			return out.Peers[i].Peer < out.Peers[j].Peer
+		})
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: BitswapScores{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *BitswapScores) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "PEER\tWANT-HAVE\tWANT-BLOCK\tDROPPED\tHAVE\tDONT-HAVE\tBLOCK\tHIT RATE\t")
+			for _, p := range out.Peers {
+				rate := fmt.Sprintf("%.1f%%", p.HitRate*100)
+				if p.Unresponsive {
+					rate += " (unresponsive)"
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t\n", p.Peer, p.WantHaves, p.WantBlocks, p.Dropped, p.Haves, p.DontHaves, p.Blocks, rate)
+			}
+			return tw.Flush()
+		}),
+	},
+}
@@ -41,6 +41,9 @@ passed with a valid CID.
 		"get":  blockGetCmd,
 		"put":  blockPutCmd,
 		"rm":   blockRmCmd,
+
+		"getmany": blockGetManyCmd,
+		"putmany": blockPutManyCmd,
 	},
 }
 
@@ -143,7 +146,7 @@ only for backward compatibility when a legacy CIDv0 is required (--format=v0).
 	},
 	Options: []cmds.Option{
 		cmds.StringOption(blockCidCodecOptionName, "Multicodec to use in returned CID").WithDefault("raw"),
-		cmds.StringOption(mhtypeOptionName, "Multihash hash function").WithDefault("sha2-256"),
+		cmds.StringOption(mhtypeOptionName, "Multihash hash function, e.g. sha2-256 or blake3").WithDefault("sha2-256"),
 		cmds.IntOption(mhlenOptionName, "Multihash hash length").WithDefault(-1),
 		cmds.BoolOption(pinOptionName, "Pin added blocks recursively").WithDefault(false),
 		cmdutils.AllowBigBlockOption,
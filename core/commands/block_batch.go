@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	blockservice "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-libipfs/files"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	gocarv2 "github.com/ipld/go-car/v2"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/thirdparty/verifbs"
+)
+
+// putManyBatchSize is the number of blocks written to the blockstore at once
+// by 'ipfs block putmany'.
+const putManyBatchSize = 256
+
+var blockPutManyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Store many IPFS blocks from a CAR stream in one call.",
+		ShortDescription: `
+'ipfs block putmany' is a plumbing command for storing many raw IPFS blocks
+with a single request. It reads the blocks from CAR streams, verifies them
+against their CIDs, stores them in batches, and outputs their CIDs.
+
+Unlike 'ipfs dag import', roots listed in the CAR headers are ignored and
+nothing is pinned.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.FileArg("data", true, true, "CAR stream(s) containing the blocks to store.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmdutils.AllowBigBlockOption,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		node, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		batch := make([]blocks.Block, 0, putManyBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := node.Blocks.AddBlocks(req.Context, batch); err != nil {
+				return err
+			}
+			for _, b := range batch {
+				if err := res.Emit(&BlockStat{Key: b.Cid().String(), Size: len(b.RawData())}); err != nil {
+					return err
+				}
+			}
+			batch = batch[:0]
+			return nil
+		}
+
+		it := req.Files.Entries()
+		for it.Next() {
+			file := files.FileFromEntry(it)
+			if file == nil {
+				return errors.New("expected a file")
+			}
+
+			err := func() error {
+				defer file.Close()
+
+				car, err := gocarv2.NewBlockReader(file)
+				if err != nil {
+					return err
+				}
+				for {
+					b, err := car.Next()
+					if err == io.EOF {
+						return nil
+					} else if err != nil {
+						return err
+					}
+					if err := cmdutils.CheckBlockSize(req, uint64(len(b.RawData()))); err != nil {
+						return err
+					}
+					if err := verifbs.VerifyBlock(b); err != nil {
+						return fmt.Errorf("block %s: %w", b.Cid(), err)
+					}
+					batch = append(batch, b)
+					if len(batch) == putManyBatchSize {
+						if err := flush(); err != nil {
+							return err
+						}
+					}
+				}
+			}()
+			if err != nil {
+				return err
+			}
+		}
+		if err := it.Err(); err != nil {
+			return err
+		}
+		return flush()
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, bs *BlockStat) error {
+			_, err := fmt.Fprintf(w, "%s\n", bs.Key)
+			return err
+		}),
+	},
+	Type: BlockStat{},
+}
+
+var blockGetManyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Get many raw IPFS blocks as a CAR stream in one call.",
+		ShortDescription: `
+'ipfs block getmany' is a plumbing command for retrieving many raw IPFS
+blocks with a single request. It outputs a CARv1 stream containing the
+requested blocks, in the order they were found. The requested CIDs are listed
+as roots in the CAR header.
+
+Blocks missing locally are fetched from the network, unless --offline is set.
+The command fails if some blocks could not be retrieved.
+`,
+	},
+
+	Arguments: []cmds.Argument{
+		cmds.StringArg("cid", true, true, "The CIDs of the blocks to get.").EnableStdin(),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		node, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		var cids []cid.Cid
+		seen := cid.NewSet()
+		for _, arg := range req.Arguments {
+			c, err := cid.Decode(arg)
+			if err != nil {
+				return fmt.Errorf("invalid CID %q: %w", arg, err)
+			}
+			if seen.Visit(c) {
+				cids = append(cids, c)
+			}
+		}
+
+		bserv := node.Blocks
+		if offlineOpt, _ := req.Options[OfflineOption].(bool); offlineOpt || !node.IsOnline {
+			bserv = blockservice.New(node.Blockstore, offline.Exchange(node.Blockstore))
+		}
+
+		pipeR, pipeW := io.Pipe()
+
+		errCh := make(chan error, 2) // we only report the 1st error
+		go func() {
+			defer func() {
+				if err := pipeW.Close(); err != nil {
+					errCh <- fmt.Errorf("stream flush failed: %s", err)
+				}
+				close(errCh)
+			}()
+			if err := writeBlocksCar(req, bserv, cids, pipeW); err != nil {
+				errCh <- err
+			}
+		}()
+
+		if err := res.Emit(pipeR); err != nil {
+			pipeR.Close() // ignore the error if any
+			return err
+		}
+		return <-errCh
+	},
+}
+
+func writeBlocksCar(req *cmds.Request, bserv blockservice.BlockGetter, cids []cid.Cid, w io.Writer) error {
+	if err := gocar.WriteHeader(&gocar.CarHeader{Roots: cids, Version: 1}, w); err != nil {
+		return err
+	}
+
+	var found int
+	for b := range bserv.GetBlocks(req.Context, cids) {
+		if err := carutil.LdWrite(w, b.Cid().Bytes(), b.RawData()); err != nil {
+			return err
+		}
+		found++
+	}
+	if err := req.Context.Err(); err != nil {
+		return err
+	}
+	if found < len(cids) {
+		return fmt.Errorf("%d of %d blocks could not be retrieved", len(cids)-found, len(cids))
+	}
+	return nil
+}
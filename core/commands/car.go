@@ -0,0 +1,174 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-libipfs/files"
+	gocarv2 "github.com/ipld/go-car/v2"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dagproof"
+	"github.com/ipfs/kubo/thirdparty/verifbs"
+)
+
+const (
+	carRootOptionName = "root"
+	carPathOptionName = "path"
+)
+
+var CarCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inspect .car files.",
+		ShortDescription: `
+'ipfs car' works on .car (Content Address aRchive) files without importing
+them, and without a repo.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"verify": carVerifyCmd,
+	},
+}
+
+// CarVerifyOutput is the output of 'ipfs car verify'.
+type CarVerifyOutput struct {
+	Root   string
+	Blocks uint64
+	Size   uint64
+	// Path is the path verified with --path, Resolved the CID it resolves
+	// to and Rest the remainder of the path within its block
+	Path     string `json:",omitempty"`
+	Resolved string `json:",omitempty"`
+	Rest     string `json:",omitempty"`
+}
+
+var carVerifyCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Verify the blocks of a .car file, and the inclusion proof of a path.",
+		ShortDescription: `
+'ipfs car verify' checks that the blocks of a .car file match their CIDs, and
+that the file contains the block of the root: the --root option, or the only
+root of the header.
+
+With --path, it checks that the blocks resolve the path from the root, e.g.
+with an inclusion proof made by 'ipfs dag proof', and prints the CID the path
+resolves to:
+
+  > ipfs dag proof bafyroot... docs/report.pdf > proof.car
+  > ipfs car verify proof.car --root=bafyroot... --path=docs/report.pdf
+  docs/report.pdf resolves to bafyreport...
+
+The blocks are kept in memory to resolve the path.
+
+Maximum supported CAR version: 2
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("file", true, false, "The path of a .car file.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(carRootOptionName, "CID of the root, the root of the header by default."),
+		cmds.StringOption(carPathOptionName, "Path, relative to the root, to verify the inclusion of."),
+	},
+	Extra: CreateCmdExtras(SetDoesNotUseRepo(true)),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		it := req.Files.Entries()
+		if !it.Next() {
+			if it.Err() != nil {
+				return it.Err()
+			}
+			return errors.New("expected a .car file")
+		}
+		file := files.FileFromEntry(it)
+		if file == nil {
+			return errors.New("expected a .car file")
+		}
+		defer file.Close()
+
+		car, err := gocarv2.NewBlockReader(file)
+		if err != nil {
+			return err
+		}
+
+		var root cid.Cid
+		if r, _ := req.Options[carRootOptionName].(string); r != "" {
+			if root, err = cid.Decode(r); err != nil {
+				return fmt.Errorf("invalid root: %w", err)
+			}
+		} else if len(car.Roots) == 1 {
+			root = car.Roots[0]
+		} else {
+			return fmt.Errorf("the header lists %d roots, pass one with --%s", len(car.Roots), carRootOptionName)
+		}
+
+		rel, hasPath := req.Options[carPathOptionName].(string)
+		out := &CarVerifyOutput{Root: enc.Encode(root)}
+		var blks []blocks.Block
+		var hasRoot bool
+		for {
+			block, err := car.Next()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			if err := verifbs.VerifyBlock(block); err != nil {
+				return fmt.Errorf("block %s: %w", block.Cid(), err)
+			}
+			out.Blocks++
+			out.Size += uint64(len(block.RawData()))
+			hasRoot = hasRoot || block.Cid().Equals(root)
+			if hasPath {
+				blks = append(blks, block)
+			}
+		}
+		if !hasRoot {
+			return fmt.Errorf("the file does not contain the root %s", enc.Encode(root))
+		}
+
+		if hasPath {
+			p, err := dagproof.Path(root, rel)
+			if err != nil {
+				return err
+			}
+			c, rest, err := dagproof.Verify(req.Context, blks, p)
+			if err != nil {
+				return err
+			}
+			out.Path = strings.Trim(rel, "/")
+			out.Resolved = enc.Encode(c)
+			out.Rest = strings.Join(rest, "/")
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: CarVerifyOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CarVerifyOutput) error {
+			if out.Resolved != "" {
+				resolved := out.Resolved
+				if out.Rest != "" {
+					resolved += "/" + out.Rest
+				}
+				p := out.Path
+				if p == "" {
+					p = "/"
+				}
+				_, err := fmt.Fprintf(w, "%s resolves to %s\n", p, resolved)
+				return err
+			}
+			_, err := fmt.Fprintf(w, "verified %d blocks (%s) of root %s\n", out.Blocks, humanize.Bytes(out.Size), out.Root)
+			return err
+		}),
+	},
+}
@@ -7,18 +7,21 @@ import (
 	"os"
 
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/encryption"
 
 	"github.com/cheggaaa/pb"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-libipfs/files"
 	iface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/libp2p/go-libp2p/core/crypto"
 )
 
 const (
 	progressBarMinSize = 1024 * 1024 * 8 // show progress bar for outputs > 8MiB
 	offsetOptionName   = "offset"
 	lengthOptionName   = "length"
+	decryptOptionName  = "decrypt"
 )
 
 var CatCmd = &cmds.Command{
@@ -34,6 +37,7 @@ var CatCmd = &cmds.Command{
 		cmds.Int64Option(offsetOptionName, "o", "Byte offset to begin reading from."),
 		cmds.Int64Option(lengthOptionName, "l", "Maximum number of bytes to read."),
 		cmds.BoolOption(progressOptionName, "p", "Stream progress data.").WithDefault(true),
+		cmds.StringOption(decryptOptionName, "Decrypt the files added with 'ipfs add --encrypt' with the named key of the keystore."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -60,9 +64,26 @@ var CatCmd = &cmds.Command{
 			return err
 		}
 
-		readers, length, err := cat(req.Context, api, req.Arguments, int64(offset), int64(max))
-		if err != nil {
-			return err
+		var readers []io.Reader
+		var length uint64
+		if decryptKey, _ := req.Options[decryptOptionName].(string); decryptKey != "" {
+			nd, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			sk, err := encryptionKey(nd, decryptKey)
+			if err != nil {
+				return err
+			}
+			readers, length, err = catDecrypted(req.Context, api, req.Arguments, sk, offset, max)
+			if err != nil {
+				return err
+			}
+		} else {
+			readers, length, err = cat(req.Context, api, req.Arguments, int64(offset), int64(max))
+			if err != nil {
+				return err
+			}
 		}
 
 		/*
@@ -179,3 +200,48 @@ func cat(ctx context.Context, api iface.CoreAPI, paths []string, offset int64, m
 	}
 	return readers, length, nil
 }
+
+// catDecrypted returns the readers of the decrypted content of the files
+// encrypted with sk. The offset and the maximum length apply to the decrypted
+// content.
+func catDecrypted(ctx context.Context, api iface.CoreAPI, paths []string, sk crypto.PrivKey, offset int64, max int64) ([]io.Reader, uint64, error) {
+	readers, _, err := cat(ctx, api, paths, 0, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var length int64
+	for i, p := range paths {
+		f := readers[i].(files.File)
+		size, err := f.Size()
+		if err != nil {
+			return nil, 0, err
+		}
+		plainSize, err := encryption.PlaintextSize(size)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", p, err)
+		}
+		r := encryption.NewDecryptReader(f, sk)
+		// skip the first bytes, which have to be decrypted
+		if offset > 0 {
+			skipped := offset
+			if skipped > plainSize {
+				skipped = plainSize
+			}
+			if _, err := io.CopyN(io.Discard, r, skipped); err != nil {
+				return nil, 0, fmt.Errorf("%s: %w", p, err)
+			}
+			offset -= skipped
+			plainSize -= skipped
+		}
+		readers[i] = r
+		length += plainSize
+	}
+
+	reader := io.MultiReader(readers...)
+	if max >= 0 && length > max {
+		reader = io.LimitReader(reader, max)
+		length = max
+	}
+	return []io.Reader{reader}, uint64(length), nil
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	defaultAddChunker = "size-262144"
+	defaultAddHash    = "sha2-256"
+)
+
+// cidProfile pins down every 'ipfs add' parameter that affects the resulting
+// CIDs, so that identical files added with the same profile get identical
+// CIDs, whatever the defaults of the Kubo version used.
+type cidProfile struct {
+	Chunker    string
+	Trickle    bool
+	CidVersion int
+	Hash       string
+	RawLeaves  bool
+}
+
+var cidProfiles = map[string]cidProfile{
+	// The historical defaults of 'ipfs add'.
+	"unixfs-v0-2015": {
+		Chunker:    "size-262144",
+		CidVersion: 0,
+		Hash:       "sha2-256",
+		RawLeaves:  false,
+	},
+	// CIDv1 with raw leaves and 1MiB chunks, as commonly used for CARs
+	// uploaded to storage providers.
+	"unixfs-v1-2023": {
+		Chunker:    "size-1048576",
+		CidVersion: 1,
+		Hash:       "sha2-256",
+		RawLeaves:  true,
+	},
+}
+
+func cidProfileNames() []string {
+	names := make([]string, 0, len(cidProfiles))
+	for name := range cidProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyCidProfile checks that the options explicitly set on the request agree
+// with the named profile, and sets the other ones to the profile values.
+func applyCidProfile(name string, opts cmds.OptMap) error {
+	p, ok := cidProfiles[name]
+	if !ok {
+		return fmt.Errorf("unknown cid profile %q, available profiles: %s", name, strings.Join(cidProfileNames(), ", "))
+	}
+
+	conflict := func(opt string, value, expected interface{}) error {
+		return fmt.Errorf("--%s=%v conflicts with cid profile %q, which uses %v", opt, value, name, expected)
+	}
+
+	if v, ok := opts[chunkerOptionName].(string); ok && v != p.Chunker {
+		return conflict(chunkerOptionName, v, p.Chunker)
+	}
+	if v, ok := opts[trickleOptionName].(bool); ok && v != p.Trickle {
+		return conflict(trickleOptionName, v, p.Trickle)
+	}
+	if v, ok := opts[cidVersionOptionName].(int); ok && v != p.CidVersion {
+		return conflict(cidVersionOptionName, v, p.CidVersion)
+	}
+	if v, ok := opts[hashOptionName].(string); ok && strings.ToLower(v) != p.Hash {
+		return conflict(hashOptionName, v, p.Hash)
+	}
+	if v, ok := opts[rawLeavesOptionName].(bool); ok && v != p.RawLeaves {
+		return conflict(rawLeavesOptionName, v, p.RawLeaves)
+	}
+	// inlining replaces small blocks with identity CIDs
+	if v, _ := opts[inlineOptionName].(bool); v {
+		return fmt.Errorf("--%s can not be used with cid profile %q", inlineOptionName, name)
+	}
+
+	opts[chunkerOptionName] = p.Chunker
+	opts[trickleOptionName] = p.Trickle
+	opts[cidVersionOptionName] = p.CidVersion
+	opts[hashOptionName] = p.Hash
+	opts[rawLeavesOptionName] = p.RawLeaves
+	return nil
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"testing"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func TestApplyCidProfile(t *testing.T) {
+	opts := cmds.OptMap{chunkerOptionName: "size-1048576"}
+	if err := applyCidProfile("unixfs-v1-2023", opts); err != nil {
+		t.Fatal(err)
+	}
+	if opts[cidVersionOptionName] != 1 || opts[rawLeavesOptionName] != true || opts[hashOptionName] != "sha2-256" {
+		t.Fatalf("profile not applied: %v", opts)
+	}
+
+	for _, opts := range []cmds.OptMap{
+		{chunkerOptionName: "size-262144"},
+		{cidVersionOptionName: 0},
+		{rawLeavesOptionName: false},
+		{hashOptionName: "blake3"},
+		{trickleOptionName: true},
+		{inlineOptionName: true},
+	} {
+		if err := applyCidProfile("unixfs-v1-2023", opts); err == nil {
+			t.Errorf("expected %v to conflict with the profile", opts)
+		}
+	}
+
+	if err := applyCidProfile("unknown", cmds.OptMap{}); err == nil {
+		t.Fatal("expected unknown profile to be rejected")
+	}
+}
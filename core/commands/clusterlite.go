@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var errClusterLiteDisabled = errors.New("cluster-lite is not enabled, set ClusterLite.Enabled to true and restart the daemon")
+
+type ClusterLitePin struct {
+	Cid         string
+	Name        string
+	Allocations []string
+	Local       bool
+}
+
+type ClusterLiteMember struct {
+	ID       string
+	Self     bool
+	LastSeen *time.Time `json:",omitempty"`
+}
+
+var ClusterLiteCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Replicate a shared pinset between trusted peers.",
+		ShortDescription: `
+'ipfs cluster-lite' manages a pinset shared with the trusted peers listed in
+ClusterLite.Peers. Members gossip the pinset over pubsub and each member pins
+its share of it, according to ClusterLite.ReplicationFactor.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add":   clusterLiteAddCmd,
+		"rm":    clusterLiteRmCmd,
+		"ls":    clusterLiteLsCmd,
+		"peers": clusterLitePeersCmd,
+	},
+}
+
+func getClusterLite(env cmds.Environment) (*core.IpfsNode, error) {
+	n, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsOnline {
+		return nil, ErrNotOnline
+	}
+	if n.ClusterLite == nil {
+		return nil, errClusterLiteDisabled
+	}
+	return n, nil
+}
+
+var clusterLiteAddCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Add a path to the shared pinset.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to object(s) to be pinned by the cluster.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption("name", "An optional name for the pin."),
+	},
+	Type: ClusterLitePin{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := getClusterLite(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		name, _ := req.Options["name"].(string)
+
+		for _, p := range req.Arguments {
+			rp, err := api.ResolvePath(req.Context, path.New(p))
+			if err != nil {
+				return err
+			}
+			if err := n.ClusterLite.Add(req.Context, rp.Cid(), name); err != nil {
+				return err
+			}
+			enc, err := cmdenv.GetCidEncoder(req)
+			if err != nil {
+				return err
+			}
+			if err := res.Emit(&ClusterLitePin{Cid: enc.Encode(rp.Cid()), Name: name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ClusterLitePin) error {
+			_, err := fmt.Fprintf(w, "added %s\n", out.Cid)
+			return err
+		}),
+	},
+}
+
+var clusterLiteRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a path from the shared pinset.",
+		ShortDescription: `
+Removes the object from the shared pinset. Members that pinned it on behalf
+of the cluster unpin it.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to object(s) to be removed from the shared pinset.").EnableStdin(),
+	},
+	Type: ClusterLitePin{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := getClusterLite(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range req.Arguments {
+			rp, err := api.ResolvePath(req.Context, path.New(p))
+			if err != nil {
+				return err
+			}
+			if err := n.ClusterLite.Rm(req.Context, rp.Cid()); err != nil {
+				return err
+			}
+			if err := res.Emit(&ClusterLitePin{Cid: enc.Encode(rp.Cid())}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ClusterLitePin) error {
+			_, err := fmt.Fprintf(w, "removed %s\n", out.Cid)
+			return err
+		}),
+	},
+}
+
+var clusterLiteLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the shared pinset.",
+		ShortDescription: `
+Lists the objects of the shared pinset, the members they are allocated to, and
+whether this node pinned them on behalf of the cluster.
+`,
+	},
+	Type: ClusterLitePin{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := getClusterLite(env)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		pins, err := n.ClusterLite.Pins(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, p := range pins {
+			out := &ClusterLitePin{
+				Cid:   enc.Encode(p.Cid),
+				Name:  p.Name,
+				Local: p.Local,
+			}
+			for _, a := range p.Allocations {
+				out.Allocations = append(out.Allocations, a.String())
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ClusterLitePin) error {
+			local := "remote"
+			if out.Local {
+				local = "local"
+			}
+			_, err := fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", out.Cid, local, strings.Join(out.Allocations, ","), cmdenv.EscNonPrint(out.Name))
+			return err
+		}),
+	},
+}
+
+var clusterLitePeersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the members of the cluster.",
+	},
+	Type: ClusterLiteMember{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := getClusterLite(env)
+		if err != nil {
+			return err
+		}
+		for _, m := range n.ClusterLite.Members() {
+			out := &ClusterLiteMember{ID: m.ID.String(), Self: m.Self}
+			if !m.LastSeen.IsZero() {
+				t := m.LastSeen
+				out.LastSeen = &t
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ClusterLiteMember) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			defer tw.Flush()
+			switch {
+			case out.Self:
+				fmt.Fprintf(tw, "%s\tself\n", out.ID)
+			case out.LastSeen == nil:
+				fmt.Fprintf(tw, "%s\tnever seen\n", out.ID)
+			default:
+				fmt.Fprintf(tw, "%s\tlast seen %s ago\n", out.ID, time.Since(*out.LastSeen).Round(time.Second))
+			}
+			return nil
+		}),
+	},
+}
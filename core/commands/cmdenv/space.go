@@ -0,0 +1,14 @@
+package cmdenv
+
+import (
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// OptionSpace selects the space a command operates in, see 'ipfs space'.
+var OptionSpace = cmds.StringOption("space", "Operate in this space instead of the pinset and MFS root of the node, see 'ipfs space'.")
+
+// GetSpace returns the space of the request, "" for the node itself.
+func GetSpace(req *cmds.Request) string {
+	space, _ := req.Options[OptionSpace.Name()].(string)
+	return space
+}
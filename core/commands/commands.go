@@ -66,6 +66,7 @@ func CommandsCmd(root *cmds.Command) *cmds.Command {
 		},
 		Subcommands: map[string]*cmds.Command{
 			"completion": CompletionCmd(root),
+			"schema":     schemaCmd(root),
 		},
 		Options: []cmds.Option{
 			cmds.BoolOption(flagsOptionName, "f", "Show command flags"),
@@ -196,6 +197,60 @@ To install the completions permanently, they can be moved to
 					return res.Emit(&buf)
 				},
 			},
+			"zsh": {
+				Helptext: cmds.HelpText{
+					Tagline:          "Generate zsh shell completions.",
+					ShortDescription: "Generates command completions for the zsh shell.",
+					LongDescription: `
+Generates command completions for the zsh shell.
+
+The simplest way to see it working is write the completions
+to a file and then source it:
+
+  > ipfs commands completion zsh > ipfs-completion.zsh
+  > source ./ipfs-completion.zsh
+
+To install the completions permanently, they can be sourced from
+your ~/.zshrc file.
+`,
+				},
+				NoRemote: true,
+				Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+					var buf bytes.Buffer
+					if err := writeZshCompletions(root, &buf); err != nil {
+						return err
+					}
+					res.SetLength(uint64(buf.Len()))
+					return res.Emit(&buf)
+				},
+			},
+			"powershell": {
+				Helptext: cmds.HelpText{
+					Tagline:          "Generate PowerShell completions.",
+					ShortDescription: "Generates command completions for PowerShell.",
+					LongDescription: `
+Generates command completions for PowerShell.
+
+The simplest way to see it working is write the completions
+to a file and then source it:
+
+  > ipfs commands completion powershell > ipfs-completion.ps1
+  > . ./ipfs-completion.ps1
+
+To install the completions permanently, they can be sourced from
+your $PROFILE file.
+`,
+				},
+				NoRemote: true,
+				Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+					var buf bytes.Buffer
+					if err := writePowershellCompletions(root, &buf); err != nil {
+						return err
+					}
+					res.SetLength(uint64(buf.Len()))
+					return res.Emit(&buf)
+				},
+			},
 		},
 	}
 }
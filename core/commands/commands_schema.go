@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// JSONSchema is the JSON schema of the output of a command, see
+// https://json-schema.org. An empty schema accepts any value.
+type JSONSchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+}
+
+// CommandSchema is the output schema of a command.
+type CommandSchema struct {
+	Command string
+	// Output is the schema of each of the values output by the command, nil
+	// if the command outputs raw data, e.g. the content of a file.
+	Output *JSONSchema `json:",omitempty"`
+}
+
+func schemaCmd(root *cmds.Command) *cmds.Command {
+	return &cmds.Command{
+		Helptext: cmds.HelpText{
+			Tagline: "Show the JSON schema of the output of a command.",
+			ShortDescription: `
+Shows the JSON schema of the values output by a command with --enc=json or
+--machine, one value per line. Commands which output raw data, e.g.
+'ipfs cat', have no schema.
+
+EXAMPLES
+
+  > ipfs commands schema pin ls
+`,
+		},
+		Arguments: []cmds.Argument{
+			cmds.StringArg("command", true, true, "The command, e.g. 'pin ls'."),
+		},
+		Extra: CreateCmdExtras(SetDoesNotUseRepo(true)),
+		Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+			var path []string
+			for _, arg := range req.Arguments {
+				path = append(path, strings.Fields(arg)...)
+			}
+			cmd := root
+			for _, name := range path {
+				sub, ok := cmd.Subcommands[name]
+				if !ok {
+					return fmt.Errorf("unknown command 'ipfs %s'", strings.Join(path, " "))
+				}
+				cmd = sub
+			}
+			out := &CommandSchema{Command: "ipfs " + strings.Join(path, " ")}
+			if cmd.Type != nil {
+				out.Output = typeSchema(reflect.TypeOf(cmd.Type), make(map[reflect.Type]bool))
+			}
+			return cmds.EmitOnce(res, out)
+		},
+		Type: CommandSchema{},
+		Encoders: cmds.EncoderMap{
+			cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *CommandSchema) error {
+				if out.Output == nil {
+					fmt.Fprintf(w, "%s outputs raw data\n", out.Command)
+					return nil
+				}
+				b, err := json.MarshalIndent(out.Output, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "%s\n", b)
+				return nil
+			}),
+		},
+	}
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+	cidType           = reflect.TypeOf(cid.Cid{})
+)
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// typeSchema returns the schema of the JSON encoding of t. The types being
+// described are in seen, to stop at recursive types.
+func typeSchema(t reflect.Type, seen map[reflect.Type]bool) *JSONSchema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &JSONSchema{Type: "string", Format: "date-time"}
+	case t == cidType:
+		return &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{"/": {Type: "string"}}}
+	case implements(t, jsonMarshalerType):
+		// custom encodings can't be described
+		return &JSONSchema{}
+	case implements(t, textMarshalerType):
+		return &JSONSchema{Type: "string"}
+	case seen[t]:
+		return &JSONSchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Type: "number"}
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Type: "string", Format: "byte"}
+		}
+		return &JSONSchema{Type: "array", Items: typeSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return &JSONSchema{Type: "object", AdditionalProperties: typeSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		seen[t] = true
+		defer delete(seen, t)
+		s := &JSONSchema{Type: "object", Properties: make(map[string]*JSONSchema)}
+		addFields(s, t, seen)
+		return s
+	default:
+		return &JSONSchema{}
+	}
+}
+
+// addFields adds the properties of the fields of the struct t to s, and the
+// ones of its embedded structs.
+func addFields(s *JSONSchema, t reflect.Type, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(s, ft, seen)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = typeSchema(f.Type, seen)
+	}
+}
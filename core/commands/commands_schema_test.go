@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestTypeSchema(t *testing.T) {
+	type embedded struct {
+		Embedded string
+	}
+	type node struct {
+		embedded
+		Name     string
+		Size     uint64 `json:"size,omitempty"`
+		Skipped  bool   `json:"-"`
+		Data     []byte
+		Cid      cid.Cid
+		Created  time.Time
+		Children []node
+		Meta     map[string]int
+		private  int
+	}
+
+	s := typeSchema(reflect.TypeOf(&node{}), make(map[reflect.Type]bool))
+	if s.Type != "object" {
+		t.Fatalf("unexpected type %q", s.Type)
+	}
+	for name, want := range map[string]string{
+		"Embedded": "string",
+		"Name":     "string",
+		"size":     "integer",
+		"Data":     "string",
+		"Cid":      "object",
+		"Created":  "string",
+		"Children": "array",
+		"Meta":     "object",
+	} {
+		p, ok := s.Properties[name]
+		if !ok {
+			t.Errorf("missing property %s", name)
+			continue
+		}
+		if p.Type != want {
+			t.Errorf("property %s has type %q, expected %q", name, p.Type, want)
+		}
+	}
+	for _, name := range []string{"Skipped", "private", "embedded"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("unexpected property %s", name)
+		}
+	}
+	// recursive types stop at the first level
+	if items := s.Properties["Children"].Items; items == nil || items.Type != "" {
+		t.Errorf("unexpected schema of the recursive property: %+v", items)
+	}
+	if meta := s.Properties["Meta"].AdditionalProperties; meta == nil || meta.Type != "integer" {
+		t.Errorf("unexpected schema of the map values: %+v", meta)
+	}
+}
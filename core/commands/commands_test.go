@@ -25,6 +25,9 @@ func TestROCommands(t *testing.T) {
 		"/commands/completion",
 		"/commands/completion/bash",
 		"/commands/completion/fish",
+		"/commands/completion/powershell",
+		"/commands/completion/zsh",
+		"/commands/schema",
 		"/dag",
 		"/dag/get",
 		"/dag/resolve",
@@ -74,14 +77,20 @@ func TestROCommands(t *testing.T) {
 func TestCommands(t *testing.T) {
 	list := []string{
 		"/add",
+		"/admin",
+		"/admin/exec",
+		"/admin/sign",
 		"/bitswap",
 		"/bitswap/ledger",
 		"/bitswap/reprovide",
+		"/bitswap/scores",
 		"/bitswap/stat",
 		"/bitswap/wantlist",
 		"/block",
 		"/block/get",
+		"/block/getmany",
 		"/block/put",
+		"/block/putmany",
 		"/block/rm",
 		"/block/stat",
 		"/bootstrap",
@@ -90,6 +99,8 @@ func TestCommands(t *testing.T) {
 		"/bootstrap/list",
 		"/bootstrap/rm",
 		"/bootstrap/rm/all",
+		"/car",
+		"/car/verify",
 		"/cat",
 		"/cid",
 		"/cid/base32",
@@ -97,20 +108,32 @@ func TestCommands(t *testing.T) {
 		"/cid/codecs",
 		"/cid/format",
 		"/cid/hashes",
+		"/cluster-lite",
+		"/cluster-lite/add",
+		"/cluster-lite/ls",
+		"/cluster-lite/peers",
+		"/cluster-lite/rm",
 		"/commands",
 		"/commands/completion",
 		"/commands/completion/bash",
+		"/commands/completion/candidates",
 		"/commands/completion/fish",
+		"/commands/completion/powershell",
+		"/commands/completion/zsh",
+		"/commands/schema",
 		"/config",
 		"/config/edit",
 		"/config/profile",
 		"/config/profile/apply",
+		"/config/profile/export",
 		"/config/replace",
 		"/config/show",
 		"/dag",
+		"/dag/diff",
 		"/dag/export",
 		"/dag/get",
 		"/dag/import",
+		"/dag/proof",
 		"/dag/put",
 		"/dag/resolve",
 		"/dag/stat",
@@ -131,9 +154,19 @@ func TestCommands(t *testing.T) {
 		"/diag/cmds",
 		"/diag/cmds/clear",
 		"/diag/cmds/set-time",
+		"/diag/dag-providers",
+		"/diag/dedup",
+		"/diag/errors",
+		"/diag/nat",
+		"/diag/netstat",
 		"/diag/profile",
+		"/diag/shape",
+		"/diag/shape/ls",
+		"/diag/shape/rm",
+		"/diag/shape/set",
 		"/diag/sys",
 		"/dns",
+		"/events",
 		"/file",
 		"/file/ls",
 		"/files",
@@ -143,9 +176,17 @@ func TestCommands(t *testing.T) {
 		"/files/ls",
 		"/files/mkdir",
 		"/files/mv",
+		"/files/patch",
+		"/files/patch/add-link",
+		"/files/patch/chmod",
+		"/files/patch/rm-link",
+		"/files/patch/set-data",
+		"/files/patch/touch",
 		"/files/read",
 		"/files/rm",
 		"/files/stat",
+		"/files/sync",
+		"/files/truncate",
 		"/files/write",
 		"/filestore",
 		"/filestore/dups",
@@ -153,6 +194,12 @@ func TestCommands(t *testing.T) {
 		"/filestore/verify",
 		"/get",
 		"/id",
+		"/jobs",
+		"/jobs/cancel",
+		"/jobs/ls",
+		"/jobs/output",
+		"/jobs/results",
+		"/jobs/status",
 		"/key",
 		"/key/export",
 		"/key/gen",
@@ -161,6 +208,7 @@ func TestCommands(t *testing.T) {
 		"/key/rename",
 		"/key/rm",
 		"/key/rotate",
+		"/key/share",
 		"/log",
 		"/log/level",
 		"/log/ls",
@@ -177,6 +225,7 @@ func TestCommands(t *testing.T) {
 		"/name/publish",
 		"/name/pubsub",
 		"/name/pubsub/cancel",
+		"/name/pubsub/peers",
 		"/name/pubsub/state",
 		"/name/pubsub/subs",
 		"/name/resolve",
@@ -203,6 +252,15 @@ func TestCommands(t *testing.T) {
 		"/p2p/stream/ls",
 		"/pin",
 		"/pin/add",
+		"/pin/export",
+		"/pin/import",
+		"/pin/jobs",
+		"/pin/jobs/cancel",
+		"/pin/jobs/ls",
+		"/pin/label",
+		"/pin/label/ls",
+		"/pin/label/rm",
+		"/pin/label/set",
 		"/pin/ls",
 		"/pin/remote",
 		"/pin/remote/add",
@@ -212,6 +270,7 @@ func TestCommands(t *testing.T) {
 		"/pin/remote/service/add",
 		"/pin/remote/service/ls",
 		"/pin/remote/service/rm",
+		"/pin/remote/status",
 		"/pin/rm",
 		"/pin/update",
 		"/pin/verify",
@@ -223,20 +282,34 @@ func TestCommands(t *testing.T) {
 		"/pubsub/sub",
 		"/refs",
 		"/refs/local",
+		"/refs/prefetch",
+		"/refs/prefetch/cancel",
+		"/refs/prefetch/ls",
 		"/repo",
+		"/repo/backup",
 		"/repo/fsck",
 		"/repo/gc",
+		"/repo/health",
 		"/repo/migrate",
+		"/repo/restore",
 		"/repo/stat",
 		"/repo/verify",
 		"/repo/version",
 		"/repo/ls",
 		"/resolve",
 		"/shutdown",
+		"/space",
+		"/space/create",
+		"/space/ls",
+		"/space/quota",
+		"/space/rm",
+		"/space/stat",
 		"/stats",
 		"/stats/bitswap",
 		"/stats/bw",
+		"/stats/bw/history",
 		"/stats/dht",
+		"/stats/gateway",
 		"/stats/provide",
 		"/stats/repo",
 		"/swarm",
@@ -254,6 +327,7 @@ func TestCommands(t *testing.T) {
 		"/swarm/peering/add",
 		"/swarm/peering/ls",
 		"/swarm/peering/rm",
+		"/swarm/pnet",
 		"/swarm/stats",
 		"/tar",
 		"/tar/add",
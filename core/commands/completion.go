@@ -3,6 +3,7 @@ package commands
 import (
 	"io"
 	"sort"
+	"strconv"
 	"text/template"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -20,12 +21,25 @@ type completionCommand struct {
 	LongFlags    []string
 	LongOptions  []string
 	IsFinal      bool
+	// ArgKinds are the arguments completed dynamically, see completionKinds,
+	// and ArgKind the kind of the first one.
+	ArgKinds []*argCompletion
+	ArgKind  string
+}
+
+type argCompletion struct {
+	// Index is the position of the argument, "*" for the last one if it's
+	// variadic.
+	Index string
+	Kind  string
 }
 
 type singleOption struct {
 	LongNames   []string
 	ShortNames  []string
 	Description string
+	// Kind is the kind of the values completed dynamically.
+	Kind string
 }
 
 func commandToCompletions(name string, fullName string, cmd *cmds.Command) *completionCommand {
@@ -35,6 +49,20 @@ func commandToCompletions(name string, fullName string, cmd *cmds.Command) *comp
 		Description: cmd.Helptext.Tagline,
 		IsFinal:     len(cmd.Subcommands) == 0,
 	}
+	for i, arg := range cmd.Arguments {
+		kind := completionKind(fullName, arg.Name)
+		if kind == "" {
+			continue
+		}
+		index := strconv.Itoa(i)
+		if arg.Variadic {
+			index = "*"
+		}
+		parsed.ArgKinds = append(parsed.ArgKinds, &argCompletion{Index: index, Kind: kind})
+		if parsed.ArgKind == "" {
+			parsed.ArgKind = kind
+		}
+	}
 	for name, subCmd := range cmd.Subcommands {
 		parsed.Subcommands = append(parsed.Subcommands,
 			commandToCompletions(name, fullName+" "+name, subCmd))
@@ -44,7 +72,7 @@ func commandToCompletions(name string, fullName string, cmd *cmds.Command) *comp
 	})
 
 	for _, opt := range cmd.Options {
-		flag := &singleOption{Description: opt.Description()}
+		flag := &singleOption{Description: opt.Description(), Kind: completionKind(fullName, opt.Name())}
 		flag.LongNames = append(flag.LongNames, opt.Name())
 		if opt.Type() == cmds.Bool {
 			parsed.LongFlags = append(parsed.LongFlags, opt.Name())
@@ -83,7 +111,23 @@ func commandToCompletions(name string, fullName string, cmd *cmds.Command) *comp
 	return parsed
 }
 
-var bashCompletionTemplate, fishCompletionTemplate *template.Template
+// allCompletions returns c and all its subcommands, recursively.
+func allCompletions(c *completionCommand) []*completionCommand {
+	all := []*completionCommand{c}
+	for _, sub := range c.Subcommands {
+		all = append(all, allCompletions(sub)...)
+	}
+	return all
+}
+
+var bashCompletionTemplate, fishCompletionTemplate, powershellCompletionTemplate *template.Template
+
+// zshCompletionHeader makes zsh load the bash completions.
+const zshCompletionHeader = `#compdef ipfs
+
+autoload -U +X bashcompinit && bashcompinit
+
+`
 
 func init() {
 	commandTemplate := template.Must(template.New("command").Parse(`
@@ -103,7 +147,12 @@ while [[ ${index} -lt ${COMP_CWORD} ]]; do
     esac
     break
 done
-
+{{ range .Options }}{{ if .Kind }}
+if [[ "${prev}" == "--{{ index .LongNames 0 }}" ]]; then
+    _ipfs_complete_dynamic {{ .Kind }} "${word}"
+    return 0
+fi
+{{ end }}{{ end }}
 if [[ "${word}" == -* ]]; then
 {{ if .ShortFlags -}}
     _ipfs_compgen -W $'{{ range .ShortFlags }}-{{.}} \n{{end}}' -- "${word}"
@@ -132,6 +181,13 @@ if [[ "${argidx}" -eq 0 ]]; then
     _ipfs_compgen -W $'{{ range .Subcommands }}{{.Name}} \n{{end}}' -- "${word}"
 fi
 {{ end -}}
+{{- if .ArgKinds }}
+case "${argidx}" in
+{{- range .ArgKinds }}
+    {{ .Index }}) _ipfs_complete_dynamic {{ .Kind }} "${word}" ;;
+{{- end }}
+esac
+{{ end -}}
 `))
 
 	bashCompletionTemplate = template.Must(commandTemplate.New("root").Parse(`#!/bin/bash
@@ -145,11 +201,25 @@ _ipfs_compgen() {
   IFS="$oldifs"
 }
 
+# _ipfs_complete_dynamic completes the values of a kind queried from the
+# node, e.g. key names. Directories end with a slash, other values a space.
+_ipfs_complete_dynamic() {
+  local candidates
+  candidates="$(ipfs --timeout=2s commands completion candidates "$1" -- "$2" 2>/dev/null | sed 's#[^/]$#& #')"
+  _ipfs_compgen -W "${candidates}" -- "$2"
+}
+
 _ipfs() {
   COMPREPLY=()
   local index=1
   local argidx=0
   local word="${COMP_WORDS[COMP_CWORD]}"
+  local prev="${COMP_WORDS[COMP_CWORD-1]}"
+  if [[ "${word}" == "=" ]]; then
+    word=""
+  elif [[ "${prev}" == "=" ]]; then
+    prev="${COMP_WORDS[COMP_CWORD-2]}"
+  fi
   {{ template "command" . }}
 }
 complete -o nosort -o nospace -o default -F _ipfs ipfs
@@ -157,13 +227,17 @@ complete -o nosort -o nospace -o default -F _ipfs ipfs
 
 	fishCommandTemplate := template.Must(template.New("command").Parse(`
 {{- if .IsFinal -}}
+{{- if .ArgKind -}}
+complete -c ipfs -n '__fish_ipfs_seen_all_subcommands_from{{ .FullName }}' -f -a '(__fish_ipfs_complete {{ .ArgKind }})'
+{{ else -}}
 complete -c ipfs -n '__fish_ipfs_seen_all_subcommands_from{{ .FullName }}' -F
 {{ end -}}
+{{ end -}}
 {{- range .Flags -}}
     complete -c ipfs -n '__fish_ipfs_seen_all_subcommands_from{{ $.FullName }}' {{ range .ShortNames }}-s {{.}} {{end}}{{ range .LongNames }}-l {{.}} {{end}}-d "{{ .Description }}"
 {{ end -}}
 {{- range .Options -}}
-    complete -c ipfs -n '__fish_ipfs_seen_all_subcommands_from{{ $.FullName }}' -r {{ range .ShortNames }}-s {{.}} {{end}}{{ range .LongNames }}-l {{.}} {{end}}-d "{{ .Description }}"
+    complete -c ipfs -n '__fish_ipfs_seen_all_subcommands_from{{ $.FullName }}' -r {{ if .Kind }}-f -a '(__fish_ipfs_complete {{ .Kind }})' {{ end }}{{ range .ShortNames }}-s {{.}} {{end}}{{ range .LongNames }}-l {{.}} {{end}}-d "{{ .Description }}"
 {{ end -}}
 
 {{- range .Subcommands }}
@@ -202,6 +276,12 @@ function __fish_ipfs_use_subcommand
 	test -z "$argv"
 end
 
+# __fish_ipfs_complete completes the values of a kind queried from the node,
+# e.g. key names.
+function __fish_ipfs_complete
+	ipfs --timeout=2s commands completion candidates $argv[1] -- (commandline -ct) 2>/dev/null
+end
+
 complete -c ipfs -l help -d "Show the full command help text."
 
 complete -c ipfs --keep-order --no-files
@@ -209,6 +289,56 @@ complete -c ipfs --keep-order --no-files
 {{ template "command" . }}
 `))
 
+	powershellCompletionTemplate = template.Must(template.New("root").Parse(`# ipfs commands, by their path.
+$ipfsCommands = @{
+{{- range . }}
+    '{{ .FullName }}' = @{
+        Subcommands = @({{ range $i, $c := .Subcommands }}{{ if $i }}, {{ end }}'{{ $c.Name }}'{{ end }})
+        Options = @({{ range .LongFlags }}'--{{ . }}', {{ end }}{{ range .LongOptions }}'--{{ . }}', {{ end }}{{ range .ShortFlags }}'-{{ . }}', {{ end }}{{ range .ShortOptions }}'-{{ . }}', {{ end }}'--help')
+        Dynamic = @{ {{- range .Options }}{{ if .Kind }} '--{{ index .LongNames 0 }}' = '{{ .Kind }}';{{ end }}{{ end }} }
+        Arguments = '{{ .ArgKind }}'
+    }
+{{- end }}
+}
+
+Register-ArgumentCompleter -Native -CommandName ipfs -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $path = ''
+    $prev = ''
+    foreach ($element in $commandAst.CommandElements | Select-Object -Skip 1) {
+        if ($element.Extent.EndOffset -ge $cursorPosition) {
+            break
+        }
+        $word = $element.ToString()
+        if ($word -notlike '-*' -and $ipfsCommands[$path].Subcommands -contains $word) {
+            $path = "$path $word"
+        }
+        $prev = $word
+    }
+
+    $command = $ipfsCommands[$path]
+    $kind = ''
+    $candidates = @()
+    if ($command.Dynamic.ContainsKey($prev)) {
+        $kind = $command.Dynamic[$prev]
+    } elseif ($wordToComplete -like '-*') {
+        $candidates = $command.Options
+    } elseif ($command.Subcommands) {
+        $candidates = $command.Subcommands
+    } else {
+        $kind = $command.Arguments
+    }
+    if ($kind) {
+        # values queried from the node, e.g. key names
+        $candidates = ipfs --timeout=2s commands completion candidates $kind -- $wordToComplete 2>$null
+    }
+
+    $candidates | Where-Object { $_ -like "$wordToComplete*" } | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`))
 }
 
 // writeBashCompletions generates a bash completion script for the given command tree.
@@ -222,3 +352,17 @@ func writeFishCompletions(cmd *cmds.Command, out io.Writer) error {
 	cmds := commandToCompletions("ipfs", "", cmd)
 	return fishCompletionTemplate.Execute(out, cmds)
 }
+
+// writeZshCompletions generates a zsh completion script for the given command tree.
+func writeZshCompletions(cmd *cmds.Command, out io.Writer) error {
+	if _, err := io.WriteString(out, zshCompletionHeader); err != nil {
+		return err
+	}
+	return writeBashCompletions(cmd, out)
+}
+
+// writePowershellCompletions generates a PowerShell completion script for the given command tree.
+func writePowershellCompletions(cmd *cmds.Command, out io.Writer) error {
+	cmds := commandToCompletions("ipfs", "", cmd)
+	return powershellCompletionTemplate.Execute(out, allCompletions(cmds))
+}
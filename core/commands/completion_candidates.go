@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"fmt"
+	gopath "path"
+	"sort"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	mfs "github.com/ipfs/go-mfs"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	config "github.com/ipfs/kubo/config"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// The kinds of values completed dynamically, by querying the node.
+const (
+	completeKey        = "key"
+	completePin        = "pin"
+	completeMFS        = "mfs"
+	completeConfig     = "config"
+	completePinService = "pin-service"
+)
+
+// maxCompletionCandidates bounds the number of candidates returned, e.g. for
+// nodes with many pins.
+const maxCompletionCandidates = 1000
+
+// completionKinds are the arguments and options completed dynamically, by
+// "<command path>:<argument or option name>".
+var completionKinds = map[string]string{
+	"config:key": completeConfig,
+
+	"files chcid:path":     completeMFS,
+	"files cp:source":      completeMFS,
+	"files cp:dest":        completeMFS,
+	"files flush:path":     completeMFS,
+	"files ls:path":        completeMFS,
+	"files mkdir:path":     completeMFS,
+	"files mv:source":      completeMFS,
+	"files mv:dest":        completeMFS,
+	"files read:path":      completeMFS,
+	"files rm:path":        completeMFS,
+	"files stat:path":      completeMFS,
+	"files write:path":     completeMFS,
+	"key export:name":      completeKey,
+	"key rename:name":      completeKey,
+	"key rm:name":          completeKey,
+	"key rotate:name":      completeKey,
+	"name publish:key":     completeKey,
+	"pin ls:ipfs-path":     completePin,
+	"pin rm:ipfs-path":     completePin,
+	"pin update:from-path": completePin,
+
+	"pin remote add:service":        completePinService,
+	"pin remote ls:service":         completePinService,
+	"pin remote rm:service":         completePinService,
+	"pin remote service rm:service": completePinService,
+}
+
+// completionKind returns the kind of the values of an argument or option of
+// a command, empty if they aren't completed dynamically.
+func completionKind(path, name string) string {
+	return completionKinds[strings.TrimSpace(path)+":"+name]
+}
+
+var completionCandidatesCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the completions of a value, queried from the node.",
+		ShortDescription: `
+Lists the values of the given kind starting with the prefix, one per line.
+It is used by the shell completion scripts, to complete key names, pinned
+CIDs, MFS paths, config keys and remote pinning services. MFS directories
+end with a '/'.
+
+Kinds: key, pin, mfs, config, pin-service.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("kind", true, false, "Kind of values to list."),
+		cmds.StringArg("prefix", false, false, "Prefix of the values to list."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		kind := req.Arguments[0]
+		var prefix string
+		if len(req.Arguments) > 1 {
+			prefix = req.Arguments[1]
+		}
+
+		var candidates []string
+		var err error
+		switch kind {
+		case completeKey:
+			candidates, err = keyCandidates(req, env)
+		case completePin:
+			candidates, err = pinCandidates(req, env, prefix)
+		case completeMFS:
+			candidates, err = mfsCandidates(req, env, prefix)
+		case completeConfig:
+			candidates, err = configCandidates(env)
+		case completePinService:
+			candidates, err = pinServiceCandidates(env)
+		default:
+			return fmt.Errorf("unknown completion kind %q", kind)
+		}
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(candidates)
+		out := &stringList{Strings: []string{}}
+		for _, c := range candidates {
+			if len(out.Strings) == maxCompletionCandidates {
+				break
+			}
+			if strings.HasPrefix(c, prefix) {
+				out.Strings = append(out.Strings, c)
+			}
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: stringList{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(safeTextListEncoder),
+	},
+}
+
+func keyCandidates(req *cmds.Request, env cmds.Environment) ([]string, error) {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := api.Key().List(req.Context)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.Name()
+	}
+	return names, nil
+}
+
+func pinCandidates(req *cmds.Request, env cmds.Environment, prefix string) ([]string, error) {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := cmdenv.GetCidEncoder(req)
+	if err != nil {
+		return nil, err
+	}
+	pins, err := api.Pin().Ls(req.Context, options.Pin.Ls.Recursive())
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for p := range pins {
+		if err := p.Err(); err != nil {
+			return nil, err
+		}
+		if c := enc.Encode(p.Path().Cid()); strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// mfsCandidates lists the entries of the MFS directory of prefix.
+func mfsCandidates(req *cmds.Request, env cmds.Environment, prefix string) ([]string, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	dir := "/"
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		dir = prefix[:i]
+	}
+	fsn, err := mfs.Lookup(nd.FilesRoot, dir)
+	if err != nil {
+		// nothing to complete
+		return nil, nil
+	}
+	d, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil, nil
+	}
+	entries, err := d.List(req.Context)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = gopath.Join(dir, e.Name)
+		if e.Type == int(mfs.TDir) {
+			out[i] += "/"
+		}
+	}
+	return out, nil
+}
+
+// configCandidates lists the keys of the config, e.g. "Addresses.API".
+func configCandidates(env cmds.Environment) ([]string, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := nd.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	m, err := config.ToMap(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	var walk func(prefix string, m map[string]interface{})
+	walk = func(prefix string, m map[string]interface{}) {
+		for k, v := range m {
+			out = append(out, prefix+k)
+			if sub, ok := v.(map[string]interface{}); ok {
+				walk(prefix+k+".", sub)
+			}
+		}
+	}
+	walk("", m)
+	return out, nil
+}
+
+func pinServiceCandidates(env cmds.Environment) ([]string, error) {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := nd.Repo.Config()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for name := range cfg.Pinning.RemoteServices {
+		out = append(out, name)
+	}
+	return out, nil
+}
@@ -0,0 +1,30 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletionKinds(t *testing.T) {
+	for key := range completionKinds {
+		path, name, _ := strings.Cut(key, ":")
+		cmd := Root
+		for _, sub := range strings.Fields(path) {
+			cmd = cmd.Subcommands[sub]
+			if cmd == nil {
+				t.Fatalf("%s: unknown command 'ipfs %s'", key, path)
+			}
+		}
+
+		found := false
+		for _, arg := range cmd.Arguments {
+			found = found || arg.Name == name
+		}
+		for _, opt := range cmd.Options {
+			found = found || opt.Name() == name
+		}
+		if !found {
+			t.Errorf("%s: 'ipfs %s' has no argument or option %s", key, path, name)
+		}
+	}
+}
@@ -7,8 +7,11 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 
+	oldcmds "github.com/ipfs/kubo/commands"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/repo"
 	"github.com/ipfs/kubo/repo/fsrepo"
@@ -90,6 +93,12 @@ Set the value of the 'Datastore.Path' key:
 			return errors.New("cannot show or change pinning services credentials")
 		}
 
+		// The executables of the remote plugins are run by the daemon, they
+		// can only be changed by editing the config file
+		if len(args) == 2 && matchesGlobPrefix(key, config.RemotePluginsSelector) {
+			return errRemotePluginsChange
+		}
+
 		cfgRoot, err := cmdenv.GetConfigRoot(env)
 		if err != nil {
 			return err
@@ -346,25 +355,37 @@ Available profiles:
 	},
 
 	Subcommands: map[string]*cmds.Command{
-		"apply": configProfileApplyCmd,
+		"apply":  configProfileApplyCmd,
+		"export": configProfileExportCmd,
 	},
 }
 
 var configProfileApplyCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Apply profile to config.",
+		ShortDescription: `
+Applies a built-in profile, or a profile exported with 'ipfs config profile
+export' to a file with the '.json' extension. The files are read by the CLI,
+so the daemon must be stopped to apply them.
+`,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(configDryRunOptionName, "print difference between the current config and the config that would be generated"),
 	},
 	Arguments: []cmds.Argument{
-		cmds.StringArg("profile", true, false, "The profile to apply to the config."),
+		cmds.StringArg("profile", true, false, "The profile to apply to the config, or the path of a profile file exported with 'ipfs config profile export'."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
-		profile, ok := config.Profiles[req.Arguments[0]]
-		if !ok {
-			return fmt.Errorf("%s is not a profile", req.Arguments[0])
+		// the RPC API would read the file on the machine of the daemon
+		if strings.HasSuffix(req.Arguments[0], config.CustomProfileExt) && env.(*oldcmds.Context).RPC {
+			return errors.New("profile files can't be applied over the RPC API, stop the daemon to apply them")
 		}
+		profile, err := config.LookupProfile(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		// the backups of the config are named after the profile
+		name := strings.TrimSuffix(filepath.Base(req.Arguments[0]), config.CustomProfileExt)
 
 		dryRun, _ := req.Options[configDryRunOptionName].(bool)
 		cfgRoot, err := cmdenv.GetConfigRoot(env)
@@ -372,7 +393,7 @@ var configProfileApplyCmd = &cmds.Command{
 			return err
 		}
 
-		oldCfg, newCfg, err := transformConfig(cfgRoot, req.Arguments[0], profile.Transform, dryRun)
+		oldCfg, newCfg, err := transformConfig(cfgRoot, name, profile.Transform, dryRun)
 		if err != nil {
 			return err
 		}
@@ -404,6 +425,66 @@ var configProfileApplyCmd = &cmds.Command{
 	Type: ConfigUpdateOutput{},
 }
 
+const configProfileDescriptionOptionName = "description"
+
+var configProfileExportCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export the config as a profile.",
+		ShortDescription: `
+Outputs the settings of the config differing from the defaults as a profile,
+to set up other nodes the same way. The identity, the remote pinning
+services and the users of the RPC API are left out.
+
+The profile is applied by giving the path of its file, which must have the
+'.json' extension, to 'ipfs init --profile' or, with the daemon stopped,
+'ipfs config profile apply':
+
+  $ ipfs config profile export fleet > fleet.json
+  $ ipfs init --profile=fleet.json
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(configProfileDescriptionOptionName, "The description of the profile."),
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "The name of the profile."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cfgRoot, err := cmdenv.GetConfigRoot(env)
+		if err != nil {
+			return err
+		}
+		r, err := fsrepo.Open(cfgRoot)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		cfg, err := r.Config()
+		if err != nil {
+			return err
+		}
+
+		description, _ := req.Options[configProfileDescriptionOptionName].(string)
+		profile, err := config.ExportProfile(cfg, req.Arguments[0], description)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, profile)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *config.CustomProfile) error {
+			buf, err := config.Marshal(out)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, byte('\n'))
+			_, err = w.Write(buf)
+			return err
+		}),
+	},
+	Type: config.CustomProfile{},
+}
+
 func buildProfileHelp() string {
 	var out string
 
@@ -461,6 +542,10 @@ func transformConfig(configRoot string, configName string, transformer config.Tr
 	if err != nil {
 		return nil, nil, err
 	}
+	// custom profiles could set the executables of the remote plugins
+	if !sameRemotePlugins(oldCfg.Plugins.Remote, newCfg.Plugins.Remote) {
+		return nil, nil, errRemotePluginsChange
+	}
 
 	if !dryRun {
 		_, err = r.BackupConfig("pre-" + configName + "-")
@@ -531,6 +616,16 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 
 	newCfg.Identity.PrivKey = pkstr
 
+	// Handle Plugins.Remote (executables run by the daemon)
+
+	oldCfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	if !sameRemotePlugins(oldCfg.Plugins.Remote, newCfg.Plugins.Remote) {
+		return errRemotePluginsChange
+	}
+
 	// Handle Pinning.RemoteServices (API.Key of each service is a secret)
 
 	newServices := newCfg.Pinning.RemoteServices
@@ -564,6 +659,22 @@ func replaceConfig(r repo.Repo, file io.Reader) error {
 	return r.SetConfig(&newCfg)
 }
 
+var errRemotePluginsChange = errors.New("cannot change remote plugins through API, edit the config file with 'ipfs config edit'")
+
+// sameRemotePlugins returns whether the remote plugins a and b are the same.
+func sameRemotePlugins(a, b map[string]config.RemotePlugin) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, pa := range a {
+		pb, ok := b[name]
+		if !ok || !reflect.DeepEqual(pa, pb) {
+			return false
+		}
+	}
+	return true
+}
+
 func getRemotePinningServices(r repo.Repo) (map[string]config.RemotePinningService, error) {
 	var oldServices map[string]config.RemotePinningService
 	if remoteServicesTag, err := getConfig(r, config.RemoteServicesPath); err == nil {
@@ -1,6 +1,10 @@
 package commands
 
-import "testing"
+import (
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+)
 
 func TestScrubMapInternalDelete(t *testing.T) {
 	m, err := scrubMapInternal(nil, nil, true)
@@ -15,3 +19,34 @@ func TestScrubMapInternalDelete(t *testing.T) {
 
 	}
 }
+
+func TestRemotePluginsChange(t *testing.T) {
+	for key, blocked := range map[string]bool{
+		"Plugins":                    true,
+		"plugins.remote":             true,
+		"Plugins.Remote.s3ds.Path":   true,
+		"Plugins.Plugins.s3ds":       false,
+		"Plugins.Plugins.ens.Config": false,
+		"Addresses.API":              false,
+	} {
+		if got := matchesGlobPrefix(key, config.RemotePluginsSelector); got != blocked {
+			t.Errorf("%s: expected blocked %t, got %t", key, blocked, got)
+		}
+	}
+
+	s3ds := config.RemotePlugin{Type: config.RemotePluginDatastore, Path: "/usr/local/bin/kubo-s3ds"}
+	if !sameRemotePlugins(nil, map[string]config.RemotePlugin{}) {
+		t.Error("expected no plugins to be the same")
+	}
+	if !sameRemotePlugins(map[string]config.RemotePlugin{"s3ds": s3ds}, map[string]config.RemotePlugin{"s3ds": s3ds}) {
+		t.Error("expected the same plugins to be the same")
+	}
+	evil := s3ds
+	evil.Path = "/bin/sh"
+	if sameRemotePlugins(map[string]config.RemotePlugin{"s3ds": s3ds}, map[string]config.RemotePlugin{"s3ds": evil}) {
+		t.Error("expected a changed Path to be a change")
+	}
+	if sameRemotePlugins(nil, map[string]config.RemotePlugin{"s3ds": s3ds}) {
+		t.Error("expected an added plugin to be a change")
+	}
+}
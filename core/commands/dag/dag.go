@@ -6,7 +6,9 @@ import (
 
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/core/dagdiff"
 
+	humanize "github.com/dustin/go-humanize"
 	cid "github.com/ipfs/go-cid"
 	cidenc "github.com/ipfs/go-cidutil/cidenc"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -21,6 +23,7 @@ const (
 	progressOptionName = "progress"
 	silentOptionName   = "silent"
 	statsOptionName    = "stats"
+	verifyOptionName   = "verify"
 )
 
 // DagCmd provides a subset of commands for interacting with ipld dag objects
@@ -41,6 +44,8 @@ the existing 'ipfs object' command moving forward.
 		"import":  DagImportCmd,
 		"export":  DagExportCmd,
 		"stat":    DagStatCmd,
+		"diff":    DagDiffCmd,
+		"proof":   DagProofCmd,
 	},
 }
 
@@ -60,10 +65,20 @@ type CarImportStats struct {
 	BlockBytesCount uint64
 }
 
+// CarImportProgress reports the progress of importing a single .car file
+type CarImportProgress struct {
+	Index           int // position of the file among the imported files
+	Name            string
+	BlockCount      uint64
+	BlockBytesCount uint64
+	Done            bool
+}
+
 // CarImportOutput is the output type of the 'dag import' commands
 type CarImportOutput struct {
-	Root  *RootMeta       `json:",omitempty"`
-	Stats *CarImportStats `json:",omitempty"`
+	Root     *RootMeta          `json:",omitempty"`
+	Stats    *CarImportStats    `json:",omitempty"`
+	Progress *CarImportProgress `json:",omitempty"`
 }
 
 // RootMeta is the metadata for a root pinning response
@@ -88,7 +103,7 @@ into an object of the specified format.
 		cmds.StringOption("store-codec", "Codec that the stored object will be encoded with").WithDefault("dag-cbor"),
 		cmds.StringOption("input-codec", "Codec that the input object is encoded in").WithDefault("dag-json"),
 		cmds.BoolOption("pin", "Pin this object when adding."),
-		cmds.StringOption("hash", "Hash function to use").WithDefault("sha2-256"),
+		cmds.StringOption("hash", "Hash function to use, e.g. sha2-256 or blake3").WithDefault("sha2-256"),
 		cmdutils.AllowBigBlockOption,
 	},
 	Run:  dagPut,
@@ -197,6 +212,15 @@ Note:
   currently present in the blockstore does not represent a complete DAG,
   pinning of that individual root will fail.
 
+  The daemon may override --pin-roots with the DagImport.PinRoots policy.
+
+  Blocks are committed after each CAR file, so that when importing several
+  files, the files fully received before a failure do not need to be sent
+  again. Use --progress to follow the import of each file.
+
+  Blocks are verified against their CIDs as they are read, unless --verify
+  is set to false for trusted .car files.
+
 Maximum supported CAR version: 2
 Specification of CAR formats: https://ipld.io/specs/transport/car/
 `,
@@ -208,6 +232,8 @@ Specification of CAR formats: https://ipld.io/specs/transport/car/
 		cmds.BoolOption(pinRootsOptionName, "Pin optional roots listed in the .car headers after importing.").WithDefault(true),
 		cmds.BoolOption(silentOptionName, "No output."),
 		cmds.BoolOption(statsOptionName, "Output stats."),
+		cmds.BoolOption(progressOptionName, "p", "Output progress of each .car file."),
+		cmds.BoolOption(verifyOptionName, "Verify that the blocks match their CIDs while importing.").WithDefault(true),
 		cmdutils.AllowBigBlockOption,
 	},
 	Type: CarImportOutput{},
@@ -220,6 +246,18 @@ Specification of CAR formats: https://ipld.io/specs/transport/car/
 				return nil
 			}
 
+			if event.Progress != nil {
+				if event.Root != nil || event.Stats != nil {
+					return fmt.Errorf("unexpected message from DAG import")
+				}
+				state := "Importing"
+				if event.Progress.Done {
+					state = "Imported"
+				}
+				_, err := fmt.Fprintf(w, "%s %s: %d blocks (%d bytes)\n", state, event.Progress.Name, event.Progress.BlockCount, event.Progress.BlockBytesCount)
+				return err
+			}
+
 			// event should have only one of `Root` or `Stats` set, not both
 			if event.Root == nil {
 				if event.Stats == nil {
@@ -324,3 +362,95 @@ Note: This command skips duplicate blocks in reporting both size and the number
 		}),
 	},
 }
+
+// DagDiffChange is a path changed between the DAGs of 'dag diff'
+type DagDiffChange struct {
+	Type   dagdiff.ChangeType
+	Path   string
+	Before string `json:",omitempty"`
+	After  string `json:",omitempty"`
+}
+
+// DagDiffOutput is the output type of 'dag diff' command
+type DagDiffOutput struct {
+	Changes []DagDiffChange
+	Blocks  dagdiff.Blocks
+}
+
+// DagDiffCmd is a command for comparing two dags
+var DagDiffCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the paths changed between two DAGs.",
+		ShortDescription: `
+'ipfs dag diff' compares two DAGs, e.g. two versions of a website, and lists
+the paths added, removed and modified in the second one, and the blocks and
+bytes only in the changed parts of each DAG.
+
+UnixFS directories, sharded or not, are compared entry by entry, and other
+nodes with links, like dag-cbor, link by link. A path whose content changed is
+listed as modified, as is a directory whose entries didn't change but whose
+metadata did. The subtrees found in both DAGs are not fetched.
+
+  > ipfs dag diff /ipfs/<site-v1> /ipfs/<site-v2>
+  M index.html
+  + blog/new-post.html
+  - old.html
+  blocks: +3 (12 kB) -2 (8.1 kB)
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("before", true, false, "Path of the first DAG."),
+		cmds.StringArg("after", true, false, "Path of the second DAG."),
+	},
+	Run:  dagDiff,
+	Type: DagDiffOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DagDiffOutput) error {
+			for _, c := range out.Changes {
+				var mark string
+				switch c.Type {
+				case dagdiff.Added:
+					mark = "+"
+				case dagdiff.Removed:
+					mark = "-"
+				default:
+					mark = "M"
+				}
+				p := c.Path
+				if p == "" {
+					p = "/"
+				}
+				fmt.Fprintf(w, "%s %s\n", mark, p)
+			}
+			b := out.Blocks
+			_, err := fmt.Fprintf(w, "blocks: +%d (%s) -%d (%s)\n", b.Added, humanize.Bytes(b.AddedBytes), b.Removed, humanize.Bytes(b.RemovedBytes))
+			return err
+		}),
+	},
+}
+
+// DagProofCmd is a command for exporting the inclusion proof of a path
+var DagProofCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Streams the inclusion proof of a path in a DAG as a .car stream on stdout.",
+		ShortDescription: `
+'ipfs dag proof' streams out the minimal set of blocks resolving a path from a
+root as a .car file whose root is the root of the DAG. Downstream systems can
+check with the proof alone that the path belongs to the root, and which CID
+it resolves to, e.g. with 'ipfs car verify':
+
+  > ipfs dag proof bafyroot... docs/report.pdf > proof.car
+  > ipfs car verify proof.car --path=docs/report.pdf
+  docs/report.pdf resolves to bafyreport...
+
+The blocks of UnixFS directories, sharded or not, and of other IPLD nodes
+traversed by the path are in the proof, but not the block the path resolves
+to.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "CID of the root of the DAG."),
+		cmds.StringArg("path", true, false, "Path to prove, relative to the root."),
+	},
+	Run: dagProof,
+}
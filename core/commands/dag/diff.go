@@ -0,0 +1,55 @@
+package dagcmd
+
+import (
+	"fmt"
+
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dagdiff"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	mdag "github.com/ipfs/go-merkledag"
+)
+
+func dagDiff(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+	enc, err := cmdenv.GetCidEncoder(req)
+	if err != nil {
+		return err
+	}
+
+	var roots [2]cid.Cid
+	for i, p := range req.Arguments {
+		rp, err := api.ResolvePath(req.Context, path.New(p))
+		if err != nil {
+			return err
+		}
+		if len(rp.Remainder()) > 0 {
+			return fmt.Errorf("cannot diff anything other than DAGs with a root CID")
+		}
+		roots[i] = rp.Cid()
+	}
+
+	dag := mdag.NewReadOnlyDagService(mdag.NewSession(req.Context, api.Dag()))
+	diff, err := dagdiff.Diff(req.Context, dag, roots[0], roots[1])
+	if err != nil {
+		return fmt.Errorf("error comparing DAGs: %w", err)
+	}
+
+	out := &DagDiffOutput{Changes: []DagDiffChange{}, Blocks: diff.Blocks}
+	for _, c := range diff.Changes {
+		change := DagDiffChange{Type: c.Type, Path: c.Path}
+		if c.Before.Defined() {
+			change.Before = enc.Encode(c.Before)
+		}
+		if c.After.Defined() {
+			change.After = enc.Encode(c.After)
+		}
+		out.Changes = append(out.Changes, change)
+	}
+	return cmds.EmitOnce(res, out)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/cmdutils"
+	"github.com/ipfs/kubo/thirdparty/verifbs"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	gocarv2 "github.com/ipld/go-car/v2"
@@ -47,7 +48,16 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 	unlocker := node.Blockstore.PinLock(req.Context)
 	defer unlocker.Unlock(req.Context)
 
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+
 	doPinRoots, _ := req.Options[pinRootsOptionName].(bool)
+	doPinRoots, err = cfg.DagImport.ResolvePinRoots(doPinRoots)
+	if err != nil {
+		return err
+	}
 
 	retCh := make(chan importResult, 1)
 	go importWorker(req, res, api, retCh)
@@ -133,18 +143,20 @@ func dagImport(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment
 	return nil
 }
 
-func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI, ret chan importResult) {
+// progressBytesInterval is the amount of imported data between two progress
+// events of the same .car file.
+const progressBytesInterval = 4 << 20
 
-	// this is *not* a transaction
-	// it is simply a way to relieve pressure on the blockstore
-	// similar to pinner.Pin/pinner.Flush
-	batch := ipld.NewBatch(req.Context, api.Dag())
+func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI, ret chan importResult) {
 
 	roots := make(map[cid.Cid]struct{})
 	var blockCount, blockBytesCount uint64
 
+	progress, _ := req.Options[progressOptionName].(bool)
+	verify, _ := req.Options[verifyOptionName].(bool)
+
 	it := req.Files.Entries()
-	for it.Next() {
+	for index := 0; it.Next(); index++ {
 
 		file := files.FileFromEntry(it)
 		if file == nil {
@@ -161,11 +173,21 @@ func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI,
 		err := func() error {
 			defer file.Close()
 
+			// this is *not* a transaction
+			// it is simply a way to relieve pressure on the blockstore
+			// similar to pinner.Pin/pinner.Flush
+			// it is committed after every file, so that files imported
+			// before a failure do not have to be sent again
+			batch := ipld.NewBatch(req.Context, api.Dag())
+
 			car, err := gocarv2.NewBlockReader(file)
 			if err != nil {
 				return err
 			}
 
+			p := CarImportProgress{Index: index, Name: it.Name()}
+			var lastEmitted uint64
+
 			for _, c := range car.Roots {
 				roots[c] = struct{}{}
 			}
@@ -180,6 +202,11 @@ func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI,
 				if err := cmdutils.CheckBlockSize(req, uint64(len(block.RawData()))); err != nil {
 					return err
 				}
+				if verify {
+					if err := verifbs.VerifyBlock(block); err != nil {
+						return fmt.Errorf("block %s: %w", block.Cid(), err)
+					}
+				}
 
 				// the double-decode is suboptimal, but we need it for batching
 				nd, err := ipldlegacy.DecodeNode(req.Context, block)
@@ -192,8 +219,27 @@ func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI,
 				}
 				blockCount++
 				blockBytesCount += uint64(len(block.RawData()))
+				p.BlockCount++
+				p.BlockBytesCount += uint64(len(block.RawData()))
+
+				if progress && p.BlockBytesCount-lastEmitted >= progressBytesInterval {
+					lastEmitted = p.BlockBytesCount
+					// emit a copy, p keeps changing while the event is encoded
+					pc := p
+					if err := re.Emit(&CarImportOutput{Progress: &pc}); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := batch.Commit(); err != nil {
+				return err
 			}
 
+			if progress {
+				p.Done = true
+				return re.Emit(&CarImportOutput{Progress: &p})
+			}
 			return nil
 		}()
 
@@ -208,11 +254,6 @@ func importWorker(req *cmds.Request, re cmds.ResponseEmitter, api iface.CoreAPI,
 		return
 	}
 
-	if err := batch.Commit(); err != nil {
-		ret <- importResult{err: err}
-		return
-	}
-
 	ret <- importResult{
 		blockCount:      blockCount,
 		blockBytesCount: blockBytesCount,
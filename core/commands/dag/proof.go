@@ -0,0 +1,52 @@
+package dagcmd
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dagproof"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+func dagProof(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	node, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	root, err := cid.Decode(req.Arguments[0])
+	if err != nil {
+		return fmt.Errorf("invalid root: %w", err)
+	}
+	p, err := dagproof.Path(root, req.Arguments[1])
+	if err != nil {
+		return err
+	}
+
+	// fetch the blocks of the path, the proof is then made of the blocks
+	// of the blockstore
+	if _, err := api.Dag().Get(req.Context, root); err != nil {
+		return err
+	}
+	if _, err := api.ResolvePath(req.Context, path.New(p.String())); err != nil {
+		return err
+	}
+	proof, err := dagproof.Prove(req.Context, node.Blockstore, p)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := proof.WriteCar(&buf); err != nil {
+		return err
+	}
+	return res.Emit(&buf)
+}
@@ -13,5 +13,12 @@ var DiagCmd = &cmds.Command{
 		"sys":     sysDiagCmd,
 		"cmds":    ActiveReqsCmd,
 		"profile": sysProfileCmd,
+		"nat":     diagNatCmd,
+		"netstat": diagNetstatCmd,
+		"shape":   diagShapeCmd,
+		"dedup":   diagDedupCmd,
+		"errors":  diagErrorsCmd,
+
+		"dag-providers": diagDagProvidersCmd,
 	},
 }
@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+const (
+	dagProvidersMaxBlocksOptionName = "max-blocks"
+	dagProvidersSamplesOptionName   = "samples"
+	dagProvidersTimeoutOptionName   = "block-timeout"
+)
+
+// DagProvidersBlock is the availability of a sampled block among the
+// connected peers.
+type DagProvidersBlock struct {
+	Cid string
+	// peers that have the block
+	Have []string
+	// peers that don't have the block
+	DontHave []string
+	// number of peers that didn't answer
+	NoAnswer int
+}
+
+// DagProvidersReport is the output of 'ipfs diag dag-providers'.
+type DagProvidersReport struct {
+	Root string
+	// peers advertising the root in the routing system
+	Providers []string
+	// number of blocks walked
+	Walked int
+	// blocks that couldn't be retrieved, their children weren't walked
+	Missing []string `json:",omitempty"`
+	// number of connected peers asked
+	Peers  int
+	Blocks []DagProvidersBlock
+}
+
+var diagDagProvidersCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report which peers have the blocks of a DAG.",
+		ShortDescription: `
+'ipfs diag dag-providers' walks the DAG under the given path and reports:
+
+  - the peers advertising the root in the routing system
+  - the blocks that couldn't be retrieved
+  - for a sample of the blocks walked, which connected peers have them and
+    which don't, asked with bitswap WANT-HAVE requests
+
+which helps debugging partially retrievable content. The report is printed
+as JSON.
+
+The walk retrieves the blocks of the DAG, which may fetch them from the
+network. It stops after --max-blocks blocks, and gives up on a block after
+--block-timeout; the same timeout applies to the answers of the peers.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, false, "The path of the root of the DAG.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(dagProvidersMaxBlocksOptionName, "Maximum number of blocks to walk.").WithDefault(1000),
+		cmds.IntOption(dagProvidersSamplesOptionName, "Number of walked blocks to ask the connected peers about.").WithDefault(100),
+		cmds.StringOption(dagProvidersTimeoutOptionName, "Timeout to retrieve a block, and for the peers to answer.").WithDefault("10s"),
+		cmds.IntOption(numProvidersOptionName, "n", "The number of providers of the root to find.").WithDefault(20),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.HaveProber == nil {
+			return fmt.Errorf("bitswap is not running")
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		maxBlocks, _ := req.Options[dagProvidersMaxBlocksOptionName].(int)
+		samples, _ := req.Options[dagProvidersSamplesOptionName].(int)
+		numProviders, _ := req.Options[numProvidersOptionName].(int)
+		if maxBlocks < 1 || samples < 1 || numProviders < 1 {
+			return fmt.Errorf("--%s, --%s and --%s must be positive", dagProvidersMaxBlocksOptionName, dagProvidersSamplesOptionName, numProvidersOptionName)
+		}
+		timeoutStr, _ := req.Options[dagProvidersTimeoutOptionName].(string)
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return err
+		}
+
+		rp, err := api.ResolvePath(req.Context, path.New(req.Arguments[0]))
+		if err != nil {
+			return err
+		}
+		root := rp.Cid()
+		report := DagProvidersReport{Root: root.String()}
+
+		// the providers are looked up during the walk
+		provsCh := make(chan []string, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(req.Context, time.Minute)
+			defer cancel()
+			var provs []string
+			for p := range nd.Routing.FindProvidersAsync(ctx, root, numProviders) {
+				provs = append(provs, p.ID.String())
+			}
+			provsCh <- provs
+		}()
+
+		// walk the DAG breadth first
+		var walked []cid.Cid
+		seen := cid.NewSet()
+		seen.Add(root)
+		queue := []cid.Cid{root}
+		for len(queue) > 0 && len(walked) < maxBlocks {
+			c := queue[0]
+			queue = queue[1:]
+			ctx, cancel := context.WithTimeout(req.Context, timeout)
+			n, err := nd.DAG.Get(ctx, c)
+			cancel()
+			if err != nil {
+				if req.Context.Err() != nil {
+					return req.Context.Err()
+				}
+				report.Missing = append(report.Missing, c.String())
+				continue
+			}
+			walked = append(walked, c)
+			for _, l := range n.Links() {
+				if seen.Visit(l.Cid) {
+					queue = append(queue, l.Cid)
+				}
+			}
+		}
+		report.Walked = len(walked)
+
+		// sample the walked blocks evenly, the root first
+		var sampled []cid.Cid
+		step := float64(len(walked)) / float64(samples)
+		if step < 1 {
+			step = 1
+		}
+		for i := 0.0; int(i) < len(walked); i += step {
+			sampled = append(sampled, walked[int(i)])
+		}
+
+		peers := nd.PeerHost.Network().Peers()
+		report.Peers = len(peers)
+		ctx, cancel := context.WithTimeout(req.Context, timeout)
+		answers := nd.HaveProber.Probe(ctx, peers, sampled)
+		cancel()
+		for _, c := range sampled {
+			b := DagProvidersBlock{Cid: c.String(), Have: []string{}, DontHave: []string{}}
+			for _, p := range peers {
+				have, ok := answers[c][p]
+				switch {
+				case !ok:
+					b.NoAnswer++
+				case have:
+					b.Have = append(b.Have, p.String())
+				default:
+					b.DontHave = append(b.DontHave, p.String())
+				}
+			}
+			sort.Strings(b.Have)
+			sort.Strings(b.DontHave)
+			report.Blocks = append(report.Blocks, b)
+		}
+
+		report.Providers = <-provsCh
+		if report.Providers == nil {
+			report.Providers = []string{}
+		}
+		return cmds.EmitOnce(res, &report)
+	},
+	Type: DagProvidersReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DagProvidersReport) error {
+			marshaled, err := json.MarshalIndent(out, "", "\t")
+			if err != nil {
+				return err
+			}
+			marshaled = append(marshaled, byte('\n'))
+			_, err = w.Write(marshaled)
+			return err
+		}),
+	},
+}
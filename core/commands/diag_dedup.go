@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	mh "github.com/multiformats/go-multihash"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/coreunix"
+)
+
+// defaultDedupChunkers are the chunkers compared by 'ipfs diag dedup' when
+// none is given.
+var defaultDedupChunkers = []string{"size-262144", "size-1048576", "rabin", "buzhash"}
+
+// DedupReport is the output of 'ipfs diag dedup'.
+type DedupReport struct {
+	Files    uint64
+	Size     uint64 // of the files
+	Chunkers []coreunix.DedupStats
+}
+
+var diagDedupCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Report how much of some files would be deduplicated by 'ipfs add'.",
+		ShortDescription: `
+'ipfs diag dedup' imports files with several chunkers without storing them,
+and reports for each chunker how many bytes of blocks would be new, already in
+the repo, or repeated within the files. It helps picking the chunker of
+backup workloads, where the same files are added again after small changes.
+
+The chunkers default to size-262144, size-1048576, rabin and buzhash, and are
+given in the format of 'ipfs add --chunker':
+
+  > ipfs diag dedup -r ./backup --chunker=size-262144 --chunker=buzhash
+
+The hashes of the blocks are kept in memory while the files are analyzed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("path", true, true, "The path to the files to analyze.").EnableRecursive().EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringsOption(chunkerOptionName, "s", "Chunking algorithm to compare, size-[bytes], rabin-[min]-[avg]-[max] or buzhash. Can be given multiple times."),
+		cmds.BoolOption(rawLeavesOptionName, "Use raw blocks for leaf nodes."),
+		cmds.IntOption(cidVersionOptionName, "CID version. Defaults to 0 unless an option that depends on CIDv1 is passed. Passing version 1 will cause the raw-leaves option to default to true."),
+		cmds.StringOption(hashOptionName, "Hash function to use, e.g. sha2-256 or blake3. Implies CIDv1 if not sha2-256.").WithDefault(defaultAddHash),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		chunkers, _ := req.Options[chunkerOptionName].([]string)
+		if len(chunkers) == 0 {
+			chunkers = defaultDedupChunkers
+		}
+		hashFunStr, _ := req.Options[hashOptionName].(string)
+		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
+		if !ok {
+			return fmt.Errorf("unrecognized hash function: %q", strings.ToLower(hashFunStr))
+		}
+
+		// the CID settings of 'ipfs add', so that the blocks of files added
+		// with the same options are recognized
+		opts := []options.UnixfsAddOption{options.Unixfs.Hash(hashFunCode)}
+		if cidVer, ok := req.Options[cidVersionOptionName].(int); ok {
+			opts = append(opts, options.Unixfs.CidVersion(cidVer))
+		}
+		if rawblks, ok := req.Options[rawLeavesOptionName].(bool); ok {
+			opts = append(opts, options.Unixfs.RawLeaves(rawblks))
+		}
+		settings, prefix, err := options.UnixfsAddOptions(opts...)
+		if err != nil {
+			return err
+		}
+
+		a, err := coreunix.NewDedupAnalyzer(nd.Blockstore, chunkers, settings.RawLeaves, prefix)
+		if err != nil {
+			return err
+		}
+
+		var count uint64
+		it := req.Files.Entries()
+		for it.Next() {
+			err := files.Walk(it.Node(), func(fpath string, n files.Node) error {
+				f, ok := n.(files.File)
+				if !ok {
+					return nil
+				}
+				count++
+				return a.AddFile(req.Context, f)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if it.Err() != nil {
+			return it.Err()
+		}
+		if count == 0 {
+			return errors.New("no file to analyze")
+		}
+
+		return cmds.EmitOnce(res, &DedupReport{
+			Files:    count,
+			Size:     a.Input,
+			Chunkers: a.Stats(),
+		})
+	},
+	Type: DedupReport{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DedupReport) error {
+			fmt.Fprintf(w, "%d files, %s\n\n", out.Files, humanize.IBytes(out.Size))
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "CHUNKER\tBLOCKS\tNEW\tIN REPO\tREPEATED\tDEDUPLICATED")
+			for _, s := range out.Chunkers {
+				dedup := 0.0
+				if s.Size > 0 {
+					dedup = float64(s.InRepo+s.Repeated) / float64(s.Size) * 100
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%.1f%%\n", s.Chunker, s.Blocks,
+					humanize.IBytes(s.New), humanize.IBytes(s.InRepo), humanize.IBytes(s.Repeated), dedup)
+			}
+			return tw.Flush()
+		}),
+	},
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/logstats"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const diagErrorsSubsystemOptionName = "subsystem"
+
+// DiagErrors is the output of 'ipfs diag errors'.
+type DiagErrors struct {
+	// Windows are the windows of the counts of the subsystems, e.g. "1h0m0s".
+	Windows    []string
+	Subsystems []logstats.SubsystemStats
+}
+
+var diagErrorsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Summarize the warnings and errors logged by each subsystem.",
+		ShortDescription: `
+'ipfs diag errors' shows the warnings and the errors logged by the
+subsystems of the daemon over the last 5 minutes, hour and day, and the last
+error of each subsystem, so that an unhealthy subsystem stands out without
+going through the logs. The subsystems are sorted by decreasing errors over
+the last hour.
+
+Only the events of the levels enabled for their subsystem are counted: the
+warnings aren't, unless the level of the subsystem is 'warn' or more verbose,
+see 'ipfs log level'. The same counts are exported as the
+ipfs_log_events_total metric.
+`,
+	},
+	NoLocal: true,
+	Options: []cmds.Option{
+		cmds.StringOption(diagErrorsSubsystemOptionName, "s", "Only show this subsystem."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.LogStats == nil {
+			return errors.New("the log events are only counted by the daemon")
+		}
+		subsystem, _ := req.Options[diagErrorsSubsystemOptionName].(string)
+
+		out := DiagErrors{Windows: make([]string, len(logstats.Windows))}
+		for i, w := range logstats.Windows {
+			out.Windows[i] = w.String()
+		}
+		for _, s := range nd.LogStats.Snapshot(time.Now()) {
+			if subsystem != "" && s.Subsystem != subsystem {
+				continue
+			}
+			out.Subsystems = append(out.Subsystems, s)
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: DiagErrors{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *DiagErrors) error {
+			windows := make([]string, len(out.Windows))
+			for i, s := range out.Windows {
+				windows[i] = shortDuration(s)
+			}
+
+			// the summary of the last hour, the second window
+			var errs, failing int
+			for _, s := range out.Subsystems {
+				if len(s.Errors.Windows) > 1 && s.Errors.Windows[1] > 0 {
+					errs += int(s.Errors.Windows[1])
+					failing++
+				}
+			}
+			if errs == 0 {
+				fmt.Fprintln(w, "no errors logged in the last hour")
+			} else {
+				fmt.Fprintf(w, "%d errors logged in the last hour by %d subsystems\n", errs, failing)
+			}
+			if len(out.Subsystems) == 0 {
+				return nil
+			}
+			fmt.Fprintln(w)
+
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintf(tw, "SUBSYSTEM\tERRORS (%s)\tWARNINGS (%s)\tLAST ERROR\n", strings.Join(windows, "/"), strings.Join(windows, "/"))
+			for _, s := range out.Subsystems {
+				last := ""
+				if s.LastError != "" {
+					last = fmt.Sprintf("%s %s", s.LastErrorTime.Format(time.RFC3339), s.LastError)
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Subsystem, joinCounts(s.Errors.Windows), joinCounts(s.Warnings.Windows), last)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+func joinCounts(counts []uint64) string {
+	s := make([]string, len(counts))
+	for i, n := range counts {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, "/")
+}
+
+// shortDuration trims the zero units of a duration, e.g. "1h0m0s" to "1h".
+func shortDuration(s string) string {
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
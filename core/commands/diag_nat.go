@@ -0,0 +1,246 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// NATTransport reports the reachability of one transport.
+type NATTransport struct {
+	Transport     string
+	ListenAddrs   []string `json:",omitempty"`
+	PublicAddrs   []string `json:",omitempty"`
+	InboundConns  int
+	OutboundConns int
+}
+
+// NATHolePunching reports the outcome of hole punching since the node started.
+type NATHolePunching struct {
+	Enabled bool
+	libp2p.HolePunchCounters
+}
+
+// NATDiagnostics is the output of 'ipfs diag nat'.
+type NATDiagnostics struct {
+	Reachability      string
+	Transports        []NATTransport
+	RelayReservations []string `json:",omitempty"`
+	HolePunching      NATHolePunching
+	Suggestions       []string `json:",omitempty"`
+}
+
+var diagNatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Diagnose why the node is or isn't dialable.",
+		ShortDescription: `
+'ipfs diag nat' reports what the node knows about its reachability from the
+public internet:
+
+  - the reachability determined by AutoNAT (public, private or unknown)
+  - per transport, the listen addresses, the public addresses announced to
+    other peers (observed or mapped through UPnP/NAT-PMP), and the current
+    inbound and outbound connections; inbound connections over a transport
+    show that it is reachable
+  - the relays holding a reservation for the node
+  - the outcome of hole punching attempts since the node started
+
+along with suggestions, such as ports to forward, when the node looks
+undialable.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		cfg, err := nd.Repo.Config()
+		if err != nil {
+			return err
+		}
+
+		h := nd.PeerHost
+		out := NATDiagnostics{
+			Reachability: currentReachability(h).String(),
+		}
+
+		transports := make(map[string]*NATTransport)
+		transport := func(a ma.Multiaddr) *NATTransport {
+			name := libp2p.TransportName(a)
+			t, ok := transports[name]
+			if !ok {
+				t = &NATTransport{Transport: name}
+				transports[name] = t
+			}
+			return t
+		}
+		for _, a := range h.Network().ListenAddresses() {
+			t := transport(a)
+			t.ListenAddrs = append(t.ListenAddrs, a.String())
+		}
+		relays := make(map[peer.ID]struct{})
+		for _, a := range h.Addrs() {
+			if _, err := a.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+				// /<relay addr>/p2p/<relay id>/p2p-circuit
+				if id, err := a.ValueForProtocol(ma.P_P2P); err == nil {
+					if pid, err := peer.Decode(id); err == nil {
+						relays[pid] = struct{}{}
+					}
+				}
+				continue
+			}
+			if manet.IsPublicAddr(a) {
+				t := transport(a)
+				t.PublicAddrs = append(t.PublicAddrs, a.String())
+			}
+		}
+		var inboundDirect int
+		for _, c := range h.Network().Conns() {
+			t := transport(c.RemoteMultiaddr())
+			if c.Stat().Direction == network.DirInbound {
+				t.InboundConns++
+				if t.Transport != "relay" {
+					inboundDirect++
+				}
+			} else {
+				t.OutboundConns++
+			}
+		}
+		for _, t := range transports {
+			out.Transports = append(out.Transports, *t)
+		}
+		sort.Slice(out.Transports, func(i, j int) bool {
+			return out.Transports[i].Transport < out.Transports[j].Transport
+		})
+		for pid := range relays {
+			out.RelayReservations = append(out.RelayReservations, pid.String())
+		}
+		sort.Strings(out.RelayReservations)
+
+		if nd.HolePunching != nil {
+			out.HolePunching = NATHolePunching{Enabled: true, HolePunchCounters: nd.HolePunching.Counters()}
+		}
+
+		if out.Reachability != network.ReachabilityPublic.String() && inboundDirect == 0 {
+			if ports := listenPorts(h); len(ports) > 0 {
+				out.Suggestions = append(out.Suggestions, fmt.Sprintf("forward %s to this host on your router", strings.Join(ports, ", ")))
+			}
+			if cfg.Swarm.DisableNatPortMap {
+				out.Suggestions = append(out.Suggestions, "set Swarm.DisableNatPortMap to false, so that ports can be forwarded automatically with UPnP or NAT-PMP")
+			}
+			if len(relays) == 0 && !cfg.Swarm.RelayClient.Enabled.WithDefault(true) {
+				out.Suggestions = append(out.Suggestions, "enable Swarm.RelayClient, so that peers can reach this node through relays")
+			}
+			if !out.HolePunching.Enabled {
+				out.Suggestions = append(out.Suggestions, "enable Swarm.EnableHolePunching, so that relayed connections can be upgraded to direct ones")
+			}
+		}
+		if hp := out.HolePunching; hp.HolePunches >= 10 && hp.HolePunchSuccesses*2 < hp.HolePunches {
+			out.Suggestions = append(out.Suggestions, "most hole punching attempts fail, which is common behind symmetric NATs; forwarding ports is the only reliable option")
+		}
+
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: NATDiagnostics{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NATDiagnostics) error {
+			fmt.Fprintf(w, "Reachability: %s\n", out.Reachability)
+			for _, t := range out.Transports {
+				fmt.Fprintf(w, "Transport %s: %d inbound, %d outbound connections\n", t.Transport, t.InboundConns, t.OutboundConns)
+				for _, a := range t.ListenAddrs {
+					fmt.Fprintf(w, "  listen: %s\n", a)
+				}
+				for _, a := range t.PublicAddrs {
+					fmt.Fprintf(w, "  public: %s\n", a)
+				}
+			}
+			if len(out.RelayReservations) == 0 {
+				fmt.Fprintln(w, "Relay reservations: none")
+			} else {
+				fmt.Fprintln(w, "Relay reservations:")
+				for _, r := range out.RelayReservations {
+					fmt.Fprintf(w, "  %s\n", r)
+				}
+			}
+			if hp := out.HolePunching; hp.Enabled {
+				fmt.Fprintf(w, "Hole punching: %d/%d direct dials and %d/%d hole punches succeeded\n",
+					hp.DirectDialSuccesses, hp.DirectDials, hp.HolePunchSuccesses, hp.HolePunches)
+				names := make([]string, 0, len(hp.Transports))
+				for name := range hp.Transports {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					tc := hp.Transports[name]
+					fmt.Fprintf(w, "  %s: %d/%d hole punches succeeded\n", name, tc.HolePunchSuccesses, tc.HolePunches)
+				}
+			} else {
+				fmt.Fprintln(w, "Hole punching: disabled")
+			}
+			for _, s := range out.Suggestions {
+				fmt.Fprintf(w, "Suggestion: %s\n", s)
+			}
+			return nil
+		}),
+	},
+}
+
+// currentReachability returns the last reachability determined by AutoNAT.
+func currentReachability(h host.Host) network.Reachability {
+	sub, err := h.EventBus().Subscribe(new(event.EvtLocalReachabilityChanged))
+	if err != nil {
+		return network.ReachabilityUnknown
+	}
+	defer sub.Close()
+
+	// the event is emitted by a stateful emitter: the last one is delivered
+	// on subscription, if any
+	select {
+	case e := <-sub.Out():
+		return e.(event.EvtLocalReachabilityChanged).Reachability
+	case <-time.After(100 * time.Millisecond):
+		return network.ReachabilityUnknown
+	}
+}
+
+// listenPorts returns the TCP and UDP ports the node listens on, outside of
+// the loopback interface.
+func listenPorts(h host.Host) []string {
+	seen := make(map[string]struct{})
+	var ports []string
+	for _, a := range h.Network().ListenAddresses() {
+		if manet.IsIPLoopback(a) {
+			continue
+		}
+		for _, p := range []struct {
+			code int
+			name string
+		}{{ma.P_TCP, "TCP"}, {ma.P_UDP, "UDP"}} {
+			if port, err := a.ValueForProtocol(p.code); err == nil {
+				s := p.name + " port " + port
+				if _, ok := seen[s]; !ok {
+					seen[s] = struct{}{}
+					ports = append(ports, s)
+				}
+			}
+		}
+	}
+	sort.Strings(ports)
+	return ports
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/node/libp2p"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+const (
+	netstatSortOptionName      = "sort"
+	netstatTransportOptionName = "transport"
+	netstatDirectionOptionName = "direction"
+	netstatProtocolOptionName  = "protocol"
+)
+
+// NetstatConn is a connection listed by 'ipfs diag netstat'.
+type NetstatConn struct {
+	Peer      string
+	Addr      string
+	Transport string
+	Direction string
+	Age       time.Duration
+	// Streams is the number of open streams by protocol.
+	Streams map[string]int `json:",omitempty"`
+	// TotalIn and TotalOut are the bytes exchanged with the peer, over all
+	// its connections, since the node started.
+	TotalIn  int64
+	TotalOut int64
+	RateIn   float64
+	RateOut  float64
+}
+
+// NumStreams returns the number of open streams of the connection.
+func (c NetstatConn) NumStreams() int {
+	var n int
+	for _, count := range c.Streams {
+		n += count
+	}
+	return n
+}
+
+// NetstatConns is the output of 'ipfs diag netstat'.
+type NetstatConns struct {
+	Conns []NetstatConn
+}
+
+// netstatLess orders the connections by the value of the sort option.
+var netstatLess = map[string]func(a, b *NetstatConn) bool{
+	"age":     func(a, b *NetstatConn) bool { return a.Age > b.Age },
+	"peer":    func(a, b *NetstatConn) bool { return a.Peer < b.Peer },
+	"streams": func(a, b *NetstatConn) bool { return a.NumStreams() > b.NumStreams() },
+	"in":      func(a, b *NetstatConn) bool { return a.TotalIn > b.TotalIn },
+	"out":     func(a, b *NetstatConn) bool { return a.TotalOut > b.TotalOut },
+}
+
+var diagNetstatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the connections of the node with their streams and traffic.",
+		ShortDescription: `
+'ipfs diag netstat' lists the current libp2p connections, like 'ss' for the
+swarm: the peer and address, the transport, the direction, the age, the open
+streams by protocol, and the traffic with the peer.
+
+libp2p doesn't count the bytes of each connection: the traffic is the total
+of the peer since the node started, over all its connections, and is only
+available when the bandwidth metrics are enabled (Swarm.DisableBandwidthMetrics
+is false).
+
+The connections can be filtered by transport (tcp, quic, websocket,
+webtransport, relay), direction (inbound, outbound) and stream protocol, and
+sorted by age (oldest first), peer, streams, in or out (most first).
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(netstatSortOptionName, "s", "Sort by age, peer, streams, in or out.").WithDefault("age"),
+		cmds.StringOption(netstatTransportOptionName, "t", "Only list the connections over this transport."),
+		cmds.StringOption(netstatDirectionOptionName, "d", "Only list the inbound or outbound connections."),
+		cmds.StringOption(netstatProtocolOptionName, "p", "Only list the connections with a stream of this protocol."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+
+		sortBy, _ := req.Options[netstatSortOptionName].(string)
+		less, ok := netstatLess[sortBy]
+		if !ok {
+			return fmt.Errorf("unknown sort %q, expected age, peer, streams, in or out", sortBy)
+		}
+		transport, _ := req.Options[netstatTransportOptionName].(string)
+		protocol, _ := req.Options[netstatProtocolOptionName].(string)
+		direction, _ := req.Options[netstatDirectionOptionName].(string)
+		switch direction {
+		case "", "inbound", "outbound":
+		default:
+			return fmt.Errorf("unknown direction %q, expected inbound or outbound", direction)
+		}
+
+		now := time.Now()
+		var out NetstatConns
+		for _, c := range nd.PeerHost.Network().Conns() {
+			stat := c.Stat()
+			nc := NetstatConn{
+				Peer:      c.RemotePeer().String(),
+				Addr:      c.RemoteMultiaddr().String(),
+				Transport: libp2p.TransportName(c.RemoteMultiaddr()),
+				Direction: directionString(stat.Direction),
+				Age:       now.Sub(stat.Opened).Truncate(time.Second),
+			}
+			if transport != "" && nc.Transport != transport {
+				continue
+			}
+			if direction != "" && nc.Direction != direction {
+				continue
+			}
+			for _, s := range c.GetStreams() {
+				if nc.Streams == nil {
+					nc.Streams = make(map[string]int)
+				}
+				nc.Streams[string(s.Protocol())]++
+			}
+			if protocol != "" && nc.Streams[protocol] == 0 {
+				continue
+			}
+			if nd.Reporter != nil {
+				bw := nd.Reporter.GetBandwidthForPeer(c.RemotePeer())
+				nc.TotalIn, nc.TotalOut = bw.TotalIn, bw.TotalOut
+				nc.RateIn, nc.RateOut = bw.RateIn, bw.RateOut
+			}
+			out.Conns = append(out.Conns, nc)
+		}
+		sort.SliceStable(out.Conns, func(i, j int) bool {
+			return less(&out.Conns[i], &out.Conns[j])
+		})
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: NetstatConns{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *NetstatConns) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "PEER\tTRANSPORT\tDIRECTION\tAGE\tSTREAMS\tIN\tOUT\tADDRESS")
+			for _, c := range out.Conns {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", c.Peer, c.Transport, c.Direction, c.Age,
+					c.NumStreams(), humanize.Bytes(uint64(c.TotalIn)), humanize.Bytes(uint64(c.TotalOut)), c.Addr)
+				protocols := make([]string, 0, len(c.Streams))
+				for p := range c.Streams {
+					protocols = append(protocols, p)
+				}
+				sort.Strings(protocols)
+				for _, p := range protocols {
+					name := p
+					if name == "" {
+						name = "<no protocol name>"
+					}
+					fmt.Fprintf(tw, "  %s\t\t\t\t%d\t\t\t\n", name, c.Streams[p])
+				}
+			}
+			return tw.Flush()
+		}),
+	},
+}
@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/shaping"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+const (
+	shapeLatencyOptionName = "latency"
+	shapeJitterOptionName  = "jitter"
+	shapeLossOptionName    = "loss"
+
+	// shapeAllPeers is the argument of the rule applying to all the peers
+	// without their own rule.
+	shapeAllPeers = "all"
+)
+
+var errShapingDisabled = errors.New("traffic shaping is disabled, enable it with 'ipfs config --json Experimental.TrafficShaping true' and restart the daemon")
+
+// ShapeRule is a traffic shaping rule, listed by 'ipfs diag shape ls'.
+type ShapeRule struct {
+	// Peer is the peer ID, or "all" for the default rule.
+	Peer    string
+	Latency time.Duration
+	Jitter  time.Duration
+	Loss    float64
+}
+
+// ShapeRules is the output of 'ipfs diag shape ls'.
+type ShapeRules struct {
+	Rules []ShapeRule
+}
+
+var diagShapeCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Inject latency and loss into the streams with other peers.",
+		ShortDescription: `
+'ipfs diag shape' delays the data the node sends to other peers, to test how
+it behaves on a degraded network during soak tests. It is experimental, and
+only available when Experimental.TrafficShaping is enabled in the config.
+
+A rule applies to one peer, or to all the peers without their own rule with
+'all'. Each write to a stream is delayed by the latency, plus a random jitter,
+and lost with the given probability; as streams are reliable, a lost write is
+delayed further as if it was retransmitted.
+
+The rules are kept in memory and are lost when the daemon restarts.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set": diagShapeSetCmd,
+		"ls":  diagShapeLsCmd,
+		"rm":  diagShapeRmCmd,
+	},
+}
+
+var diagShapeSetCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Shape the streams with a peer.",
+		ShortDescription: `
+'ipfs diag shape set' sets the rule of a peer, or of all the peers without
+their own rule with 'all', replacing the previous one. For example:
+
+  > ipfs diag shape set all --latency=100ms --jitter=20ms --loss=0.01
+`,
+	},
+	NoLocal: true,
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", true, false, "The peer ID, or 'all'."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(shapeLatencyOptionName, "Latency added to each write.").WithDefault("0s"),
+		cmds.StringOption(shapeJitterOptionName, "Maximum random latency added to each write.").WithDefault("0s"),
+		cmds.FloatOption(shapeLossOptionName, "Probability, between 0 and 1, that a write is lost.").WithDefault(0.0),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.Shaper == nil {
+			return errShapingDisabled
+		}
+		p, err := shapePeer(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+
+		var rule shaping.Rule
+		latency, _ := req.Options[shapeLatencyOptionName].(string)
+		if rule.Latency, err = time.ParseDuration(latency); err != nil {
+			return fmt.Errorf("invalid --%s: %w", shapeLatencyOptionName, err)
+		}
+		jitter, _ := req.Options[shapeJitterOptionName].(string)
+		if rule.Jitter, err = time.ParseDuration(jitter); err != nil {
+			return fmt.Errorf("invalid --%s: %w", shapeJitterOptionName, err)
+		}
+		rule.Loss, _ = req.Options[shapeLossOptionName].(float64)
+		return nd.Shaper.Set(p, rule)
+	},
+}
+
+var diagShapeLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the traffic shaping rules.",
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.Shaper == nil {
+			return errShapingDisabled
+		}
+
+		out := ShapeRules{Rules: []ShapeRule{}}
+		for p, r := range nd.Shaper.Rules() {
+			name := shapeAllPeers
+			if p != shaping.All {
+				name = p.String()
+			}
+			out.Rules = append(out.Rules, ShapeRule{Peer: name, Latency: r.Latency, Jitter: r.Jitter, Loss: r.Loss})
+		}
+		sort.Slice(out.Rules, func(i, j int) bool {
+			return out.Rules[i].Peer < out.Rules[j].Peer
+		})
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: ShapeRules{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ShapeRules) error {
+			tw := tabwriter.NewWriter(w, 4, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "PEER\tLATENCY\tJITTER\tLOSS")
+			for _, r := range out.Rules {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%g\n", r.Peer, r.Latency, r.Jitter, r.Loss)
+			}
+			return tw.Flush()
+		}),
+	},
+}
+
+var diagShapeRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove traffic shaping rules.",
+		ShortDescription: `
+'ipfs diag shape rm' removes the rules of the given peers, or the default rule
+with 'all'. With --all, it removes all the rules.
+`,
+	},
+	NoLocal: true,
+	Arguments: []cmds.Argument{
+		cmds.StringArg("peer", false, true, "The peer IDs, or 'all'."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption("all", "a", "Remove all the rules."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.Shaper == nil {
+			return errShapingDisabled
+		}
+
+		if all, _ := req.Options["all"].(bool); all {
+			if len(req.Arguments) > 0 {
+				return errors.New("--all takes no peer")
+			}
+			nd.Shaper.Clear()
+			return nil
+		}
+		if len(req.Arguments) == 0 {
+			return errors.New("no peer given, use --all to remove all the rules")
+		}
+		peers := make([]peer.ID, 0, len(req.Arguments))
+		for _, arg := range req.Arguments {
+			p, err := shapePeer(arg)
+			if err != nil {
+				return err
+			}
+			peers = append(peers, p)
+		}
+		for i, p := range peers {
+			if !nd.Shaper.Remove(p) {
+				return fmt.Errorf("no rule for %s", req.Arguments[i])
+			}
+		}
+		return nil
+	},
+}
+
+// shapePeer parses the peer of a rule, "all" or a peer ID.
+func shapePeer(arg string) (peer.ID, error) {
+	if arg == shapeAllPeers {
+		return shaping.All, nil
+	}
+	p, err := peer.Decode(arg)
+	if err != nil {
+		return "", fmt.Errorf("invalid peer %q: %w", arg, err)
+	}
+	return p, nil
+}
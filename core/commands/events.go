@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/events"
+)
+
+const (
+	eventsTypeOptionName   = "type"
+	eventsBufferOptionName = "buffer"
+)
+
+var EventsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Stream internal node events.",
+		ShortDescription: `
+'ipfs events' streams events emitted by a running daemon until the request is
+cancelled. Each event is a JSON object with a Type, a Time and optional Data.
+`,
+		LongDescription: `
+'ipfs events' streams events emitted by a running daemon until the request is
+cancelled. Each event is a JSON object with a Type, a Time and optional Data.
+
+Available event types:
+
+  peer.connected       a connection to a peer was opened
+  peer.disconnected    the last connection to a peer was closed
+  pin.added            content was pinned
+  pin.removed          content was unpinned
+  pin.failed           pinning content failed
+  gc.started           a repo garbage collection started
+  gc.finished          a repo garbage collection finished
+  ipns.published       an IPNS record was published
+  reprovide.started    a reprovide cycle started
+  reprovide.finished   a reprovide cycle finished
+  gateway.error        the gateway repeatedly failed to serve a CID
+  block.corrupted      the scrubber found a corrupted block
+  scrub.finished       the scrubber checked all the blocks of the repo
+
+Use --type to only receive some events. A type matches itself and all of
+the types it prefixes, so '--type=pin' receives all pin events.
+The option can be given multiple times.
+
+Events are delivered on a best-effort basis: if the client does not read
+them fast enough, new events are dropped.
+
+EXAMPLES
+
+  > ipfs events --type=gc --type=pin.added --enc=json
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringsOption(eventsTypeOptionName, "t", "Only stream events of the given type (or type prefix)."),
+		cmds.IntOption(eventsBufferOptionName, "Number of events buffered before dropping.").WithDefault(events.DefaultBufferSize),
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if n.Events == nil {
+			return fmt.Errorf("event bus not available")
+		}
+
+		types, _ := req.Options[eventsTypeOptionName].([]string)
+		for _, t := range types {
+			if !validEventFilter(t) {
+				return fmt.Errorf("unknown event type %q", t)
+			}
+		}
+		buf, _ := req.Options[eventsBufferOptionName].(int)
+
+		sub := n.Events.Subscribe(buf, types...)
+		defer sub.Close()
+
+		if f, ok := res.(http.Flusher); ok {
+			f.Flush()
+		}
+
+		for {
+			select {
+			case evt, ok := <-sub.Out():
+				if !ok {
+					return nil
+				}
+				if err := res.Emit(&evt); err != nil {
+					return err
+				}
+			case <-req.Context.Done():
+				return nil
+			}
+		}
+	},
+	Type: events.Event{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, evt *events.Event) error {
+			_, err := fmt.Fprintf(w, "%s %s", evt.Time.Format("2006-01-02T15:04:05.000Z07:00"), evt.Type)
+			if err != nil {
+				return err
+			}
+			for k, v := range evt.Data {
+				if _, err := fmt.Fprintf(w, " %s=%v", k, v); err != nil {
+					return err
+				}
+			}
+			_, err = fmt.Fprintln(w)
+			return err
+		}),
+	},
+}
+
+func validEventFilter(filter string) bool {
+	for _, t := range events.Types {
+		if t == filter || strings.HasPrefix(t, filter+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -13,6 +13,7 @@ import (
 	humanize "github.com/dustin/go-humanize"
 	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/quota"
 
 	bservice "github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
@@ -55,6 +56,10 @@ Content added with "ipfs add" (which by default also becomes pinned), is not
 added to MFS. Any content can be lazily referenced from MFS with the command
 "ipfs files cp /ipfs/<cid> /some/path/" (see ipfs files cp --help).
 
+With --space, the subcommands operate in the MFS root of a space instead of the
+one of the node, and the writes are refused once the space is over its quota
+(see ipfs space --help).
+
 
 NOTE:
 Most of the subcommands of 'ipfs files' accept the '--flush' flag. It defaults
@@ -68,18 +73,22 @@ operations.
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(filesFlushOptionName, "f", "Flush target and ancestors after write.").WithDefault(true),
+		cmdenv.OptionSpace,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"read":  filesReadCmd,
-		"write": filesWriteCmd,
-		"mv":    filesMvCmd,
-		"cp":    filesCpCmd,
-		"ls":    filesLsCmd,
-		"mkdir": filesMkdirCmd,
-		"stat":  filesStatCmd,
-		"rm":    filesRmCmd,
-		"flush": filesFlushCmd,
-		"chcid": filesChcidCmd,
+		"read":     filesReadCmd,
+		"write":    filesWriteCmd,
+		"mv":       filesMvCmd,
+		"cp":       filesCpCmd,
+		"ls":       filesLsCmd,
+		"mkdir":    filesMkdirCmd,
+		"stat":     filesStatCmd,
+		"rm":       filesRmCmd,
+		"flush":    filesFlushCmd,
+		"chcid":    filesChcidCmd,
+		"truncate": filesTruncateCmd,
+		"sync":     filesSyncCmd,
+		"patch":    filesPatchCmd,
 	},
 }
 
@@ -170,7 +179,12 @@ var filesStatCmd = &cmds.Command{
 			dagserv = node.DAG
 		}
 
-		nd, err := getNodeFromPath(req.Context, node, api, path)
+		filesRoot, err := getFilesRoot(req, node)
+		if err != nil {
+			return err
+		}
+
+		nd, err := getNodeFromPath(req.Context, filesRoot, api, path)
 		if err != nil {
 			return err
 		}
@@ -365,6 +379,13 @@ being GC'ed.
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
+		if err := checkFilesQuota(req, nd); err != nil {
+			return err
+		}
 
 		prefix, err := getPrefixNew(req)
 		if err != nil {
@@ -393,25 +414,26 @@ being GC'ed.
 			dst += gopath.Base(src)
 		}
 
-		node, err := getNodeFromPath(req.Context, nd, api, src)
+		node, err := getNodeFromPath(req.Context, filesRoot, api, src)
 		if err != nil {
 			return fmt.Errorf("cp: cannot get node from path %s: %s", src, err)
 		}
 
 		if mkParents {
-			err := ensureContainingDirectoryExists(nd.FilesRoot, dst, prefix)
+			err := ensureContainingDirectoryExists(filesRoot, dst, prefix)
 			if err != nil {
 				return err
 			}
 		}
 
-		err = mfs.PutNode(nd.FilesRoot, dst, node)
+		err = mfs.PutNode(filesRoot, dst, node)
 		if err != nil {
 			return fmt.Errorf("cp: cannot put node in path %s: %s", dst, err)
 		}
 
+		deferFlush(nd, flush)
 		if flush {
-			_, err := mfs.FlushPath(req.Context, nd.FilesRoot, dst)
+			_, err := mfs.FlushPath(req.Context, filesRoot, dst)
 			if err != nil {
 				return fmt.Errorf("cp: cannot flush the created file %s: %s", dst, err)
 			}
@@ -421,12 +443,31 @@ being GC'ed.
 	},
 }
 
-func getNodeFromPath(ctx context.Context, node *core.IpfsNode, api iface.CoreAPI, p string) (ipld.Node, error) {
+// getFilesRoot returns the MFS root of the --space of the request, the one
+// of the node by default.
+func getFilesRoot(req *cmds.Request, nd *core.IpfsNode) (*mfs.Root, error) {
+	space := cmdenv.GetSpace(req)
+	if space == "" {
+		return nd.FilesRoot, nil
+	}
+	return nd.Spaces.FilesRoot(req.Context, space)
+}
+
+// checkFilesQuota refuses new data once MFS is over quota, or the --space of
+// the request.
+func checkFilesQuota(req *cmds.Request, nd *core.IpfsNode) error {
+	if space := cmdenv.GetSpace(req); space != "" {
+		return nd.Spaces.Check(req.Context, space)
+	}
+	return nd.Quotas.Check(req.Context, quota.MFS)
+}
+
+func getNodeFromPath(ctx context.Context, filesRoot *mfs.Root, api iface.CoreAPI, p string) (ipld.Node, error) {
 	switch {
 	case strings.HasPrefix(p, "/ipfs/"):
 		return api.ResolveNode(ctx, path.New(p))
 	default:
-		fsn, err := mfs.Lookup(node.FilesRoot, p)
+		fsn, err := mfs.Lookup(filesRoot, p)
 		if err != nil {
 			return nil, err
 		}
@@ -490,8 +531,12 @@ Examples:
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 
-		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		fsn, err := mfs.Lookup(filesRoot, path)
 		if err != nil {
 			return err
 		}
@@ -605,13 +650,17 @@ Examples:
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 
 		path, err := checkPath(req.Arguments[0])
 		if err != nil {
 			return err
 		}
 
-		fsn, err := mfs.Lookup(nd.FilesRoot, path)
+		fsn, err := mfs.Lookup(filesRoot, path)
 		if err != nil {
 			return err
 		}
@@ -694,6 +743,10 @@ Example:
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 
 		flush, _ := req.Options[filesFlushOptionName].(bool)
 
@@ -706,9 +759,10 @@ Example:
 			return err
 		}
 
-		err = mfs.Mv(nd.FilesRoot, src, dst)
+		err = mfs.Mv(filesRoot, src, dst)
+		deferFlush(nd, flush)
 		if err == nil && flush {
-			_, err = mfs.FlushPath(req.Context, nd.FilesRoot, "/")
+			_, err = mfs.FlushPath(req.Context, filesRoot, "/")
 		}
 		return err
 	},
@@ -746,9 +800,18 @@ Newly created leaves will be in the legacy format (Protobuf) if the
 CID version is 0, or raw if the CID version is non-zero.  Use of the
 '--raw-leaves' option will override this behavior.
 
+Appending to an existing file keeps the format of its leaves, raw or
+Protobuf, unless '--raw-leaves' is given.
+
+Writing past the end of the file fills the gap with zeros, like a sparse
+file: the zeros are stored as identical blocks, taking the space of a single
+block. 'ipfs files truncate' shrinks or extends a file the same way.
+
 If the '--flush' option is set to false, changes will not be propagated to the
 merkledag root. This can make operations much faster when doing a large number
-of writes to a deeper directory structure.
+of writes to a deeper directory structure. With Internal.MFSFlushInterval set,
+the daemon propagates them in the background, batching the changes of many
+writes.
 
 EXAMPLE:
 
@@ -813,6 +876,13 @@ See '--to-files' in 'ipfs add --help' for more information.
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
+		if err := checkFilesQuota(req, nd); err != nil {
+			return err
+		}
 
 		offset, _ := req.Options[filesOffsetOptionName].(int64)
 		if offset < 0 {
@@ -820,18 +890,20 @@ See '--to-files' in 'ipfs add --help' for more information.
 		}
 
 		if mkParents {
-			err := ensureContainingDirectoryExists(nd.FilesRoot, path, prefix)
+			err := ensureContainingDirectoryExists(filesRoot, path, prefix)
 			if err != nil {
 				return err
 			}
 		}
 
-		fi, err := getFileHandle(nd.FilesRoot, path, create, prefix)
+		fi, err := getFileHandle(filesRoot, path, create, prefix)
 		if err != nil {
 			return err
 		}
 		if rawLeavesDef {
 			fi.RawLeaves = rawLeaves
+		} else if err := keepLeafFormat(req.Context, nd.DAG, fi); err != nil {
+			return err
 		}
 
 		wfd, err := fi.Open(mfs.Flags{Write: true, Sync: flush})
@@ -840,6 +912,7 @@ See '--to-files' in 'ipfs add --help' for more information.
 		}
 
 		defer func() {
+			deferFlush(nd, flush)
 			err := wfd.Close()
 			if err != nil {
 				if retErr == nil {
@@ -861,6 +934,9 @@ See '--to-files' in 'ipfs add --help' for more information.
 			return fmt.Errorf("cannot have negative byte count")
 		}
 
+		if err := fillSparse(wfd, offset); err != nil {
+			return err
+		}
 		_, err = wfd.Seek(int64(offset), io.SeekStart)
 		if err != nil {
 			flog.Error("seekfail: ", err)
@@ -925,13 +1001,17 @@ Examples:
 		if err != nil {
 			return err
 		}
-		root := n.FilesRoot
+		root, err := getFilesRoot(req, n)
+		if err != nil {
+			return err
+		}
 
 		err = mfs.Mkdir(root, dirtomake, mfs.MkdirOpts{
 			Mkparents:  dashp,
 			Flush:      flush,
 			CidBuilder: prefix,
 		})
+		deferFlush(n, flush)
 
 		return err
 	},
@@ -957,6 +1037,10 @@ are run with the '--flush=false'.
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 
 		enc, err := cmdenv.GetCidEncoder(req)
 		if err != nil {
@@ -968,7 +1052,7 @@ are run with the '--flush=false'.
 			path = req.Arguments[0]
 		}
 
-		n, err := mfs.FlushPath(req.Context, nd.FilesRoot, path)
+		n, err := mfs.FlushPath(req.Context, filesRoot, path)
 		if err != nil {
 			return err
 		}
@@ -997,6 +1081,10 @@ Change the CID version or hash function of the root node of a given path.
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 
 		path := "/"
 		if len(req.Arguments) > 0 {
@@ -1010,9 +1098,10 @@ Change the CID version or hash function of the root node of a given path.
 			return err
 		}
 
-		err = updatePath(nd.FilesRoot, path, prefix)
+		err = updatePath(filesRoot, path, prefix)
+		deferFlush(nd, flush)
 		if err == nil && flush {
-			_, err = mfs.FlushPath(req.Context, nd.FilesRoot, path)
+			_, err = mfs.FlushPath(req.Context, filesRoot, path)
 		}
 		return err
 	},
@@ -1065,6 +1154,10 @@ Remove files or directories.
 		if err != nil {
 			return err
 		}
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
 		// if '--force' specified, it will remove anything else,
 		// including file, directory, corrupted node, etc
 		force, _ := req.Options[forceOptionName].(bool)
@@ -1077,7 +1170,7 @@ Remove files or directories.
 				continue
 			}
 
-			if err := removePath(nd.FilesRoot, path, force, dashr); err != nil {
+			if err := removePath(filesRoot, path, force, dashr); err != nil {
 				errs = append(errs, fmt.Errorf("%s: %w", path, err))
 			}
 		}
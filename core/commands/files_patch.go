@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/unixfspatch"
+)
+
+type filesPatchOutput struct {
+	Root string
+}
+
+const (
+	filesMtimeOptionName      = "mtime"
+	filesMtimeNsecsOptionName = "mtime-nsecs"
+)
+
+var filesPatchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Edit the UnixFS DAG under any root, returning the new root.",
+		ShortDescription: `
+'ipfs files patch' edits the UnixFS DAG under an arbitrary root CID or path,
+outside of MFS, and prints the CID of the new root. The old root is left
+untouched: the new root shares its unchanged subtrees.
+
+Unlike 'ipfs object patch', directories are edited as UnixFS directories, so
+HAMT-sharded directories are supported, and directories are sharded or
+unsharded as needed.
+
+The new nodes are not pinned.
+
+Examples:
+
+    $ ipfs files patch add-link -p $ROOT some/dir/file.txt $FILE
+    $ ipfs files patch rm-link $ROOT some/dir/file.txt
+    $ echo "new content" | ipfs files patch set-data $ROOT some/dir/file.txt
+    $ ipfs files patch chmod $ROOT some/dir/file.txt 755
+    $ ipfs files patch touch $ROOT some/dir/file.txt
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"add-link": filesPatchAddLinkCmd,
+		"rm-link":  filesPatchRmLinkCmd,
+		"set-data": filesPatchSetDataCmd,
+		"chmod":    filesPatchChmodCmd,
+		"touch":    filesPatchTouchCmd,
+	},
+}
+
+var filesPatchEncoders = cmds.EncoderMap{
+	cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *filesPatchOutput) error {
+		_, err := fmt.Fprintln(w, out.Root)
+		return err
+	}),
+}
+
+type filesPatchFunc func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error)
+
+// filesPatchRun resolves the root argument, edits it with edit and emits the
+// new root.
+func filesPatchRun(edit filesPatchFunc) func(*cmds.Request, cmds.ResponseEmitter, cmds.Environment) error {
+	return func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		root, err := api.ResolveNode(req.Context, path.New(req.Arguments[0]))
+		if err != nil {
+			return err
+		}
+		out, err := edit(req, api, unixfspatch.New(api.Dag()), root)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &filesPatchOutput{Root: enc.Encode(out.Cid())})
+	}
+}
+
+var filesPatchAddLinkCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set a directory entry under a root.",
+		ShortDescription: `
+Set the entry at <path> under <root> to <ref>, replacing any existing entry.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root to edit."),
+		cmds.StringArg("path", true, false, "Path of the entry under the root."),
+		cmds.StringArg("ref", true, false, "Path of the node to link to."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesParentsOptionName, "p", "Create the missing parent directories."),
+	},
+	Run: filesPatchRun(func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error) {
+		parents, _ := req.Options[filesParentsOptionName].(bool)
+		child, err := api.ResolveNode(req.Context, path.New(req.Arguments[2]))
+		if err != nil {
+			return nil, err
+		}
+		return e.AddLink(req.Context, root, req.Arguments[1], child, parents)
+	}),
+	Type:     filesPatchOutput{},
+	Encoders: filesPatchEncoders,
+}
+
+var filesPatchRmLinkCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a directory entry under a root.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root to edit."),
+		cmds.StringArg("path", true, false, "Path of the entry under the root."),
+	},
+	Run: filesPatchRun(func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error) {
+		return e.RmLink(req.Context, root, req.Arguments[1])
+	}),
+	Type:     filesPatchOutput{},
+	Encoders: filesPatchEncoders,
+}
+
+var filesPatchSetDataCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Replace the content of a file under a root.",
+		ShortDescription: `
+Replace the content of the file at <path> under <root>, or of <root> itself if
+<path> is empty, with the data read from stdin or a file. The data is added as
+a UnixFS file with the CID version of the root. The mode and mtime of the file
+are kept.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root to edit."),
+		cmds.StringArg("path", true, false, "Path of the file under the root."),
+		cmds.FileArg("data", true, false, "The new content of the file.").EnableStdin(),
+	},
+	Run: filesPatchRun(func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error) {
+		file, err := cmdenv.GetFileArg(req.Files.Entries())
+		if err != nil {
+			return nil, err
+		}
+		prefix := root.Cid().Prefix()
+		added, err := api.Unixfs().Add(req.Context, file,
+			options.Unixfs.Pin(false),
+			options.Unixfs.CidVersion(int(prefix.Version)),
+			options.Unixfs.RawLeaves(prefix.Version == 1),
+		)
+		if err != nil {
+			return nil, err
+		}
+		nd, err := api.Dag().Get(req.Context, added.Cid())
+		if err != nil {
+			return nil, err
+		}
+		return e.SetData(req.Context, root, req.Arguments[1], nd)
+	}),
+	Type:     filesPatchOutput{},
+	Encoders: filesPatchEncoders,
+}
+
+var filesPatchChmodCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the UnixFS mode of a node under a root.",
+		ShortDescription: `
+Set the UnixFS mode of the node at <path> under <root>, or of <root> itself if
+<path> is empty, to the octal <mode>, e.g. 755. Raw leaves are wrapped in a
+UnixFS file node, which holds the mode.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root to edit."),
+		cmds.StringArg("path", true, false, "Path of the node under the root."),
+		cmds.StringArg("mode", true, false, "The octal permission bits."),
+	},
+	Run: filesPatchRun(func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error) {
+		mode, err := strconv.ParseUint(req.Arguments[2], 8, 32)
+		if err != nil || mode > 07777 {
+			return nil, fmt.Errorf("invalid mode %q", req.Arguments[2])
+		}
+		return e.Chmod(req.Context, root, req.Arguments[1], os.FileMode(mode))
+	}),
+	Type:     filesPatchOutput{},
+	Encoders: filesPatchEncoders,
+}
+
+var filesPatchTouchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the UnixFS mtime of a node under a root.",
+		ShortDescription: `
+Set the UnixFS mtime of the node at <path> under <root>, or of <root> itself if
+<path> is empty, to the current time or the time given with --mtime. Raw leaves
+are wrapped in a UnixFS file node, which holds the mtime.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("root", true, false, "The root to edit."),
+		cmds.StringArg("path", true, false, "Path of the node under the root."),
+	},
+	Options: []cmds.Option{
+		cmds.Int64Option(filesMtimeOptionName, "The mtime, in seconds since the Unix epoch. Default: now."),
+		cmds.UintOption(filesMtimeNsecsOptionName, "The nanoseconds of the mtime."),
+	},
+	Run: filesPatchRun(func(req *cmds.Request, api iface.CoreAPI, e *unixfspatch.Editor, root ipld.Node) (ipld.Node, error) {
+		mtime := time.Now()
+		if secs, ok := req.Options[filesMtimeOptionName].(int64); ok {
+			nsecs, _ := req.Options[filesMtimeNsecsOptionName].(uint)
+			if nsecs >= uint(time.Second) {
+				return nil, fmt.Errorf("%s must be less than %d", filesMtimeNsecsOptionName, time.Second)
+			}
+			mtime = time.Unix(secs, int64(nsecs))
+		}
+		return e.Touch(req.Context, root, req.Arguments[1], mtime)
+	}),
+	Type:     filesPatchOutput{},
+	Encoders: filesPatchEncoders,
+}
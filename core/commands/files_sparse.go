@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	cid "github.com/ipfs/go-cid"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mfs "github.com/ipfs/go-mfs"
+
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+var filesTruncateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Truncate or extend a file in MFS.",
+		ShortDescription: `
+Changes the size of a file in MFS to the given number of bytes. A larger size
+extends the file with zeros, like a sparse file: the zeros are stored as
+identical blocks, taking the space of a single block.
+
+The format of the leaves of the file, raw or Protobuf, is kept unless
+'--raw-leaves' is given.
+
+EXAMPLE:
+
+    ipfs files truncate /db/table 1073741824
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("path", true, false, "Path of the file."),
+		cmds.StringArg("size", true, false, "New size of the file, in bytes."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesRawLeavesOptionName, "Use raw blocks for newly created leaf nodes. (experimental)"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) (retErr error) {
+		path, err := checkPath(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		size, err := strconv.ParseInt(req.Arguments[1], 10, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("invalid size %q", req.Arguments[1])
+		}
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		rawLeaves, rawLeavesDef := req.Options[filesRawLeavesOptionName].(bool)
+
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if err := checkFilesQuota(req, nd); err != nil {
+			return err
+		}
+
+		filesRoot, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
+		fi, err := getFileHandle(filesRoot, path, false, nil)
+		if err != nil {
+			return err
+		}
+		if rawLeavesDef {
+			fi.RawLeaves = rawLeaves
+		} else if err := keepLeafFormat(req.Context, nd.DAG, fi); err != nil {
+			return err
+		}
+
+		wfd, err := fi.Open(mfs.Flags{Write: true, Sync: flush})
+		if err != nil {
+			return err
+		}
+		defer func() {
+			deferFlush(nd, flush)
+			if err := wfd.Close(); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+
+		cur, err := wfd.Size()
+		if err != nil {
+			return err
+		}
+		if size < cur {
+			return wfd.Truncate(size)
+		}
+		return fillSparse(wfd, size)
+	},
+}
+
+// fillSparse extends the file of fd with zeros up to size, if it's smaller.
+//
+// The zeros are written rather than left to the seek past the end of the
+// file, which would chunk them into 4KiB blocks: written, they're chunked
+// into blocks of the default chunker, one block of storage however large the
+// gap.
+func fillSparse(fd mfs.FileDescriptor, size int64) error {
+	cur, err := fd.Size()
+	if err != nil {
+		return err
+	}
+	if size <= cur {
+		return nil
+	}
+	if _, err := fd.Seek(cur, io.SeekStart); err != nil {
+		return err
+	}
+	buf := make([]byte, chunker.DefaultBlockSize)
+	_, err = io.CopyBuffer(fd, io.LimitReader(zeroReader{}, size-cur), buf)
+	return err
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+// keepLeafFormat makes the leaves written to fi raw or Protobuf like its
+// existing leaves, rather than by the CID version of the file, so that
+// appending to a file doesn't mix the formats of its leaves.
+func keepLeafFormat(ctx context.Context, ng ipld.NodeGetter, fi *mfs.File) error {
+	nd, err := fi.GetNode()
+	if err != nil {
+		return err
+	}
+	raw, ok, err := hasRawLeaves(ctx, ng, nd)
+	if err != nil {
+		return err
+	}
+	if ok {
+		fi.RawLeaves = raw
+	}
+	return nil
+}
+
+// hasRawLeaves returns whether the leaves of the file nd are raw blocks, by
+// following its first links. ok is false for the files with their data
+// inlined in their root, which have no leaf to tell.
+func hasRawLeaves(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node) (raw bool, ok bool, err error) {
+	for depth := 0; ; depth++ {
+		switch n := nd.(type) {
+		case *dag.RawNode:
+			return true, true, nil
+		case *dag.ProtoNode:
+			links := n.Links()
+			if len(links) == 0 {
+				return false, depth > 0, nil
+			}
+			if links[0].Cid.Prefix().Codec == cid.Raw {
+				return true, true, nil
+			}
+			if nd, err = links[0].GetNode(ctx, ng); err != nil {
+				return false, false, err
+			}
+		default:
+			return false, false, nil
+		}
+	}
+}
+
+// deferFlush hands the changes made without flushing to the MFS flusher of
+// the node, if any, to be flushed within Internal.MFSFlushInterval.
+func deferFlush(nd *core.IpfsNode, flush bool) {
+	if !flush && nd.MFSFlusher != nil {
+		nd.MFSFlusher.MarkDirty()
+	}
+}
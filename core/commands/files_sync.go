@@ -0,0 +1,659 @@
+package commands
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	gopath "path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-libipfs/files"
+	mfs "github.com/ipfs/go-mfs"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	ipath "github.com/ipfs/interface-go-ipfs-core/path"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+const (
+	filesSyncDeleteOptionName  = "delete"
+	filesSyncDryRunOptionName  = "dry-run"
+	filesSyncReverseOptionName = "reverse"
+)
+
+// filesSyncStatePrefix is the datastore prefix of the state of the synced
+// directories, by local directory and MFS path.
+var filesSyncStatePrefix = datastore.NewKey("/local/filessync")
+
+type filesSyncOutput struct {
+	Added     []string
+	Updated   []string
+	Removed   []string
+	Unchanged int
+	DryRun    bool
+}
+
+var filesSyncCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Mirror a local directory into MFS, or back.",
+		ShortDescription: `
+Mirrors the local directory into the MFS directory at the given path, adding
+the files which are new or changed since the last sync, and reports the
+changes. With '--reverse', mirrors the MFS directory into the local directory
+instead.
+
+The files are compared by their size, modification time and CID: the files
+whose size and modification time haven't changed since the last sync of the
+directories, and whose CID in MFS hasn't either, are skipped without being
+read. The others are read and hashed, and only copied if their CID differs.
+
+The local directory is read, or written, by the daemon: it must be on the
+machine running the daemon. Symbolic links and special files are skipped.
+
+EXAMPLES:
+
+    # publish a website
+    ipfs files sync ./public /www
+    ipfs files sync --delete ./public /www
+
+    # fetch the changes of the website
+    ipfs files sync --reverse ./public /www
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("local-dir", true, false, "Local directory."),
+		cmds.StringArg("mfs-path", true, false, "MFS directory."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(filesSyncDeleteOptionName, "Remove the files of the destination which aren't in the source."),
+		cmds.BoolOption(filesSyncDryRunOptionName, "Report the changes without making them."),
+		cmds.BoolOption(filesSyncReverseOptionName, "Mirror the MFS directory into the local directory."),
+		cmds.BoolOption(filesRawLeavesOptionName, "Use raw blocks for the leaf nodes of the files added. (experimental)"),
+		cidVersionOption,
+		hashOption,
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		// the directory is read by the daemon, resolve relative paths here
+		abs, err := filepath.Abs(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		req.Arguments[0] = abs
+		return nil
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		localDir := filepath.Clean(req.Arguments[0])
+		if !filepath.IsAbs(localDir) {
+			return fmt.Errorf("the local directory %q must be an absolute path", localDir)
+		}
+		mfsDir, err := checkPath(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		mfsDir = gopath.Clean(mfsDir)
+
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		prefix, err := getPrefixNew(req)
+		if err != nil {
+			return err
+		}
+		addOpts, err := filesSyncAddOptions(req)
+		if err != nil {
+			return err
+		}
+
+		root, err := getFilesRoot(req, nd)
+		if err != nil {
+			return err
+		}
+
+		reverse, _ := req.Options[filesSyncReverseOptionName].(bool)
+		flush, _ := req.Options[filesFlushOptionName].(bool)
+		s := &filesSync{
+			nd:       nd,
+			root:     root,
+			api:      api,
+			localDir: localDir,
+			mfsDir:   mfsDir,
+			prefix:   prefix,
+			addOpts:  addOpts,
+			delete:   req.Options[filesSyncDeleteOptionName] == true,
+			dryRun:   req.Options[filesSyncDryRunOptionName] == true,
+			seen:     make(map[string]bool),
+			next:     make(map[string]filesSyncEntry),
+		}
+		s.out.DryRun = s.dryRun
+
+		// the directories of the spaces are synced separately
+		stateDir := mfsDir
+		if space := cmdenv.GetSpace(req); space != "" {
+			stateDir = space + ":" + mfsDir
+		}
+		if s.state, err = loadFilesSyncState(req.Context, nd, localDir, stateDir); err != nil {
+			return err
+		}
+		if reverse {
+			err = s.toLocal(req.Context)
+		} else {
+			if err := checkFilesQuota(req, nd); err != nil {
+				return err
+			}
+			err = s.toMFS(req.Context)
+			if err == nil && !s.dryRun {
+				deferFlush(nd, flush)
+				if flush {
+					_, err = mfs.FlushPath(req.Context, s.root, mfsDir)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if !s.dryRun {
+			if err := saveFilesSyncState(req.Context, nd, localDir, stateDir, s.next); err != nil {
+				return err
+			}
+		}
+		return cmds.EmitOnce(res, &s.out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *filesSyncOutput) error {
+			for _, c := range []struct {
+				action string
+				paths  []string
+			}{{"added", out.Added}, {"updated", out.Updated}, {"removed", out.Removed}} {
+				for _, p := range c.paths {
+					fmt.Fprintf(w, "%s %s\n", c.action, p)
+				}
+			}
+			fmt.Fprintf(w, "%d added, %d updated, %d removed, %d unchanged", len(out.Added), len(out.Updated), len(out.Removed), out.Unchanged)
+			if out.DryRun {
+				fmt.Fprint(w, " (dry run)")
+			}
+			fmt.Fprintln(w)
+			return nil
+		}),
+	},
+	Type: filesSyncOutput{},
+}
+
+// filesSyncAddOptions returns the options of the files added to MFS.
+func filesSyncAddOptions(req *cmds.Request) ([]options.UnixfsAddOption, error) {
+	opts := []options.UnixfsAddOption{options.Unixfs.Pin(false)}
+	if cidVer, ok := req.Options[filesCidVersionOptionName].(int); ok {
+		opts = append(opts, options.Unixfs.CidVersion(cidVer))
+	}
+	if hashFunStr, ok := req.Options[filesHashOptionName].(string); ok {
+		hashFunCode, ok := mh.Names[strings.ToLower(hashFunStr)]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized hash function: %s", strings.ToLower(hashFunStr))
+		}
+		opts = append(opts, options.Unixfs.Hash(hashFunCode))
+	}
+	if rawLeaves, ok := req.Options[filesRawLeavesOptionName].(bool); ok {
+		opts = append(opts, options.Unixfs.RawLeaves(rawLeaves))
+	}
+	return opts, nil
+}
+
+// filesSyncEntry is the state of a file after a sync: the size and the
+// modification time of the local file, and the CID of the MFS file.
+type filesSyncEntry struct {
+	Size    int64
+	ModTime int64
+	Cid     string
+}
+
+// filesSync mirrors a local directory and an MFS directory.
+type filesSync struct {
+	nd       *core.IpfsNode
+	root     *mfs.Root
+	api      iface.CoreAPI
+	localDir string
+	mfsDir   string
+	prefix   cid.Builder
+	addOpts  []options.UnixfsAddOption
+	delete   bool
+	dryRun   bool
+
+	// state of the files after the last sync, and after this one, by
+	// relative path
+	state, next map[string]filesSyncEntry
+	// seen are the relative paths of the source
+	seen map[string]bool
+	out  filesSyncOutput
+}
+
+// addOptions returns the options of the files added, with extra.
+func (s *filesSync) addOptions(extra ...options.UnixfsAddOption) []options.UnixfsAddOption {
+	return append(append([]options.UnixfsAddOption(nil), s.addOpts...), extra...)
+}
+
+// unchanged tells whether the local file of fi wasn't changed since the
+// last sync, nor the MFS file, with the CID c.
+func (s *filesSync) unchanged(rel string, fi fs.FileInfo, c cid.Cid) bool {
+	e, ok := s.state[rel]
+	return ok && e.Size == fi.Size() && e.ModTime == fi.ModTime().UnixNano() && e.Cid == c.String()
+}
+
+func (s *filesSync) record(rel string, fi fs.FileInfo, c cid.Cid) {
+	s.next[rel] = filesSyncEntry{Size: fi.Size(), ModTime: fi.ModTime().UnixNano(), Cid: c.String()}
+}
+
+// toMFS mirrors the local directory into the MFS directory.
+func (s *filesSync) toMFS(ctx context.Context) error {
+	fi, err := os.Stat(s.localDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.localDir)
+	}
+
+	err = filepath.WalkDir(s.localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = "/" + filepath.ToSlash(rel)
+		if rel == "/." {
+			rel = "/"
+		}
+		dst := gopath.Join(s.mfsDir, rel)
+
+		switch {
+		case d.IsDir():
+			s.seen[rel] = true
+			return s.mkdirMFS(dst)
+		case d.Type().IsRegular():
+			s.seen[rel] = true
+			return s.fileToMFS(ctx, p, rel, dst)
+		default:
+			return nil
+		}
+	})
+	if err != nil || !s.delete {
+		return err
+	}
+	return s.removeUnseenMFS(ctx, "/")
+}
+
+// mkdirMFS makes the MFS directory dst, replacing the file at dst if any.
+func (s *filesSync) mkdirMFS(dst string) error {
+	fsn, err := mfs.Lookup(s.root, dst)
+	switch {
+	case err == nil:
+		if _, ok := fsn.(*mfs.Directory); ok {
+			return nil
+		}
+		if err := s.unlinkMFS(dst); err != nil {
+			return err
+		}
+	case err != os.ErrNotExist:
+		return err
+	}
+	if s.dryRun {
+		return nil
+	}
+	return mfs.Mkdir(s.root, dst, mfs.MkdirOpts{Mkparents: true, CidBuilder: s.prefix})
+}
+
+func (s *filesSync) fileToMFS(ctx context.Context, p, rel, dst string) error {
+	var cur cid.Cid
+	fsn, err := mfs.Lookup(s.root, dst)
+	switch {
+	case err == nil:
+		n, err := fsn.GetNode()
+		if err != nil {
+			return err
+		}
+		cur = n.Cid()
+	case err != os.ErrNotExist:
+		return err
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if cur.Defined() && s.unchanged(rel, fi, cur) {
+		s.record(rel, fi, cur)
+		s.out.Unchanged++
+		return nil
+	}
+
+	added, err := s.api.Unixfs().Add(ctx, files.NewReaderStatFile(f, fi), s.addOptions(options.Unixfs.HashOnly(s.dryRun))...)
+	if err != nil {
+		return fmt.Errorf("adding %s: %w", p, err)
+	}
+	s.record(rel, fi, added.Cid())
+	if cur == added.Cid() {
+		s.out.Unchanged++
+		return nil
+	}
+
+	if cur.Defined() {
+		s.out.Updated = append(s.out.Updated, rel)
+		if err := s.unlinkMFS(dst); err != nil {
+			return err
+		}
+	} else {
+		s.out.Added = append(s.out.Added, rel)
+	}
+	if s.dryRun {
+		return nil
+	}
+	n, err := s.api.Dag().Get(ctx, added.Cid())
+	if err != nil {
+		return err
+	}
+	return mfs.PutNode(s.root, dst, n)
+}
+
+func (s *filesSync) unlinkMFS(dst string) error {
+	if s.dryRun {
+		return nil
+	}
+	dir, name := gopath.Split(dst)
+	pdir, err := getParentDir(s.root, dir)
+	if err != nil {
+		return err
+	}
+	return pdir.Unlink(name)
+}
+
+// removeUnseenMFS removes the entries of the MFS directory at rel which
+// aren't in the local directory.
+func (s *filesSync) removeUnseenMFS(ctx context.Context, rel string) error {
+	fsn, err := mfs.Lookup(s.root, gopath.Join(s.mfsDir, rel))
+	if err != nil {
+		if s.dryRun && err == os.ErrNotExist {
+			// not made by the dry run
+			return nil
+		}
+		return err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return nil
+	}
+	names, err := dir.ListNames(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child := gopath.Join(rel, name)
+		if !s.seen[child] {
+			s.out.Removed = append(s.out.Removed, child)
+			if err := s.unlinkMFS(gopath.Join(s.mfsDir, child)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := s.removeUnseenMFS(ctx, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// toLocal mirrors the MFS directory into the local directory.
+func (s *filesSync) toLocal(ctx context.Context) error {
+	fsn, err := mfs.Lookup(s.root, s.mfsDir)
+	if err != nil {
+		return err
+	}
+	dir, ok := fsn.(*mfs.Directory)
+	if !ok {
+		return fmt.Errorf("%s is not a directory", s.mfsDir)
+	}
+	if err := s.walkMFS(ctx, dir, "/"); err != nil {
+		return err
+	}
+	if !s.delete {
+		return nil
+	}
+
+	err = filepath.WalkDir(s.localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if s.dryRun && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(s.localDir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = "/" + filepath.ToSlash(rel)
+		if s.seen[rel] {
+			return nil
+		}
+		s.out.Removed = append(s.out.Removed, rel)
+		if !s.dryRun {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *filesSync) walkMFS(ctx context.Context, dir *mfs.Directory, rel string) error {
+	s.seen[rel] = true
+	dst := filepath.Join(s.localDir, filepath.FromSlash(rel))
+	fi, err := os.Lstat(dst)
+	switch {
+	case err == nil && !fi.IsDir():
+		if err := s.removeLocal(dst); err != nil {
+			return err
+		}
+		fallthrough
+	case os.IsNotExist(err):
+		if !s.dryRun {
+			if err := os.MkdirAll(dst, 0o755); err != nil {
+				return err
+			}
+		}
+	case err != nil:
+		return err
+	}
+
+	names, err := dir.ListNames(ctx)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		child, err := dir.Child(name)
+		if err != nil {
+			return err
+		}
+		childRel := gopath.Join(rel, name)
+		switch child := child.(type) {
+		case *mfs.Directory:
+			err = s.walkMFS(ctx, child, childRel)
+		case *mfs.File:
+			err = s.fileToLocal(ctx, child, childRel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *filesSync) fileToLocal(ctx context.Context, file *mfs.File, rel string) error {
+	s.seen[rel] = true
+	n, err := file.GetNode()
+	if err != nil {
+		return err
+	}
+	c := n.Cid()
+	dst := filepath.Join(s.localDir, filepath.FromSlash(rel))
+
+	fi, err := os.Lstat(dst)
+	exists := err == nil
+	switch {
+	case exists && fi.Mode().IsRegular():
+		if s.unchanged(rel, fi, c) {
+			s.record(rel, fi, c)
+			s.out.Unchanged++
+			return nil
+		}
+		local, err := s.hashLocal(ctx, dst, fi, c)
+		if err != nil {
+			return err
+		}
+		if local == c {
+			s.record(rel, fi, c)
+			s.out.Unchanged++
+			return nil
+		}
+	case exists:
+		if err := s.removeLocal(dst); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	if exists {
+		s.out.Updated = append(s.out.Updated, rel)
+	} else {
+		s.out.Added = append(s.out.Added, rel)
+	}
+	if s.dryRun {
+		return nil
+	}
+	if err := s.writeLocal(ctx, dst, c); err != nil {
+		return err
+	}
+	if fi, err = os.Stat(dst); err != nil {
+		return err
+	}
+	s.record(rel, fi, c)
+	return nil
+}
+
+// hashLocal returns the CID of the local file at p, hashed with the CID
+// version of c.
+func (s *filesSync) hashLocal(ctx context.Context, p string, fi fs.FileInfo, c cid.Cid) (cid.Cid, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer f.Close()
+	opts := s.addOptions(
+		options.Unixfs.HashOnly(true),
+		options.Unixfs.CidVersion(int(c.Version())),
+		options.Unixfs.Hash(c.Prefix().MhType),
+	)
+	added, err := s.api.Unixfs().Add(ctx, files.NewReaderStatFile(f, fi), opts...)
+	if err != nil {
+		return cid.Undef, fmt.Errorf("hashing %s: %w", p, err)
+	}
+	return added.Cid(), nil
+}
+
+// writeLocal writes the file c at p, through a temporary file renamed once
+// written.
+func (s *filesSync) writeLocal(ctx context.Context, p string, c cid.Cid) error {
+	n, err := s.api.Unixfs().Get(ctx, ipath.IpfsPath(c))
+	if err != nil {
+		return err
+	}
+	defer n.Close()
+	r, ok := n.(files.File)
+	if !ok {
+		return fmt.Errorf("%s is not a file", c)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), "."+filepath.Base(p)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *filesSync) removeLocal(p string) error {
+	if s.dryRun {
+		return nil
+	}
+	return os.RemoveAll(p)
+}
+
+func filesSyncStateKey(localDir, mfsDir string) datastore.Key {
+	h := sha256.Sum256([]byte(localDir + "\x00" + mfsDir))
+	return filesSyncStatePrefix.ChildString(hex.EncodeToString(h[:]))
+}
+
+func loadFilesSyncState(ctx context.Context, nd *core.IpfsNode, localDir, mfsDir string) (map[string]filesSyncEntry, error) {
+	state := make(map[string]filesSyncEntry)
+	b, err := nd.Repo.Datastore().Get(ctx, filesSyncStateKey(localDir, mfsDir))
+	switch {
+	case err == datastore.ErrNotFound:
+		return state, nil
+	case err != nil:
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &state); err != nil {
+		// the state only saves reading the files
+		log.Warnf("ignoring the corrupted sync state of %s: %s", localDir, err)
+		return make(map[string]filesSyncEntry), nil
+	}
+	return state, nil
+}
+
+func saveFilesSyncState(ctx context.Context, nd *core.IpfsNode, localDir, mfsDir string, state map[string]filesSyncEntry) error {
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return nd.Repo.Datastore().Put(ctx, filesSyncStateKey(localDir, mfsDir), b)
+}
@@ -3,6 +3,7 @@ package commands
 import (
 	"bufio"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -15,10 +16,13 @@ import (
 	"github.com/ipfs/kubo/core/commands/e"
 
 	"github.com/cheggaaa/pb"
+	cid "github.com/ipfs/go-cid"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-libipfs/files"
 	"github.com/ipfs/go-libipfs/tar"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/path"
+	car "github.com/ipld/go-car"
 )
 
 var ErrInvalidCompressionLevel = errors.New("compression level must be between 1 and 9")
@@ -28,6 +32,15 @@ const (
 	archiveOptionName          = "archive"
 	compressOptionName         = "compress"
 	compressionLevelOptionName = "compression-level"
+	outputFormatOptionName     = "output-format"
+	includeOptionName          = "include"
+	excludeOptionName          = "exclude"
+)
+
+// The formats of the streams output by 'ipfs get --output-format'.
+const (
+	getFormatTar = "tar"
+	getFormatCar = "car"
 )
 
 var GetCmd = &cmds.Command{
@@ -43,11 +56,41 @@ To output a TAR archive instead of unpacked files, use '--archive' or '-a'.
 
 To compress the output with GZIP compression, use '--compress' or '-C'. You
 may also specify the level of compression by specifying '-l=<1-9>'.
+
+To write a TAR or CAR stream to stdout instead, use '--output-format=tar' or
+'--output-format=car'. The stream is written to the file given with
+'--output' if any. The CAR holds all the blocks of the DAG of the path, in
+the order they're traversed.
+
+To extract part of a directory, use '--include' and '--exclude' with glob
+patterns: a pattern with a slash matches the path of an entry relative to the
+directory, and a pattern without one matches its name at any depth. A file is
+extracted if it matches an include pattern, or if there are none, and no
+exclude pattern. An excluded directory is skipped without being fetched, and
+a directory matching an include pattern is extracted entirely:
+
+  # the documentation, without the images
+  ipfs get --include=docs --exclude='*.png' /ipfs/<cid>
+
+  # the Markdown files at the top
+  ipfs get --include='./*.md' --output-format=tar /ipfs/<cid> | tar -t
+
+To download many paths, use '--batch' with the paths as arguments, or one
+per line on stdin. They're downloaded concurrently, '--parallel' at a time,
+with a session shared by all the paths, into the directory given with
+'--output', the current directory by default. The status of each path is
+reported once it's downloaded. '--rate-limit' caps the rate of the blocks
+fetched from the network for the whole batch, e.g. '10MiB' per second.
+
+Unlike the other downloads, those of '--batch' are written by the daemon:
+the directory must be on the machine running it.
+
+  ipfs get --batch --parallel=16 --rate-limit=20MiB -o ./data < cids.txt
 `,
 	},
 
 	Arguments: []cmds.Argument{
-		cmds.StringArg("ipfs-path", true, false, "The path to the IPFS object(s) to be outputted.").EnableStdin(),
+		cmds.StringArg("ipfs-path", true, true, "The path to the IPFS object(s) to be outputted, several with --batch.").EnableStdin(),
 	},
 	Options: []cmds.Option{
 		cmds.StringOption(outputOptionName, "o", "The path where the output should be stored."),
@@ -55,17 +98,50 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 		cmds.BoolOption(compressOptionName, "C", "Compress the output with GZIP compression."),
 		cmds.IntOption(compressionLevelOptionName, "l", "The level of compression (1-9)."),
 		cmds.BoolOption(progressOptionName, "p", "Stream progress data.").WithDefault(true),
+		cmds.StringOption(outputFormatOptionName, "Write a stream to stdout, or to --output: tar or car."),
+		cmds.StringsOption(includeOptionName, "Only extract the entries of directories matching the glob pattern. Can be repeated."),
+		cmds.StringsOption(excludeOptionName, "Skip the entries of directories matching the glob pattern. Can be repeated."),
+		cmds.BoolOption(batchOptionName, "Download the paths concurrently into the directory of --output."),
+		cmds.IntOption(parallelOptionName, fmt.Sprintf("Number of paths downloaded at a time with --batch. Default: %d.", defaultGetBatchParallel)),
+		cmds.StringOption(rateLimitOptionName, "Maximum rate of the blocks fetched for --batch, in bytes per second, e.g. 10MiB."),
 	},
 	PreRun: func(req *cmds.Request, env cmds.Environment) error {
-		_, err := getCompressOptions(req)
+		if batch, _ := req.Options[batchOptionName].(bool); batch {
+			if _, err := getBatchOptions(req); err != nil {
+				return err
+			}
+			// the paths are written by the daemon, resolve the directory here
+			out, _ := req.Options[outputOptionName].(string)
+			abs, err := filepath.Abs(out)
+			if err != nil {
+				return err
+			}
+			req.Options[outputOptionName] = abs
+			return nil
+		}
+		if _, err := getCompressOptions(req); err != nil {
+			return err
+		}
+		_, _, err := getOutputOptions(req)
 		return err
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if batch, _ := req.Options[batchOptionName].(bool); batch {
+			return getBatch(req, res, env)
+		}
+		if len(req.Arguments) > 1 {
+			return fmt.Errorf("only one path can be downloaded without --%s", batchOptionName)
+		}
+
 		ctx := req.Context
 		cmplvl, err := getCompressOptions(req)
 		if err != nil {
 			return err
 		}
+		format, filter, err := getOutputOptions(req)
+		if err != nil {
+			return err
+		}
 
 		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
@@ -74,20 +150,36 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 
 		p := path.New(req.Arguments[0])
 
-		file, err := api.Unixfs().Get(ctx, p)
-		if err != nil {
-			return err
+		if format == getFormatCar {
+			rp, err := api.ResolvePath(ctx, p)
+			if err != nil {
+				return err
+			}
+			reader, err := carArchive(ctx, api, rp.Cid(), cmplvl)
+			if err != nil {
+				return err
+			}
+			return res.Emit(reader)
 		}
 
-		size, err := file.Size()
+		file, err := api.Unixfs().Get(ctx, p)
 		if err != nil {
 			return err
 		}
 
-		res.SetLength(uint64(size))
+		if filter != nil {
+			// the size of the entries extracted isn't known beforehand
+			file = filter.apply(file)
+		} else {
+			size, err := file.Size()
+			if err != nil {
+				return err
+			}
+			res.SetLength(uint64(size))
+		}
 
 		archive, _ := req.Options[archiveOptionName].(bool)
-		reader, err := fileArchive(file, p.String(), archive, cmplvl)
+		reader, err := fileArchive(file, p.String(), archive || format == getFormatTar, cmplvl)
 		if err != nil {
 			return err
 		}
@@ -104,6 +196,9 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
 			req := res.Request()
+			if batch, _ := req.Options[batchOptionName].(bool); batch {
+				return cmds.Copy(re, res)
+			}
 
 			v, err := res.Next()
 			if err != nil {
@@ -115,12 +210,20 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 				return e.New(e.TypeErr(outReader, v))
 			}
 
-			outPath := getOutPath(req)
-
 			cmplvl, err := getCompressOptions(req)
 			if err != nil {
 				return err
 			}
+			format, _, err := getOutputOptions(req)
+			if err != nil {
+				return err
+			}
+
+			outPath := getOutPath(req)
+			if format != "" {
+				// streams go to stdout, unless --output is given
+				outPath, _ = req.Options[outputOptionName].(string)
+			}
 
 			archive, _ := req.Options[archiveOptionName].(bool)
 			progress, _ := req.Options[progressOptionName].(bool)
@@ -130,6 +233,7 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 				Err:         os.Stderr,
 				Archive:     archive,
 				Compression: cmplvl,
+				Format:      format,
 				Size:        int64(res.Length()),
 				Progress:    progress,
 			}
@@ -137,6 +241,10 @@ may also specify the level of compression by specifying '-l=<1-9>'.
 			return gw.Write(outReader, outPath)
 		},
 	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: getBatchEncoder,
+	},
+	Type: getBatchItem{},
 }
 
 type clearlineReader struct {
@@ -191,11 +299,15 @@ type getWriter struct {
 
 	Archive     bool
 	Compression int
+	Format      string // format of the stream, if any
 	Size        int64
 	Progress    bool
 }
 
 func (gw *getWriter) Write(r io.Reader, fpath string) error {
+	if gw.Format != "" {
+		return gw.writeStream(r, fpath)
+	}
 	if gw.Archive || gw.Compression != gzip.NoCompression {
 		return gw.writeArchive(r, fpath)
 	}
@@ -236,6 +348,34 @@ func (gw *getWriter) writeArchive(r io.Reader, fpath string) error {
 	return err
 }
 
+// writeStream writes the stream to the file at fpath, or to Out if fpath is
+// empty.
+func (gw *getWriter) writeStream(r io.Reader, fpath string) error {
+	if fpath == "" || fpath == "-" {
+		_, err := io.Copy(gw.Out, r)
+		return err
+	}
+
+	file, err := os.Create(fpath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintf(gw.Out, "Saving %s to %s\n", strings.ToUpper(gw.Format), fpath)
+	if gw.Progress {
+		var bar *pb.ProgressBar
+		bar, r = progressBarForReader(gw.Err, r, gw.Size)
+		bar.Start()
+		defer bar.Finish()
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 func (gw *getWriter) writeExtracted(r io.Reader, fpath string) error {
 	fmt.Fprintf(gw.Out, "Saving file(s) to %s\n", fpath)
 	var progressCb func(int64) int64
@@ -265,6 +405,31 @@ func getCompressOptions(req *cmds.Request) (int, error) {
 	return cmplvl, nil
 }
 
+// getOutputOptions returns the format of the stream output, if any, and the
+// filter of the entries extracted.
+func getOutputOptions(req *cmds.Request) (string, *getFilter, error) {
+	format, _ := req.Options[outputFormatOptionName].(string)
+	switch format {
+	case "", getFormatTar, getFormatCar:
+	default:
+		return "", nil, fmt.Errorf("unknown output format %q, expected %s or %s", format, getFormatTar, getFormatCar)
+	}
+	if archive, _ := req.Options[archiveOptionName].(bool); archive && format != "" {
+		return "", nil, fmt.Errorf("--%s and --%s can't be used together", archiveOptionName, outputFormatOptionName)
+	}
+
+	include, _ := req.Options[includeOptionName].([]string)
+	exclude, _ := req.Options[excludeOptionName].([]string)
+	filter, err := newGetFilter(include, exclude)
+	if err != nil {
+		return "", nil, err
+	}
+	if filter != nil && format == getFormatCar {
+		return "", nil, fmt.Errorf("--%s and --%s can't be used with --%s=%s", includeOptionName, excludeOptionName, outputFormatOptionName, getFormatCar)
+	}
+	return format, filter, nil
+}
+
 // DefaultBufSize is the buffer size for gets. for now, 1MiB, which is ~4 blocks.
 // TODO: does this need to be configurable?
 var DefaultBufSize = 1048576
@@ -349,6 +514,30 @@ func fileArchive(f files.Node, name string, archive bool, compression int) (io.R
 	return piper, nil
 }
 
+// carArchive returns the CAR of the DAG of root, compressed if compression
+// says so.
+func carArchive(ctx context.Context, api coreiface.CoreAPI, root cid.Cid, compression int) (io.ReadCloser, error) {
+	piper, pipew := io.Pipe()
+	bufw := bufio.NewWriterSize(pipew, DefaultBufSize)
+	maybeGzw, err := newMaybeGzWriter(bufw, compression)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := car.WriteCar(ctx, api.Dag(), []cid.Cid{root}, maybeGzw)
+		if err == nil {
+			err = maybeGzw.Close()
+		}
+		if err == nil {
+			err = bufw.Flush()
+		}
+		// a nil error closes the pipe
+		_ = pipew.CloseWithError(err)
+	}()
+	return piper, nil
+}
+
 func newMaybeGzWriter(w io.Writer, compression int) (io.WriteCloser, error) {
 	if compression != gzip.NoCompression {
 		return gzip.NewWriterLevel(w, compression)
@@ -0,0 +1,346 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-libipfs/files"
+	dag "github.com/ipfs/go-merkledag"
+	unixfile "github.com/ipfs/go-unixfs/file"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+const (
+	batchOptionName     = "batch"
+	parallelOptionName  = "parallel"
+	rateLimitOptionName = "rate-limit"
+
+	defaultGetBatchParallel = 8
+)
+
+// getBatchItem is the status of a path downloaded by 'ipfs get --batch'.
+type getBatchItem struct {
+	Path     string
+	Cid      string `json:",omitempty"`
+	Output   string `json:",omitempty"`
+	Size     int64  `json:",omitempty"`
+	Duration string `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// getBatchPaths returns the paths of the batch, one per argument or line of
+// stdin, without the blank lines and the comments.
+func getBatchPaths(args []string) []string {
+	var paths []string
+	for _, arg := range args {
+		arg = strings.TrimSpace(arg)
+		if arg == "" || strings.HasPrefix(arg, "#") {
+			continue
+		}
+		paths = append(paths, arg)
+	}
+	return paths
+}
+
+// getBatchOptions checks the options given with --batch, and returns the
+// rate limit of the batch in bytes per second, 0 for none.
+func getBatchOptions(req *cmds.Request) (uint64, error) {
+	for _, name := range []string{archiveOptionName, compressOptionName, outputFormatOptionName} {
+		if _, ok := req.Options[name]; ok {
+			return 0, fmt.Errorf("--%s can't be used with --%s", name, batchOptionName)
+		}
+	}
+	if parallel, ok := req.Options[parallelOptionName].(int); ok && parallel < 1 {
+		return 0, fmt.Errorf("--%s must be positive", parallelOptionName)
+	}
+	limit, _ := req.Options[rateLimitOptionName].(string)
+	if limit == "" {
+		return 0, nil
+	}
+	rate, err := humanize.ParseBytes(limit)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --%s: %w", rateLimitOptionName, err)
+	}
+	return rate, nil
+}
+
+// getBatch downloads the paths of the arguments concurrently, with a shared
+// session, into the directory of --output, and emits the status of each.
+func getBatch(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	ctx := req.Context
+	rate, err := getBatchOptions(req)
+	if err != nil {
+		return err
+	}
+	_, filter, err := getOutputOptions(req)
+	if err != nil {
+		return err
+	}
+	outDir, _ := req.Options[outputOptionName].(string)
+	if !filepath.IsAbs(outDir) {
+		return fmt.Errorf("the output directory %q must be an absolute path", outDir)
+	}
+	parallel, ok := req.Options[parallelOptionName].(int)
+	if !ok {
+		parallel = defaultGetBatchParallel
+	}
+
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+
+	// all the items share a session, finding the providers of the blocks
+	// once for the items with the same providers
+	var ng ipld.NodeGetter = dag.NewSession(ctx, nd.DAG)
+	if rate > 0 {
+		ng = &rateLimitedGetter{NodeGetter: ng, bs: nd.Blockstore, limiter: newByteRateLimiter(float64(rate))}
+	}
+	dserv := dag.NewReadOnlyDagService(ng)
+
+	var mu sync.Mutex
+	outputs := make(map[string]string)
+	get := func(p string) *getBatchItem {
+		start := time.Now()
+		item := &getBatchItem{Path: p}
+		fail := func(err error) *getBatchItem {
+			item.Error = err.Error()
+			return item
+		}
+
+		rp, err := api.ResolvePath(ctx, path.New(p))
+		if err != nil {
+			return fail(err)
+		}
+		item.Cid = rp.Cid().String()
+
+		name := filepath.Base(filepath.Clean(strings.TrimRight(p, "/")))
+		mu.Lock()
+		other, taken := outputs[name]
+		if !taken {
+			outputs[name] = p
+		}
+		mu.Unlock()
+		if taken {
+			return fail(fmt.Errorf("the output %s is already the one of %s", name, other))
+		}
+		item.Output = filepath.Join(outDir, name)
+
+		root, err := dserv.Get(ctx, rp.Cid())
+		if err != nil {
+			return fail(err)
+		}
+		var file files.Node
+		if file, err = unixfile.NewUnixfsFile(ctx, dserv, root); err != nil {
+			return fail(err)
+		}
+		if filter != nil {
+			file = filter.apply(file)
+		}
+		w := &countingNode{}
+		if err := files.WriteTo(w.wrap(file), item.Output); err != nil {
+			return fail(err)
+		}
+		item.Size = w.n
+		item.Duration = time.Since(start).Round(time.Millisecond).String()
+		return item
+	}
+
+	paths := getBatchPaths(req.Arguments)
+	todo := make(chan string)
+	done := make(chan *getBatchItem, len(paths))
+	var wg sync.WaitGroup
+	for i := 0; i < parallel && i < len(paths); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range todo {
+				done <- get(p)
+			}
+		}()
+	}
+	go func() {
+		defer close(todo)
+		for _, p := range paths {
+			select {
+			case todo <- p:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	failed := 0
+	for item := range done {
+		if item.Error != "" {
+			failed++
+		}
+		if err := res.Emit(item); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d paths failed to download", failed, len(paths))
+	}
+	return nil
+}
+
+var getBatchEncoder = cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, item *getBatchItem) error {
+	if item.Error != "" {
+		_, err := fmt.Fprintf(w, "failed %s: %s\n", item.Path, item.Error)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "saved %s to %s (%s in %s)\n", item.Path, item.Output, humanize.IBytes(uint64(item.Size)), item.Duration)
+	return err
+})
+
+// countingNode counts the bytes read from the files of a node.
+type countingNode struct {
+	n int64
+}
+
+func (c *countingNode) wrap(nd files.Node) files.Node {
+	switch nd := nd.(type) {
+	case *files.Symlink:
+		// written as a link by files.WriteTo
+		return nd
+	case files.File:
+		return &countingFile{File: nd, c: c}
+	case files.Directory:
+		return &countingDirectory{Directory: nd, c: c}
+	}
+	return nd
+}
+
+type countingFile struct {
+	files.File
+	c *countingNode
+}
+
+func (f *countingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.c.n += int64(n)
+	return n, err
+}
+
+type countingDirectory struct {
+	files.Directory
+	c *countingNode
+}
+
+func (d *countingDirectory) Entries() files.DirIterator {
+	return &countingIterator{DirIterator: d.Directory.Entries(), c: d.c}
+}
+
+type countingIterator struct {
+	files.DirIterator
+	c *countingNode
+}
+
+func (it *countingIterator) Node() files.Node {
+	return it.c.wrap(it.DirIterator.Node())
+}
+
+// rateLimitedGetter paces the nodes fetched from the network to the rate of
+// its limiter. The local nodes aren't limited.
+type rateLimitedGetter struct {
+	ipld.NodeGetter
+	bs      blockstore.Blockstore
+	limiter *byteRateLimiter
+}
+
+func (g *rateLimitedGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	local, _ := g.bs.Has(ctx, c)
+	nd, err := g.NodeGetter.Get(ctx, c)
+	if err != nil || local {
+		return nd, err
+	}
+	if err := g.limiter.wait(ctx, len(nd.RawData())); err != nil {
+		return nil, err
+	}
+	return nd, nil
+}
+
+func (g *rateLimitedGetter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	local := make(map[cid.Cid]bool, len(cids))
+	for _, c := range cids {
+		if has, _ := g.bs.Has(ctx, c); has {
+			local[c] = true
+		}
+	}
+	in := g.NodeGetter.GetMany(ctx, cids)
+	out := make(chan *ipld.NodeOption, len(cids))
+	go func() {
+		defer close(out)
+		for opt := range in {
+			if opt.Err == nil && !local[opt.Node.Cid()] {
+				if err := g.limiter.wait(ctx, len(opt.Node.RawData())); err != nil {
+					opt = &ipld.NodeOption{Err: err}
+				}
+			}
+			select {
+			case out <- opt:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// byteRateLimiter paces the bytes of all its callers to a rate, in bytes per
+// second.
+type byteRateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time // when the bytes already paced are through
+}
+
+func newByteRateLimiter(rate float64) *byteRateLimiter {
+	return &byteRateLimiter{rate: rate}
+}
+
+// wait waits for the time n bytes take at the rate, after the bytes of the
+// other callers.
+func (l *byteRateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	until := l.next
+	l.mu.Unlock()
+
+	t := time.NewTimer(time.Until(until))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetBatchPaths(t *testing.T) {
+	args := []string{"/ipfs/a", "", "  /ipfs/b/c  ", "# comment", "/ipns/d"}
+	expected := []string{"/ipfs/a", "/ipfs/b/c", "/ipns/d"}
+	if paths := getBatchPaths(args); !reflect.DeepEqual(paths, expected) {
+		t.Errorf("got %q, expected %q", paths, expected)
+	}
+}
+
+func TestByteRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := newByteRateLimiter(10000)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(ctx, 500); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("1500 bytes at 10000 B/s took %s, expected 150ms", elapsed)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.wait(cctx, 100000); err != context.Canceled {
+		t.Errorf("expected the wait to be canceled, got %v", err)
+	}
+}
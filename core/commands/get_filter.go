@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	gopath "path"
+	"strings"
+
+	"github.com/ipfs/go-libipfs/files"
+)
+
+// getFilter selects the entries of the directories extracted by 'ipfs get'
+// with glob patterns. A pattern with a slash matches the path of an entry
+// relative to the directory extracted, e.g. 'docs/*.md' or './*.md', and a
+// pattern without one matches its name at any depth, e.g. '*.md'.
+type getFilter struct {
+	include []string
+	exclude []string
+}
+
+// newGetFilter returns the filter of the patterns, nil if there are none.
+func newGetFilter(include, exclude []string) (*getFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string(nil), include...), exclude...) {
+		if _, err := gopath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+		}
+	}
+	return &getFilter{include: include, exclude: exclude}, nil
+}
+
+func matchAny(patterns []string, rel string) bool {
+	name := gopath.Base(rel)
+	for _, pattern := range patterns {
+		subject := name
+		if strings.Contains(pattern, "/") {
+			subject = rel
+		}
+		pattern = strings.Trim(strings.TrimPrefix(pattern, "./"), "/")
+		if ok, _ := gopath.Match(pattern, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// match tells whether the entry at rel is kept, and for a directory whether
+// its entries are all included, excluded entries aside. The excluded
+// directories aren't traversed, and the other directories are, whether or
+// not they include any file.
+func (f *getFilter) match(rel string, isDir, all bool) (keep, allIncluded bool) {
+	if matchAny(f.exclude, rel) {
+		return false, false
+	}
+	included := all || len(f.include) == 0 || matchAny(f.include, rel)
+	if isDir {
+		return true, included
+	}
+	return included, false
+}
+
+// apply returns nd with the entries of its directories filtered.
+func (f *getFilter) apply(nd files.Node) files.Node {
+	dir, ok := nd.(files.Directory)
+	if !ok {
+		return nd
+	}
+	return &filteredDirectory{Directory: dir, filter: f}
+}
+
+type filteredDirectory struct {
+	files.Directory
+	filter *getFilter
+	// rel is the path of the directory relative to the one extracted
+	rel string
+	all bool
+}
+
+func (d *filteredDirectory) Entries() files.DirIterator {
+	return &filteredIterator{DirIterator: d.Directory.Entries(), dir: d}
+}
+
+type filteredIterator struct {
+	files.DirIterator
+	dir  *filteredDirectory
+	node files.Node
+}
+
+func (it *filteredIterator) Next() bool {
+	for it.DirIterator.Next() {
+		rel := gopath.Join(it.dir.rel, it.DirIterator.Name())
+		nd := it.DirIterator.Node()
+		sub, isDir := nd.(files.Directory)
+		keep, all := it.dir.filter.match(rel, isDir, it.dir.all)
+		if !keep {
+			nd.Close()
+			continue
+		}
+		if isDir {
+			nd = &filteredDirectory{Directory: sub, filter: it.dir.filter, rel: rel, all: all}
+		}
+		it.node = nd
+		return true
+	}
+	return false
+}
+
+func (it *filteredIterator) Node() files.Node {
+	return it.node
+}
@@ -0,0 +1,87 @@
+package commands
+
+import (
+	gopath "path"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ipfs/go-libipfs/files"
+)
+
+func filteredPaths(t *testing.T, dir files.Directory, rel string) []string {
+	var paths []string
+	it := dir.Entries()
+	for it.Next() {
+		p := gopath.Join(rel, it.Name())
+		paths = append(paths, p)
+		if sub, ok := it.Node().(files.Directory); ok {
+			paths = append(paths, filteredPaths(t, sub, p)...)
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestGetFilter(t *testing.T) {
+	tree := func() files.Node {
+		return files.NewMapDirectory(map[string]files.Node{
+			"README.md": files.NewBytesFile([]byte("readme")),
+			"main.go":   files.NewBytesFile([]byte("package main")),
+			"docs": files.NewMapDirectory(map[string]files.Node{
+				"guide.md": files.NewBytesFile([]byte("guide")),
+				"logo.png": files.NewBytesFile([]byte("png")),
+			}),
+			"vendor": files.NewMapDirectory(map[string]files.Node{
+				"lib.md": files.NewBytesFile([]byte("lib")),
+			}),
+		})
+	}
+
+	cases := []struct {
+		include, exclude []string
+		paths            []string
+	}{
+		{
+			include: []string{"*.md"},
+			paths:   []string{"README.md", "docs", "docs/guide.md", "vendor", "vendor/lib.md"},
+		},
+		{
+			include: []string{"./*.md"},
+			paths:   []string{"README.md", "docs", "vendor"},
+		},
+		{
+			include: []string{"docs"},
+			exclude: []string{"*.png"},
+			paths:   []string{"docs", "docs/guide.md", "vendor"},
+		},
+		{
+			exclude: []string{"vendor"},
+			paths:   []string{"README.md", "docs", "docs/guide.md", "docs/logo.png", "main.go"},
+		},
+		{
+			include: []string{"docs/*.png", "main.go"},
+			paths:   []string{"docs", "docs/logo.png", "main.go", "vendor"},
+		},
+	}
+	for _, c := range cases {
+		f, err := newGetFilter(c.include, c.exclude)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := filteredPaths(t, f.apply(tree()).(files.Directory), "")
+		if !reflect.DeepEqual(paths, c.paths) {
+			t.Errorf("include %q, exclude %q: got %q, expected %q", c.include, c.exclude, paths, c.paths)
+		}
+	}
+
+	if f, err := newGetFilter(nil, nil); f != nil || err != nil {
+		t.Errorf("expected no filter without patterns, got %v, %v", f, err)
+	}
+	if _, err := newGetFilter([]string{"[a-"}, nil); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
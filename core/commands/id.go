@@ -8,9 +8,11 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"time"
 
 	version "github.com/ipfs/kubo"
 	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/autonatv2"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -32,11 +34,28 @@ type IdOutput struct { //nolint
 	AgentVersion    string
 	ProtocolVersion string
 	Protocols       []string
+	// Capabilities are the protocols and transports probed with --probe.
+	Capabilities *IdCapabilities `json:",omitempty"`
+	// Reachability is the reachability of the addresses of the node found
+	// by AutoNAT v2, with --reachability.
+	Reachability []AddrReachability `json:",omitempty"`
+}
+
+// AddrReachability is the reachability of an address of the node.
+type AddrReachability struct {
+	Address      string
+	Reachability string
+	// Checked is the time of the last check, and Server the peer which
+	// made it.
+	Checked time.Time
+	Server  string
 }
 
 const (
-	formatOptionName   = "format"
-	idFormatOptionName = "peerid-base"
+	formatOptionName         = "format"
+	idFormatOptionName       = "peerid-base"
+	idProbeOptionName        = "probe"
+	idReachabilityOptionName = "reachability"
 )
 
 var IDCmd = &cmds.Command{
@@ -53,10 +72,23 @@ If no peer is specified, prints out information for local peers.
 <pubkey>: Public key.
 <addrs>: Addresses (newline delimited).
 <protocols>: Libp2p Protocol registrations (newline delimited).
+<capabilities>: Probed protocols and transports, with --probe (newline delimited).
+<reachability>: Reachability of the addresses, with --reachability (newline delimited).
+
+With --probe, the protocols of a remote peer are probed by negotiating them
+on new streams, e.g. the bitswap, DHT, graphsync and relay versions, and
+reported along with the transports of its addresses. The probed protocols
+may differ from the ones the peer announces.
+
+With --reachability, the reachability of each public address of the node is
+reported as found by AutoNAT v2, enabled with AutoNAT.V2: public if a peer
+dialed it, private if several peers failed to. The private addresses are not
+advertised.
 
 EXAMPLE:
 
     ipfs id Qmece2RkXhsKe5CRooNisBTh4SK119KrXXGmoK6V3kb8aH -f="<addrs>\n"
+    ipfs id --probe Qmece2RkXhsKe5CRooNisBTh4SK119KrXXGmoK6V3kb8aH -f="<capabilities>"
 `,
 	},
 	Arguments: []cmds.Argument{
@@ -65,6 +97,8 @@ EXAMPLE:
 	Options: []cmds.Option{
 		cmds.StringOption(formatOptionName, "f", "Optional output format."),
 		cmds.StringOption(idFormatOptionName, "Encoding used for peer IDs: Can either be a multibase encoded CID or a base58btc encoded multihash. Takes {b58mh|base36|k|base32|b...}.").WithDefault("b58mh"),
+		cmds.BoolOption(idProbeOptionName, "Probe the protocols and transports supported by the remote peer."),
+		cmds.BoolOption(idReachabilityOptionName, "Report the reachability of the addresses of the node found by AutoNAT v2."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		keyEnc, err := ke.KeyEncoderFromString(req.Options[idFormatOptionName].(string))
@@ -88,18 +122,35 @@ EXAMPLE:
 			id = n.Identity
 		}
 
+		probe, _ := req.Options[idProbeOptionName].(bool)
+		reachability, _ := req.Options[idReachabilityOptionName].(bool)
 		if id == n.Identity {
+			if probe {
+				return errors.New("--probe needs a remote peer")
+			}
 			output, err := printSelf(keyEnc, n)
 			if err != nil {
 				return err
 			}
+			if reachability {
+				if n.AutoNATV2 == nil {
+					return errors.New("the reachability of the addresses is only checked by a running daemon with AutoNAT.V2 enabled")
+				}
+				output.(*IdOutput).Reachability = addrsReachability(keyEnc, n.AutoNATV2.Results().All())
+			}
 			return cmds.EmitOnce(res, output)
 		}
+		if reachability {
+			return errors.New("--reachability is only reported for the node itself")
+		}
 
 		offline, _ := req.Options[OfflineOption].(bool)
 		if !offline && !n.IsOnline {
 			return errors.New(offlineIDErrorMessage)
 		}
+		if probe && offline {
+			return errors.New("remote peers can't be probed with --offline")
+		}
 
 		if !offline {
 			// We need to actually connect to run identify.
@@ -117,6 +168,9 @@ EXAMPLE:
 		if err != nil {
 			return err
 		}
+		if probe {
+			output.(*IdOutput).Capabilities = probeCapabilities(req.Context, n, id)
+		}
 		return cmds.EmitOnce(res, output)
 	},
 	Encoders: cmds.EncoderMap{
@@ -130,6 +184,8 @@ EXAMPLE:
 				output = strings.Replace(output, "<pubkey>", out.PublicKey, -1)
 				output = strings.Replace(output, "<addrs>", strings.Join(out.Addresses, "\n"), -1)
 				output = strings.Replace(output, "<protocols>", strings.Join(out.Protocols, "\n"), -1)
+				output = strings.Replace(output, "<capabilities>", formatCapabilities(out.Capabilities), -1)
+				output = strings.Replace(output, "<reachability>", formatReachability(out.Reachability), -1)
 				output = strings.Replace(output, "\\n", "\n", -1)
 				output = strings.Replace(output, "\\t", "\t", -1)
 				fmt.Fprint(w, output)
@@ -222,3 +278,26 @@ func printSelf(keyEnc ke.KeyEncoder, node *core.IpfsNode) (interface{}, error) {
 	info.AgentVersion = version.GetUserAgentVersion()
 	return info, nil
 }
+
+func addrsReachability(keyEnc ke.KeyEncoder, results []autonatv2.Result) []AddrReachability {
+	out := make([]AddrReachability, len(results))
+	for i, r := range results {
+		out[i] = AddrReachability{
+			Address:      r.Addr.String(),
+			Reachability: r.Reachability.String(),
+			Checked:      r.Checked,
+			Server:       keyEnc.FormatID(r.Server),
+		}
+	}
+	return out
+}
+
+// formatReachability formats the reachability as lines of "<address>
+// <reachability>".
+func formatReachability(rs []AddrReachability) string {
+	lines := make([]string, len(rs))
+	for i, r := range rs {
+		lines[i] = fmt.Sprintf("%s\t%s", r.Address, r.Reachability)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,185 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/kubo/core"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// IdCapabilities are the protocols and transports supported by a peer,
+// reported by 'ipfs id --probe'.
+type IdCapabilities struct { //nolint
+	Protocols  []ProtocolSupport
+	Transports []TransportSupport
+}
+
+// ProtocolSupport is whether a peer supports a protocol.
+type ProtocolSupport struct {
+	Protocol string
+	Category string
+	// Announced is whether the peer lists the protocol with identify.
+	Announced bool
+	// Supported is whether the peer accepted the protocol when probed.
+	Supported bool
+	Error     string `json:",omitempty"`
+}
+
+// TransportSupport is whether a peer can be reached with a transport.
+type TransportSupport struct {
+	Transport string
+	// Addresses is the number of addresses of the peer with the transport.
+	Addresses int
+	// Connected is whether the node is connected to the peer with it.
+	Connected bool
+}
+
+// probeTimeout bounds the negotiation of each probed protocol.
+const probeTimeout = 10 * time.Second
+
+// probedProtocols are the protocols probed on every peer, by category.
+var probedProtocols = []struct {
+	category  string
+	protocols []string
+}{
+	{"bitswap", []string{"/ipfs/bitswap/1.2.0", "/ipfs/bitswap/1.1.0", "/ipfs/bitswap/1.0.0", "/ipfs/bitswap"}},
+	{"dht", []string{"/ipfs/kad/1.0.0", "/ipfs/lan/kad/1.0.0"}},
+	{"graphsync", []string{"/ipfs/graphsync/2.0.0", "/ipfs/graphsync/1.0.0"}},
+	{"relay", []string{"/libp2p/circuit/relay/0.2.0/hop", "/libp2p/circuit/relay/0.2.0/stop", "/libp2p/circuit/relay/0.1.0"}},
+	{"hole punching", []string{"/libp2p/dcutr"}},
+	{"autonat", []string{"/libp2p/autonat/1.0.0", "/libp2p/autonat/2/dial-request"}},
+	{"pubsub", []string{"/meshsub/1.1.0", "/meshsub/1.0.0", "/floodsub/1.0.0"}},
+	{"ipns", []string{"/libp2p/fetch/0.0.1"}},
+	{"identify", []string{"/ipfs/id/1.0.0", "/ipfs/id/push/1.0.0"}},
+	{"ping", []string{"/ipfs/ping/1.0.0"}},
+}
+
+// probeCapabilities negotiates the probed protocols and the ones announced
+// by the peer on new streams, in parallel, and lists the transports of its
+// addresses.
+func probeCapabilities(ctx context.Context, n *core.IpfsNode, id peer.ID) *IdCapabilities {
+	announced := make(map[string]bool)
+	protocols, _ := n.Peerstore.GetProtocols(id)
+	for _, p := range protocols {
+		announced[string(p)] = true
+	}
+
+	var out IdCapabilities
+	seen := make(map[string]bool)
+	for _, c := range probedProtocols {
+		for _, p := range c.protocols {
+			seen[p] = true
+			out.Protocols = append(out.Protocols, ProtocolSupport{Protocol: p, Category: c.category, Announced: announced[p]})
+		}
+	}
+	var others []string
+	for p := range announced {
+		if !seen[p] {
+			others = append(others, p)
+		}
+	}
+	sort.Strings(others)
+	for _, p := range others {
+		out.Protocols = append(out.Protocols, ProtocolSupport{Protocol: p, Category: "other", Announced: true})
+	}
+
+	var wg sync.WaitGroup
+	for i := range out.Protocols {
+		wg.Add(1)
+		go func(ps *ProtocolSupport) {
+			defer wg.Done()
+			if err := probeProtocol(ctx, n, id, ps.Protocol); err != nil {
+				ps.Error = err.Error()
+				return
+			}
+			ps.Supported = true
+		}(&out.Protocols[i])
+	}
+
+	transports := make(map[string]*TransportSupport)
+	transport := func(a ma.Multiaddr) *TransportSupport {
+		name := transportName(a)
+		t, ok := transports[name]
+		if !ok {
+			t = &TransportSupport{Transport: name}
+			transports[name] = t
+		}
+		return t
+	}
+	for _, a := range n.Peerstore.Addrs(id) {
+		transport(a).Addresses++
+	}
+	for _, c := range n.PeerHost.Network().ConnsToPeer(id) {
+		transport(c.RemoteMultiaddr()).Connected = true
+	}
+	for _, t := range transports {
+		out.Transports = append(out.Transports, *t)
+	}
+	sort.Slice(out.Transports, func(i, j int) bool {
+		return out.Transports[i].Transport < out.Transports[j].Transport
+	})
+
+	wg.Wait()
+	return &out
+}
+
+// probeProtocol negotiates a protocol on a new stream to the peer, without
+// relying on the protocols the peer announced, and resets the stream.
+func probeProtocol(ctx context.Context, n *core.IpfsNode, id peer.ID, proto string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	s, err := n.PeerHost.Network().NewStream(ctx, id)
+	if err != nil {
+		return err
+	}
+	defer s.Reset()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = s.SetDeadline(deadline)
+	}
+	return msmux.SelectProtoOrFail(proto, s)
+}
+
+// transportName returns the transport of an address, e.g. "quic-v1".
+func transportName(a ma.Multiaddr) string {
+	has := make(map[string]bool)
+	for _, p := range a.Protocols() {
+		has[p.Name] = true
+	}
+	for _, name := range []string{"p2p-circuit", "webtransport", "webrtc", "quic-v1", "quic"} {
+		if has[name] {
+			return name
+		}
+	}
+	switch {
+	case has["ws"] || has["wss"]:
+		return "websocket"
+	case has["tcp"]:
+		return "tcp"
+	}
+	return "other"
+}
+
+// formatCapabilities formats the capabilities as lines of "<protocol>
+// <category> <announced> <supported>" and "<transport> <addresses>
+// <connected>".
+func formatCapabilities(c *IdCapabilities) string {
+	if c == nil {
+		return ""
+	}
+	var lines []string
+	for _, p := range c.Protocols {
+		lines = append(lines, fmt.Sprintf("%s\t%s\tannounced=%t\tsupported=%t", p.Protocol, p.Category, p.Announced, p.Supported))
+	}
+	for _, t := range c.Transports {
+		lines = append(lines, fmt.Sprintf("%s\taddresses=%d\tconnected=%t", t.Transport, t.Addresses, t.Connected))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,233 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/jobs"
+)
+
+const jobsWatchOptionName = "watch"
+
+var JobsCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the background jobs of the RPC API.",
+		ShortDescription: `
+Some RPC commands run as background jobs when called with the 'async=true'
+query parameter, e.g. 'POST /api/v0/add?async=true': the daemon responds with
+the ID of the job right away, and the job keeps running when the client
+disconnects. These commands query the progress, results and output of the
+jobs, and cancel them.
+`,
+		LongDescription: `
+Some RPC commands run as background jobs when called with the 'async=true'
+query parameter, e.g. 'POST /api/v0/add?async=true': the daemon responds with
+the ID of the job right away, and the job keeps running when the client
+disconnects. These commands query the progress, results and output of the
+jobs, and cancel them.
+
+The commands which can run as jobs are:
+
+  add           the added files are the results of the job
+  pin add       the pinned CIDs are the results of the job
+  dag import    the imported roots are the results of the job
+  dag export    the CAR file is the output of the job
+  repo gc       the removed CIDs are the results of the job
+
+The jobs are kept in memory: they are cancelled when the daemon stops. Only
+the last 100 finished jobs, and the last 1000 results of each job, are kept.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":      lsJobsCmd,
+		"status":  statusJobsCmd,
+		"results": resultsJobsCmd,
+		"output":  outputJobsCmd,
+		"cancel":  cancelJobsCmd,
+	},
+}
+
+func encodeJobStatus(w io.Writer, s *jobs.Status) {
+	state := string(s.State)
+	if s.Err != "" {
+		state += ": " + s.Err
+	}
+	fmt.Fprintf(w, "%s\t%s\t%s\t%d results\n", s.ID, s.Command, state, s.Results)
+}
+
+var lsJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the background jobs.",
+	},
+	NoLocal: true,
+	Type:    jobs.Status{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		for _, s := range nd.Jobs.List() {
+			s := s
+			if err := res.Emit(&s); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *jobs.Status) error {
+			encodeJobStatus(w, s)
+			return nil
+		}),
+	},
+}
+
+var statusJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the status of a background job.",
+		ShortDescription: `
+Shows the state and the last progress of a job. With --watch, the status is
+streamed on every change, until the job is finished.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, false, "ID of the job."),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(jobsWatchOptionName, "w", "Stream the status until the job is finished."),
+	},
+	NoLocal: true,
+	Type:    jobs.Status{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		id := req.Arguments[0]
+		s, err := nd.Jobs.Status(id)
+		if err != nil {
+			return err
+		}
+		if err := res.Emit(&s); err != nil {
+			return err
+		}
+		if watch, _ := req.Options[jobsWatchOptionName].(bool); !watch {
+			return nil
+		}
+		for !s.State.Final() {
+			if s, err = nd.Jobs.Next(req.Context, id, s.Seq); err != nil {
+				return err
+			}
+			if err := res.Emit(&s); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *jobs.Status) error {
+			encodeJobStatus(w, s)
+			if s.Progress != nil && !s.State.Final() {
+				b, err := json.Marshal(s.Progress)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "\tprogress: %s\n", b)
+			}
+			return nil
+		}),
+	},
+}
+
+var resultsJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the results of a background job.",
+		ShortDescription: `
+Shows the results of a job so far, as returned by its RPC command, one JSON
+value per line.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, false, "ID of the job."),
+	},
+	NoLocal: true,
+	Type:    json.RawMessage{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		results, err := nd.Jobs.Results(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if err := res.Emit(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeEncoder(func(req *cmds.Request, w io.Writer, v interface{}) error {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\n", b)
+			return nil
+		}),
+	},
+}
+
+var outputJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Stream the output of a finished background job.",
+		ShortDescription: `
+Streams the output of a job which is done, e.g. the CAR file of
+'dag export'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, false, "ID of the job."),
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		r, err := nd.Jobs.Output(req.Arguments[0])
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		return res.Emit(r)
+	},
+}
+
+var cancelJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel background jobs.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, true, "ID of the jobs to cancel."),
+	},
+	NoLocal: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		for _, id := range req.Arguments {
+			if err := nd.Jobs.Cancel(id); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		return nil
+	},
+}
@@ -11,15 +11,19 @@ import (
 	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	keystore "github.com/ipfs/go-ipfs-keystore"
 	options "github.com/ipfs/interface-go-ipfs-core/options"
+	nsopts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
 	oldcmds "github.com/ipfs/kubo/commands"
 	config "github.com/ipfs/kubo/config"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	"github.com/ipfs/kubo/core/commands/e"
 	ke "github.com/ipfs/kubo/core/commands/keyencode"
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/core/spaces"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	migrations "github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -41,6 +45,9 @@ publish'.
   > ipfs key list
   self
   mykey
+
+With --space, 'ipfs key gen', 'ipfs key list', 'ipfs key rename' and
+'ipfs key rm' only see the keys of a space, see 'ipfs space --help'.
 		`,
 	},
 	Subcommands: map[string]*cmds.Command{
@@ -51,9 +58,19 @@ publish'.
 		"rename": keyRenameCmd,
 		"rm":     keyRmCmd,
 		"rotate": keyRotateCmd,
+		"share":  keyShareCmd,
 	},
 }
 
+// spaceKeyName returns the name in the keystore of the key name of the
+// --space of the request.
+func spaceKeyName(req *cmds.Request, name string) string {
+	if space := cmdenv.GetSpace(req); space != "" {
+		return spaces.KeyName(space, name)
+	}
+	return name
+}
+
 type KeyOutput struct {
 	Name string
 	Id   string //nolint
@@ -86,6 +103,7 @@ var keyGenCmd = &cmds.Command{
 		cmds.StringOption(keyStoreTypeOptionName, "t", "type of the key to create: rsa, ed25519").WithDefault(keyStoreAlgorithmDefault),
 		cmds.IntOption(keyStoreSizeOptionName, "s", "size of the key to generate"),
 		ke.OptionIPNSBase,
+		cmdenv.OptionSpace,
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("name", true, false, "name of key to create"),
@@ -117,7 +135,7 @@ var keyGenCmd = &cmds.Command{
 			return err
 		}
 
-		key, err := api.Key().Generate(req.Context, name, opts...)
+		key, err := api.Key().Generate(req.Context, spaceKeyName(req, name), opts...)
 
 		if err != nil {
 			return err
@@ -453,6 +471,7 @@ var keyListCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("l", "Show extra information about keys."),
 		ke.OptionIPNSBase,
+		cmdenv.OptionSpace,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
@@ -472,9 +491,17 @@ var keyListCmd = &cmds.Command{
 
 		list := make([]KeyOutput, 0, len(keys))
 
+		space := cmdenv.GetSpace(req)
 		for _, key := range keys {
+			name := key.Name()
+			if space != "" {
+				var ok bool
+				if name, ok = spaces.KeyInSpace(space, name); !ok {
+					continue
+				}
+			}
 			list = append(list, KeyOutput{
-				Name: key.Name(),
+				Name: name,
 				Id:   keyEnc.FormatID(key.ID()),
 			})
 		}
@@ -502,6 +529,7 @@ var keyRenameCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption(keyStoreForceOptionName, "f", "Allow to overwrite an existing key."),
 		ke.OptionIPNSBase,
+		cmdenv.OptionSpace,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -517,7 +545,7 @@ var keyRenameCmd = &cmds.Command{
 		newName := req.Arguments[1]
 		force, _ := req.Options[keyStoreForceOptionName].(bool)
 
-		key, overwritten, err := api.Key().Rename(req.Context, name, newName, options.Key.Force(force))
+		key, overwritten, err := api.Key().Rename(req.Context, spaceKeyName(req, name), spaceKeyName(req, newName), options.Key.Force(force))
 		if err != nil {
 			return err
 		}
@@ -552,6 +580,7 @@ var keyRmCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption("l", "Show extra information about keys."),
 		ke.OptionIPNSBase,
+		cmdenv.OptionSpace,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -567,7 +596,7 @@ var keyRmCmd = &cmds.Command{
 
 		list := make([]KeyOutput, 0, len(names))
 		for _, name := range names {
-			key, err := api.Key().Remove(req.Context, name)
+			key, err := api.Key().Remove(req.Context, spaceKeyName(req, name))
 			if err != nil {
 				return err
 			}
@@ -586,14 +615,38 @@ var keyRmCmd = &cmds.Command{
 	Type: KeyOutputList{},
 }
 
+const (
+	keyGraceOptionName        = "grace"
+	keyAllowOfflineOptionName = "allow-offline"
+)
+
+// KeyRotateOutput is the output of 'ipfs key rotate <name>'.
+type KeyRotateOutput struct {
+	Name string
+	// Old is the name of the rotated key, and Id its ID.
+	Old   string
+	OldId string
+	NewId string
+	// Until is the end of the grace period of the forwarding record.
+	Until time.Time
+}
+
 var keyRotateCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
-		Tagline: "Rotates the IPFS identity.",
+		Tagline: "Rotates the IPFS identity or an IPNS key.",
 		ShortDescription: `
-Generates a new ipfs identity and saves it to the ipfs config file.
-Your existing identity key will be backed up in the Keystore.
+Without a name, generates a new ipfs identity and saves it to the ipfs
+config file. Your existing identity key will be backed up in the Keystore.
 The daemon must not be running when calling this command.
 
+With a name, generates a new key with that name, keeps the existing key as
+--oldkey (<name>-retired by default), and publishes a forwarding record from
+the old IPNS name to the new one, valid for the --grace period. The current
+value of the old name is published with the new key. Once the grace period
+is over, the forwarding record isn't republished anymore and expires.
+
+  > ipfs key rotate --grace=168h mykey
+
 ipfs uses a repository in the local file system. By default, the repo is
 located at ~/.ipfs. To change the repo location, set the $IPFS_PATH
 environment variable:
@@ -601,15 +654,28 @@ environment variable:
     export IPFS_PATH=/path/to/ipfsrepo
 `,
 	},
-	Arguments: []cmds.Argument{},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", false, false, "name of the IPNS key to rotate, the identity by default"),
+	},
 	Options: []cmds.Option{
-		cmds.StringOption(oldKeyOptionName, "o", "Keystore name to use for backing up your existing identity"),
+		cmds.StringOption(oldKeyOptionName, "o", "Keystore name to use for backing up your existing identity or key"),
 		cmds.StringOption(keyStoreTypeOptionName, "t", "type of the key to create: rsa, ed25519").WithDefault(keyStoreAlgorithmDefault),
 		cmds.IntOption(keyStoreSizeOptionName, "s", "size of the key to generate"),
+		cmds.StringOption(keyGraceOptionName, "Time during which the old name forwards to the new one.").WithDefault("720h"),
+		cmds.BoolOption(keyAllowOfflineOptionName, "When offline, save the IPNS records to the local datastore without broadcasting them."),
+		ke.OptionIPNSBase,
+	},
+	PreRun: func(req *cmds.Request, env cmds.Environment) error {
+		if len(req.Arguments) > 0 {
+			return nil
+		}
+		return DaemonNotRunning(req, env)
 	},
-	NoRemote: true,
-	PreRun:   DaemonNotRunning,
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		if len(req.Arguments) > 0 {
+			return rotateIPNSKey(req, res, env)
+		}
+
 		cctx := env.(*oldcmds.Context)
 		nBitsForKeypair, nBitsGiven := req.Options[keyStoreSizeOptionName].(int)
 		algorithm, _ := req.Options[keyStoreTypeOptionName].(string)
@@ -622,6 +688,101 @@ environment variable:
 		}
 		return doRotate(os.Stdout, cctx.ConfigRoot, oldKey, algorithm, nBitsForKeypair, nBitsGiven)
 	},
+	Type: KeyRotateOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KeyRotateOutput) error {
+			fmt.Fprintf(w, "Key %s is now %s\n", cmdenv.EscNonPrint(out.Name), out.NewId)
+			fmt.Fprintf(w, "Old key %s kept as %s, forwarding to the new key until %s\n",
+				out.OldId, cmdenv.EscNonPrint(out.Old), out.Until.Format(time.RFC3339))
+			return nil
+		}),
+	},
+}
+
+// rotateIPNSKey replaces the key of an IPNS name with a new one, and forwards
+// the old name to the new one for a grace period.
+func rotateIPNSKey(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+	api, err := cmdenv.GetApi(env, req)
+	if err != nil {
+		return err
+	}
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
+	if err != nil {
+		return err
+	}
+
+	name := req.Arguments[0]
+	if name == "self" {
+		return fmt.Errorf("the identity is rotated by 'ipfs key rotate' without a name, with the daemon stopped")
+	}
+	oldKey, ok := req.Options[oldKeyOptionName].(string)
+	if !ok {
+		oldKey = name + "-retired"
+	}
+	grace, err := time.ParseDuration(req.Options[keyGraceOptionName].(string))
+	if err != nil {
+		return fmt.Errorf("invalid grace period: %w", err)
+	}
+	if grace <= 0 {
+		return fmt.Errorf("the grace period must be positive")
+	}
+	allowOffline, _ := req.Options[keyAllowOfflineOptionName].(bool)
+
+	genOpts := []options.KeyGenerateOption{}
+	if typ, ok := req.Options[keyStoreTypeOptionName].(string); ok {
+		genOpts = append(genOpts, options.Key.Type(typ))
+	}
+	if size, ok := req.Options[keyStoreSizeOptionName].(int); ok {
+		genOpts = append(genOpts, options.Key.Size(size))
+	}
+
+	old, _, err := api.Key().Rename(req.Context, name, oldKey)
+	if err != nil {
+		return err
+	}
+	key, err := api.Key().Generate(req.Context, name, genOpts...)
+	if err != nil {
+		if _, _, rerr := api.Key().Rename(req.Context, oldKey, name); rerr != nil {
+			log.Errorf("restoring key %s: %s", name, rerr)
+		}
+		return err
+	}
+
+	// links to the new name resolve to the current value right away
+	current, err := api.Name().Resolve(req.Context, old.Path().String(),
+		options.Name.ResolveOption(nsopts.Depth(1)))
+	if err == nil {
+		if _, err := api.Name().Publish(req.Context, current,
+			options.Name.Key(name),
+			options.Name.AllowOffline(allowOffline),
+		); err != nil {
+			return fmt.Errorf("publishing the current value with the new key: %w", err)
+		}
+	}
+
+	until := time.Now().Add(grace)
+	if _, err := api.Name().Publish(req.Context, key.Path(),
+		options.Name.Key(oldKey),
+		options.Name.ValidTime(grace),
+		options.Name.AllowOffline(allowOffline),
+	); err != nil {
+		return fmt.Errorf("publishing the forwarding record: %w", err)
+	}
+	if err := node.RetireKey(req.Context, nd.Repo.Datastore(), oldKey, old.ID(), until); err != nil {
+		return err
+	}
+
+	return cmds.EmitOnce(res, &KeyRotateOutput{
+		Name:  name,
+		Old:   oldKey,
+		OldId: keyEnc.FormatID(old.ID()),
+		NewId: keyEnc.FormatID(key.ID()),
+		Until: until,
+	})
 }
 
 func doRotate(out io.Writer, repoRoot string, oldKey string, algorithm string, nBitsForKeypair int, nBitsGiven bool) error {
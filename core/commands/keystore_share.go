@@ -0,0 +1,124 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core/capability"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const (
+	keyShareKeyOptionName     = "key"
+	keyShareTTLOptionName     = "ttl"
+	keyShareGatewayOptionName = "gateway"
+)
+
+// KeyShareOutput is the output of 'ipfs key share'.
+type KeyShareOutput struct {
+	Paths   []string
+	Token   string
+	Expires time.Time
+	URL     string `json:",omitempty"`
+}
+
+var keyShareCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Issue a capability token granting access to protected gateway paths.",
+		ShortDescription: `
+'ipfs key share' signs a token granting the read access to content paths and
+their subpaths on the gateways protecting them with Gateway.Capabilities, until
+the token expires. The token is a UCAN-style JWT signed with an ed25519 key of
+the keystore, which has to be the key configured in Gateway.Capabilities.Key.
+
+With --gateway, the command prints a link to the first path carrying the
+token, which can be shared:
+
+  > ipfs key share --ttl=48h --gateway=https://ipfs.example.net /ipfs/bafy.../report.pdf
+  https://ipfs.example.net/ipfs/bafy.../report.pdf?cap=eyJhbGciOi...
+
+The token can also be sent in an 'Authorization: Bearer' header. Anyone with
+the token can read the paths until it expires: it can't be revoked, other than
+by changing the key of the gateway.
+
+The token only gates the protected gateway URLs, not the content: its blocks
+remain reachable through a parent CID path, a DNSLink name or bitswap. Encrypt
+private content with 'ipfs add --encrypt'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "The content paths to grant access to."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(keyShareKeyOptionName, "k", "Name of the ed25519 key signing the token.").WithDefault("self"),
+		cmds.StringOption(keyShareTTLOptionName, "Time after which the token expires.").WithDefault("24h"),
+		cmds.StringOption(keyShareGatewayOptionName, "URL of the gateway to make a link to the first path with."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		ttl, err := time.ParseDuration(req.Options[keyShareTTLOptionName].(string))
+		if err != nil {
+			return fmt.Errorf("invalid ttl: %w", err)
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("the ttl must be positive")
+		}
+
+		paths := make([]string, 0, len(req.Arguments))
+		for _, arg := range req.Arguments {
+			p := path.New(arg)
+			if err := p.IsValid(); err != nil {
+				return err
+			}
+			paths = append(paths, capability.CleanPath(p.String()))
+		}
+
+		name, _ := req.Options[keyShareKeyOptionName].(string)
+		var sk crypto.PrivKey
+		if name == "self" {
+			sk = nd.PrivateKey
+		} else if sk, err = nd.Repo.Keystore().Get(name); err != nil {
+			return fmt.Errorf("key with name '%s': %w", name, err)
+		}
+
+		expires := time.Now().Add(ttl).Truncate(time.Second)
+		token, err := capability.Issue(sk, paths, expires)
+		if err != nil {
+			return err
+		}
+
+		out := &KeyShareOutput{Paths: paths, Token: token, Expires: expires}
+		if gw, _ := req.Options[keyShareGatewayOptionName].(string); gw != "" {
+			u, err := url.Parse(strings.TrimSuffix(gw, "/") + paths[0])
+			if err != nil {
+				return fmt.Errorf("invalid gateway URL: %w", err)
+			}
+			q := u.Query()
+			q.Set("cap", token)
+			u.RawQuery = q.Encode()
+			out.URL = u.String()
+		}
+		return cmds.EmitOnce(res, out)
+	},
+	Type: KeyShareOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *KeyShareOutput) error {
+			if out.URL != "" {
+				fmt.Fprintln(w, out.URL)
+				return nil
+			}
+			fmt.Fprintln(w, out.Token)
+			return nil
+		}),
+	},
+}
@@ -1,6 +1,8 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -8,6 +10,7 @@ import (
 	"text/tabwriter"
 
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dirpage"
 
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	unixfs "github.com/ipfs/go-unixfs"
@@ -43,8 +46,15 @@ const (
 	lsResolveTypeOptionName = "resolve-type"
 	lsSizeOptionName        = "size"
 	lsStreamOptionName      = "stream"
+	lsOffsetOptionName      = "offset"
+	lsPageSizeOptionName    = "page-size"
 )
 
+// dirPager is implemented by the CoreAPI to list pages of directories.
+type dirPager interface {
+	LsPage(ctx context.Context, p path.Path, page dirpage.Page, opts ...options.UnixfsLsOption) (<-chan iface.DirEntry, error)
+}
+
 var LsCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List directory contents for Unix filesystem objects.",
@@ -55,6 +65,14 @@ the following format:
   <link base58 hash> <link size in bytes> <link name>
 
 The JSON output contains type information.
+
+Large HAMT-sharded directories can be listed page by page with --page-size
+and --offset: only the shards holding the requested page are fetched. The
+entries of a page are listed in directory order, which is stable for a given
+CID, instead of being sorted by name.
+
+  $ ipfs ls --page-size 100 <dir>
+  $ ipfs ls --page-size 100 --offset 100 <dir>
 `,
 	},
 
@@ -66,6 +84,8 @@ The JSON output contains type information.
 		cmds.BoolOption(lsResolveTypeOptionName, "Resolve linked objects to find out their types.").WithDefault(true),
 		cmds.BoolOption(lsSizeOptionName, "Resolve linked objects to find out their file size.").WithDefault(true),
 		cmds.BoolOption(lsStreamOptionName, "s", "Enable experimental streaming of directory entries as they are traversed."),
+		cmds.IntOption(lsOffsetOptionName, "Number of directory entries to skip."),
+		cmds.IntOption(lsPageSizeOptionName, "Maximum number of directory entries to list. Default: all."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -76,6 +96,23 @@ The JSON output contains type information.
 		resolveType, _ := req.Options[lsResolveTypeOptionName].(bool)
 		resolveSize, _ := req.Options[lsSizeOptionName].(bool)
 		stream, _ := req.Options[lsStreamOptionName].(bool)
+		offset, _ := req.Options[lsOffsetOptionName].(int)
+		pageSize, _ := req.Options[lsPageSizeOptionName].(int)
+		if offset < 0 || pageSize < 0 {
+			return errors.New("offset and page size must not be negative")
+		}
+		paged := offset > 0 || pageSize > 0
+
+		ls := api.Unixfs().Ls
+		if paged {
+			pager, ok := api.(dirPager)
+			if !ok {
+				return errors.New("paged listings are not supported by this api")
+			}
+			ls = func(ctx context.Context, p path.Path, opts ...options.UnixfsLsOption) (<-chan iface.DirEntry, error) {
+				return pager.LsPage(ctx, p, dirpage.Page{Offset: offset, Limit: pageSize}, opts...)
+			}
+		}
 
 		err = req.ParseBodyArgs()
 		if err != nil {
@@ -114,9 +151,11 @@ The JSON output contains type information.
 						return nil
 					}, func(i int) {
 						// after each dir
-						sort.Slice(outputLinks, func(i, j int) bool {
-							return outputLinks[i].Name < outputLinks[j].Name
-						})
+						if !paged {
+							sort.Slice(outputLinks, func(i, j int) bool {
+								return outputLinks[i].Name < outputLinks[j].Name
+							})
+						}
 
 						output[i] = LsObject{
 							Hash:  paths[i],
@@ -131,7 +170,7 @@ The JSON output contains type information.
 		}
 
 		for i, fpath := range paths {
-			results, err := api.Unixfs().Ls(req.Context, path.New(fpath),
+			results, err := ls(req.Context, path.New(fpath),
 				options.Unixfs.ResolveChildren(resolveSize || resolveType))
 			if err != nil {
 				return err
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	namesys "github.com/ipfs/go-namesys"
@@ -21,6 +23,9 @@ var log = logging.Logger("core/commands/ipns")
 
 type ResolvedPath struct {
 	Path path.Path
+	// Sources are the records returned by each routing source, with
+	// --report.
+	Sources []*SourceRecord `json:",omitempty"`
 }
 
 const (
@@ -29,6 +34,7 @@ const (
 	dhtRecordCountOptionName = "dht-record-count"
 	dhtTimeoutOptionName     = "dht-timeout"
 	streamOptionName         = "stream"
+	reportOptionName         = "report"
 )
 
 var IpnsCmd = &cmds.Command{
@@ -66,6 +72,21 @@ Resolve the value of a dnslink:
   > ipfs name resolve ipfs.io
   /ipfs/QmaBvfZooxWkrv7D3r8LS9moNjzD2o525XMZze69hhoxf5
 
+Report the record returned by each routing source:
+
+  > ipfs name resolve --report k51qzi5uqu5dlvj2baxnqndepeb86cbk3ng7n3i46uzyxzyqj2xjonzllnv0v8
+  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+
+    SOURCE                    SEQUENCE  VALIDITY              VALUE
+  * dht                       42        2023-03-02T10:00:00Z  /ipfs/QmSiTko9JZyabH56y2fussEt1A5oDqsFXB3CkvAqraFryz
+    pubsub                    41        2023-03-01T10:00:00Z  /ipfs/QmatmE9msSfkKxoffpHwNLNKgwZG8eT9Bud6YoPab52vpy
+    http https://cid.contact  -         -                     routing: operation or key not supported
+
+With --report, the record of the name is resolved over the DHT, pubsub and
+each delegated HTTP router in parallel, and the freshest valid one, marked
+with '*', is used. This helps finding the routing sources serving stale
+records.
+
 `,
 	},
 
@@ -78,6 +99,7 @@ Resolve the value of a dnslink:
 		cmds.UintOption(dhtRecordCountOptionName, "dhtrc", "Number of records to request for DHT resolution."),
 		cmds.StringOption(dhtTimeoutOptionName, "dhtt", "Max time to collect values during DHT resolution eg \"30s\". Pass 0 for no timeout."),
 		cmds.BoolOption(streamOptionName, "s", "Stream entries as they are found."),
+		cmds.BoolOption(reportOptionName, "Resolve over each routing source in parallel, and report the record each returned."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -102,6 +124,10 @@ Resolve the value of a dnslink:
 		rc, rcok := req.Options[dhtRecordCountOptionName].(uint)
 		dhtt, dhttok := req.Options[dhtTimeoutOptionName].(string)
 		stream, _ := req.Options[streamOptionName].(bool)
+		report, _ := req.Options[reportOptionName].(bool)
+		if stream && report {
+			return fmt.Errorf("--%s and --%s can't be used together", streamOptionName, reportOptionName)
+		}
 
 		opts := []options.NameResolveOption{
 			options.Name.Cache(!nocache),
@@ -113,6 +139,7 @@ Resolve the value of a dnslink:
 		if rcok {
 			opts = append(opts, options.Name.ResolveOption(nsopts.DhtRecordCount(rc)))
 		}
+		dhtTimeout := nsopts.DefaultResolveOpts().DhtTimeout
 		if dhttok {
 			d, err := time.ParseDuration(dhtt)
 			if err != nil {
@@ -122,12 +149,42 @@ Resolve the value of a dnslink:
 				return errors.New("DHT timeout value must be >= 0")
 			}
 			opts = append(opts, options.Name.ResolveOption(nsopts.DhtTimeout(d)))
+			dhtTimeout = d
 		}
 
 		if !strings.HasPrefix(name, "/ipns/") {
 			name = "/ipns/" + name
 		}
 
+		if report {
+			nd, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			key, rest, _ := strings.Cut(strings.TrimPrefix(name, "/ipns/"), "/")
+			value, sources, err := resolveSources(req.Context, nd, key, rc, dhtTimeout)
+			if err != nil {
+				if sources != nil {
+					// the report shows why the name couldn't be resolved
+					if err := res.Emit(&ResolvedPath{Sources: sources}); err != nil {
+						return err
+					}
+				}
+				return err
+			}
+			if rest != "" {
+				value += "/" + rest
+			}
+			if recursive && strings.HasPrefix(value, "/ipns/") {
+				output, err := api.Name().Resolve(req.Context, value, opts...)
+				if err != nil {
+					return err
+				}
+				value = output.String()
+			}
+			return cmds.EmitOnce(res, &ResolvedPath{Path: path.FromString(value), Sources: sources})
+		}
+
 		if !stream {
 			output, err := api.Name().Resolve(req.Context, name, opts...)
 			if err != nil && (recursive || err != namesys.ErrResolveRecursion) {
@@ -156,8 +213,38 @@ Resolve the value of a dnslink:
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, rp *ResolvedPath) error {
-			_, err := fmt.Fprintln(w, rp.Path)
-			return err
+			if rp.Path != "" {
+				if _, err := fmt.Fprintln(w, rp.Path); err != nil {
+					return err
+				}
+			}
+			if rp.Sources == nil {
+				return nil
+			}
+
+			if rp.Path != "" {
+				fmt.Fprintln(w)
+			}
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "  SOURCE\tSEQUENCE\tVALIDITY\tVALUE")
+			for _, s := range rp.Sources {
+				mark := " "
+				if s.Selected {
+					mark = "*"
+				}
+				seq, validity, value := "-", "-", s.Value
+				if s.Sequence != nil {
+					seq = strconv.FormatUint(*s.Sequence, 10)
+				}
+				if s.Validity != nil {
+					validity = s.Validity.UTC().Format(time.RFC3339)
+				}
+				if s.Error != "" {
+					value = s.Error
+				}
+				fmt.Fprintf(tw, "%s %s\t%s\t%s\t%s\n", mark, s.Source, seq, validity, value)
+			}
+			return tw.Flush()
 		}),
 	},
 	Type: ResolvedPath{},
@@ -0,0 +1,151 @@
+package name
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	"github.com/ipfs/kubo/core"
+	irouting "github.com/ipfs/kubo/routing"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// SourceRecord is the IPNS record returned by a routing source, reported by
+// 'ipfs name resolve --report'.
+type SourceRecord struct {
+	// Source is "dht", "pubsub" or "http <endpoint>".
+	Source   string
+	Value    string     `json:",omitempty"`
+	Sequence *uint64    `json:",omitempty"`
+	Validity *time.Time `json:",omitempty"`
+	// Valid is whether the record has a valid signature and hasn't expired.
+	Valid bool
+	// Selected is whether the record is the freshest valid one, which the
+	// name resolves to.
+	Selected bool
+	Error    string `json:",omitempty"`
+	Duration time.Duration
+}
+
+type valueSource struct {
+	name  string
+	store routing.ValueStore
+}
+
+// valueSources returns the routing sources of IPNS records of the node: the
+// DHT, pubsub, and each delegated router.
+func valueSources(nd *core.IpfsNode) []valueSource {
+	var sources []valueSource
+	if r := irouting.Select(nd.Routing, irouting.IsDHT); r != nil {
+		sources = append(sources, valueSource{"dht", r})
+	}
+	if nd.PSRouter != nil {
+		sources = append(sources, valueSource{"pubsub", nd.PSRouter})
+	}
+	for _, r := range irouting.Routers(nd.Routing, irouting.IsHTTP) {
+		sources = append(sources, valueSource{"http " + irouting.Endpoint(r), r})
+	}
+	return sources
+}
+
+// resolveSources gets the record of the IPNS key from all the sources of the
+// node in parallel, and returns the value of the freshest valid one with the
+// record returned by each source.
+func resolveSources(ctx context.Context, nd *core.IpfsNode, key string, recordCount uint, timeout time.Duration) (string, []*SourceRecord, error) {
+	if !nd.IsOnline {
+		return "", nil, errors.New("this action must be run in online mode, try running 'ipfs daemon' first")
+	}
+	pid, err := peer.Decode(key)
+	if err != nil {
+		return "", nil, fmt.Errorf("--%s needs an IPNS key, not a DNSLink name: %w", reportOptionName, err)
+	}
+	recordKey := ipns.RecordKey(pid)
+
+	var opts []routing.Option
+	if recordCount > 0 {
+		opts = append(opts, dht.Quorum(int(recordCount)))
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	sources := valueSources(nd)
+	if len(sources) == 0 {
+		return "", nil, errors.New("no routing source of IPNS records is configured")
+	}
+	return resolveRecords(ctx, sources, nd.RecordValidator, key, recordKey, opts)
+}
+
+// resolveRecords gets the record at recordKey, the record key of the IPNS
+// key, from sources in parallel, validating them with validator.
+func resolveRecords(ctx context.Context, sources []valueSource, validator record.Validator, key, recordKey string, opts []routing.Option) (string, []*SourceRecord, error) {
+	reports := make([]*SourceRecord, len(sources))
+	values := make([][]byte, len(sources))
+	var wg sync.WaitGroup
+	for i, s := range sources {
+		wg.Add(1)
+		go func(i int, s valueSource) {
+			defer wg.Done()
+			start := time.Now()
+			val, err := s.store.GetValue(ctx, recordKey, opts...)
+			reports[i] = &SourceRecord{Source: s.name, Duration: time.Since(start)}
+			if err != nil {
+				reports[i].Error = err.Error()
+				return
+			}
+			values[i] = val
+		}(i, s)
+	}
+	wg.Wait()
+
+	var valid [][]byte
+	for i, val := range values {
+		if val == nil {
+			continue
+		}
+		r := reports[i]
+		var entry ipns_pb.IpnsEntry
+		if err := proto.Unmarshal(val, &entry); err != nil {
+			r.Error = fmt.Sprintf("invalid record: %s", err)
+			continue
+		}
+		seq := entry.GetSequence()
+		r.Value, r.Sequence = string(entry.GetValue()), &seq
+		if eol, err := ipns.GetEOL(&entry); err == nil {
+			r.Validity = &eol
+		}
+		if err := validator.Validate(recordKey, val); err != nil {
+			r.Error = err.Error()
+			continue
+		}
+		r.Valid = true
+		valid = append(valid, val)
+	}
+	if len(valid) == 0 {
+		return "", reports, fmt.Errorf("no valid record of /ipns/%s was found", key)
+	}
+
+	best, err := validator.Select(recordKey, valid)
+	if err != nil {
+		return "", reports, err
+	}
+	var value string
+	for i, val := range values {
+		if reports[i].Valid && bytes.Equal(val, valid[best]) {
+			reports[i].Selected = true
+			value = reports[i].Value
+		}
+	}
+	return value, reports, nil
+}
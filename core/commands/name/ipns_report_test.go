@@ -0,0 +1,109 @@
+package name
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/ipfs/go-ipns"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// recordStore returns its record, or its error.
+type recordStore struct {
+	routing.ValueStore
+	val []byte
+	err error
+}
+
+func (s *recordStore) GetValue(context.Context, string, ...routing.Option) ([]byte, error) {
+	return s.val, s.err
+}
+
+func newRecord(t *testing.T, sk ci.PrivKey, value string, seq uint64, eol time.Time) []byte {
+	t.Helper()
+	entry, err := ipns.Create(sk, []byte(value), seq, eol, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ipns.EmbedPublicKey(sk.GetPublic(), entry); err != nil {
+		t.Fatal(err)
+	}
+	val, err := proto.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return val
+}
+
+func TestResolveRecords(t *testing.T) {
+	sk, _, err := ci.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSk, _, err := ci.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := pid.String()
+	recordKey := ipns.RecordKey(pid)
+	eol := time.Now().Add(time.Hour)
+
+	sources := []valueSource{
+		{"dht", &recordStore{val: newRecord(t, sk, "/ipfs/old", 1, eol)}},
+		{"pubsub", &recordStore{val: newRecord(t, sk, "/ipfs/new", 2, eol)}},
+		{"http https://a.example.net", &recordStore{err: routing.ErrNotFound}},
+		{"http https://b.example.net", &recordStore{val: []byte("garbage")}},
+		{"http https://c.example.net", &recordStore{val: newRecord(t, sk, "/ipfs/expired", 3, time.Now().Add(-time.Hour))}},
+		{"http https://d.example.net", &recordStore{val: newRecord(t, otherSk, "/ipfs/forged", 4, eol)}},
+	}
+	value, reports, err := resolveRecords(context.Background(), sources, ipns.Validator{}, key, recordKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "/ipfs/new" {
+		t.Fatalf("expected the freshest valid record, got %q", value)
+	}
+	if len(reports) != len(sources) {
+		t.Fatalf("expected %d reports, got %d", len(sources), len(reports))
+	}
+	for i, r := range reports {
+		if r.Source != sources[i].name {
+			t.Errorf("expected the report of %s, got %s", sources[i].name, r.Source)
+		}
+	}
+
+	if r := reports[0]; !r.Valid || r.Selected || r.Value != "/ipfs/old" || *r.Sequence != 1 || r.Validity == nil {
+		t.Errorf("unexpected dht report %+v", r)
+	}
+	if r := reports[1]; !r.Valid || !r.Selected || r.Value != "/ipfs/new" || *r.Sequence != 2 {
+		t.Errorf("unexpected pubsub report %+v", r)
+	}
+	if r := reports[2]; r.Valid || r.Error != routing.ErrNotFound.Error() || r.Sequence != nil {
+		t.Errorf("unexpected report of the failing router %+v", r)
+	}
+	if r := reports[3]; r.Valid || r.Error == "" || r.Sequence != nil {
+		t.Errorf("unexpected report of the invalid record %+v", r)
+	}
+	// the invalid records are reported with their content
+	for _, r := range reports[4:] {
+		if r.Valid || r.Selected || r.Error == "" || r.Sequence == nil {
+			t.Errorf("unexpected report of the invalid record %+v", r)
+		}
+	}
+
+	_, reports, err = resolveRecords(context.Background(), sources[2:], ipns.Validator{}, key, recordKey, nil)
+	if err == nil {
+		t.Fatal("expected an error without valid records")
+	}
+	if len(reports) != 4 {
+		t.Fatalf("expected the reports of the sources along the error, got %d", len(reports))
+	}
+}
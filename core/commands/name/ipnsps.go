@@ -3,11 +3,18 @@ package name
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/gogo/protobuf/proto"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	ke "github.com/ipfs/kubo/core/commands/keyencode"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	psrouter "github.com/libp2p/go-libp2p-pubsub-router"
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
@@ -24,6 +31,14 @@ type stringList struct {
 	Strings []string
 }
 
+// ipnsPubsubPeers is the number of peers subscribed to the topic of a name.
+type ipnsPubsubPeers struct {
+	Name  string
+	Peers int
+	// Sequence is the one of the latest record of the name, if any.
+	Sequence *uint64 `json:",omitempty"`
+}
+
 // IpnsPubsubCmd is the subcommand that allows us to manage the IPNS pubsub system
 var IpnsPubsubCmd = &cmds.Command{
 	Status: cmds.Experimental,
@@ -38,6 +53,7 @@ Note: this command is experimental and subject to change as the system is refine
 	Subcommands: map[string]*cmds.Command{
 		"state":  ipnspsStateCmd,
 		"subs":   ipnspsSubsCmd,
+		"peers":  ipnspsPeersCmd,
 		"cancel": ipnspsCancelCmd,
 	},
 }
@@ -116,6 +132,76 @@ var ipnspsSubsCmd = &cmds.Command{
 	},
 }
 
+var ipnspsPeersCmd = &cmds.Command{
+	Status: cmds.Experimental,
+	Helptext: cmds.HelpText{
+		Tagline: "Show the number of peers subscribed to each name.",
+		ShortDescription: `
+Shows, for each name subscription, the number of peers subscribed to the
+pubsub topic of the name, and the sequence number of its latest record. Names
+without peers only get their updates from the other routers.
+`,
+	},
+	Options: []cmds.Option{
+		ke.OptionIPNSBase,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		keyEnc, err := ke.KeyEncoderFromString(req.Options[ke.OptionIPNSBase.Name()].(string))
+		if err != nil {
+			return err
+		}
+
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if n.PSRouter == nil || n.PubSub == nil {
+			return cmds.Errorf(cmds.ErrClient, "IPNS pubsub subsystem is not enabled")
+		}
+		keys := n.PSRouter.GetSubscriptions()
+		sort.Strings(keys)
+		for _, key := range keys {
+			ns, k, err := record.SplitKey(key)
+			if err != nil || ns != "ipns" {
+				continue
+			}
+			pid, err := peer.IDFromBytes([]byte(k))
+			if err != nil {
+				log.Errorf("ipns key not a valid peer ID: %s", err)
+				continue
+			}
+
+			out := &ipnsPubsubPeers{
+				Name:  "/ipns/" + keyEnc.FormatID(pid),
+				Peers: len(n.PubSub.ListPeers(psrouter.KeyToTopic(key))),
+			}
+			if val, err := n.PSRouter.GetValue(req.Context, key); err == nil {
+				var entry ipns_pb.IpnsEntry
+				if err := proto.Unmarshal(val, &entry); err == nil {
+					seq := entry.GetSequence()
+					out.Sequence = &seq
+				}
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: ipnsPubsubPeers{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *ipnsPubsubPeers) error {
+			seq := "-"
+			if out.Sequence != nil {
+				seq = strconv.FormatUint(*out.Sequence, 10)
+			}
+			_, err := fmt.Fprintf(w, "%s\t%d peers\tsequence %s\n", out.Name, out.Peers, seq)
+			return err
+		}),
+	},
+}
+
 var ipnspsCancelCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
@@ -138,10 +224,16 @@ var ipnspsCancelCmd = &cmds.Command{
 			return cmds.Errorf(cmds.ErrClient, err.Error())
 		}
 
-		ok, err := n.PSRouter.Cancel("/ipns/" + string(pid))
+		key := "/ipns/" + string(pid)
+		ok, err := n.PSRouter.Cancel(key)
 		if err != nil {
 			return err
 		}
+		// don't subscribe to the name again on restart
+		records := libp2p.PubsubRecordsDatastore(n.Repo.Datastore())
+		if err := records.Delete(req.Context, dshelp.NewKeyFromBinary([]byte(key))); err != nil {
+			return err
+		}
 		return cmds.EmitOnce(res, &ipnsPubsubCancel{ok})
 	},
 	Arguments: []cmds.Argument{
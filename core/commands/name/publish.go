@@ -13,6 +13,7 @@ import (
 	options "github.com/ipfs/interface-go-ipfs-core/options"
 	path "github.com/ipfs/interface-go-ipfs-core/path"
 	ke "github.com/ipfs/kubo/core/commands/keyencode"
+	"github.com/ipfs/kubo/core/spaces"
 	peer "github.com/libp2p/go-libp2p/core/peer"
 )
 
@@ -84,6 +85,7 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 		cmds.StringOption(keyOptionName, "k", "Name of the key to be used or a valid PeerID, as listed by 'ipfs key list -l'.").WithDefault("self"),
 		cmds.BoolOption(quieterOptionName, "Q", "Write only final hash."),
 		ke.OptionIPNSBase,
+		cmdenv.OptionSpace,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -97,6 +99,13 @@ Alternatively, publish an <ipfs-path> using a valid PeerID (as listed by
 
 		allowOffline, _ := req.Options[allowOfflineOptionName].(bool)
 		kname, _ := req.Options[keyOptionName].(string)
+		if space := cmdenv.GetSpace(req); space != "" {
+			// only the keys of the space, by name
+			if kname == "self" {
+				return errors.New("the key of the node can not be used in a space, pass --key")
+			}
+			kname = spaces.KeyName(space, kname)
+		}
 
 		validTimeOpt, _ := req.Options[lifeTimeOptionName].(string)
 		validTime, err := time.ParseDuration(validTimeOpt)
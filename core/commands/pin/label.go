@@ -0,0 +1,220 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	core "github.com/ipfs/kubo/core"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/pinlabels"
+)
+
+const pinLabelOptionName = "label"
+
+// PinLabelOutput is the label of a pinned root.
+type PinLabelOutput struct {
+	Cid     string
+	Label   string
+	Private bool `json:",omitempty"`
+}
+
+var labelPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Label pinned roots.",
+		ShortDescription: `
+Labels pinned roots, e.g. to keep their content private: the content of the
+roots with one of the labels of Routing.PrivateLabels is only provided on the
+LAN DHT, never on the public DHT nor to the HTTP routers and the indexers.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"set": setLabelPinCmd,
+		"rm":  rmLabelPinCmd,
+		"ls":  lsLabelPinCmd,
+	},
+}
+
+var setLabelPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Label roots.",
+		ShortDescription: `
+Labels the roots of the paths. A root has one label, replaced when labeled
+again. Use 'ipfs pin add --label' to label a root before it is pinned and
+provided.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("label", true, false, "Label of the roots."),
+		cmds.StringArg("ipfs-path", true, true, "Path to the roots to label.").EnableStdin(),
+	},
+	Type: PinLabelOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if err := req.ParseBodyArgs(); err != nil {
+			return err
+		}
+
+		label := req.Arguments[0]
+		roots, err := labelPins(req.Context, n, api, req.Arguments[1:], label)
+		if err != nil {
+			return err
+		}
+		for _, c := range roots {
+			if err := res.Emit(labelOutput(n, c, label)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinLabelOutput) error {
+			_, err := fmt.Fprintf(w, "labeled %s %s\n", out.Cid, out.Label)
+			return err
+		}),
+	},
+}
+
+var rmLabelPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove the label of roots.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", true, true, "Path to the roots to unlabel.").EnableStdin(),
+	},
+	Type: PinLabelOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		if err := req.ParseBodyArgs(); err != nil {
+			return err
+		}
+
+		for _, p := range req.Arguments {
+			rp, err := api.ResolvePath(req.Context, path.New(p))
+			if err != nil {
+				return err
+			}
+			label, err := n.PinLabels.Get(req.Context, rp.Cid())
+			if err != nil {
+				return err
+			}
+			if label == "" {
+				continue
+			}
+			if err := n.PinLabels.Remove(req.Context, rp.Cid()); err != nil {
+				return err
+			}
+			if err := res.Emit(labelOutput(n, rp.Cid(), label)); err != nil {
+				return err
+			}
+		}
+		return refreshPrivateScope(req.Context, n)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinLabelOutput) error {
+			_, err := fmt.Fprintf(w, "unlabeled %s %s\n", out.Cid, out.Label)
+			return err
+		}),
+	},
+}
+
+var lsLabelPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the labels of the roots.",
+		ShortDescription: `
+Lists the labeled roots and their labels, telling whether their content is
+private.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("label", false, false, "Only list the roots with this label."),
+	},
+	Type: PinLabelOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		labels, err := n.PinLabels.List(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, l := range labels {
+			if len(req.Arguments) > 0 && l.Label != req.Arguments[0] {
+				continue
+			}
+			if err := res.Emit(labelOutput(n, l.Cid, l.Label)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinLabelOutput) error {
+			scope := "public"
+			if out.Private {
+				scope = "private"
+			}
+			_, err := fmt.Fprintf(w, "%s %s %s\n", out.Cid, out.Label, scope)
+			return err
+		}),
+	},
+}
+
+func labelOutput(n *core.IpfsNode, c cid.Cid, label string) *PinLabelOutput {
+	return &PinLabelOutput{
+		Cid:     c.String(),
+		Label:   label,
+		Private: n.PrivateScope != nil && n.PrivateScope.IsPrivateLabel(label),
+	}
+}
+
+// labelPins labels the roots of paths, and returns them. The content of the
+// roots labeled private is private before returning.
+func labelPins(ctx context.Context, n *core.IpfsNode, api coreiface.CoreAPI, paths []string, label string) ([]cid.Cid, error) {
+	if err := pinlabels.ValidateLabel(label); err != nil {
+		return nil, err
+	}
+	roots := make([]cid.Cid, 0, len(paths))
+	for _, p := range paths {
+		rp, err := api.ResolvePath(ctx, path.New(p))
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, rp.Cid())
+	}
+	for _, c := range roots {
+		if err := n.PinLabels.Set(ctx, c, label); err != nil {
+			return nil, err
+		}
+	}
+	return roots, refreshPrivateScope(ctx, n)
+}
+
+// refreshPrivateScope lists the private content again, after labeling roots
+// or fetching their blocks.
+func refreshPrivateScope(ctx context.Context, n *core.IpfsNode) error {
+	if n.PrivateScope == nil {
+		return nil
+	}
+	return n.PrivateScope.Refresh(ctx)
+}
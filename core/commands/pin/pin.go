@@ -36,6 +36,10 @@ var PinCmd = &cmds.Command{
 		"verify": verifyPinCmd,
 		"update": updatePinCmd,
 		"remote": remotePinCmd,
+		"jobs":   pinJobsCmd,
+		"export": exportPinCmd,
+		"import": importPinCmd,
+		"label":  labelPinCmd,
 	},
 }
 
@@ -46,17 +50,35 @@ type PinOutput struct {
 type AddPinOutput struct {
 	Pins     []string `json:",omitempty"`
 	Progress int      `json:",omitempty"`
+	// IDs of the jobs of --background, one per pin
+	Jobs []string `json:",omitempty"`
 }
 
 const (
 	pinRecursiveOptionName = "recursive"
 	pinProgressOptionName  = "progress"
+	pinPriorityOptionName  = "priority"
 )
 
 var addPinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:          "Pin objects to local storage.",
 		ShortDescription: "Stores an IPFS object(s) from a given path locally to disk.",
+		LongDescription: `
+Stores an IPFS object(s) from a given path locally to disk.
+
+With --background, the command returns once the paths are resolved, with the
+ID of a pin job for each of them. The jobs are queued by --priority, run a
+few at a time, and survive restarts of the daemon: pinning large DAGs doesn't
+tie up the connection of the client. See 'ipfs pin jobs --help'.
+
+With --label, the roots are labeled before being pinned, see
+'ipfs pin label --help': the content of the roots labeled private is never
+provided on the public DHT, not even while being pinned.
+
+With --space, the roots are pinned in a space instead of the pinset of the
+node, within the quota of the space, see 'ipfs space --help'.
+`,
 	},
 
 	Arguments: []cmds.Argument{
@@ -65,6 +87,10 @@ var addPinCmd = &cmds.Command{
 	Options: []cmds.Option{
 		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively pin the object linked to by the specified object(s).").WithDefault(true),
 		cmds.BoolOption(pinProgressOptionName, "Show progress"),
+		cmds.BoolOption(pinBackgroundOptionName, "Queue the pins as background jobs and print their IDs."),
+		cmds.StringOption(pinPriorityOptionName, "Only with --background, priority of the jobs: low, normal or high.").WithDefault("normal"),
+		cmds.StringOption(pinLabelOptionName, "Label the roots before pinning them."),
+		cmdenv.OptionSpace,
 	},
 	Type: AddPinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -86,6 +112,42 @@ var addPinCmd = &cmds.Command{
 			return err
 		}
 
+		if label, ok := req.Options[pinLabelOptionName].(string); ok {
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			if _, err := labelPins(req.Context, n, api, req.Arguments, label); err != nil {
+				return err
+			}
+			// the blocks fetched by the pins are private as well
+			defer func() {
+				if err := refreshPrivateScope(req.Context, n); err != nil {
+					log.Errorf("failed to list the private content: %s", err)
+				}
+			}()
+		}
+
+		background, _ := req.Options[pinBackgroundOptionName].(bool)
+		if space := cmdenv.GetSpace(req); space != "" {
+			if background {
+				return fmt.Errorf("--%s can not be used with --%s", pinBackgroundOptionName, cmdenv.OptionSpace.Name())
+			}
+			n, err := cmdenv.GetNode(env)
+			if err != nil {
+				return err
+			}
+			added, err := pinAddSpace(req.Context, n, api, enc, space, req.Arguments, recursive)
+			if err != nil {
+				return err
+			}
+			return cmds.EmitOnce(res, &AddPinOutput{Pins: added})
+		}
+
+		if background {
+			return pinAddBackground(req, res, env, api, enc, recursive)
+		}
+
 		if !showProgress {
 			added, err := pinAddMany(req.Context, api, enc, req.Arguments, recursive)
 			if err != nil {
@@ -145,6 +207,12 @@ var addPinCmd = &cmds.Command{
 				pintype = "directly"
 			}
 
+			if len(out.Jobs) > 0 {
+				for i, k := range out.Pins {
+					fmt.Fprintf(w, "queued %s %s as job %s\n", k, pintype, out.Jobs[i])
+				}
+				return nil
+			}
 			for _, k := range out.Pins {
 				fmt.Fprintf(w, "pinned %s %s\n", k, pintype)
 			}
@@ -220,6 +288,7 @@ ipfs pin ls -t indirect <cid>
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(pinRecursiveOptionName, "r", "Recursively unpin the object linked to by the specified object(s).").WithDefault(true),
+		cmdenv.OptionSpace,
 	},
 	Type: PinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -240,6 +309,14 @@ ipfs pin ls -t indirect <cid>
 			return err
 		}
 
+		space := cmdenv.GetSpace(req)
+		var n *core.IpfsNode
+		if space != "" {
+			if n, err = cmdenv.GetNode(env); err != nil {
+				return err
+			}
+		}
+
 		pins := make([]string, 0, len(req.Arguments))
 		for _, b := range req.Arguments {
 			rp, err := api.ResolvePath(req.Context, path.New(b))
@@ -249,7 +326,12 @@ ipfs pin ls -t indirect <cid>
 
 			id := enc.Encode(rp.Cid())
 			pins = append(pins, id)
-			if err := api.Pin().Rm(req.Context, rp, options.Pin.RmRecursive(recursive)); err != nil {
+			if space != "" {
+				err = n.Spaces.Unpin(req.Context, space, rp.Cid(), recursive)
+			} else {
+				err = api.Pin().Rm(req.Context, rp, options.Pin.RmRecursive(recursive))
+			}
+			if err != nil {
 				return err
 			}
 		}
@@ -324,6 +406,7 @@ Example:
 		cmds.StringOption(pinTypeOptionName, "t", "The type of pinned keys to list. Can be \"direct\", \"indirect\", \"recursive\", or \"all\".").WithDefault("all"),
 		cmds.BoolOption(pinQuietOptionName, "q", "Write just hashes of objects."),
 		cmds.BoolOption(pinStreamOptionName, "s", "Enable streaming of pins as they are discovered."),
+		cmdenv.OptionSpace,
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		api, err := cmdenv.GetApi(env, req)
@@ -352,7 +435,13 @@ Example:
 			}
 		}
 
-		if len(req.Arguments) > 0 {
+		if space := cmdenv.GetSpace(req); space != "" {
+			var n *core.IpfsNode
+			if n, err = cmdenv.GetNode(env); err != nil {
+				return err
+			}
+			err = pinLsSpace(req, n, api, space, typeStr, emit)
+		} else if len(req.Arguments) > 0 {
 			err = pinLsKeys(req, typeStr, api, emit)
 		} else {
 			err = pinLsAll(req, typeStr, api, emit)
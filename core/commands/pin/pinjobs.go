@@ -0,0 +1,174 @@
+package pin
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	cidenc "github.com/ipfs/go-cidutil/cidenc"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/prefetch"
+)
+
+// pinAddBackground queues the pins of 'ipfs pin add --background'.
+func pinAddBackground(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment, api coreiface.CoreAPI, enc cidenc.Encoder, recursive bool) error {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	if !nd.IsDaemon {
+		return fmt.Errorf("--%s requires a running daemon", pinBackgroundOptionName)
+	}
+	priority, _ := req.Options[pinPriorityOptionName].(string)
+	prio, err := prefetch.ParsePriority(priority)
+	if err != nil {
+		return err
+	}
+
+	var out AddPinOutput
+	for _, p := range req.Arguments {
+		rp, err := api.ResolvePath(req.Context, path.New(p))
+		if err != nil {
+			return err
+		}
+		id, err := nd.PinQueue.Add(rp.Cid(), recursive, prio)
+		if err != nil {
+			return err
+		}
+		out.Pins = append(out.Pins, enc.Encode(rp.Cid()))
+		out.Jobs = append(out.Jobs, id)
+	}
+	return cmds.EmitOnce(res, &out)
+}
+
+// PinJob is a background pin job.
+type PinJob struct {
+	ID        string
+	Cid       string
+	Recursive bool
+	Priority  string
+	State     prefetch.State
+	Fetched   int
+	Error     string `json:",omitempty"`
+	Created   time.Time
+	Started   time.Time
+	Finished  time.Time
+}
+
+var pinJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the background pin jobs.",
+		ShortDescription: `
+'ipfs pin add --background' queues pin jobs, run by the daemon a few at a
+time, the higher priority jobs first. The jobs are persisted in the datastore:
+the jobs interrupted by a restart of the daemon are queued again. Only the last
+100 finished jobs are kept.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     lsPinJobsCmd,
+		"cancel": cancelPinJobsCmd,
+	},
+}
+
+var lsPinJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the background pin jobs.",
+		ShortDescription: `
+Lists the background pin jobs, or only the given ones, with the number of
+nodes fetched so far by the running jobs.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", false, true, "ID of the jobs to list."),
+	},
+	Type: PinJob{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		jobs := nd.PinQueue.Jobs()
+		if len(req.Arguments) > 0 {
+			jobs = jobs[:0]
+			for _, id := range req.Arguments {
+				j, err := nd.PinQueue.Job(id)
+				if err != nil {
+					return fmt.Errorf("%s: %w", id, err)
+				}
+				jobs = append(jobs, j)
+			}
+		}
+		for _, j := range jobs {
+			if err := res.Emit(toPinJob(j, enc)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, j *PinJob) error {
+			pintype := "direct"
+			if j.Recursive {
+				pintype = "recursive"
+			}
+			state := string(j.State)
+			if j.Error != "" {
+				state += ": " + j.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d nodes\t%s\n", j.ID, j.Cid, pintype, j.Priority, j.Fetched, state)
+			return nil
+		}),
+	},
+}
+
+func toPinJob(j pinqueue.Job, enc cidenc.Encoder) *PinJob {
+	return &PinJob{
+		ID:        j.ID,
+		Cid:       enc.Encode(j.Cid),
+		Recursive: j.Recursive,
+		Priority:  j.Priority.String(),
+		State:     j.State,
+		Fetched:   j.Fetched,
+		Error:     j.Err,
+		Created:   j.Created,
+		Started:   j.Started,
+		Finished:  j.Finished,
+	}
+}
+
+var cancelPinJobsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel background pin jobs.",
+		ShortDescription: `
+Cancels queued or running pin jobs. The blocks already fetched are kept, but
+not pinned: they may be garbage collected.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, true, "ID of the jobs to cancel."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range req.Arguments {
+			if err := nd.PinQueue.Cancel(id); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,307 @@
+package pin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/go-libipfs/files"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+
+	core "github.com/ipfs/kubo/core"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/pinmanifest"
+	"github.com/ipfs/kubo/core/prefetch"
+)
+
+const (
+	pinExportKeyOptionName      = "key"
+	pinExportMetaOptionName     = "meta"
+	pinExportUnsignedOptionName = "unsigned"
+
+	pinImportSignerOptionName        = "signer"
+	pinImportAllowUnsignedOptionName = "allow-unsigned"
+)
+
+var exportPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Export the pinset as a signed, portable manifest.",
+		ShortDescription: `
+Writes a JSON manifest of the recursive and direct pins, signed by the
+identity of the node, to replay them on another node or after rebuilding the
+repo with 'ipfs pin import'.
+`,
+		LongDescription: `
+Writes a JSON manifest of the recursive and direct pins, signed by the
+identity of the node, to replay them on another node or after rebuilding the
+repo with 'ipfs pin import'.
+
+With arguments, only the given pins are exported, and each pin is named after
+its argument when it's a path rather than a CID. --meta labels the manifest
+with key=value pairs, e.g. its origin.
+
+The manifest is signed with the key given to --key, or left unsigned with
+--unsigned. It doesn't hold the blocks of the pins: they are fetched from the
+network on import. Use 'ipfs dag export' to move the blocks as well.
+
+Example:
+  $ ipfs pin export --meta origin=gateway-1 > pins.json
+  $ ipfs pin export /ipns/docs.ipfs.tech > docs-pin.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("ipfs-path", false, true, "Path to the pins to export, all of them by default."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(pinExportKeyOptionName, "k", "Name of the key signing the manifest, as listed by 'ipfs key list'.").WithDefault("self"),
+		cmds.StringsOption(pinExportMetaOptionName, "Label the manifest with a key=value pair."),
+		cmds.BoolOption(pinExportUnsignedOptionName, "Don't sign the manifest."),
+	},
+	Type: pinmanifest.Manifest{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+
+		meta, err := parseManifestMeta(req.Options[pinExportMetaOptionName])
+		if err != nil {
+			return err
+		}
+
+		var pins []pinmanifest.Pin
+		if len(req.Arguments) == 0 {
+			recursive, err := n.Pinning.RecursiveKeys(req.Context)
+			if err != nil {
+				return err
+			}
+			for _, c := range recursive {
+				pins = append(pins, pinmanifest.Pin{Cid: c, Mode: pinmanifest.Recursive})
+			}
+			direct, err := n.Pinning.DirectKeys(req.Context)
+			if err != nil {
+				return err
+			}
+			for _, c := range direct {
+				pins = append(pins, pinmanifest.Pin{Cid: c, Mode: pinmanifest.Direct})
+			}
+		}
+		for _, p := range req.Arguments {
+			rp, err := api.ResolvePath(req.Context, path.New(p))
+			if err != nil {
+				return err
+			}
+			mode, pinned, err := n.Pinning.IsPinned(req.Context, rp.Cid())
+			if err != nil {
+				return err
+			}
+			if !pinned || (mode != pinmanifest.Recursive && mode != pinmanifest.Direct) {
+				return fmt.Errorf("%s is not pinned recursively or directly", p)
+			}
+			pin := pinmanifest.Pin{Cid: rp.Cid(), Mode: mode}
+			if _, err := cid.Decode(strings.TrimPrefix(p, "/ipfs/")); err != nil {
+				pin.Name = p
+			}
+			pins = append(pins, pin)
+		}
+
+		m := pinmanifest.New(pins, meta)
+		if unsigned, _ := req.Options[pinExportUnsignedOptionName].(bool); !unsigned {
+			keyName, _ := req.Options[pinExportKeyOptionName].(string)
+			k, err := manifestKey(n, keyName)
+			if err != nil {
+				return err
+			}
+			if err := m.Sign(k); err != nil {
+				return err
+			}
+		}
+		return cmds.EmitOnce(res, m)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, m *pinmanifest.Manifest) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(m)
+		}),
+	},
+}
+
+func parseManifestMeta(v interface{}) (map[string]string, error) {
+	pairs, _ := v.([]string)
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	meta := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid --%s %q, expected key=value", pinExportMetaOptionName, pair)
+		}
+		meta[kv[0]] = kv[1]
+	}
+	return meta, nil
+}
+
+// manifestKey returns the key named name, "self" being the identity of the
+// node.
+func manifestKey(n *core.IpfsNode, name string) (crypto.PrivKey, error) {
+	if name == "" || name == "self" {
+		return n.PrivateKey, nil
+	}
+	return n.Repo.Keystore().Get(name)
+}
+
+// PinImportOutput is the outcome of importing a pin of a manifest.
+type PinImportOutput struct {
+	Cid   string
+	Mode  string
+	Name  string `json:",omitempty"`
+	Job   string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+var importPinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Pin the roots of a manifest of 'ipfs pin export'.",
+		ShortDescription: `
+Pins the roots listed by a manifest of 'ipfs pin export', fetching their
+blocks from the network.
+`,
+		LongDescription: `
+Pins the roots listed by a manifest of 'ipfs pin export', fetching their
+blocks from the network.
+
+The signature of the manifest is checked, and unsigned manifests are refused
+unless --allow-unsigned is given. --signer only accepts manifests signed by
+the given peer ID, e.g. the ID of the node that exported them.
+
+With --background, the pins are queued as background jobs, like
+'ipfs pin add --background'. Otherwise they are pinned one after the other;
+pins which fail are reported, and the others are still pinned.
+
+Example:
+  $ ipfs pin import --signer=12D3KooW... pins.json
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("manifest", true, false, "The manifest to import.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(pinImportSignerOptionName, "Only import a manifest signed by this peer ID."),
+		cmds.BoolOption(pinImportAllowUnsignedOptionName, "Import an unsigned manifest."),
+		cmds.BoolOption(pinBackgroundOptionName, "Queue the pins as background jobs."),
+		cmds.StringOption(pinPriorityOptionName, "Only with --background, priority of the jobs: low, normal or high.").WithDefault("normal"),
+	},
+	Type: PinImportOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		api, err := cmdenv.GetApi(env, req)
+		if err != nil {
+			return err
+		}
+		enc, err := cmdenv.GetCidEncoder(req)
+		if err != nil {
+			return err
+		}
+
+		it := req.Files.Entries()
+		if !it.Next() {
+			if it.Err() != nil {
+				return it.Err()
+			}
+			return errors.New("missing manifest")
+		}
+		file := files.FileFromEntry(it)
+		if file == nil {
+			return errors.New("expected a file")
+		}
+		defer file.Close()
+		m, err := pinmanifest.Read(file)
+		if err != nil {
+			return err
+		}
+
+		signer, err := m.Verify()
+		switch allowUnsigned, _ := req.Options[pinImportAllowUnsignedOptionName].(bool); {
+		case errors.Is(err, pinmanifest.ErrUnsigned) && allowUnsigned:
+		case err != nil:
+			return err
+		}
+		if want, _ := req.Options[pinImportSignerOptionName].(string); want != "" {
+			wantID, err := peer.Decode(want)
+			if err != nil {
+				return fmt.Errorf("invalid --%s: %w", pinImportSignerOptionName, err)
+			}
+			if signer != wantID {
+				return fmt.Errorf("the manifest is not signed by %s", want)
+			}
+		}
+
+		background, _ := req.Options[pinBackgroundOptionName].(bool)
+		if background && !n.IsDaemon {
+			return fmt.Errorf("--%s requires a running daemon", pinBackgroundOptionName)
+		}
+		priority, _ := req.Options[pinPriorityOptionName].(string)
+		prio, err := prefetch.ParsePriority(priority)
+		if err != nil {
+			return err
+		}
+
+		var failed int
+		for _, p := range m.Pins {
+			out := &PinImportOutput{Cid: enc.Encode(p.Cid), Mode: p.Mode, Name: p.Name}
+			recursive := p.Mode == pinmanifest.Recursive
+			if background {
+				out.Job, err = n.PinQueue.Add(p.Cid, recursive, prio)
+			} else {
+				err = api.Pin().Add(req.Context, path.IpfsPath(p.Cid), options.Pin.Recursive(recursive))
+			}
+			if err != nil {
+				if req.Context.Err() != nil {
+					return req.Context.Err()
+				}
+				out.Error = err.Error()
+				failed++
+			}
+			if err := res.Emit(out); err != nil {
+				return err
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("failed to import %d of %d pins", failed, len(m.Pins))
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PinImportOutput) error {
+			name := ""
+			if out.Name != "" {
+				name = " (" + out.Name + ")"
+			}
+			switch {
+			case out.Error != "":
+				fmt.Fprintf(w, "failed to pin %s%s: %s\n", out.Cid, name, out.Error)
+			case out.Job != "":
+				fmt.Fprintf(w, "queued %s%s %s as job %s\n", out.Cid, name, out.Mode, out.Job)
+			default:
+				fmt.Fprintf(w, "pinned %s%s %s\n", out.Cid, name, out.Mode)
+			}
+			return nil
+		}),
+	},
+}
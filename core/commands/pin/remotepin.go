@@ -7,6 +7,8 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/tabwriter"
 	"time"
 
@@ -15,12 +17,17 @@ import (
 
 	"golang.org/x/sync/errgroup"
 
+	humanize "github.com/dustin/go-humanize"
+	blocks "github.com/ipfs/go-block-format"
 	cid "github.com/ipfs/go-cid"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	bitswap "github.com/ipfs/go-libipfs/bitswap"
 	logging "github.com/ipfs/go-log"
 	pinclient "github.com/ipfs/go-pinning-service-http-client"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	path "github.com/ipfs/interface-go-ipfs-core/path"
 	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -39,6 +46,7 @@ var remotePinCmd = &cmds.Command{
 		"ls":      listRemotePinCmd,
 		"rm":      rmRemotePinCmd,
 		"service": remotePinServiceCmd,
+		"status":  statusRemotePinCmd,
 	},
 }
 
@@ -64,11 +72,16 @@ const pinServiceKeyArgName = "key"
 const pinServiceStatOptionName = "stat"
 const pinBackgroundOptionName = "background"
 const pinForceOptionName = "force"
+const pinParallelOptionName = "parallel"
+const pinGroupOptionName = "group"
 
 type RemotePinOutput struct {
-	Status string
-	Cid    string
-	Name   string
+	Status   string
+	Cid      string
+	Name     string
+	Service  string `json:",omitempty"` // service of a group the pin was sent to
+	Progress bool   `json:",omitempty"` // intermediate status update
+	Sent     uint64 `json:",omitempty"` // bytes sent to delegates so far
 }
 
 func toRemotePinOutput(ps pinclient.PinStatusGetter) RemotePinOutput {
@@ -88,11 +101,15 @@ func printRemotePinDetails(w io.Writer, out *RemotePinOutput) {
 	fw("CID", out.Cid)
 	fw("Name", out.Name)
 	fw("Status", out.Status)
+	if out.Service != "" {
+		fw("Service", out.Service)
+	}
 }
 
 // remote pin commands
 
 var pinServiceNameOption = cmds.StringOption(pinServiceNameOptionName, "Name of the remote pinning service to use (mandatory).")
+var pinGroupOption = cmds.StringOption(pinGroupOptionName, "Name of the remote pinning service group from Pinning.RemoteGroups to use instead of --service.")
 
 var addRemotePinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
@@ -125,130 +142,326 @@ NOTE: a comma-separated notation is supported in CLI for convenience:
 
   $ ipfs pin remote ls --service=mysrv --cid=bafkqaaa --status=queued,pinning,pinned,failed
 
+To replicate a pin to N of the services of a group configured in
+Pinning.RemoteGroups, with automatic retries and failover to the next service
+of the group, pass '--group' instead of '--service':
+
+  $ ipfs pin remote add --group=mygroup bafkqaaa
+
+Multiple paths can be pinned at once. Up to '--parallel' requests are sent to
+the remote service and transferred at the same time:
+
+  $ ipfs pin remote add --service=mysrv --parallel=8 --progress bafy1 bafy2 bafy3
+
+When the service returns delegates, the local node connects to them and keeps
+these connections open until the pin completes, and announces the CID to them
+over bitswap and to the routing system when it has it, so that the service can
+fetch blocks over bitswap without waiting for a DHT lookup. Pass '--progress'
+to print status changes and the amount of data sent to the delegates.
+
 `,
 	},
 
 	Arguments: []cmds.Argument{
-		cmds.StringArg("ipfs-path", true, false, "CID or Path to be pinned."),
+		cmds.StringArg("ipfs-path", true, true, "CID or Path to be pinned."),
 	},
 	Options: []cmds.Option{
 		pinServiceNameOption,
+		pinGroupOption,
 		cmds.StringOption(pinNameOptionName, "An optional name for the pin."),
 		cmds.BoolOption(pinBackgroundOptionName, "Add to the queue on the remote service and return immediately (does not wait for pinned status).").WithDefault(false),
+		cmds.IntOption(pinParallelOptionName, "Number of pins requested and transferred in parallel.").WithDefault(4),
+		cmds.BoolOption(pinProgressOptionName, "Stream status changes and transfer progress (ignored with --background).").WithDefault(false),
 	},
 	Type: RemotePinOutput{},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		ctx, cancel := context.WithCancel(req.Context)
 		defer cancel()
 
-		// Get remote service
-		c, err := getRemotePinServiceFromRequest(req, env)
-		if err != nil {
-			return err
+		// Get remote service or service group
+		var c *pinclient.Client
+		group, groupFound := req.Options[pinGroupOptionName].(string)
+		if groupFound {
+			if _, serviceFound := req.Options[pinServiceNameOptionName]; serviceFound {
+				return fmt.Errorf("--%s and --%s are mutually exclusive", pinServiceNameOptionName, pinGroupOptionName)
+			}
+		} else {
+			var err error
+			c, err = getRemotePinServiceFromRequest(req, env)
+			if err != nil {
+				return err
+			}
 		}
 
-		// Prepare value for Pin.cid
-		if len(req.Arguments) != 1 {
-			return fmt.Errorf("expecting one CID argument")
+		// Prepare values for Pin.cid
+		if len(req.Arguments) == 0 {
+			return fmt.Errorf("expecting at least one CID argument")
 		}
-		api, err := cmdenv.GetApi(env, req)
-		if err != nil {
-			return err
+		parallel, _ := req.Options[pinParallelOptionName].(int)
+		if parallel < 1 {
+			return fmt.Errorf("%s must be positive", pinParallelOptionName)
 		}
-		rp, err := api.ResolvePath(ctx, path.New(req.Arguments[0]))
+		api, err := cmdenv.GetApi(env, req)
 		if err != nil {
 			return err
 		}
-
-		// Prepare Pin.name
-		opts := []pinclient.AddOption{}
-		if name, nameFound := req.Options[pinNameOptionName]; nameFound {
-			nameStr := name.(string)
-			opts = append(opts, pinclient.PinOpts.WithName(nameStr))
+		cids := make([]cid.Cid, 0, len(req.Arguments))
+		for _, arg := range req.Arguments {
+			rp, err := api.ResolvePath(ctx, path.New(arg))
+			if err != nil {
+				return err
+			}
+			cids = append(cids, rp.Cid())
 		}
 
-		// Prepare Pin.origins
-		// If CID in blockstore, add own multiaddrs to the 'origins' array
-		// so pinning service can use that as a hint and connect back to us.
 		node, err := cmdenv.GetNode(env)
 		if err != nil {
 			return err
 		}
 
-		isInBlockstore, err := node.Blockstore.Has(req.Context, rp.Cid())
-		if err != nil {
-			return err
+		background, _ := req.Options[pinBackgroundOptionName].(bool)
+		progress, _ := req.Options[pinProgressOptionName].(bool)
+		p := &remotePinner{
+			client:     c,
+			node:       node,
+			api:        api,
+			background: background,
+			progress:   progress && !background,
+			textOutput: cmds.GetEncoding(req, cmds.Text) == cmds.Text,
+			res:        res,
+			emitMu:     new(sync.Mutex),
+		}
+		if name, nameFound := req.Options[pinNameOptionName]; nameFound {
+			p.name = name.(string)
 		}
 
-		if isInBlockstore && node.PeerHost != nil {
-			addrs, err := peer.AddrInfoToP2pAddrs(host.InfoFromHost(node.PeerHost))
+		pin := p.pin
+		if groupFound {
+			gp, err := newGroupPinner(env, p, group)
 			if err != nil {
 				return err
 			}
-			opts = append(opts, pinclient.PinOpts.WithOrigins(addrs...))
-		} else if isInBlockstore && !node.IsOnline && cmds.GetEncoding(req, cmds.Text) == cmds.Text {
-			fmt.Fprintf(os.Stdout, "WARNING: the local node is offline and remote pinning may fail if there is no other provider for this CID\n")
+			pin = gp.pin
 		}
 
-		// Execute remote pin request
-		// TODO: fix panic when pinning service is down
-		ps, err := c.Add(ctx, rp.Cid(), opts...)
-		if err != nil {
-			return err
-		}
-
-		// Act on PinStatus.delegates
-		// If Pinning Service returned any delegates, proactively try to
-		// connect to them to facilitate data exchange without waiting for DHT
-		// lookup
-		for _, d := range ps.GetDelegates() {
-			// TODO: confirm this works as expected
-			p, err := peer.AddrInfoFromP2pAddr(d)
-			if err != nil {
-				return err
-			}
-			if err := api.Swarm().Connect(ctx, *p); err != nil {
-				log.Infof("error connecting to remote pin delegate %v : %w", d, err)
-			}
+		if len(cids) == 1 {
+			return pin(ctx, cids[0])
 		}
 
-		// Block unless --background=true is passed
-		if !req.Options[pinBackgroundOptionName].(bool) {
-			requestID := ps.GetRequestId()
-			for {
-				ps, err = c.GetStatusByID(ctx, requestID)
-				if err != nil {
-					return fmt.Errorf("failed to check pin status for requestid=%q due to error: %v", requestID, err)
+		var g errgroup.Group
+		g.SetLimit(parallel)
+		var failed int32
+		for _, k := range cids {
+			k := k
+			g.Go(func() error {
+				if err := pin(ctx, k); err != nil {
+					atomic.AddInt32(&failed, 1)
+					log.Errorf("remote pin of %s failed: %s", k, err)
+					return fmt.Errorf("%s: %w", k, err)
 				}
-				if ps.GetRequestId() != requestID {
-					return fmt.Errorf("failed to check pin status for requestid=%q, remote service sent unexpected requestid=%q", requestID, ps.GetRequestId())
-				}
-				s := ps.GetStatus()
-				if s == pinclient.StatusPinned {
-					break
-				}
-				if s == pinclient.StatusFailed {
-					return fmt.Errorf("remote service failed to pin requestid=%q", requestID)
-				}
-				tmr := time.NewTimer(time.Second / 2)
-				select {
-				case <-tmr.C:
-				case <-ctx.Done():
-					return fmt.Errorf("waiting for pin interrupted, requestid=%q remains on remote service", requestID)
-				}
-			}
+				return nil
+			})
 		}
-
-		return res.Emit(toRemotePinOutput(ps))
+		if err := g.Wait(); err != nil {
+			return fmt.Errorf("failed to pin %d of %d CIDs, first error: %w", atomic.LoadInt32(&failed), len(cids), err)
+		}
+		return nil
 	},
 	Encoders: cmds.EncoderMap{
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemotePinOutput) error {
+			if out.Progress {
+				fmt.Fprintf(w, "%s\t%s\t%s sent to delegates\n", out.Cid, out.Status, humanize.Bytes(out.Sent))
+				return nil
+			}
 			printRemotePinDetails(w, out)
 			return nil
 		}),
 	},
 }
 
+// remotePinner requests remote pins for one or more CIDs of a single
+// 'ipfs pin remote add' invocation.
+type remotePinner struct {
+	client     *pinclient.Client
+	service    string // set when pinning to a service group
+	node       *core.IpfsNode
+	api        coreiface.CoreAPI
+	name       string
+	background bool
+	progress   bool
+	textOutput bool
+
+	emitMu *sync.Mutex // shared by all pinners of a request
+	res    cmds.ResponseEmitter
+}
+
+func (p *remotePinner) emit(out RemotePinOutput) error {
+	out.Service = p.service
+	p.emitMu.Lock()
+	defer p.emitMu.Unlock()
+	return p.res.Emit(out)
+}
+
+func (p *remotePinner) pin(ctx context.Context, c cid.Cid) error {
+	// Prepare Pin.name
+	opts := []pinclient.AddOption{}
+	if p.name != "" {
+		opts = append(opts, pinclient.PinOpts.WithName(p.name))
+	}
+
+	// Prepare Pin.origins
+	// If CID in blockstore, add own multiaddrs to the 'origins' array
+	// so pinning service can use that as a hint and connect back to us.
+	isInBlockstore, err := p.node.Blockstore.Has(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if isInBlockstore && p.node.PeerHost != nil {
+		addrs, err := peer.AddrInfoToP2pAddrs(host.InfoFromHost(p.node.PeerHost))
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pinclient.PinOpts.WithOrigins(addrs...))
+	} else if isInBlockstore && !p.node.IsOnline && p.textOutput {
+		fmt.Fprintf(os.Stdout, "WARNING: the local node is offline and remote pinning may fail if there is no other provider for this CID\n")
+	}
+
+	// Execute remote pin request
+	// TODO: fix panic when pinning service is down
+	ps, err := p.client.Add(ctx, c, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Act on PinStatus.delegates
+	// If Pinning Service returned any delegates, proactively try to
+	// connect to them to facilitate data exchange without waiting for DHT
+	// lookup. Connections are protected until the pin completes.
+	var delegates []peer.ID
+	protectTag := "remote-pin-" + c.String()
+	for _, d := range ps.GetDelegates() {
+		pi, err := peer.AddrInfoFromP2pAddr(d)
+		if err != nil {
+			return err
+		}
+		if err := p.api.Swarm().Connect(ctx, *pi); err != nil {
+			log.Infof("error connecting to remote pin delegate %v : %s", d, err)
+			continue
+		}
+		delegates = append(delegates, pi.ID)
+		if p.node.PeerHost != nil {
+			p.node.PeerHost.ConnManager().Protect(pi.ID, protectTag)
+		}
+	}
+	defer func() {
+		if p.node.PeerHost == nil {
+			return
+		}
+		for _, d := range delegates {
+			p.node.PeerHost.ConnManager().Unprotect(d, protectTag)
+		}
+	}()
+	if isInBlockstore && len(delegates) > 0 {
+		var notifier blockNotifier
+		if bs, ok := p.node.Exchange.(*bitswap.Bitswap); ok {
+			notifier = bs
+		}
+		if err := announce(ctx, p.node.Blockstore, notifier, p.node.Provider, c); err != nil {
+			log.Infof("error announcing %s to remote pin delegates: %s", c, err)
+		}
+	}
+
+	// Block unless --background=true is passed
+	if !p.background {
+		baseline := p.sentTo(delegates)
+		lastStatus := pinclient.Status("")
+		var lastSent uint64
+		requestID := ps.GetRequestId()
+		for {
+			ps, err = p.client.GetStatusByID(ctx, requestID)
+			if err != nil {
+				return fmt.Errorf("failed to check pin status for requestid=%q due to error: %v", requestID, err)
+			}
+			if ps.GetRequestId() != requestID {
+				return fmt.Errorf("failed to check pin status for requestid=%q, remote service sent unexpected requestid=%q", requestID, ps.GetRequestId())
+			}
+			s := ps.GetStatus()
+			if s == pinclient.StatusPinned {
+				break
+			}
+			if s == pinclient.StatusFailed {
+				return fmt.Errorf("remote service failed to pin requestid=%q", requestID)
+			}
+			if p.progress {
+				sent := p.sentTo(delegates) - baseline
+				if s != lastStatus || sent != lastSent {
+					out := toRemotePinOutput(ps)
+					out.Progress = true
+					out.Sent = sent
+					if err := p.emit(out); err != nil {
+						return err
+					}
+					lastStatus, lastSent = s, sent
+				}
+			}
+			tmr := time.NewTimer(time.Second / 2)
+			select {
+			case <-tmr.C:
+			case <-ctx.Done():
+				return fmt.Errorf("waiting for pin interrupted, requestid=%q remains on remote service", requestID)
+			}
+		}
+	}
+
+	return p.emit(toRemotePinOutput(ps))
+}
+
+// blockGetter reads the blocks of the node, like its blockstore.
+type blockGetter interface {
+	Get(context.Context, cid.Cid) (blocks.Block, error)
+}
+
+// blockNotifier tells the peers wanting blocks that the node has them, like
+// bitswap.
+type blockNotifier interface {
+	NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error
+}
+
+// cidProvider announces the CIDs the node provides to the routing system.
+type cidProvider interface {
+	Provide(cid.Cid) error
+}
+
+// announce tells the delegates that the node has the root block of c: over
+// bitswap to the connected delegates already wanting it, and to the routing
+// system for those looking for providers.
+func announce(ctx context.Context, bstore blockGetter, notifier blockNotifier, prov cidProvider, c cid.Cid) error {
+	if notifier != nil {
+		blk, err := bstore.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := notifier.NotifyNewBlocks(ctx, blk); err != nil {
+			return err
+		}
+	}
+	return prov.Provide(c)
+}
+
+// sentTo returns the number of bytes sent over bitswap to the given peers.
+func (p *remotePinner) sentTo(peers []peer.ID) uint64 {
+	bs, ok := p.node.Exchange.(*bitswap.Bitswap)
+	if !ok {
+		return 0
+	}
+	var sent uint64
+	for _, pid := range peers {
+		sent += bs.LedgerForPeer(pid).Sent
+	}
+	return sent
+}
+
 var listRemotePinCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "List objects pinned to remote pinning service.",
@@ -304,6 +517,19 @@ Pass '--status=queued,pinning,pinned,failed' to list pins in all states.
 
 // Executes GET /pins/?query-with-filters
 func lsRemote(ctx context.Context, req *cmds.Request, c *pinclient.Client) (chan pinclient.PinStatusGetter, chan error, error) {
+	opts, err := lsRemoteOptions(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	psCh, errCh := c.Ls(ctx, opts...)
+
+	return psCh, errCh, nil
+}
+
+// lsRemoteOptions builds the remote ls query from the name, cid and status
+// options of the request.
+func lsRemoteOptions(req *cmds.Request) ([]pinclient.LsOption, error) {
 	opts := []pinclient.LsOption{}
 	if name, nameFound := req.Options[pinNameOptionName]; nameFound {
 		nameStr := name.(string)
@@ -316,7 +542,7 @@ func lsRemote(ctx context.Context, req *cmds.Request, c *pinclient.Client) (chan
 		for _, rawCID := range cidsRawArr {
 			parsedCID, err := cid.Decode(rawCID)
 			if err != nil {
-				return nil, nil, fmt.Errorf("CID %q cannot be parsed: %v", rawCID, err)
+				return nil, fmt.Errorf("CID %q cannot be parsed: %v", rawCID, err)
 			}
 			parsedCIDs = append(parsedCIDs, parsedCID)
 		}
@@ -328,16 +554,13 @@ func lsRemote(ctx context.Context, req *cmds.Request, c *pinclient.Client) (chan
 		for _, rawStatus := range statusRawArr {
 			s := pinclient.Status(rawStatus)
 			if s.String() == string(pinclient.StatusUnknown) {
-				return nil, nil, fmt.Errorf("status %q is not valid", rawStatus)
+				return nil, fmt.Errorf("status %q is not valid", rawStatus)
 			}
 			parsedStatuses = append(parsedStatuses, s)
 		}
 		opts = append(opts, pinclient.PinOpts.FilterStatus(parsedStatuses...))
 	}
-
-	psCh, errCh := c.Ls(ctx, opts...)
-
-	return psCh, errCh, nil
+	return opts, nil
 }
 
 var rmRemotePinCmd = &cmds.Command{
@@ -1,7 +1,13 @@
 package pin
 
 import (
+	"context"
+	"errors"
 	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/config"
 )
 
 func TestNormalizeEndpoint(t *testing.T) {
@@ -65,3 +71,93 @@ func TestNormalizeEndpoint(t *testing.T) {
 	}
 
 }
+
+func TestGroupReplication(t *testing.T) {
+	services := []string{"a", "b", "c"}
+	cases := []struct {
+		replication *config.OptionalInteger
+		services    []string
+		out         int
+		err         bool
+	}{
+		{nil, services, 3, false},
+		{config.NewOptionalInteger(2), services, 2, false},
+		{config.NewOptionalInteger(0), services, 0, true},
+		{config.NewOptionalInteger(4), services, 0, true},
+		{nil, nil, 0, true},
+	}
+	for _, tc := range cases {
+		out, err := groupReplication("g", config.RemotePinningGroup{Services: tc.services, Replication: tc.replication})
+		if (err != nil) != tc.err {
+			t.Errorf("unexpected error for %v: %v", tc.replication, err)
+			continue
+		}
+		if out != tc.out {
+			t.Errorf("expected %d, got %d", tc.out, out)
+		}
+	}
+}
+
+type announceRecorder struct {
+	notified []cid.Cid
+	provided []cid.Cid
+}
+
+func (r *announceRecorder) NotifyNewBlocks(ctx context.Context, blks ...blocks.Block) error {
+	for _, b := range blks {
+		r.notified = append(r.notified, b.Cid())
+	}
+	return nil
+}
+
+func (r *announceRecorder) Provide(c cid.Cid) error {
+	r.provided = append(r.provided, c)
+	return nil
+}
+
+type mapBlockGetter map[cid.Cid]blocks.Block
+
+func (m mapBlockGetter) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, ok := m[c]
+	if !ok {
+		return nil, errors.New("block not found")
+	}
+	return b, nil
+}
+
+func TestAnnounce(t *testing.T) {
+	ctx := context.Background()
+	blk := blocks.NewBlock([]byte("remote pin root"))
+	bstore := mapBlockGetter{blk.Cid(): blk}
+
+	// over bitswap and to the routing system
+	rec := &announceRecorder{}
+	if err := announce(ctx, bstore, rec, rec, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.notified) != 1 || !rec.notified[0].Equals(blk.Cid()) {
+		t.Errorf("expected %s to be announced over bitswap, got %v", blk.Cid(), rec.notified)
+	}
+	if len(rec.provided) != 1 || !rec.provided[0].Equals(blk.Cid()) {
+		t.Errorf("expected %s to be provided, got %v", blk.Cid(), rec.provided)
+	}
+
+	// without bitswap, only to the routing system
+	rec = &announceRecorder{}
+	if err := announce(ctx, bstore, nil, rec, blk.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.notified) != 0 || len(rec.provided) != 1 {
+		t.Errorf("expected %s to only be provided, got %v and %v", blk.Cid(), rec.notified, rec.provided)
+	}
+
+	// the blocks the node doesn't have are not announced
+	missing := blocks.NewBlock([]byte("missing"))
+	rec = &announceRecorder{}
+	if err := announce(ctx, bstore, rec, rec, missing.Cid()); err == nil {
+		t.Error("expected announcing a missing block to fail")
+	}
+	if len(rec.notified) != 0 || len(rec.provided) != 0 {
+		t.Errorf("expected nothing to be announced, got %v and %v", rec.notified, rec.provided)
+	}
+}
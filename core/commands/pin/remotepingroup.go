@@ -0,0 +1,340 @@
+package pin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	pinclient "github.com/ipfs/go-pinning-service-http-client"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
+	"golang.org/x/sync/errgroup"
+)
+
+// groupPinner replicates pins to the services of a remote pinning service
+// group, failing over to the next service of the group when one fails.
+type groupPinner struct {
+	pinners     []*remotePinner
+	replication int
+	maxRetries  int
+	backoff     time.Duration
+}
+
+func newGroupPinner(env cmds.Environment, base *remotePinner, name string) (*groupPinner, error) {
+	group, err := getRemotePinGroup(env, name)
+	if err != nil {
+		return nil, err
+	}
+	replication, err := groupReplication(name, group)
+	if err != nil {
+		return nil, err
+	}
+
+	gp := &groupPinner{
+		replication: replication,
+		maxRetries:  int(group.MaxRetries.WithDefault(config.DefaultRemotePinningGroupMaxRetries)),
+		backoff:     group.RetryBackoff.WithDefault(config.DefaultRemotePinningGroupRetryBackoff),
+	}
+	for _, svc := range group.Services {
+		c, err := getRemotePinService(env, svc)
+		if err != nil {
+			return nil, fmt.Errorf("group %q: service %q: %w", name, svc, err)
+		}
+		gp.pinners = append(gp.pinners, &remotePinner{
+			client:     c,
+			service:    svc,
+			node:       base.node,
+			api:        base.api,
+			name:       base.name,
+			background: base.background,
+			progress:   base.progress,
+			textOutput: base.textOutput,
+			// the pinners of the group emit to the same response
+			emitMu: base.emitMu,
+			res:    base.res,
+		})
+	}
+	return gp, nil
+}
+
+type groupPinResult struct {
+	service string
+	err     error
+}
+
+// pin requests the pin from the first services of the group and starts the
+// next service of the group whenever one fails, until enough services
+// confirmed the pin or the group is exhausted.
+func (gp *groupPinner) pin(ctx context.Context, c cid.Cid) error {
+	results := make(chan groupPinResult)
+	next := 0
+	start := func() bool {
+		if next >= len(gp.pinners) {
+			return false
+		}
+		p := gp.pinners[next]
+		next++
+		go func() {
+			results <- groupPinResult{service: p.service, err: gp.pinWithRetry(ctx, p, c)}
+		}()
+		return true
+	}
+
+	running := 0
+	for i := 0; i < gp.replication && start(); i++ {
+		running++
+	}
+
+	var pinned int
+	var errs []string
+	for running > 0 {
+		r := <-results
+		running--
+		if r.err == nil {
+			pinned++
+			continue
+		}
+		log.Warnf("remote pin of %s to service %q failed: %s", c, r.service, r.err)
+		errs = append(errs, fmt.Sprintf("%s: %s", r.service, r.err))
+		if start() {
+			running++
+		}
+	}
+
+	if pinned < gp.replication {
+		return fmt.Errorf("pinned to %d of %d required services (%s)", pinned, gp.replication, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (gp *groupPinner) pinWithRetry(ctx context.Context, p *remotePinner, c cid.Cid) error {
+	var err error
+	for attempt := 0; attempt <= gp.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(gp.backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if err = p.pin(ctx, c); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func groupReplication(name string, group config.RemotePinningGroup) (int, error) {
+	if len(group.Services) == 0 {
+		return 0, fmt.Errorf("group %q has no services", name)
+	}
+	replication := int(group.Replication.WithDefault(int64(len(group.Services))))
+	if replication < 1 || replication > len(group.Services) {
+		return 0, fmt.Errorf("group %q: Replication must be between 1 and %d", name, len(group.Services))
+	}
+	return replication, nil
+}
+
+func getRemotePinGroup(env cmds.Environment, name string) (config.RemotePinningGroup, error) {
+	if name == "" {
+		return config.RemotePinningGroup{}, fmt.Errorf("remote pinning group name not specified")
+	}
+	cfgRoot, err := cmdenv.GetConfigRoot(env)
+	if err != nil {
+		return config.RemotePinningGroup{}, err
+	}
+	repo, err := fsrepo.Open(cfgRoot)
+	if err != nil {
+		return config.RemotePinningGroup{}, err
+	}
+	defer repo.Close()
+	cfg, err := repo.Config()
+	if err != nil {
+		return config.RemotePinningGroup{}, err
+	}
+	group, present := cfg.Pinning.RemoteGroups[name]
+	if !present {
+		return config.RemotePinningGroup{}, fmt.Errorf("group not known")
+	}
+	return group, nil
+}
+
+type RemotePinGroupStatus struct {
+	Cid         string
+	Name        string
+	Target      int
+	Pinned      []string
+	Pending     []string
+	Failed      []string
+	Unreachable []string `json:",omitempty"`
+	Replicated  bool
+}
+
+var statusRemotePinCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Summarize replication of pins across a remote pinning service group.",
+		ShortDescription: `
+Lists pins on every service of a group from Pinning.RemoteGroups and reports,
+for each CID, which services pinned it, which are still pinning and which
+failed, compared to the group replication target.
+`,
+		LongDescription: `
+Lists pins on every service of a group from Pinning.RemoteGroups and reports,
+for each CID, which services pinned it, which are still pinning and which
+failed, compared to the group replication target.
+
+  $ ipfs pin remote status --group=mygroup
+  $ ipfs pin remote status --group=mygroup --cid=bafkqaaa
+
+Services that could not be queried are reported as unreachable.
+`,
+	},
+
+	Options: []cmds.Option{
+		pinGroupOption,
+		cmds.StringOption(pinNameOptionName, "Only report pins with names that contain the value provided (case-sensitive, exact match)."),
+		cmds.DelimitedStringsOption(",", pinCIDsOptionName, "Only report pins for the specified CIDs (comma-separated)."),
+	},
+	Type: RemotePinGroupStatus{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		ctx, cancel := context.WithCancel(req.Context)
+		defer cancel()
+
+		name, _ := req.Options[pinGroupOptionName].(string)
+		group, err := getRemotePinGroup(env, name)
+		if err != nil {
+			return err
+		}
+		target, err := groupReplication(name, group)
+		if err != nil {
+			return err
+		}
+
+		opts, err := lsRemoteOptions(req)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pinclient.PinOpts.FilterStatus(
+			pinclient.StatusQueued, pinclient.StatusPinning, pinclient.StatusPinned, pinclient.StatusFailed))
+
+		var mu sync.Mutex
+		statuses := make(map[string]*RemotePinGroupStatus)
+		var unreachable []string
+
+		var g errgroup.Group
+		for _, svc := range group.Services {
+			svc := svc
+			g.Go(func() error {
+				pins, err := lsGroupService(ctx, env, svc, opts)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					log.Warnf("failed to list pins of service %q: %s", svc, err)
+					unreachable = append(unreachable, svc)
+					return nil
+				}
+				for _, ps := range pins {
+					k := ps.GetPin().GetCid().String()
+					st, ok := statuses[k]
+					if !ok {
+						st = &RemotePinGroupStatus{Cid: k, Target: target}
+						statuses[k] = st
+					}
+					if st.Name == "" {
+						st.Name = ps.GetPin().GetName()
+					}
+					switch ps.GetStatus() {
+					case pinclient.StatusPinned:
+						st.Pinned = appendUnique(st.Pinned, svc)
+					case pinclient.StatusFailed:
+						st.Failed = appendUnique(st.Failed, svc)
+					default:
+						st.Pending = appendUnique(st.Pending, svc)
+					}
+				}
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			return err
+		}
+		if len(unreachable) == len(group.Services) {
+			return fmt.Errorf("no service of group %q could be reached", name)
+		}
+		sort.Strings(unreachable)
+
+		keys := make([]string, 0, len(statuses))
+		for k := range statuses {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			st := statuses[k]
+			sort.Strings(st.Pinned)
+			sort.Strings(st.Pending)
+			sort.Strings(st.Failed)
+			st.Unreachable = unreachable
+			st.Replicated = len(st.Pinned) >= st.Target
+			if err := res.Emit(st); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *RemotePinGroupStatus) error {
+			state := "under-replicated"
+			if out.Replicated {
+				state = "replicated"
+			}
+			list := func(s []string) string {
+				if len(s) == 0 {
+					return "-"
+				}
+				return strings.Join(s, ",")
+			}
+			fmt.Fprintf(w, "%s\t%d/%d\t%s\tpinned=%s pending=%s failed=%s",
+				out.Cid, len(out.Pinned), out.Target, state, list(out.Pinned), list(out.Pending), list(out.Failed))
+			if len(out.Unreachable) > 0 {
+				fmt.Fprintf(w, " unreachable=%s", list(out.Unreachable))
+			}
+			if out.Name != "" {
+				fmt.Fprintf(w, "\t%s", cmdenv.EscNonPrint(out.Name))
+			}
+			fmt.Fprintln(w)
+			return nil
+		}),
+	},
+}
+
+func lsGroupService(ctx context.Context, env cmds.Environment, svc string, opts []pinclient.LsOption) ([]pinclient.PinStatusGetter, error) {
+	c, err := getRemotePinService(env, svc)
+	if err != nil {
+		return nil, err
+	}
+	psCh, errCh := c.Ls(ctx, opts...)
+	var pins []pinclient.PinStatusGetter
+	for ps := range psCh {
+		pins = append(pins, ps)
+	}
+	return pins, <-errCh
+}
+
+func appendUnique(s []string, v string) []string {
+	for _, e := range s {
+		if e == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
@@ -0,0 +1,86 @@
+package pin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	cidenc "github.com/ipfs/go-cidutil/cidenc"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	core "github.com/ipfs/kubo/core"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// pinAddSpace pins paths in space, and returns their encoded roots.
+func pinAddSpace(ctx context.Context, n *core.IpfsNode, api coreiface.CoreAPI, enc cidenc.Encoder, space string, paths []string, recursive bool) ([]string, error) {
+	added := make([]string, len(paths))
+	for i, p := range paths {
+		rp, err := api.ResolvePath(ctx, path.New(p))
+		if err != nil {
+			return nil, err
+		}
+		if err := n.Spaces.Pin(ctx, space, rp.Cid(), recursive); err != nil {
+			return nil, err
+		}
+		added[i] = enc.Encode(rp.Cid())
+	}
+	return added, nil
+}
+
+// pinLsSpace lists the pins of space, or the ones of the paths of the
+// arguments, failing when one of them is not pinned in the space.
+func pinLsSpace(req *cmds.Request, n *core.IpfsNode, api coreiface.CoreAPI, space, typeStr string, emit func(value interface{}) error) error {
+	if typeStr == "indirect" {
+		return errors.New("indirect pins can not be listed in a space")
+	}
+	enc, err := cmdenv.GetCidEncoder(req)
+	if err != nil {
+		return err
+	}
+	pins, err := n.Spaces.Pins(req.Context, space)
+	if err != nil {
+		return err
+	}
+	types := make(map[cid.Cid]string, len(pins))
+	for _, p := range pins {
+		pinType := "direct"
+		if p.Recursive {
+			pinType = "recursive"
+		}
+		if typeStr == "all" || typeStr == pinType {
+			types[p.Cid] = pinType
+		}
+	}
+
+	if len(req.Arguments) == 0 {
+		for _, p := range pins {
+			pinType, ok := types[p.Cid]
+			if !ok {
+				continue
+			}
+			if err := emit(&PinLsOutputWrapper{PinLsObject: PinLsObject{Type: pinType, Cid: enc.Encode(p.Cid)}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, p := range req.Arguments {
+		rp, err := api.ResolvePath(req.Context, path.New(p))
+		if err != nil {
+			return err
+		}
+		pinType, ok := types[rp.Cid()]
+		if !ok {
+			return fmt.Errorf("path '%s' is not pinned in space %q", p, space)
+		}
+		if err := emit(&PinLsOutputWrapper{PinLsObject: PinLsObject{Type: pinType, Cid: enc.Encode(rp.Cid())}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
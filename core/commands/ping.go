@@ -23,10 +23,14 @@ type PingResult struct {
 	Success bool
 	Time    time.Duration
 	Text    string
+	// Path is the address of the pong with --per-path, or its stats once
+	// all its pings are sent.
+	Path *PathStats `json:",omitempty"`
 }
 
 const (
-	pingCountOptionName = "count"
+	pingCountOptionName   = "count"
+	pingPerPathOptionName = "per-path"
 )
 
 // ErrPingSelf is returned when the user attempts to ping themself.
@@ -39,6 +43,12 @@ var PingCmd = &cmds.Command{
 'ipfs ping' is a tool to test sending data to other nodes. It finds nodes
 via the routing system, sends pings, waits for pongs, and prints out round-
 trip latency information.
+
+With --per-path, the peer is pinged over each of its addresses separately, on
+dedicated connections and in parallel, and the latency and loss of each
+address are reported, e.g. to compare QUIC, TCP and relayed paths:
+
+  > ipfs ping --per-path -n 5 QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
 		`,
 	},
 	Arguments: []cmds.Argument{
@@ -46,6 +56,7 @@ trip latency information.
 	},
 	Options: []cmds.Option{
 		cmds.IntOption(pingCountOptionName, "n", "Number of ping messages to send.").WithDefault(10),
+		cmds.BoolOption(pingPerPathOptionName, "Ping over each address of the peer separately, and report the latency and loss of each."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -103,6 +114,13 @@ trip latency information.
 
 		ctx, cancel := context.WithTimeout(req.Context, kPingTimeout*time.Duration(numPings))
 		defer cancel()
+
+		if perPath, _ := req.Options[pingPerPathOptionName].(bool); perPath {
+			return pingPaths(ctx, n, pid, numPings, func(r *PingResult) error {
+				return res.Emit(r)
+			})
+		}
+
 		pings := ping.Ping(ctx, n.PeerHost, pid)
 
 		var (
@@ -178,7 +196,7 @@ trip latency information.
 				}
 
 				pr := event.(*PingResult)
-				if pr.Success && pr.Text == "" {
+				if pr.Success && pr.Text == "" && (pr.Path == nil || pr.Path.Sent == 0) {
 					total += pr.Time
 					count++
 				}
@@ -193,6 +211,20 @@ trip latency information.
 		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PingResult) error {
 			if len(out.Text) > 0 {
 				fmt.Fprintln(w, out.Text)
+			} else if p := out.Path; p != nil && p.Sent > 0 {
+				fmt.Fprintf(w, "%s: %d/%d pongs received, %.0f%% loss", p.Address, p.Received, p.Sent, p.Loss)
+				if p.Received > 0 {
+					fmt.Fprintf(w, ", time min/avg/max=%.2f/%.2f/%.2f ms",
+						p.Min.Seconds()*1000, p.Average.Seconds()*1000, p.Max.Seconds()*1000)
+				}
+				if p.Error != "" {
+					fmt.Fprintf(w, ", error: %s", p.Error)
+				}
+				fmt.Fprintln(w)
+			} else if p != nil && p.Error != "" {
+				fmt.Fprintf(w, "%s: failed: %s\n", p.Address, p.Error)
+			} else if out.Success && p != nil {
+				fmt.Fprintf(w, "Pong received over %s: time=%.2f ms\n", p.Address, out.Time.Seconds()*1000)
 			} else if out.Success {
 				fmt.Fprintf(w, "Pong received: time=%.2f ms\n", out.Time.Seconds()*1000)
 			} else {
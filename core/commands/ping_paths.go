@@ -0,0 +1,188 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	core "github.com/ipfs/kubo/core"
+	"github.com/libp2p/go-libp2p/core/network"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
+	swarm "github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ping "github.com/libp2p/go-libp2p/p2p/protocol/ping"
+	ma "github.com/multiformats/go-multiaddr"
+	msmux "github.com/multiformats/go-multistream"
+)
+
+// PathStats are the results of the pings sent over one address of a peer,
+// with --per-path.
+type PathStats struct {
+	Address   string
+	Transport string
+	Sent      int
+	Received  int
+	// Loss is the percentage of pings without a pong.
+	Loss    float64
+	Average time.Duration
+	Min     time.Duration
+	Max     time.Duration
+	Error   string `json:",omitempty"`
+}
+
+// pingPaths pings the peer over each of its addresses separately and in
+// parallel, on dedicated connections, and emits each pong and the stats of
+// each address.
+func pingPaths(ctx context.Context, n *core.IpfsNode, pid peer.ID, numPings int, emit func(*PingResult) error) error {
+	sw, ok := n.PeerHost.Network().(*swarm.Swarm)
+	if !ok {
+		return errors.New("pinging each address needs a libp2p swarm")
+	}
+	addrs := ma.Unique(n.Peerstore.Addrs(pid))
+	if len(addrs) == 0 {
+		return errors.New("the peer has no known address")
+	}
+
+	results := make(chan *PingResult)
+	var wg sync.WaitGroup
+	for _, a := range addrs {
+		wg.Add(1)
+		go func(a ma.Multiaddr) {
+			defer wg.Done()
+			stats := pingPath(ctx, sw, pid, a, numPings, results)
+			select {
+			case results <- &PingResult{Success: stats.Received > 0, Path: stats}:
+			case <-ctx.Done():
+			}
+		}(a)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		if err := emit(r); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// pingPath sends the pings over a new connection to the address, one per
+// second, and sends the pongs to results.
+func pingPath(ctx context.Context, sw *swarm.Swarm, pid peer.ID, a ma.Multiaddr, numPings int, results chan<- *PingResult) *PathStats {
+	stats := &PathStats{Address: a.String(), Transport: transportName(a)}
+	tpt := sw.TransportForDialing(a)
+	if tpt == nil {
+		stats.Error = "no transport for the address"
+		return stats
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, kPingTimeout)
+	conn, err := tpt.Dial(dialCtx, a, pid)
+	cancel()
+	if err != nil {
+		stats.Error = err.Error()
+		return stats
+	}
+	defer conn.Close()
+
+	var (
+		total time.Duration
+		s     network.MuxedStream
+	)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for i := 0; i < numPings; i++ {
+		if i > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return stats
+			}
+		}
+
+		stats.Sent++
+		if s == nil {
+			if s, err = openPingStream(ctx, conn); err != nil {
+				stats.Error = err.Error()
+				continue
+			}
+		}
+		rtt, err := pingOnce(s)
+		if err != nil {
+			s.Reset()
+			s = nil
+			stats.Error = err.Error()
+			continue
+		}
+
+		stats.Received++
+		total += rtt
+		if stats.Min == 0 || rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+		select {
+		case results <- &PingResult{Success: true, Time: rtt, Path: &PathStats{Address: stats.Address, Transport: stats.Transport}}:
+		case <-ctx.Done():
+			return stats
+		}
+	}
+	if s != nil {
+		s.Close()
+	}
+
+	if stats.Received > 0 {
+		stats.Average = total / time.Duration(stats.Received)
+		// the last error doesn't matter if the path works
+		stats.Error = ""
+	}
+	stats.Loss = float64(stats.Sent-stats.Received) * 100 / float64(stats.Sent)
+	return stats
+}
+
+func openPingStream(ctx context.Context, conn transport.CapableConn) (network.MuxedStream, error) {
+	ctx, cancel := context.WithTimeout(ctx, kPingTimeout)
+	defer cancel()
+	s, err := conn.OpenStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_ = s.SetDeadline(time.Now().Add(kPingTimeout))
+	if err := msmux.SelectProtoOrFail(ping.ID, s); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	return s, nil
+}
+
+// pingOnce sends a ping on the stream, as the ping protocol does, and waits
+// for the pong.
+func pingOnce(s network.MuxedStream) (time.Duration, error) {
+	buf := make([]byte, ping.PingSize)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, err
+	}
+	_ = s.SetDeadline(time.Now().Add(kPingTimeout))
+
+	start := time.Now()
+	if _, err := s.Write(buf); err != nil {
+		return 0, err
+	}
+	pong := make([]byte, ping.PingSize)
+	if _, err := io.ReadFull(s, pong); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(buf, pong) {
+		return 0, fmt.Errorf("ping packet was incorrect")
+	}
+	return time.Since(start), nil
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ import (
 	merkledag "github.com/ipfs/go-merkledag"
 	iface "github.com/ipfs/interface-go-ipfs-core"
 	path "github.com/ipfs/interface-go-ipfs-core/path"
+	selectorparse "github.com/ipld/go-ipld-prime/traversal/selector/parse"
 )
 
 var refsEncoderMap = cmds.EncoderMap{
@@ -23,6 +25,14 @@ var refsEncoderMap = cmds.EncoderMap{
 		if out.Err != "" {
 			return fmt.Errorf(out.Err)
 		}
+		if out.Edge != nil {
+			b, err := json.Marshal(out.Edge)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\n", b)
+			return nil
+		}
 		fmt.Fprintln(w, out.Ref)
 
 		return nil
@@ -40,6 +50,9 @@ const (
 	refsUniqueOptionName    = "unique"
 	refsRecursiveOptionName = "recursive"
 	refsMaxDepthOptionName  = "max-depth"
+	refsPrefetchOptionName  = "prefetch"
+	refsSelectorOptionName  = "selector"
+	refsNDJSONOptionName    = "ndjson"
 )
 
 // RefsCmd is the `ipfs refs` command
@@ -54,11 +67,31 @@ with the following format:
 
 List all references recursively by using the flag '-r'.
 
+With --selector, the DAG is traversed with an IPLD selector given in DAG-JSON
+instead, which replaces -r and --max-depth, and the refs of the blocks loaded
+by the traversal are listed, e.g. all of them like -r:
+
+  > ipfs refs --selector='{"R":{"l":{"none":{}},":>":{"a":{">":{"@":{}}}}}}' <cid>
+
+With -u, the blocks are only traversed once, which may prune some of the paths
+of complex selectors.
+
+With --ndjson, each edge is written as a JSON object on its own line, with the
+name of the link and the codecs of both ends, e.g. to build a visualization of
+the graph:
+
+  {"From":"<cid>","To":"<cid>","Name":"<linkname>","FromCodec":"dag-pb","ToCodec":"raw"}
+
+With --prefetch, the refs are not listed: a background job fetching them into
+the local blockstore is started for each path, and its ID is printed. See
+'ipfs refs prefetch --help'.
+
 NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed path if they can't be found in the local store if it is running in online mode.
 `,
 	},
 	Subcommands: map[string]*cmds.Command{
-		"local": RefsLocalCmd,
+		"local":    RefsLocalCmd,
+		"prefetch": refsPrefetchCmd,
 	},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("ipfs-path", true, true, "Path to the object(s) to list refs from.").EnableStdin(),
@@ -69,6 +102,11 @@ NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed
 		cmds.BoolOption(refsUniqueOptionName, "u", "Omit duplicate refs from output."),
 		cmds.BoolOption(refsRecursiveOptionName, "r", "Recursively list links of child nodes."),
 		cmds.IntOption(refsMaxDepthOptionName, "Only for recursive refs, limits fetch and listing to the given depth").WithDefault(-1),
+		cmds.StringOption(refsSelectorOptionName, "Traverse the DAG with the given IPLD selector, in DAG-JSON."),
+		cmds.BoolOption(refsNDJSONOptionName, "Emit edges as JSON objects with link names and codecs, one per line."),
+		cmds.BoolOption(refsPrefetchOptionName, "Fetch the refs in the background instead of listing them, and print the ID of the job."),
+		cmds.StringOption(prefetchMaxBytesOptionName, "Only with --prefetch, stop fetching after the given size, e.g. 500MiB."),
+		cmds.StringOption(prefetchPriorityOptionName, "Only with --prefetch, priority of the job: low, normal or high.").WithDefault("normal"),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		err := req.ParseBodyArgs()
@@ -92,11 +130,17 @@ NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed
 		maxDepth, _ := req.Options[refsMaxDepthOptionName].(int)
 		edges, _ := req.Options[refsEdgesOptionName].(bool)
 		format, _ := req.Options[refsFormatOptionName].(string)
+		selectorStr, _ := req.Options[refsSelectorOptionName].(string)
+		ndjson, _ := req.Options[refsNDJSONOptionName].(bool)
 
 		if !recursive {
 			maxDepth = 1 // write only direct refs
 		}
 
+		if prefetch, _ := req.Options[refsPrefetchOptionName].(bool); prefetch {
+			return startPrefetch(req, res, env, api, maxDepth)
+		}
+
 		if edges {
 			if format != "<dst>" {
 				return errors.New("using format argument with edges is not allowed")
@@ -104,6 +148,9 @@ NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed
 
 			format = "<src> -> <dst>"
 		}
+		if ndjson && (edges || format != "<dst>") {
+			return errors.New("using format or edges arguments with ndjson is not allowed")
+		}
 
 		// TODO: use session for resolving as well.
 		objs, err := objectsForPaths(ctx, api, req.Arguments)
@@ -118,6 +165,22 @@ NOTE: Like most other commands, Kubo will try to fetch the blocks of the passed
 			Unique:   unique,
 			PrintFmt: format,
 			MaxDepth: maxDepth,
+			NDJSON:   ndjson,
+		}
+
+		if selectorStr != "" {
+			sel, err := selectorparse.ParseAndCompileJSONSelector(selectorStr)
+			if err != nil {
+				return fmt.Errorf("invalid selector: %w", err)
+			}
+			for _, o := range objs {
+				if _, err := rw.WriteSelectorRefs(api, o, sel, enc); err != nil {
+					if err := res.Emit(&RefWrapper{Err: err.Error()}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
 		}
 
 		for _, o := range objs {
@@ -183,6 +246,8 @@ func objectsForPaths(ctx context.Context, n iface.CoreAPI, paths []string) ([]ci
 type RefWrapper struct {
 	Ref string
 	Err string
+	// Edge is set instead of Ref with --ndjson
+	Edge *RefEdge `json:",omitempty"`
 }
 
 type RefWriter struct {
@@ -193,6 +258,7 @@ type RefWriter struct {
 	Unique   bool
 	MaxDepth int
 	PrintFmt string
+	NDJSON   bool
 
 	seen map[string]int
 }
@@ -318,6 +384,10 @@ func (rw *RefWriter) visit(c cid.Cid, depth int) (bool, bool) {
 
 // Write one edge
 func (rw *RefWriter) WriteEdge(from, to cid.Cid, linkname string, enc cidenc.Encoder) error {
+	return rw.writeEdge(from, to, linkname, "", enc)
+}
+
+func (rw *RefWriter) writeEdge(from, to cid.Cid, linkname, linkpath string, enc cidenc.Encoder) error {
 	if rw.Ctx != nil {
 		select {
 		case <-rw.Ctx.Done(): // just in case.
@@ -326,6 +396,17 @@ func (rw *RefWriter) WriteEdge(from, to cid.Cid, linkname string, enc cidenc.Enc
 		}
 	}
 
+	if rw.NDJSON {
+		return rw.res.Emit(&RefWrapper{Edge: &RefEdge{
+			From:      enc.Encode(from),
+			To:        enc.Encode(to),
+			Name:      linkname,
+			Path:      linkpath,
+			FromCodec: codecName(from),
+			ToCodec:   codecName(to),
+		}})
+	}
+
 	var s string
 	switch {
 	case rw.PrintFmt != "":
@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/prefetch"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+const (
+	prefetchMaxBytesOptionName = "prefetch-max-bytes"
+	prefetchPriorityOptionName = "prefetch-priority"
+)
+
+// cacheWarmer is implemented by the CoreAPI of the node.
+type cacheWarmer interface {
+	WarmCache(ctx context.Context, p path.Path, opts prefetch.Options) (string, error)
+}
+
+// startPrefetch starts the prefetch jobs of 'ipfs refs --prefetch'.
+func startPrefetch(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment, api iface.CoreAPI, maxDepth int) error {
+	nd, err := cmdenv.GetNode(env)
+	if err != nil {
+		return err
+	}
+	// the jobs would die with the command
+	if !nd.IsOnline {
+		return ErrNotOnline
+	}
+	warmer, ok := api.(cacheWarmer)
+	if !ok {
+		return fmt.Errorf("prefetching is not supported by %T", api)
+	}
+
+	opts := prefetch.Options{MaxDepth: maxDepth}
+	if s, _ := req.Options[prefetchMaxBytesOptionName].(string); s != "" {
+		maxBytes, err := humanize.ParseBytes(s)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", prefetchMaxBytesOptionName, err)
+		}
+		opts.MaxBytes = maxBytes
+	}
+	priority, _ := req.Options[prefetchPriorityOptionName].(string)
+	if opts.Priority, err = prefetch.ParsePriority(priority); err != nil {
+		return err
+	}
+
+	for _, p := range req.Arguments {
+		id, err := warmer.WarmCache(req.Context, path.New(p), opts)
+		if err != nil {
+			return err
+		}
+		if err := res.Emit(&RefWrapper{Ref: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrefetchJob is the progress of a prefetch job.
+type PrefetchJob struct {
+	ID        string
+	Root      string
+	Priority  string
+	State     prefetch.State
+	Blocks    uint64
+	Bytes     uint64
+	Truncated bool
+	Error     string `json:",omitempty"`
+	Created   time.Time
+	Started   time.Time
+	Finished  time.Time
+}
+
+func toPrefetchJob(p prefetch.Progress) *PrefetchJob {
+	return &PrefetchJob{
+		ID:        p.ID,
+		Root:      p.Root.String(),
+		Priority:  p.Priority.String(),
+		State:     p.State,
+		Blocks:    p.Blocks,
+		Bytes:     p.Bytes,
+		Truncated: p.Truncated,
+		Error:     p.Err,
+		Created:   p.Created,
+		Started:   p.Started,
+		Finished:  p.Finished,
+	}
+}
+
+var refsPrefetchCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the background jobs fetching DAGs into the local blockstore.",
+		ShortDescription: `
+'ipfs refs --prefetch' starts a background job fetching the refs of a path into
+the local blockstore, e.g. to warm a gateway with content about to be
+announced. The command returns the ID of the job once the path is resolved.
+
+  > ipfs refs -r --prefetch --prefetch-max-bytes=1GiB --prefetch-priority=high /ipfs/<cid>
+  12
+
+A few jobs run at a time, the queued jobs start by priority. The progress of
+the jobs is listed with 'ipfs refs prefetch ls'. The jobs are kept in memory:
+they don't survive a restart of the daemon, and only the last 100 finished
+jobs are listed.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     refsPrefetchLsCmd,
+		"cancel": refsPrefetchCancelCmd,
+	},
+}
+
+var refsPrefetchLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the prefetch jobs and their progress.",
+		ShortDescription: `
+Lists the prefetch jobs started with 'ipfs refs --prefetch', or only the given
+ones, with the blocks and bytes fetched so far. The blocks already in the local
+blockstore are counted too.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", false, true, "ID of the jobs to list."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if len(req.Arguments) == 0 {
+			for _, p := range nd.Prefetch.List() {
+				if err := res.Emit(toPrefetchJob(p)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for _, id := range req.Arguments {
+			p, err := nd.Prefetch.Progress(id)
+			if err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+			if err := res.Emit(toPrefetchJob(p)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: PrefetchJob{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, j *PrefetchJob) error {
+			state := string(j.State)
+			switch {
+			case j.Truncated:
+				state += " (size limit reached)"
+			case j.Error != "":
+				state += ": " + j.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d blocks\t%s\t%s\n", j.ID, j.Root, j.Priority, j.Blocks, humanize.Bytes(j.Bytes), state)
+			return nil
+		}),
+	},
+}
+
+var refsPrefetchCancelCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Cancel prefetch jobs.",
+		ShortDescription: `
+Cancels queued or running prefetch jobs. The blocks already fetched are kept.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("job-id", true, true, "ID of the jobs to cancel."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range req.Arguments {
+			if err := nd.Prefetch.Cancel(id); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+		return nil
+	},
+}
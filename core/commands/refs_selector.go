@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	cidenc "github.com/ipfs/go-cidutil/cidenc"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+	dagpb "github.com/ipld/go-codec-dagpb"
+	ipld "github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	basicnode "github.com/ipld/go-ipld-prime/node/basic"
+	"github.com/ipld/go-ipld-prime/schema"
+	"github.com/ipld/go-ipld-prime/traversal"
+	"github.com/ipld/go-ipld-prime/traversal/selector"
+	mc "github.com/multiformats/go-multicodec"
+)
+
+// RefEdge is an edge of the DAG, emitted by 'ipfs refs --ndjson'.
+type RefEdge struct {
+	From string
+	To   string
+	// Name is the name of the link for dag-pb, and its path within the
+	// block of From otherwise
+	Name string
+	// Path is the path of the link from the root, only with --selector
+	Path      string `json:",omitempty"`
+	FromCodec string
+	ToCodec   string
+}
+
+func codecName(c cid.Cid) string {
+	return mc.Code(c.Prefix().Codec).String()
+}
+
+var refsPrototypeChooser = dagpb.AddSupportToChooser(func(lnk ipld.Link, lnkCtx ipld.LinkContext) (ipld.NodePrototype, error) {
+	if tlnkNd, ok := lnkCtx.LinkNode.(schema.TypedLinkNode); ok {
+		return tlnkNd.LinkTargetNodePrototype(), nil
+	}
+	return basicnode.Prototype.Any, nil
+})
+
+// WriteSelectorRefs writes the refs of the blocks loaded by the traversal of
+// the DAG under root with the selector sel. Every block is loaded once if
+// rw.Unique is set.
+func (rw *RefWriter) WriteSelectorRefs(api iface.CoreAPI, root cid.Cid, sel selector.Selector, enc cidenc.Encoder) (int, error) {
+	var count int
+	// blocks by path from the root, to find the block holding a link
+	blocks := make(map[string]cid.Cid)
+
+	lsys := cidlink.DefaultLinkSystem()
+	lsys.StorageReadOpener = func(lnkCtx ipld.LinkContext, lnk ipld.Link) (io.Reader, error) {
+		c := lnk.(cidlink.Link).Cid
+		r, err := api.Block().Get(lnkCtx.Ctx, path.IpfsPath(c))
+		if err != nil {
+			return nil, err
+		}
+
+		p := lnkCtx.LinkPath
+		blocks[p.String()] = c
+		if p.Len() == 0 {
+			// the root
+			return r, nil
+		}
+		parent := p.Pop()
+		from, ok := blocks[parent.String()]
+		for !ok {
+			parent = parent.Pop()
+			from, ok = blocks[parent.String()]
+		}
+		name := ipld.NewPath(p.Segments()[parent.Len():]).String()
+		if from.Prefix().Codec == cid.DagProtobuf && lnkCtx.ParentNode != nil {
+			if n, err := lnkCtx.ParentNode.LookupByString("Name"); err == nil {
+				name, _ = n.AsString()
+			}
+		}
+		if err := rw.writeEdge(from, c, name, p.String(), enc); err != nil {
+			return nil, err
+		}
+		count++
+		return r, nil
+	}
+
+	ctx := rw.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rootLink := cidlink.Link{Cid: root}
+	proto, err := refsPrototypeChooser(rootLink, ipld.LinkContext{Ctx: ctx})
+	if err != nil {
+		return 0, err
+	}
+	nd, err := lsys.Load(ipld.LinkContext{Ctx: ctx}, rootLink, proto)
+	if err != nil {
+		return 0, err
+	}
+
+	prog := traversal.Progress{
+		Cfg: &traversal.Config{
+			Ctx:                            ctx,
+			LinkSystem:                     lsys,
+			LinkTargetNodePrototypeChooser: refsPrototypeChooser,
+			LinkVisitOnlyOnce:              rw.Unique,
+		},
+	}
+	err = prog.WalkAdv(nd, sel, func(traversal.Progress, ipld.Node, traversal.VisitReason) error {
+		return nil
+	})
+	return count, err
+}
@@ -7,13 +7,17 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	oldcmds "github.com/ipfs/kubo/commands"
 	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
 	corerepo "github.com/ipfs/kubo/core/corerepo"
+	"github.com/ipfs/kubo/core/quota"
+	"github.com/ipfs/kubo/core/scrub"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations"
 	"github.com/ipfs/kubo/repo/fsrepo/migrations/ipfsfetcher"
@@ -42,8 +46,11 @@ var RepoCmd = &cmds.Command{
 		"fsck":    repoFsckCmd,
 		"version": repoVersionCmd,
 		"verify":  repoVerifyCmd,
+		"health":  repoHealthCmd,
 		"migrate": repoMigrateCmd,
 		"ls":      RefsLocalCmd,
+		"backup":  repoBackupCmd,
+		"restore": repoRestoreCmd,
 	},
 }
 
@@ -58,6 +65,7 @@ const (
 	repoQuietOptionName          = "quiet"
 	repoSilentOptionName         = "silent"
 	repoAllowDowngradeOptionName = "allow-downgrade"
+	repoDryRunOptionName         = "dry-run"
 )
 
 var repoGcCmd = &cmds.Command{
@@ -148,6 +156,8 @@ order to reclaim hard disk space.
 const (
 	repoSizeOnlyOptionName = "size-only"
 	repoHumanOptionName    = "human"
+	repoDetailedOptionName = "detailed"
+	repoForecastOptionName = "forecast"
 )
 
 var repoStatCmd = &cmds.Command{
@@ -162,11 +172,24 @@ StorageMax      string Maximum datastore size (from configuration)
 NumObjects      int Number of objects in the local repo.
 RepoPath        string The path to the repo being currently used.
 Version         string The repo version.
+
+With --detailed, it also breaks down the size and number of blocks by
+namespace, along with the quotas set in Datastore.Quotas. Blocks reachable
+from a pin are attributed to Pins, blocks only reachable from MFS to MFS, and
+the remaining blocks to Cache. This walks all pins and MFS, which may take a
+while on large repos.
+
+With --forecast, it also reports the daily growth of the repo and of each
+namespace over the last 30 days, and the number of days before the repo
+reaches StorageMax at this rate. The growth is computed from the size of the
+repo recorded once a day by the daemon.
 `,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(repoSizeOnlyOptionName, "s", "Only report RepoSize and StorageMax."),
 		cmds.BoolOption(repoHumanOptionName, "H", "Print sizes in human readable format (e.g., 1K 234M 2G)"),
+		cmds.BoolOption(repoDetailedOptionName, "Break down the repo usage by namespace (pins, MFS, cache)."),
+		cmds.BoolOption(repoForecastOptionName, "Forecast when the repo reaches StorageMax from its growth."),
 	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		n, err := cmdenv.GetNode(env)
@@ -175,14 +198,20 @@ Version         string The repo version.
 		}
 
 		sizeOnly, _ := req.Options[repoSizeOnlyOptionName].(bool)
+		forecast, _ := req.Options[repoForecastOptionName].(bool)
 		if sizeOnly {
-			sizeStat, err := corerepo.RepoSize(req.Context, n)
+			stat := corerepo.Stat{}
+			stat.SizeStat, err = corerepo.RepoSize(req.Context, n)
 			if err != nil {
 				return err
 			}
-			return cmds.EmitOnce(res, &corerepo.Stat{
-				SizeStat: sizeStat,
-			})
+			if forecast {
+				stat.Forecast, err = corerepo.RepoForecast(req.Context, n, stat.SizeStat)
+				if err != nil {
+					return err
+				}
+			}
+			return cmds.EmitOnce(res, &stat)
 		}
 
 		stat, err := corerepo.RepoStat(req.Context, n)
@@ -190,6 +219,20 @@ Version         string The repo version.
 			return err
 		}
 
+		if forecast {
+			stat.Forecast, err = corerepo.RepoForecast(req.Context, n, stat.SizeStat)
+			if err != nil {
+				return err
+			}
+		}
+
+		if detailed, _ := req.Options[repoDetailedOptionName].(bool); detailed {
+			stat.Namespaces, err = n.Quotas.Report(req.Context)
+			if err != nil {
+				return err
+			}
+		}
+
 		return cmds.EmitOnce(res, &stat)
 	},
 	Type: &corerepo.Stat{},
@@ -222,6 +265,52 @@ Version         string The repo version.
 				fmt.Fprintf(wtr, "Version:\t%s\n", stat.Version)
 			}
 
+			if ns := stat.Namespaces; ns != nil {
+				for _, u := range []struct {
+					name string
+					quota.Usage
+				}{{"Pins", ns.Pins}, {"MFS", ns.MFS}, {"Cache", ns.Cache}} {
+					fmt.Fprintf(wtr, "%s:\n", u.name)
+					fmt.Fprintf(wtr, "  NumObjects:\t%d\n", u.NumObjects)
+					printSize("  Size", u.Size)
+					if u.Quota == quota.NoLimit {
+						fmt.Fprintf(wtr, "  Quota:\tnone\n")
+					} else {
+						printSize("  Quota", u.Quota)
+					}
+				}
+			}
+
+			if f := stat.Forecast; f != nil {
+				fmt.Fprintf(wtr, "Forecast:\n")
+				if f.Days == 0 {
+					fmt.Fprintf(wtr, "  not enough daily samples, the daemon records one per day\n")
+					return nil
+				}
+				printGrowth := func(name string, growth int64) {
+					sign := ""
+					size := uint64(growth)
+					if growth < 0 {
+						sign, size = "-", uint64(-growth)
+					}
+					sizeStr := fmt.Sprintf("%s%d", sign, size)
+					if human {
+						sizeStr = sign + humanize.Bytes(size)
+					}
+					fmt.Fprintf(wtr, "%s:\t%s/day\n", name, sizeStr)
+				}
+				fmt.Fprintf(wtr, "  Days:\t%d\n", f.Days)
+				printGrowth("  RepoGrowth", f.RepoGrowth)
+				printGrowth("  PinsGrowth", f.PinsGrowth)
+				printGrowth("  MFSGrowth", f.MFSGrowth)
+				printGrowth("  CacheGrowth", f.CacheGrowth)
+				if f.DaysUntilFull == quota.NeverFull {
+					fmt.Fprintf(wtr, "  DaysUntilFull:\tnever\n")
+				} else {
+					fmt.Fprintf(wtr, "  DaysUntilFull:\t%d\n", f.DaysUntilFull)
+				}
+			}
+
 			return nil
 		}),
 	},
@@ -363,6 +452,61 @@ var repoVerifyCmd = &cmds.Command{
 	},
 }
 
+const repoCorruptedOptionName = "corrupted"
+
+var repoHealthCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the health of the shards of the blockstore.",
+		ShortDescription: `
+'ipfs repo health' lists the shards of the blockstore checked by the
+background scrubber, when they were last checked, and the number of corrupted
+blocks they contain. The scrubber is enabled with Datastore.Scrub.Enabled.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoCorruptedOptionName, "Only list the shards with corrupted blocks."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		corrupted, _ := req.Options[repoCorruptedOptionName].(bool)
+
+		health, err := scrub.Health(req.Context, nd.Repo.Datastore())
+		if err != nil {
+			return err
+		}
+		shards := make([]string, 0, len(health))
+		for shard := range health {
+			shards = append(shards, shard)
+		}
+		sort.Strings(shards)
+
+		for _, shard := range shards {
+			h := health[shard]
+			if corrupted && h.Corrupted == 0 {
+				continue
+			}
+			if err := res.Emit(h); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: scrub.ShardHealth{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, h *scrub.ShardHealth) error {
+			status := "ok"
+			if h.Corrupted > 0 {
+				status = fmt.Sprintf("%d corrupted: %s", h.Corrupted, strings.Join(h.CorruptedBlocks, " "))
+			}
+			fmt.Fprintf(w, "%s\t%d blocks\t%s\tchecked %s\t%s\n", h.Shard, h.Blocks, humanize.IBytes(h.Size), h.Checked.Format(time.RFC3339), status)
+			return nil
+		}),
+	},
+}
+
 var repoVersionCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Show the repo version.",
@@ -397,9 +541,21 @@ var repoVersionCmd = &cmds.Command{
 var repoMigrateCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Apply any outstanding migrations to the repo.",
+		ShortDescription: `
+Migrates the repo to the version of this ipfs binary. The migrations built
+into ipfs run without network access, the others are run from the binaries
+found in PATH, or downloaded.
+
+Before each migration, the config, keys and version of the repo are saved in
+<repo>/migration-backup, and restored if the migration fails. The datastore
+isn't saved.
+
+Use --dry-run to list the migrations without running them.
+`,
 	},
 	Options: []cmds.Option{
 		cmds.BoolOption(repoAllowDowngradeOptionName, "Allow downgrading to a lower repo version"),
+		cmds.BoolOption(repoDryRunOptionName, "List the migrations to run, without running them."),
 	},
 	NoRemote: true,
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
@@ -415,6 +571,24 @@ var repoMigrateCmd = &cmds.Command{
 			return err
 		}
 
+		if dryRun, _ := req.Options[repoDryRunOptionName].(bool); dryRun {
+			steps, err := migrations.PlanMigration(cctx.Context(), fsrepo.RepoVersion, "", allowDowngrade)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Migrations to run to version %d:\n", fsrepo.RepoVersion)
+			for _, step := range steps {
+				fmt.Printf("  %s\n", step)
+			}
+			return nil
+		}
+
+		if replicas, err := fsrepo.ReadReplicas(cctx.ConfigRoot); err != nil {
+			return err
+		} else if replicas > 0 {
+			return fmt.Errorf("%d read replicas of the repo are open, stop them before migrating", replicas)
+		}
+
 		fmt.Println("Found outdated fs-repo, starting migration.")
 
 		// Read Migration section of IPFS config
@@ -0,0 +1,330 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-datastore"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	"github.com/ipfs/go-libipfs/files"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-mfs"
+	oldcmds "github.com/ipfs/kubo/commands"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/repobackup"
+	"github.com/ipfs/kubo/repo"
+	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
+	gocar "github.com/ipld/go-car"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const (
+	repoBackupBlocksOptionName     = "blocks"
+	repoBackupPassphraseOptionName = "passphrase-file"
+)
+
+var repoBackupCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Write a backup of the repo to stdout.",
+		ShortDescription: `
+'ipfs repo backup' writes a consistent snapshot of the repo as a tar archive:
+the config, including the identity key, the keys of the keystore, the pinset
+and the root of the files API (MFS).
+`,
+		LongDescription: `
+'ipfs repo backup' writes a consistent snapshot of the repo as a tar archive:
+the config, including the identity key, the keys of the keystore, the pinset
+and the root of the files API (MFS).
+
+As the backup holds the private keys, it is written by the CLI with the
+daemon stopped, and never sent over the RPC API. The garbage collector and
+pinning are paused while the snapshot of the pins and the files API is taken.
+
+With --blocks, the archive also holds the blocks of the pins and of the files
+API as a CAR file, so that the backup doesn't depend on the network to be
+restored.
+
+As the backup holds private keys, it can be encrypted with the passphrase in
+the file given to --passphrase-file.
+
+  > ipfs repo backup --blocks --passphrase-file=pass.txt > ipfs-backup.tar.enc
+
+Restore it with 'ipfs repo restore'.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(repoBackupBlocksOptionName, "Include the blocks of the pins and the files API."),
+		cmds.StringOption(repoBackupPassphraseOptionName, "Encrypt the backup with the passphrase in the given file."),
+	},
+	// the backup holds the private keys, like 'ipfs key export'
+	NoRemote: true,
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return err
+		}
+		withBlocks, _ := req.Options[repoBackupBlocksOptionName].(bool)
+
+		snapshot, err := takeRepoSnapshot(req.Context, n, cfg, withBlocks)
+		if err != nil {
+			return err
+		}
+
+		pipeR, pipeW := io.Pipe()
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := snapshot.Write(req.Context, pipeW)
+			pipeW.CloseWithError(err)
+			errCh <- err
+		}()
+
+		if err := res.Emit(pipeR); err != nil {
+			pipeR.Close()
+			return err
+		}
+		return <-errCh
+	},
+	PostRun: cmds.PostRunMap{
+		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
+			passFile, _ := res.Request().Options[repoBackupPassphraseOptionName].(string)
+			if passFile == "" {
+				return cmds.Copy(re, res)
+			}
+			passphrase, err := readPassphrase(passFile)
+			if err != nil {
+				return err
+			}
+
+			v, err := res.Next()
+			if err != nil {
+				return err
+			}
+			r, ok := v.(io.Reader)
+			if !ok {
+				return errors.New("unexpected non-stream passed to PostRun: please file a bugreport")
+			}
+
+			pipeR, pipeW := io.Pipe()
+			go func() {
+				enc, err := repobackup.Encrypt(pipeW, passphrase)
+				if err == nil {
+					_, err = io.Copy(enc, r)
+				}
+				if err == nil {
+					err = enc.Close()
+				}
+				pipeW.CloseWithError(err)
+			}()
+			if err := re.Emit(pipeR); err != nil {
+				return err
+			}
+			return re.Close()
+		},
+	},
+}
+
+// takeRepoSnapshot takes the snapshot of the repo of n to back up. The pin
+// lock stops GC and pinning while it's taken, so that the pins, the MFS root
+// and the list of their blocks are consistent, and is released before the
+// backup is written. The node is offline, no GC runs meanwhile.
+func takeRepoSnapshot(ctx context.Context, n *core.IpfsNode, cfg *config.Config, withBlocks bool) (*repobackup.Snapshot, error) {
+	defer n.Blockstore.PinLock(ctx).Unlock(ctx)
+	root, err := mfs.FlushPath(ctx, n.FilesRoot, "/")
+	if err != nil {
+		return nil, err
+	}
+	src := repobackup.Source{
+		Config:   cfg,
+		Keystore: n.Repo.Keystore(),
+		Pinner:   n.Pinning,
+		MFSRoot:  root.Cid(),
+	}
+	if withBlocks {
+		src.Blocks = n.Blockstore
+	}
+	return repobackup.Take(ctx, src)
+}
+
+func readPassphrase(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	passphrase := bytes.TrimRight(data, "\r\n")
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty passphrase in %s", path)
+	}
+	return passphrase, nil
+}
+
+var repoRestoreCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Restore a backup of 'ipfs repo backup' to a new repo.",
+		ShortDescription: `
+'ipfs repo restore' initializes the repo from a backup written by
+'ipfs repo backup': the config, the keys and the pins are restored, and the
+blocks and the root of the files API if the blocks were backed up.
+
+The repo must not exist yet. Encrypted backups are decrypted with the
+passphrase in the file given to --passphrase-file.
+
+  > ipfs repo restore --passphrase-file=pass.txt ipfs-backup.tar.enc
+
+Pins whose blocks weren't backed up are fetched from the network once the
+daemon runs, when they're reprovided or accessed.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.FileArg("backup", true, false, "The backup to restore.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(repoBackupPassphraseOptionName, "Decrypt the backup with the passphrase in the given file."),
+	},
+	NoRemote: true,
+	Extra:    CreateCmdExtras(SetDoesNotUseRepo(true)),
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		cctx := env.(*oldcmds.Context)
+		if fsrepo.IsInitialized(cctx.ConfigRoot) {
+			return fmt.Errorf("a repo already exists at %s, restore to an empty path with --repo-dir", cctx.ConfigRoot)
+		}
+
+		it := req.Files.Entries()
+		if !it.Next() {
+			if it.Err() != nil {
+				return it.Err()
+			}
+			return errors.New("missing backup")
+		}
+		file := files.FileFromEntry(it)
+		if file == nil {
+			return errors.New("expected a file")
+		}
+		defer file.Close()
+
+		br := bufio.NewReader(file)
+		var r io.Reader = br
+		passFile, _ := req.Options[repoBackupPassphraseOptionName].(string)
+		if repobackup.IsEncrypted(br) {
+			if passFile == "" {
+				return fmt.Errorf("the backup is encrypted, pass --%s", repoBackupPassphraseOptionName)
+			}
+			passphrase, err := readPassphrase(passFile)
+			if err != nil {
+				return err
+			}
+			if r, err = repobackup.Decrypt(br, passphrase); err != nil {
+				return err
+			}
+		}
+
+		t := &restoreTarget{path: cctx.ConfigRoot}
+		m, err := repobackup.Read(req.Context, r, t)
+		if err == nil && t.repo == nil {
+			err = errors.New("invalid backup: missing config")
+		}
+		if err == nil {
+			err = t.finish(req.Context, m)
+		}
+		if t.repo != nil {
+			if cerr := t.repo.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("restoring the backup: %w", err)
+		}
+
+		fmt.Printf("Restored the repo of peer %s to %s: %d keys, %d pins", m.PeerID, cctx.ConfigRoot, t.keys, len(m.Recursive)+len(m.Direct))
+		if m.Blocks > 0 {
+			fmt.Printf(", %d blocks", m.Blocks)
+		}
+		fmt.Println()
+		return nil
+	},
+}
+
+// restoreTarget restores a backup to a new fsrepo.
+type restoreTarget struct {
+	path string
+	repo repo.Repo
+	keys int
+}
+
+func (t *restoreTarget) Init(cfg *config.Config) error {
+	if t.repo != nil {
+		return errors.New("invalid backup: duplicate config")
+	}
+	if err := fsrepo.Init(t.path, cfg); err != nil {
+		return err
+	}
+	r, err := fsrepo.Open(t.path)
+	if err != nil {
+		return err
+	}
+	t.repo = r
+	return nil
+}
+
+func (t *restoreTarget) PutKey(name string, k crypto.PrivKey) error {
+	if t.repo == nil {
+		return errors.New("invalid backup: keys before the config")
+	}
+	t.keys++
+	return t.repo.Keystore().Put(name, k)
+}
+
+func (t *restoreTarget) PutBlocks(ctx context.Context, r io.Reader) error {
+	if t.repo == nil {
+		return errors.New("invalid backup: blocks before the config")
+	}
+	_, err := gocar.LoadCar(ctx, bstore.NewBlockstore(t.repo.Datastore()), r)
+	return err
+}
+
+// finish restores the pins and the MFS root of the backup, once its blocks
+// are.
+func (t *restoreTarget) finish(ctx context.Context, m *repobackup.Manifest) error {
+	rootDS := t.repo.Datastore()
+	bs := bstore.NewBlockstore(rootDS)
+	pinner, err := dspinner.New(ctx, rootDS, dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))))
+	if err != nil {
+		return err
+	}
+	for _, c := range m.Recursive {
+		pinner.PinWithMode(c, pin.Recursive)
+	}
+	for _, c := range m.Direct {
+		pinner.PinWithMode(c, pin.Direct)
+	}
+	if err := pinner.Flush(ctx); err != nil {
+		return err
+	}
+
+	// the files API starts with an empty root if its block is missing
+	if m.MFSRoot.Defined() {
+		if has, err := bs.Has(ctx, m.MFSRoot); err != nil {
+			return err
+		} else if has {
+			if err := rootDS.Put(ctx, datastore.NewKey("/local/filesroot"), m.MFSRoot.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+	return rootDS.Sync(ctx, datastore.NewKey("/"))
+}
@@ -25,13 +25,15 @@ const (
 	DebugOption      = "debug"
 	LocalOption      = "local" // DEPRECATED: use OfflineOption
 	OfflineOption    = "offline"
-	ApiOption        = "api" //nolint
+	ApiOption        = "api"      //nolint
+	ApiAuthOption    = "api-auth" //nolint
+	MachineOption    = "machine"
 )
 
 var Root = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline:  "Global p2p merkle-dag filesystem.",
-		Synopsis: "ipfs [--config=<config> | -c] [--debug | -D] [--help] [-h] [--api=<api>] [--offline] [--cid-base=<base>] [--upgrade-cidv0-in-output] [--encoding=<encoding> | --enc] [--timeout=<timeout>] <command> ...",
+		Synopsis: "ipfs [--config=<config> | -c] [--debug | -D] [--help] [-h] [--api=<api>] [--offline] [--cid-base=<base>] [--upgrade-cidv0-in-output] [--encoding=<encoding> | --enc] [--machine] [--timeout=<timeout>] <command> ...",
 		Subcommands: `
 BASIC COMMANDS
   init          Initialize local IPFS configuration
@@ -59,6 +61,9 @@ ADVANCED COMMANDS
   pin           Pin objects to local storage
   repo          Manipulate the IPFS repository
   stats         Various operational stats
+  admin         Manage nodes remotely with signed batches of operations
+  cluster-lite  Replicate a shared pinset between trusted peers (experimental)
+  events        Stream internal node events (experimental)
   p2p           Libp2p stream mounting (experimental)
   filestore     Manage the filestore (experimental)
   mount         Mount an IPFS read-only mount point (experimental)
@@ -107,12 +112,14 @@ The CLI will exit with one of the following values:
 		cmds.BoolOption(LocalOption, "L", "Run the command locally, instead of using the daemon. DEPRECATED: use --offline."),
 		cmds.BoolOption(OfflineOption, "Run the command offline."),
 		cmds.StringOption(ApiOption, "Use a specific API instance (defaults to /ip4/127.0.0.1/tcp/5001)"),
+		cmds.StringOption(ApiAuthOption, "Credentials of the RPC API, as in API.Authorizations: 'user:password', 'basic:user:password' or 'bearer:token'."),
 
 		// global options, added to every command
 		cmdenv.OptionCidBase,
 		cmdenv.OptionUpgradeCidV0InOutput,
 
 		cmds.OptionEncodingType,
+		cmds.BoolOption(MachineOption, "Machine-readable output: one JSON value per line, without progress or formatting. See 'ipfs commands schema'."),
 		cmds.OptionStreamChannels,
 		cmds.OptionTimeout,
 	},
@@ -124,6 +131,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"add":       AddCmd,
 	"bitswap":   BitswapCmd,
 	"block":     BlockCmd,
+	"car":       CarCmd,
 	"cat":       CatCmd,
 	"commands":  CommandsDaemonCmd,
 	"files":     FilesCmd,
@@ -132,14 +140,17 @@ var rootSubcommands = map[string]*cmds.Command{
 	"pubsub":    PubsubCmd,
 	"repo":      RepoCmd,
 	"stats":     StatsCmd,
+	"admin":     AdminCmd,
 	"bootstrap": BootstrapCmd,
 	"config":    ConfigCmd,
 	"dag":       dag.DagCmd,
 	"dht":       DhtCmd,
 	"routing":   RoutingCmd,
 	"diag":      DiagCmd,
+	"events":    EventsCmd,
 	"dns":       DNSCmd,
 	"id":        IDCmd,
+	"jobs":      JobsCmd,
 	"key":       KeyCmd,
 	"log":       LogCmd,
 	"ls":        LsCmd,
@@ -151,6 +162,7 @@ var rootSubcommands = map[string]*cmds.Command{
 	"p2p":       P2PCmd,
 	"refs":      RefsCmd,
 	"resolve":   ResolveCmd,
+	"space":     SpaceCmd,
 	"swarm":     SwarmCmd,
 	"tar":       TarCmd,
 	"file":      unixfs.UnixFSCmd,
@@ -160,6 +172,8 @@ var rootSubcommands = map[string]*cmds.Command{
 	"shutdown":  daemonShutdownCmd,
 	"cid":       CidCmd,
 	"multibase": MbaseCmd,
+
+	"cluster-lite": ClusterLiteCmd,
 }
 
 // RootRO is the readonly version of Root
@@ -220,6 +234,15 @@ func init() {
 	// sanitize readonly refs command
 	*RefsROCmd = *RefsCmd
 	RefsROCmd.Subcommands = map[string]*cmds.Command{}
+	// no background jobs from the readonly api
+	RefsROCmd.Options = nil
+	for _, opt := range RefsCmd.Options {
+		switch opt.Name() {
+		case refsPrefetchOptionName, prefetchMaxBytesOptionName, prefetchPriorityOptionName:
+		default:
+			RefsROCmd.Options = append(RefsROCmd.Options, opt)
+		}
+	}
 	rootROSubcommands["refs"] = RefsROCmd
 
 	// sanitize readonly version command (no need to expose precise deps)
@@ -227,6 +250,9 @@ func init() {
 	VersionROCmd.Subcommands = map[string]*cmds.Command{}
 	rootROSubcommands["version"] = VersionROCmd
 
+	// the values completed dynamically are only listed by the full API
+	CommandsDaemonCmd.Subcommands["completion"].Subcommands["candidates"] = completionCandidatesCmd
+
 	Root.Subcommands = rootSubcommands
 	RootRO.Subcommands = rootROSubcommands
 }
@@ -0,0 +1,245 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/spaces"
+)
+
+const (
+	spaceQuotaOptionName = "quota"
+)
+
+// SpaceOutput describes a space.
+type SpaceOutput struct {
+	Name    string
+	Quota   uint64 `json:",omitempty"`
+	Created time.Time
+	Pins    int    `json:",omitempty"`
+	Size    uint64 `json:",omitempty"`
+}
+
+var SpaceCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Manage the named spaces of the node.",
+		ShortDescription: `
+Spaces split a node into isolated projects. Each space has its own pinset,
+MFS root and quota, and shares the blockstore of the repo: a block pinned in
+several spaces is stored once, and kept until no space references it anymore.
+
+Pass --space=<name> to 'ipfs pin add', 'ipfs pin rm', 'ipfs pin ls' and
+'ipfs files' to operate in a space instead of the pinset and the MFS root of
+the node.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"create": spaceCreateCmd,
+		"ls":     spaceLsCmd,
+		"stat":   spaceStatCmd,
+		"quota":  spaceQuotaCmd,
+		"rm":     spaceRmCmd,
+	},
+}
+
+// parseSpaceQuota parses a quota such as "10GB", "none" for no quota.
+func parseSpaceQuota(s string) (uint64, error) {
+	if s == "" || s == "none" {
+		return spaces.NoLimit, nil
+	}
+	q, err := humanize.ParseBytes(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid space quota %q: %w", s, err)
+	}
+	return q, nil
+}
+
+func formatSpaceQuota(q uint64) string {
+	if q == spaces.NoLimit {
+		return "none"
+	}
+	return humanize.Bytes(q)
+}
+
+var spaceCreateCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Create a space.",
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the space."),
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(spaceQuotaOptionName, "Maximum size of the blocks of the space, e.g. 10GB.").WithDefault("none"),
+	},
+	Type: SpaceOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		s, _ := req.Options[spaceQuotaOptionName].(string)
+		quota, err := parseSpaceQuota(s)
+		if err != nil {
+			return err
+		}
+		info, err := n.Spaces.Create(req.Context, req.Arguments[0], quota)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &SpaceOutput{Name: info.Name, Quota: info.Quota, Created: info.Created})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SpaceOutput) error {
+			_, err := fmt.Fprintf(w, "created space %s with quota %s\n", out.Name, formatSpaceQuota(out.Quota))
+			return err
+		}),
+	},
+}
+
+var spaceLsCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the spaces.",
+	},
+	Type: SpaceOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		list, err := n.Spaces.List(req.Context)
+		if err != nil {
+			return err
+		}
+		for _, info := range list {
+			if err := res.Emit(&SpaceOutput{Name: info.Name, Quota: info.Quota, Created: info.Created}); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SpaceOutput) error {
+			_, err := fmt.Fprintf(w, "%s\t%s\n", out.Name, formatSpaceQuota(out.Quota))
+			return err
+		}),
+	},
+}
+
+var spaceStatCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the usage of a space.",
+		ShortDescription: `
+Shows the number of roots pinned in a space, and the size of the local blocks
+reachable from its pins and its MFS root. The blocks shared with other spaces
+count in each of them.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the space."),
+	},
+	Type: SpaceOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		name := req.Arguments[0]
+		info, err := n.Spaces.Get(req.Context, name)
+		if err != nil {
+			return err
+		}
+		pins, err := n.Spaces.Pins(req.Context, name)
+		if err != nil {
+			return err
+		}
+		size, err := n.Spaces.Usage(req.Context, name)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &SpaceOutput{
+			Name:    info.Name,
+			Quota:   info.Quota,
+			Created: info.Created,
+			Pins:    len(pins),
+			Size:    size,
+		})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SpaceOutput) error {
+			tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+			fmt.Fprintf(tw, "Space:\t%s\n", out.Name)
+			fmt.Fprintf(tw, "Created:\t%s\n", out.Created.Format(time.RFC3339))
+			fmt.Fprintf(tw, "Pins:\t%d\n", out.Pins)
+			fmt.Fprintf(tw, "Size:\t%s\n", humanize.Bytes(out.Size))
+			fmt.Fprintf(tw, "Quota:\t%s\n", formatSpaceQuota(out.Quota))
+			return tw.Flush()
+		}),
+	},
+}
+
+var spaceQuotaCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Set the quota of a space.",
+		ShortDescription: `
+Sets the maximum size of the blocks of a space, or 'none'. Pins and MFS
+writes are refused once the space is over quota, the content already in the
+space is kept.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the space."),
+		cmds.StringArg("quota", true, false, "Maximum size of the blocks of the space, e.g. 10GB, or 'none'."),
+	},
+	Type: SpaceOutput{},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		quota, err := parseSpaceQuota(req.Arguments[1])
+		if err != nil {
+			return err
+		}
+		name := req.Arguments[0]
+		if err := n.Spaces.SetQuota(req.Context, name, quota); err != nil {
+			return err
+		}
+		info, err := n.Spaces.Get(req.Context, name)
+		if err != nil {
+			return err
+		}
+		return cmds.EmitOnce(res, &SpaceOutput{Name: info.Name, Quota: info.Quota, Created: info.Created})
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *SpaceOutput) error {
+			_, err := fmt.Fprintf(w, "set the quota of space %s to %s\n", out.Name, formatSpaceQuota(out.Quota))
+			return err
+		}),
+	},
+}
+
+var spaceRmCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Remove a space.",
+		ShortDescription: `
+Removes a space, with its pins and its MFS root. The blocks no other space,
+pin or MFS references are removed by the next 'ipfs repo gc'.
+`,
+	},
+	Arguments: []cmds.Argument{
+		cmds.StringArg("name", true, false, "Name of the space."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		n, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		return n.Spaces.Remove(req.Context, req.Arguments[0])
+	},
+}
@@ -31,6 +31,7 @@ for your IPFS node.`,
 		"bitswap": bitswapStatCmd,
 		"dht":     statDhtCmd,
 		"provide": statProvideCmd,
+		"gateway": statGatewayCmd,
 	},
 }
 
@@ -153,6 +154,9 @@ Example:
 			}
 		}
 	},
+	Subcommands: map[string]*cmds.Command{
+		"history": statBwHistoryCmd,
+	},
 	Type: metrics.Stats{},
 	PostRun: cmds.PostRunMap{
 		cmds.CLI: func(res cmds.Response, re cmds.ResponseEmitter) error {
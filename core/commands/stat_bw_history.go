@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ipfs/kubo/core/bwhistory"
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	metrics "github.com/libp2p/go-libp2p/core/metrics"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+	protocol "github.com/libp2p/go-libp2p/core/protocol"
+)
+
+const statSinceOptionName = "since"
+
+var statBwHistoryCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Print the recent history of IPFS bandwidth.",
+		ShortDescription: `
+'ipfs stats bw history' prints the bandwidth samples recorded by the daemon
+over the last Swarm.BandwidthHistory.Retention (1h by default), one sample
+every Swarm.BandwidthHistory.Interval (10s by default).
+
+Each sample holds the totals, the breakdown by protocol, and the breakdown of
+the busiest peers at the time of the sample. Use the 'peer' or 'proto' options
+to only print the bandwidth of one peer or protocol, and 'since' to only print
+the most recent samples.
+
+Example:
+
+    > ipfs stats bw history --since 1m -t /ipfs/bitswap/1.2.0
+`,
+	},
+	Options: []cmds.Option{
+		cmds.StringOption(statPeerOptionName, "p", "Specify a peer to print bandwidth for."),
+		cmds.StringOption(statProtoOptionName, "t", "Specify a protocol to print bandwidth for."),
+		cmds.StringOption(statSinceOptionName, "s", `Only print the samples of the given duration, such as "5m".`),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+
+		if !nd.IsOnline {
+			return cmds.Errorf(cmds.ErrClient, ErrNotOnline.Error())
+		}
+
+		if nd.BandwidthHistory == nil {
+			return fmt.Errorf("bandwidth history disabled in config")
+		}
+
+		pstr, pfound := req.Options[statPeerOptionName].(string)
+		tstr, tfound := req.Options[statProtoOptionName].(string)
+		if pfound && tfound {
+			return cmds.Errorf(cmds.ErrClient, "please only specify peer OR protocol")
+		}
+		var pid peer.ID
+		if pfound {
+			pid, err = peer.Decode(pstr)
+			if err != nil {
+				return err
+			}
+		}
+
+		var since time.Time
+		if s, ok := req.Options[statSinceOptionName].(string); ok {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return err
+			}
+			since = time.Now().Add(-d)
+		}
+
+		for _, s := range nd.BandwidthHistory.Samples(since) {
+			s := s
+			switch {
+			case pfound:
+				s.Protocols = nil
+				s.Peers = map[peer.ID]metrics.Stats{pid: s.Peers[pid]}
+			case tfound:
+				proto := protocol.ID(tstr)
+				s.Peers = nil
+				s.Protocols = map[protocol.ID]metrics.Stats{proto: s.Protocols[proto]}
+			}
+			if err := res.Emit(&s); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: bwhistory.Sample{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *bwhistory.Sample) error {
+			stats := s.Totals
+			if pstr, ok := req.Options[statPeerOptionName].(string); ok {
+				pid, err := peer.Decode(pstr)
+				if err != nil {
+					return err
+				}
+				stats = s.Peers[pid]
+			} else if tstr, ok := req.Options[statProtoOptionName].(string); ok {
+				stats = s.Protocols[protocol.ID(tstr)]
+			}
+
+			_, err := fmt.Fprintf(w, "%s  TotalIn: %-8s  TotalOut: %-8s  RateIn: %-10s  RateOut: %s\n",
+				s.Time.Format(time.RFC3339),
+				humanize.Bytes(uint64(stats.TotalIn)),
+				humanize.Bytes(uint64(stats.TotalOut)),
+				humanize.Bytes(uint64(stats.RateIn))+"/s",
+				humanize.Bytes(uint64(stats.RateOut))+"/s",
+			)
+			return err
+		}),
+	},
+}
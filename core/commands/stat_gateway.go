@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/core/commands/cmdenv"
+)
+
+// GatewayPathStat is the count of a path in 'ipfs stats gateway'.
+type GatewayPathStat struct {
+	Path     string
+	Requests uint64
+	Bytes    uint64
+	// Error is the maximum overestimation of Requests
+	Error uint64
+}
+
+// GatewayStats is the output of 'ipfs stats gateway'.
+type GatewayStats struct {
+	Since time.Time
+	Paths []GatewayPathStat
+}
+
+const (
+	statGatewayTopOptionName   = "top"
+	statGatewaySortOptionName  = "sort"
+	statGatewayResetOptionName = "reset"
+)
+
+var statGatewayCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "Show the most requested gateway paths.",
+		ShortDescription: `
+'ipfs stats gateway' lists the content paths most requested from the gateway,
+e.g. /ipfs/{cid}/index.html, with the number of successful GET requests and
+the bytes served, to help deciding what to pin or push to a CDN.
+
+Only the top Gateway.PopularityTopN paths are counted: a new path replaces the
+least requested one and inherits its count, so the counts are overestimated
+by at most the Error column. A path requested more than 1/PopularityTopN of
+the time is always listed.
+
+This interface is not stable and may change from release to release.
+`,
+	},
+	Options: []cmds.Option{
+		cmds.IntOption(statGatewayTopOptionName, "n", "Number of paths to list.").WithDefault(20),
+		cmds.StringOption(statGatewaySortOptionName, "Sort by 'requests' or 'bytes'.").WithDefault("requests"),
+		cmds.BoolOption(statGatewayResetOptionName, "Reset the counts after listing them."),
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if nd.GatewayPopularity == nil {
+			return errors.New("gateway popularity counting is disabled by Gateway.PopularityTopN")
+		}
+
+		top, _ := req.Options[statGatewayTopOptionName].(int)
+		sortBy, _ := req.Options[statGatewaySortOptionName].(string)
+		if sortBy != "requests" && sortBy != "bytes" {
+			return fmt.Errorf("unknown sort %q, expected 'requests' or 'bytes'", sortBy)
+		}
+
+		out := GatewayStats{Since: nd.GatewayPopularity.Since(), Paths: []GatewayPathStat{}}
+		for _, e := range nd.GatewayPopularity.Top(top, sortBy == "bytes") {
+			out.Paths = append(out.Paths, GatewayPathStat{
+				Path:     e.Key,
+				Requests: e.Requests,
+				Bytes:    e.Bytes,
+				Error:    e.Error,
+			})
+		}
+		if reset, _ := req.Options[statGatewayResetOptionName].(bool); reset {
+			nd.GatewayPopularity.Reset()
+		}
+		return cmds.EmitOnce(res, &out)
+	},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *GatewayStats) error {
+			fmt.Fprintf(w, "Since %s\n", out.Since.Format(time.RFC3339))
+			tw := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
+			defer tw.Flush()
+			fmt.Fprintln(tw, "Requests\tError\tBytes\tPath")
+			for _, p := range out.Paths {
+				fmt.Fprintf(tw, "%d\t%d\t%s\t%s\n", p.Requests, p.Error, humanize.Bytes(p.Bytes), cmdenv.EscNonPrint(p.Path))
+			}
+			return nil
+		}),
+	},
+	Type: GatewayStats{},
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"text/tabwriter"
@@ -8,22 +9,70 @@ import (
 
 	humanize "github.com/dustin/go-humanize"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/providestats"
+	"github.com/multiformats/go-multihash"
 
 	"github.com/ipfs/go-ipfs-provider/batched"
 )
 
+const (
+	provideRootsOptionName   = "roots"
+	provideOverdueOptionName = "overdue"
+)
+
+// provideStats are the statistics of the (re)provider system.
+type provideStats struct {
+	*batched.BatchedProviderStats
+
+	Strategy          string
+	ReprovideInterval string
+	LastReprovide     *providestats.Reprovide `json:",omitempty"`
+	Roots             []provideRootStats      `json:",omitempty"`
+}
+
+// provideRootStats are the statistics of the announcement of a pinned root.
+type provideRootStats struct {
+	Cid           string
+	Type          string
+	Status        string
+	LastAnnounced *providestats.Announce `json:",omitempty"`
+}
+
 var statProvideCmd = &cmds.Command{
 	Helptext: cmds.HelpText{
 		Tagline: "Returns statistics about the node's (re)provider system.",
 		ShortDescription: `
 Returns statistics about the content the node is advertising.
 
+This interface is not stable and may change from release to release.
+`,
+		LongDescription: `
+Returns statistics about the content the node is advertising: the reprovider
+strategy, the last run of the reprovider and, with
+Experimental.AcceleratedDHTClient, the statistics of the batched provider.
+
+With --roots, lists for each pinned root when it was last announced since the
+daemon started, how long the announcement took, and its status:
+
+  announced  announced within the reprovide interval
+  failed     the last announcement failed
+  overdue    not announced within the reprovide interval, plus the duration
+             of the last reprovide
+  pending    not announced yet since the daemon started
+
+With --overdue, only the roots failed or overdue are listed.
+
 This interface is not stable and may change from release to release.
 `,
 	},
 	Arguments: []cmds.Argument{},
-	Options:   []cmds.Option{},
+	Options: []cmds.Option{
+		cmds.BoolOption(provideRootsOptionName, "List when each pinned root was last announced."),
+		cmds.BoolOption(provideOverdueOptionName, "Only list the pinned roots failed or overdue. Implies --roots."),
+	},
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		nd, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -34,35 +83,113 @@ This interface is not stable and may change from release to release.
 			return ErrNotOnline
 		}
 
-		sys, ok := nd.Provider.(*batched.BatchProvidingSystem)
-		if !ok {
-			return fmt.Errorf("can only return stats if Experimental.AcceleratedDHTClient is enabled")
+		sys, batchedOk := nd.Provider.(*batched.BatchProvidingSystem)
+		if !batchedOk && nd.ProvideStats == nil {
+			return fmt.Errorf("no statistics of the provider system with Experimental.StrategicProviding")
 		}
-
-		stats, err := sys.Stat(req.Context)
+		cfg, err := nd.Repo.Config()
 		if err != nil {
 			return err
 		}
 
-		if err := res.Emit(stats); err != nil {
-			return err
+		out := &provideStats{
+			Strategy: cfg.Reprovider.Strategy.WithDefault(config.DefaultReproviderStrategy),
+		}
+		if batchedOk {
+			if out.BatchedProviderStats, err = sys.Stat(req.Context); err != nil {
+				return err
+			}
+		}
+		if nd.ProvideStats != nil {
+			out.ReprovideInterval = nd.ProvideStats.Interval().String()
+			out.LastReprovide = nd.ProvideStats.LastReprovide()
+		}
+
+		overdue, _ := req.Options[provideOverdueOptionName].(bool)
+		roots, _ := req.Options[provideRootsOptionName].(bool)
+		if (roots || overdue) && nd.ProvideStats != nil {
+			if out.Roots, err = provideRoots(req.Context, nd, overdue); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		return res.Emit(out)
 	},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *batched.BatchedProviderStats) error {
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, s *provideStats) error {
 			wtr := tabwriter.NewWriter(w, 1, 2, 1, ' ', 0)
 			defer wtr.Flush()
 
-			fmt.Fprintf(wtr, "TotalProvides:\t%s\n", humanNumber(s.TotalProvides))
-			fmt.Fprintf(wtr, "AvgProvideDuration:\t%s\n", humanDuration(s.AvgProvideDuration))
-			fmt.Fprintf(wtr, "LastReprovideDuration:\t%s\n", humanDuration(s.LastReprovideDuration))
-			fmt.Fprintf(wtr, "LastReprovideBatchSize:\t%s\n", humanNumber(s.LastReprovideBatchSize))
+			if s.BatchedProviderStats != nil {
+				fmt.Fprintf(wtr, "TotalProvides:\t%s\n", humanNumber(s.TotalProvides))
+				fmt.Fprintf(wtr, "AvgProvideDuration:\t%s\n", humanDuration(s.AvgProvideDuration))
+				fmt.Fprintf(wtr, "LastReprovideDuration:\t%s\n", humanDuration(s.LastReprovideDuration))
+				fmt.Fprintf(wtr, "LastReprovideBatchSize:\t%s\n", humanNumber(s.LastReprovideBatchSize))
+			}
+			fmt.Fprintf(wtr, "Strategy:\t%s\n", s.Strategy)
+			if s.ReprovideInterval != "" {
+				fmt.Fprintf(wtr, "ReprovideInterval:\t%s\n", s.ReprovideInterval)
+			}
+			if r := s.LastReprovide; r != nil {
+				fmt.Fprintf(wtr, "LastReprovide:\t%s (%s, %s keys)\n", r.Start.Format(time.RFC3339), humanDuration(r.Duration), humanFull(float64(r.Keys), 0))
+				if r.Error != "" {
+					fmt.Fprintf(wtr, "LastReprovideError:\t%s\n", r.Error)
+				}
+			}
+
+			if len(s.Roots) == 0 {
+				return nil
+			}
+			fmt.Fprintln(wtr)
+			fmt.Fprintln(wtr, "ROOT\tTYPE\tSTATUS\tLAST ANNOUNCED\tDURATION\t")
+			for _, r := range s.Roots {
+				last, duration := "-", "-"
+				if a := r.LastAnnounced; a != nil {
+					last = a.Time.Format(time.RFC3339)
+					duration = humanDuration(a.Duration)
+				}
+				fmt.Fprintf(wtr, "%s\t%s\t%s\t%s\t%s\t\n", r.Cid, r.Type, r.Status, last, duration)
+			}
 			return nil
 		}),
 	},
-	Type: batched.BatchedProviderStats{},
+	Type: provideStats{},
+}
+
+// provideRoots returns the statistics of the pinned roots, only the failed
+// and overdue ones with onlyOverdue.
+func provideRoots(ctx context.Context, nd *core.IpfsNode, onlyOverdue bool) ([]provideRootStats, error) {
+	recursive, err := nd.Pinning.RecursiveKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	direct, err := nd.Pinning.DirectKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]provideRootStats, 0, len(recursive)+len(direct))
+	keys := make([]multihash.Multihash, 0, len(recursive)+len(direct))
+	for _, c := range recursive {
+		pins = append(pins, provideRootStats{Cid: c.String(), Type: "recursive"})
+		keys = append(keys, c.Hash())
+	}
+	for _, c := range direct {
+		pins = append(pins, provideRootStats{Cid: c.String(), Type: "direct"})
+		keys = append(keys, c.Hash())
+	}
+	nd.ProvideStats.Watch(keys)
+
+	now := time.Now()
+	roots := pins[:0]
+	for i, r := range pins {
+		r.LastAnnounced, r.Status = nd.ProvideStats.Root(keys[i], now)
+		if onlyOverdue && r.Status != providestats.StatusFailed && r.Status != providestats.StatusOverdue {
+			continue
+		}
+		roots = append(roots, r)
+	}
+	return roots, nil
 }
 
 func humanDuration(val time.Duration) string {
@@ -16,6 +16,7 @@ import (
 	"github.com/ipfs/kubo/commands"
 	"github.com/ipfs/kubo/config"
 	"github.com/ipfs/kubo/core/commands/cmdenv"
+	"github.com/ipfs/kubo/core/dialstats"
 	"github.com/ipfs/kubo/core/node/libp2p"
 	"github.com/ipfs/kubo/repo"
 	"github.com/ipfs/kubo/repo/fsrepo"
@@ -57,6 +58,7 @@ ipfs peers in the internet.
 		"filters":    swarmFiltersCmd,
 		"peers":      swarmPeersCmd,
 		"peering":    swarmPeeringCmd,
+		"pnet":       swarmPnetCmd,
 		"stats":      swarmStatsCmd, // libp2p Network Resource Manager
 		"limit":      swarmLimitCmd, // libp2p Network Resource Manager
 	},
@@ -71,6 +73,8 @@ const (
 	swarmUsedResourcesPercentageName = "min-used-limit-perc"
 )
 
+const swarmPreferDirectOptionName = "prefer-direct"
+
 type peeringResult struct {
 	ID     peer.ID
 	Status string
@@ -326,8 +330,8 @@ var swarmPeersCmd = &cmds.Command{
 var swarmStatsCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
-		Tagline: "Report resource usage for a scope.",
-		LongDescription: `Report resource usage for a scope.
+		Tagline: "Report resource usage for a scope, or the dial statistics.",
+		LongDescription: `Report resource usage for a scope, or the dial statistics.
 The scope can be one of the following:
 - system        -- reports the system aggregate resource usage.
 - transient     -- reports the transient resource usage.
@@ -335,11 +339,20 @@ The scope can be one of the following:
 - proto:<proto> -- reports the resource usage of a specific protocol.
 - peer:<peer>   -- reports the resource usage of a specific peer.
 - all           -- reports the resource usage for all currently active scopes.
+- dials         -- reports the outcomes and the latencies of the dials, by
+                   transport and address family.
 
 The output of this command is JSON.
 
 To see all resources that are close to hitting their respective limit, one can do something like:
   ipfs swarm stats --min-used-limit-perc=90 all
+
+The dials report counts, since the start of the daemon, the successes and
+the failures of the dials of each transport, and the successful dials by
+latency: Latency[i] dials took at most Buckets[i] seconds, and the last
+element counts the slower ones. The dials canceled once another address of
+the peer connected are counted apart. A transport failing over an address
+family, e.g. QUIC over IPv4 while TCP works, is likely blocked by the network.
 `},
 	Arguments: []cmds.Argument{
 		cmds.StringArg("scope", true, false, "scope of the stat report"),
@@ -353,10 +366,6 @@ To see all resources that are close to hitting their respective limit, one can d
 			return err
 		}
 
-		if node.ResourceManager == nil {
-			return libp2p.ErrNoResourceMgr
-		}
-
 		if len(req.Arguments) != 1 {
 			return fmt.Errorf("must specify exactly one scope")
 		}
@@ -364,13 +373,24 @@ To see all resources that are close to hitting their respective limit, one can d
 		percentage, _ := req.Options[swarmUsedResourcesPercentageName].(int)
 		scope := req.Arguments[0]
 
-		if percentage != 0 && scope != "all" {
-			return fmt.Errorf("%q can only be used when scope is %q", swarmUsedResourcesPercentageName, "all")
-		}
+		var result interface{}
+		if scope == "dials" {
+			if node.DialStats == nil {
+				return ErrNotOnline
+			}
+			result = &SwarmDialStats{Buckets: dialstats.Buckets, Transports: node.DialStats.Snapshot()}
+		} else {
+			if node.ResourceManager == nil {
+				return libp2p.ErrNoResourceMgr
+			}
 
-		result, err := libp2p.NetStat(node.ResourceManager, scope, percentage)
-		if err != nil {
-			return err
+			if percentage != 0 && scope != "all" {
+				return fmt.Errorf("%q can only be used when scope is %q", swarmUsedResourcesPercentageName, "all")
+			}
+
+			if result, err = libp2p.NetStat(node.ResourceManager, scope, percentage); err != nil {
+				return err
+			}
 		}
 
 		b := new(bytes.Buffer)
@@ -386,6 +406,13 @@ To see all resources that are close to hitting their respective limit, one can d
 	},
 }
 
+// SwarmDialStats is the dials report of 'ipfs swarm stats dials'.
+type SwarmDialStats struct {
+	// Buckets are the upper bounds, in seconds, of the latency histograms
+	Buckets    []float64
+	Transports []dialstats.TransportStats
+}
+
 var swarmLimitCmd = &cmds.Command{
 	Status: cmds.Experimental,
 	Helptext: cmds.HelpText{
@@ -672,11 +699,35 @@ var swarmConnectCmd = &cmds.Command{
 The address format is an IPFS multiaddr:
 
 ipfs swarm connect /ip4/104.131.131.82/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ
+
+When the only connection to a peer goes through a relay, the --prefer-direct
+option tries to upgrade it to a direct connection, dialing the peer directly
+and hole punching (DCUtR) if that fails. This requires
+Swarm.EnableHolePunching.
+
+The --input option connects to the addresses listed in a file, one per line,
+ignoring empty lines and lines starting with '#'. Up to --parallel addresses
+are dialed at once, and the outcome of each dial is printed as a JSON object
+on its own line as soon as it is known, followed by a summary:
+
+  > ipfs swarm connect --input peers.txt
+  {"Address":"/ip4/...","Peer":"12D3Koo...","Success":true,"Latency":51234567}
+  ...
+  {"Total":200,"Succeeded":187,"Failed":13,"Duration":4123456789}
+
+Latencies and durations are in nanoseconds. Failed dials don't fail the
+command.
 `,
 	},
 	Arguments: []cmds.Argument{
-		cmds.StringArg("address", true, true, "Address of peer to connect to.").EnableStdin(),
+		cmds.StringArg("address", false, true, "Address of peer to connect to.").EnableStdin(),
+	},
+	Options: []cmds.Option{
+		cmds.BoolOption(swarmPreferDirectOptionName, "Upgrade relayed connections to direct ones with hole punching."),
+		cmds.StringOption(swarmInputOptionName, "Connect to the addresses listed in the given file."),
+		cmds.IntOption(swarmParallelOptionName, "Maximum number of concurrent dials with --input.").WithDefault(defaultConnectParallel),
 	},
+	PreRun: swarmConnectPreRun,
 	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
 		node, err := cmdenv.GetNode(env)
 		if err != nil {
@@ -689,12 +740,25 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N
 		}
 
 		addrs := req.Arguments
+		if len(addrs) == 0 {
+			return fmt.Errorf("argument \"address\" is required")
+		}
+
+		if _, ok := req.Options[swarmInputOptionName].(string); ok {
+			parallel, _ := req.Options[swarmParallelOptionName].(int)
+			return connectAll(req.Context, res, api, node.DNSResolver, addrs, parallel)
+		}
 
 		pis, err := parseAddresses(req.Context, addrs, node.DNSResolver)
 		if err != nil {
 			return err
 		}
 
+		preferDirect, _ := req.Options[swarmPreferDirectOptionName].(bool)
+		if preferDirect && node.HolePunching == nil {
+			return fmt.Errorf("hole punching disabled in config")
+		}
+
 		output := make([]string, len(pis))
 		for i, pi := range pis {
 			output[i] = "connect " + pi.ID.Pretty()
@@ -704,14 +768,30 @@ ipfs swarm connect /ip4/104.131.131.82/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N
 				return fmt.Errorf("%s failure: %s", output[i], err)
 			}
 			output[i] += " success"
+			if preferDirect && onlyRelayed(node.PeerHost.Network().ConnsToPeer(pi.ID)) {
+				if err := node.HolePunching.DirectConnect(req.Context, pi.ID); err != nil {
+					return fmt.Errorf("%s, upgrade to direct connection failure: %s", output[i], err)
+				}
+				output[i] += ", upgraded to direct connection"
+			}
 		}
 
-		return cmds.EmitOnce(res, &stringList{output})
+		return cmds.EmitOnce(res, &swarmConnectOutput{Strings: output})
 	},
 	Encoders: cmds.EncoderMap{
-		cmds.Text: cmds.MakeTypedEncoder(safeTextListEncoder),
+		cmds.Text: cmds.MakeTypedEncoder(swarmConnectEncoder),
 	},
-	Type: stringList{},
+	Type: swarmConnectOutput{},
+}
+
+// onlyRelayed returns true if all conns, if any, go through a relay.
+func onlyRelayed(conns []inet.Conn) bool {
+	for _, c := range conns {
+		if _, err := c.RemoteMultiaddr().ValueForProtocol(ma.P_CIRCUIT); err != nil {
+			return false
+		}
+	}
+	return len(conns) > 0
 }
 
 var swarmDisconnectCmd = &cmds.Command{
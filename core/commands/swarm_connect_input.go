@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+const (
+	swarmInputOptionName    = "input"
+	swarmParallelOptionName = "parallel"
+
+	defaultConnectParallel = 32
+)
+
+// swarmConnectOutput is the output of 'ipfs swarm connect'. Strings is
+// emitted once when connecting to the addresses given as arguments, Result
+// and Summary are emitted when connecting to the addresses of an --input
+// file.
+type swarmConnectOutput struct {
+	Strings []string        `json:",omitempty"`
+	Result  *connectResult  `json:",omitempty"`
+	Summary *connectSummary `json:",omitempty"`
+}
+
+// connectResult is the outcome of dialing one address.
+type connectResult struct {
+	Address string
+	Peer    string `json:",omitempty"`
+	Success bool
+	Latency time.Duration
+	Error   string `json:",omitempty"`
+}
+
+// connectSummary sums up the dials of an --input file.
+type connectSummary struct {
+	Total     int
+	Succeeded int
+	Failed    int
+	Duration  time.Duration
+}
+
+// readAddressesFile returns the addresses in the file at path, one per line.
+// Empty lines and lines starting with # are ignored.
+func readAddressesFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var addrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+	return addrs, scanner.Err()
+}
+
+// connectAll dials addrs with up to parallel concurrent dials, emitting the
+// result of each dial as it completes, then the summary.
+func connectAll(ctx context.Context, res cmds.ResponseEmitter, api coreiface.CoreAPI, rslv *madns.Resolver, addrs []string, parallel int) error {
+	if parallel < 1 {
+		return fmt.Errorf("--%s must be positive", swarmParallelOptionName)
+	}
+
+	start := time.Now()
+	results := make(chan *connectResult)
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	go func() {
+		defer close(results)
+		for _, addr := range addrs {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				wg.Wait()
+				return
+			}
+			wg.Add(1)
+			go func(addr string) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				results <- connectOne(ctx, api, rslv, addr)
+			}(addr)
+		}
+		wg.Wait()
+	}()
+
+	var summary connectSummary
+	for r := range results {
+		summary.Total++
+		if r.Success {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		if err := res.Emit(&swarmConnectOutput{Result: r}); err != nil {
+			// drain, so that the dialers can exit
+			go func() {
+				for range results {
+				}
+			}()
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	summary.Duration = time.Since(start)
+	return res.Emit(&swarmConnectOutput{Summary: &summary})
+}
+
+func connectOne(ctx context.Context, api coreiface.CoreAPI, rslv *madns.Resolver, addr string) *connectResult {
+	r := &connectResult{Address: addr}
+	start := time.Now()
+	pis, err := parseAddresses(ctx, []string{addr}, rslv)
+	if err == nil && len(pis) != 1 {
+		err = fmt.Errorf("address resolves to %d peers", len(pis))
+	}
+	if err == nil {
+		r.Peer = pis[0].ID.String()
+		err = api.Swarm().Connect(ctx, pis[0])
+	}
+	r.Latency = time.Since(start)
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Success = true
+	}
+	return r
+}
+
+func swarmConnectPreRun(req *cmds.Request, env cmds.Environment) error {
+	path, ok := req.Options[swarmInputOptionName].(string)
+	if !ok {
+		return nil
+	}
+	// the file is read on the client side, the daemon may run elsewhere
+	addrs, err := readAddressesFile(path)
+	if err != nil {
+		return err
+	}
+	req.Arguments = append(req.Arguments, addrs...)
+	return nil
+}
+
+func swarmConnectEncoder(req *cmds.Request, w io.Writer, out *swarmConnectOutput) error {
+	switch {
+	case out.Result != nil:
+		return json.NewEncoder(w).Encode(out.Result)
+	case out.Summary != nil:
+		return json.NewEncoder(w).Encode(out.Summary)
+	default:
+		return safeTextListEncoder(req, w, &stringList{out.Strings})
+	}
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	cmdenv "github.com/ipfs/kubo/core/commands/cmdenv"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+)
+
+// PNetKey is a key of the private network.
+type PNetKey struct {
+	Fingerprint string
+	Active      bool
+}
+
+// PNetConn is a connection and the key used by the remote peer.
+type PNetConn struct {
+	Peer string
+	Addr string
+	// Key is the fingerprint of the key, empty if not known yet
+	Key string `json:",omitempty"`
+}
+
+// PNetOutput is the output of 'ipfs swarm pnet'.
+type PNetOutput struct {
+	Keys  []PNetKey
+	Conns []PNetConn
+}
+
+var swarmPnetCmd = &cmds.Command{
+	Helptext: cmds.HelpText{
+		Tagline: "List the private network keys and the key used by each connection.",
+		ShortDescription: `
+'ipfs swarm pnet' lists the fingerprints of the keys of the private network
+configured in the swarm.key file of the repo, and the key used by the remote
+peer of each connection.
+
+The swarm.key file may hold several keys, one after the other. The first key
+is active: it protects the connections opened by the node. The other keys are
+accepted: connections protected by any of the keys are accepted. This allows
+rotating the key of a fleet without a flag day:
+
+  1. add the new key after the old one on every node, and restart them
+  2. move the new key first on every node, and restart them
+  3. once no connection uses the old key anymore, remove it
+
+Several keys are not supported by the QUIC, WebTransport and Relay transports.
+`,
+	},
+	Run: func(req *cmds.Request, res cmds.ResponseEmitter, env cmds.Environment) error {
+		nd, err := cmdenv.GetNode(env)
+		if err != nil {
+			return err
+		}
+		if !nd.IsOnline {
+			return ErrNotOnline
+		}
+		if nd.PNetKeys == nil {
+			return fmt.Errorf("not in a private network")
+		}
+
+		var out PNetOutput
+		for i, fp := range nd.PNetKeys.Fingerprints() {
+			out.Keys = append(out.Keys, PNetKey{Fingerprint: hex.EncodeToString(fp), Active: i == 0})
+		}
+		for _, c := range nd.PeerHost.Network().Conns() {
+			pc := PNetConn{
+				Peer: c.RemotePeer().String(),
+				Addr: c.RemoteMultiaddr().String(),
+			}
+			if fp, ok := nd.PNetKeys.ConnKey(c); ok {
+				pc.Key = hex.EncodeToString(fp)
+			}
+			out.Conns = append(out.Conns, pc)
+		}
+		sort.Slice(out.Conns, func(i, j int) bool {
+			return out.Conns[i].Peer < out.Conns[j].Peer
+		})
+		return cmds.EmitOnce(res, &out)
+	},
+	Type: PNetOutput{},
+	Encoders: cmds.EncoderMap{
+		cmds.Text: cmds.MakeTypedEncoder(func(req *cmds.Request, w io.Writer, out *PNetOutput) error {
+			for _, k := range out.Keys {
+				state := "accepted"
+				if k.Active {
+					state = "active"
+				}
+				fmt.Fprintf(w, "key %s %s\n", k.Fingerprint, state)
+			}
+			for _, c := range out.Conns {
+				key := c.Key
+				if key == "" {
+					key = "unknown"
+				}
+				fmt.Fprintf(w, "%s/p2p/%s %s\n", c.Addr, c.Peer, key)
+			}
+			return nil
+		}),
+	},
+}
@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	inet "github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/test"
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+)
+
+type addrConn struct {
+	inet.Conn
+	addr ma.Multiaddr
+}
+
+func (c *addrConn) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+
+func TestOnlyRelayed(t *testing.T) {
+	relayed := &addrConn{addr: ma.StringCast("/ip4/1.2.3.4/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit")}
+	direct := &addrConn{addr: ma.StringCast("/ip4/5.6.7.8/udp/4001/quic-v1")}
+
+	for _, tc := range []struct {
+		conns []inet.Conn
+		want  bool
+	}{
+		{nil, false},
+		{[]inet.Conn{relayed}, true},
+		{[]inet.Conn{relayed, relayed}, true},
+		{[]inet.Conn{relayed, direct}, false},
+		{[]inet.Conn{direct}, false},
+	} {
+		if got := onlyRelayed(tc.conns); got != tc.want {
+			t.Errorf("onlyRelayed(%v) = %t, want %t", tc.conns, got, tc.want)
+		}
+	}
+}
+
+// connectSwarm fails the dials to the peer fail, and records the highest number of
+// concurrent dials.
+type connectSwarm struct {
+	coreiface.SwarmAPI
+	fail peer.ID
+
+	mu        sync.Mutex
+	dialing   int
+	maxDialed int
+}
+
+func (s *connectSwarm) Connect(ctx context.Context, pi peer.AddrInfo) error {
+	s.mu.Lock()
+	s.dialing++
+	if s.dialing > s.maxDialed {
+		s.maxDialed = s.dialing
+	}
+	s.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	s.mu.Lock()
+	s.dialing--
+	s.mu.Unlock()
+	if pi.ID == s.fail {
+		return errors.New("dial refused")
+	}
+	return nil
+}
+
+type connectAPI struct {
+	coreiface.CoreAPI
+	swarm *connectSwarm
+}
+
+func (api connectAPI) Swarm() coreiface.SwarmAPI { return api.swarm }
+
+func TestReadAddressesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers.txt")
+	content := "# bootstrap peers\n\n/ip4/1.2.3.4/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ\n  /dnsaddr/bootstrap.libp2p.io  \n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := readAddressesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/ip4/1.2.3.4/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ",
+		"/dnsaddr/bootstrap.libp2p.io",
+	}
+	if !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("expected %v, got %v", want, addrs)
+	}
+	if _, err := readAddressesFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Fatal("expected an error reading a missing file")
+	}
+}
+
+func TestConnectAll(t *testing.T) {
+	ok := test.RandPeerIDFatal(t)
+	failing := test.RandPeerIDFatal(t)
+	var addrs []string
+	for i := 0; i < 6; i++ {
+		addrs = append(addrs, fmt.Sprintf("/ip4/1.2.3.%d/tcp/4001/p2p/%s", i, ok))
+	}
+	addrs = append(addrs, "/ip4/5.6.7.8/tcp/4001/p2p/"+failing.String(), "not a multiaddr")
+
+	swarm := &connectSwarm{fail: failing}
+	api := connectAPI{swarm: swarm}
+	req := &cmds.Request{Context: context.Background()}
+	re, res := cmds.NewChanResponsePair(req)
+	go func() {
+		re.CloseWithError(connectAll(req.Context, re, api, madns.DefaultResolver, addrs, 2))
+	}()
+
+	results := make(map[string]*connectResult)
+	var summary *connectSummary
+	for {
+		v, err := res.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := v.(*swarmConnectOutput)
+		switch {
+		case out.Result != nil:
+			if summary != nil {
+				t.Fatal("result emitted after the summary")
+			}
+			results[out.Result.Address] = out.Result
+		case out.Summary != nil:
+			summary = out.Summary
+		default:
+			t.Fatalf("unexpected output %#v", out)
+		}
+	}
+
+	if len(results) != len(addrs) {
+		t.Fatalf("expected %d results, got %d", len(addrs), len(results))
+	}
+	for _, addr := range addrs[:6] {
+		if r := results[addr]; !r.Success || r.Peer != ok.String() || r.Error != "" {
+			t.Errorf("expected dialing %s to succeed, got %#v", addr, r)
+		}
+	}
+	if r := results[addrs[6]]; r.Success || r.Peer != failing.String() || r.Error != "dial refused" {
+		t.Errorf("expected dialing %s to fail, got %#v", addrs[6], r)
+	}
+	if r := results[addrs[7]]; r.Success || r.Peer != "" || r.Error == "" {
+		t.Errorf("expected parsing %s to fail, got %#v", addrs[7], r)
+	}
+	if summary == nil || summary.Total != 8 || summary.Succeeded != 6 || summary.Failed != 2 {
+		t.Fatalf("unexpected summary %#v", summary)
+	}
+	if swarm.maxDialed > 2 {
+		t.Fatalf("expected at most 2 concurrent dials, got %d", swarm.maxDialed)
+	}
+
+	if err := connectAll(req.Context, re, api, madns.DefaultResolver, addrs, 0); err == nil {
+		t.Fatal("expected an error with no parallel dials")
+	}
+}
@@ -45,14 +45,33 @@ import (
 
 	"github.com/ipfs/go-namesys"
 	ipnsrp "github.com/ipfs/go-namesys/republisher"
+	"github.com/ipfs/kubo/clusterlite"
+	"github.com/ipfs/kubo/core/autonatv2"
 	"github.com/ipfs/kubo/core/bootstrap"
+	"github.com/ipfs/kubo/core/bsbroadcast"
+	"github.com/ipfs/kubo/core/bwhistory"
+	"github.com/ipfs/kubo/core/dialstats"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/haveprobe"
+	"github.com/ipfs/kubo/core/ipni"
+	"github.com/ipfs/kubo/core/jobs"
+	"github.com/ipfs/kubo/core/logstats"
+	"github.com/ipfs/kubo/core/mfsflush"
 	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/core/pinlabels"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/popularity"
+	"github.com/ipfs/kubo/core/prefetch"
+	"github.com/ipfs/kubo/core/providestats"
+	"github.com/ipfs/kubo/core/quota"
+	"github.com/ipfs/kubo/core/spaces"
 	"github.com/ipfs/kubo/fuse/mount"
 	"github.com/ipfs/kubo/p2p"
 	"github.com/ipfs/kubo/peering"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
+	"github.com/ipfs/kubo/shaping"
 )
 
 var log = logging.Logger("core")
@@ -70,6 +89,7 @@ type IpfsNode struct {
 	Mounts          Mounts                 `optional:"true"` // current mount state, if any.
 	PrivateKey      ic.PrivKey             `optional:"true"` // the local node's private Key
 	PNetFingerprint libp2p.PNetFingerprint `optional:"true"` // fingerprint of private network
+	PNetKeys        *libp2p.PNetKeys       `optional:"true"` // keys of private network
 
 	// Services
 	Peerstore            pstore.Peerstore          `optional:"true"` // storage for other Peer instances
@@ -82,23 +102,43 @@ type IpfsNode struct {
 	IPLDFetcherFactory   fetcher.Factory           `name:"ipldFetcher"`   // fetcher that paths over the IPLD data model
 	UnixFSFetcherFactory fetcher.Factory           `name:"unixfsFetcher"` // fetcher that interprets UnixFS data
 	Reporter             *metrics.BandwidthCounter `optional:"true"`
+	BandwidthHistory     *bwhistory.History        `optional:"true"`
+	DialStats            *dialstats.Stats          `optional:"true"` // outcomes of the dials, by transport
+	LogStats             *logstats.Stats           `optional:"true"` // warnings and errors logged, by subsystem
+	AutoNATV2            *autonatv2.Client         `optional:"true"` // reachability of the addresses, with AutoNAT v2
 	Discovery            mdns.Service              `optional:"true"`
 	FilesRoot            *mfs.Root
+	MFSFlusher           *mfsflush.Flusher `optional:"true"` // batched flushes of FilesRoot
 	RecordValidator      record.Validator
+	Events               *events.Bus         // internal event stream
+	Quotas               *quota.Accountant   // per namespace repo quotas
+	Prefetch             *prefetch.Manager   // background jobs warming the blockstore
+	PinQueue             *pinqueue.Queue     // background pin jobs
+	Jobs                 *jobs.Manager       // background RPC operations
+	PinLabels            *pinlabels.Store    // labels of the pinned roots
+	PrivateScope         *pinlabels.Scope    `optional:"true"` // content only provided on the LAN DHT
+	Spaces               *spaces.Manager     // named spaces with their own pinset, MFS root and quota
+	GatewayPopularity    *popularity.Tracker `optional:"true"` // most requested gateway paths
 
 	// Online
-	PeerHost        p2phost.Host               `optional:"true"` // the network host (server+client)
-	Peering         *peering.PeeringService    `optional:"true"`
-	Filters         *ma.Filters                `optional:"true"`
-	Bootstrapper    io.Closer                  `optional:"true"` // the periodic bootstrapper
-	Routing         irouting.ProvideManyRouter `optional:"true"` // the routing system. recommend ipfs-dht
-	DNSResolver     *madns.Resolver            // the DNS resolver
-	Exchange        exchange.Interface         // the block exchange + strategy (bitswap)
-	Namesys         namesys.NameSystem         // the name system, resolves paths to hashes
-	Provider        provider.System            // the value provider system
-	IpnsRepub       *ipnsrp.Republisher        `optional:"true"`
-	GraphExchange   graphsync.GraphExchange    `optional:"true"`
-	ResourceManager network.ResourceManager    `optional:"true"`
+	PeerHost         p2phost.Host               `optional:"true"` // the network host (server+client)
+	Peering          *peering.PeeringService    `optional:"true"`
+	Shaper           *shaping.Shaper            `optional:"true"` // traffic shaping of the streams, for testing
+	Filters          *ma.Filters                `optional:"true"`
+	Bootstrapper     io.Closer                  `optional:"true"` // the periodic bootstrapper
+	Routing          irouting.ProvideManyRouter `optional:"true"` // the routing system. recommend ipfs-dht
+	DNSResolver      *madns.Resolver            // the DNS resolver
+	Exchange         exchange.Interface         // the block exchange + strategy (bitswap)
+	Namesys          namesys.NameSystem         // the name system, resolves paths to hashes
+	Provider         provider.System            // the value provider system
+	ProvideStats     *providestats.Tracker      `optional:"true"` // announcements of the pinned roots
+	IPNIPublisher    *ipni.Publisher            `optional:"true"` // advertisements of the provided content to IPNI indexers
+	IpnsRepub        *ipnsrp.Republisher        `optional:"true"`
+	GraphExchange    graphsync.GraphExchange    `optional:"true"`
+	ResourceManager  network.ResourceManager    `optional:"true"`
+	HolePunching     *libp2p.HolePuncher        `optional:"true"`
+	HaveProber       *haveprobe.Prober          `optional:"true"`
+	BitswapBroadcast *bsbroadcast.Network       `optional:"true"`
 
 	PubSub   *pubsub.PubSub             `optional:"true"`
 	PSRouter *psrouter.PubsubValueStore `optional:"true"`
@@ -108,6 +148,8 @@ type IpfsNode struct {
 
 	P2P *p2p.P2P `optional:"true"`
 
+	ClusterLite *clusterlite.Service `optional:"true"`
+
 	Process goprocess.Process
 	ctx     context.Context
 
@@ -72,12 +72,16 @@ func (api *BlockAPI) Put(ctx context.Context, src io.Reader, opts ...caopts.Bloc
 func (api *BlockAPI) Get(ctx context.Context, p path.Path) (io.Reader, error) {
 	ctx, span := tracing.Span(ctx, "CoreAPI.BlockAPI", "Get", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
-	rp, err := api.core().ResolvePath(ctx, p)
+	reqAPI, err := (*CoreAPI)(api).forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := reqAPI.ResolvePath(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := api.blocks.GetBlock(ctx, rp.Cid())
+	b, err := reqAPI.blocks.GetBlock(ctx, rp.Cid())
 	if err != nil {
 		return nil, err
 	}
@@ -130,12 +134,16 @@ func (api *BlockAPI) Stat(ctx context.Context, p path.Path) (coreiface.BlockStat
 	ctx, span := tracing.Span(ctx, "CoreAPI.BlockAPI", "Stat", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
-	rp, err := api.core().ResolvePath(ctx, p)
+	reqAPI, err := (*CoreAPI)(api).forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rp, err := reqAPI.ResolvePath(ctx, p)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err := api.blocks.GetBlock(ctx, rp.Cid())
+	b, err := reqAPI.blocks.GetBlock(ctx, rp.Cid())
 	if err != nil {
 		return nil, err
 	}
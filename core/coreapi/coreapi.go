@@ -40,8 +40,12 @@ import (
 	madns "github.com/multiformats/go-multiaddr-dns"
 
 	"github.com/ipfs/go-namesys"
+	"github.com/ipfs/kubo/clusterlite"
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/events"
 	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/core/prefetch"
+	"github.com/ipfs/kubo/core/quota"
 	"github.com/ipfs/kubo/repo"
 )
 
@@ -73,9 +77,19 @@ type CoreAPI struct {
 
 	pubSub *pubsub.PubSub
 
+	// clusterLite, if enabled, hands over the pins added by the user
+	clusterLite *clusterlite.Service
+
+	events   *events.Bus
+	quotas   *quota.Accountant
+	prefetch *prefetch.Manager
+
 	checkPublishAllowed func() error
 	checkOnline         func(allowOffline bool) error
 
+	// request is the request the API was narrowed for by forRequest
+	request *request
+
 	// ONLY for re-applying options in WithOptions, DO NOT USE ANYWHERE ELSE
 	nd         *core.IpfsNode
 	parentOpts options.ApiSettings
@@ -191,6 +205,12 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 
 		pubSub: n.PubSub,
 
+		clusterLite: n.ClusterLite,
+
+		events:   n.Events,
+		quotas:   n.Quotas,
+		prefetch: n.Prefetch,
+
 		nd:         n,
 		parentOpts: settings,
 	}
@@ -232,6 +252,7 @@ func (api *CoreAPI) WithOptions(opts ...options.ApiOption) (coreiface.CoreAPI, e
 		if err != nil {
 			return nil, fmt.Errorf("error constructing namesys: %w", err)
 		}
+		subAPI.namesys = node.WithNameResolvers(subAPI.namesys)
 
 		subAPI.provider = provider.NewOfflineProvider()
 
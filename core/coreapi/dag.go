@@ -55,6 +55,28 @@ func (adder *pinningAdder) AddMany(ctx context.Context, nds []ipld.Node) error {
 	return adder.pinning.Flush(ctx)
 }
 
+// Get fetches the node with the request options of ctx, see
+// WithRequestOptions.
+func (api *dagAPI) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	reqAPI, err := api.core.forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return reqAPI.dag.Get(ctx, c)
+}
+
+// GetMany fetches the nodes with the request options of ctx.
+func (api *dagAPI) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	reqAPI, err := api.core.forRequest(ctx)
+	if err != nil {
+		out := make(chan *ipld.NodeOption, 1)
+		out <- &ipld.NodeOption{Err: err}
+		close(out)
+		return out
+	}
+	return reqAPI.dag.GetMany(ctx, cids)
+}
+
 func (api *dagAPI) Pinning() ipld.NodeAdder {
 	return (*pinningAdder)(api.core)
 }
@@ -0,0 +1,42 @@
+package coreapi
+
+import (
+	"context"
+
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	"github.com/ipfs/kubo/core/dirpage"
+)
+
+// LsPage is like Unixfs().Ls, but only lists page of the directory at p. The
+// shards of a HAMT-sharded directory are fetched lazily, so that the first
+// pages are listed without fetching the whole directory.
+func (api *CoreAPI) LsPage(ctx context.Context, p path.Path, page dirpage.Page, opts ...options.UnixfsLsOption) (<-chan coreiface.DirEntry, error) {
+	settings, err := options.UnixfsLsOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	ses := api.getSession(ctx)
+	uses := (*UnixfsAPI)(ses)
+
+	dagnode, err := ses.ResolveNode(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan coreiface.DirEntry)
+	go func() {
+		defer close(out)
+		for l := range dirpage.Links(ctx, ses.dag, dagnode, page) {
+			select {
+			case out <- uses.processLink(ctx, l, settings):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -8,6 +8,8 @@ import (
 
 	keystore "github.com/ipfs/go-ipfs-keystore"
 	"github.com/ipfs/go-namesys"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/node"
 	"github.com/ipfs/kubo/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -95,8 +97,14 @@ func (api *NameAPI) Publish(ctx context.Context, p path.Path, opts ...caopts.Nam
 		return nil, err
 	}
 
+	name := coreiface.FormatKeyID(pid)
+	api.events.Emit(events.IpnsPublished, map[string]interface{}{
+		"Name":  name,
+		"Value": p.String(),
+	})
+
 	return &ipnsEntry{
-		name:  coreiface.FormatKeyID(pid),
+		name:  name,
 		value: p,
 	}, nil
 }
@@ -117,14 +125,19 @@ func (api *NameAPI) Search(ctx context.Context, name string, opts ...caopts.Name
 		return nil, err
 	}
 
-	var resolver namesys.Resolver = api.namesys
+	reqAPI, err := (*CoreAPI)(api).forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var resolver namesys.Resolver = reqAPI.namesys
 	if !options.Cache {
-		resolver, err = namesys.NewNameSystem(api.routing,
+		ns, err := namesys.NewNameSystem(reqAPI.routing,
 			namesys.WithDatastore(api.repo.Datastore()),
 			namesys.WithDNSResolver(api.dnsResolver))
 		if err != nil {
 			return nil, err
 		}
+		resolver = node.WithNameResolvers(ns)
 	}
 
 	if !strings.HasPrefix(name, "/ipns/") {
@@ -50,6 +50,10 @@ func (api *CoreAPI) ResolvePath(ctx context.Context, p path.Path) (path.Resolved
 	if err := p.IsValid(); err != nil {
 		return nil, err
 	}
+	api, err := api.forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	ipath := ipfspath.Path(p.String())
 	ipath, err := resolve.ResolveIPNS(ctx, api.namesys, ipath)
@@ -12,6 +12,8 @@ import (
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
 	caopts "github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/quota"
 	"github.com/ipfs/kubo/tracing"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -19,10 +21,19 @@ import (
 
 type PinAPI CoreAPI
 
-func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOption) error {
+func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOption) (err error) {
 	ctx, span := tracing.Span(ctx, "CoreAPI.PinAPI", "Add", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
+	defer func() {
+		if err != nil {
+			api.events.Emit(events.PinFailed, map[string]interface{}{
+				"Path":  p.String(),
+				"Error": err.Error(),
+			})
+		}
+	}()
+
 	dagNode, err := api.core().ResolveNode(ctx, p)
 	if err != nil {
 		return fmt.Errorf("pin: %s", err)
@@ -35,6 +46,10 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 
 	span.SetAttributes(attribute.Bool("recursive", settings.Recursive))
 
+	if err := api.quotas.Check(ctx, quota.Pins); err != nil {
+		return fmt.Errorf("pin: %w", err)
+	}
+
 	defer api.blockstore.PinLock(ctx).Unlock(ctx)
 
 	err = api.pinning.Pin(ctx, dagNode, settings.Recursive)
@@ -46,7 +61,22 @@ func (api *PinAPI) Add(ctx context.Context, p path.Path, opts ...caopts.PinAddOp
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+
+	// the pin is the user's now, even if the cluster pinned the CID first
+	if api.clusterLite != nil && settings.Recursive {
+		if err := api.clusterLite.Disown(ctx, dagNode.Cid()); err != nil {
+			return err
+		}
+	}
+
+	api.events.Emit(events.PinAdded, map[string]interface{}{
+		"Cid":       dagNode.Cid().String(),
+		"Recursive": settings.Recursive,
+	})
+	return nil
 }
 
 func (api *PinAPI) Ls(ctx context.Context, opts ...caopts.PinLsOption) (<-chan coreiface.Pin, error) {
@@ -118,7 +148,15 @@ func (api *PinAPI) Rm(ctx context.Context, p path.Path, opts ...caopts.PinRmOpti
 		return err
 	}
 
-	return api.pinning.Flush(ctx)
+	if err := api.pinning.Flush(ctx); err != nil {
+		return err
+	}
+
+	api.events.Emit(events.PinRemoved, map[string]interface{}{
+		"Cid":       rp.Cid().String(),
+		"Recursive": settings.Recursive,
+	})
+	return nil
 }
 
 func (api *PinAPI) Update(ctx context.Context, from path.Path, to path.Path, opts ...caopts.PinUpdateOption) error {
@@ -0,0 +1,25 @@
+package coreapi
+
+import (
+	"context"
+
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/ipfs/kubo/core/prefetch"
+)
+
+// WarmCache starts a background job fetching the DAG under p into the local
+// blockstore, and returns its ID. It returns once p is resolved, without
+// waiting for the blocks. With an offline api, the job only walks the blocks
+// already in the blockstore.
+func (api *CoreAPI) WarmCache(ctx context.Context, p path.Path, opts prefetch.Options) (string, error) {
+	rp, err := api.ResolvePath(ctx, p)
+	if err != nil {
+		return "", err
+	}
+	return api.prefetch.Start(api.dag, rp.Cid(), opts), nil
+}
+
+// WarmCacheProgress returns the progress of a job started by WarmCache.
+func (api *CoreAPI) WarmCacheProgress(id string) (prefetch.Progress, error) {
+	return api.prefetch.Progress(id)
+}
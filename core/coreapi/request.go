@@ -0,0 +1,370 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	offlinexch "github.com/ipfs/go-ipfs-exchange-offline"
+	offlineroute "github.com/ipfs/go-ipfs-routing/offline"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-namesys"
+
+	"github.com/ipfs/kubo/core/node"
+	irouting "github.com/ipfs/kubo/routing"
+)
+
+// RoutingPreference restricts the routers used by a request.
+type RoutingPreference string
+
+const (
+	// RoutingAll uses all the configured routers.
+	RoutingAll RoutingPreference = ""
+	// RoutingDHTOnly only uses the DHT.
+	RoutingDHTOnly RoutingPreference = "dht"
+	// RoutingHTTPOnly only uses the delegated HTTP routers.
+	RoutingHTTPOnly RoutingPreference = "http"
+)
+
+// ErrFetchBudgetExceeded is returned when a request fetched more blocks or
+// bytes from the network than its budget allows. The request fetches nothing
+// more once its budget is exceeded.
+var ErrFetchBudgetExceeded = errors.New("fetch budget of the request exceeded")
+
+// RequestOptions are the options of a single request to the CoreAPI, carried
+// by its context. They narrow the options of the API for the calls made with
+// the context, so that one node can serve several tenants with their own
+// policies.
+//
+// They apply to Unixfs().Get, Unixfs().Ls, Dag().Get, Block().Get,
+// Block().Stat, ResolvePath and Name().Resolve. The routing preference applies
+// to name resolution: blocks are still discovered with the routers of the
+// node.
+type RequestOptions struct {
+	// Offline only uses the local blocks and records.
+	Offline bool
+	// Routing restricts the routers resolving names.
+	Routing RoutingPreference
+	// MaxBlocks and MaxBytes bound the blocks fetched from the network by
+	// all the calls made with the context, 0 for no limit.
+	MaxBlocks int
+	MaxBytes  int64
+	// Accounting counts the blocks fetched from the network, reported by
+	// RequestCostFrom, even without a limit.
+	Accounting bool
+	// Validator validates the blocks read by the request, local or fetched.
+	Validator BlockValidator
+}
+
+// BlockValidator validates the blocks read by a request, e.g. to refuse the
+// blocks of crafted DAGs. Its implementations must be comparable, e.g.
+// pointers.
+type BlockValidator interface {
+	ValidateBlock(blocks.Block) error
+}
+
+// RequestCost is the cost of a request: the blocks and bytes it fetched from
+// the network.
+type RequestCost struct {
+	Blocks   int
+	Bytes    int64
+	Exceeded bool // the fetch budget was exceeded
+	Rejected bool // a block was rejected by the validator
+}
+
+// RequestOption sets a RequestOptions.
+type RequestOption func(*RequestOptions)
+
+// RequestOffline only uses the local blocks and records.
+func RequestOffline() RequestOption {
+	return func(o *RequestOptions) { o.Offline = true }
+}
+
+// RequestRouting restricts the routers resolving names.
+func RequestRouting(p RoutingPreference) RequestOption {
+	return func(o *RequestOptions) { o.Routing = p }
+}
+
+// RequestFetchBudget bounds the blocks and bytes fetched from the network,
+// 0 for no limit.
+func RequestFetchBudget(maxBlocks int, maxBytes int64) RequestOption {
+	return func(o *RequestOptions) {
+		o.MaxBlocks = maxBlocks
+		o.MaxBytes = maxBytes
+	}
+}
+
+// RequestAccounting counts the blocks fetched by the request, see
+// RequestCostFrom.
+func RequestAccounting() RequestOption {
+	return func(o *RequestOptions) { o.Accounting = true }
+}
+
+// RequestBlockValidator validates the blocks read by the request with v.
+func RequestBlockValidator(v BlockValidator) RequestOption {
+	return func(o *RequestOptions) { o.Validator = v }
+}
+
+type requestKey struct{}
+
+// request are the options of a request, and the blocks it fetched so far.
+type request struct {
+	opts   RequestOptions
+	budget *fetchBudget
+}
+
+// WithRequestOptions returns a context carrying the options, on top of the
+// options already carried by ctx. The fetch budget starts over.
+func WithRequestOptions(ctx context.Context, opts ...RequestOption) context.Context {
+	var o RequestOptions
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		o = r.opts
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	r := &request{opts: o}
+	if o.MaxBlocks > 0 || o.MaxBytes > 0 || o.Accounting || o.Validator != nil {
+		r.budget = &fetchBudget{maxBlocks: o.MaxBlocks, maxBytes: o.MaxBytes}
+	}
+	return context.WithValue(ctx, requestKey{}, r)
+}
+
+// RequestOptionsFrom returns the options carried by ctx.
+func RequestOptionsFrom(ctx context.Context) RequestOptions {
+	if r, ok := ctx.Value(requestKey{}).(*request); ok {
+		return r.opts
+	}
+	return RequestOptions{}
+}
+
+// RequestCostFrom returns the cost of the calls made so far with ctx, when it
+// carries a fetch budget, RequestAccounting or a validator.
+func RequestCostFrom(ctx context.Context) (RequestCost, bool) {
+	r, ok := ctx.Value(requestKey{}).(*request)
+	if !ok || r.budget == nil {
+		return RequestCost{}, false
+	}
+	return r.budget.cost(), true
+}
+
+// forRequest returns api narrowed by the request options of ctx, or api if
+// there are none or if it is already narrowed for them.
+func (api *CoreAPI) forRequest(ctx context.Context) (*CoreAPI, error) {
+	r, ok := ctx.Value(requestKey{}).(*request)
+	if !ok || (r.opts == RequestOptions{}) || api.request == r {
+		return api, nil
+	}
+	reqAPI := *api
+	reqAPI.request = r
+
+	routingChanged := false
+	switch {
+	case r.opts.Offline:
+		reqAPI.routing = offlineroute.NewOfflineRouter(api.repo.Datastore(), api.recordValidator)
+		routingChanged = true
+	case r.opts.Routing == RoutingDHTOnly:
+		if reqAPI.routing = irouting.Select(api.routing, irouting.IsDHT); reqAPI.routing == nil {
+			return nil, errors.New("no DHT router is configured")
+		}
+		routingChanged = true
+	case r.opts.Routing == RoutingHTTPOnly:
+		if reqAPI.routing = irouting.Select(api.routing, irouting.IsHTTP); reqAPI.routing == nil {
+			return nil, errors.New("no HTTP router is configured")
+		}
+		routingChanged = true
+	case r.opts.Routing != RoutingAll:
+		return nil, fmt.Errorf("unknown routing preference %q", r.opts.Routing)
+	}
+	if routingChanged {
+		// the records resolved with other routers aren't cached
+		ns, err := namesys.NewNameSystem(reqAPI.routing,
+			namesys.WithDatastore(api.repo.Datastore()),
+			namesys.WithDNSResolver(api.dnsResolver))
+		if err != nil {
+			return nil, fmt.Errorf("error constructing namesys: %w", err)
+		}
+		reqAPI.namesys = node.WithNameResolvers(ns)
+	}
+
+	exchangeChanged := false
+	if r.opts.Offline {
+		reqAPI.exchange = offlinexch.Exchange(api.blockstore)
+		exchangeChanged = true
+	} else if r.budget != nil {
+		reqAPI.exchange = &budgetExchange{Interface: api.exchange, budget: r.budget, validator: r.opts.Validator}
+		exchangeChanged = true
+	}
+	if exchangeChanged || r.opts.Validator != nil {
+		var bs blockstore.Blockstore = api.blockstore
+		if r.opts.Validator != nil {
+			bs = &validatingBlockstore{Blockstore: bs, budget: r.budget, validator: r.opts.Validator}
+		}
+		reqAPI.blocks = bserv.New(bs, reqAPI.exchange)
+		reqAPI.dag = dag.NewDAGService(reqAPI.blocks)
+		fetchers := node.FetcherConfig(reqAPI.blocks)
+		reqAPI.ipldFetcherFactory = fetchers.IPLDFetcher
+		reqAPI.unixFSFetcherFactory = fetchers.UnixfsFetcher
+	}
+	return &reqAPI, nil
+}
+
+// fetchBudget counts the blocks fetched by a request.
+type fetchBudget struct {
+	maxBlocks int
+	maxBytes  int64
+
+	mu       sync.Mutex
+	blocks   int
+	bytes    int64
+	exceeded bool
+	rejected bool
+}
+
+// spend counts a fetched block, and returns ErrFetchBudgetExceeded once the
+// budget is exceeded.
+func (b *fetchBudget) spend(blk blocks.Block) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocks++
+	b.bytes += int64(len(blk.RawData()))
+	if (b.maxBlocks > 0 && b.blocks > b.maxBlocks) || (b.maxBytes > 0 && b.bytes > b.maxBytes) {
+		b.exceeded = true
+	}
+	if b.exceeded {
+		return ErrFetchBudgetExceeded
+	}
+	return nil
+}
+
+// check returns ErrFetchBudgetExceeded if the budget is already exceeded, so
+// that nothing more is fetched.
+func (b *fetchBudget) check() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.exceeded {
+		return ErrFetchBudgetExceeded
+	}
+	return nil
+}
+
+// validate validates a block with v, recording its rejection.
+func (b *fetchBudget) validate(v BlockValidator, blk blocks.Block) error {
+	if v == nil {
+		return nil
+	}
+	if err := v.ValidateBlock(blk); err != nil {
+		b.mu.Lock()
+		b.rejected = true
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (b *fetchBudget) cost() RequestCost {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return RequestCost{Blocks: b.blocks, Bytes: b.bytes, Exceeded: b.exceeded, Rejected: b.rejected}
+}
+
+// validatingBlockstore validates the local blocks read by a request.
+type validatingBlockstore struct {
+	blockstore.Blockstore
+	budget    *fetchBudget
+	validator BlockValidator
+}
+
+func (bs *validatingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	blk, err := bs.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := bs.budget.validate(bs.validator, blk); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+// budgetExchange is an exchange spending the fetch budget of a request, and
+// validating the blocks it fetches.
+type budgetExchange struct {
+	exchange.Interface
+	budget    *fetchBudget
+	validator BlockValidator
+}
+
+var _ exchange.SessionExchange = (*budgetExchange)(nil)
+
+func (e *budgetExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	return budgetFetcher{e.Interface, e.budget, e.validator}.GetBlock(ctx, c)
+}
+
+func (e *budgetExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	return budgetFetcher{e.Interface, e.budget, e.validator}.GetBlocks(ctx, cids)
+}
+
+func (e *budgetExchange) NewSession(ctx context.Context) exchange.Fetcher {
+	if sx, ok := e.Interface.(exchange.SessionExchange); ok {
+		return budgetFetcher{sx.NewSession(ctx), e.budget, e.validator}
+	}
+	return budgetFetcher{e.Interface, e.budget, e.validator}
+}
+
+type budgetFetcher struct {
+	f         exchange.Fetcher
+	budget    *fetchBudget
+	validator BlockValidator
+}
+
+func (f budgetFetcher) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if err := f.budget.check(); err != nil {
+		return nil, err
+	}
+	blk, err := f.f.GetBlock(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.budget.spend(blk); err != nil {
+		return nil, err
+	}
+	if err := f.budget.validate(f.validator, blk); err != nil {
+		return nil, err
+	}
+	return blk, nil
+}
+
+func (f budgetFetcher) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	if err := f.budget.check(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	in, err := f.f.GetBlocks(ctx, cids)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	out := make(chan blocks.Block)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for blk := range in {
+			if f.budget.spend(blk) != nil || f.budget.validate(f.validator, blk) != nil {
+				// the callers see the missing blocks
+				return
+			}
+			select {
+			case out <- blk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,144 @@
+package coreapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+)
+
+// mapExchange serves the blocks it holds.
+type mapExchange struct {
+	exchange.Interface
+	blocks map[cid.Cid]blocks.Block
+}
+
+func (e *mapExchange) GetBlock(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	if blk, ok := e.blocks[c]; ok {
+		return blk, nil
+	}
+	return nil, errors.New("block not found")
+}
+
+func (e *mapExchange) GetBlocks(ctx context.Context, cids []cid.Cid) (<-chan blocks.Block, error) {
+	out := make(chan blocks.Block, len(cids))
+	for _, c := range cids {
+		if blk, ok := e.blocks[c]; ok {
+			out <- blk
+		}
+	}
+	close(out)
+	return out, nil
+}
+
+// sizeValidator rejects the blocks larger than max.
+type sizeValidator struct{ max int }
+
+func (v *sizeValidator) ValidateBlock(blk blocks.Block) error {
+	if len(blk.RawData()) > v.max {
+		return fmt.Errorf("block %s too large", blk.Cid())
+	}
+	return nil
+}
+
+func newMapExchange(data ...string) (*mapExchange, []cid.Cid) {
+	e := &mapExchange{blocks: make(map[cid.Cid]blocks.Block)}
+	cids := make([]cid.Cid, len(data))
+	for i, d := range data {
+		blk := blocks.NewBlock([]byte(d))
+		e.blocks[blk.Cid()] = blk
+		cids[i] = blk.Cid()
+	}
+	return e, cids
+}
+
+func TestRequestOptions(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := RequestCostFrom(ctx); ok {
+		t.Fatal("expected no cost without request options")
+	}
+
+	ctx = WithRequestOptions(ctx, RequestRouting(RoutingDHTOnly))
+	if _, ok := RequestCostFrom(ctx); ok {
+		t.Fatal("expected no cost without a fetch budget")
+	}
+
+	// the options are narrowed on top of the ones of the context
+	v := &sizeValidator{max: 10}
+	ctx = WithRequestOptions(ctx, RequestOffline(), RequestFetchBudget(2, 100), RequestBlockValidator(v))
+	want := RequestOptions{Offline: true, Routing: RoutingDHTOnly, MaxBlocks: 2, MaxBytes: 100, Validator: v}
+	if got := RequestOptionsFrom(ctx); got != want {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	if cost, ok := RequestCostFrom(ctx); !ok || cost != (RequestCost{}) {
+		t.Fatalf("expected an empty cost, got %+v", cost)
+	}
+}
+
+func TestBudgetExchange(t *testing.T) {
+	e, cids := newMapExchange("one", "two", "three")
+	ctx := WithRequestOptions(context.Background(), RequestFetchBudget(2, 0))
+	r := ctx.Value(requestKey{}).(*request)
+	bx := &budgetExchange{Interface: e, budget: r.budget}
+
+	for _, c := range cids[:2] {
+		if _, err := bx.GetBlock(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := bx.GetBlock(ctx, cids[2]); err != ErrFetchBudgetExceeded {
+		t.Fatalf("expected %v, got %v", ErrFetchBudgetExceeded, err)
+	}
+	// nothing more is fetched once the budget is exceeded
+	if _, err := bx.NewSession(ctx).GetBlock(ctx, cids[0]); err != ErrFetchBudgetExceeded {
+		t.Fatalf("expected %v, got %v", ErrFetchBudgetExceeded, err)
+	}
+	want := RequestCost{Blocks: 3, Bytes: int64(len("one") + len("two") + len("three")), Exceeded: true}
+	if cost, _ := RequestCostFrom(ctx); cost != want {
+		t.Fatalf("expected %+v, got %+v", want, cost)
+	}
+}
+
+func TestBudgetExchangeBytes(t *testing.T) {
+	e, cids := newMapExchange("one", "two", "three")
+	ctx := WithRequestOptions(context.Background(), RequestFetchBudget(0, 8))
+	r := ctx.Value(requestKey{}).(*request)
+	bx := &budgetExchange{Interface: e, budget: r.budget}
+
+	ch, err := bx.GetBlocks(ctx, cids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got int
+	for range ch {
+		got++
+	}
+	if got != 2 {
+		t.Fatalf("expected the 2 blocks within the budget, got %d", got)
+	}
+	if cost, _ := RequestCostFrom(ctx); !cost.Exceeded || cost.Blocks != 3 {
+		t.Fatalf("expected the budget to be exceeded by the third block, got %+v", cost)
+	}
+}
+
+func TestBudgetExchangeValidator(t *testing.T) {
+	e, cids := newMapExchange("small", "much larger block")
+	ctx := WithRequestOptions(context.Background(), RequestAccounting(), RequestBlockValidator(&sizeValidator{max: 10}))
+	r := ctx.Value(requestKey{}).(*request)
+	bx := &budgetExchange{Interface: e, budget: r.budget, validator: r.opts.Validator}
+
+	if _, err := bx.GetBlock(ctx, cids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bx.GetBlock(ctx, cids[1]); err == nil {
+		t.Fatal("expected the large block to be rejected")
+	}
+	cost, _ := RequestCostFrom(ctx)
+	if !cost.Rejected || cost.Exceeded || cost.Blocks != 2 {
+		t.Fatalf("expected the rejection to be recorded, got %+v", cost)
+	}
+}
@@ -11,10 +11,10 @@ import (
 	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ipfs/kubo/core/coreunix"
+	"github.com/ipfs/kubo/core/quota"
 
 	blockservice "github.com/ipfs/go-blockservice"
 	cid "github.com/ipfs/go-cid"
-	cidutil "github.com/ipfs/go-cidutil"
 	filestore "github.com/ipfs/go-filestore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	ipld "github.com/ipfs/go-ipld-format"
@@ -94,6 +94,12 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 	//	return
 	//}
 
+	if settings.Pin && !settings.OnlyHash {
+		if err := api.quotas.Check(ctx, quota.Pins); err != nil {
+			return nil, err
+		}
+	}
+
 	if settings.NoCopy && !(cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled) {
 		return nil, fmt.Errorf("either the filestore or the urlstore must be enabled to use nocopy, see: https://github.com/ipfs/kubo/blob/master/docs/experimental-features.md#ipfs-filestore")
 	}
@@ -145,31 +151,8 @@ func (api *UnixfsAPI) Add(ctx context.Context, files files.Node, opts ...options
 		return nil, err
 	}
 
-	fileAdder.Chunker = settings.Chunker
-	if settings.Events != nil {
-		fileAdder.Out = settings.Events
-		fileAdder.Progress = settings.Progress
-	}
-	fileAdder.Pin = settings.Pin && !settings.OnlyHash
-	fileAdder.Silent = settings.Silent
-	fileAdder.RawLeaves = settings.RawLeaves
-	fileAdder.NoCopy = settings.NoCopy
-	fileAdder.CidBuilder = prefix
-
-	switch settings.Layout {
-	case options.BalancedLayout:
-		// Default
-	case options.TrickleLayout:
-		fileAdder.Trickle = true
-	default:
-		return nil, fmt.Errorf("unknown layout: %d", settings.Layout)
-	}
-
-	if settings.Inline {
-		fileAdder.CidBuilder = cidutil.InlineBuilder{
-			Builder: fileAdder.CidBuilder,
-			Limit:   settings.InlineLimit,
-		}
+	if err := fileAdder.Configure(settings, prefix); err != nil {
+		return nil, err
 	}
 
 	if settings.OnlyHash {
@@ -206,7 +189,11 @@ func (api *UnixfsAPI) Get(ctx context.Context, p path.Path) (files.Node, error)
 	ctx, span := tracing.Span(ctx, "CoreAPI.UnixfsAPI", "Get", trace.WithAttributes(attribute.String("path", p.String())))
 	defer span.End()
 
-	ses := api.core().getSession(ctx)
+	reqAPI, err := api.core().forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ses := reqAPI.getSession(ctx)
 
 	nd, err := ses.ResolveNode(ctx, p)
 	if err != nil {
@@ -229,7 +216,11 @@ func (api *UnixfsAPI) Ls(ctx context.Context, p path.Path, opts ...options.Unixf
 
 	span.SetAttributes(attribute.Bool("resolvechildren", settings.ResolveChildren))
 
-	ses := api.core().getSession(ctx)
+	reqAPI, err := api.core().forRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ses := reqAPI.getSession(ctx)
 	uses := (*UnixfsAPI)(ses)
 
 	dagnode, err := ses.ResolveNode(ctx, p)
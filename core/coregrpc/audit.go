@@ -0,0 +1,81 @@
+package coregrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/kubo/core/auditlog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// auditedMethods are the methods changing the node, which are recorded in the
+// audit log.
+var auditedMethods = map[string]bool{
+	"/kubo.rpc.v1.Block/Put":    true,
+	"/kubo.rpc.v1.Block/Rm":     true,
+	"/kubo.rpc.v1.Unixfs/Add":   true,
+	"/kubo.rpc.v1.Pin/Add":      true,
+	"/kubo.rpc.v1.Pin/Rm":       true,
+	"/kubo.rpc.v1.Name/Publish": true,
+}
+
+// auditEntry is an entry of the audit log, for each call of auditedMethods.
+// The entries of the RPC API share the log, and are told apart by Command.
+type auditEntry struct {
+	Time time.Time
+	// Command is the full name of the method, e.g. /kubo.rpc.v1.Pin/Add.
+	Command   string
+	Arguments []string `json:",omitempty"`
+	Remote    string
+	// Status is the gRPC status code of the call.
+	Status   string
+	Duration string
+}
+
+func auditUnary(l *auditlog.Log) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !auditedMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		var args []string
+		if r, ok := req.(interface{ GetPath() string }); ok {
+			args = []string{r.GetPath()}
+		}
+		appendEntry(ctx, l, info.FullMethod, args, start, err)
+		return resp, err
+	}
+}
+
+// auditStream records the streaming calls, without the messages streamed,
+// such as the files added.
+func auditStream(l *auditlog.Log) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !auditedMethods[info.FullMethod] {
+			return handler(srv, ss)
+		}
+		start := time.Now()
+		err := handler(srv, ss)
+		appendEntry(ss.Context(), l, info.FullMethod, nil, start, err)
+		return err
+	}
+}
+
+func appendEntry(ctx context.Context, l *auditlog.Log, method string, args []string, start time.Time, err error) {
+	e := auditEntry{
+		Time:      start.UTC(),
+		Command:   method,
+		Arguments: args,
+		Status:    status.Code(err).String(),
+		Duration:  time.Since(start).String(),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		e.Remote = p.Addr.String()
+	}
+	if err := l.Append(e); err != nil {
+		log.Errorw("failed to write the API audit log", "command", method, "error", err)
+	}
+}
@@ -0,0 +1,152 @@
+// Package coregrpc serves the core operations of the node over gRPC, as an
+// opt-in alternative to the HTTP RPC API for clients in other languages.
+//
+// The services are defined in pb/kubo.proto and described in
+// docs/grpc-api.md. The API has no authorizations: it isn't served while
+// API.Authorizations is set. The calls changing the node are recorded in the
+// audit log of the RPC API when API.Audit is enabled.
+package coregrpc
+
+//go:generate protoc -I pb --go_out=pb --go_opt=paths=source_relative --go-grpc_out=pb --go-grpc_opt=paths=source_relative kubo.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/auditlog"
+	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/coregrpc/pb"
+	"github.com/ipfs/kubo/core/corehttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var log = logging.Logger("core/grpc")
+
+// shutdownTimeout is how long running calls are waited for when the node
+// shuts down.
+const shutdownTimeout = 30 * time.Second
+
+// ErrAuthorizations is returned by Serve when API.Authorizations is set, as
+// the gRPC API can't restrict its callers.
+var ErrAuthorizations = errors.New("the gRPC API has no authorizations and can't be served while API.Authorizations is set")
+
+// Serve serves the gRPC API of node on lis until the node shuts down. The
+// repo of the node is at repoRoot.
+func Serve(node *core.IpfsNode, lis net.Listener, repoRoot string) error {
+	defer lis.Close()
+
+	cfg, err := node.Repo.Config()
+	if err != nil {
+		return err
+	}
+	if len(cfg.API.Authorizations) > 0 {
+		return ErrAuthorizations
+	}
+	var audit *auditlog.Log
+	if a := cfg.API.Audit; a != nil && a.Enabled.WithDefault(false) {
+		audit, err = corehttp.APIAuditLog(a, repoRoot)
+		if err != nil {
+			return err
+		}
+	}
+
+	api, err := coreapi.NewCoreAPI(node)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-node.Process.Closing():
+		return fmt.Errorf("failed to start server, process closing")
+	default:
+	}
+
+	srv := newServer(&server{node: node, api: api}, audit)
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-node.Process.Closing():
+		log.Infof("server at %s terminating...", lis.Addr())
+		stopped := make(chan struct{})
+		go func() {
+			srv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			srv.Stop()
+		}
+		<-done
+		log.Infof("server at %s terminated", lis.Addr())
+		return nil
+	}
+}
+
+// newServer returns the server of the services of s, recording the calls
+// changing the node in audit if not nil.
+func newServer(s *server, audit *auditlog.Log) *grpc.Server {
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	if audit != nil {
+		unary = append(unary, auditUnary(audit))
+		stream = append(stream, auditStream(audit))
+	}
+	unary = append(unary, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		return resp, toStatus(err)
+	})
+	stream = append(stream, func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return toStatus(handler(srv, ss))
+	})
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	pb.RegisterNodeServer(srv, &nodeServer{server: s})
+	pb.RegisterBlockServer(srv, &blockServer{server: s})
+	pb.RegisterUnixfsServer(srv, &unixfsServer{server: s})
+	pb.RegisterPinServer(srv, &pinServer{server: s})
+	pb.RegisterNameServer(srv, &nameServer{server: s})
+	pb.RegisterRoutingServer(srv, &routingServer{server: s})
+	return srv
+}
+
+type server struct {
+	node *core.IpfsNode
+	api  coreiface.CoreAPI
+}
+
+// invalidArgument marks errors in the request.
+type invalidArgument struct{ error }
+
+// toStatus converts the errors to a gRPC status, so that clients can tell
+// invalid requests and missing content apart from other failures.
+func toStatus(err error) error {
+	var invalid invalidArgument
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &invalid):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case ipld.IsNotFound(err):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package coregrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/auditlog"
+	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/coregrpc/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// dialServer serves the API of a new node on a unix socket, recording the
+// calls in audit if not nil, and dials it.
+func dialServer(t *testing.T, audit *auditlog.Log) (*core.IpfsNode, *grpc.ClientConn) {
+	t.Helper()
+	ctx := context.Background()
+	n, err := core.NewNode(ctx, &core.BuildCfg{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { n.Close() })
+	api, err := coreapi.NewCoreAPI(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "grpc.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newServer(&server{node: n, api: api}, audit)
+	go srv.Serve(l)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("unix://"+l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return n, conn
+}
+
+func TestGRPC(t *testing.T) {
+	ctx := context.Background()
+	n, conn := dialServer(t, nil)
+
+	id, err := pb.NewNodeClient(conn).ID(ctx, &pb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Id != n.Identity.String() {
+		t.Fatalf("unexpected ID %s", id.Id)
+	}
+
+	// blocks
+	blocks := pb.NewBlockClient(conn)
+	put, err := blocks.Put(ctx, &pb.BlockPutRequest{Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := blocks.Get(ctx, &pb.PathMessage{Path: "/ipfs/" + put.Cid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(block.Data) != "hello" {
+		t.Fatalf("unexpected block %q", block.Data)
+	}
+	_, err = blocks.Get(ctx, &pb.PathMessage{Path: "not a path"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+
+	// files are streamed both ways
+	unixfs := pb.NewUnixfsClient(conn)
+	data := bytes.Repeat([]byte("0123456789"), 100000)
+	add, err := unixfs.Add(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(data); i += 300000 {
+		end := i + 300000
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := add.Send(&pb.AddRequest{Data: data[i:end], Pin: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	added, err := add.CloseAndRecv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cat, err := unixfs.Cat(ctx, &pb.CatRequest{Path: "/ipfs/" + added.Cid, Offset: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []byte
+	for {
+		msg, err := cat.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg.Data...)
+	}
+	if !bytes.Equal(got, data[10:]) {
+		t.Fatalf("unexpected file of %d bytes, expected %d", len(got), len(data)-10)
+	}
+
+	pins, err := pb.NewPinClient(conn).Ls(ctx, &pb.PinLsRequest{Type: "recursive"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin, err := pins.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pin.Cid != added.Cid || pin.Type != "recursive" {
+		t.Fatalf("unexpected pin %+v", pin)
+	}
+}
+
+func TestGRPCAudit(t *testing.T) {
+	ctx := context.Background()
+	audit := &auditlog.Log{Path: filepath.Join(t.TempDir(), "api-audit.log")}
+	_, conn := dialServer(t, audit)
+
+	blocks := pb.NewBlockClient(conn)
+	put, err := blocks.Put(ctx, &pb.BlockPutRequest{Data: []byte("hello")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blocks.Stat(ctx, &pb.PathMessage{Path: "/ipfs/" + put.Cid}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := blocks.Rm(ctx, &pb.BlockRmRequest{Path: "not a path"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected an invalid argument, got %v", err)
+	}
+
+	b, err := os.ReadFile(audit.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []auditEntry
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var e auditEntry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	// Stat doesn't change the node
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Command != "/kubo.rpc.v1.Block/Put" || e.Status != "OK" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+	if e := entries[1]; e.Command != "/kubo.rpc.v1.Block/Rm" || e.Status != "InvalidArgument" || len(e.Arguments) != 1 || e.Arguments[0] != "not a path" {
+		t.Fatalf("unexpected entry %+v", e)
+	}
+}
@@ -0,0 +1,1010 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: kubo.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// NodeClient is the client API for Node service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NodeClient interface {
+	ID(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*IDResponse, error)
+	Version(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*VersionResponse, error)
+}
+
+type nodeClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNodeClient(cc grpc.ClientConnInterface) NodeClient {
+	return &nodeClient{cc}
+}
+
+func (c *nodeClient) ID(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*IDResponse, error) {
+	out := new(IDResponse)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Node/ID", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nodeClient) Version(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Node/Version", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NodeServer is the server API for Node service.
+// All implementations must embed UnimplementedNodeServer
+// for forward compatibility
+type NodeServer interface {
+	ID(context.Context, *Empty) (*IDResponse, error)
+	Version(context.Context, *Empty) (*VersionResponse, error)
+	mustEmbedUnimplementedNodeServer()
+}
+
+// UnimplementedNodeServer must be embedded to have forward compatible implementations.
+type UnimplementedNodeServer struct {
+}
+
+func (UnimplementedNodeServer) ID(context.Context, *Empty) (*IDResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ID not implemented")
+}
+func (UnimplementedNodeServer) Version(context.Context, *Empty) (*VersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Version not implemented")
+}
+func (UnimplementedNodeServer) mustEmbedUnimplementedNodeServer() {}
+
+// UnsafeNodeServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NodeServer will
+// result in compilation errors.
+type UnsafeNodeServer interface {
+	mustEmbedUnimplementedNodeServer()
+}
+
+func RegisterNodeServer(s grpc.ServiceRegistrar, srv NodeServer) {
+	s.RegisterService(&Node_ServiceDesc, srv)
+}
+
+func _Node_ID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).ID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Node/ID",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).ID(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Node_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NodeServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Node/Version",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NodeServer).Version(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Node_ServiceDesc is the grpc.ServiceDesc for Node service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Node_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Node",
+	HandlerType: (*NodeServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ID",
+			Handler:    _Node_ID_Handler,
+		},
+		{
+			MethodName: "Version",
+			Handler:    _Node_Version_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "kubo.proto",
+}
+
+// BlockClient is the client API for Block service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BlockClient interface {
+	Get(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (*DataMessage, error)
+	Put(ctx context.Context, in *BlockPutRequest, opts ...grpc.CallOption) (*BlockStatResponse, error)
+	Stat(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (*BlockStatResponse, error)
+	Rm(ctx context.Context, in *BlockRmRequest, opts ...grpc.CallOption) (*Empty, error)
+}
+
+type blockClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBlockClient(cc grpc.ClientConnInterface) BlockClient {
+	return &blockClient{cc}
+}
+
+func (c *blockClient) Get(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (*DataMessage, error) {
+	out := new(DataMessage)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Block/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockClient) Put(ctx context.Context, in *BlockPutRequest, opts ...grpc.CallOption) (*BlockStatResponse, error) {
+	out := new(BlockStatResponse)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Block/Put", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockClient) Stat(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (*BlockStatResponse, error) {
+	out := new(BlockStatResponse)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Block/Stat", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockClient) Rm(ctx context.Context, in *BlockRmRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Block/Rm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlockServer is the server API for Block service.
+// All implementations must embed UnimplementedBlockServer
+// for forward compatibility
+type BlockServer interface {
+	Get(context.Context, *PathMessage) (*DataMessage, error)
+	Put(context.Context, *BlockPutRequest) (*BlockStatResponse, error)
+	Stat(context.Context, *PathMessage) (*BlockStatResponse, error)
+	Rm(context.Context, *BlockRmRequest) (*Empty, error)
+	mustEmbedUnimplementedBlockServer()
+}
+
+// UnimplementedBlockServer must be embedded to have forward compatible implementations.
+type UnimplementedBlockServer struct {
+}
+
+func (UnimplementedBlockServer) Get(context.Context, *PathMessage) (*DataMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedBlockServer) Put(context.Context, *BlockPutRequest) (*BlockStatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Put not implemented")
+}
+func (UnimplementedBlockServer) Stat(context.Context, *PathMessage) (*BlockStatResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stat not implemented")
+}
+func (UnimplementedBlockServer) Rm(context.Context, *BlockRmRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rm not implemented")
+}
+func (UnimplementedBlockServer) mustEmbedUnimplementedBlockServer() {}
+
+// UnsafeBlockServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BlockServer will
+// result in compilation errors.
+type UnsafeBlockServer interface {
+	mustEmbedUnimplementedBlockServer()
+}
+
+func RegisterBlockServer(s grpc.ServiceRegistrar, srv BlockServer) {
+	s.RegisterService(&Block_ServiceDesc, srv)
+}
+
+func _Block_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PathMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Block/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockServer).Get(ctx, req.(*PathMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Block_Put_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockPutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockServer).Put(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Block/Put",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockServer).Put(ctx, req.(*BlockPutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Block_Stat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PathMessage)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockServer).Stat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Block/Stat",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockServer).Stat(ctx, req.(*PathMessage))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Block_Rm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockRmRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockServer).Rm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Block/Rm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockServer).Rm(ctx, req.(*BlockRmRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Block_ServiceDesc is the grpc.ServiceDesc for Block service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Block_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Block",
+	HandlerType: (*BlockServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Block_Get_Handler,
+		},
+		{
+			MethodName: "Put",
+			Handler:    _Block_Put_Handler,
+		},
+		{
+			MethodName: "Stat",
+			Handler:    _Block_Stat_Handler,
+		},
+		{
+			MethodName: "Rm",
+			Handler:    _Block_Rm_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "kubo.proto",
+}
+
+// UnixfsClient is the client API for Unixfs service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type UnixfsClient interface {
+	// The options are read from the first message, the file is the
+	// concatenation of the data of all the messages.
+	Add(ctx context.Context, opts ...grpc.CallOption) (Unixfs_AddClient, error)
+	// The file is streamed in chunks of up to 256KiB.
+	Cat(ctx context.Context, in *CatRequest, opts ...grpc.CallOption) (Unixfs_CatClient, error)
+	Ls(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (Unixfs_LsClient, error)
+}
+
+type unixfsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewUnixfsClient(cc grpc.ClientConnInterface) UnixfsClient {
+	return &unixfsClient{cc}
+}
+
+func (c *unixfsClient) Add(ctx context.Context, opts ...grpc.CallOption) (Unixfs_AddClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Unixfs_ServiceDesc.Streams[0], "/kubo.rpc.v1.Unixfs/Add", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &unixfsAddClient{stream}
+	return x, nil
+}
+
+type Unixfs_AddClient interface {
+	Send(*AddRequest) error
+	CloseAndRecv() (*CidResponse, error)
+	grpc.ClientStream
+}
+
+type unixfsAddClient struct {
+	grpc.ClientStream
+}
+
+func (x *unixfsAddClient) Send(m *AddRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *unixfsAddClient) CloseAndRecv() (*CidResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(CidResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *unixfsClient) Cat(ctx context.Context, in *CatRequest, opts ...grpc.CallOption) (Unixfs_CatClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Unixfs_ServiceDesc.Streams[1], "/kubo.rpc.v1.Unixfs/Cat", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &unixfsCatClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Unixfs_CatClient interface {
+	Recv() (*DataMessage, error)
+	grpc.ClientStream
+}
+
+type unixfsCatClient struct {
+	grpc.ClientStream
+}
+
+func (x *unixfsCatClient) Recv() (*DataMessage, error) {
+	m := new(DataMessage)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *unixfsClient) Ls(ctx context.Context, in *PathMessage, opts ...grpc.CallOption) (Unixfs_LsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Unixfs_ServiceDesc.Streams[2], "/kubo.rpc.v1.Unixfs/Ls", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &unixfsLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Unixfs_LsClient interface {
+	Recv() (*LsEntry, error)
+	grpc.ClientStream
+}
+
+type unixfsLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *unixfsLsClient) Recv() (*LsEntry, error) {
+	m := new(LsEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UnixfsServer is the server API for Unixfs service.
+// All implementations must embed UnimplementedUnixfsServer
+// for forward compatibility
+type UnixfsServer interface {
+	// The options are read from the first message, the file is the
+	// concatenation of the data of all the messages.
+	Add(Unixfs_AddServer) error
+	// The file is streamed in chunks of up to 256KiB.
+	Cat(*CatRequest, Unixfs_CatServer) error
+	Ls(*PathMessage, Unixfs_LsServer) error
+	mustEmbedUnimplementedUnixfsServer()
+}
+
+// UnimplementedUnixfsServer must be embedded to have forward compatible implementations.
+type UnimplementedUnixfsServer struct {
+}
+
+func (UnimplementedUnixfsServer) Add(Unixfs_AddServer) error {
+	return status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedUnixfsServer) Cat(*CatRequest, Unixfs_CatServer) error {
+	return status.Errorf(codes.Unimplemented, "method Cat not implemented")
+}
+func (UnimplementedUnixfsServer) Ls(*PathMessage, Unixfs_LsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Ls not implemented")
+}
+func (UnimplementedUnixfsServer) mustEmbedUnimplementedUnixfsServer() {}
+
+// UnsafeUnixfsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to UnixfsServer will
+// result in compilation errors.
+type UnsafeUnixfsServer interface {
+	mustEmbedUnimplementedUnixfsServer()
+}
+
+func RegisterUnixfsServer(s grpc.ServiceRegistrar, srv UnixfsServer) {
+	s.RegisterService(&Unixfs_ServiceDesc, srv)
+}
+
+func _Unixfs_Add_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UnixfsServer).Add(&unixfsAddServer{stream})
+}
+
+type Unixfs_AddServer interface {
+	SendAndClose(*CidResponse) error
+	Recv() (*AddRequest, error)
+	grpc.ServerStream
+}
+
+type unixfsAddServer struct {
+	grpc.ServerStream
+}
+
+func (x *unixfsAddServer) SendAndClose(m *CidResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *unixfsAddServer) Recv() (*AddRequest, error) {
+	m := new(AddRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Unixfs_Cat_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CatRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UnixfsServer).Cat(m, &unixfsCatServer{stream})
+}
+
+type Unixfs_CatServer interface {
+	Send(*DataMessage) error
+	grpc.ServerStream
+}
+
+type unixfsCatServer struct {
+	grpc.ServerStream
+}
+
+func (x *unixfsCatServer) Send(m *DataMessage) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Unixfs_Ls_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PathMessage)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(UnixfsServer).Ls(m, &unixfsLsServer{stream})
+}
+
+type Unixfs_LsServer interface {
+	Send(*LsEntry) error
+	grpc.ServerStream
+}
+
+type unixfsLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *unixfsLsServer) Send(m *LsEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Unixfs_ServiceDesc is the grpc.ServiceDesc for Unixfs service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Unixfs_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Unixfs",
+	HandlerType: (*UnixfsServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Add",
+			Handler:       _Unixfs_Add_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Cat",
+			Handler:       _Unixfs_Cat_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Ls",
+			Handler:       _Unixfs_Ls_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kubo.proto",
+}
+
+// PinClient is the client API for Pin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PinClient interface {
+	Add(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*Empty, error)
+	Rm(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*Empty, error)
+	Ls(ctx context.Context, in *PinLsRequest, opts ...grpc.CallOption) (Pin_LsClient, error)
+}
+
+type pinClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPinClient(cc grpc.ClientConnInterface) PinClient {
+	return &pinClient{cc}
+}
+
+func (c *pinClient) Add(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Pin/Add", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pinClient) Rm(ctx context.Context, in *PinRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Pin/Rm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *pinClient) Ls(ctx context.Context, in *PinLsRequest, opts ...grpc.CallOption) (Pin_LsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Pin_ServiceDesc.Streams[0], "/kubo.rpc.v1.Pin/Ls", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &pinLsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Pin_LsClient interface {
+	Recv() (*PinLsEntry, error)
+	grpc.ClientStream
+}
+
+type pinLsClient struct {
+	grpc.ClientStream
+}
+
+func (x *pinLsClient) Recv() (*PinLsEntry, error) {
+	m := new(PinLsEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PinServer is the server API for Pin service.
+// All implementations must embed UnimplementedPinServer
+// for forward compatibility
+type PinServer interface {
+	Add(context.Context, *PinRequest) (*Empty, error)
+	Rm(context.Context, *PinRequest) (*Empty, error)
+	Ls(*PinLsRequest, Pin_LsServer) error
+	mustEmbedUnimplementedPinServer()
+}
+
+// UnimplementedPinServer must be embedded to have forward compatible implementations.
+type UnimplementedPinServer struct {
+}
+
+func (UnimplementedPinServer) Add(context.Context, *PinRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Add not implemented")
+}
+func (UnimplementedPinServer) Rm(context.Context, *PinRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rm not implemented")
+}
+func (UnimplementedPinServer) Ls(*PinLsRequest, Pin_LsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Ls not implemented")
+}
+func (UnimplementedPinServer) mustEmbedUnimplementedPinServer() {}
+
+// UnsafePinServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PinServer will
+// result in compilation errors.
+type UnsafePinServer interface {
+	mustEmbedUnimplementedPinServer()
+}
+
+func RegisterPinServer(s grpc.ServiceRegistrar, srv PinServer) {
+	s.RegisterService(&Pin_ServiceDesc, srv)
+}
+
+func _Pin_Add_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PinServer).Add(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Pin/Add",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PinServer).Add(ctx, req.(*PinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pin_Rm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PinRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PinServer).Rm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Pin/Rm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PinServer).Rm(ctx, req.(*PinRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pin_Ls_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PinLsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PinServer).Ls(m, &pinLsServer{stream})
+}
+
+type Pin_LsServer interface {
+	Send(*PinLsEntry) error
+	grpc.ServerStream
+}
+
+type pinLsServer struct {
+	grpc.ServerStream
+}
+
+func (x *pinLsServer) Send(m *PinLsEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Pin_ServiceDesc is the grpc.ServiceDesc for Pin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Pin",
+	HandlerType: (*PinServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Add",
+			Handler:    _Pin_Add_Handler,
+		},
+		{
+			MethodName: "Rm",
+			Handler:    _Pin_Rm_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Ls",
+			Handler:       _Pin_Ls_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kubo.proto",
+}
+
+// NameClient is the client API for Name service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NameClient interface {
+	Publish(ctx context.Context, in *NamePublishRequest, opts ...grpc.CallOption) (*NamePublishResponse, error)
+	Resolve(ctx context.Context, in *NameResolveRequest, opts ...grpc.CallOption) (*PathMessage, error)
+}
+
+type nameClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNameClient(cc grpc.ClientConnInterface) NameClient {
+	return &nameClient{cc}
+}
+
+func (c *nameClient) Publish(ctx context.Context, in *NamePublishRequest, opts ...grpc.CallOption) (*NamePublishResponse, error) {
+	out := new(NamePublishResponse)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Name/Publish", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nameClient) Resolve(ctx context.Context, in *NameResolveRequest, opts ...grpc.CallOption) (*PathMessage, error) {
+	out := new(PathMessage)
+	err := c.cc.Invoke(ctx, "/kubo.rpc.v1.Name/Resolve", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NameServer is the server API for Name service.
+// All implementations must embed UnimplementedNameServer
+// for forward compatibility
+type NameServer interface {
+	Publish(context.Context, *NamePublishRequest) (*NamePublishResponse, error)
+	Resolve(context.Context, *NameResolveRequest) (*PathMessage, error)
+	mustEmbedUnimplementedNameServer()
+}
+
+// UnimplementedNameServer must be embedded to have forward compatible implementations.
+type UnimplementedNameServer struct {
+}
+
+func (UnimplementedNameServer) Publish(context.Context, *NamePublishRequest) (*NamePublishResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
+}
+func (UnimplementedNameServer) Resolve(context.Context, *NameResolveRequest) (*PathMessage, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
+}
+func (UnimplementedNameServer) mustEmbedUnimplementedNameServer() {}
+
+// UnsafeNameServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NameServer will
+// result in compilation errors.
+type UnsafeNameServer interface {
+	mustEmbedUnimplementedNameServer()
+}
+
+func RegisterNameServer(s grpc.ServiceRegistrar, srv NameServer) {
+	s.RegisterService(&Name_ServiceDesc, srv)
+}
+
+func _Name_Publish_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NamePublishRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServer).Publish(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Name/Publish",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServer).Publish(ctx, req.(*NamePublishRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Name_Resolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NameResolveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServer).Resolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubo.rpc.v1.Name/Resolve",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServer).Resolve(ctx, req.(*NameResolveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Name_ServiceDesc is the grpc.ServiceDesc for Name service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Name_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Name",
+	HandlerType: (*NameServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Publish",
+			Handler:    _Name_Publish_Handler,
+		},
+		{
+			MethodName: "Resolve",
+			Handler:    _Name_Resolve_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "kubo.proto",
+}
+
+// RoutingClient is the client API for Routing service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RoutingClient interface {
+	// count is the number of providers to find, 20 if 0.
+	FindProviders(ctx context.Context, in *FindProvidersRequest, opts ...grpc.CallOption) (Routing_FindProvidersClient, error)
+}
+
+type routingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRoutingClient(cc grpc.ClientConnInterface) RoutingClient {
+	return &routingClient{cc}
+}
+
+func (c *routingClient) FindProviders(ctx context.Context, in *FindProvidersRequest, opts ...grpc.CallOption) (Routing_FindProvidersClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Routing_ServiceDesc.Streams[0], "/kubo.rpc.v1.Routing/FindProviders", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &routingFindProvidersClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Routing_FindProvidersClient interface {
+	Recv() (*AddrInfo, error)
+	grpc.ClientStream
+}
+
+type routingFindProvidersClient struct {
+	grpc.ClientStream
+}
+
+func (x *routingFindProvidersClient) Recv() (*AddrInfo, error) {
+	m := new(AddrInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RoutingServer is the server API for Routing service.
+// All implementations must embed UnimplementedRoutingServer
+// for forward compatibility
+type RoutingServer interface {
+	// count is the number of providers to find, 20 if 0.
+	FindProviders(*FindProvidersRequest, Routing_FindProvidersServer) error
+	mustEmbedUnimplementedRoutingServer()
+}
+
+// UnimplementedRoutingServer must be embedded to have forward compatible implementations.
+type UnimplementedRoutingServer struct {
+}
+
+func (UnimplementedRoutingServer) FindProviders(*FindProvidersRequest, Routing_FindProvidersServer) error {
+	return status.Errorf(codes.Unimplemented, "method FindProviders not implemented")
+}
+func (UnimplementedRoutingServer) mustEmbedUnimplementedRoutingServer() {}
+
+// UnsafeRoutingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RoutingServer will
+// result in compilation errors.
+type UnsafeRoutingServer interface {
+	mustEmbedUnimplementedRoutingServer()
+}
+
+func RegisterRoutingServer(s grpc.ServiceRegistrar, srv RoutingServer) {
+	s.RegisterService(&Routing_ServiceDesc, srv)
+}
+
+func _Routing_FindProviders_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(FindProvidersRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RoutingServer).FindProviders(m, &routingFindProvidersServer{stream})
+}
+
+type Routing_FindProvidersServer interface {
+	Send(*AddrInfo) error
+	grpc.ServerStream
+}
+
+type routingFindProvidersServer struct {
+	grpc.ServerStream
+}
+
+func (x *routingFindProvidersServer) Send(m *AddrInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Routing_ServiceDesc is the grpc.ServiceDesc for Routing service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Routing_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubo.rpc.v1.Routing",
+	HandlerType: (*RoutingServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "FindProviders",
+			Handler:       _Routing_FindProviders_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "kubo.proto",
+}
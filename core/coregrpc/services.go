@@ -0,0 +1,313 @@
+package coregrpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/ipfs/go-libipfs/files"
+	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	path "github.com/ipfs/interface-go-ipfs-core/path"
+	version "github.com/ipfs/kubo"
+	"github.com/ipfs/kubo/core/coregrpc/pb"
+)
+
+// chunkSize is the size of the data messages streamed by Cat.
+const chunkSize = 256 << 10
+
+// parsePath parses a path of a request.
+func parsePath(p string) (path.Path, error) {
+	pth := path.New(p)
+	if err := pth.IsValid(); err != nil {
+		return nil, invalidArgument{err}
+	}
+	return pth, nil
+}
+
+type nodeServer struct {
+	pb.UnimplementedNodeServer
+	*server
+}
+
+func (s *nodeServer) ID(ctx context.Context, _ *pb.Empty) (*pb.IDResponse, error) {
+	out := &pb.IDResponse{Id: s.node.Identity.String()}
+	if s.node.PeerHost != nil {
+		for _, a := range s.node.PeerHost.Addrs() {
+			out.Addresses = append(out.Addresses, a.String())
+		}
+	}
+	return out, nil
+}
+
+func (s *nodeServer) Version(ctx context.Context, _ *pb.Empty) (*pb.VersionResponse, error) {
+	return &pb.VersionResponse{Version: version.CurrentVersionNumber, Commit: version.CurrentCommit}, nil
+}
+
+type blockServer struct {
+	pb.UnimplementedBlockServer
+	*server
+}
+
+func (s *blockServer) Get(ctx context.Context, req *pb.PathMessage) (*pb.DataMessage, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := s.api.Block().Get(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.DataMessage{Data: data}, nil
+}
+
+func (s *blockServer) Put(ctx context.Context, req *pb.BlockPutRequest) (*pb.BlockStatResponse, error) {
+	opts := []options.BlockPutOption{options.Block.Pin(req.Pin)}
+	if req.Codec != "" {
+		opts = append(opts, options.Block.CidCodec(req.Codec))
+	}
+	st, err := s.api.Block().Put(ctx, bytes.NewReader(req.Data), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BlockStatResponse{Cid: st.Path().Cid().String(), Size: int64(st.Size())}, nil
+}
+
+func (s *blockServer) Stat(ctx context.Context, req *pb.PathMessage) (*pb.BlockStatResponse, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.api.Block().Stat(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.BlockStatResponse{Cid: st.Path().Cid().String(), Size: int64(st.Size())}, nil
+}
+
+func (s *blockServer) Rm(ctx context.Context, req *pb.BlockRmRequest) (*pb.Empty, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, s.api.Block().Rm(ctx, p, options.Block.Force(req.Force))
+}
+
+type unixfsServer struct {
+	pb.UnimplementedUnixfsServer
+	*server
+}
+
+func (s *unixfsServer) Add(stream pb.Unixfs_AddServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	opts := []options.UnixfsAddOption{options.Unixfs.Pin(first.Pin)}
+	if first.CidVersion != nil {
+		opts = append(opts, options.Unixfs.CidVersion(int(*first.CidVersion)))
+	}
+	if first.RawLeaves != nil {
+		opts = append(opts, options.Unixfs.RawLeaves(*first.RawLeaves))
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		if _, err := pw.Write(first.Data); err != nil {
+			return
+		}
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := pw.Write(msg.Data); err != nil {
+				return
+			}
+		}
+	}()
+	defer pr.Close()
+
+	p, err := s.api.Unixfs().Add(stream.Context(), files.NewReaderFile(pr), opts...)
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(&pb.CidResponse{Cid: p.Cid().String()})
+}
+
+func (s *unixfsServer) Cat(req *pb.CatRequest, stream pb.Unixfs_CatServer) error {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return err
+	}
+	nd, err := s.api.Unixfs().Get(stream.Context(), p)
+	if err != nil {
+		return err
+	}
+	defer nd.Close()
+	f, ok := nd.(files.File)
+	if !ok {
+		return invalidArgument{coreiface.ErrNotFile}
+	}
+	if req.Offset > 0 {
+		if _, err := f.Seek(req.Offset, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	var src io.Reader = f
+	if req.Length > 0 {
+		src = io.LimitReader(f, req.Length)
+	}
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if err := stream.Send(&pb.DataMessage{Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *unixfsServer) Ls(req *pb.PathMessage, stream pb.Unixfs_LsServer) error {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return err
+	}
+	entries, err := s.api.Unixfs().Ls(stream.Context(), p)
+	if err != nil {
+		return err
+	}
+	for e := range entries {
+		if e.Err != nil {
+			return e.Err
+		}
+		out := &pb.LsEntry{Name: e.Name, Cid: e.Cid.String(), Size: e.Size, Type: e.Type.String(), Target: e.Target}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pinServer struct {
+	pb.UnimplementedPinServer
+	*server
+}
+
+func (s *pinServer) Add(ctx context.Context, req *pb.PinRequest) (*pb.Empty, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, s.api.Pin().Add(ctx, p, options.Pin.Recursive(req.Recursive))
+}
+
+func (s *pinServer) Rm(ctx context.Context, req *pb.PinRequest) (*pb.Empty, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.Empty{}, s.api.Pin().Rm(ctx, p, options.Pin.RmRecursive(req.Recursive))
+}
+
+func (s *pinServer) Ls(req *pb.PinLsRequest, stream pb.Pin_LsServer) error {
+	typ := req.Type
+	if typ == "" {
+		typ = "all"
+	}
+	opt, err := options.Pin.Ls.Type(typ)
+	if err != nil {
+		return invalidArgument{err}
+	}
+	pins, err := s.api.Pin().Ls(stream.Context(), opt)
+	if err != nil {
+		return err
+	}
+	for p := range pins {
+		if err := p.Err(); err != nil {
+			return err
+		}
+		if err := stream.Send(&pb.PinLsEntry{Cid: p.Path().Cid().String(), Type: p.Type()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type nameServer struct {
+	pb.UnimplementedNameServer
+	*server
+}
+
+func (s *nameServer) Publish(ctx context.Context, req *pb.NamePublishRequest) (*pb.NamePublishResponse, error) {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return nil, err
+	}
+	opts := []options.NamePublishOption{options.Name.AllowOffline(req.AllowOffline)}
+	if req.Key != "" {
+		opts = append(opts, options.Name.Key(req.Key))
+	}
+	if req.Lifetime > 0 {
+		opts = append(opts, options.Name.ValidTime(time.Duration(req.Lifetime)*time.Second))
+	}
+	entry, err := s.api.Name().Publish(ctx, p, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.NamePublishResponse{Name: entry.Name(), Value: entry.Value().String()}, nil
+}
+
+func (s *nameServer) Resolve(ctx context.Context, req *pb.NameResolveRequest) (*pb.PathMessage, error) {
+	p, err := s.api.Name().Resolve(ctx, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PathMessage{Path: p.String()}, nil
+}
+
+type routingServer struct {
+	pb.UnimplementedRoutingServer
+	*server
+}
+
+func (s *routingServer) FindProviders(req *pb.FindProvidersRequest, stream pb.Routing_FindProvidersServer) error {
+	p, err := parsePath(req.Path)
+	if err != nil {
+		return err
+	}
+	count := int(req.Count)
+	if count <= 0 {
+		count = 20
+	}
+	provs, err := s.api.Dht().FindProviders(stream.Context(), p, options.Dht.NumProviders(count))
+	if err != nil {
+		return err
+	}
+	for ai := range provs {
+		out := &pb.AddrInfo{Id: ai.ID.String()}
+		for _, a := range ai.Addrs {
+			out.Addrs = append(out.Addrs, a.String())
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
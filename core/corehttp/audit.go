@@ -0,0 +1,210 @@
+package corehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/auditlog"
+)
+
+// auditedCommands are the RPC commands changing the node, by path, which are
+// recorded in the audit log. The predicate, when set, tells whether a call
+// with the arguments changes the node.
+var auditedCommands = map[string]func(args []string) bool{
+	"/add": nil,
+
+	"/dag/import": nil,
+	"/dag/put":    nil,
+	"/block/put":  nil,
+	"/block/rm":   nil,
+	// the resumable uploads of DagUploadOption
+	dagUploadCommand: nil,
+
+	"/pin/add":                nil,
+	"/pin/rm":                 nil,
+	"/pin/update":             nil,
+	"/pin/import":             nil,
+	"/pin/label/set":          nil,
+	"/pin/label/rm":           nil,
+	"/pin/remote/add":         nil,
+	"/pin/remote/rm":          nil,
+	"/pin/remote/service/add": nil,
+	"/pin/remote/service/rm":  nil,
+
+	// 'ipfs config <key>' reads the key
+	"/config":               func(args []string) bool { return len(args) > 1 },
+	"/config/replace":       nil,
+	"/config/profile/apply": nil,
+
+	"/key/gen":    nil,
+	"/key/import": nil,
+	"/key/rename": nil,
+	"/key/rm":     nil,
+	"/key/rotate": nil,
+
+	"/files/write":    nil,
+	"/files/patch":    nil,
+	"/files/mkdir":    nil,
+	"/files/mv":       nil,
+	"/files/cp":       nil,
+	"/files/rm":       nil,
+	"/files/chcid":    nil,
+	"/files/truncate": nil,
+	"/files/sync":     nil,
+	"/files/flush":    nil,
+
+	"/space/create": nil,
+	"/space/quota":  nil,
+	"/space/rm":     nil,
+
+	"/name/publish": nil,
+	"/repo/gc":      nil,
+	"/repo/backup":  nil,
+
+	"/bootstrap/add":         nil,
+	"/bootstrap/add/default": nil,
+	"/bootstrap/rm":          nil,
+	"/bootstrap/rm/all":      nil,
+	"/swarm/peering/add":     nil,
+	"/swarm/peering/rm":      nil,
+	"/swarm/filters/add":     nil,
+	"/swarm/filters/rm":      nil,
+
+	"/routing/put":     nil,
+	"/routing/provide": nil,
+	"/dht/put":         nil,
+	"/dht/provide":     nil,
+
+	"/admin/exec": nil,
+}
+
+// dagUploadCommand is the command the chunks of the resumable uploads are
+// recorded as, with the ID of the upload as argument.
+const dagUploadCommand = "/dag/import/upload"
+
+// redacted replaces the secrets in the arguments recorded.
+const redacted = "<redacted>"
+
+// apiAuditEntry is an entry of the audit log, for each call of
+// auditedCommands.
+type apiAuditEntry struct {
+	Time    time.Time
+	Command string
+	// Method is the HTTP method of the call, which tells the chunks of the
+	// uploads from their cancellation.
+	Method    string
+	Arguments []string            `json:",omitempty"`
+	Options   map[string][]string `json:",omitempty"`
+	// Identity identifies the credentials of the caller, see callerIdentity.
+	Identity string `json:",omitempty"`
+	Remote   string
+	Status   int
+	Duration string
+}
+
+// APIAuditLog returns the audit log of the RPC calls configured by a, the path
+// being relative to the repo at repoRoot.
+func APIAuditLog(a *config.APIAudit, repoRoot string) (*auditlog.Log, error) {
+	maxSize, err := humanize.ParseBytes(a.MaxSize.WithDefault(config.DefaultAPIAuditMaxSize))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API.Audit.MaxSize: %w", err)
+	}
+	path := a.Path.WithDefault(config.DefaultAPIAuditPath)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoRoot, path)
+	}
+	return &auditlog.Log{
+		Path:     path,
+		MaxSize:  int64(maxSize),
+		MaxFiles: int(a.MaxFiles.WithDefault(config.DefaultAPIAuditMaxFiles)),
+	}, nil
+}
+
+// withAuditLog records the calls of auditedCommands served by next in l,
+// once they're done. The bodies of the requests, such as the files added,
+// aren't recorded.
+func withAuditLog(l *auditlog.Log, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		command := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, APIPath), "/")
+		query := r.URL.Query()
+		args := query["arg"]
+		if id := strings.TrimPrefix(command, dagUploadCommand+"/"); id != command {
+			command, args = dagUploadCommand, []string{id}
+		}
+		changes, audited := auditedCommands[command]
+		// the HEAD requests of the uploads, and the CORS preflight
+		// requests, change nothing
+		readOnly := r.Method == http.MethodHead || r.Method == http.MethodOptions
+		if !audited || readOnly || (changes != nil && !changes(args)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		query.Del("arg")
+		e := apiAuditEntry{
+			Time:      start.UTC(),
+			Command:   command,
+			Method:    r.Method,
+			Arguments: redactArgs(command, args),
+			Identity:  callerIdentity(r),
+			Remote:    r.RemoteAddr,
+			Status:    sw.status,
+			Duration:  time.Since(start).String(),
+		}
+		if len(query) > 0 {
+			e.Options = query
+		}
+		if err := l.Append(e); err != nil {
+			log.Errorw("failed to write the API audit log", "command", command, "error", err)
+		}
+	})
+}
+
+// redactArgs returns args without the secrets they hold: the values of the
+// config keys holding config.SecretSelectors, and the API keys of the remote
+// pinning services.
+func redactArgs(command string, args []string) []string {
+	args = append([]string(nil), args...)
+	switch command {
+	case "/config":
+		if len(args) > 1 && config.IsSecretKey(args[0]) {
+			args[1] = redacted
+		}
+	case "/pin/remote/service/add":
+		// name, endpoint, key
+		if len(args) > 2 {
+			args[2] = redacted
+		}
+	}
+	return args
+}
+
+// callerIdentity identifies the caller of r by the credentials of its
+// Authorization header: the user of the basic authentication, or a
+// fingerprint of the token, never the token itself.
+func callerIdentity(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return ""
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		return "basic:" + user
+	}
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok {
+		scheme, token = "token", auth
+	}
+	h := sha256.Sum256([]byte(token))
+	return strings.ToLower(scheme) + ":" + hex.EncodeToString(h[:8])
+}
@@ -0,0 +1,174 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	datastore "github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/auditlog"
+	repo "github.com/ipfs/kubo/repo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	l := &auditlog.Log{Path: filepath.Join(t.TempDir(), "audit.log")}
+	h := withAuditLog(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, u := range []string{
+		APIPath + "/config?arg=Gateway.NoFetch",
+		APIPath + "/cat?arg=/ipfs/bafkqaaa",
+		APIPath + "/pin/add?arg=/ipfs/bafkqaaa&recursive=false",
+		APIPath + "/config?arg=Pinning.RemoteServices.svc.API.Key&arg=secret",
+		APIPath + "/config?arg=API.Authorizations.user.AuthSecret&arg=bearer:secret",
+		APIPath + "/config?arg=api.authorizations.user.AUTHSECRET&arg=bearer:secret",
+		APIPath + "/config?arg=Webhooks.Endpoints&arg=" + url.QueryEscape(`[{"URL":"https://hooks.example.com","Headers":{"Authorization":"secret"}}]`) + "&json=true",
+	} {
+		req := httptest.NewRequest(http.MethodPost, u, nil)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	b, err := os.ReadFile(l.Path)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "secret")
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	// the reads aren't recorded
+	require.Len(t, lines, 5)
+
+	var e apiAuditEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &e))
+	require.Equal(t, "/pin/add", e.Command)
+	require.Equal(t, []string{"/ipfs/bafkqaaa"}, e.Arguments)
+	require.Equal(t, map[string][]string{"recursive": {"false"}}, e.Options)
+	require.Equal(t, http.StatusOK, e.Status)
+	require.True(t, strings.HasPrefix(e.Identity, "bearer:"))
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	require.Equal(t, []string{"Pinning.RemoteServices.svc.API.Key", redacted}, e.Arguments)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &e))
+	require.Equal(t, []string{"API.Authorizations.user.AuthSecret", redacted}, e.Arguments)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &e))
+	require.Equal(t, []string{"api.authorizations.user.AUTHSECRET", redacted}, e.Arguments)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[4]), &e))
+	require.Equal(t, []string{"Webhooks.Endpoints", redacted}, e.Arguments)
+}
+
+func TestAuditLogCommands(t *testing.T) {
+	l := &auditlog.Log{Path: filepath.Join(t.TempDir(), "audit.log")}
+	h := withAuditLog(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, c := range []struct {
+		method, url string
+	}{
+		{http.MethodPost, APIPath + "/dag/import"},
+		{http.MethodPost, APIPath + "/dag/put"},
+		{http.MethodPost, APIPath + "/block/put"},
+		{http.MethodPost, APIPath + "/block/rm?arg=bafkqaaa"},
+		{http.MethodPost, APIPath + "/name/publish?arg=/ipfs/bafkqaaa"},
+		{http.MethodPost, APIPath + "/repo/gc"},
+		{http.MethodPost, APIPath + "/swarm/peering/add?arg=/ip4/1.2.3.4/tcp/4001/p2p/12D3KooW"},
+		{http.MethodPost, APIPath + "/bootstrap/rm/all"},
+		{http.MethodPost, APIPath + "/routing/provide?arg=bafkqaaa"},
+		{http.MethodPost, APIPath + "/files/flush"},
+		{http.MethodHead, DagUploadPath + "upload-1"},
+		{http.MethodPut, DagUploadPath + "upload-1"},
+		{http.MethodDelete, DagUploadPath + "upload-1"},
+		{http.MethodPost, APIPath + "/block/get?arg=bafkqaaa"},
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(c.method, c.url, nil))
+	}
+
+	b, err := os.ReadFile(l.Path)
+	require.NoError(t, err)
+	var commands []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var e apiAuditEntry
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		commands = append(commands, e.Method+" "+e.Command)
+		if e.Command == dagUploadCommand {
+			require.Equal(t, []string{"upload-1"}, e.Arguments)
+		}
+	}
+	// the reads, and the offsets of the uploads, aren't recorded
+	require.Equal(t, []string{
+		"POST /dag/import",
+		"POST /dag/put",
+		"POST /block/put",
+		"POST /block/rm",
+		"POST /name/publish",
+		"POST /repo/gc",
+		"POST /swarm/peering/add",
+		"POST /bootstrap/rm/all",
+		"POST /routing/provide",
+		"POST /files/flush",
+		"PUT /dag/import/upload",
+		"DELETE /dag/import/upload",
+	}, commands)
+}
+
+// newAPITestServer serves the options behind apiGuardOption, for a node with
+// the API config api.
+func newAPITestServer(t *testing.T, api config.API, options ...ServeOption) *httptest.Server {
+	r := &repo.Mock{
+		C: config.Config{
+			Identity: config.Identity{
+				PeerID: "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe", // required by offline node
+			},
+			API: api,
+		},
+		D: syncds.MutexWrap(datastore.NewMapDatastore()),
+	}
+	n, err := core.NewNode(context.Background(), &core.BuildCfg{Repo: r})
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close() })
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	t.Cleanup(func() { ts.Close() })
+	dh.Handler, err = makeHandler(n, ts.Listener, append([]ServeOption{apiGuardOption(t.TempDir())}, options...)...)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestAuditLogDagUpload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ts := newAPITestServer(t, config.API{
+		Audit: &config.APIAudit{Enabled: config.True, Path: config.NewOptionalString(path)},
+	}, DagUploadOption(t.TempDir()))
+
+	// the handlers under the API path registered after the commands are
+	// audited as well
+	req, err := http.NewRequest(http.MethodPut, ts.URL+DagUploadPath+"upload-1", bytes.NewReader([]byte("car")))
+	require.NoError(t, err)
+	req.Header.Set("Content-Range", "bytes 0-2/10")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var e apiAuditEntry
+	require.NoError(t, json.Unmarshal(bytes.TrimSpace(b), &e))
+	require.Equal(t, dagUploadCommand, e.Command)
+	require.Equal(t, []string{"upload-1"}, e.Arguments)
+	require.Equal(t, http.StatusAccepted, e.Status)
+}
@@ -0,0 +1,180 @@
+package corehttp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/spaces"
+)
+
+// spaceQueryParam is the option of the commands run in a space, see
+// cmdenv.OptionSpace.
+const spaceQueryParam = "space"
+
+// tenantCommands are the RPC commands the users scoped to a space can call:
+// the ones reading content by CID, and the ones changing the pins, the MFS
+// root and the keys of the space. The commands under "/files/" are allowed as
+// well.
+var tenantCommands = map[string]bool{
+	"/add":          true,
+	"/cat":          true,
+	"/get":          true,
+	"/ls":           true,
+	"/refs":         true,
+	"/resolve":      true,
+	"/block/get":    true,
+	"/block/stat":   true,
+	"/dag/get":      true,
+	"/dag/resolve":  true,
+	"/pin/add":      true,
+	"/pin/rm":       true,
+	"/pin/ls":       true,
+	"/key/gen":      true,
+	"/key/list":     true,
+	"/key/rename":   true,
+	"/key/rm":       true,
+	"/name/publish": true,
+	"/name/resolve": true,
+	"/id":           true,
+	"/version":      true,
+}
+
+// tenantForbiddenOptions are the options of tenantCommands the users scoped
+// to a space can't pass: the ones reaching the filesystem of the node or the
+// keys outside the space.
+var tenantForbiddenOptions = map[string][]string{
+	"/add": {"nocopy", "encrypt"},
+	"/cat": {"decrypt"},
+}
+
+func tenantCommand(command string) bool {
+	if strings.HasPrefix(command, "/files/") {
+		// syncs a directory of the node
+		return command != "/files/sync"
+	}
+	return tenantCommands[command]
+}
+
+// rpcAuthUser is a user of the RPC API, see config.API.Authorizations.
+type rpcAuthUser struct {
+	name          string
+	authorization string // the expected Authorization header
+	allowedPaths  []string
+	space         string
+}
+
+// allowed tells whether the user can call the RPC path p.
+func (u *rpcAuthUser) allowed(p string) bool {
+	if len(u.allowedPaths) == 0 {
+		return true
+	}
+	for _, prefix := range u.allowedPaths {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcAuthUsers returns the users of auths, sorted by name.
+func rpcAuthUsers(auths map[string]*config.RPCAuthScope) ([]rpcAuthUser, error) {
+	users := make([]rpcAuthUser, 0, len(auths))
+	for name, a := range auths {
+		if a == nil || a.AuthSecret == "" {
+			return nil, fmt.Errorf("API.Authorizations.%s: AuthSecret is not set", name)
+		}
+		u := rpcAuthUser{
+			name:          name,
+			authorization: config.ConvertAuthSecret(a.AuthSecret),
+			allowedPaths:  a.AllowedPaths,
+			space:         a.Space.WithDefault(""),
+		}
+		if u.space != "" {
+			if err := spaces.ValidateName(u.space); err != nil {
+				return nil, fmt.Errorf("API.Authorizations.%s: %w", name, err)
+			}
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].name < users[j].name })
+	return users, nil
+}
+
+// ensureTenantSpaces creates the spaces of the users scoped to one, when
+// missing.
+func ensureTenantSpaces(ctx context.Context, m *spaces.Manager, users []rpcAuthUser) error {
+	for _, u := range users {
+		if u.space == "" {
+			continue
+		}
+		_, err := m.Get(ctx, u.space)
+		if errors.Is(err, spaces.ErrNotFound) {
+			_, err = m.Create(ctx, u.space, spaces.NoLimit)
+		}
+		if err != nil {
+			return fmt.Errorf("space of API.Authorizations.%s: %w", u.name, err)
+		}
+	}
+	return nil
+}
+
+// withAuthorizations only lets the calls authenticated as one of users
+// through to next. The users scoped to a space can only call tenantCommands,
+// which run in their space.
+func withAuthorizations(users []rpcAuthUser, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the CORS preflight requests carry no credentials
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		var user *rpcAuthUser
+		for i := range users {
+			if subtle.ConstantTimeCompare([]byte(auth), []byte(users[i].authorization)) == 1 {
+				user = &users[i]
+				break
+			}
+		}
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kubo", charset="UTF-8"`)
+			http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !user.allowed(r.URL.Path) {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+
+		if user.space != "" {
+			command := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, APIPath), "/")
+			if !tenantCommand(command) {
+				http.Error(w, fmt.Sprintf("403 Forbidden: %s can not be called in space %q", command, user.space), http.StatusForbidden)
+				return
+			}
+			query := r.URL.Query()
+			for _, opt := range tenantForbiddenOptions[command] {
+				if query.Has(opt) {
+					http.Error(w, fmt.Sprintf("403 Forbidden: --%s can not be passed in space %q", opt, user.space), http.StatusForbidden)
+					return
+				}
+			}
+			if s := query.Get(spaceQueryParam); s != "" && s != user.space {
+				http.Error(w, fmt.Sprintf("403 Forbidden: not allowed in space %q", s), http.StatusForbidden)
+				return
+			}
+			query.Set(spaceQueryParam, user.space)
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = query.Encode()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
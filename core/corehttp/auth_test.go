@@ -0,0 +1,107 @@
+package corehttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizations(t *testing.T) {
+	users, err := rpcAuthUsers(map[string]*config.RPCAuthScope{
+		"admin": {AuthSecret: "bearer:admin-token"},
+		"ro": {
+			AuthSecret:   "ro:password",
+			AllowedPaths: []string{APIPath + "/cat", APIPath + "/pin/ls"},
+		},
+		"alice": {
+			AuthSecret: "bearer:alice-token",
+			Space:      config.NewOptionalString("alice"),
+		},
+	})
+	require.NoError(t, err)
+
+	var query string
+	h := withAuthorizations(users, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, c := range []struct {
+		secret string
+		url    string
+		status int
+		query  string
+	}{
+		{"", APIPath + "/cat?arg=/ipfs/bafkqaaa", http.StatusUnauthorized, ""},
+		{"bearer:wrong", APIPath + "/cat?arg=/ipfs/bafkqaaa", http.StatusUnauthorized, ""},
+		{"bearer:admin-token", APIPath + "/config?arg=Gateway", http.StatusOK, "arg=Gateway"},
+		{"ro:password", APIPath + "/cat?arg=/ipfs/bafkqaaa", http.StatusOK, "arg=/ipfs/bafkqaaa"},
+		{"ro:password", APIPath + "/pin/add?arg=/ipfs/bafkqaaa", http.StatusForbidden, ""},
+		{"bearer:alice-token", APIPath + "/pin/add?arg=/ipfs/bafkqaaa", http.StatusOK, "arg=%2Fipfs%2Fbafkqaaa&space=alice"},
+		{"bearer:alice-token", APIPath + "/files/ls", http.StatusOK, "space=alice"},
+		{"bearer:alice-token", APIPath + "/pin/ls?space=alice", http.StatusOK, "space=alice"},
+		{"bearer:alice-token", APIPath + "/pin/ls?space=bob", http.StatusForbidden, ""},
+		{"bearer:alice-token", APIPath + "/files/sync?arg=/tmp", http.StatusForbidden, ""},
+		{"bearer:alice-token", APIPath + "/config?arg=Gateway", http.StatusForbidden, ""},
+		{"bearer:alice-token", APIPath + "/add?nocopy=true", http.StatusForbidden, ""},
+	} {
+		query = ""
+		req := httptest.NewRequest(http.MethodPost, c.url, nil)
+		if c.secret != "" {
+			req.Header.Set("Authorization", config.ConvertAuthSecret(c.secret))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		require.Equal(t, c.status, rec.Code, "%s %s", c.secret, c.url)
+		require.Equal(t, c.query, query, "%s %s", c.secret, c.url)
+	}
+}
+
+func TestAuthorizationsInvalid(t *testing.T) {
+	_, err := rpcAuthUsers(map[string]*config.RPCAuthScope{"admin": {}})
+	require.Error(t, err)
+
+	_, err = rpcAuthUsers(map[string]*config.RPCAuthScope{
+		"alice": {AuthSecret: "bearer:token", Space: config.NewOptionalString("a/b")},
+	})
+	require.Error(t, err)
+}
+
+func TestAuthorizationsDagUpload(t *testing.T) {
+	ts := newAPITestServer(t, config.API{
+		Authorizations: map[string]*config.RPCAuthScope{
+			"admin": {AuthSecret: "bearer:admin-token"},
+			"alice": {
+				AuthSecret: "bearer:alice-token",
+				Space:      config.NewOptionalString("alice"),
+			},
+		},
+	}, DagUploadOption(t.TempDir()))
+
+	// the resumable uploads are authorized like the commands, and can't be
+	// made in a space since they pin outside of it
+	for _, c := range []struct {
+		secret string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"bearer:wrong", http.StatusUnauthorized},
+		{"bearer:alice-token", http.StatusForbidden},
+		{"bearer:admin-token", http.StatusAccepted},
+	} {
+		req, err := http.NewRequest(http.MethodPut, ts.URL+DagUploadPath+"upload-1", bytes.NewReader([]byte("car")))
+		require.NoError(t, err)
+		req.Header.Set("Content-Range", "bytes 0-2/10")
+		if c.secret != "" {
+			req.Header.Set("Authorization", config.ConvertAuthSecret(c.secret))
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		require.Equal(t, c.status, res.StatusCode, c.secret)
+	}
+}
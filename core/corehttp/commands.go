@@ -123,7 +123,7 @@ func patchCORSVars(c *cmdsHttp.ServerConfig, addr net.Addr) {
 	c.SetAllowedOrigins(newOrigins...)
 }
 
-func commandsOption(cctx oldcmds.Context, command *cmds.Command, allowGet bool) ServeOption {
+func commandsOption(cctx oldcmds.Context, command *cmds.Command, allowGet bool, async bool) ServeOption {
 	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
 
 		cfg := cmdsHttp.NewServerConfig()
@@ -145,22 +145,79 @@ func commandsOption(cctx oldcmds.Context, command *cmds.Command, allowGet bool)
 		addCORSDefaults(cfg)
 		patchCORSVars(cfg, l.Addr())
 
-		cmdHandler := cmdsHttp.NewHandler(&cctx, command, cfg)
+		cctx.RPC = true
+		var cmdHandler http.Handler = cmdsHttp.NewHandler(&cctx, command, cfg)
+		if b := rcfg.API.FetchBudget; b != nil {
+			opts, err := fetchBudgetOptions(b)
+			if err != nil {
+				return nil, fmt.Errorf("API: %w", err)
+			}
+			cmdHandler = withFetchBudget("api", opts, cmdHandler)
+		}
+		if async && n.Jobs != nil {
+			cmdHandler = &asyncJobHandler{m: n.Jobs, next: cmdHandler}
+		}
+		mux, err = apiGuardOption(cctx.ConfigRoot)(n, l, mux)
+		if err != nil {
+			return nil, err
+		}
 		mux.Handle(APIPath+"/", cmdHandler)
 		return mux, nil
 	}
 }
 
+// apiGuardOption applies the API.Authorizations and the API.Audit log of the
+// config to all the handlers under APIPath registered on the returned mux: the
+// commands, and the resumable uploads of DagUploadOption. The repo of the node
+// is at repoRoot.
+func apiGuardOption(repoRoot string) ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		rcfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		childMux := http.NewServeMux()
+		var apiHandler http.Handler = childMux
+		if a := rcfg.API.Audit; a != nil && a.Enabled.WithDefault(false) {
+			l, err := APIAuditLog(a, repoRoot)
+			if err != nil {
+				return nil, err
+			}
+			apiHandler = withAuditLog(l, apiHandler)
+		}
+		if len(rcfg.API.Authorizations) > 0 {
+			users, err := rpcAuthUsers(rcfg.API.Authorizations)
+			if err != nil {
+				return nil, err
+			}
+			if err := ensureTenantSpaces(n.Context(), n.Spaces, users); err != nil {
+				return nil, err
+			}
+			apiHandler = withAuthorizations(users, apiHandler)
+		}
+		mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, APIPath+"/") {
+				apiHandler.ServeHTTP(w, r)
+				return
+			}
+			childMux.ServeHTTP(w, r)
+		}))
+		return childMux, nil
+	}
+}
+
 // CommandsOption constructs a ServerOption for hooking the commands into the
-// HTTP server. It will NOT allow GET requests.
+// HTTP server. It will NOT allow GET requests. Some of the commands can run as
+// background jobs, see asyncCommands.
 func CommandsOption(cctx oldcmds.Context) ServeOption {
-	return commandsOption(cctx, corecommands.Root, false)
+	return commandsOption(cctx, corecommands.Root, false, true)
 }
 
 // CommandsROOption constructs a ServerOption for hooking the read-only commands
 // into the HTTP server. It will allow GET requests.
 func CommandsROOption(cctx oldcmds.Context) ServeOption {
-	return commandsOption(cctx, corecommands.RootRO, true)
+	return commandsOption(cctx, corecommands.RootRO, true, false)
 }
 
 // CheckVersionOption returns a ServeOption that checks whether the client ipfs version matches. Does nothing when the user agent string does not contain `/kubo/` or `/go-ipfs/`
@@ -0,0 +1,154 @@
+package corehttp
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	core "github.com/ipfs/kubo/core"
+)
+
+// companionDialTimeout bounds the dials of the HTTPS tunnels of the
+// companion proxy.
+const companionDialTimeout = 30 * time.Second
+
+// ServeCompanionProxy serves an HTTP proxy on lis for the browsers without
+// the IPFS companion extension: the ipfs:// and ipns:// URLs, the /ipfs and
+// /ipns paths of any host and the subdomains of the form <cid>.ipfs.<domain>
+// are served by the gateway of the node, and the other requests are
+// forwarded to their origin.
+func ServeCompanionProxy(node *core.IpfsNode, lis net.Listener) error {
+	defer lis.Close()
+
+	gateway, err := makeHandler(node, lis, GatewayOption(false, "/ipfs", "/ipns"))
+	if err != nil {
+		return err
+	}
+	return serve(node, lis, companionProxy(gateway))
+}
+
+// companionProxy returns the handler of the companion proxy, serving the
+// content requests with gateway.
+func companionProxy(gateway http.Handler) http.Handler {
+	// the proxies of the environment may be this one
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	forward := &httputil.ReverseProxy{
+		// the requests to a proxy have an absolute URL already
+		Director:  func(*http.Request) {},
+		Transport: transport,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			// HTTPS, which can't be seen through
+			tunnel(w, r)
+			return
+		}
+		if p, ok := companionContentPath(r.URL, r.Host); ok {
+			r = r.Clone(r.Context())
+			r.URL = &url.URL{Path: p, RawQuery: r.URL.RawQuery}
+			r.RequestURI = r.URL.RequestURI()
+			gateway.ServeHTTP(w, r)
+			return
+		}
+		if !r.URL.IsAbs() {
+			http.Error(w, "this is an HTTP proxy, configure it as the proxy of the browser", http.StatusBadRequest)
+			return
+		}
+		forward.ServeHTTP(w, r)
+	})
+}
+
+// companionContentPath returns the content path of the request of u, to the
+// host, and false if it isn't an IPFS request.
+func companionContentPath(u *url.URL, host string) (string, bool) {
+	switch u.Scheme {
+	case "ipfs", "ipns":
+		// ipfs://<cid>/<path>, the host holding the CID or the name
+		if u.Host == "" {
+			return "", false
+		}
+		return "/" + u.Scheme + "/" + u.Host + u.Path, true
+	}
+
+	// /ipfs/<cid>/<path> and /ipns/<name>/<path> on any host
+	p := u.Path
+	for _, ns := range []string{"ipfs", "ipns"} {
+		prefix := "/" + ns + "/"
+		if !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		root, _, _ := strings.Cut(strings.TrimPrefix(p, prefix), "/")
+		if root == "" {
+			return "", false
+		}
+		if ns == "ipfs" {
+			if _, err := cid.Decode(root); err != nil {
+				return "", false
+			}
+		}
+		return p, true
+	}
+
+	// <cid>.ipfs.<domain> and <name>.ipns.<domain>
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.SplitN(host, ".", 3)
+	if len(labels) == 3 && (labels[1] == "ipfs" || labels[1] == "ipns") {
+		if labels[1] == "ipfs" {
+			if _, err := cid.Decode(labels[0]); err != nil {
+				return "", false
+			}
+		}
+		return "/" + labels[1] + "/" + labels[0] + p, true
+	}
+	return "", false
+}
+
+// tunnel relays the CONNECT request r to its host.
+func tunnel(w http.ResponseWriter, r *http.Request) {
+	dst, err := net.DialTimeout("tcp", r.Host, companionDialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		dst.Close()
+		http.Error(w, "tunnels not supported", http.StatusInternalServerError)
+		return
+	}
+	src, buf, err := hj.Hijack()
+	if err != nil {
+		dst.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if _, err := src.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		src.Close()
+		dst.Close()
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	go func() {
+		// the bytes the client sent past the request, if any
+		_, _ = io.Copy(dst, buf.Reader)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(src, dst)
+		done <- struct{}{}
+	}()
+	<-done
+	src.Close()
+	dst.Close()
+	<-done
+}
@@ -0,0 +1,79 @@
+package corehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testCompanionCid = "bafkqaaa"
+
+func TestCompanionContentPath(t *testing.T) {
+	for _, c := range []struct {
+		url, host string
+		path      string
+	}{
+		{"ipfs://" + testCompanionCid + "/a/b.txt", "", "/ipfs/" + testCompanionCid + "/a/b.txt"},
+		{"ipns://en.wikipedia-on-ipfs.org/wiki/", "", "/ipns/en.wikipedia-on-ipfs.org/wiki/"},
+		{"http://example.com/ipfs/" + testCompanionCid + "/index.html?x=1", "example.com", "/ipfs/" + testCompanionCid + "/index.html"},
+		{"http://example.com/ipns/docs.ipfs.tech", "example.com", "/ipns/docs.ipfs.tech"},
+		{"http://" + testCompanionCid + ".ipfs.dweb.link/style.css", testCompanionCid + ".ipfs.dweb.link", "/ipfs/" + testCompanionCid + "/style.css"},
+		{"http://docs-ipfs-tech.ipns.localhost:8080/", "docs-ipfs-tech.ipns.localhost:8080", "/ipns/docs-ipfs-tech/"},
+		{"http://example.com/ipfs/not-a-cid/", "example.com", ""},
+		{"http://example.com/ipfs/", "example.com", ""},
+		{"http://www.ipfs.tech/", "www.ipfs.tech", ""},
+		{"http://example.com/page", "example.com", ""},
+	} {
+		u, err := url.Parse(c.url)
+		require.NoError(t, err)
+		p, ok := companionContentPath(u, c.host)
+		require.Equal(t, c.path != "", ok, c.url)
+		require.Equal(t, c.path, p, c.url)
+	}
+}
+
+func TestCompanionProxy(t *testing.T) {
+	proxy := httptest.NewServer(companionProxy(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "gateway %s", r.URL.RequestURI())
+	})))
+	t.Cleanup(proxy.Close)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "origin %s", r.URL.Path)
+	}))
+	t.Cleanup(origin.Close)
+	tlsOrigin := httptest.NewTLSServer(origin.Config.Handler)
+	t.Cleanup(tlsOrigin.Close)
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	transport := tlsOrigin.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{Transport: transport}
+
+	get := func(u string) string {
+		t.Helper()
+		resp, err := client.Get(u)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Equal(t, "gateway /ipfs/"+testCompanionCid+"/a?b=c", get(origin.URL+"/ipfs/"+testCompanionCid+"/a?b=c"))
+	require.Equal(t, "origin /page", get(origin.URL+"/page"))
+	// HTTPS is tunneled
+	require.Equal(t, "origin /ipfs/"+testCompanionCid, get(tlsOrigin.URL+"/ipfs/"+testCompanionCid))
+
+	// not proxied
+	resp, err := http.Get(proxy.URL + "/page")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -90,7 +90,11 @@ func Serve(node *core.IpfsNode, lis net.Listener, options ...ServeOption) error
 	if err != nil {
 		return err
 	}
+	return serve(node, lis, handler)
+}
 
+// serve serves handler on lis until the node is closed.
+func serve(node *core.IpfsNode, lis net.Listener, handler http.Handler) error {
 	addr, err := manet.FromNetAddr(lis.Addr())
 	if err != nil {
 		return err
@@ -0,0 +1,393 @@
+package corehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	cmdsHttp "github.com/ipfs/go-ipfs-cmds/http"
+	ipld "github.com/ipfs/go-ipld-format"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/thirdparty/verifbs"
+	gocarv2 "github.com/ipld/go-car/v2"
+)
+
+// DagUploadPath is the path of the resumable CAR upload endpoint.
+const DagUploadPath = APIPath + "/dag/import/upload/"
+
+// uploadOffsetHeader reports how many bytes of an upload were received.
+const uploadOffsetHeader = "Upload-Offset"
+
+var uploadIDRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,128}$`)
+
+// DagUploadResult is the response to the final chunk of a resumable upload.
+type DagUploadResult struct {
+	Roots           []DagUploadRoot
+	BlockCount      uint64
+	BlockBytesCount uint64
+}
+
+// DagUploadRoot is the pinning result of a root of an uploaded CAR.
+type DagUploadRoot struct {
+	Cid         cid.Cid
+	Pinned      bool
+	PinErrorMsg string `json:",omitempty"`
+}
+
+type dagUploadHandler struct {
+	node    *core.IpfsNode
+	dir     string
+	cors    *cmdsHttp.ServerConfig
+	maxSize int64
+	expiry  time.Duration
+	policy  config.DagImport
+
+	mu   sync.Mutex
+	busy map[string]bool
+}
+
+// DagUploadOption serves resumable CAR uploads, staged in dir until complete.
+//
+// Clients PUT the CAR in chunks to DagUploadPath + <upload-id>, each with a
+// "Content-Range: bytes <first>-<last>/<total>" header. A HEAD request
+// returns the number of bytes already received in the Upload-Offset header,
+// so an interrupted upload can resume from there. The CAR is imported, and
+// its roots pinned according to DagImport.PinRoots, once the last chunk is
+// received.
+//
+// It follows CommandsOption, so that the uploads are authorized by
+// API.Authorizations and recorded by API.Audit like the commands. The users
+// scoped to a space can't upload, the roots being pinned outside of it.
+func DagUploadOption(dir string) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		cfg, err := n.Repo.Config()
+		if err != nil {
+			return nil, err
+		}
+		// Validate the policy early rather than after a large upload.
+		if _, err := cfg.DagImport.ResolvePinRoots(true); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+
+		// Apply the same origin restrictions as the commands.
+		cors := cmdsHttp.NewServerConfig()
+		addHeadersFromConfig(cors, cfg)
+		addCORSFromEnv(cors)
+		addCORSDefaults(cors)
+		patchCORSVars(cors, l.Addr())
+
+		mux.Handle(DagUploadPath, &dagUploadHandler{
+			node:    n,
+			dir:     dir,
+			cors:    cors,
+			maxSize: cfg.DagImport.MaxUploadSize.WithDefault(0),
+			expiry:  cfg.DagImport.UploadExpiry.WithDefault(config.DefaultDagImportUploadExpiry),
+			policy:  cfg.DagImport,
+			busy:    make(map[string]bool),
+		})
+		return mux, nil
+	}
+}
+
+func (h *dagUploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.allowOrigin(r) {
+		http.Error(w, "403 - Forbidden", http.StatusForbidden)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, DagUploadPath)
+	if !uploadIDRe.MatchString(id) {
+		http.Error(w, "invalid upload id", http.StatusBadRequest)
+		return
+	}
+	if !h.acquire(id) {
+		http.Error(w, "upload already in progress", http.StatusConflict)
+		return
+	}
+	defer h.release(id)
+
+	file := filepath.Join(h.dir, id+".car")
+	switch r.Method {
+	case http.MethodHead:
+		fi, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				http.Error(w, "unknown upload", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(fi.Size(), 10))
+	case http.MethodDelete:
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case http.MethodPut, http.MethodPost:
+		h.put(w, r, file)
+	default:
+		w.Header().Set("Allow", "HEAD, PUT, POST, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *dagUploadHandler) put(w http.ResponseWriter, r *http.Request, file string) {
+	first, last, total, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.maxSize > 0 && total > h.maxSize {
+		http.Error(w, fmt.Sprintf("upload exceeds DagImport.MaxUploadSize (%d bytes)", h.maxSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if first == 0 {
+		h.pruneExpired()
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if first != offset {
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		http.Error(w, fmt.Sprintf("chunk starts at %d, expected %d", first, offset), http.StatusConflict)
+		return
+	}
+
+	n, err := io.Copy(f, io.LimitReader(r.Body, last-first+1))
+	if err == nil && n != last-first+1 {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// Drop the partial chunk so that the client can resend it.
+		if terr := f.Truncate(offset); terr != nil {
+			log.Errorf("failed to truncate upload %s: %s", file, terr)
+		}
+		w.Header().Set(uploadOffsetHeader, strconv.FormatInt(offset, 10))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := f.Sync(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(uploadOffsetHeader, strconv.FormatInt(last+1, 10))
+
+	if last+1 < total {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	requested := true
+	if v := r.URL.Query().Get("pin-roots"); v != "" {
+		if requested, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid pin-roots value", http.StatusBadRequest)
+			return
+		}
+	}
+	pinRoots, err := h.policy.ResolvePinRoots(requested)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res, err := h.importCar(f, pinRoots)
+	// The upload is complete: whatever the outcome, it can not be resumed.
+	f.Close()
+	if rerr := os.Remove(file); rerr != nil {
+		log.Errorf("failed to remove upload %s: %s", file, rerr)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		log.Debugf("failed to write upload result: %s", err)
+	}
+}
+
+// importCar imports the blocks of a CAR and pins its roots, mirroring
+// 'ipfs dag import'.
+func (h *dagUploadHandler) importCar(f io.Reader, pinRoots bool) (*DagUploadResult, error) {
+	// The data is all here: finish the import even if the client goes away.
+	ctx := h.node.Context()
+
+	// hold the pin lock so that imported blocks are not garbage collected
+	// before their roots are pinned
+	defer h.node.Blockstore.PinLock(ctx).Unlock(ctx)
+
+	car, err := gocarv2.NewBlockReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &DagUploadResult{}
+	batch := ipld.NewBatch(ctx, h.node.DAG)
+	for {
+		block, err := car.Next()
+		if err != nil && err != io.EOF {
+			return nil, err
+		} else if block == nil {
+			break
+		}
+		if err := verifbs.VerifyBlock(block); err != nil {
+			return nil, fmt.Errorf("block %s: %w", block.Cid(), err)
+		}
+		nd, err := ipldlegacy.DecodeNode(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		if err := batch.Add(ctx, nd); err != nil {
+			return nil, err
+		}
+		res.BlockCount++
+		res.BlockBytesCount += uint64(len(block.RawData()))
+	}
+	if err := batch.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range car.Roots {
+		root := DagUploadRoot{Cid: c}
+		if pinRoots {
+			if block, err := h.node.Blockstore.Get(ctx, c); err != nil {
+				root.PinErrorMsg = err.Error()
+			} else if nd, err := ipldlegacy.DecodeNode(ctx, block); err != nil {
+				root.PinErrorMsg = err.Error()
+			} else if err := h.node.Pinning.Pin(ctx, nd, true); err != nil {
+				root.PinErrorMsg = err.Error()
+			} else if err := h.node.Pinning.Flush(ctx); err != nil {
+				root.PinErrorMsg = err.Error()
+			} else {
+				root.Pinned = true
+			}
+		}
+		res.Roots = append(res.Roots, root)
+	}
+	return res, nil
+}
+
+// allowOrigin rejects cross-origin browser requests that the commands API
+// would reject as well.
+func (h *dagUploadHandler) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Referer()
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+	for _, o := range h.cors.AllowedOrigins() {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *dagUploadHandler) acquire(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.busy[id] {
+		return false
+	}
+	h.busy[id] = true
+	return true
+}
+
+func (h *dagUploadHandler) release(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.busy, id)
+}
+
+// pruneExpired removes incomplete uploads that did not receive a chunk for
+// longer than the expiry.
+func (h *dagUploadHandler) pruneExpired() {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		log.Errorf("failed to list uploads: %s", err)
+		return
+	}
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".car")
+		if !strings.HasSuffix(e.Name(), ".car") || !h.acquire(id) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil && time.Since(fi.ModTime()) > h.expiry {
+			if err := os.Remove(filepath.Join(h.dir, e.Name())); err != nil {
+				log.Errorf("failed to remove expired upload %s: %s", id, err)
+			}
+		}
+		h.release(id)
+	}
+}
+
+var errInvalidContentRange = errors.New(`invalid Content-Range, expected "bytes <first>-<last>/<total>"`)
+
+// parseContentRange parses a "bytes <first>-<last>/<total>" header.
+func parseContentRange(s string) (first, last, total int64, err error) {
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	rng, size, ok := strings.Cut(strings.TrimPrefix(s, "bytes "), "/")
+	if !ok {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	f, l, ok := strings.Cut(rng, "-")
+	if !ok {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	if first, err = strconv.ParseInt(f, 10, 64); err != nil {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	if last, err = strconv.ParseInt(l, 10, 64); err != nil {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	if total, err = strconv.ParseInt(size, 10, 64); err != nil {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	if first < 0 || last < first || total <= last {
+		return 0, 0, 0, errInvalidContentRange
+	}
+	return first, last, total, nil
+}
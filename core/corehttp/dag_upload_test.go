@@ -0,0 +1,132 @@
+package corehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	dag "github.com/ipfs/go-merkledag"
+	car "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+)
+
+func TestParseContentRange(t *testing.T) {
+	first, last, total, err := parseContentRange("bytes 10-19/30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 10 || last != 19 || total != 30 {
+		t.Fatalf("unexpected range %d-%d/%d", first, last, total)
+	}
+
+	for _, s := range []string{
+		"",
+		"bytes */30",
+		"bytes 10-19/*",
+		"bytes 10-9/30",
+		"bytes 10-30/30",
+		"items 0-1/2",
+	} {
+		if _, _, _, err := parseContentRange(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestDagUpload(t *testing.T) {
+	n, err := newNodeWithMockNamesys(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dh := &delegatedHandler{}
+	ts := httptest.NewServer(dh)
+	t.Cleanup(func() { ts.Close() })
+	dh.Handler, err = makeHandler(n, ts.Listener, DagUploadOption(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nd := dag.NewRawNode([]byte("resumable upload"))
+	var buf bytes.Buffer
+	if err := car.WriteHeader(&car.CarHeader{Roots: []cid.Cid{nd.Cid()}, Version: 1}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := carutil.LdWrite(&buf, nd.Cid().Bytes(), nd.RawData()); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	url := ts.URL + DagUploadPath + "test-upload"
+
+	put := func(first, last int) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, url, bytes.NewReader(data[first:last+1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, len(data)))
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { res.Body.Close() })
+		return res
+	}
+	offset := func() int {
+		t.Helper()
+		res, err := http.Head(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected HEAD status %d", res.StatusCode)
+		}
+		o, err := strconv.Atoi(res.Header.Get(uploadOffsetHeader))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+
+	half := len(data) / 2
+	if res := put(0, half-1); res.StatusCode != http.StatusAccepted {
+		t.Fatalf("unexpected status %d", res.StatusCode)
+	}
+	if o := offset(); o != half {
+		t.Fatalf("expected offset %d, got %d", half, o)
+	}
+
+	// chunks must start at the current offset
+	if res := put(half+1, len(data)-1); res.StatusCode != http.StatusConflict {
+		t.Fatalf("expected conflict, got %d", res.StatusCode)
+	}
+
+	res := put(half, len(data)-1)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", res.StatusCode)
+	}
+	var out DagUploadResult
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.BlockCount != 1 || len(out.Roots) != 1 || !out.Roots[0].Pinned {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	if _, pinned, err := n.Pinning.IsPinned(n.Context(), nd.Cid()); err != nil || !pinned {
+		t.Fatalf("expected root to be pinned (err: %v)", err)
+	}
+
+	// completed uploads are removed
+	if res, err := http.Head(url); err != nil {
+		t.Fatal(err)
+	} else if res.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected upload to be removed, got %d", res.StatusCode)
+	}
+}
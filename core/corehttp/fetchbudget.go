@@ -0,0 +1,75 @@
+package corehttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	humanize "github.com/dustin/go-humanize"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/coreapi"
+)
+
+// fetchBudgetOptions returns the request options of the fetch budget b, with
+// the accounting of the cost of the requests.
+func fetchBudgetOptions(b *config.FetchBudget) ([]coreapi.RequestOption, error) {
+	var maxBytes uint64
+	if s := b.MaxBytes.WithDefault(""); s != "" {
+		var err error
+		if maxBytes, err = humanize.ParseBytes(s); err != nil {
+			return nil, fmt.Errorf("invalid FetchBudget.MaxBytes: %w", err)
+		}
+	}
+	return []coreapi.RequestOption{
+		coreapi.RequestFetchBudget(int(b.MaxBlocks.WithDefault(0)), int64(maxBytes)),
+		coreapi.RequestAccounting(),
+	}, nil
+}
+
+// withFetchBudget serves the requests to next with the fetch budget and the
+// block validator of opts, and logs the blocks they fetched or rejected.
+func withFetchBudget(name string, opts []coreapi.RequestOption, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := coreapi.WithRequestOptions(r.Context(), opts...)
+		next.ServeHTTP(w, r.WithContext(ctx))
+
+		cost, _ := coreapi.RequestCostFrom(ctx)
+		switch {
+		case cost.Exceeded:
+			log.Warnw("fetch budget exceeded", "handler", name, "url", r.URL.String(), "blocks", cost.Blocks, "bytes", cost.Bytes)
+		case cost.Rejected:
+			log.Warnw("block rejected", "handler", name, "url", r.URL.String())
+		case cost.Blocks > 0:
+			log.Debugw("request cost", "handler", name, "url", r.URL.String(), "blocks", cost.Blocks, "bytes", cost.Bytes)
+		}
+	})
+}
+
+// budgetErrors responds with HTTP 403 instead of the error of next when the
+// request exceeded its fetch budget, or read a block rejected by its
+// validator.
+func budgetErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&budgetErrorWriter{ResponseWriter: w, ctx: r.Context()}, r)
+	})
+}
+
+type budgetErrorWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+}
+
+func (w *budgetErrorWriter) WriteHeader(code int) {
+	if code >= 400 {
+		if cost, _ := coreapi.RequestCostFrom(w.ctx); cost.Exceeded || cost.Rejected {
+			code = http.StatusForbidden
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *budgetErrorWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
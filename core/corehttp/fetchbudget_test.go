@@ -0,0 +1,35 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBudget(t *testing.T) {
+	_, err := fetchBudgetOptions(&config.FetchBudget{MaxBytes: config.NewOptionalString("lots")})
+	require.Error(t, err)
+
+	opts, err := fetchBudgetOptions(&config.FetchBudget{
+		MaxBlocks: config.NewOptionalInteger(100),
+		MaxBytes:  config.NewOptionalString("1MiB"),
+	})
+	require.NoError(t, err)
+
+	h := withFetchBudget("test", opts, budgetErrors(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, coreapi.RequestOptions{MaxBlocks: 100, MaxBytes: 1 << 20, Accounting: true}, coreapi.RequestOptionsFrom(r.Context()))
+		cost, ok := coreapi.RequestCostFrom(r.Context())
+		require.True(t, ok)
+		require.Equal(t, coreapi.RequestCost{}, cost)
+		http.Error(w, "not found", http.StatusNotFound)
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ipfs/bafy", nil))
+	// the errors of the requests within their budget are kept
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
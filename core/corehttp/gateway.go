@@ -6,6 +6,8 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/go-libipfs/blocks"
@@ -15,8 +17,11 @@ import (
 	options "github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
 	version "github.com/ipfs/kubo"
+	config "github.com/ipfs/kubo/config"
 	core "github.com/ipfs/kubo/core"
 	coreapi "github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/dirpage"
+	"github.com/ipfs/kubo/core/gatewayauth"
 	id "github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
@@ -49,14 +54,30 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			Headers: headers,
 		}
 
+		limits, err := newGatewayLimits(cfg.Gateway.Limits)
+		if err != nil {
+			return nil, fmt.Errorf("Gateway.Limits: %w", err)
+		}
+
 		gatewayAPI := &gatewayAPI{
 			api:        api,
 			offlineAPI: offlineAPI,
+			pageSize:   int(cfg.Gateway.DirectoryPageSize.WithDefault(config.DefaultDirectoryPageSize)),
+			limits:     limits,
 		}
 
 		gateway := gateway.NewHandler(gatewayConfig, gatewayAPI)
 		gateway = otelhttp.NewHandler(gateway, "Gateway.Request")
 
+		errCfg := cfg.Webhooks.GatewayErrors
+		if threshold := errCfg.Threshold.WithDefault(config.DefaultGatewayErrorThreshold); threshold > 0 && n.Events != nil {
+			tracker := newGatewayErrorTracker(n.Events, int(threshold), errCfg.Window.WithDefault(config.DefaultGatewayErrorWindow))
+			gateway = tracker.wrap(gateway)
+		}
+
+		archives := &archiveHandler{api: api}
+		earlyHints := cfg.Gateway.EarlyHints.WithDefault(false)
+
 		var writableGateway *writableGatewayHandler
 		if writable {
 			writableGateway = &writableGatewayHandler{
@@ -65,25 +86,82 @@ func GatewayOption(writable bool, paths ...string) ServeOption {
 			}
 		}
 
-		for _, p := range paths {
-			mux.HandleFunc(p+"/", func(w http.ResponseWriter, r *http.Request) {
-				if writable {
-					switch r.Method {
-					case http.MethodPost:
-						writableGateway.postHandler(w, r)
-					case http.MethodDelete:
-						writableGateway.deleteHandler(w, r)
-					case http.MethodPut:
-						writableGateway.putHandler(w, r)
-					default:
-						gateway.ServeHTTP(w, r)
-					}
-
-					return
+		var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r = withDirPage(w, r)
+			if earlyHints {
+				sendEarlyHints(api, w, r)
+			}
+			if archives.serve(w, r) || serveTransform(api, w, r) {
+				return
+			}
+			if writable {
+				switch r.Method {
+				case http.MethodPost:
+					writableGateway.postHandler(w, r)
+				case http.MethodDelete:
+					writableGateway.deleteHandler(w, r)
+				case http.MethodPut:
+					writableGateway.putHandler(w, r)
+				default:
+					gateway.ServeHTTP(w, r)
 				}
 
-				gateway.ServeHTTP(w, r)
-			})
+				return
+			}
+
+			gateway.ServeHTTP(w, r)
+		})
+		handler = wrapConditional(handler)
+		if c := cfg.Gateway.Compression; c != nil && c.Enabled.WithDefault(false) {
+			gc, err := newGatewayCompression(c)
+			if err != nil {
+				return nil, fmt.Errorf("Gateway.Compression: %w", err)
+			}
+			// outside of wrapConditional, which compares the Etags of the
+			// content
+			handler = compressResponses(gc, handler)
+		}
+		if caps := cfg.Gateway.Capabilities; caps != nil && len(caps.Paths) > 0 {
+			verifier, err := newCapabilityVerifier(n, caps)
+			if err != nil {
+				return nil, fmt.Errorf("Gateway.Capabilities: %w", err)
+			}
+			handler = requireCapability(verifier, caps.Paths, handler)
+		}
+		var reqOpts []coreapi.RequestOption
+		if b := cfg.Gateway.FetchBudget; b != nil {
+			opts, err := fetchBudgetOptions(b)
+			if err != nil {
+				return nil, fmt.Errorf("Gateway: %w", err)
+			}
+			reqOpts = append(reqOpts, opts...)
+		}
+		if limits != nil {
+			reqOpts = append(reqOpts, coreapi.RequestBlockValidator(limits))
+		}
+		if len(reqOpts) > 0 {
+			handler = withFetchBudget("gateway", reqOpts, budgetErrors(handler))
+		}
+		if limits != nil && limits.maxPathDepth > 0 {
+			handler = limits.limitPathDepth(handler)
+		}
+		if rl := cfg.Gateway.RateLimit; rl != nil {
+			if rps := rl.RequestsPerSecond.WithDefault(0); rps > 0 {
+				handler = limitRequests(newRateLimiter(rps, rl.Burst.WithDefault(rps)), handler)
+			}
+		}
+		if len(cfg.Gateway.Authorizers) > 0 {
+			authorizers, err := gatewayauth.Get(cfg.Gateway.Authorizers)
+			if err != nil {
+				return nil, fmt.Errorf("Gateway.Authorizers: %w", err)
+			}
+			handler = authorizeRequests(authorizers, handler)
+		}
+		if n.GatewayPopularity != nil {
+			handler = wrapPopularity(n.GatewayPopularity, handler)
+		}
+		for _, p := range paths {
+			mux.Handle(p+"/", handler)
 		}
 		return mux, nil
 	}
@@ -103,6 +181,36 @@ func VersionOption() ServeOption {
 type gatewayAPI struct {
 	api        iface.CoreAPI
 	offlineAPI iface.CoreAPI
+	// pageSize is the maximum number of entries in the listings of sharded
+	// directories, 0 for no limit
+	pageSize int
+	// limits are the Gateway.Limits, nil for none
+	limits *gatewayLimits
+}
+
+// dirPager is implemented by the CoreAPI to list pages of directories.
+type dirPager interface {
+	LsPage(ctx context.Context, p path.Path, page dirpage.Page, opts ...options.UnixfsLsOption) (<-chan iface.DirEntry, error)
+}
+
+type dirPageKey struct{}
+
+// dirPageRequest is the page of a directory listing requested with the
+// "offset" query parameter.
+type dirPageRequest struct {
+	offset int
+	url    url.URL
+	// header is the header of the response, to link to the next page
+	header http.Header
+}
+
+func withDirPage(w http.ResponseWriter, r *http.Request) *http.Request {
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	page := &dirPageRequest{offset: offset, url: *r.URL, header: w.Header()}
+	return r.WithContext(context.WithValue(r.Context(), dirPageKey{}, page))
 }
 
 func (gw *gatewayAPI) GetUnixFsNode(ctx context.Context, pth path.Resolved) (files.Node, error) {
@@ -110,13 +218,69 @@ func (gw *gatewayAPI) GetUnixFsNode(ctx context.Context, pth path.Resolved) (fil
 }
 
 func (gw *gatewayAPI) LsUnixFsDir(ctx context.Context, pth path.Resolved) (<-chan iface.DirEntry, error) {
+	if gw.limits == nil || gw.limits.maxDirLinks <= 0 {
+		return gw.lsUnixFsDir(ctx, pth)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	entries, err := gw.lsUnixFsDir(ctx, pth)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return gw.limits.limitDirLinks(ctx, cancel, entries), nil
+}
+
+func (gw *gatewayAPI) lsUnixFsDir(ctx context.Context, pth path.Resolved) (<-chan iface.DirEntry, error) {
 	// Optimization: use Unixfs.Ls without resolving children, but using the
 	// cumulative DAG size as the file size. This allows for a fast listing
 	// while keeping a good enough Size field.
-	return gw.api.Unixfs().Ls(ctx, pth,
+	opts := []options.UnixfsLsOption{
 		options.Unixfs.ResolveChildren(false),
 		options.Unixfs.UseCumulativeSize(true),
-	)
+	}
+
+	page, _ := ctx.Value(dirPageKey{}).(*dirPageRequest)
+	pager, ok := gw.api.(dirPager)
+	if gw.pageSize <= 0 || page == nil || !ok {
+		return gw.api.Unixfs().Ls(ctx, pth, opts...)
+	}
+	nd, err := gw.api.Dag().Get(ctx, pth.Cid())
+	if err != nil {
+		return nil, err
+	}
+	if !dirpage.IsSharded(nd) {
+		return gw.api.Unixfs().Ls(ctx, pth, opts...)
+	}
+
+	// Sharded directories are listed page by page, fetching only the shards
+	// of the page. One more entry is listed to know if there is a next page.
+	entries, err := pager.LsPage(ctx, pth, dirpage.Page{Offset: page.offset, Limit: gw.pageSize + 1}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan iface.DirEntry)
+	go func() {
+		defer close(out)
+		var n int
+		for e := range entries {
+			if n == gw.pageSize {
+				next := page.url
+				q := next.Query()
+				q.Set("offset", strconv.Itoa(page.offset+n))
+				next.RawQuery = q.Encode()
+				// the listing is rendered once out is closed
+				page.header.Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.String()))
+				return
+			}
+			select {
+			case out <- e:
+				n++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
 }
 
 func (gw *gatewayAPI) GetBlock(ctx context.Context, cid cid.Cid) (blocks.Block, error) {
@@ -0,0 +1,393 @@
+package corehttp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	gopath "path"
+	"sort"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/files"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+// archiveModTime is the modification time of all the entries of the
+// archives, so that the archive of a given CID is always the same: it is the
+// earliest time zip can represent.
+var archiveModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// archiveHandler serves UnixFS files and directories as tar or zip archives.
+// The entries are sorted by name and have fixed timestamps, so that the
+// output is deterministic. Tar archives have a Content-Length and support
+// range requests, so that downloads can be resumed.
+type archiveHandler struct {
+	api iface.CoreAPI
+}
+
+type archiveEntry struct {
+	name   string
+	typ    iface.FileType
+	cid    cid.Cid
+	size   int64
+	target string
+}
+
+// archiveFormat returns the archive format requested by r, "" for none.
+func archiveFormat(r *http.Request) string {
+	switch r.URL.Query().Get("format") {
+	case "tar":
+		return "tar"
+	case "zip":
+		return "zip"
+	}
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		switch strings.TrimSpace(strings.Split(accept, ";")[0]) {
+		case "application/x-tar":
+			return "tar"
+		case "application/zip":
+			return "zip"
+		}
+	}
+	return ""
+}
+
+// serve serves the archive requested by r, and returns false if there is
+// none, or if the path can't be resolved, to let the gateway handle the
+// request.
+func (h *archiveHandler) serve(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	format := archiveFormat(r)
+	if format == "" {
+		return false
+	}
+	ctx := r.Context()
+	rp, err := h.api.ResolvePath(ctx, path.New(r.URL.Path))
+	if err != nil {
+		return false
+	}
+
+	// the CID or the DNSLink name for the roots
+	rootName := gopath.Base(strings.TrimRight(r.URL.Path, "/"))
+	entries, err := h.entries(ctx, rp, rootName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+
+	filename := r.URL.Query().Get("filename")
+	if filename == "" {
+		filename = rootName + "." + format
+	}
+	etag := fmt.Sprintf(`"%s.%s"`, rp.Cid(), format)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Etag", etag)
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+	if strings.HasPrefix(r.URL.Path, "/ipfs/") {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	}
+
+	switch format {
+	case "tar":
+		w.Header().Set("Content-Type", "application/x-tar")
+		tr, err := newTarReader(ctx, h.api, entries)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return true
+		}
+		defer tr.close()
+		http.ServeContent(w, r, filename, time.Time{}, tr)
+	case "zip":
+		w.Header().Set("Content-Type", "application/zip")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+		if r.Method == http.MethodHead {
+			return true
+		}
+		if err := h.writeZip(ctx, w, entries); err != nil {
+			log.Errorf("gateway: zip archive of %s: %s", r.URL.Path, err)
+			// the response has started, let the client see a broken
+			// download rather than a truncated archive
+			panic(http.ErrAbortHandler)
+		}
+	}
+	return true
+}
+
+// entries returns the entries of the archive of the UnixFS node at rp, sorted
+// by path.
+func (h *archiveHandler) entries(ctx context.Context, rp path.Resolved, rootName string) ([]archiveEntry, error) {
+	nd, err := h.api.Unixfs().Get(ctx, rp)
+	if err != nil {
+		return nil, err
+	}
+	defer nd.Close()
+
+	root := archiveEntry{name: rootName, cid: rp.Cid()}
+	switch n := nd.(type) {
+	case files.Directory:
+		root.typ = iface.TDirectory
+	case *files.Symlink:
+		root.typ = iface.TSymlink
+		root.target = n.Target
+	case files.File:
+		root.typ = iface.TFile
+		if root.size, err = n.Size(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported UnixFS node %T", nd)
+	}
+
+	entries := []archiveEntry{root}
+	if root.typ == iface.TDirectory {
+		return h.walk(ctx, entries, rootName, rp)
+	}
+	return entries, nil
+}
+
+func (h *archiveHandler) walk(ctx context.Context, entries []archiveEntry, prefix string, p path.Path) ([]archiveEntry, error) {
+	results, err := h.api.Unixfs().Ls(ctx, p, options.Unixfs.ResolveChildren(true))
+	if err != nil {
+		return nil, err
+	}
+	var children []iface.DirEntry
+	for e := range results {
+		if e.Err != nil {
+			return nil, e.Err
+		}
+		if e.Name == "" || e.Name == "." || e.Name == ".." || strings.Contains(e.Name, "/") {
+			return nil, fmt.Errorf("invalid entry name %q under %s", e.Name, prefix)
+		}
+		children = append(children, e)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+
+	for _, e := range children {
+		name := prefix + "/" + e.Name
+		entries = append(entries, archiveEntry{
+			name:   name,
+			typ:    e.Type,
+			cid:    e.Cid,
+			size:   int64(e.Size),
+			target: e.Target,
+		})
+		if e.Type == iface.TDirectory {
+			if entries, err = h.walk(ctx, entries, name, path.IpfsPath(e.Cid)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (h *archiveHandler) writeZip(ctx context.Context, w io.Writer, entries []archiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Store, Modified: archiveModTime}
+		switch e.typ {
+		case iface.TDirectory:
+			hdr.Name += "/"
+			hdr.SetMode(os.ModeDir | 0755)
+			if _, err := zw.CreateHeader(hdr); err != nil {
+				return err
+			}
+		case iface.TSymlink:
+			hdr.SetMode(os.ModeSymlink | 0777)
+			fw, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(fw, e.target); err != nil {
+				return err
+			}
+		default:
+			hdr.SetMode(0644)
+			fw, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+			f, err := openArchiveFile(ctx, h.api, e.cid)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(fw, f)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return zw.Close()
+}
+
+func openArchiveFile(ctx context.Context, api iface.CoreAPI, c cid.Cid) (files.File, error) {
+	nd, err := api.Unixfs().Get(ctx, path.IpfsPath(c))
+	if err != nil {
+		return nil, err
+	}
+	f, ok := nd.(files.File)
+	if !ok {
+		nd.Close()
+		return nil, fmt.Errorf("%s is not a file", c)
+	}
+	return f, nil
+}
+
+// tarSegment is a part of a tar archive: either inline data, i.e. headers
+// and padding, or the content of a file.
+type tarSegment struct {
+	start int64
+	size  int64
+	data  []byte
+	cid   cid.Cid
+}
+
+// tarReader reads a tar archive laid out in advance, opening the files as
+// they are read. It can seek anywhere in the archive, to serve ranges.
+type tarReader struct {
+	ctx  context.Context
+	api  iface.CoreAPI
+	segs []tarSegment
+	size int64
+	off  int64
+
+	// the file being read, its segment and the offset in it
+	cur    files.File
+	curSeg int
+	curOff int64
+}
+
+var tarPadding [1024]byte
+
+func newTarReader(ctx context.Context, api iface.CoreAPI, entries []archiveEntry) (*tarReader, error) {
+	tr := &tarReader{ctx: ctx, api: api, curSeg: -1}
+	add := func(seg tarSegment) {
+		seg.start = tr.size
+		if seg.data != nil {
+			seg.size = int64(len(seg.data))
+		}
+		if seg.size > 0 {
+			tr.segs = append(tr.segs, seg)
+			tr.size += seg.size
+		}
+	}
+
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, ModTime: archiveModTime}
+		switch e.typ {
+		case iface.TDirectory:
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.Mode = 0755
+		case iface.TSymlink:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = e.target
+			hdr.Mode = 0777
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = 0644
+			hdr.Size = e.size
+		}
+
+		var buf bytes.Buffer
+		if err := tar.NewWriter(&buf).WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		add(tarSegment{data: buf.Bytes()})
+		if hdr.Typeflag == tar.TypeReg {
+			add(tarSegment{cid: e.cid, size: e.size})
+			if pad := e.size % 512; pad != 0 {
+				add(tarSegment{data: tarPadding[:512-pad]})
+			}
+		}
+	}
+	// the end of the archive
+	add(tarSegment{data: tarPadding[:]})
+	return tr, nil
+}
+
+func (tr *tarReader) Read(p []byte) (int, error) {
+	if tr.off >= tr.size {
+		return 0, io.EOF
+	}
+	i := sort.Search(len(tr.segs), func(i int) bool {
+		return tr.segs[i].start+tr.segs[i].size > tr.off
+	})
+	seg := tr.segs[i]
+	within := tr.off - seg.start
+	if left := seg.size - within; int64(len(p)) > left {
+		p = p[:left]
+	}
+
+	if seg.data != nil {
+		n := copy(p, seg.data[within:])
+		tr.off += int64(n)
+		return n, nil
+	}
+
+	if tr.curSeg != i {
+		tr.close()
+		f, err := openArchiveFile(tr.ctx, tr.api, seg.cid)
+		if err != nil {
+			return 0, err
+		}
+		tr.cur, tr.curSeg, tr.curOff = f, i, 0
+	}
+	if tr.curOff != within {
+		if _, err := tr.cur.Seek(within, io.SeekStart); err != nil {
+			return 0, err
+		}
+		tr.curOff = within
+	}
+	n, err := tr.cur.Read(p)
+	tr.off += int64(n)
+	tr.curOff += int64(n)
+	if err == io.EOF {
+		if n == 0 {
+			// the file is shorter than its size
+			return 0, io.ErrUnexpectedEOF
+		}
+		err = nil
+	}
+	return n, err
+}
+
+func (tr *tarReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += tr.off
+	case io.SeekEnd:
+		offset += tr.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	tr.off = offset
+	return offset, nil
+}
+
+func (tr *tarReader) close() {
+	if tr.cur != nil {
+		tr.cur.Close()
+		tr.cur, tr.curSeg = nil, -1
+	}
+}
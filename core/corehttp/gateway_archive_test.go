@@ -0,0 +1,88 @@
+package corehttp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/stretchr/testify/require"
+)
+
+func getArchive(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, body
+}
+
+func TestGatewayArchives(t *testing.T) {
+	ts, api, ctx := newTestServerAndNode(t, mockNamesys{})
+
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"b.txt": files.NewBytesFile([]byte("bbb")),
+		"a.txt": files.NewBytesFile(bytes.Repeat([]byte("a"), 1000)),
+		"sub": files.NewMapDirectory(map[string]files.Node{
+			"c.txt": files.NewBytesFile([]byte("c")),
+		}),
+	})
+	p, err := api.Unixfs().Add(ctx, dir)
+	require.NoError(t, err)
+	root := p.Cid().String()
+
+	resp, body := getArchive(t, ts.URL+p.String()+"?format=tar", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/x-tar", resp.Header.Get("Content-Type"))
+	require.Equal(t, strconv.Itoa(len(body)), resp.Header.Get("Content-Length"))
+	require.Equal(t, `"`+root+`.tar"`, resp.Header.Get("Etag"))
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(body))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.Equal(t, archiveModTime, hdr.ModTime.UTC())
+		names = append(names, hdr.Name)
+		if hdr.Name == root+"/a.txt" {
+			content, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			require.Len(t, content, 1000)
+		}
+	}
+	require.Equal(t, []string{root + "/", root + "/a.txt", root + "/b.txt", root + "/sub/", root + "/sub/c.txt"}, names)
+
+	// the output is deterministic, so downloads can be resumed
+	resp, part := getArchive(t, ts.URL+p.String()+"?format=tar", http.Header{"Range": {"bytes=700-"}})
+	require.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	require.Equal(t, body[700:], part)
+
+	resp, body = getArchive(t, ts.URL+p.String(), http.Header{"Accept": {"application/zip"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/zip", resp.Header.Get("Content-Type"))
+	require.True(t, strings.Contains(resp.Header.Get("Content-Disposition"), root+".zip"))
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	names = nil
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{root + "/", root + "/a.txt", root + "/b.txt", root + "/sub/", root + "/sub/c.txt"}, names)
+
+	_, again := getArchive(t, ts.URL+p.String()+"?format=zip", nil)
+	require.Equal(t, body, again)
+}
@@ -0,0 +1,59 @@
+package corehttp
+
+import (
+	"net/http"
+
+	"github.com/ipfs/kubo/core/gatewayauth"
+)
+
+// authorizeRequests only serves the requests allowed by all the authorizers,
+// in order, with next.
+func authorizeRequests(authorizers []gatewayauth.Authorizer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &gatewayauth.Request{Path: r.URL.Path, Request: r}
+		decided := false
+		for _, authorize := range authorizers {
+			auth, err := authorize(r.Context(), req)
+			if err != nil {
+				log.Errorf("authorizing the gateway request of %s failed: %s", r.URL.Path, err)
+				http.Error(w, "authorizing the request failed", http.StatusInternalServerError)
+				return
+			}
+			if auth == nil {
+				continue
+			}
+			decided = true
+			for k, v := range auth.Header {
+				for _, s := range v {
+					w.Header().Add(k, s)
+				}
+			}
+
+			var status int
+			switch auth.Decision {
+			case gatewayauth.Allow:
+				continue
+			case gatewayauth.PaymentRequired:
+				status = http.StatusPaymentRequired
+				if auth.Challenge != "" {
+					w.Header().Set("WWW-Authenticate", auth.Challenge)
+				}
+			default:
+				status = http.StatusForbidden
+			}
+			w.Header().Set("Cache-Control", "no-store")
+			msg := auth.Message
+			if msg == "" {
+				msg = http.StatusText(status)
+			}
+			http.Error(w, msg, status)
+			return
+		}
+		if decided {
+			// the decision depends on the client, the responses must not be
+			// served to others by shared caches
+			w = &noStoreWriter{ResponseWriter: w}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
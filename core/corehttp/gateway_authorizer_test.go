@@ -0,0 +1,76 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ipfs/kubo/core/gatewayauth"
+)
+
+func TestGatewayAuthorizer(t *testing.T) {
+	paywall := func(ctx context.Context, req *gatewayauth.Request) (*gatewayauth.Authorization, error) {
+		switch {
+		case strings.HasPrefix(req.Path, "/ipfs/bafyfree"):
+			return nil, nil
+		case strings.HasPrefix(req.Path, "/ipfs/bafyfail"):
+			return nil, errors.New("metering service unavailable")
+		case req.Request.Header.Get("X-Payment") == "paid":
+			return &gatewayauth.Authorization{Decision: gatewayauth.Allow, Header: http.Header{"X-Metered": {"1"}}}, nil
+		}
+		return &gatewayauth.Authorization{Decision: gatewayauth.PaymentRequired, Challenge: `L402 invoice="lnbc1"`}, nil
+	}
+	blocklist := func(ctx context.Context, req *gatewayauth.Request) (*gatewayauth.Authorization, error) {
+		if strings.HasPrefix(req.Path, "/ipfs/bafyblocked") {
+			return &gatewayauth.Authorization{Decision: gatewayauth.Deny, Message: "blocked"}, nil
+		}
+		return nil, nil
+	}
+	require.NoError(t, gatewayauth.Add("test-paywall", paywall))
+	require.NoError(t, gatewayauth.Add("test-blocklist", blocklist))
+	require.Error(t, gatewayauth.Add("test-paywall", paywall))
+
+	_, err := gatewayauth.Get([]string{"test-paywall", "nope"})
+	require.Error(t, err)
+	authorizers, err := gatewayauth.Get([]string{"test-blocklist", "test-paywall"})
+	require.NoError(t, err)
+
+	h := authorizeRequests(authorizers, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("/ipfs/bafyfree", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Empty(t, rec.Header().Get("Cache-Control"))
+
+	rec = serve("/ipfs/bafypaid/index.html", nil)
+	require.Equal(t, http.StatusPaymentRequired, rec.Code)
+	require.Equal(t, `L402 invoice="lnbc1"`, rec.Header().Get("WWW-Authenticate"))
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	rec = serve("/ipfs/bafypaid/index.html", http.Header{"X-Payment": {"paid"}})
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("X-Metered"))
+	require.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+
+	rec = serve("/ipfs/bafyblocked", http.Header{"X-Payment": {"paid"}})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Equal(t, "blocked\n", rec.Body.String())
+
+	rec = serve("/ipfs/bafyfail", nil)
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+}
@@ -0,0 +1,99 @@
+package corehttp
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/capability"
+)
+
+// capabilityQueryParam is the query parameter carrying the capability token
+// of the links made by 'ipfs key share'.
+const capabilityQueryParam = "cap"
+
+// newCapabilityVerifier returns the verifier of the tokens signed by the key
+// configured in Gateway.Capabilities.
+func newCapabilityVerifier(n *core.IpfsNode, cfg *config.GatewayCapabilities) (*capability.Verifier, error) {
+	name := cfg.Key.WithDefault(config.DefaultCapabilityKey)
+	if name == "self" {
+		return capability.NewVerifier(n.PrivateKey.GetPublic())
+	}
+	sk, err := n.Repo.Keystore().Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return capability.NewVerifier(sk.GetPublic())
+}
+
+// requireCapability only serves the requests of the protected paths carrying
+// a capability token granting the access to them, in the "cap" query
+// parameter or in an Authorization: Bearer header.
+// It only gates the URLs: the same blocks are still served through any
+// other path leading to them, e.g. from a parent CID or a DNSLink name.
+func requireCapability(v *capability.Verifier, protected []string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := capability.CleanPath(r.URL.Path)
+		if !isProtected(protected, p) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.URL.Query().Get(capabilityQueryParam)
+		if token == "" {
+			token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "a capability token is required", http.StatusUnauthorized)
+			return
+		}
+		if err := v.Verify(token, p); err != nil {
+			status := http.StatusForbidden
+			if errors.Is(err, capability.ErrInvalid) {
+				status = http.StatusUnauthorized
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		// the responses must not be kept by shared caches once the token
+		// has expired
+		next.ServeHTTP(&noStoreWriter{ResponseWriter: w}, r)
+	})
+}
+
+func isProtected(protected []string, p string) bool {
+	for _, prefix := range protected {
+		if capability.Grants(prefix, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// noStoreWriter overrides the Cache-Control header of the final response.
+type noStoreWriter struct {
+	http.ResponseWriter
+}
+
+func (w *noStoreWriter) WriteHeader(code int) {
+	if code >= 200 {
+		w.ResponseWriter.Header().Set("Cache-Control", "no-store")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *noStoreWriter) Write(b []byte) (int, error) {
+	w.ResponseWriter.Header().Set("Cache-Control", "no-store")
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *noStoreWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
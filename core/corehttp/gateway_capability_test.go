@@ -0,0 +1,118 @@
+package corehttp
+
+import (
+	"crypto/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/capability"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequireCapability(t *testing.T) {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	v, err := capability.NewVerifier(sk.GetPublic())
+	require.NoError(t, err)
+
+	token, err := capability.Issue(sk, []string{"/ipfs/bafyprivate/a"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	expired, err := capability.Issue(sk, []string{"/ipfs/bafyprivate"}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	h := requireCapability(v, []string{"/ipfs/bafyprivate"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+		w.Write([]byte("hello"))
+	}))
+
+	for _, c := range []struct {
+		path, token, bearer string
+		status              int
+	}{
+		{"/ipfs/bafypublic", "", "", http.StatusOK},
+		{"/ipfs/bafyprivate/a", "", "", http.StatusUnauthorized},
+		{"/ipfs/bafyprivate/a/b.txt", token, "", http.StatusOK},
+		{"/ipfs/bafyprivate/a", "", token, http.StatusOK},
+		{"/ipfs/bafyprivate/c", token, "", http.StatusForbidden},
+		{"/ipfs/bafyprivate/a", expired, "", http.StatusForbidden},
+		{"/ipfs/bafyprivate/a", "garbage", "", http.StatusUnauthorized},
+	} {
+		target := c.path
+		if c.token != "" {
+			target += "?" + capabilityQueryParam + "=" + url.QueryEscape(c.token)
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if c.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+c.bearer)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, c.status, w.Code, c.path)
+		if c.status == http.StatusOK && c.path != "/ipfs/bafypublic" {
+			require.Equal(t, "no-store", w.Header().Get("Cache-Control"), c.path)
+		}
+	}
+}
+
+func TestRequireCapabilityAcrossEncodings(t *testing.T) {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	require.NoError(t, err)
+	v, err := capability.NewVerifier(sk.GetPublic())
+	require.NoError(t, err)
+
+	hash, err := mh.Sum([]byte("private"), mh.SHA2_256, -1)
+	require.NoError(t, err)
+	v0 := cid.NewCidV0(hash).String()
+	v1 := cid.NewCidV1(cid.DagProtobuf, hash).String()
+	base36, err := cid.NewCidV1(cid.DagProtobuf, hash).StringOfBase(multibase.Base36)
+	require.NoError(t, err)
+
+	// the protected path and the token use the CIDv0, the requests the other
+	// encodings
+	token, err := capability.Issue(sk, []string{"/ipfs/" + v0 + "/a"}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+	protect := func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.Handle("/ipfs/", requireCapability(v, []string{"/ipfs/" + v0}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		})))
+		return mux, nil
+	}
+	// the subdomain requests reach the gateway as /ipfs/<CIDv1 in base32>
+	h, err := makeHandler(n, nil, HostnameOption(), protect)
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		host, path, token string
+		status            int
+	}{
+		{"127.0.0.1", "/ipfs/" + v1 + "/a", "", http.StatusUnauthorized},
+		{"127.0.0.1", "/ipfs/" + base36 + "/a", "", http.StatusUnauthorized},
+		{"127.0.0.1", "/ipfs/" + base36 + "/a/b.txt", token, http.StatusOK},
+		{"127.0.0.1", "/ipfs/" + v1 + "/c", token, http.StatusForbidden},
+		{v1 + ".ipfs.localhost", "/a", "", http.StatusUnauthorized},
+		{v1 + ".ipfs.localhost", "/a/b.txt", token, http.StatusOK},
+		{v1 + ".ipfs.localhost", "/c", token, http.StatusForbidden},
+	} {
+		target := c.path
+		if c.token != "" {
+			target += "?" + capabilityQueryParam + "=" + url.QueryEscape(c.token)
+		}
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = c.host
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		require.Equal(t, c.status, w.Code, c.host+c.path)
+	}
+}
@@ -0,0 +1,279 @@
+package corehttp
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	config "github.com/ipfs/kubo/config"
+)
+
+// The content codings the gateway compresses with.
+const (
+	encodingZstd   = "zstd"
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+var (
+	defaultCompressionEncodings = []string{encodingZstd, encodingBrotli, encodingGzip}
+	defaultCompressionMinSize   = "1KiB"
+)
+
+// zstdWindowSize is the largest window the browsers decode, see RFC 8878.
+const zstdWindowSize = 8 << 20
+
+// compressibleTypes are the media types compressed, along with text/* and
+// the +json and +xml structured syntaxes. The other media, such as images,
+// videos, archives, CAR and raw blocks, are compressed already or binary.
+var compressibleTypes = map[string]bool{
+	"application/json":              true,
+	"application/javascript":        true,
+	"application/x-javascript":      true,
+	"application/ecmascript":        true,
+	"application/xml":               true,
+	"application/wasm":              true,
+	"application/vnd.ipld.dag-json": true,
+	"application/x-ndjson":          true,
+	"font/ttf":                      true,
+	"font/otf":                      true,
+	"image/bmp":                     true,
+	"image/x-icon":                  true,
+	"image/vnd.microsoft.icon":      true,
+}
+
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "+json") || strings.HasSuffix(mt, "+xml") || compressibleTypes[mt]
+}
+
+// compressEncoder is an encoder of a content coding, reset for each response.
+type compressEncoder interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// gatewayCompression compresses the responses of the gateway.
+type gatewayCompression struct {
+	encodings []string
+	minSize   uint64
+	pools     map[string]*sync.Pool
+	// sem bounds the responses compressed at once
+	sem chan struct{}
+}
+
+func newGatewayCompression(c *config.GatewayCompression) (*gatewayCompression, error) {
+	gc := &gatewayCompression{
+		encodings: c.Encodings,
+		pools:     make(map[string]*sync.Pool),
+	}
+	if len(gc.encodings) == 0 {
+		gc.encodings = defaultCompressionEncodings
+	}
+
+	var err error
+	gc.minSize, err = humanize.ParseBytes(c.MinSize.WithDefault(defaultCompressionMinSize))
+	if err != nil {
+		return nil, fmt.Errorf("MinSize: %w", err)
+	}
+
+	maxConcurrent := c.MaxConcurrent.WithDefault(int64(runtime.NumCPU()))
+	if maxConcurrent <= 0 {
+		return nil, fmt.Errorf("MaxConcurrent must be positive, got %d", maxConcurrent)
+	}
+	gc.sem = make(chan struct{}, maxConcurrent)
+
+	var zstdLevel zstd.EncoderLevel
+	var brotliLevel, gzipLevel int
+	switch level := c.Level.WithDefault("default"); level {
+	case "fastest":
+		zstdLevel, brotliLevel, gzipLevel = zstd.SpeedFastest, brotli.BestSpeed, gzip.BestSpeed
+	case "default":
+		// the default level of brotli, 6, is several times slower than the
+		// ones of zstd and gzip, too slow to compress on the fly
+		zstdLevel, brotliLevel, gzipLevel = zstd.SpeedDefault, 4, gzip.DefaultCompression
+	case "best":
+		zstdLevel, brotliLevel, gzipLevel = zstd.SpeedBestCompression, brotli.BestCompression, gzip.BestCompression
+	default:
+		return nil, fmt.Errorf("unknown Level %q, expected fastest, default or best", level)
+	}
+
+	for _, e := range gc.encodings {
+		switch e {
+		case encodingZstd:
+			gc.pools[e] = &sync.Pool{New: func() interface{} {
+				enc, _ := zstd.NewWriter(nil,
+					zstd.WithEncoderLevel(zstdLevel),
+					zstd.WithEncoderConcurrency(1),
+					zstd.WithWindowSize(zstdWindowSize))
+				return enc
+			}}
+		case encodingBrotli:
+			gc.pools[e] = &sync.Pool{New: func() interface{} {
+				return brotli.NewWriterLevel(nil, brotliLevel)
+			}}
+		case encodingGzip:
+			gc.pools[e] = &sync.Pool{New: func() interface{} {
+				enc, _ := gzip.NewWriterLevel(nil, gzipLevel)
+				return enc
+			}}
+		default:
+			return nil, fmt.Errorf("unknown encoding %q, expected %s, %s or %s", e, encodingZstd, encodingBrotli, encodingGzip)
+		}
+	}
+	return gc, nil
+}
+
+// negotiate returns the preferred encoding of the ones accepted by the
+// Accept-Encoding header, "" for none.
+func (gc *gatewayCompression) negotiate(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		ok := true
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			v, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			ok = err == nil && v > 0
+		}
+		if name == "*" {
+			wildcard = ok
+			continue
+		}
+		if _, seen := accepted[name]; !seen {
+			accepted[name] = ok
+		}
+	}
+	for _, e := range gc.encodings {
+		if ok, seen := accepted[e]; ok || (!seen && wildcard) {
+			return e
+		}
+	}
+	return ""
+}
+
+// compressResponses compresses the successful responses of compressible
+// media types with the encoding negotiated with the client.
+func compressResponses(gc *gatewayCompression, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the ranges and the lengths of HEAD responses are the ones of the
+		// uncompressed content
+		if r.Method != http.MethodGet || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, gc: gc, encoding: gc.negotiate(r.Header.Get("Accept-Encoding"))}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter compresses the response written, when compressible.
+type compressWriter struct {
+	http.ResponseWriter
+	gc       *gatewayCompression
+	encoding string
+
+	wroteHeader bool
+	// enc is the encoder of the response, nil when sent as is
+	enc compressEncoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		// informational responses, such as 103 Early Hints, precede the
+		// final one
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if code == http.StatusOK && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+		w.start()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// start sets up the encoder of the response, unless it is too small, the
+// client doesn't accept any of the encodings, or too many responses are
+// compressed already.
+func (w *compressWriter) start() {
+	if w.encoding == "" {
+		return
+	}
+	h := w.Header()
+	if l, err := strconv.ParseUint(h.Get("Content-Length"), 10, 64); err == nil && l < w.gc.minSize {
+		return
+	}
+	select {
+	case w.gc.sem <- struct{}{}:
+	default:
+		return
+	}
+	w.enc = w.gc.pools[w.encoding].Get().(compressEncoder)
+	w.enc.Reset(w.ResponseWriter)
+
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length")
+	// the compressed bytes differ from the content identified by the Etag
+	if etag := h.Get("Etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("Etag", "W/"+etag)
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) Flush() {
+	if w.enc != nil {
+		if err := w.enc.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close ends the compressed stream, once the handler returned.
+func (w *compressWriter) close() {
+	if w.enc == nil {
+		return
+	}
+	if err := w.enc.Close(); err != nil {
+		log.Debugf("compressing the gateway response failed: %s", err)
+	}
+	w.enc.Reset(nil)
+	w.gc.pools[w.encoding].Put(w.enc)
+	w.enc = nil
+	<-w.gc.sem
+}
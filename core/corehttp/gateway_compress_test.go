@@ -0,0 +1,150 @@
+package corehttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	config "github.com/ipfs/kubo/config"
+)
+
+func TestCompressNegotiate(t *testing.T) {
+	gc, err := newGatewayCompression(&config.GatewayCompression{})
+	require.NoError(t, err)
+	for accept, want := range map[string]string{
+		"":                      "",
+		"gzip, deflate, br":     encodingBrotli,
+		"gzip, deflate":         encodingGzip,
+		"gzip, zstd, br":        encodingZstd,
+		"zstd;q=0, gzip;q=0.5":  encodingGzip,
+		"br;q=0, gzip":          encodingGzip,
+		"*":                     encodingZstd,
+		"*;q=0, gzip":           encodingGzip,
+		"br, identity":          encodingBrotli,
+		"deflate, identity":     "",
+		"GZIP":                  encodingGzip,
+		"zstd;q=0, *;q=1, gzip": encodingBrotli,
+	} {
+		require.Equal(t, want, gc.negotiate(accept), accept)
+	}
+
+	gc, err = newGatewayCompression(&config.GatewayCompression{Encodings: []string{encodingGzip, encodingBrotli}})
+	require.NoError(t, err)
+	require.Equal(t, encodingGzip, gc.negotiate("br, gzip"))
+
+	_, err = newGatewayCompression(&config.GatewayCompression{Encodings: []string{"deflate"}})
+	require.Error(t, err)
+	_, err = newGatewayCompression(&config.GatewayCompression{Level: config.NewOptionalString("max")})
+	require.Error(t, err)
+}
+
+func TestCompressResponses(t *testing.T) {
+	gc, err := newGatewayCompression(&config.GatewayCompression{})
+	require.NoError(t, err)
+	text := strings.Repeat("hello gateway ", 1000)
+	h := compressResponses(gc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"bafy"`)
+		switch r.URL.Path {
+		case "/text":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, text)
+		case "/small":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Length", "5")
+			io.WriteString(w, "hello")
+		case "/car":
+			w.Header().Set("Content-Type", "application/vnd.ipld.car")
+			io.WriteString(w, text)
+		}
+	}))
+	serve := func(method, path, accept string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Accept-Encoding", accept)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	decoders := map[string]func(r io.Reader) (io.Reader, error){
+		encodingZstd: func(r io.Reader) (io.Reader, error) {
+			dec, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		},
+		encodingBrotli: func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+		encodingGzip: func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+	}
+	for _, c := range []struct {
+		accept, encoding string
+	}{
+		{"gzip, zstd", encodingZstd},
+		{"gzip, deflate, br", encodingBrotli},
+		{"gzip", encodingGzip},
+	} {
+		rec := serve(http.MethodGet, "/text", c.accept, nil)
+		require.Equal(t, c.encoding, rec.Header().Get("Content-Encoding"), c.accept)
+		require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"), c.accept)
+		require.Empty(t, rec.Header().Get("Content-Length"), c.accept)
+		require.Equal(t, `W/"bafy"`, rec.Header().Get("Etag"), c.accept)
+		require.Less(t, rec.Body.Len(), len(text), c.accept)
+		dec, err := decoders[c.encoding](rec.Body)
+		require.NoError(t, err, c.accept)
+		body, err := io.ReadAll(dec)
+		require.NoError(t, err, c.accept)
+		require.Equal(t, text, string(body), c.accept)
+	}
+
+	// the responses sent as is vary with the encodings accepted as well
+	rec := serve(http.MethodGet, "/text", "identity", nil)
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	require.Equal(t, text, rec.Body.String())
+
+	for _, c := range []struct {
+		method, path, accept string
+		header               http.Header
+	}{
+		{http.MethodGet, "/text", "", nil},
+		{http.MethodGet, "/small", "zstd", nil},
+		{http.MethodGet, "/car", "zstd", nil},
+		{http.MethodHead, "/text", "zstd", nil},
+		{http.MethodGet, "/text", "zstd", http.Header{"Range": {"bytes=0-10"}}},
+	} {
+		rec = serve(c.method, c.path, c.accept, c.header)
+		require.Empty(t, rec.Header().Get("Content-Encoding"), c.path)
+		require.Equal(t, `"bafy"`, rec.Header().Get("Etag"), c.path)
+	}
+}
+
+func TestCompressBudget(t *testing.T) {
+	gc, err := newGatewayCompression(&config.GatewayCompression{MaxConcurrent: config.NewOptionalInteger(1)})
+	require.NoError(t, err)
+	// a response is being compressed already
+	gc.sem <- struct{}{}
+	h := compressResponses(gc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, strings.Repeat("a", 4096))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, 4096, rec.Body.Len())
+}
@@ -0,0 +1,150 @@
+package corehttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// conditionalBufferLimit is the size of the responses without an Etag which
+// are buffered to derive one from their body.
+const conditionalBufferLimit = 1 << 20
+
+// etagMatches reports whether the If-None-Match header matches the Etag,
+// with the weak comparison of RFC 7232: "*", or one of the listed tags with
+// the same opaque tag, weak or not.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapConditional returns a handler answering the conditional GET and HEAD
+// requests with 304 Not Modified, whichever format serves them, so that
+// caches in front of the gateway don't download unchanged content again.
+// The successful responses without an Etag, such as IPNS records or
+// generated listings, get a strong one derived from their body, when it is
+// small enough to be buffered.
+func wrapConditional(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &conditionalWriter{ResponseWriter: w, ifNoneMatch: r.Header.Get("If-None-Match"), head: r.Method == http.MethodHead}
+		next.ServeHTTP(cw, r)
+		cw.finish()
+	})
+}
+
+// conditionalWriter replaces the successful responses matching If-None-Match
+// with 304 Not Modified.
+type conditionalWriter struct {
+	http.ResponseWriter
+	ifNoneMatch string
+	head        bool
+
+	wroteHeader bool
+	notModified bool
+	// buf is the body buffered to derive an Etag, nil when not buffering
+	buf *bytes.Buffer
+}
+
+func (w *conditionalWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 200 {
+		// informational responses, such as 103 Early Hints, precede the
+		// final one
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.wroteHeader = true
+	if code != http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	etag := w.Header().Get("Etag")
+	if etag == "" && !w.head {
+		w.buf = new(bytes.Buffer)
+		return
+	}
+	w.writeHeader(etag)
+}
+
+// writeHeader writes the header of a successful response with the Etag.
+func (w *conditionalWriter) writeHeader(etag string) {
+	if etagMatches(w.ifNoneMatch, etag) {
+		w.notModified = true
+		w.Header().Del("Content-Length")
+		w.Header().Del("Content-Type")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+}
+
+func (w *conditionalWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case w.notModified:
+		return len(b), nil
+	case w.buf != nil:
+		w.buf.Write(b)
+		if w.buf.Len() > conditionalBufferLimit {
+			// too large for an Etag
+			return len(b), w.flushBuffer()
+		}
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// flushBuffer writes the buffered response as is, without an Etag.
+func (w *conditionalWriter) flushBuffer() error {
+	buf := w.buf
+	w.buf = nil
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, err := w.ResponseWriter.Write(buf.Bytes())
+	return err
+}
+
+func (w *conditionalWriter) Flush() {
+	if w.buf != nil {
+		// the handler streams the response
+		if err := w.flushBuffer(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.notModified {
+		f.Flush()
+	}
+}
+
+// finish writes the response buffered to derive its Etag, once the handler
+// returned.
+func (w *conditionalWriter) finish() {
+	if w.buf == nil {
+		return
+	}
+	sum := sha256.Sum256(w.buf.Bytes())
+	etag := `"sha256-` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("Etag", etag)
+	w.writeHeader(etag)
+	if !w.notModified {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+}
@@ -0,0 +1,68 @@
+package corehttp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEtagMatches(t *testing.T) {
+	for _, tc := range []struct {
+		ifNoneMatch, etag string
+		match             bool
+	}{
+		{"", `"a"`, false},
+		{`"a"`, `"a"`, true},
+		{`"b"`, `"a"`, false},
+		{`"b", "a"`, `"a"`, true},
+		{`W/"a"`, `"a"`, true},
+		{`"a"`, `W/"a"`, true},
+		{"*", `"a"`, true},
+		{"*", "", false},
+	} {
+		require.Equal(t, tc.match, etagMatches(tc.ifNoneMatch, tc.etag), "If-None-Match %s, Etag %s", tc.ifNoneMatch, tc.etag)
+	}
+}
+
+func TestGatewayConditional(t *testing.T) {
+	big := bytes.Repeat([]byte("x"), conditionalBufferLimit+1)
+	ts := httptest.NewServer(wrapConditional(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/etag":
+			w.Header().Set("Etag", `"fnord"`)
+			w.Write([]byte("fnord"))
+		case "/derived":
+			w.Write([]byte("fnord"))
+		case "/big":
+			w.Write(big)
+		case "/missing":
+			http.Error(w, "missing", http.StatusNotFound)
+		}
+	})))
+	defer ts.Close()
+
+	resp, body := getArchive(t, ts.URL+"/etag", http.Header{"If-None-Match": {`"other", W/"fnord"`}})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Empty(t, body)
+
+	resp, body = getArchive(t, ts.URL+"/derived", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "fnord", string(body))
+	etag := resp.Header.Get("Etag")
+	require.NotEmpty(t, etag)
+	resp, body = getArchive(t, ts.URL+"/derived", http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+	require.Empty(t, body)
+	require.Equal(t, etag, resp.Header.Get("Etag"))
+
+	resp, body = getArchive(t, ts.URL+"/big", http.Header{"If-None-Match": {"*"}})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Empty(t, resp.Header.Get("Etag"))
+	require.Equal(t, big, body)
+
+	resp, _ = getArchive(t, ts.URL+"/missing", http.Header{"If-None-Match": {"*"}})
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,128 @@
+package corehttp
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ipfs/go-libipfs/files"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"golang.org/x/net/html"
+)
+
+const (
+	// earlyHintsMaxLinks is the maximum number of subresources hinted for a
+	// document.
+	earlyHintsMaxLinks = 16
+	// earlyHintsMaxHead is the number of bytes of a document read to find
+	// the subresources in its head.
+	earlyHintsMaxHead = 64 << 10
+)
+
+// sendEarlyHints sends a 103 Early Hints response with Link preload headers
+// for the subresources in the head of the HTML document requested by r:
+// stylesheets, scripts and explicit preloads. The browser fetches them while
+// the gateway resolves and serves the document. Only the subresources of the
+// same gateway are hinted, with their URLs as written in the document, which
+// the client resolves like the document does. The headers are repeated on
+// the final response.
+func sendEarlyHints(api iface.CoreAPI, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || r.URL.RawQuery != "" {
+		return
+	}
+	p := r.URL.Path
+	switch {
+	case strings.HasSuffix(p, "/"):
+		p += "index.html"
+	case strings.HasSuffix(p, ".html"), strings.HasSuffix(p, ".htm"):
+	default:
+		return
+	}
+
+	ctx := r.Context()
+	rp, err := api.ResolvePath(ctx, path.New(p))
+	if err != nil {
+		return
+	}
+	nd, err := api.Unixfs().Get(ctx, rp)
+	if err != nil {
+		return
+	}
+	defer nd.Close()
+	f, ok := nd.(files.File)
+	if !ok {
+		return
+	}
+
+	links := earlyHintLinks(io.LimitReader(f, earlyHintsMaxHead))
+	if len(links) == 0 {
+		return
+	}
+	for _, l := range links {
+		w.Header().Add("Link", l)
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+}
+
+// earlyHintLinks returns the Link header values preloading the subresources
+// in the head of the HTML document.
+func earlyHintLinks(r io.Reader) []string {
+	var links []string
+	add := func(href, rel, as string) {
+		u, err := url.Parse(strings.TrimSpace(href))
+		if href == "" || err != nil || u.Scheme != "" || u.Host != "" || len(links) == earlyHintsMaxLinks {
+			return
+		}
+		// as is a token, e.g. "font"
+		if strings.Trim(as, "abcdefghijklmnopqrstuvwxyz") != "" {
+			return
+		}
+		l := fmt.Sprintf("<%s>; rel=%s", u.String(), rel)
+		if as != "" {
+			l += "; as=" + as
+		}
+		links = append(links, l)
+	}
+
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" {
+				return links
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			attrs := make(map[string]string)
+			for hasAttr {
+				var k, v []byte
+				k, v, hasAttr = z.TagAttr()
+				attrs[string(k)] = string(v)
+			}
+			switch string(name) {
+			case "body":
+				return links
+			case "script":
+				if attrs["type"] == "module" {
+					add(attrs["src"], "modulepreload", "")
+				} else {
+					add(attrs["src"], "preload", "script")
+				}
+			case "link":
+				switch strings.ToLower(attrs["rel"]) {
+				case "stylesheet":
+					add(attrs["href"], "preload", "style")
+				case "preload":
+					add(attrs["href"], "preload", attrs["as"])
+				case "modulepreload":
+					add(attrs["href"], "modulepreload", "")
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package corehttp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEarlyHintLinks(t *testing.T) {
+	doc := `<!DOCTYPE html>
+<html>
+<head>
+  <link rel="stylesheet" href="style.css">
+  <link rel="preload" href="/fonts/a.woff2" as="font">
+  <link rel="preload" href="x.bin" as="a>b">
+  <link rel="icon" href="favicon.ico">
+  <script src="app.js"></script>
+  <script type="module" src="./main.mjs"></script>
+  <script src="https://cdn.example.com/lib.js"></script>
+  <link rel="stylesheet" href="//cdn.example.com/lib.css">
+</head>
+<body>
+  <script src="late.js"></script>
+</body>
+</html>`
+	require.Equal(t, []string{
+		"<style.css>; rel=preload; as=style",
+		"</fonts/a.woff2>; rel=preload; as=font",
+		"<app.js>; rel=preload; as=script",
+		"<./main.mjs>; rel=modulepreload",
+	}, earlyHintLinks(strings.NewReader(doc)))
+
+	require.Empty(t, earlyHintLinks(strings.NewReader("<p>no head</p>")))
+}
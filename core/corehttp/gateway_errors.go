@@ -0,0 +1,140 @@
+package corehttp
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/kubo/core/events"
+)
+
+// gatewayErrorTracker counts 5xx responses per CID and emits a
+// gateway.error event once a CID failed threshold times within window.
+type gatewayErrorTracker struct {
+	bus       *events.Bus
+	threshold int
+	window    time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	counts map[cid.Cid]*errorCount
+}
+
+type errorCount struct {
+	first time.Time
+	n     int
+}
+
+// maxTrackedErrors bounds the number of CIDs tracked at once; expired entries
+// are pruned when it is reached.
+const maxTrackedErrors = 1024
+
+func newGatewayErrorTracker(bus *events.Bus, threshold int, window time.Duration) *gatewayErrorTracker {
+	return &gatewayErrorTracker{
+		bus:       bus,
+		threshold: threshold,
+		window:    window,
+		now:       time.Now,
+		counts:    make(map[cid.Cid]*errorCount),
+	}
+}
+
+func (t *gatewayErrorTracker) record(c cid.Cid, status int, path string) {
+	t.mu.Lock()
+	now := t.now()
+	if len(t.counts) >= maxTrackedErrors {
+		for k, ec := range t.counts {
+			if now.Sub(ec.first) > t.window {
+				delete(t.counts, k)
+			}
+		}
+	}
+
+	ec, ok := t.counts[c]
+	if !ok || now.Sub(ec.first) > t.window {
+		if !ok && len(t.counts) >= maxTrackedErrors {
+			t.mu.Unlock()
+			return
+		}
+		ec = &errorCount{first: now}
+		t.counts[c] = ec
+	}
+	ec.n++
+	n := ec.n
+	if n >= t.threshold {
+		delete(t.counts, c)
+	}
+	t.mu.Unlock()
+
+	if n >= t.threshold {
+		t.bus.Emit(events.GatewayError, map[string]interface{}{
+			"Cid":    c.String(),
+			"Path":   path,
+			"Status": status,
+			"Count":  n,
+		})
+	}
+}
+
+// wrap returns a handler recording 5xx responses for /ipfs/{cid} requests.
+func (t *gatewayErrorTracker) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if sw.status < 500 {
+			return
+		}
+		if c, ok := requestCid(r.URL.Path); ok {
+			t.record(c, sw.status, r.URL.Path)
+		}
+	})
+}
+
+// requestCid extracts the root CID from an /ipfs/{cid}/... path.
+func requestCid(p string) (cid.Cid, bool) {
+	if !strings.HasPrefix(p, "/ipfs/") {
+		return cid.Undef, false
+	}
+	s := strings.TrimPrefix(p, "/ipfs/")
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		s = s[:i]
+	}
+	c, err := cid.Decode(s)
+	if err != nil {
+		return cid.Undef, false
+	}
+	return c, true
+}
+
+// statusWriter records the status code and the size of the body written to
+// the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	written     uint64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	// informational responses, such as 103 Early Hints, precede the final one
+	if !w.wroteHeader && code >= 200 {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += uint64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
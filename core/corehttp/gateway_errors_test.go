@@ -0,0 +1,50 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/core/events"
+)
+
+func TestGatewayErrorTracker(t *testing.T) {
+	bus := events.NewBus()
+	sub := bus.Subscribe(0, events.GatewayError)
+	defer sub.Close()
+
+	now := time.Unix(0, 0)
+	tracker := newGatewayErrorTracker(bus, 2, time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	status := http.StatusInternalServerError
+	h := tracker.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	serve := func(p string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+
+	const c = "bafkqaaa"
+	serve("/ipfs/" + c + "/a")
+	now = now.Add(2 * time.Minute) // first error expires
+	serve("/ipfs/" + c + "/b")
+	status = http.StatusNotFound
+	serve("/ipfs/" + c)
+	serve("/ipns/example.com")
+	if len(sub.Out()) != 0 {
+		t.Fatal("unexpected gateway.error event")
+	}
+
+	status = http.StatusBadGateway
+	serve("/ipfs/" + c)
+	select {
+	case evt := <-sub.Out():
+		if evt.Data["Cid"] != c || evt.Data["Status"] != http.StatusBadGateway || evt.Data["Count"] != 2 {
+			t.Fatalf("unexpected event %+v", evt)
+		}
+	default:
+		t.Fatal("expected gateway.error event")
+	}
+}
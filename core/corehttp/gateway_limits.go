@@ -0,0 +1,126 @@
+package corehttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	humanize "github.com/dustin/go-humanize"
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/blocks"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/prometheus/client_golang/prometheus"
+
+	config "github.com/ipfs/kubo/config"
+)
+
+var gatewayLimitHits = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ipfs_http_gw_limit_hits_total",
+		Help: "requests of the gateway over one of Gateway.Limits, by limit",
+	},
+	[]string{"limit"},
+)
+
+// gatewayLimits are the Gateway.Limits. It validates the blocks read by the
+// requests to the gateway, see coreapi.RequestBlockValidator.
+type gatewayLimits struct {
+	maxPathDepth  int
+	maxDirLinks   int
+	maxHAMTFanout uint64
+	maxBlockSize  uint64
+}
+
+// newGatewayLimits returns the limits of cfg, or nil if none is set.
+func newGatewayLimits(cfg *config.GatewayLimits) (*gatewayLimits, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	l := &gatewayLimits{
+		maxPathDepth:  int(cfg.MaxPathDepth.WithDefault(0)),
+		maxDirLinks:   int(cfg.MaxDirectoryLinks.WithDefault(0)),
+		maxHAMTFanout: uint64(cfg.MaxHAMTFanout.WithDefault(0)),
+	}
+	if s := cfg.MaxBlockSize.WithDefault(""); s != "" {
+		var err error
+		if l.maxBlockSize, err = humanize.ParseBytes(s); err != nil {
+			return nil, fmt.Errorf("invalid MaxBlockSize: %w", err)
+		}
+	}
+	if (*l == gatewayLimits{}) {
+		return nil, nil
+	}
+	if err := prometheus.Register(gatewayLimitHits); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ValidateBlock refuses the blocks over MaxBlockSize, and the HAMT shards
+// over MaxHAMTFanout.
+func (l *gatewayLimits) ValidateBlock(blk blocks.Block) error {
+	if l.maxBlockSize > 0 && uint64(len(blk.RawData())) > l.maxBlockSize {
+		gatewayLimitHits.WithLabelValues("block_size").Inc()
+		return fmt.Errorf("block %s of %d bytes is over Gateway.Limits.MaxBlockSize", blk.Cid(), len(blk.RawData()))
+	}
+	if l.maxHAMTFanout == 0 || blk.Cid().Type() != cid.DagProtobuf {
+		return nil
+	}
+	pn, err := dag.DecodeProtobuf(blk.RawData())
+	if err != nil {
+		// left to the decoding of the block by the request
+		return nil
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	if err != nil || fsn.Type() != ft.THAMTShard {
+		return nil
+	}
+	if fsn.Fanout() > l.maxHAMTFanout {
+		gatewayLimitHits.WithLabelValues("hamt_fanout").Inc()
+		return fmt.Errorf("HAMT shard %s of fanout %d is over Gateway.Limits.MaxHAMTFanout", blk.Cid(), fsn.Fanout())
+	}
+	return nil
+}
+
+// limitPathDepth responds with HTTP 400 to the requests of content paths
+// deeper than MaxPathDepth, e.g. /ipfs/{cid}/a/b has a depth of 2.
+func (l *gatewayLimits) limitPathDepth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the namespace and the root aren't counted
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(segments)-2 > l.maxPathDepth {
+			gatewayLimitHits.WithLabelValues("path_depth").Inc()
+			http.Error(w, fmt.Sprintf("the path is deeper than %d segments", l.maxPathDepth), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// limitDirLinks returns the first MaxDirectoryLinks entries of a directory
+// listing, then cancels the listing with cancel.
+func (l *gatewayLimits) limitDirLinks(ctx context.Context, cancel context.CancelFunc, entries <-chan iface.DirEntry) <-chan iface.DirEntry {
+	out := make(chan iface.DirEntry)
+	go func() {
+		defer cancel()
+		defer close(out)
+		var n int
+		for e := range entries {
+			if n == l.maxDirLinks {
+				gatewayLimitHits.WithLabelValues("directory_links").Inc()
+				return
+			}
+			select {
+			case out <- e:
+				n++
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
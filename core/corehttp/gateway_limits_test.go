@@ -0,0 +1,51 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-libipfs/blocks"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	config "github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayLimits(t *testing.T) {
+	l, err := newGatewayLimits(&config.GatewayLimits{})
+	require.NoError(t, err)
+	require.Nil(t, l)
+
+	_, err = newGatewayLimits(&config.GatewayLimits{MaxBlockSize: config.NewOptionalString("big")})
+	require.Error(t, err)
+
+	l, err = newGatewayLimits(&config.GatewayLimits{
+		MaxPathDepth:  config.NewOptionalInteger(2),
+		MaxHAMTFanout: config.NewOptionalInteger(256),
+		MaxBlockSize:  config.NewOptionalString("1KiB"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, l.ValidateBlock(blocks.NewBlock([]byte("small"))))
+	require.Error(t, l.ValidateBlock(blocks.NewBlock(make([]byte, 1025))))
+
+	shard := func(fanout uint64) blocks.Block {
+		data, err := ft.HAMTShardData(nil, fanout, 0x22)
+		require.NoError(t, err)
+		return dag.NodeWithData(data)
+	}
+	require.NoError(t, l.ValidateBlock(shard(256)))
+	require.Error(t, l.ValidateBlock(shard(4096)))
+
+	h := l.limitPathDepth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for p, code := range map[string]int{
+		"/ipfs/bafy":       http.StatusOK,
+		"/ipfs/bafy/a/b/":  http.StatusOK,
+		"/ipfs/bafy/a/b/c": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		require.Equal(t, code, w.Code, p)
+	}
+}
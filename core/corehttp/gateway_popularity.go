@@ -0,0 +1,25 @@
+package corehttp
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/kubo/core/popularity"
+)
+
+// wrapPopularity returns a handler counting the successful GET requests and
+// the bytes served by content path, e.g. /ipfs/{cid}/index.html.
+func wrapPopularity(t *popularity.Tracker, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		if r.Method != http.MethodGet || sw.status >= 400 {
+			return
+		}
+		key := strings.TrimRight(r.URL.Path, "/")
+		if key == "" {
+			return
+		}
+		t.Record(key, sw.written)
+	})
+}
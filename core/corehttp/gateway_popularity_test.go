@@ -0,0 +1,36 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/kubo/core/popularity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayPopularity(t *testing.T) {
+	tracker := popularity.New(10)
+	h := wrapPopularity(tracker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ipfs/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/ipfs/a/", nil),
+		httptest.NewRequest(http.MethodGet, "/ipfs/a", nil),
+		httptest.NewRequest(http.MethodGet, "/ipfs/b/index.html", nil),
+		httptest.NewRequest(http.MethodHead, "/ipfs/b/index.html", nil),
+		httptest.NewRequest(http.MethodGet, "/ipfs/missing", nil),
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	require.Equal(t, []popularity.Entry{
+		{Key: "/ipfs/a", Requests: 2, Bytes: 10},
+		{Key: "/ipfs/b/index.html", Requests: 1, Bytes: 5},
+	}, tracker.Top(0, false))
+}
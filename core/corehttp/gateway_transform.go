@@ -0,0 +1,86 @@
+package corehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/ipfs/go-libipfs/files"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+
+	"github.com/ipfs/kubo/core/gatewaytransform"
+)
+
+// serveTransform serves the transformed file requested by r, and returns false
+// if no transform is requested, or if the path can't be resolved, to let the
+// gateway handle the request.
+func serveTransform(api iface.CoreAPI, w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	query := r.URL.Query()
+	name := query.Get(gatewaytransform.Param)
+	if name == "" {
+		return false
+	}
+	ctx := r.Context()
+	rp, err := api.ResolvePath(ctx, path.New(r.URL.Path))
+	if err != nil {
+		return false
+	}
+
+	transform, ok := gatewaytransform.Get(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown transform %q", name), http.StatusBadRequest)
+		return true
+	}
+
+	// the query is encoded sorted by key
+	sum := sha256.Sum256([]byte(query.Encode()))
+	etag := fmt.Sprintf(`"%s.%s"`, rp.Cid(), hex.EncodeToString(sum[:8]))
+	w.Header().Set("Etag", etag)
+	w.Header().Set("X-Ipfs-Path", r.URL.Path)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+
+	nd, err := api.Unixfs().Get(ctx, rp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	defer nd.Close()
+	f, ok := nd.(files.File)
+	if !ok {
+		http.Error(w, "transforms only apply to files", http.StatusBadRequest)
+		return true
+	}
+
+	res, err := transform(ctx, &gatewaytransform.Request{Path: rp, File: f, Query: query})
+	switch {
+	case errors.Is(err, gatewaytransform.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return true
+	case err != nil:
+		log.Errorf("gateway: transform %q of %s: %s", name, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return true
+	}
+	defer res.Body.Close()
+
+	if res.ContentType != "" {
+		w.Header().Set("Content-Type", res.ContentType)
+	}
+	if r.Method == http.MethodHead {
+		return true
+	}
+	if _, err := io.Copy(w, res.Body); err != nil {
+		log.Debugf("gateway: transform %q of %s: %s", name, r.URL.Path, err)
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package corehttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ipfs/kubo/core/gatewaytransform"
+)
+
+func TestGatewayTransform(t *testing.T) {
+	require.NoError(t, gatewaytransform.Add("test-upper", func(ctx context.Context, req *gatewaytransform.Request) (*gatewaytransform.Response, error) {
+		data, err := io.ReadAll(req.File)
+		if err != nil {
+			return nil, err
+		}
+		if req.Query.Get("fail") != "" {
+			return nil, gatewaytransform.ErrUnsupported
+		}
+		return &gatewaytransform.Response{
+			Body:        io.NopCloser(bytes.NewReader(bytes.ToUpper(data))),
+			ContentType: "text/plain",
+		}, nil
+	}))
+	require.Error(t, gatewaytransform.Add("test-upper", nil))
+
+	ts, api, ctx := newTestServerAndNode(t, mockNamesys{})
+	p, err := api.Unixfs().Add(ctx, files.NewBytesFile([]byte("fnord")))
+	require.NoError(t, err)
+
+	resp, body := getArchive(t, ts.URL+p.String()+"?transform=test-upper", nil)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "FNORD", string(body))
+	etag := resp.Header.Get("Etag")
+	require.True(t, strings.HasPrefix(etag, `"`+p.Cid().String()+"."))
+
+	resp, _ = getArchive(t, ts.URL+p.String()+"?transform=test-upper", http.Header{"If-None-Match": {etag}})
+	require.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, _ = getArchive(t, ts.URL+p.String()+"?transform=test-upper&fail=1", nil)
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	require.NotEqual(t, etag, resp.Header.Get("Etag"))
+
+	resp, _ = getArchive(t, ts.URL+p.String()+"?transform=nope", nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
@@ -77,6 +77,21 @@ func HostnameOption() ServeOption {
 				// This is a known gateway but request is not using
 				// the subdomain feature.
 
+				// Apply per-hostname rate limits and headers
+				if w, ok = knownGateways.applyHostPolicy(w, gw); !ok {
+					return
+				}
+
+				// Is this a static website with a fixed content root?
+				if gw.RootPath != "" {
+					if !allowsResponse(w, r, gw) {
+						return
+					}
+					r.URL.Path = strings.TrimSuffix(gw.RootPath, "/") + r.URL.Path
+					childMux.ServeHTTP(w, withHostnameContext(r, host))
+					return
+				}
+
 				// Does this gateway _handle_ this path?
 				if hasPrefix(r.URL.Path, gw.Paths...) {
 					// It does.
@@ -106,6 +121,9 @@ func HostnameOption() ServeOption {
 
 					// Not a subdomain resource, continue with path processing
 					// Example: 127.0.0.1:8080/ipfs/{CID}, ipfs.io/ipfs/{CID} etc
+					if hasPrefix(r.URL.Path, "/ipfs", "/ipns") && !allowsResponse(w, r, gw) {
+						return
+					}
 					childMux.ServeHTTP(w, r)
 					return
 				}
@@ -113,6 +131,9 @@ func HostnameOption() ServeOption {
 
 				// Try DNSLink, if it was not explicitly disabled for the hostname
 				if !gw.NoDNSLink && isDNSLinkName(r.Context(), coreAPI, host) {
+					if !allowsResponse(w, r, gw) {
+						return
+					}
 					// rewrite path and handle as DNSLink
 					r.URL.Path = "/ipns/" + stripPort(host) + r.URL.Path
 					childMux.ServeHTTP(w, withHostnameContext(r, host))
@@ -131,6 +152,14 @@ func HostnameOption() ServeOption {
 			if gw, gwHostname, ns, rootID, ok := knownSubdomainDetails(host, knownGateways); ok {
 				// Looks like we're using a known gateway in subdomain mode.
 
+				// Apply per-hostname rate limits and headers
+				if w, ok = knownGateways.applyHostPolicy(w, gw); !ok {
+					return
+				}
+				if !allowsResponse(w, r, gw) {
+					return
+				}
+
 				// Assemble original path prefix.
 				pathPrefix := "/" + ns + "/" + rootID
 
@@ -241,6 +270,7 @@ func HostnameOption() ServeOption {
 type gatewayHosts struct {
 	exact    map[string]*config.GatewaySpec
 	wildcard []wildcardHost
+	limiters map[*config.GatewaySpec]*rateLimiter
 }
 
 type wildcardHost struct {
@@ -248,6 +278,87 @@ type wildcardHost struct {
 	spec *config.GatewaySpec
 }
 
+// applyHostPolicy enforces the rate limit configured for the hostname and
+// returns a ResponseWriter that includes hostname-specific headers. When ok is
+// false, the request was rejected and a response has already been written.
+func (h gatewayHosts) applyHostPolicy(w http.ResponseWriter, gw *config.GatewaySpec) (_ http.ResponseWriter, ok bool) {
+	if limiter, found := h.limiters[gw]; found {
+		if allowed, wait := limiter.allow(); !allowed {
+			tooManyRequests(w, wait)
+			return nil, false
+		}
+	}
+	if len(gw.HTTPHeaders) > 0 {
+		headers := make(map[string][]string, len(gw.HTTPHeaders))
+		for k, v := range gw.HTTPHeaders {
+			headers[http.CanonicalHeaderKey(k)] = v
+		}
+		w = &hostHeadersWriter{ResponseWriter: w, headers: headers}
+	}
+	return w, true
+}
+
+// allowsResponse returns false and writes an error if the hostname does not
+// allow deserialized responses and the request is not asking for a verifiable
+// response type.
+func allowsResponse(w http.ResponseWriter, r *http.Request, gw *config.GatewaySpec) bool {
+	if gw.DeserializedResponses.WithDefault(config.DefaultDeserializedResponses) || isTrustlessRequest(r) {
+		return true
+	}
+	http.Error(w, "deserialized responses are disabled on this hostname: use ?format=raw or ?format=car, or a matching Accept header", http.StatusNotAcceptable)
+	return false
+}
+
+// isTrustlessRequest returns true if request asks for a response type that
+// can be verified by the client (raw block, CAR or IPNS record).
+func isTrustlessRequest(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "raw", "car", "ipns-record":
+		return true
+	}
+	for _, accept := range r.Header.Values("Accept") {
+		for _, value := range strings.Split(accept, ",") {
+			mediaType := strings.TrimSpace(strings.SplitN(value, ";", 2)[0])
+			switch mediaType {
+			case "application/vnd.ipld.raw", "application/vnd.ipld.car", "application/vnd.ipfs.ipns-record":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostHeadersWriter sets hostname-specific headers right before the response
+// is written, so they take precedence over global Gateway.HTTPHeaders.
+type hostHeadersWriter struct {
+	http.ResponseWriter
+	headers     map[string][]string
+	wroteHeader bool
+}
+
+func (w *hostHeadersWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for k, v := range w.headers {
+			w.ResponseWriter.Header()[k] = v
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *hostHeadersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *hostHeadersWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // Extends request context to include hostname of a canonical gateway root
 // (subdomain root or dnslink fqdn)
 func withHostnameContext(r *http.Request, hostname string) *http.Request {
@@ -264,6 +375,7 @@ func prepareKnownGateways(publicGateways map[string]*config.GatewaySpec) gateway
 	var hosts gatewayHosts
 
 	hosts.exact = make(map[string]*config.GatewaySpec, len(publicGateways)+len(defaultKnownGateways))
+	hosts.limiters = make(map[*config.GatewaySpec]*rateLimiter)
 
 	// First, implicit defaults such as subdomain gateway on localhost
 	for hostname, gw := range defaultKnownGateways {
@@ -278,6 +390,12 @@ func prepareKnownGateways(publicGateways map[string]*config.GatewaySpec) gateway
 			delete(hosts.exact, hostname)
 			continue
 		}
+		if gw.RateLimit != nil {
+			rps := gw.RateLimit.RequestsPerSecond.WithDefault(0)
+			if rps > 0 {
+				hosts.limiters[gw] = newRateLimiter(rps, gw.RateLimit.Burst.WithDefault(rps))
+			}
+		}
 		if strings.Contains(hostname, "*") {
 			// from *.domain.tld, construct a regexp that match any direct subdomain
 			// of .domain.tld.
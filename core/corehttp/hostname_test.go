@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	cid "github.com/ipfs/go-cid"
 	"github.com/ipfs/go-libipfs/files"
@@ -302,6 +303,76 @@ func TestKnownSubdomainDetails(t *testing.T) {
 
 }
 
+func TestIsTrustlessRequest(t *testing.T) {
+	for _, test := range []struct {
+		url    string
+		accept string
+		out    bool
+	}{
+		{"http://example.com/ipfs/cid", "", false},
+		{"http://example.com/ipfs/cid", "text/html", false},
+		{"http://example.com/ipfs/cid?format=raw", "", true},
+		{"http://example.com/ipfs/cid?format=car", "", true},
+		{"http://example.com/ipfs/cid?format=tar", "", false},
+		{"http://example.com/ipfs/cid", "application/vnd.ipld.raw", true},
+		{"http://example.com/ipfs/cid", "text/html, application/vnd.ipld.car; version=1", true},
+		{"http://example.com/ipns/name", "application/vnd.ipfs.ipns-record", true},
+	} {
+		r := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if out := isTrustlessRequest(r); out != test.out {
+			t.Errorf("isTrustlessRequest(%s, %q) = %t, expected %t", test.url, test.accept, out, test.out)
+		}
+	}
+}
+
+func TestHostPolicyRateLimit(t *testing.T) {
+	gw := &config.GatewaySpec{
+		Paths: []string{"/ipfs"},
+		RateLimit: &config.GatewayRateLimit{
+			RequestsPerSecond: config.NewOptionalInteger(1),
+			Burst:             config.NewOptionalInteger(2),
+		},
+		HTTPHeaders: map[string][]string{"x-site": {"example"}},
+	}
+	knownGateways := prepareKnownGateways(map[string]*config.GatewaySpec{"example.com": gw})
+	limiter := knownGateways.limiters[gw]
+	if limiter == nil {
+		t.Fatal("expected rate limiter for example.com")
+	}
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		hw, ok := knownGateways.applyHostPolicy(w, gw)
+		if expected := i < 2; ok != expected {
+			t.Fatalf("request %d: ok is %t, expected %t", i, ok, expected)
+		}
+		if !ok {
+			if w.Code != http.StatusTooManyRequests {
+				t.Errorf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+			}
+			if w.Header().Get("Retry-After") != "1" {
+				t.Errorf("expected Retry-After: 1, got %q", w.Header().Get("Retry-After"))
+			}
+			continue
+		}
+		hw.WriteHeader(http.StatusOK)
+		if w.Header().Get("X-Site") != "example" {
+			t.Errorf("expected hostname header to be set")
+		}
+	}
+
+	// token is refilled after a second
+	now = now.Add(time.Second)
+	if _, ok := knownGateways.applyHostPolicy(httptest.NewRecorder(), gw); !ok {
+		t.Error("expected request to be allowed after refill")
+	}
+}
+
 func equalError(a, b error) bool {
 	return (a == nil && b == nil) || (a != nil && b != nil && a.Error() == b.Error())
 }
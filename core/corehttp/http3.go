@@ -0,0 +1,73 @@
+package corehttp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/lucas-clemente/quic-go/http3"
+
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+)
+
+// HTTP3Option serves the handlers of the options after it over HTTP/3 too,
+// on the UDP port of the TCP listener, and advertises the HTTP/3 server in
+// the Alt-Svc header of the responses. The relative paths of the
+// certificate are relative to repoRoot. It does nothing for the listeners
+// other than TCP, such as unix sockets.
+func HTTP3Option(h3 *config.HTTP3, repoRoot string) ServeOption {
+	return func(n *core.IpfsNode, l net.Listener, parent *http.ServeMux) (*http.ServeMux, error) {
+		tcpAddr, ok := l.Addr().(*net.TCPAddr)
+		if !ok {
+			return parent, nil
+		}
+
+		certFile, keyFile := h3.CertFile.WithDefault(""), h3.KeyFile.WithDefault("")
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("HTTP3: CertFile and KeyFile are required")
+		}
+		if !filepath.IsAbs(certFile) {
+			certFile = filepath.Join(repoRoot, certFile)
+		}
+		if !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(repoRoot, keyFile)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP3: %w", err)
+		}
+
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: tcpAddr.IP, Port: tcpAddr.Port, Zone: tcpAddr.Zone})
+		if err != nil {
+			return nil, fmt.Errorf("HTTP3: %w", err)
+		}
+
+		mux := http.NewServeMux()
+		server := &http3.Server{
+			Handler:   mux,
+			TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS13},
+		}
+		go func() {
+			if err := server.Serve(conn); err != nil && !errors.Is(err, net.ErrClosed) && !errors.Is(err, http.ErrServerClosed) {
+				log.Errorf("HTTP/3 server on %s failed: %s", conn.LocalAddr(), err)
+			}
+		}()
+		go func() {
+			<-n.Process.Closing()
+			server.Close()
+			conn.Close()
+		}()
+		log.Infof("HTTP/3 server listening on %s", conn.LocalAddr())
+
+		altSvc := fmt.Sprintf(`h3=":%d"; ma=%d`, tcpAddr.Port, int(h3.AltSvcMaxAge.WithDefault(config.DefaultHTTP3AltSvcMaxAge).Seconds()))
+		parent.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Alt-Svc", altSvc)
+			mux.ServeHTTP(w, r)
+		})
+		return mux, nil
+	}
+}
@@ -0,0 +1,83 @@
+package corehttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/stretchr/testify/require"
+
+	config "github.com/ipfs/kubo/config"
+)
+
+// writeTestCert writes a self-signed certificate of 127.0.0.1 and its key
+// in dir.
+func writeTestCert(t *testing.T, dir string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
+
+func TestHTTP3Option(t *testing.T) {
+	n, err := newNodeWithMockNamesys(mockNamesys{})
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close() })
+
+	dir := t.TempDir()
+	writeTestCert(t, dir)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	port := l.Addr().(*net.TCPAddr).Port
+
+	h3 := &config.HTTP3{
+		CertFile:     config.NewOptionalString("cert.pem"),
+		KeyFile:      config.NewOptionalString("key.pem"),
+		AltSvcMaxAge: config.NewOptionalDuration(time.Hour),
+	}
+	root := http.NewServeMux()
+	mux, err := HTTP3Option(h3, dir)(n, l, root)
+	require.NoError(t, err)
+	mux.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+
+	rec := httptest.NewRecorder()
+	root.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+	require.Equal(t, "HTTP/1.1", rec.Body.String())
+	require.Equal(t, fmt.Sprintf(`h3=":%d"; ma=3600`, port), rec.Header().Get("Alt-Svc"))
+
+	rt := &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}} //nolint:gosec
+	t.Cleanup(func() { rt.Close() })
+	resp, err := (&http.Client{Transport: rt}).Get(fmt.Sprintf("https://127.0.0.1:%d/test", port))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "HTTP/3.0", string(body))
+}
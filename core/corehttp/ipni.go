@@ -0,0 +1,20 @@
+package corehttp
+
+import (
+	"net"
+	"net/http"
+
+	core "github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/ipni"
+)
+
+// IPNIOption serves the IPNI advertisements of the node, fetched by the
+// indexers they are announced to, when Routing.IPNI is enabled.
+func IPNIOption() ServeOption {
+	return func(n *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		if n.IPNIPublisher != nil {
+			mux.Handle(ipni.HTTPPath, n.IPNIPublisher)
+		}
+		return mux, nil
+	}
+}
@@ -0,0 +1,217 @@
+package corehttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/kubo/core/jobs"
+)
+
+// asyncQueryParam makes an RPC request of asyncCommands run as a background
+// job, e.g. POST /api/v0/add?async=true.
+const asyncQueryParam = "async"
+
+// asyncCommands are the RPC commands which can run as background jobs, by
+// path, with the predicate telling their progress events from their results.
+var asyncCommands = map[string]func(v map[string]interface{}) bool{
+	// progress events of 'ipfs add --progress' have no hash
+	"/add": func(v map[string]interface{}) bool { return v["Hash"] == nil },
+	// progress events of 'ipfs pin add --progress' have no pins
+	"/pin/add":    func(v map[string]interface{}) bool { return v["Pins"] == nil },
+	"/dag/import": nil,
+	"/dag/export": nil,
+	"/repo/gc":    nil,
+}
+
+// asyncJobHandler runs the requests of asyncCommands with the async query
+// parameter as jobs of m, in place of next, and responds with the ID of the
+// job. The job keeps running when the client disconnects: its progress,
+// results and output are queried with 'ipfs jobs'.
+type asyncJobHandler struct {
+	m    *jobs.Manager
+	next http.Handler
+}
+
+// asyncJobOutput is the response to an async request.
+type asyncJobOutput struct {
+	Job string
+}
+
+func (h *asyncJobHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, APIPath)
+	isProgress, ok := asyncCommands[path]
+	if !ok || r.URL.Query().Get(asyncQueryParam) != "true" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// the body is spooled to a file, read by the job once the client is gone
+	ready := make(chan error, 1)
+	command := strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", " ")
+	id := h.m.Start(command, func(ctx context.Context, jh *jobs.Handle) error {
+		dir, err := jh.TempDir()
+		if err != nil {
+			ready <- err
+			return err
+		}
+		defer os.RemoveAll(dir)
+		body, err := spoolBody(r.Body, dir)
+		if err != nil {
+			ready <- err
+			return err
+		}
+		defer body.Close()
+
+		jr := r.Clone(ctx)
+		jr.Body = body
+		q := jr.URL.Query()
+		q.Del(asyncQueryParam)
+		jr.URL.RawQuery = q.Encode()
+		ready <- nil
+
+		return runJob(jh, h.next, jr, isProgress)
+	})
+	if err := <-ready; err != nil {
+		http.Error(w, fmt.Sprintf("starting job %s: %s", id, err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(asyncJobOutput{Job: id})
+}
+
+func spoolBody(body io.Reader, dir string) (*os.File, error) {
+	f, err := os.Create(filepath.Join(dir, "body"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// runJob serves r with next, recording the response in the job: the values
+// of a JSON response as its progress and results, and any other response as
+// its output.
+func runJob(jh *jobs.Handle, next http.Handler, r *http.Request, isProgress func(map[string]interface{}) bool) error {
+	jw := newJobResponseWriter()
+	consumed := make(chan error, 1)
+	go func() {
+		<-jw.started
+		err := consumeResponse(jh, jw, isProgress)
+		// unblock the handler if the response is no longer read
+		io.Copy(io.Discard, jw.pr)
+		consumed <- err
+	}()
+
+	next.ServeHTTP(jw, r)
+	jw.WriteHeader(http.StatusOK)
+	jw.pw.Close()
+	if err := <-consumed; err != nil {
+		return err
+	}
+	// set by the commands handler when a streaming command fails
+	if msg := jw.header.Get(cmdsStreamErrorHeader); msg != "" {
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// cmdsStreamErrorHeader is the trailer in which the commands handler reports
+// the error of a command which already started its response.
+const cmdsStreamErrorHeader = "X-Stream-Error"
+
+func consumeResponse(jh *jobs.Handle, jw *jobResponseWriter, isProgress func(map[string]interface{}) bool) error {
+	if jw.status >= http.StatusBadRequest {
+		var e struct{ Message string }
+		if err := json.NewDecoder(jw.pr).Decode(&e); err != nil || e.Message == "" {
+			return errors.New(http.StatusText(jw.status))
+		}
+		return errors.New(e.Message)
+	}
+
+	if !strings.HasPrefix(jw.contentType, "application/json") {
+		f, err := jh.CreateOutput()
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, jw.pr); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	dec := json.NewDecoder(jw.pr)
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if m, ok := v.(map[string]interface{}); ok && isProgress != nil && isProgress(m) {
+			jh.SetProgress(v)
+		} else {
+			jh.AddResult(v)
+		}
+	}
+}
+
+// jobResponseWriter pipes the response of a job to consumeResponse.
+type jobResponseWriter struct {
+	header http.Header
+	pr     *io.PipeReader
+	pw     *io.PipeWriter
+
+	// set before started is closed
+	status      int
+	contentType string
+	started     chan struct{}
+}
+
+func newJobResponseWriter() *jobResponseWriter {
+	pr, pw := io.Pipe()
+	return &jobResponseWriter{
+		header:  make(http.Header),
+		pr:      pr,
+		pw:      pw,
+		started: make(chan struct{}),
+	}
+}
+
+func (jw *jobResponseWriter) Header() http.Header {
+	return jw.header
+}
+
+func (jw *jobResponseWriter) WriteHeader(status int) {
+	if jw.status != 0 {
+		return
+	}
+	jw.status = status
+	jw.contentType = jw.header.Get("Content-Type")
+	close(jw.started)
+}
+
+func (jw *jobResponseWriter) Write(p []byte) (int, error) {
+	jw.WriteHeader(http.StatusOK)
+	return jw.pw.Write(p)
+}
+
+// Flush is a no-op: the writes aren't buffered.
+func (jw *jobResponseWriter) Flush() {}
@@ -0,0 +1,71 @@
+package corehttp
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter is a simple token bucket used to throttle requests to the
+// gateway, or to a single gateway hostname.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRateLimiter(rps, burst int64) *rateLimiter {
+	if burst < 1 {
+		burst = rps
+	}
+	return &rateLimiter{
+		rate:   float64(rps),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		now:    time.Now,
+	}
+}
+
+// allow consumes a token if one is available. When the bucket is empty it
+// returns false and the time after which a token will be available again.
+func (l *rateLimiter) allow() (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if !l.last.IsZero() {
+		elapsed := now.Sub(l.last).Seconds()
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// limitRequests rejects the requests to next over the limit of l.
+func limitRequests(l *rateLimiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if allowed, wait := l.allow(); !allowed {
+			tooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tooManyRequests responds with HTTP 429, telling the client to retry after
+// wait.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
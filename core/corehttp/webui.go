@@ -1,5 +1,16 @@
 package corehttp
 
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+)
+
 // TODO: move to IPNS
 const WebUIPath = "/ipfs/bafybeifeqt7mvxaniphyu2i3qhovjaf3sayooxbh5enfdqtiehxjv2ldte" // v2.22.0
 
@@ -56,3 +67,39 @@ var WebUIPaths = []string{
 }
 
 var WebUIOption = RedirectOption("webui", WebUIPath)
+
+// WebUIConfigOption returns the ServeOption for the WebUI configured in
+// API.WebUI, together with the content paths the API gateway has to allow for
+// it to load. When nothing is configured, the bundled WebUIPath is used.
+func WebUIConfigOption(cfg config.WebUI) (ServeOption, []string, error) {
+	if dir := cfg.Dir.WithDefault(""); dir != "" {
+		fi, err := os.Stat(dir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("API.WebUI.Dir: %w", err)
+		}
+		if !fi.IsDir() {
+			return nil, nil, fmt.Errorf("API.WebUI.Dir: %q is not a directory", dir)
+		}
+		return WebUIDirOption(dir), WebUIPaths, nil
+	}
+
+	if p := cfg.Path.WithDefault(""); p != "" {
+		if !strings.HasPrefix(p, "/ipfs/") {
+			return nil, nil, fmt.Errorf("API.WebUI.Path: %q is not an /ipfs/ path", p)
+		}
+		p = strings.TrimSuffix(p, "/")
+		paths := append([]string{p}, WebUIPaths...)
+		return RedirectOption("webui", p), paths, nil
+	}
+
+	return WebUIOption, WebUIPaths, nil
+}
+
+// WebUIDirOption serves a WebUI build from a local directory at /webui/.
+func WebUIDirOption(dir string) ServeOption {
+	handler := http.StripPrefix("/webui/", http.FileServer(http.Dir(dir)))
+	return func(_ *core.IpfsNode, _ net.Listener, mux *http.ServeMux) (*http.ServeMux, error) {
+		mux.Handle("/webui/", handler)
+		return mux, nil
+	}
+}
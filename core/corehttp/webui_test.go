@@ -0,0 +1,67 @@
+package corehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func serveWebUI(t *testing.T, opt ServeOption, path string) *httptest.ResponseRecorder {
+	mux, err := opt(nil, nil, http.NewServeMux())
+	require.NoError(t, err)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestWebUIConfigOption(t *testing.T) {
+	t.Run("bundled", func(t *testing.T) {
+		opt, paths, err := WebUIConfigOption(config.WebUI{})
+		require.NoError(t, err)
+		require.Equal(t, WebUIPaths, paths)
+
+		rec := serveWebUI(t, opt, "/webui/")
+		require.Equal(t, http.StatusFound, rec.Code)
+		require.Equal(t, WebUIPath, rec.Header().Get("Location"))
+	})
+
+	t.Run("pinned path", func(t *testing.T) {
+		const p = "/ipfs/bafybeihcyruaeza7uyjd6ugicbcrqumejf6uf353e5etdkhotqffwtguva"
+		opt, paths, err := WebUIConfigOption(config.WebUI{Path: config.NewOptionalString(p + "/")})
+		require.NoError(t, err)
+		require.Equal(t, p, paths[0])
+		require.Contains(t, paths, WebUIPath)
+
+		rec := serveWebUI(t, opt, "/webui/")
+		require.Equal(t, http.StatusFound, rec.Code)
+		require.Equal(t, p, rec.Header().Get("Location"))
+
+		_, _, err = WebUIConfigOption(config.WebUI{Path: config.NewOptionalString("/ipns/webui.ipfs.io")})
+		require.Error(t, err)
+	})
+
+	t.Run("local directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>webui</h1>"), 0o644))
+
+		opt, _, err := WebUIConfigOption(config.WebUI{Dir: config.NewOptionalString(dir)})
+		require.NoError(t, err)
+
+		rec := serveWebUI(t, opt, "/webui/")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "<h1>webui</h1>", rec.Body.String())
+
+		rec = serveWebUI(t, opt, "/webui/missing.js")
+		require.Equal(t, http.StatusNotFound, rec.Code)
+
+		_, _, err = WebUIConfigOption(config.WebUI{Dir: config.NewOptionalString(filepath.Join(dir, "index.html"))})
+		require.Error(t, err)
+		_, _, err = WebUIConfigOption(config.WebUI{Dir: config.NewOptionalString(filepath.Join(dir, "nope"))})
+		require.Error(t, err)
+	})
+}
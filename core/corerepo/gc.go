@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/quota"
 	"github.com/ipfs/kubo/gc"
 	"github.com/ipfs/kubo/repo"
 
@@ -88,11 +90,59 @@ func GarbageCollect(n *core.IpfsNode, ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	rmed := runGC(ctx, n, roots)
 
 	return CollectResult(ctx, rmed, nil)
 }
 
+// runGC runs a garbage collection and reports its start and completion on
+// the node event bus.
+func runGC(ctx context.Context, n *core.IpfsNode, roots []cid.Cid) <-chan gc.Result {
+	n.Events.Emit(events.GCStarted, nil)
+	start := time.Now()
+
+	// the pins and the MFS roots of the spaces are kept as well
+	pinning := n.Spaces.Pinner(n.Pinning)
+	if n.Spaces != nil {
+		spaceRoots, err := n.Spaces.FilesRoots(ctx)
+		if err != nil {
+			out := make(chan gc.Result, 1)
+			out <- gc.Result{Error: err}
+			close(out)
+			return out
+		}
+		roots = append(roots, spaceRoots...)
+	}
+
+	rmed := gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), pinning, roots)
+	if n.Events == nil {
+		return rmed
+	}
+
+	out := make(chan gc.Result, cap(rmed))
+	go func() {
+		defer close(out)
+		var removed, errs int
+		for res := range rmed {
+			if res.Error != nil {
+				errs++
+			} else if res.KeyRemoved.Defined() {
+				removed++
+			}
+			select {
+			case out <- res:
+			case <-ctx.Done():
+			}
+		}
+		n.Events.Emit(events.GCFinished, map[string]interface{}{
+			"Removed":  removed,
+			"Errors":   errs,
+			"Duration": time.Since(start).String(),
+		})
+	}()
+	return out
+}
+
 // CollectResult collects the output of a garbage collection run and calls the
 // given callback for each object removed.  It also collects all errors into a
 // MultiError which is returned after the gc is completed.
@@ -156,7 +206,7 @@ func GarbageCollectAsync(n *core.IpfsNode, ctx context.Context) <-chan gc.Result
 		return out
 	}
 
-	return gc.GC(ctx, n.Blockstore, n.Repo.Datastore(), n.Pinning, roots)
+	return runGC(ctx, n, roots)
 }
 
 func PeriodicGC(ctx context.Context, node *core.IpfsNode) error {
@@ -222,6 +272,22 @@ func (gc *GC) maybeGC(ctx context.Context, offset uint64) error {
 			return err
 		}
 		log.Infof("Repo GC done. See `ipfs repo stat` to see how much space got freed.\n")
+		return nil
+	}
+
+	// the cache quota is enforced even below the watermark
+	if gc.Node.Quotas.Quota(quota.Cache) != quota.NoLimit {
+		report, err := gc.Node.Quotas.Report(ctx)
+		if err != nil {
+			return err
+		}
+		if report.Cache.Size > report.Cache.Quota {
+			log.Info("Cache quota exceeded. Starting repo GC...")
+			if err := GarbageCollect(gc.Node, ctx); err != nil {
+				return err
+			}
+			log.Infof("Repo GC done. See `ipfs repo stat --detailed` to see how much space got freed.\n")
+		}
 	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 	context "context"
 
 	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/quota"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 
 	humanize "github.com/dustin/go-humanize"
@@ -24,6 +25,9 @@ type Stat struct {
 	NumObjects uint64
 	RepoPath   string
 	Version    string
+
+	Namespaces *quota.Report   `json:",omitempty"` // set by 'repo stat --detailed'
+	Forecast   *quota.Forecast `json:",omitempty"` // set by 'repo stat --forecast'
 }
 
 // NoLimit represents the value for unlimited storage
@@ -89,3 +93,13 @@ func RepoSize(ctx context.Context, n *core.IpfsNode) (SizeStat, error) {
 		StorageMax: storageMax,
 	}, nil
 }
+
+// RepoForecast forecasts the growth of the repo from the daily samples
+// recorded by the daemon.
+func RepoForecast(ctx context.Context, n *core.IpfsNode, sizeStat SizeStat) (*quota.Forecast, error) {
+	samples, err := quota.Samples(ctx, n.Repo.Datastore())
+	if err != nil {
+		return nil, err
+	}
+	return quota.NewForecast(samples, sizeStat.RepoSize, sizeStat.StorageMax), nil
+}
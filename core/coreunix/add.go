@@ -9,6 +9,7 @@ import (
 	"strconv"
 
 	"github.com/ipfs/go-cid"
+	cidutil "github.com/ipfs/go-cidutil"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	chunker "github.com/ipfs/go-ipfs-chunker"
 	pin "github.com/ipfs/go-ipfs-pinner"
@@ -23,6 +24,7 @@ import (
 	ihelper "github.com/ipfs/go-unixfs/importer/helpers"
 	"github.com/ipfs/go-unixfs/importer/trickle"
 	coreiface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
 	"github.com/ipfs/interface-go-ipfs-core/path"
 	"github.com/ipfs/kubo/tracing"
 )
@@ -104,6 +106,37 @@ func (adder *Adder) SetMfsRoot(r *mfs.Root) {
 	adder.mroot = r
 }
 
+// Configure applies the settings of a unixfs add operation to the adder.
+func (adder *Adder) Configure(settings *options.UnixfsAddSettings, prefix cid.Prefix) error {
+	adder.Chunker = settings.Chunker
+	if settings.Events != nil {
+		adder.Out = settings.Events
+		adder.Progress = settings.Progress
+	}
+	adder.Pin = settings.Pin && !settings.OnlyHash
+	adder.Silent = settings.Silent
+	adder.RawLeaves = settings.RawLeaves
+	adder.NoCopy = settings.NoCopy
+	adder.CidBuilder = prefix
+
+	switch settings.Layout {
+	case options.BalancedLayout:
+		// Default
+	case options.TrickleLayout:
+		adder.Trickle = true
+	default:
+		return fmt.Errorf("unknown layout: %d", settings.Layout)
+	}
+
+	if settings.Inline {
+		adder.CidBuilder = cidutil.InlineBuilder{
+			Builder: adder.CidBuilder,
+			Limit:   settings.InlineLimit,
+		}
+	}
+	return nil
+}
+
 // Constructs a node from reader's data, and adds it. Doesn't pin.
 func (adder *Adder) add(reader io.Reader) (ipld.Node, error) {
 	chnk, err := chunker.FromString(reader, adder.Chunker)
@@ -0,0 +1,202 @@
+package coreunix
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	ipldlegacy "github.com/ipfs/go-ipld-legacy"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+	car "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// CarWriter is a DAGService writing the added nodes to a CAR instead of the
+// repo. Nodes are appended to a temporary file as they are added, so that
+// they can be read back while building directories, and the CAR is assembled
+// by Finalize once the roots are known.
+type CarWriter struct {
+	mu       sync.Mutex
+	tmp      *os.File
+	end      int64
+	sections map[cid.Cid]carSection
+}
+
+type carSection struct {
+	offset int64
+	size   int
+}
+
+var _ ipld.DAGService = (*CarWriter)(nil)
+
+// NewCarWriter returns a CarWriter, with its temporary file in the directory
+// for temporary files.
+func NewCarWriter() (*CarWriter, error) {
+	tmp, err := os.CreateTemp("", "ipfs-add-*.car.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &CarWriter{
+		tmp:      tmp,
+		sections: make(map[cid.Cid]carSection),
+	}, nil
+}
+
+// Add implements ipld.DAGService.
+func (w *CarWriter) Add(ctx context.Context, nd ipld.Node) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.tmp == nil {
+		return fmt.Errorf("car writer is closed")
+	}
+	if _, ok := w.sections[nd.Cid()]; ok {
+		return nil
+	}
+	data := nd.RawData()
+	if _, err := w.tmp.WriteAt(data, w.end); err != nil {
+		return err
+	}
+	w.sections[nd.Cid()] = carSection{offset: w.end, size: len(data)}
+	w.end += int64(len(data))
+	return nil
+}
+
+// AddMany implements ipld.DAGService.
+func (w *CarWriter) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := w.Add(ctx, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get implements ipld.NodeGetter.
+func (w *CarWriter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	data, err := w.read(c)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return nil, err
+	}
+	return ipldlegacy.DecodeNode(ctx, blk)
+}
+
+func (w *CarWriter) read(c cid.Cid) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s, ok := w.sections[c]
+	if !ok || w.tmp == nil {
+		return nil, ipld.ErrNotFound{Cid: c}
+	}
+	data := make([]byte, s.size)
+	if _, err := w.tmp.ReadAt(data, s.offset); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// GetMany implements ipld.NodeGetter.
+func (w *CarWriter) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	for _, c := range cids {
+		nd, err := w.Get(ctx, c)
+		out <- &ipld.NodeOption{Node: nd, Err: err}
+	}
+	close(out)
+	return out
+}
+
+// Remove implements ipld.DAGService. The data stays in the temporary file
+// but is not written to the CAR.
+func (w *CarWriter) Remove(ctx context.Context, c cid.Cid) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.sections, c)
+	return nil
+}
+
+// RemoveMany implements ipld.DAGService.
+func (w *CarWriter) RemoveMany(ctx context.Context, cids []cid.Cid) error {
+	for _, c := range cids {
+		if err := w.Remove(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Finalize writes the CARv1 with the given roots and the blocks reachable
+// from them to out, in depth-first order, then closes the writer.
+// Intermediate nodes that are no longer referenced are left out.
+func (w *CarWriter) Finalize(ctx context.Context, roots []cid.Cid, out io.Writer) (err error) {
+	defer func() {
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	bw := bufio.NewWriter(out)
+	if err := car.WriteHeader(&car.CarHeader{Roots: roots, Version: 1}, bw); err != nil {
+		return err
+	}
+
+	seen := cid.NewSet()
+	var walk func(c cid.Cid) error
+	walk = func(c cid.Cid) error {
+		if !seen.Visit(c) || c.Prefix().MhType == mh.IDENTITY {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		nd, err := w.Get(ctx, c)
+		if err != nil {
+			return err
+		}
+		if err := carutil.LdWrite(bw, c.Bytes(), nd.RawData()); err != nil {
+			return err
+		}
+		for _, l := range nd.Links() {
+			if err := walk(l.Cid); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, r := range roots {
+		if err := walk(r); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Close removes the temporary file. The CAR is not written unless Finalize
+// was called.
+func (w *CarWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.tmp == nil {
+		return nil
+	}
+	name := w.tmp.Name()
+	err := w.tmp.Close()
+	w.tmp = nil
+	if rerr := os.Remove(name); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,79 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/ipfs/go-libipfs/files"
+	car "github.com/ipld/go-car"
+)
+
+func TestCarWriter(t *testing.T) {
+	ctx := context.Background()
+	w, err := NewCarWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := w.tmp.Name()
+
+	adder, err := NewAdder(ctx, nil, blockstore.NewGCLocker(), w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	adder.Pin = false
+	adder.Chunker = "size-4"
+
+	root, err := adder.AddAllAndPin(ctx, files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile([]byte("some data for file a")),
+		"b": files.NewBytesFile([]byte("file b")),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := w.Finalize(ctx, []cid.Cid{root.Cid()}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	// the temporary file is removed
+	if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", tmp, err)
+	}
+
+	cr, err := car.NewCarReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cr.Header.Roots) != 1 || !cr.Header.Roots[0].Equals(root.Cid()) {
+		t.Fatalf("unexpected roots %v", cr.Header.Roots)
+	}
+
+	var count int
+	seen := cid.NewSet()
+	for {
+		blk, err := cr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !seen.Visit(blk.Cid()) {
+			t.Fatalf("duplicate block %s", blk.Cid())
+		}
+		count++
+	}
+	// root directory, two files chunked in 5 and 2 leaves under a node each
+	if count != 10 {
+		t.Fatalf("expected 10 blocks, got %d", count)
+	}
+	if _, err := w.Get(ctx, root.Cid()); err == nil {
+		t.Fatal("expected finalized writer to be closed")
+	}
+}
@@ -0,0 +1,182 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	chunker "github.com/ipfs/go-ipfs-chunker"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-unixfs/importer/balanced"
+	ihelper "github.com/ipfs/go-unixfs/importer/helpers"
+)
+
+// DedupStats counts the blocks the files analyzed by a DedupAnalyzer would be
+// imported as with a chunker, and how many bytes of them are deduplicated.
+type DedupStats struct {
+	Chunker string
+	Blocks  uint64
+	Size    uint64 // of all the blocks
+	// New is the size of the blocks which would be added to the repo.
+	New uint64
+	// InRepo is the size of the blocks already in the repo.
+	InRepo uint64
+	// Repeated is the size of the blocks found earlier in the files.
+	Repeated uint64
+}
+
+// DedupAnalyzer imports files with several chunkers without storing them,
+// and counts the blocks already in a blockstore or repeated in the files. The
+// hashes of the blocks seen are kept in memory.
+type DedupAnalyzer struct {
+	bs         bstore.Blockstore
+	rawLeaves  bool
+	cidBuilder cid.Builder
+
+	// Input is the number of bytes of the files analyzed.
+	Input uint64
+	stats []DedupStats
+	seen  []map[string]struct{}
+}
+
+// NewDedupAnalyzer returns an analyzer comparing the chunkers, in the format
+// of 'ipfs add --chunker', against the blocks of bs.
+func NewDedupAnalyzer(bs bstore.Blockstore, chunkers []string, rawLeaves bool, cidBuilder cid.Builder) (*DedupAnalyzer, error) {
+	if len(chunkers) == 0 {
+		return nil, errors.New("no chunker to analyze")
+	}
+	a := &DedupAnalyzer{bs: bs, rawLeaves: rawLeaves, cidBuilder: cidBuilder}
+	for _, c := range chunkers {
+		if _, err := chunker.FromString(bytes.NewReader(nil), c); err != nil {
+			return nil, err
+		}
+		a.stats = append(a.stats, DedupStats{Chunker: c})
+		a.seen = append(a.seen, make(map[string]struct{}))
+	}
+	return a, nil
+}
+
+// AddFile imports the content of a file with every chunker, reading it once.
+func (a *DedupAnalyzer) AddFile(ctx context.Context, r io.Reader) error {
+	writers := make([]io.Writer, len(a.stats))
+	pipes := make([]*io.PipeWriter, len(a.stats))
+	errs := make(chan error, len(a.stats))
+	for i := range a.stats {
+		pr, pw := io.Pipe()
+		writers[i], pipes[i] = pw, pw
+		go func(i int) {
+			err := a.layout(ctx, i, pr)
+			// unblock the writes if the import stopped early
+			pr.CloseWithError(err)
+			errs <- err
+		}(i)
+	}
+
+	n, err := io.Copy(io.MultiWriter(writers...), r)
+	a.Input += uint64(n)
+	for _, pw := range pipes {
+		pw.CloseWithError(err)
+	}
+	for range a.stats {
+		if layoutErr := <-errs; layoutErr != nil && err == nil {
+			err = layoutErr
+		}
+	}
+	return err
+}
+
+func (a *DedupAnalyzer) layout(ctx context.Context, i int, r io.Reader) error {
+	chnk, err := chunker.FromString(r, a.stats[i].Chunker)
+	if err != nil {
+		return err
+	}
+	params := ihelper.DagBuilderParams{
+		Dagserv:    &dedupDAG{ctx: ctx, a: a, i: i},
+		RawLeaves:  a.rawLeaves,
+		Maxlinks:   ihelper.DefaultLinksPerBlock,
+		CidBuilder: a.cidBuilder,
+	}
+	db, err := params.New(chnk)
+	if err != nil {
+		return err
+	}
+	_, err = balanced.Layout(db)
+	return err
+}
+
+// Stats returns the statistics of each chunker, in the order they were
+// given.
+func (a *DedupAnalyzer) Stats() []DedupStats {
+	return append([]DedupStats(nil), a.stats...)
+}
+
+// dedupDAG counts the nodes added with the i-th chunker of an analyzer
+// instead of storing them.
+type dedupDAG struct {
+	ctx context.Context
+	a   *DedupAnalyzer
+	i   int
+}
+
+var _ ipld.DAGService = (*dedupDAG)(nil)
+
+func (d *dedupDAG) Add(ctx context.Context, nd ipld.Node) error {
+	if err := d.ctx.Err(); err != nil {
+		return err
+	}
+	s := &d.a.stats[d.i]
+	size := uint64(len(nd.RawData()))
+	s.Blocks++
+	s.Size += size
+
+	key := string(nd.Cid().Hash())
+	if _, ok := d.a.seen[d.i][key]; ok {
+		s.Repeated += size
+		return nil
+	}
+	d.a.seen[d.i][key] = struct{}{}
+
+	has, err := d.a.bs.Has(d.ctx, nd.Cid())
+	if err != nil {
+		return err
+	}
+	if has {
+		s.InRepo += size
+	} else {
+		s.New += size
+	}
+	return nil
+}
+
+func (d *dedupDAG) AddMany(ctx context.Context, nds []ipld.Node) error {
+	for _, nd := range nds {
+		if err := d.Add(ctx, nd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dedupDAG) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	return nil, ipld.ErrNotFound{Cid: c}
+}
+
+func (d *dedupDAG) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption, len(cids))
+	for _, c := range cids {
+		out <- &ipld.NodeOption{Err: ipld.ErrNotFound{Cid: c}}
+	}
+	close(out)
+	return out
+}
+
+func (d *dedupDAG) Remove(ctx context.Context, c cid.Cid) error {
+	return nil
+}
+
+func (d *dedupDAG) RemoveMany(ctx context.Context, cids []cid.Cid) error {
+	return nil
+}
@@ -0,0 +1,54 @@
+package coreunix
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+func TestDedupAnalyzer(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(syncds.MutexWrap(datastore.NewMapDatastore()))
+
+	half := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(half)
+	// the first chunk of 1KiB is already in the repo
+	if err := bs.Put(ctx, dag.NewRawNode(half[:1024])); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewDedupAnalyzer(bs, []string{"size-1024", "size-8192"}, true, dag.V1CidPrefix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AddFile(ctx, bytes.NewReader(append(half, half...))); err != nil {
+		t.Fatal(err)
+	}
+	if a.Input != 8192 {
+		t.Errorf("expected 8192 bytes read, got %d", a.Input)
+	}
+
+	stats := a.Stats()
+	small, large := stats[0], stats[1]
+	// 8 leaves, the second half repeating the first one, and a root
+	if small.Blocks != 9 {
+		t.Errorf("expected 9 blocks with size-1024, got %d", small.Blocks)
+	}
+	if small.InRepo != 1024 || small.Repeated != 4096 || small.New != small.Size-1024-4096 {
+		t.Errorf("unexpected deduplication with size-1024: %+v", small)
+	}
+	// a single leaf, which is not in the repo
+	if large.Blocks != 1 || large.New != 8192 || large.InRepo != 0 || large.Repeated != 0 {
+		t.Errorf("unexpected deduplication with size-8192: %+v", large)
+	}
+
+	if _, err := NewDedupAnalyzer(bs, []string{"size-0"}, true, dag.V1CidPrefix()); err == nil {
+		t.Error("expected an error for an invalid chunker")
+	}
+}
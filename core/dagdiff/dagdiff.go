@@ -0,0 +1,205 @@
+// Package dagdiff computes the structural difference between two DAGs, e.g.
+// two versions of a website added with 'ipfs add -r'.
+//
+// UnixFS directories, sharded or not, are compared entry by entry. The other
+// nodes with links, e.g. dag-cbor, are compared link by link, by the names of
+// their links. Everything else is compared by CID only.
+package dagdiff
+
+import (
+	"context"
+	"sort"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	uio "github.com/ipfs/go-unixfs/io"
+)
+
+// ChangeType is the type of a change.
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Removed  ChangeType = "removed"
+	Modified ChangeType = "modified"
+)
+
+// Change is a path added, removed or modified between the two DAGs.
+type Change struct {
+	Type ChangeType
+	// Path from the roots, "" for the roots themselves
+	Path string
+	// Before is undefined for added paths, After for removed paths
+	Before cid.Cid
+	After  cid.Cid
+}
+
+// Blocks counts the blocks of the changed parts of the DAGs: the blocks only
+// in the changed parts of b are added, the ones only in the changed parts of
+// a are removed.
+type Blocks struct {
+	Added        uint64
+	AddedBytes   uint64
+	Removed      uint64
+	RemovedBytes uint64
+}
+
+// Result is the difference between two DAGs.
+type Result struct {
+	Changes []Change
+	Blocks  Blocks
+}
+
+type differ struct {
+	ctx context.Context
+	dag ipld.DAGService
+	// the subtrees found in both DAGs
+	shared map[cid.Cid]struct{}
+	out    []Change
+}
+
+// Diff returns the difference between the DAGs under a and b, fetching their
+// nodes from dag. The changes are sorted by path.
+func Diff(ctx context.Context, dag ipld.DAGService, a, b cid.Cid) (*Result, error) {
+	d := &differ{
+		ctx:    ctx,
+		dag:    dag,
+		shared: make(map[cid.Cid]struct{}),
+	}
+	if err := d.diff("", a, b); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(d.out, func(i, j int) bool { return d.out[i].Path < d.out[j].Path })
+
+	blocksA, err := d.blocks(a)
+	if err != nil {
+		return nil, err
+	}
+	blocksB, err := d.blocks(b)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Changes: d.out}
+	for c, size := range blocksB {
+		if _, ok := blocksA[c]; !ok {
+			res.Blocks.Added++
+			res.Blocks.AddedBytes += size
+		}
+	}
+	for c, size := range blocksA {
+		if _, ok := blocksB[c]; !ok {
+			res.Blocks.Removed++
+			res.Blocks.RemovedBytes += size
+		}
+	}
+	return res, nil
+}
+
+func (d *differ) diff(path string, a, b cid.Cid) error {
+	if a.Equals(b) {
+		d.shared[a] = struct{}{}
+		return nil
+	}
+
+	na, err := d.dag.Get(d.ctx, a)
+	if err != nil {
+		return err
+	}
+	nb, err := d.dag.Get(d.ctx, b)
+	if err != nil {
+		return err
+	}
+	linksA, okA, err := d.children(na)
+	if err != nil {
+		return err
+	}
+	linksB, okB, err := d.children(nb)
+	if err != nil {
+		return err
+	}
+	if !okA || !okB {
+		d.out = append(d.out, Change{Type: Modified, Path: path, Before: a, After: b})
+		return nil
+	}
+
+	changes := len(d.out)
+	for name, ca := range linksA {
+		cb, ok := linksB[name]
+		if !ok {
+			d.out = append(d.out, Change{Type: Removed, Path: join(path, name), Before: ca})
+			continue
+		}
+		if err := d.diff(join(path, name), ca, cb); err != nil {
+			return err
+		}
+	}
+	for name, cb := range linksB {
+		if _, ok := linksA[name]; !ok {
+			d.out = append(d.out, Change{Type: Added, Path: join(path, name), After: cb})
+		}
+	}
+	if len(d.out) == changes {
+		// only the node itself changed, e.g. the metadata of a directory
+		d.out = append(d.out, Change{Type: Modified, Path: path, Before: a, After: b})
+	}
+	return nil
+}
+
+// children returns the links of nd by name, and false if nd isn't compared by
+// its children.
+func (d *differ) children(nd ipld.Node) (map[string]cid.Cid, bool, error) {
+	links := make(map[string]cid.Cid)
+	if nd.Cid().Prefix().Codec != cid.DagProtobuf {
+		if len(nd.Links()) == 0 {
+			return nil, false, nil
+		}
+		for _, l := range nd.Links() {
+			links[l.Name] = l.Cid
+		}
+		return links, true, nil
+	}
+
+	dir, err := uio.NewDirectoryFromNode(d.dag, nd)
+	if err != nil {
+		// files, and dag-pb nodes that aren't UnixFS
+		return nil, false, nil
+	}
+	err = dir.ForEachLink(d.ctx, func(l *ipld.Link) error {
+		links[l.Name] = l.Cid
+		return nil
+	})
+	return links, err == nil, err
+}
+
+// blocks returns the sizes of the blocks under root, except the shared
+// subtrees.
+func (d *differ) blocks(root cid.Cid) (map[cid.Cid]uint64, error) {
+	out := make(map[cid.Cid]uint64)
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if _, ok := d.shared[c]; ok {
+			continue
+		}
+		if _, ok := out[c]; ok {
+			continue
+		}
+		nd, err := d.dag.Get(d.ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		out[c] = uint64(len(nd.RawData()))
+		for _, l := range nd.Links() {
+			queue = append(queue, l.Cid)
+		}
+	}
+	return out, nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "/" + name
+}
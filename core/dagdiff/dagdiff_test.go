@@ -0,0 +1,75 @@
+package dagdiff
+
+import (
+	"context"
+	"testing"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/stretchr/testify/require"
+)
+
+func addDir(t *testing.T, ds ipld.DAGService, entries map[string]ipld.Node) ipld.Node {
+	ctx := context.Background()
+	dir := uio.NewDirectory(ds)
+	for name, nd := range entries {
+		require.NoError(t, ds.Add(ctx, nd))
+		require.NoError(t, dir.AddChild(ctx, name, nd))
+	}
+	nd, err := dir.GetNode()
+	require.NoError(t, err)
+	require.NoError(t, ds.Add(ctx, nd))
+	return nd
+}
+
+func TestDiff(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	keep := dag.NewRawNode([]byte("keep"))
+	x := dag.NewRawNode([]byte("x"))
+	change := dag.NewRawNode([]byte("change"))
+	changed := dag.NewRawNode([]byte("changed"))
+	gone := dag.NewRawNode([]byte("gone"))
+	added := dag.NewRawNode([]byte("new"))
+	y := dag.NewRawNode([]byte("y"))
+
+	a := addDir(t, ds, map[string]ipld.Node{
+		"keep":   keep,
+		"change": change,
+		"gone":   gone,
+		"sub":    addDir(t, ds, map[string]ipld.Node{"x": x}),
+	})
+	b := addDir(t, ds, map[string]ipld.Node{
+		"keep":   keep,
+		"change": changed,
+		"new":    added,
+		"sub":    addDir(t, ds, map[string]ipld.Node{"x": x, "y": y}),
+	})
+
+	res, err := Diff(ctx, ds, a.Cid(), b.Cid())
+	require.NoError(t, err)
+	require.Equal(t, []Change{
+		{Type: Modified, Path: "change", Before: change.Cid(), After: changed.Cid()},
+		{Type: Removed, Path: "gone", Before: gone.Cid()},
+		{Type: Added, Path: "new", After: added.Cid()},
+		{Type: Added, Path: "sub/y", After: y.Cid()},
+	}, res.Changes)
+	// the roots, sub, and the changed files
+	require.Equal(t, uint64(5), res.Blocks.Added)
+	require.Equal(t, uint64(4), res.Blocks.Removed)
+	require.Greater(t, res.Blocks.AddedBytes, uint64(len("changed")+len("new")+len("y")))
+	require.Greater(t, res.Blocks.RemovedBytes, uint64(len("change")+len("gone")))
+
+	res, err = Diff(ctx, ds, a.Cid(), a.Cid())
+	require.NoError(t, err)
+	require.Empty(t, res.Changes)
+	require.Equal(t, Blocks{}, res.Blocks)
+
+	// files are compared by CID
+	res, err = Diff(ctx, ds, change.Cid(), changed.Cid())
+	require.NoError(t, err)
+	require.Equal(t, []Change{{Type: Modified, Before: change.Cid(), After: changed.Cid()}}, res.Changes)
+	require.Equal(t, Blocks{1, uint64(len("changed")), 1, uint64(len("change"))}, res.Blocks)
+}
@@ -0,0 +1,148 @@
+// Package dagproof produces and verifies the inclusion proofs of paths in
+// DAGs. A proof is the minimal set of blocks resolving a path from a root, so
+// that a client holding only the proof can check that the path belongs to
+// the root, and which CID it resolves to, without a node.
+package dagproof
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-libipfs/blocks"
+	ipfspath "github.com/ipfs/go-path"
+	"github.com/ipfs/go-path/resolver"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+
+	"github.com/ipfs/kubo/core/node"
+	"github.com/ipfs/kubo/thirdparty/verifbs"
+)
+
+// Proof is the inclusion proof of a path in the DAG of its root.
+type Proof struct {
+	Path ipfspath.Path
+	// Cid is the CID the path resolves to, and Rest the remainder of the
+	// path within its block
+	Cid  cid.Cid
+	Rest []string
+	// Blocks are the blocks read to resolve the path, the root first
+	Blocks []blocks.Block
+}
+
+// Path returns the path rel, relative to root, e.g. "docs/report.pdf".
+func Path(root cid.Cid, rel string) (ipfspath.Path, error) {
+	segments := []string{root.String()}
+	if rel = strings.Trim(rel, "/"); rel != "" {
+		segments = append(segments, strings.Split(rel, "/")...)
+	}
+	return ipfspath.FromSegments("/ipfs/", segments...)
+}
+
+// Prove returns the proof of the path p, resolved with the blocks of bs
+// without fetching any. The blocks of the path have to be in bs, e.g. after
+// a resolution of the path with the CoreAPI.
+func Prove(ctx context.Context, bs blockstore.Blockstore, p ipfspath.Path) (*Proof, error) {
+	root, _, err := ipfspath.SplitAbsPath(p)
+	if err != nil {
+		return nil, err
+	}
+	rec := &recordingBlockstore{Blockstore: bs, seen: make(map[cid.Cid]struct{})}
+	// the root is in the proof even if the path has no segment to resolve
+	if _, err := rec.Get(ctx, root); err != nil {
+		return nil, fmt.Errorf("root %s: %w", root, err)
+	}
+	c, rest, err := resolve(ctx, rec, p)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{Path: p, Cid: c, Rest: rest, Blocks: rec.blocks}, nil
+}
+
+// Verify checks that the blocks match their CIDs and resolve the path p from
+// its root, and returns the CID the path resolves to and the remainder of the
+// path within its block.
+func Verify(ctx context.Context, blks []blocks.Block, p ipfspath.Path) (cid.Cid, []string, error) {
+	root, _, err := ipfspath.SplitAbsPath(p)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	bs := blockstore.NewBlockstore(syncds.MutexWrap(datastore.NewMapDatastore()))
+	for _, b := range blks {
+		if err := verifbs.VerifyBlock(b); err != nil {
+			return cid.Undef, nil, fmt.Errorf("block %s: %w", b.Cid(), err)
+		}
+	}
+	if err := bs.PutMany(ctx, blks); err != nil {
+		return cid.Undef, nil, err
+	}
+	if has, err := bs.Has(ctx, root); err != nil {
+		return cid.Undef, nil, err
+	} else if !has {
+		return cid.Undef, nil, fmt.Errorf("the proof does not contain the root %s", root)
+	}
+	c, rest, err := resolve(ctx, bs, p)
+	if err != nil {
+		return cid.Undef, nil, fmt.Errorf("the proof does not resolve %s: %w", p, err)
+	}
+	return c, rest, nil
+}
+
+// resolve resolves p with the blocks of bs only.
+func resolve(ctx context.Context, bs blockstore.Blockstore, p ipfspath.Path) (cid.Cid, []string, error) {
+	fetchers := node.FetcherConfig(blockservice.New(bs, offline.Exchange(bs)))
+	f := fetchers.UnixfsFetcher
+	if p.Segments()[0] == "ipld" {
+		f = fetchers.IPLDFetcher
+	}
+	return resolver.NewBasicResolver(f).ResolveToLastNode(ctx, p)
+}
+
+// recordingBlockstore records the blocks read from a blockstore, in order.
+type recordingBlockstore struct {
+	blockstore.Blockstore
+
+	mu     sync.Mutex
+	seen   map[cid.Cid]struct{}
+	blocks []blocks.Block
+}
+
+func (bs *recordingBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, err := bs.Blockstore.Get(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if _, ok := bs.seen[c]; !ok {
+		bs.seen[c] = struct{}{}
+		bs.blocks = append(bs.blocks, b)
+	}
+	return b, nil
+}
+
+// WriteCar writes the blocks of the proof as a CARv1 file whose root is the
+// root of the path.
+func (p *Proof) WriteCar(w io.Writer) error {
+	root, _, err := ipfspath.SplitAbsPath(p.Path)
+	if err != nil {
+		return err
+	}
+	if err := gocar.WriteHeader(&gocar.CarHeader{Roots: []cid.Cid{root}, Version: 1}, w); err != nil {
+		return err
+	}
+	for _, b := range p.Blocks {
+		if err := carutil.LdWrite(w, b.Cid().Bytes(), b.RawData()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package dagproof
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-datastore"
+	syncds "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-libipfs/blocks"
+	dag "github.com/ipfs/go-merkledag"
+	ipfspath "github.com/ipfs/go-path"
+	gocar "github.com/ipld/go-car"
+)
+
+// unixfsDir returns a UnixFS directory of the entries.
+func unixfsDir(t *testing.T, entries map[string]ipld.Node) *dag.ProtoNode {
+	// the protobuf of the UnixFS Data{Type: Directory}
+	nd := dag.NodeWithData([]byte{0x08, 0x01})
+	for name, e := range entries {
+		if err := nd.AddNodeLink(name, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return nd
+}
+
+func TestProof(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewBlockstore(syncds.MutexWrap(datastore.NewMapDatastore()))
+	dserv := dag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+
+	file := dag.NewRawNode([]byte("hello"))
+	other := dag.NewRawNode([]byte("unrelated"))
+	sub := unixfsDir(t, map[string]ipld.Node{"file.txt": file})
+	root := unixfsDir(t, map[string]ipld.Node{"sub": sub, "other": other})
+	if err := dserv.AddMany(ctx, []ipld.Node{file, other, sub, root}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ipfspath.FromSegments("/ipfs/", root.Cid().String(), "sub", "file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := Prove(ctx, bs, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proof.Cid != file.Cid() {
+		t.Errorf("expected the path to resolve to %s, got %s", file.Cid(), proof.Cid)
+	}
+	// the directories of the path, but not the file nor the other entries
+	if len(proof.Blocks) != 2 || proof.Blocks[0].Cid() != root.Cid() || proof.Blocks[1].Cid() != sub.Cid() {
+		t.Fatalf("unexpected blocks in the proof: %v", proof.Blocks)
+	}
+
+	c, _, err := Verify(ctx, proof.Blocks, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != file.Cid() {
+		t.Errorf("expected the proof to resolve to %s, got %s", file.Cid(), c)
+	}
+
+	if _, _, err := Verify(ctx, proof.Blocks[:1], p); err == nil {
+		t.Error("expected an error for a proof missing a block")
+	}
+	if _, _, err := Verify(ctx, proof.Blocks[1:], p); err == nil {
+		t.Error("expected an error for a proof missing the root")
+	}
+	forged, err := blocks.NewBlockWithCid([]byte("forged"), sub.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Verify(ctx, []blocks.Block{proof.Blocks[0], forged}, p); err == nil {
+		t.Error("expected an error for a block not matching its CID")
+	}
+
+	var buf bytes.Buffer
+	if err := proof.WriteCar(&buf); err != nil {
+		t.Fatal(err)
+	}
+	cr, err := gocar.NewCarReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cr.Header.Roots) != 1 || cr.Header.Roots[0] != root.Cid() {
+		t.Errorf("unexpected roots: %v", cr.Header.Roots)
+	}
+}
@@ -0,0 +1,159 @@
+// Package dialstats counts the outcomes and the latencies of the dials of
+// the swarm, by transport and address family, so that operators can tell
+// which transports are reachable from their network, e.g. that QUIC is
+// blocked while TCP works.
+package dialstats
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Buckets are the upper bounds, in seconds, of the latency histograms.
+var Buckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	dialsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipfs_swarm_dials_total",
+			Help: "dials of the swarm, by transport, address family and outcome",
+		},
+		[]string{"transport", "family", "outcome"},
+	)
+	dialDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ipfs_swarm_dial_duration_seconds",
+			Help:    "duration of the dials of the swarm, by transport, address family and outcome",
+			Buckets: Buckets,
+		},
+		[]string{"transport", "family", "outcome"},
+	)
+)
+
+// The outcomes of the dials. The dials canceled once another address of the
+// peer connected are neither successes nor failures.
+const (
+	Success  = "success"
+	Failure  = "failure"
+	Canceled = "canceled"
+)
+
+// TransportStats are the statistics of the dials of a transport over an
+// address family.
+type TransportStats struct {
+	Transport string
+	Family    string
+	Successes uint64
+	Failures  uint64
+	Canceled  uint64
+	// SuccessRate is the ratio of successes to the dials that succeeded or
+	// failed.
+	SuccessRate float64
+	// Latency counts the successful dials by latency, the dials of
+	// Latency[i] taking at most Buckets[i] seconds, and those of the last
+	// element more than the last bucket.
+	Latency []uint64
+}
+
+type key struct {
+	transport, family string
+}
+
+// Stats counts the dials recorded with Observe.
+type Stats struct {
+	mu    sync.Mutex
+	stats map[key]*TransportStats
+}
+
+// New returns empty Stats, and registers the Prometheus metrics of the dials.
+func New() (*Stats, error) {
+	for _, c := range []prometheus.Collector{dialsTotal, dialDuration} {
+		if err := prometheus.Register(c); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			return nil, err
+		}
+	}
+	return &Stats{stats: make(map[key]*TransportStats)}, nil
+}
+
+// Observe records a dial of addr which took d, and ended with err, or
+// ctx canceled.
+func (s *Stats) Observe(ctx context.Context, addr ma.Multiaddr, d time.Duration, err error) {
+	tpt, family := Classify(addr)
+	outcome := Success
+	switch {
+	case err != nil && ctx.Err() != nil:
+		outcome = Canceled
+	case err != nil:
+		outcome = Failure
+	}
+	dialsTotal.WithLabelValues(tpt, family, outcome).Inc()
+	dialDuration.WithLabelValues(tpt, family, outcome).Observe(d.Seconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := key{tpt, family}
+	ts, ok := s.stats[k]
+	if !ok {
+		ts = &TransportStats{Transport: tpt, Family: family, Latency: make([]uint64, len(Buckets)+1)}
+		s.stats[k] = ts
+	}
+	switch outcome {
+	case Success:
+		ts.Successes++
+		ts.Latency[sort.SearchFloat64s(Buckets, d.Seconds())]++
+	case Failure:
+		ts.Failures++
+	case Canceled:
+		ts.Canceled++
+	}
+}
+
+// Snapshot returns the statistics of the transports, sorted by transport and
+// address family.
+func (s *Stats) Snapshot() []TransportStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]TransportStats, 0, len(s.stats))
+	for _, ts := range s.stats {
+		c := *ts
+		c.Latency = append([]uint64(nil), ts.Latency...)
+		if n := c.Successes + c.Failures; n > 0 {
+			c.SuccessRate = float64(c.Successes) / float64(n)
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Transport != out[j].Transport {
+			return out[i].Transport < out[j].Transport
+		}
+		return out[i].Family < out[j].Family
+	})
+	return out
+}
+
+// Classify returns the transport and the address family of addr, e.g.
+// "quic-v1" and "ip6".
+func Classify(addr ma.Multiaddr) (tpt string, family string) {
+	tpt, family = "other", "other"
+	for i, p := range addr.Protocols() {
+		switch p.Code {
+		case ma.P_IP4, ma.P_IP6, ma.P_DNS4, ma.P_DNS6, ma.P_DNS, ma.P_DNSADDR:
+			if i == 0 {
+				family = p.Name
+			}
+		case ma.P_CIRCUIT:
+			// the relayed dials are dials of the circuit, whatever the
+			// transport to the relay
+			return "p2p-circuit", family
+		case ma.P_TCP, ma.P_QUIC, ma.P_QUIC_V1, ma.P_WS, ma.P_WSS, ma.P_WEBTRANSPORT:
+			tpt = p.Name
+		}
+	}
+	return tpt, family
+}
@@ -0,0 +1,67 @@
+package dialstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestClassify(t *testing.T) {
+	for addr, want := range map[string][2]string{
+		"/ip4/1.2.3.4/tcp/4001":                      {"tcp", "ip4"},
+		"/ip6/::1/udp/4001/quic-v1":                  {"quic-v1", "ip6"},
+		"/ip4/1.2.3.4/udp/4001/quic":                 {"quic", "ip4"},
+		"/dns4/example.com/tcp/443/wss":              {"wss", "dns4"},
+		"/ip4/1.2.3.4/udp/4001/quic-v1/webtransport": {"webtransport", "ip4"},
+		"/ip4/1.2.3.4/tcp/4001/p2p/QmcgpsyWgH8Y8ajJz1Cu72KnS5uo2Aa2LpzU7kinSupNKC/p2p-circuit": {"p2p-circuit", "ip4"},
+	} {
+		tpt, family := Classify(ma.StringCast(addr))
+		if tpt != want[0] || family != want[1] {
+			t.Errorf("%s: expected %s over %s, got %s over %s", addr, want[0], want[1], tpt, family)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	quic := ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+	ctx := context.Background()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	s.Observe(ctx, tcp, 20*time.Millisecond, nil)
+	s.Observe(ctx, tcp, 3*time.Second, nil)
+	s.Observe(ctx, tcp, time.Minute, nil)
+	s.Observe(ctx, tcp, time.Second, errors.New("refused"))
+	s.Observe(ctx, quic, 5*time.Second, errors.New("timeout"))
+	s.Observe(canceled, quic, time.Second, context.Canceled)
+
+	stats := s.Snapshot()
+	if len(stats) != 2 || stats[0].Transport != "quic-v1" || stats[1].Transport != "tcp" {
+		t.Fatalf("unexpected transports: %+v", stats)
+	}
+	if q := stats[0]; q.Failures != 1 || q.Canceled != 1 || q.SuccessRate != 0 {
+		t.Errorf("unexpected quic stats: %+v", q)
+	}
+	tc := stats[1]
+	if tc.Successes != 3 || tc.Failures != 1 || tc.SuccessRate != 0.75 {
+		t.Errorf("unexpected tcp stats: %+v", tc)
+	}
+	// 20ms, 3s and 1m fall in the buckets of 25ms, 5s and over 10s
+	for i, n := range tc.Latency {
+		want := uint64(0)
+		if i == 1 || i == 8 || i == len(Buckets) {
+			want = 1
+		}
+		if n != want {
+			t.Errorf("expected %d dials in bucket %d, got %d", want, i, n)
+		}
+	}
+}
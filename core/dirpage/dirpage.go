@@ -0,0 +1,139 @@
+// Package dirpage lists pages of UnixFS directories. The shards of a
+// HAMT-sharded directory are fetched one at a time, in the order of the
+// entries, and only until the page is full: the first page of a directory
+// with millions of entries only needs a few blocks.
+//
+// The entries of a sharded directory are listed in the order of the HAMT,
+// which is stable for a given directory CID, so offsets can be used to page
+// through it.
+package dirpage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+)
+
+// Page selects the entries of a directory.
+type Page struct {
+	// Offset is the number of entries to skip.
+	Offset int
+	// Limit is the maximum number of entries, 0 for no limit.
+	Limit int
+}
+
+// IsSharded returns whether nd is the root of a HAMT-sharded directory.
+func IsSharded(nd ipld.Node) bool {
+	pn, ok := nd.(*dag.ProtoNode)
+	if !ok {
+		return false
+	}
+	fsn, err := ft.FSNodeFromBytes(pn.Data())
+	return err == nil && fsn.Type() == ft.THAMTShard
+}
+
+// Links sends the entries of page of the directory nd to the returned
+// channel, and closes it. The links of the entries are named after the
+// entries. Errors are sent as the last LinkResult.
+func Links(ctx context.Context, ng ipld.NodeGetter, nd ipld.Node, page Page) <-chan ft.LinkResult {
+	out := make(chan ft.LinkResult)
+	l := &lister{ctx: ctx, ng: ng, out: out, skip: page.Offset, left: page.Limit}
+	if page.Limit <= 0 {
+		l.left = -1
+	}
+	go func() {
+		defer close(out)
+		var err error
+		if IsSharded(nd) {
+			err = l.shard(nd.(*dag.ProtoNode))
+		} else {
+			for _, lnk := range nd.Links() {
+				if !l.emit(lnk) {
+					break
+				}
+			}
+		}
+		if err != nil && err != errDone {
+			select {
+			case out <- ft.LinkResult{Err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return out
+}
+
+var errDone = errors.New("page done")
+
+type lister struct {
+	ctx  context.Context
+	ng   ipld.NodeGetter
+	out  chan<- ft.LinkResult
+	skip int
+	// left is the number of entries left in the page, -1 for no limit
+	left int
+}
+
+// emit sends lnk unless it is skipped, and returns false once the page is
+// full or the context is done.
+func (l *lister) emit(lnk *ipld.Link) bool {
+	if l.left == 0 {
+		return false
+	}
+	if l.skip > 0 {
+		l.skip--
+		return true
+	}
+	select {
+	case l.out <- ft.LinkResult{Link: lnk}:
+	case <-l.ctx.Done():
+		return false
+	}
+	if l.left > 0 {
+		l.left--
+	}
+	return l.left != 0
+}
+
+// shard lists the entries of the HAMT shard nd, depth first.
+func (l *lister) shard(nd *dag.ProtoNode) error {
+	fsn, err := ft.FSNodeFromBytes(nd.Data())
+	if err != nil {
+		return err
+	}
+	if fsn.Type() != ft.THAMTShard {
+		return fmt.Errorf("%s: not a HAMT shard", nd.Cid())
+	}
+	// the links to the child shards are named after their index in the
+	// shard, the links to the entries are prefixed with it
+	padLen := len(fmt.Sprintf("%X", fsn.Fanout()-1))
+
+	for _, lnk := range nd.Links() {
+		if len(lnk.Name) < padLen {
+			return fmt.Errorf("%s: invalid HAMT link name %q", nd.Cid(), lnk.Name)
+		}
+		if len(lnk.Name) > padLen {
+			if !l.emit(&ipld.Link{Name: lnk.Name[padLen:], Size: lnk.Size, Cid: lnk.Cid}) {
+				return errDone
+			}
+			continue
+		}
+
+		child, err := lnk.GetNode(l.ctx, l.ng)
+		if err != nil {
+			return err
+		}
+		pn, ok := child.(*dag.ProtoNode)
+		if !ok {
+			return fmt.Errorf("%s: HAMT shard isn't dag-pb", lnk.Cid)
+		}
+		if err := l.shard(pn); err != nil {
+			return err
+		}
+	}
+	return l.ctx.Err()
+}
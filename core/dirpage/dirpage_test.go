@@ -0,0 +1,89 @@
+package dirpage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/stretchr/testify/require"
+)
+
+type countingGetter struct {
+	ipld.NodeGetter
+	gets int
+}
+
+func (g *countingGetter) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	g.gets++
+	return g.NodeGetter.Get(ctx, c)
+}
+
+func list(t *testing.T, ng ipld.NodeGetter, nd ipld.Node, page Page) []string {
+	var names []string
+	for res := range Links(context.Background(), ng, nd, page) {
+		require.NoError(t, res.Err)
+		names = append(names, res.Link.Name)
+	}
+	return names
+}
+
+func TestShardedPages(t *testing.T) {
+	defer func(size int) { uio.HAMTShardingSize = size }(uio.HAMTShardingSize)
+	uio.HAMTShardingSize = 1
+
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	dir := uio.NewDirectory(ds)
+	var want []string
+	for i := 0; i < 2000; i++ {
+		name := fmt.Sprintf("entry-%d", i)
+		nd := dag.NewRawNode([]byte(name))
+		require.NoError(t, ds.Add(ctx, nd))
+		require.NoError(t, dir.AddChild(ctx, name, nd))
+		want = append(want, name)
+	}
+	root, err := dir.GetNode()
+	require.NoError(t, err)
+	require.NoError(t, ds.Add(ctx, root))
+	require.True(t, IsSharded(root))
+
+	all := list(t, ds, root, Page{})
+	got := append([]string(nil), all...)
+	sort.Strings(got)
+	sort.Strings(want)
+	require.Equal(t, want, got)
+
+	var paged []string
+	for offset := 0; offset < len(all); offset += 300 {
+		paged = append(paged, list(t, ds, root, Page{Offset: offset, Limit: 300})...)
+	}
+	require.Equal(t, all, paged)
+
+	// the first page only needs the first shards
+	g := &countingGetter{NodeGetter: ds}
+	require.Len(t, list(t, g, root, Page{Limit: 5}), 5)
+	require.Less(t, g.gets, 5)
+}
+
+func TestBasicPages(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	dir := uio.NewDirectory(ds)
+	for _, name := range []string{"a", "b", "c"} {
+		nd := dag.NewRawNode([]byte(name))
+		require.NoError(t, dir.AddChild(ctx, name, nd))
+	}
+	root, err := dir.GetNode()
+	require.NoError(t, err)
+	require.False(t, IsSharded(root))
+
+	require.Equal(t, []string{"a", "b", "c"}, list(t, ds, root, Page{}))
+	require.Equal(t, []string{"b"}, list(t, ds, root, Page{Offset: 1, Limit: 1}))
+	require.Empty(t, list(t, ds, root, Page{Offset: 3}))
+}
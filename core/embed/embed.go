@@ -0,0 +1,220 @@
+// Package embed provides a supported way to run an IPFS node inside a Go
+// application.
+//
+// A node is created with New and a set of functional options, and must be
+// closed with Close:
+//
+//	node, err := embed.New(ctx, embed.RepoPath("/path/to/repo"), embed.Online(true))
+//	if err != nil {
+//		return err
+//	}
+//	defer node.Close()
+//
+//	cid, err := node.API.Unixfs().Add(ctx, files.NewBytesFile(data))
+package embed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	multierror "github.com/hashicorp/go-multierror"
+	ds "github.com/ipfs/go-datastore"
+	dsync "github.com/ipfs/go-datastore/sync"
+	keystore "github.com/ipfs/go-ipfs-keystore"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	config "github.com/ipfs/kubo/config"
+	core "github.com/ipfs/kubo/core"
+	coreapi "github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/plugin/loader"
+	"github.com/ipfs/kubo/repo"
+	"github.com/ipfs/kubo/repo/fsrepo"
+)
+
+// keySize is the size of the RSA key generated for new repositories.
+const keySize = 2048
+
+var (
+	pluginsOnce sync.Once
+	pluginsErr  error
+)
+
+// Node is an IPFS node running in-process.
+type Node struct {
+	*core.IpfsNode
+
+	// API is the CoreAPI of the node.
+	API iface.CoreAPI
+
+	onStop    []func(*Node) error
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New constructs and starts a node.
+func New(ctx context.Context, opts ...Option) (*Node, error) {
+	s := defaultSettings()
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, err
+		}
+	}
+
+	if !s.noPlugins {
+		if err := setupPlugins(s.repoPath, s.pluginsDir); err != nil {
+			return nil, err
+		}
+	}
+
+	r, err := openRepo(s)
+	if err != nil {
+		return nil, err
+	}
+
+	routing := s.routing
+	if routing == nil {
+		routing = libp2p.DHTOption
+	}
+
+	n, err := core.NewNode(ctx, &core.BuildCfg{
+		Online:    s.online,
+		Permanent: s.repoPath != "",
+		Routing:   routing,
+		ExtraOpts: s.extraOpts,
+		Repo:      r,
+	})
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+
+	api, err := coreapi.NewCoreAPI(n)
+	if err != nil {
+		n.Close()
+		return nil, fmt.Errorf("embed: %w", err)
+	}
+
+	node := &Node{
+		IpfsNode: n,
+		API:      api,
+		onStop:   s.onStop,
+	}
+
+	for _, hook := range s.onStart {
+		if err := hook(ctx, node); err != nil {
+			return nil, multierror.Append(err, node.Close()).ErrorOrNil()
+		}
+	}
+
+	return node, nil
+}
+
+// Close runs the OnStop hooks and shuts the node down. It is safe to call
+// Close more than once.
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() {
+		var errs *multierror.Error
+		for i := len(n.onStop) - 1; i >= 0; i-- {
+			errs = multierror.Append(errs, n.onStop[i](n))
+		}
+		errs = multierror.Append(errs, n.IpfsNode.Close())
+		n.closeErr = errs.ErrorOrNil()
+	})
+	return n.closeErr
+}
+
+func setupPlugins(repoPath, dir string) error {
+	pluginsOnce.Do(func() {
+		plugins, err := loader.NewPluginLoader(repoPath)
+		if err != nil {
+			pluginsErr = fmt.Errorf("embed: loading plugins: %w", err)
+			return
+		}
+		if dir != "" {
+			if err := plugins.LoadDirectory(dir); err != nil {
+				pluginsErr = fmt.Errorf("embed: loading plugins: %w", err)
+				return
+			}
+		}
+		if err := plugins.Initialize(); err != nil {
+			pluginsErr = fmt.Errorf("embed: initializing plugins: %w", err)
+			return
+		}
+		if err := plugins.Inject(); err != nil {
+			pluginsErr = fmt.Errorf("embed: injecting plugins: %w", err)
+		}
+	})
+	return pluginsErr
+}
+
+func openRepo(s *settings) (repo.Repo, error) {
+	if s.repoPath == "" {
+		cfg, err := newConfig(s)
+		if err != nil {
+			return nil, err
+		}
+		d := s.datastore
+		if d == nil {
+			d = dsync.MutexWrap(ds.NewMapDatastore())
+		}
+		return &repo.Mock{
+			C: *cfg,
+			D: d,
+			K: keystore.NewMemKeystore(),
+		}, nil
+	}
+
+	if !fsrepo.IsInitialized(s.repoPath) {
+		cfg, err := newConfig(s)
+		if err != nil {
+			return nil, err
+		}
+		if err := fsrepo.Init(s.repoPath, cfg); err != nil {
+			return nil, fmt.Errorf("embed: initializing repo: %w", err)
+		}
+	}
+
+	r, err := fsrepo.Open(s.repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("embed: opening repo: %w", err)
+	}
+
+	// Apply options to existing repositories too, without persisting them.
+	if len(s.swarmAddrs) > 0 || len(s.configFuncs) > 0 {
+		cfg, err := r.Config()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		if err := applyConfig(s, cfg); err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func newConfig(s *settings) (*config.Config, error) {
+	cfg, err := config.Init(io.Discard, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("embed: creating config: %w", err)
+	}
+	if err := applyConfig(s, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func applyConfig(s *settings, cfg *config.Config) error {
+	if len(s.swarmAddrs) > 0 {
+		cfg.Addresses.Swarm = s.swarmAddrs
+	}
+	for _, fn := range s.configFuncs {
+		if err := fn(cfg); err != nil {
+			return fmt.Errorf("embed: applying config: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package embed
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/ipfs/go-libipfs/files"
+	config "github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEphemeralNode(t *testing.T) {
+	ctx := context.Background()
+
+	var started, stopped bool
+	node, err := New(ctx,
+		Online(false),
+		NoPlugins(),
+		Config(func(cfg *config.Config) error {
+			cfg.Experimental.FilestoreEnabled = true
+			return nil
+		}),
+		OnStart(func(_ context.Context, n *Node) error {
+			started = true
+			return nil
+		}),
+		OnStop(func(n *Node) error {
+			stopped = true
+			return nil
+		}),
+	)
+	require.NoError(t, err)
+	require.True(t, started)
+
+	cfg, err := node.Repo.Config()
+	require.NoError(t, err)
+	require.True(t, cfg.Experimental.FilestoreEnabled)
+
+	p, err := node.API.Unixfs().Add(ctx, files.NewBytesFile([]byte("hello embed")))
+	require.NoError(t, err)
+
+	nd, err := node.API.Unixfs().Get(ctx, p)
+	require.NoError(t, err)
+	data, err := io.ReadAll(nd.(files.File))
+	require.NoError(t, err)
+	require.Equal(t, "hello embed", string(data))
+
+	require.NoError(t, node.Close())
+	require.True(t, stopped)
+	require.NoError(t, node.Close())
+}
+
+func TestRepoPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	node, err := New(ctx, RepoPath(dir), Online(false))
+	require.NoError(t, err)
+	id := node.Identity
+	require.NoError(t, node.Close())
+
+	// reopening uses the existing repository
+	node, err = New(ctx, RepoPath(dir), Online(false))
+	require.NoError(t, err)
+	require.Equal(t, id, node.Identity)
+	require.NoError(t, node.Close())
+}
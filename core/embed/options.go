@@ -0,0 +1,139 @@
+package embed
+
+import (
+	"context"
+
+	ds "github.com/ipfs/go-datastore"
+	config "github.com/ipfs/kubo/config"
+	libp2p "github.com/ipfs/kubo/core/node/libp2p"
+)
+
+// Option configures a Node created with New.
+type Option func(*settings) error
+
+type settings struct {
+	repoPath    string
+	datastore   ds.Batching
+	online      bool
+	routing     libp2p.RoutingOption
+	swarmAddrs  []string
+	extraOpts   map[string]bool
+	configFuncs []func(*config.Config) error
+	onStart     []func(context.Context, *Node) error
+	onStop      []func(*Node) error
+	pluginsDir  string
+	noPlugins   bool
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		online:    true,
+		extraOpts: make(map[string]bool),
+	}
+}
+
+// RepoPath makes the node use the on-disk repository at path. The repository
+// is initialized with a default config if it does not exist yet.
+func RepoPath(path string) Option {
+	return func(s *settings) error {
+		s.repoPath = path
+		return nil
+	}
+}
+
+// Datastore makes the node use an ephemeral repository backed by d. It is
+// ignored when RepoPath is set. When neither is set, an in-memory datastore
+// is used.
+func Datastore(d ds.Batching) Option {
+	return func(s *settings) error {
+		s.datastore = d
+		return nil
+	}
+}
+
+// Online configures whether the node connects to the network. Defaults to
+// true.
+func Online(online bool) Option {
+	return func(s *settings) error {
+		s.online = online
+		return nil
+	}
+}
+
+// Routing sets the content and peer routing used by the node. Defaults to
+// libp2p.DHTOption.
+func Routing(r libp2p.RoutingOption) Option {
+	return func(s *settings) error {
+		s.routing = r
+		return nil
+	}
+}
+
+// ListenAddrs sets the multiaddrs the node listens on (Addresses.Swarm).
+func ListenAddrs(addrs ...string) Option {
+	return func(s *settings) error {
+		s.swarmAddrs = addrs
+		return nil
+	}
+}
+
+// Pubsub enables the pubsub subsystem.
+func Pubsub(enabled bool) Option {
+	return func(s *settings) error {
+		s.extraOpts["pubsub"] = enabled
+		return nil
+	}
+}
+
+// IPNSPubsub enables IPNS over pubsub.
+func IPNSPubsub(enabled bool) Option {
+	return func(s *settings) error {
+		s.extraOpts["ipnsps"] = enabled
+		return nil
+	}
+}
+
+// Config registers a function that can modify the node configuration before
+// the node is constructed. Functions run in the order they were passed.
+func Config(fn func(*config.Config) error) Option {
+	return func(s *settings) error {
+		s.configFuncs = append(s.configFuncs, fn)
+		return nil
+	}
+}
+
+// OnStart registers a hook that runs after the node has started. If a hook
+// returns an error, the node is closed and New returns that error.
+func OnStart(fn func(context.Context, *Node) error) Option {
+	return func(s *settings) error {
+		s.onStart = append(s.onStart, fn)
+		return nil
+	}
+}
+
+// OnStop registers a hook that runs when the node is closed, before the node
+// shuts down. Hooks run in reverse registration order.
+func OnStop(fn func(*Node) error) Option {
+	return func(s *settings) error {
+		s.onStop = append(s.onStop, fn)
+		return nil
+	}
+}
+
+// PluginsDir loads external plugins from dir in addition to the preloaded
+// ones. Plugins are loaded once per process.
+func PluginsDir(dir string) Option {
+	return func(s *settings) error {
+		s.pluginsDir = dir
+		return nil
+	}
+}
+
+// NoPlugins skips loading plugins. Use this when the application already
+// initialized and injected plugins itself.
+func NoPlugins() Option {
+	return func(s *settings) error {
+		s.noPlugins = true
+		return nil
+	}
+}
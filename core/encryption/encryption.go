@@ -0,0 +1,226 @@
+// Package encryption encrypts files with a key of the keystore before they
+// are added, so that private data can be stored on public nodes as regular
+// UnixFS DAGs of ciphertext.
+//
+// The files are encrypted in the age format (https://age-encryption.org/v1)
+// to an scrypt recipient, whose passphrase is derived from the private key
+// with HKDF-SHA256. They can be decrypted by any age implementation given the
+// passphrase: the payload is sealed with ChaCha20-Poly1305 in chunks of
+// 64KiB, so that truncated or reordered files fail to decrypt. Other data,
+// such as the backups of the repo, is encrypted with a passphrase in the same
+// format, see EncryptPassphrase.
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"filippo.io/age"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// ageIntro is the first line of the age files.
+	ageIntro = "age-encryption.org/v1\n"
+	hkdfInfo = "ipfs-enc/age passphrase"
+
+	// keyWorkFactor is the scrypt work factor of the files encrypted with a
+	// key, and the highest one accepted when decrypting. The passphrase
+	// derived from the key can't be guessed, so scrypt only has to be cheap,
+	// and the cost of decrypting crafted files is bounded.
+	keyWorkFactor = 10
+
+	// HeaderSize is the size of the header of an encrypted file: the age
+	// header with its scrypt stanza, and the nonce of the payload.
+	HeaderSize = 166
+
+	// ChunkSize is the size of the plaintext of a chunk, but the last one.
+	ChunkSize = 64 << 10
+
+	tagSize = 16
+)
+
+var (
+	// ErrNotEncrypted is returned when decrypting data without the header
+	// of an encrypted file.
+	ErrNotEncrypted = errors.New("not an encrypted file")
+
+	// ErrDecrypt is returned when decrypting data with the wrong key, or
+	// which was modified or truncated.
+	ErrDecrypt = errors.New("decryption failed: wrong key, or corrupted or truncated data")
+)
+
+// keyPassphrase returns the passphrase of the scrypt recipient of the files
+// encrypted with sk.
+func keyPassphrase(sk crypto.PrivKey) (string, error) {
+	ikm, err := sk.Raw()
+	if err != nil {
+		return "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, ikm, nil, []byte(hkdfInfo)), secret); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(secret), nil
+}
+
+// EncryptedSize returns the size of a file of size bytes once encrypted.
+func EncryptedSize(size int64) int64 {
+	chunks := (size + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+	return int64(HeaderSize) + size + chunks*tagSize
+}
+
+// PlaintextSize returns the size of the plaintext of an encrypted file of size
+// bytes.
+func PlaintextSize(size int64) (int64, error) {
+	size -= int64(HeaderSize)
+	chunks := (size + ChunkSize + tagSize - 1) / (ChunkSize + tagSize)
+	if size < tagSize || size-chunks*tagSize < 0 {
+		return 0, ErrDecrypt
+	}
+	return size - chunks*tagSize, nil
+}
+
+type encryptReader struct {
+	r     io.Reader
+	w     io.WriteCloser
+	plain []byte
+	out   bytes.Buffer
+	done  bool
+}
+
+// NewEncryptReader returns a reader of the encryption of r with sk.
+func NewEncryptReader(r io.Reader, sk crypto.PrivKey) (io.Reader, error) {
+	passphrase, err := keyPassphrase(sk)
+	if err != nil {
+		return nil, err
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	recipient.SetWorkFactor(keyWorkFactor)
+
+	e := &encryptReader{r: r, plain: make([]byte, ChunkSize)}
+	if e.w, err = age.Encrypt(&e.out, recipient); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	// the age writer writes the chunks to out as they are filled
+	for e.out.Len() == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := e.r.Read(e.plain)
+		if n > 0 {
+			if _, err := e.w.Write(e.plain[:n]); err != nil {
+				return 0, err
+			}
+		}
+		switch err {
+		case nil:
+		case io.EOF:
+			if err := e.w.Close(); err != nil {
+				return 0, err
+			}
+			e.done = true
+		default:
+			return 0, err
+		}
+	}
+	return e.out.Read(p)
+}
+
+// sourceReader records the errors of the reader of the ciphertext, to tell
+// them from the decryption errors.
+type sourceReader struct {
+	r   io.Reader
+	err error
+}
+
+func (s *sourceReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if err != nil && err != io.EOF {
+		s.err = err
+	}
+	return n, err
+}
+
+// sourceErr returns the error of the source if any, err otherwise.
+func (s *sourceReader) sourceErr(err error) error {
+	if s.err != nil {
+		return s.err
+	}
+	return err
+}
+
+// ageReader reads the plaintext of an age file, failing with ErrDecrypt when
+// a chunk doesn't authenticate.
+type ageReader struct {
+	src   *sourceReader
+	plain io.Reader
+}
+
+// openAge returns a reader of the decryption of the age file read by r with
+// identity.
+func openAge(r io.Reader, identity age.Identity) (io.Reader, error) {
+	src := &sourceReader{r: r}
+	br := bufio.NewReader(src)
+	if intro, _ := br.Peek(len(ageIntro)); string(intro) != ageIntro {
+		return nil, src.sourceErr(ErrNotEncrypted)
+	}
+	plain, err := age.Decrypt(br, identity)
+	if err != nil {
+		return nil, src.sourceErr(ErrDecrypt)
+	}
+	return &ageReader{src: src, plain: plain}, nil
+}
+
+func (a *ageReader) Read(p []byte) (int, error) {
+	n, err := a.plain.Read(p)
+	if err != nil && err != io.EOF {
+		return n, a.src.sourceErr(ErrDecrypt)
+	}
+	return n, err
+}
+
+type decryptReader struct {
+	r     io.Reader
+	sk    crypto.PrivKey
+	plain io.Reader
+}
+
+// NewDecryptReader returns a reader of the decryption of r, encrypted with sk.
+// It fails with ErrDecrypt as soon as a chunk doesn't authenticate.
+func NewDecryptReader(r io.Reader, sk crypto.PrivKey) io.Reader {
+	return &decryptReader{r: r, sk: sk}
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	if d.plain == nil {
+		passphrase, err := keyPassphrase(d.sk)
+		if err != nil {
+			return 0, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return 0, err
+		}
+		identity.SetMaxWorkFactor(keyWorkFactor)
+		if d.plain, err = openAge(d.r, identity); err != nil {
+			return 0, err
+		}
+	}
+	return d.plain.Read(p)
+}
@@ -0,0 +1,228 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"filippo.io/age"
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func genKey(t *testing.T) crypto.PrivKey {
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk
+}
+
+func encrypt(t *testing.T, data []byte, sk crypto.PrivKey) []byte {
+	r, err := NewEncryptReader(bytes.NewReader(data), sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ciphertext
+}
+
+func TestRoundTrip(t *testing.T) {
+	sk := genKey(t)
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 7} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		ciphertext := encrypt(t, data, sk)
+		if int64(len(ciphertext)) != EncryptedSize(int64(size)) {
+			t.Errorf("size %d: expected %d bytes of ciphertext, got %d", size, EncryptedSize(int64(size)), len(ciphertext))
+		}
+		if plain, err := PlaintextSize(int64(len(ciphertext))); err != nil || plain != int64(size) {
+			t.Errorf("size %d: unexpected plaintext size %d, %v", size, plain, err)
+		}
+
+		plaintext, err := io.ReadAll(NewDecryptReader(bytes.NewReader(ciphertext), sk))
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if !bytes.Equal(plaintext, data) {
+			t.Errorf("size %d: the decrypted data differs", size)
+		}
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	sk := genKey(t)
+	data := make([]byte, 2*ChunkSize+10)
+	ciphertext := encrypt(t, data, sk)
+
+	for name, c := range map[string]struct {
+		data []byte
+		sk   crypto.PrivKey
+		err  error
+	}{
+		"wrong key":     {ciphertext, genKey(t), ErrDecrypt},
+		"truncated":     {ciphertext[:HeaderSize+ChunkSize+tagSize], sk, ErrDecrypt},
+		"modified":      {append(append([]byte(nil), ciphertext[:len(ciphertext)-1]...), ciphertext[len(ciphertext)-1]^1), sk, ErrDecrypt},
+		"not encrypted": {data, sk, ErrNotEncrypted},
+	} {
+		_, err := io.ReadAll(NewDecryptReader(bytes.NewReader(c.data), c.sk))
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, err)
+		}
+	}
+}
+
+func TestEncryptNode(t *testing.T) {
+	sk := genKey(t)
+	dir := files.NewMapDirectory(map[string]files.Node{
+		"a": files.NewBytesFile([]byte("secret")),
+		"b": files.NewMapDirectory(map[string]files.Node{
+			"c": files.NewBytesFile([]byte("nested secret")),
+		}),
+	})
+
+	var count int
+	err := files.Walk(EncryptNode(dir, sk), func(fpath string, nd files.Node) error {
+		f, ok := nd.(files.File)
+		if !ok {
+			return nil
+		}
+		count++
+		ciphertext, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		if size, err := f.Size(); err != nil || size != int64(len(ciphertext)) {
+			t.Errorf("%s: size %d, %v, expected %d", fpath, size, err, len(ciphertext))
+		}
+		plaintext, err := io.ReadAll(NewDecryptReader(bytes.NewReader(ciphertext), sk))
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(ciphertext, plaintext) {
+			t.Errorf("%s: the file is not encrypted", fpath)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 files, got %d", count)
+	}
+}
+
+func TestAgeFormat(t *testing.T) {
+	sk := genKey(t)
+	passphrase, err := keyPassphrase(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 2*ChunkSize+10)
+	rand.Read(data)
+
+	// the encrypted files are age files
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(encrypt(t, data, sk)), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Fatal("the data decrypted by age differs")
+	}
+
+	// and the age files encrypted with the passphrase are decrypted
+	ageEncrypt := func(workFactor int) []byte {
+		recipient, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recipient.SetWorkFactor(workFactor)
+		var buf bytes.Buffer
+		w, err := age.Encrypt(&buf, recipient)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	plaintext, err = io.ReadAll(NewDecryptReader(bytes.NewReader(ageEncrypt(keyWorkFactor)), sk))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, data) {
+		t.Fatal("the data encrypted by age differs")
+	}
+
+	// but not with a work factor making them costly to decrypt
+	_, err = io.ReadAll(NewDecryptReader(bytes.NewReader(ageEncrypt(keyWorkFactor+1)), sk))
+	if !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("expected %v, got %v", ErrDecrypt, err)
+	}
+}
+
+func TestDecryptSourceError(t *testing.T) {
+	sk := genKey(t)
+	ciphertext := encrypt(t, make([]byte, 2*ChunkSize), sk)
+	errRead := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader(ciphertext[:HeaderSize+ChunkSize]), iotest.ErrReader(errRead))
+
+	if _, err := io.ReadAll(NewDecryptReader(r, sk)); err != errRead {
+		t.Fatalf("expected the error of the source, got %v", err)
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	data := []byte("backup of the repo")
+	var buf bytes.Buffer
+	w, err := EncryptPassphrase(&buf, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(bufio.NewReader(bytes.NewReader(buf.Bytes()))) || IsEncrypted(bufio.NewReader(bytes.NewReader(data))) {
+		t.Fatal("IsEncrypted doesn't tell the encrypted data")
+	}
+
+	// the data is decrypted by age with the passphrase
+	identity, err := age.NewScryptIdentity("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := age.Decrypt(bytes.NewReader(buf.Bytes()), identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := io.ReadAll(r); err != nil || !bytes.Equal(plaintext, data) {
+		t.Fatalf("the data decrypted by age differs: %v", err)
+	}
+
+	if _, err := DecryptPassphrase(bytes.NewReader(buf.Bytes()), []byte("wrong")); err != ErrDecrypt {
+		t.Fatalf("expected %v, got %v", ErrDecrypt, err)
+	}
+}
@@ -0,0 +1,82 @@
+package encryption
+
+import (
+	"io"
+
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// EncryptNode returns the node with the content of its files encrypted with
+// sk, as they are read. The names of the files and of the directories, and
+// the targets of the symlinks, are not encrypted.
+func EncryptNode(nd files.Node, sk crypto.PrivKey) files.Node {
+	switch nd := nd.(type) {
+	case *files.Symlink:
+		return nd
+	case files.File:
+		f := &encryptedFile{File: nd}
+		f.r, f.err = NewEncryptReader(nd, sk)
+		return f
+	case files.Directory:
+		return &encryptedDirectory{Directory: nd, sk: sk}
+	default:
+		return nd
+	}
+}
+
+type encryptedFile struct {
+	files.File
+	r   io.Reader
+	err error
+}
+
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	return f.r.Read(p)
+}
+
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, files.ErrNotSupported
+}
+
+func (f *encryptedFile) Size() (int64, error) {
+	size, err := f.File.Size()
+	if err != nil {
+		return 0, err
+	}
+	return EncryptedSize(size), nil
+}
+
+type encryptedDirectory struct {
+	files.Directory
+	sk crypto.PrivKey
+}
+
+func (d *encryptedDirectory) Entries() files.DirIterator {
+	return &encryptedIterator{DirIterator: d.Directory.Entries(), sk: d.sk}
+}
+
+func (d *encryptedDirectory) Size() (int64, error) {
+	return 0, files.ErrNotSupported
+}
+
+type encryptedIterator struct {
+	files.DirIterator
+	sk crypto.PrivKey
+	nd files.Node
+}
+
+func (it *encryptedIterator) Next() bool {
+	it.nd = nil
+	return it.DirIterator.Next()
+}
+
+func (it *encryptedIterator) Node() files.Node {
+	if it.nd == nil {
+		it.nd = EncryptNode(it.DirIterator.Node(), it.sk)
+	}
+	return it.nd
+}
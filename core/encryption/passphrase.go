@@ -0,0 +1,38 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"filippo.io/age"
+)
+
+// IsEncrypted returns whether the data read by r is encrypted, with a key or
+// a passphrase, without consuming it.
+func IsEncrypted(r *bufio.Reader) bool {
+	intro, _ := r.Peek(len(ageIntro))
+	return bytes.Equal(intro, []byte(ageIntro))
+}
+
+// EncryptPassphrase returns a writer encrypting to w with the passphrase, in
+// the age format, with the default scrypt work factor of age. It must be
+// closed to write the last chunk.
+func EncryptPassphrase(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	recipient, err := age.NewScryptRecipient(string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return age.Encrypt(w, recipient)
+}
+
+// DecryptPassphrase returns a reader decrypting the data read by r, encrypted
+// with the passphrase. It fails with ErrDecrypt when the passphrase is wrong,
+// or as soon as a chunk doesn't authenticate.
+func DecryptPassphrase(r io.Reader, passphrase []byte) (io.Reader, error) {
+	identity, err := age.NewScryptIdentity(string(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return openAge(r, identity)
+}
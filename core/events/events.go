@@ -0,0 +1,173 @@
+// Package events implements a small in-process bus used to publish internal
+// node events (peer connections, pin changes, GC runs, IPNS publishes,
+// reprovide cycles, gateway errors and corrupted blocks) to interested
+// subscribers such as `ipfs events` and webhooks.
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("core/events")
+
+// Event types emitted by the node.
+const (
+	PeerConnected     = "peer.connected"
+	PeerDisconnected  = "peer.disconnected"
+	PinAdded          = "pin.added"
+	PinRemoved        = "pin.removed"
+	PinFailed         = "pin.failed"
+	GCStarted         = "gc.started"
+	GCFinished        = "gc.finished"
+	IpnsPublished     = "ipns.published"
+	ReprovideStarted  = "reprovide.started"
+	ReprovideFinished = "reprovide.finished"
+	GatewayError      = "gateway.error"
+	BlockCorrupted    = "block.corrupted"
+	ScrubFinished     = "scrub.finished"
+)
+
+// Types lists all event types known to the bus.
+var Types = []string{
+	PeerConnected,
+	PeerDisconnected,
+	PinAdded,
+	PinRemoved,
+	PinFailed,
+	GCStarted,
+	GCFinished,
+	IpnsPublished,
+	ReprovideStarted,
+	ReprovideFinished,
+	GatewayError,
+	BlockCorrupted,
+	ScrubFinished,
+}
+
+// DefaultBufferSize is the number of events buffered per subscriber before
+// new events start being dropped.
+const DefaultBufferSize = 128
+
+// Event is a single internal event.
+type Event struct {
+	Type string
+	Time time.Time
+	Data map[string]interface{} `json:",omitempty"`
+}
+
+// Bus fans out emitted events to subscribers. A nil *Bus is valid and
+// discards all events.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates a new event bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Emit publishes an event to all matching subscribers. Emit never blocks:
+// subscribers that are not keeping up miss events.
+func (b *Bus) Emit(typ string, data map[string]interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.subs) == 0 {
+		return
+	}
+
+	evt := Event{Type: typ, Time: time.Now(), Data: data}
+	for s := range b.subs {
+		if !s.matches(typ) {
+			continue
+		}
+		select {
+		case s.ch <- evt:
+		default:
+			s.mu.Lock()
+			s.dropped++
+			s.mu.Unlock()
+			log.Debugf("dropping %s event for slow subscriber", typ)
+		}
+	}
+}
+
+// Subscribe returns a subscription receiving events whose type matches one
+// of the given filters. A filter matches an event type exactly or as a
+// dot-separated prefix, so "pin" matches both "pin.added" and "pin.removed".
+// No filters means all events.
+func (b *Bus) Subscribe(bufSize int, filters ...string) *Subscription {
+	if bufSize <= 0 {
+		bufSize = DefaultBufferSize
+	}
+	s := &Subscription{
+		bus:     b,
+		ch:      make(chan Event, bufSize),
+		filters: filters,
+	}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Subscription is a filtered stream of events.
+type Subscription struct {
+	bus     *Bus
+	ch      chan Event
+	filters []string
+
+	mu      sync.Mutex
+	dropped uint64
+	closed  bool
+}
+
+// Out returns the channel events are delivered on. It is closed when the
+// subscription is closed.
+func (s *Subscription) Out() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the subscriber was
+// too slow.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close unsubscribes from the bus.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subs, s)
+	close(s.ch)
+}
+
+func (s *Subscription) matches(typ string) bool {
+	if len(s.filters) == 0 {
+		return true
+	}
+	for _, f := range s.filters {
+		if f == typ || strings.HasPrefix(typ, f+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestBusFilter(t *testing.T) {
+	b := NewBus()
+	all := b.Subscribe(0)
+	pins := b.Subscribe(0, "pin")
+	gc := b.Subscribe(0, GCFinished)
+
+	b.Emit(PinAdded, map[string]interface{}{"Cid": "bafy"})
+	b.Emit(GCStarted, nil)
+	b.Emit(GCFinished, nil)
+
+	if n := len(all.ch); n != 3 {
+		t.Fatalf("expected 3 events, got %d", n)
+	}
+	if n := len(pins.ch); n != 1 {
+		t.Fatalf("expected 1 pin event, got %d", n)
+	}
+	if evt := <-pins.Out(); evt.Type != PinAdded || evt.Data["Cid"] != "bafy" {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+	if evt := <-gc.Out(); evt.Type != GCFinished {
+		t.Fatalf("unexpected event %+v", evt)
+	}
+
+	all.Close()
+	all.Close()
+	for range all.Out() {
+		// drain buffered events, the loop ends once the channel is closed
+	}
+	b.Emit(PinRemoved, nil)
+	if n := len(pins.ch); n != 1 {
+		t.Fatalf("expected 1 pin event, got %d", n)
+	}
+}
+
+func TestBusDropsForSlowSubscriber(t *testing.T) {
+	b := NewBus()
+	s := b.Subscribe(1)
+	b.Emit(PinAdded, nil)
+	b.Emit(PinAdded, nil)
+	b.Emit(PinAdded, nil)
+	if d := s.Dropped(); d != 2 {
+		t.Fatalf("expected 2 dropped events, got %d", d)
+	}
+}
+
+func TestNilBus(t *testing.T) {
+	var b *Bus
+	b.Emit(PinAdded, nil)
+}
@@ -0,0 +1,87 @@
+// Package gatewayauth holds the authorizers of the gateway requests,
+// registered by plugins and applied by corehttp.
+package gatewayauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Decision is the decision of an Authorizer on a request.
+type Decision int
+
+const (
+	// Allow lets the request through, to the next authorizer or to the
+	// gateway.
+	Allow Decision = iota
+	// Deny refuses the request with 403 Forbidden.
+	Deny
+	// PaymentRequired refuses the request with 402 Payment Required, and the
+	// challenge telling the client how to pay.
+	PaymentRequired
+)
+
+// Request is a request to the gateway to authorize.
+type Request struct {
+	// Path is the content path requested, e.g. "/ipfs/<cid>/index.html",
+	// after the subdomain and DNSLink hostnames are mapped to it.
+	Path string
+	// Request is the HTTP request, with its headers, such as the credentials
+	// or the proof of payment of the client. It must not be modified.
+	Request *http.Request
+}
+
+// Authorization is the decision of an Authorizer.
+type Authorization struct {
+	Decision Decision
+	// Challenge is the value of the WWW-Authenticate header of the 402
+	// responses, e.g. an L402 macaroon and invoice.
+	Challenge string
+	// Header holds the headers added to the response, whatever the
+	// decision, e.g. the metering headers of the allowed requests.
+	Header http.Header
+	// Message is the body of the refused requests.
+	Message string
+}
+
+// Authorizer decides whether the gateway serves a request, e.g. by metering
+// the requests or checking a proof of payment. A nil authorization allows the
+// request, and lets shared caches keep the response: the responses of the
+// requests an authorizer decided on are sent with Cache-Control: no-store.
+type Authorizer func(ctx context.Context, req *Request) (*Authorization, error)
+
+var (
+	authorizersLk sync.RWMutex
+	authorizers   = make(map[string]Authorizer)
+)
+
+// Add registers the authorizer applied to the gateway requests when its name
+// is in Gateway.Authorizers.
+func Add(name string, a Authorizer) error {
+	authorizersLk.Lock()
+	defer authorizersLk.Unlock()
+
+	if _, ok := authorizers[name]; ok {
+		return fmt.Errorf("gateway authorizer %q already registered", name)
+	}
+	authorizers[name] = a
+	return nil
+}
+
+// Get returns the authorizers of names, failing if one of them isn't
+// registered.
+func Get(names []string) ([]Authorizer, error) {
+	authorizersLk.RLock()
+	defer authorizersLk.RUnlock()
+	out := make([]Authorizer, len(names))
+	for i, name := range names {
+		a, ok := authorizers[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown gateway authorizer %q, is its plugin loaded?", name)
+		}
+		out[i] = a
+	}
+	return out, nil
+}
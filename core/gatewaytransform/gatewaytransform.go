@@ -0,0 +1,74 @@
+// Package gatewaytransform holds the transforms of the files served by the
+// gateway, registered by plugins and applied by corehttp.
+package gatewaytransform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+
+	"github.com/ipfs/go-libipfs/files"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+)
+
+// Param is the query parameter selecting the transform of the file served by
+// the gateway, e.g. "?transform=thumbnail&width=200".
+const Param = "transform"
+
+// ErrUnsupported is returned by a Transform which can't transform the file,
+// e.g. a thumbnailer given a text file. It is reported to the client as 415
+// Unsupported Media Type.
+var ErrUnsupported = errors.New("file not supported by the transform")
+
+// Request is a UnixFS file to transform.
+type Request struct {
+	// Path is the resolved path of the file.
+	Path path.Resolved
+	// File is the content of the file, closed by the gateway.
+	File files.File
+	// Query holds the query parameters of the request, which parametrize
+	// the transform.
+	Query url.Values
+}
+
+// Response is the transformed file.
+type Response struct {
+	// Body is the transformed content, closed by the gateway.
+	Body io.ReadCloser
+	// ContentType is the media type of Body, sniffed if empty.
+	ContentType string
+}
+
+// Transform transforms the files served by the gateway. The response must
+// only depend on the file and the query parameters, as it is cached by
+// clients: the gateway sets an Etag derived from them.
+type Transform func(ctx context.Context, req *Request) (*Response, error)
+
+var (
+	transformsLk sync.RWMutex
+	transforms   = make(map[string]Transform)
+)
+
+// Add registers the transform applied to the files requested with
+// "?transform=<name>".
+func Add(name string, t Transform) error {
+	transformsLk.Lock()
+	defer transformsLk.Unlock()
+
+	if _, ok := transforms[name]; ok {
+		return fmt.Errorf("gateway transform %q already registered", name)
+	}
+	transforms[name] = t
+	return nil
+}
+
+// Get returns the transform registered with name.
+func Get(name string) (Transform, bool) {
+	transformsLk.RLock()
+	defer transformsLk.RUnlock()
+	t, ok := transforms[name]
+	return t, ok
+}
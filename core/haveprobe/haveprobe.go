@@ -0,0 +1,155 @@
+// Package haveprobe asks peers whether they have blocks with bitswap
+// WANT-HAVE requests, without fetching the blocks.
+//
+// The answers are received by the bitswap instance of the node: the Prober
+// must be installed as its tracer.
+package haveprobe
+
+import (
+	"context"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	bsmsg "github.com/ipfs/go-libipfs/bitswap/message"
+	pb "github.com/ipfs/go-libipfs/bitswap/message/pb"
+	bsnet "github.com/ipfs/go-libipfs/bitswap/network"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var log = logging.Logger("haveprobe")
+
+// Answers are the answers of the peers to a probe, by block.
+type Answers map[cid.Cid]map[peer.ID]bool
+
+type answer struct {
+	peer peer.ID
+	c    cid.Cid
+	have bool
+}
+
+// Prober sends WANT-HAVE requests and collects the HAVE and DONT_HAVE
+// answers.
+type Prober struct {
+	host host.Host
+
+	mu      sync.Mutex
+	waiters map[chan<- answer]struct{}
+}
+
+// New returns a Prober sending requests from h.
+func New(h host.Host) *Prober {
+	return &Prober{
+		host:    h,
+		waiters: make(map[chan<- answer]struct{}),
+	}
+}
+
+// MessageReceived implements the bitswap tracer interface.
+func (p *Prober) MessageReceived(from peer.ID, msg bsmsg.BitSwapMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.waiters) == 0 {
+		return
+	}
+	var answers []answer
+	for _, c := range msg.Haves() {
+		answers = append(answers, answer{from, c, true})
+	}
+	for _, b := range msg.Blocks() {
+		answers = append(answers, answer{from, b.Cid(), true})
+	}
+	for _, c := range msg.DontHaves() {
+		answers = append(answers, answer{from, c, false})
+	}
+	for ch := range p.waiters {
+		for _, a := range answers {
+			select {
+			case ch <- a:
+			default:
+				// the waiter is gone or too slow, it will time out
+			}
+		}
+	}
+}
+
+// MessageSent implements the bitswap tracer interface.
+func (p *Prober) MessageSent(peer.ID, bsmsg.BitSwapMessage) {}
+
+// Probe asks peers whether they have the blocks cids, and waits for their
+// answers until all of them answered or ctx is done. Peers that didn't
+// answer about a block are missing from its answers.
+func (p *Prober) Probe(ctx context.Context, peers []peer.ID, cids []cid.Cid) Answers {
+	answers := make(Answers, len(cids))
+	for _, c := range cids {
+		answers[c] = make(map[peer.ID]bool)
+	}
+
+	ch := make(chan answer, len(peers)*len(cids))
+	p.mu.Lock()
+	p.waiters[ch] = struct{}{}
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.waiters, ch)
+		p.mu.Unlock()
+	}()
+
+	want := bsmsg.New(false)
+	cancel := bsmsg.New(false)
+	for _, c := range cids {
+		want.AddEntry(c, 0, pb.Message_Wantlist_Have, true)
+		cancel.Cancel(c)
+	}
+	asked := make(map[peer.ID]struct{}, len(peers))
+	for _, pid := range peers {
+		if err := p.send(ctx, pid, want); err != nil {
+			log.Debugf("probing %s: %s", pid, err)
+			continue
+		}
+		asked[pid] = struct{}{}
+	}
+	// leave nothing in the wantlists of the peers, whatever happens
+	defer func() {
+		for pid := range asked {
+			if err := p.send(context.Background(), pid, cancel); err != nil {
+				log.Debugf("cancelling probe of %s: %s", pid, err)
+			}
+		}
+	}()
+
+	pending := len(asked) * len(cids)
+	for pending > 0 {
+		select {
+		case a := <-ch:
+			if _, ok := asked[a.peer]; !ok {
+				continue
+			}
+			peerAnswers, ok := answers[a.c]
+			if !ok {
+				continue
+			}
+			if _, ok := peerAnswers[a.peer]; !ok {
+				pending--
+			}
+			peerAnswers[a.peer] = peerAnswers[a.peer] || a.have
+		case <-ctx.Done():
+			return answers
+		}
+	}
+	return answers
+}
+
+func (p *Prober) send(ctx context.Context, pid peer.ID, msg bsmsg.BitSwapMessage) error {
+	// WANT-HAVE requests need bitswap 1.2.0
+	s, err := p.host.NewStream(ctx, pid, bsnet.ProtocolBitswap)
+	if err != nil {
+		return err
+	}
+	if err := msg.ToNetV1(s); err != nil {
+		_ = s.Reset()
+		return err
+	}
+	return s.Close()
+}
@@ -0,0 +1,308 @@
+package ipni
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/multiformats/go-multihash"
+)
+
+// bitswapMetadata is the metadata of the advertisements: the varint of the
+// transport-bitswap multicodec, the content being retrieved over bitswap.
+var bitswapMetadata = func() []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	return buf[:binary.PutUvarint(buf, 0x0900)]
+}()
+
+// blockPrefix is the prefix of the CIDs of the advertisement chain, of
+// dag-json blocks.
+var blockPrefix = cid.Prefix{
+	Version:  1,
+	Codec:    cid.DagJSON,
+	MhType:   multihash.SHA2_256,
+	MhLength: -1,
+}
+
+// Advertisement is an IPNI advertisement of the node, announcing the
+// multihashes of its entries.
+type Advertisement struct {
+	PreviousID cid.Cid // cid.Undef for the first advertisement
+	Provider   string
+	Addresses  []string
+	Entries    cid.Cid
+	ContextID  []byte
+	Metadata   []byte
+	IsRm       bool
+	Signature  []byte
+}
+
+// signaturePayload is the hash of the fields of the advertisement, the
+// payload of its signature.
+func (ad *Advertisement) signaturePayload() ([]byte, error) {
+	var buf bytes.Buffer
+	if ad.PreviousID.Defined() {
+		buf.Write(ad.PreviousID.Bytes())
+	}
+	buf.WriteString(ad.Provider)
+	for _, addr := range ad.Addresses {
+		buf.WriteString(addr)
+	}
+	buf.Write(ad.Entries.Bytes())
+	buf.Write(ad.ContextID)
+	buf.Write(ad.Metadata)
+	if ad.IsRm {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	return multihash.Sum(buf.Bytes(), multihash.SHA2_256, -1)
+}
+
+// Sign sets the signature of the advertisement, an envelope signed with sk.
+func (ad *Advertisement) Sign(sk crypto.PrivKey) error {
+	payload, err := ad.signaturePayload()
+	if err != nil {
+		return err
+	}
+	env, err := record.Seal(&adSignature{payload: payload}, sk)
+	if err != nil {
+		return err
+	}
+	ad.Signature, err = env.Marshal()
+	return err
+}
+
+// Verify checks the signature of the advertisement, and returns the public
+// key it is signed with.
+func (ad *Advertisement) Verify() (crypto.PubKey, error) {
+	sig := &adSignature{}
+	env, err := record.ConsumeTypedEnvelope(ad.Signature, sig)
+	if err != nil {
+		return nil, fmt.Errorf("invalid advertisement signature: %w", err)
+	}
+	payload, err := ad.signaturePayload()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(payload, sig.payload) {
+		return nil, errors.New("the advertisement signature doesn't match its fields")
+	}
+	return env.PublicKey, nil
+}
+
+func (ad *Advertisement) node() (datamodel.Node, error) {
+	return qp.BuildMap(basicnode.Prototype.Map, -1, func(ma datamodel.MapAssembler) {
+		if ad.PreviousID.Defined() {
+			qp.MapEntry(ma, "PreviousID", qp.Link(cidlink.Link{Cid: ad.PreviousID}))
+		}
+		qp.MapEntry(ma, "Provider", qp.String(ad.Provider))
+		qp.MapEntry(ma, "Addresses", qp.List(int64(len(ad.Addresses)), func(la datamodel.ListAssembler) {
+			for _, addr := range ad.Addresses {
+				qp.ListEntry(la, qp.String(addr))
+			}
+		}))
+		qp.MapEntry(ma, "Signature", qp.Bytes(ad.Signature))
+		qp.MapEntry(ma, "Entries", qp.Link(cidlink.Link{Cid: ad.Entries}))
+		qp.MapEntry(ma, "ContextID", qp.Bytes(ad.ContextID))
+		qp.MapEntry(ma, "Metadata", qp.Bytes(ad.Metadata))
+		qp.MapEntry(ma, "IsRm", qp.Bool(ad.IsRm))
+	})
+}
+
+// Encode returns the dag-json block of the advertisement and its CID.
+func (ad *Advertisement) Encode() (cid.Cid, []byte, error) {
+	nd, err := ad.node()
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return encode(nd)
+}
+
+// DecodeAdvertisement decodes the dag-json block of an advertisement.
+func DecodeAdvertisement(data []byte) (*Advertisement, error) {
+	nd, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	ad := &Advertisement{}
+	if prev, err := nd.LookupByString("PreviousID"); err == nil {
+		if ad.PreviousID, err = asCid(prev); err != nil {
+			return nil, err
+		}
+	}
+	if ad.Provider, err = lookupString(nd, "Provider"); err != nil {
+		return nil, err
+	}
+	addrs, err := nd.LookupByString("Addresses")
+	if err != nil {
+		return nil, err
+	}
+	for it := addrs.ListIterator(); it != nil && !it.Done(); {
+		_, v, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		addr, err := v.AsString()
+		if err != nil {
+			return nil, err
+		}
+		ad.Addresses = append(ad.Addresses, addr)
+	}
+	entries, err := nd.LookupByString("Entries")
+	if err != nil {
+		return nil, err
+	}
+	if ad.Entries, err = asCid(entries); err != nil {
+		return nil, err
+	}
+	if ad.Signature, err = lookupBytes(nd, "Signature"); err != nil {
+		return nil, err
+	}
+	if ad.ContextID, err = lookupBytes(nd, "ContextID"); err != nil {
+		return nil, err
+	}
+	if ad.Metadata, err = lookupBytes(nd, "Metadata"); err != nil {
+		return nil, err
+	}
+	isRm, err := nd.LookupByString("IsRm")
+	if err != nil {
+		return nil, err
+	}
+	if ad.IsRm, err = isRm.AsBool(); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
+
+// encodeEntryChunk returns the dag-json block of a chunk of the entries of
+// an advertisement, linking to the next chunk unless cid.Undef, and its CID.
+func encodeEntryChunk(mhs []multihash.Multihash, next cid.Cid) (cid.Cid, []byte, error) {
+	nd, err := qp.BuildMap(basicnode.Prototype.Map, -1, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "Entries", qp.List(int64(len(mhs)), func(la datamodel.ListAssembler) {
+			for _, mh := range mhs {
+				qp.ListEntry(la, qp.Bytes(mh))
+			}
+		}))
+		if next.Defined() {
+			qp.MapEntry(ma, "Next", qp.Link(cidlink.Link{Cid: next}))
+		}
+	})
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return encode(nd)
+}
+
+// decodeEntryChunk decodes the dag-json block of a chunk of entries, and
+// returns its multihashes and the CID of the next chunk, cid.Undef for the
+// last one.
+func decodeEntryChunk(data []byte) ([]multihash.Multihash, cid.Cid, error) {
+	nd, err := decode(data)
+	if err != nil {
+		return nil, cid.Undef, err
+	}
+	entries, err := nd.LookupByString("Entries")
+	if err != nil {
+		return nil, cid.Undef, err
+	}
+	var mhs []multihash.Multihash
+	for it := entries.ListIterator(); it != nil && !it.Done(); {
+		_, v, err := it.Next()
+		if err != nil {
+			return nil, cid.Undef, err
+		}
+		b, err := v.AsBytes()
+		if err != nil {
+			return nil, cid.Undef, err
+		}
+		mhs = append(mhs, b)
+	}
+	next := cid.Undef
+	if nl, err := nd.LookupByString("Next"); err == nil {
+		if next, err = asCid(nl); err != nil {
+			return nil, cid.Undef, err
+		}
+	}
+	return mhs, next, nil
+}
+
+func encode(nd datamodel.Node) (cid.Cid, []byte, error) {
+	var buf bytes.Buffer
+	if err := dagjson.Encode(nd, &buf); err != nil {
+		return cid.Undef, nil, err
+	}
+	c, err := blockPrefix.Sum(buf.Bytes())
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	return c, buf.Bytes(), nil
+}
+
+func decode(data []byte) (datamodel.Node, error) {
+	nb := basicnode.Prototype.Any.NewBuilder()
+	if err := dagjson.Decode(nb, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return nb.Build(), nil
+}
+
+func asCid(nd datamodel.Node) (cid.Cid, error) {
+	l, err := nd.AsLink()
+	if err != nil {
+		return cid.Undef, err
+	}
+	cl, ok := l.(cidlink.Link)
+	if !ok {
+		return cid.Undef, fmt.Errorf("unexpected link %s", l)
+	}
+	return cl.Cid, nil
+}
+
+func lookupString(nd datamodel.Node, key string) (string, error) {
+	v, err := nd.LookupByString(key)
+	if err != nil {
+		return "", err
+	}
+	return v.AsString()
+}
+
+func lookupBytes(nd datamodel.Node, key string) ([]byte, error) {
+	v, err := nd.LookupByString(key)
+	if err != nil {
+		return nil, err
+	}
+	return v.AsBytes()
+}
+
+// adSignature is the record of the signature of an advertisement, the hash
+// of its fields.
+type adSignature struct {
+	payload []byte
+}
+
+func (s *adSignature) Domain() string {
+	return "indexer"
+}
+
+func (s *adSignature) Codec() []byte {
+	return []byte("/indexer/ingest/adSignature")
+}
+
+func (s *adSignature) MarshalRecord() ([]byte, error) {
+	return s.payload, nil
+}
+
+func (s *adSignature) UnmarshalRecord(data []byte) error {
+	s.payload = data
+	return nil
+}
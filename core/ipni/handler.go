@@ -0,0 +1,102 @@
+package ipni
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipld/go-ipld-prime/codec/dagjson"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// HTTPPath is the path the advertisements are served at, by the HTTP
+// publisher of the IPNI specification.
+const HTTPPath = "/ipni/v1/ad/"
+
+// headTopic is the topic of the signed head.
+const headTopic = "/indexer/ingest/mainnet"
+
+// ServeHTTP serves the signed head at HTTPPath/head, and the blocks of the
+// advertisements and their entries at HTTPPath/<cid>.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, HTTPPath)
+	if name == "head" {
+		p.serveHead(w, r)
+		return
+	}
+
+	c, err := cid.Decode(name)
+	if err != nil {
+		http.Error(w, "invalid CID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := p.Block(r.Context(), c)
+	if err == datastore.ErrNotFound {
+		http.Error(w, "advertisement not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	w.Write(data)
+}
+
+func (p *Publisher) serveHead(w http.ResponseWriter, r *http.Request) {
+	head, err := p.Head(r.Context())
+	if err == errNoHead {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data, err := signedHead(p.sk, head)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// signedHead returns the dag-json of the head, signed with sk along with
+// the topic.
+func signedHead(sk crypto.PrivKey, head cid.Cid) ([]byte, error) {
+	pub, err := crypto.MarshalPublicKey(sk.GetPublic())
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sk.Sign(append(head.Bytes(), headTopic...))
+	if err != nil {
+		return nil, err
+	}
+	nd, err := qp.BuildMap(basicnode.Prototype.Map, 4, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "head", qp.Link(cidlink.Link{Cid: head}))
+		qp.MapEntry(ma, "topic", qp.String(headTopic))
+		qp.MapEntry(ma, "pubkey", qp.Bytes(pub))
+		qp.MapEntry(ma, "sig", qp.Bytes(sig))
+	})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := dagjson.Encode(nd, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,346 @@
+// Package ipni publishes the content provided by the node to IPNI indexers,
+// such as cid.contact, as a chain of signed advertisements. The
+// advertisements are served over HTTP by the node, and announced to the
+// indexers, which fetch them and find the node as a provider of their
+// multihashes, without waiting for the DHT reprovide cycle.
+package ipni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("ipni")
+
+var (
+	dsPrefix        = datastore.NewKey("/ipni")
+	headKey         = datastore.NewKey("/head")
+	blocksKey       = datastore.NewKey("/blocks")
+	advertisedKey   = datastore.NewKey("/advertised")
+	errNoHead       = errors.New("no advertisement published yet")
+	announceTimeout = time.Minute
+)
+
+// chunksPerAd is the number of entry chunks collected before the pending
+// multihashes are published without waiting for the interval.
+const chunksPerAd = 64
+
+// Params are the parameters of a Publisher.
+type Params struct {
+	// Indexers are the base URLs of the indexers the advertisements are
+	// announced to.
+	Indexers []string
+	// PublisherAddrs are the HTTP multiaddrs the indexers fetch the
+	// advertisements from.
+	PublisherAddrs []ma.Multiaddr
+	// Addrs returns the addresses the content is retrieved from, set in
+	// the advertisements.
+	Addrs func() []ma.Multiaddr
+	// ChunkSize is the maximum number of multihashes per entry chunk.
+	ChunkSize int
+	// Interval is how long the multihashes provided are collected before
+	// being published in an advertisement.
+	Interval time.Duration
+}
+
+// Publisher publishes the multihashes added to it as advertisements chained
+// to the previous ones, signed with the key of the node. The multihashes
+// already advertised aren't advertised again.
+type Publisher struct {
+	ds     datastore.Batching
+	sk     crypto.PrivKey
+	id     peer.ID
+	params Params
+	client *http.Client
+
+	mu      sync.Mutex
+	pending []multihash.Multihash
+	queued  map[string]struct{}
+	publish sync.Mutex // serializes the publications, which extend the head
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Publisher storing its advertisements in ds, which publishes
+// every interval once started.
+func New(ds datastore.Batching, sk crypto.PrivKey, params Params) (*Publisher, error) {
+	id, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.PublisherAddrs) == 0 {
+		return nil, errors.New("no publisher address the indexers can fetch the advertisements from")
+	}
+	if params.ChunkSize < 1 {
+		return nil, fmt.Errorf("invalid chunk size %d", params.ChunkSize)
+	}
+	return &Publisher{
+		ds:     namespace.Wrap(ds, dsPrefix),
+		sk:     sk,
+		id:     id,
+		params: params,
+		client: &http.Client{Timeout: announceTimeout},
+		queued: make(map[string]struct{}),
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}, nil
+}
+
+// Start publishes the pending multihashes in the background until Stop is
+// called.
+func (p *Publisher) Start() {
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.params.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			case <-p.kick:
+			}
+			if _, err := p.Publish(context.Background()); err != nil {
+				log.Errorf("failed to publish the IPNI advertisement: %s", err)
+			}
+		}
+	}()
+}
+
+// Stop stops publishing. The multihashes pending are dropped: not being
+// advertised, they are added again by the next reprovide.
+func (p *Publisher) Stop(ctx context.Context) error {
+	close(p.stop)
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Add queues the multihashes not advertised yet to be published in the next
+// advertisement.
+func (p *Publisher) Add(ctx context.Context, mhs ...multihash.Multihash) error {
+	var fresh []multihash.Multihash
+	for _, mh := range mhs {
+		advertised, err := p.ds.Has(ctx, advertisedKey.Child(dshelp.MultihashToDsKey(mh)))
+		if err != nil {
+			return err
+		}
+		if !advertised {
+			fresh = append(fresh, mh)
+		}
+	}
+
+	p.mu.Lock()
+	for _, mh := range fresh {
+		if _, ok := p.queued[string(mh)]; ok {
+			continue
+		}
+		p.queued[string(mh)] = struct{}{}
+		p.pending = append(p.pending, mh)
+	}
+	full := len(p.pending) >= chunksPerAd*p.params.ChunkSize
+	p.mu.Unlock()
+
+	if full {
+		select {
+		case p.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Publish publishes the pending multihashes in an advertisement, chained to
+// the head, and announces it to the indexers. It returns the CID of the
+// advertisement, cid.Undef when nothing is pending.
+func (p *Publisher) Publish(ctx context.Context) (cid.Cid, error) {
+	p.publish.Lock()
+	defer p.publish.Unlock()
+
+	p.mu.Lock()
+	mhs := p.pending
+	p.pending = nil
+	p.queued = make(map[string]struct{})
+	p.mu.Unlock()
+	if len(mhs) == 0 {
+		return cid.Undef, nil
+	}
+
+	c, err := p.publishAd(ctx, mhs)
+	if err != nil {
+		// queue them back, for the next publication
+		if addErr := p.Add(ctx, mhs...); addErr != nil {
+			log.Errorf("failed to queue back the multihashes: %s", addErr)
+		}
+		return cid.Undef, err
+	}
+	if err := p.announce(ctx, c); err != nil {
+		// the indexers will find the advertisement with the next one
+		log.Warnf("failed to announce the IPNI advertisement %s: %s", c, err)
+	}
+	return c, nil
+}
+
+// publishAd stores the entries and the advertisement of mhs, and makes the
+// advertisement the head.
+func (p *Publisher) publishAd(ctx context.Context, mhs []multihash.Multihash) (cid.Cid, error) {
+	batch, err := p.ds.Batch(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	// the chunks are linked from the first to the last: build them from the
+	// last
+	entries := cid.Undef
+	for end := len(mhs); end > 0; end -= p.params.ChunkSize {
+		start := end - p.params.ChunkSize
+		if start < 0 {
+			start = 0
+		}
+		c, data, err := encodeEntryChunk(mhs[start:end], entries)
+		if err != nil {
+			return cid.Undef, err
+		}
+		if err := batch.Put(ctx, blockKey(c), data); err != nil {
+			return cid.Undef, err
+		}
+		entries = c
+	}
+
+	prev, err := p.Head(ctx)
+	if err != nil && err != errNoHead {
+		return cid.Undef, err
+	}
+	var addrs []string
+	if p.params.Addrs != nil {
+		for _, a := range p.params.Addrs() {
+			addrs = append(addrs, a.String())
+		}
+	}
+	ad := &Advertisement{
+		PreviousID: prev,
+		Provider:   p.id.String(),
+		Addresses:  addrs,
+		Entries:    entries,
+		// the entries of each advertisement are new: their CID identifies
+		// them
+		ContextID: entries.Bytes(),
+		Metadata:  bitswapMetadata,
+	}
+	if err := ad.Sign(p.sk); err != nil {
+		return cid.Undef, err
+	}
+	c, data, err := ad.Encode()
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := batch.Put(ctx, blockKey(c), data); err != nil {
+		return cid.Undef, err
+	}
+	for _, mh := range mhs {
+		if err := batch.Put(ctx, advertisedKey.Child(dshelp.MultihashToDsKey(mh)), nil); err != nil {
+			return cid.Undef, err
+		}
+	}
+	if err := batch.Put(ctx, headKey, c.Bytes()); err != nil {
+		return cid.Undef, err
+	}
+	if err := batch.Commit(ctx); err != nil {
+		return cid.Undef, err
+	}
+	log.Infow("published IPNI advertisement", "cid", c, "entries", len(mhs))
+	return c, nil
+}
+
+// Head returns the CID of the latest advertisement.
+func (p *Publisher) Head(ctx context.Context) (cid.Cid, error) {
+	data, err := p.ds.Get(ctx, headKey)
+	if err == datastore.ErrNotFound {
+		return cid.Undef, errNoHead
+	}
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Cast(data)
+}
+
+// Block returns the block of an advertisement or a chunk of entries.
+func (p *Publisher) Block(ctx context.Context, c cid.Cid) ([]byte, error) {
+	return p.ds.Get(ctx, blockKey(c))
+}
+
+func blockKey(c cid.Cid) datastore.Key {
+	return blocksKey.Child(dshelp.MultihashToDsKey(c.Hash()))
+}
+
+// announceMessage is the announcement of an advertisement to an indexer.
+type announceMessage struct {
+	Cid   cid.Cid
+	Addrs [][]byte
+}
+
+// announce tells the indexers the advertisement c is the head, to be fetched
+// from the publisher addresses.
+func (p *Publisher) announce(ctx context.Context, c cid.Cid) error {
+	p2p, err := ma.NewComponent("p2p", p.id.String())
+	if err != nil {
+		return err
+	}
+	msg := announceMessage{Cid: c}
+	for _, a := range p.params.PublisherAddrs {
+		msg.Addrs = append(msg.Addrs, a.Encapsulate(p2p).Bytes())
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, indexer := range p.params.Indexers {
+		if err := p.announceTo(ctx, indexer, body); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s: %w", indexer, err))
+		}
+	}
+	return errs
+}
+
+func (p *Publisher) announceTo(ctx context.Context, indexer string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimSuffix(indexer, "/")+"/announce", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
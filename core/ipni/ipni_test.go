@@ -0,0 +1,182 @@
+package ipni
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+)
+
+func testMultihashes(t *testing.T, n int, seed byte) []multihash.Multihash {
+	mhs := make([]multihash.Multihash, n)
+	for i := range mhs {
+		mh, err := multihash.Sum([]byte{seed, byte(i)}, multihash.SHA2_256, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mhs[i] = mh
+	}
+	return mhs
+}
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+	sk, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var announced []announceMessage
+	indexer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/announce" {
+			t.Errorf("unexpected announcement %s %s", r.Method, r.URL.Path)
+		}
+		var msg announceMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		announced = append(announced, msg)
+		mu.Unlock()
+	}))
+	defer indexer.Close()
+
+	p, err := New(dssync.MutexWrap(datastore.NewMapDatastore()), sk, Params{
+		Indexers:       []string{indexer.URL},
+		PublisherAddrs: []ma.Multiaddr{ma.StringCast("/dns4/example.net/tcp/443/https")},
+		Addrs:          func() []ma.Multiaddr { return []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/4001")} },
+		ChunkSize:      3,
+		Interval:       time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c, err := p.Publish(ctx); err != nil || c.Defined() {
+		t.Fatalf("expected nothing to publish, got %s, %v", c, err)
+	}
+
+	first := testMultihashes(t, 7, 0)
+	if err := p.Add(ctx, first...); err != nil {
+		t.Fatal(err)
+	}
+	c1, err := p.Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the multihashes advertised are skipped
+	if err := p.Add(ctx, append(first, testMultihashes(t, 2, 1)...)...); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := p.Publish(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head, err := p.Head(ctx); err != nil || head != c2 {
+		t.Fatalf("expected the head %s, got %s, %v", c2, head, err)
+	}
+
+	ad := checkAd(t, p, c2, 2)
+	if ad.PreviousID != c1 {
+		t.Errorf("expected the previous advertisement %s, got %s", c1, ad.PreviousID)
+	}
+	if ad := checkAd(t, p, c1, 7); ad.PreviousID.Defined() {
+		t.Errorf("expected no previous advertisement, got %s", ad.PreviousID)
+	}
+
+	mu.Lock()
+	if len(announced) != 2 || announced[1].Cid != c2 || len(announced[1].Addrs) != 1 {
+		t.Errorf("unexpected announcements %v", announced)
+	} else if addr, err := ma.NewMultiaddrBytes(announced[1].Addrs[0]); err != nil || addr.String() != "/dns4/example.net/tcp/443/https/p2p/"+p.id.String() {
+		t.Errorf("unexpected publisher address %s, %v", addr, err)
+	}
+	mu.Unlock()
+
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	for _, c := range []cid.Cid{c1, ad.Entries} {
+		resp, err := http.Get(srv.URL + HTTPPath + c.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %s for %s", resp.Status, c)
+		}
+		if served, err := blockPrefix.Sum(data); err != nil || served != c {
+			t.Errorf("served %s for %s", served, c)
+		}
+	}
+	resp, err := http.Get(srv.URL + HTTPPath + "head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("unexpected status %s for the head", resp.Status)
+	}
+}
+
+// checkAd checks the signature of the advertisement c, and that its entries
+// are n multihashes, and returns it.
+func checkAd(t *testing.T, p *Publisher, c cid.Cid, n int) *Advertisement {
+	ctx := context.Background()
+	data, err := p.Block(ctx, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ad, err := DecodeAdvertisement(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ad.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Equals(p.sk.GetPublic()) {
+		t.Error("the advertisement isn't signed with the key of the publisher")
+	}
+	if ad.Provider != p.id.String() || len(ad.Addresses) != 1 {
+		t.Errorf("unexpected provider %s at %v", ad.Provider, ad.Addresses)
+	}
+
+	ad.Metadata = nil
+	if _, err := ad.Verify(); err == nil {
+		t.Error("expected the altered advertisement to fail the verification")
+	}
+
+	entries := 0
+	for next := ad.Entries; next.Defined(); {
+		data, err := p.Block(ctx, next)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mhs []multihash.Multihash
+		if mhs, next, err = decodeEntryChunk(data); err != nil {
+			t.Fatal(err)
+		}
+		if len(mhs) > p.params.ChunkSize {
+			t.Errorf("chunk of %d entries, more than %d", len(mhs), p.params.ChunkSize)
+		}
+		entries += len(mhs)
+	}
+	if entries != n {
+		t.Errorf("expected %d entries, got %d", n, entries)
+	}
+	return ad
+}
@@ -0,0 +1,379 @@
+// Package jobs runs long-running RPC operations in the background, e.g.
+// 'ipfs add --async', so that they survive the disconnection of the client.
+// The client polls or streams the progress of a job by its ID, fetches its
+// results and output, or cancels it.
+//
+// The jobs are kept in memory only: they don't survive a restart of the node.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+)
+
+var log = logging.Logger("jobs")
+
+const (
+	// keepFinished is the number of finished jobs kept for their status,
+	// results and output to be queried.
+	keepFinished = 100
+
+	// maxResults is the number of results kept per job, the oldest ones are
+	// dropped.
+	maxResults = 1000
+)
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// State is the state of a job.
+type State string
+
+const (
+	Running   State = "running"
+	Done      State = "done"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Final returns whether the job is finished.
+func (s State) Final() bool {
+	return s != Running
+}
+
+// Status is a snapshot of a job.
+type Status struct {
+	ID      string
+	Command string
+	State   State
+	// Progress is the last progress reported by the job.
+	Progress interface{} `json:",omitempty"`
+	// Results counts the results of the job, DroppedResults the oldest ones
+	// which aren't kept.
+	Results        int
+	DroppedResults int `json:",omitempty"`
+	// Output is true if the job writes an output, e.g. a CAR file.
+	Output bool `json:",omitempty"`
+	// Seq increases with every change of the job.
+	Seq      uint64
+	Err      string `json:",omitempty"`
+	Created  time.Time
+	Finished time.Time
+}
+
+// Func is the function of a job. It's cancelled with ctx.
+type Func func(ctx context.Context, h *Handle) error
+
+type job struct {
+	id      string
+	num     uint64
+	command string
+	created time.Time
+	cancel  context.CancelFunc
+
+	// protected by the lock of the manager
+	seq      uint64
+	state    State
+	progress interface{}
+	results  []interface{}
+	dropped  int
+	output   string
+	err      error
+	finished time.Time
+	// changed is closed and replaced on every change
+	changed chan struct{}
+}
+
+// Manager runs the jobs.
+type Manager struct {
+	ctx context.Context
+
+	mu       sync.Mutex
+	num      uint64
+	dir      string
+	jobs     map[string]*job
+	finished []*job
+}
+
+// New returns a Manager. The jobs are cancelled and their files removed when
+// ctx is done.
+func New(ctx context.Context) *Manager {
+	m := &Manager{ctx: ctx, jobs: make(map[string]*job)}
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if m.dir != "" {
+			os.RemoveAll(m.dir)
+		}
+	}()
+	return m
+}
+
+// Start runs a job of the given command in the background, and returns its
+// ID.
+func (m *Manager) Start(command string, fn Func) string {
+	ctx, cancel := context.WithCancel(m.ctx)
+
+	m.mu.Lock()
+	m.num++
+	j := &job{
+		id:      strconv.FormatUint(m.num, 10),
+		num:     m.num,
+		command: command,
+		created: time.Now(),
+		cancel:  cancel,
+		state:   Running,
+		changed: make(chan struct{}),
+	}
+	m.jobs[j.id] = j
+	m.mu.Unlock()
+
+	go func() {
+		err := fn(ctx, &Handle{m: m, j: j})
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		switch {
+		case err == nil:
+			m.finish(j, Done)
+		case ctx.Err() != nil:
+			m.finish(j, Cancelled)
+		default:
+			log.Debugf("job %s (%s): %s", j.id, j.command, err)
+			j.err = err
+			m.finish(j, Failed)
+		}
+		cancel()
+	}()
+	return j.id
+}
+
+// update marks j as changed. m.mu must be held.
+func (j *job) update() {
+	j.seq++
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// finish moves j to a final state and forgets the oldest finished jobs. m.mu
+// must be held.
+func (m *Manager) finish(j *job, state State) {
+	j.state = state
+	j.finished = time.Now()
+	j.update()
+	m.finished = append(m.finished, j)
+	if len(m.finished) > keepFinished {
+		old := m.finished[0]
+		m.finished = m.finished[1:]
+		delete(m.jobs, old.id)
+		if old.output != "" {
+			os.Remove(old.output)
+		}
+	}
+}
+
+// Status returns the status of a job.
+func (m *Manager) Status(id string) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+	return j.status(), nil
+}
+
+// Next waits for the job to change after seq, or to be finished, and returns
+// its status.
+func (m *Manager) Next(ctx context.Context, id string, seq uint64) (Status, error) {
+	for {
+		m.mu.Lock()
+		j, ok := m.jobs[id]
+		if !ok {
+			m.mu.Unlock()
+			return Status{}, ErrNotFound
+		}
+		if j.seq > seq || j.state.Final() {
+			s := j.status()
+			m.mu.Unlock()
+			return s, nil
+		}
+		changed := j.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return Status{}, ctx.Err()
+		}
+	}
+}
+
+// List returns the status of the jobs, the oldest first.
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].num < jobs[k].num })
+	out := make([]Status, len(jobs))
+	for i, j := range jobs {
+		out[i] = j.status()
+	}
+	return out
+}
+
+// Results returns the results kept for a job.
+func (m *Manager) Results(id string) ([]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]interface{}(nil), j.results...), nil
+}
+
+// Output opens the output of a job which is done.
+func (m *Manager) Output(id string) (io.ReadCloser, error) {
+	m.mu.Lock()
+	j, ok := m.jobs[id]
+	var path string
+	var state State
+	if ok {
+		path, state = j.output, j.state
+	}
+	m.mu.Unlock()
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case path == "":
+		return nil, fmt.Errorf("job %s has no output", id)
+	case state != Done:
+		return nil, fmt.Errorf("job %s is %s", id, state)
+	}
+	return os.Open(path)
+}
+
+// Cancel cancels a running job.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	// the job is finished by its goroutine
+	j.cancel()
+	return nil
+}
+
+func (j *job) status() Status {
+	s := Status{
+		ID:             j.id,
+		Command:        j.command,
+		State:          j.state,
+		Progress:       j.progress,
+		Results:        len(j.results) + j.dropped,
+		DroppedResults: j.dropped,
+		Output:         j.output != "",
+		Seq:            j.seq,
+		Created:        j.created,
+		Finished:       j.finished,
+	}
+	if j.err != nil {
+		s.Err = j.err.Error()
+	}
+	return s
+}
+
+// mkdir returns the directory of the files of the jobs, creating it first.
+func (m *Manager) mkdir() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dir != "" {
+		return m.dir, nil
+	}
+	if err := m.ctx.Err(); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp("", "ipfs-jobs-")
+	if err != nil {
+		return "", err
+	}
+	m.dir = dir
+	return dir, nil
+}
+
+// Handle is used by a job to report its progress, results and output.
+type Handle struct {
+	m *Manager
+	j *job
+}
+
+// ID returns the ID of the job.
+func (h *Handle) ID() string {
+	return h.j.id
+}
+
+// SetProgress reports the progress of the job.
+func (h *Handle) SetProgress(v interface{}) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.j.progress = v
+	h.j.update()
+}
+
+// AddResult adds a result of the job.
+func (h *Handle) AddResult(v interface{}) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	h.j.results = append(h.j.results, v)
+	if len(h.j.results) > maxResults {
+		h.j.results = h.j.results[1:]
+		h.j.dropped++
+	}
+	h.j.update()
+}
+
+// TempDir returns a new directory for the files of the job, e.g. its
+// uploaded inputs. The job removes it once done with it.
+func (h *Handle) TempDir() (string, error) {
+	dir, err := h.m.mkdir()
+	if err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, h.j.id+".")
+}
+
+// CreateOutput creates the output of the job, which is kept with the job.
+func (h *Handle) CreateOutput() (*os.File, error) {
+	dir, err := h.m.mkdir()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(dir, h.j.id+".*.out")
+	if err != nil {
+		return nil, err
+	}
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	if h.j.output != "" {
+		os.Remove(h.j.output)
+	}
+	h.j.output = f.Name()
+	h.j.update()
+	return f, nil
+}
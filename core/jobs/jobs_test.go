@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func wait(t *testing.T, m *Manager, id string) Status {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var seq uint64
+	for {
+		s, err := m.Next(ctx, id, seq)
+		require.NoError(t, err)
+		if s.State.Final() {
+			return s
+		}
+		seq = s.Seq
+	}
+}
+
+func TestJobs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := New(ctx)
+
+	id := m.Start("add", func(ctx context.Context, h *Handle) error {
+		h.SetProgress(1)
+		h.AddResult("a")
+		h.AddResult("b")
+		f, err := h.CreateOutput()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write([]byte("output"))
+		return err
+	})
+	s := wait(t, m, id)
+	require.Equal(t, Done, s.State)
+	require.Equal(t, "add", s.Command)
+	require.Equal(t, 1, s.Progress)
+	require.Equal(t, 2, s.Results)
+	require.True(t, s.Output)
+
+	results, err := m.Results(id)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"a", "b"}, results)
+	out, err := m.Output(id)
+	require.NoError(t, err)
+	data, err := io.ReadAll(out)
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+	require.Equal(t, "output", string(data))
+
+	failed := m.Start("gc", func(ctx context.Context, h *Handle) error {
+		return errors.New("boom")
+	})
+	s = wait(t, m, failed)
+	require.Equal(t, Failed, s.State)
+	require.Equal(t, "boom", s.Err)
+	_, err = m.Output(failed)
+	require.Error(t, err)
+
+	started := make(chan struct{})
+	cancelled := m.Start("pin", func(ctx context.Context, h *Handle) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+	require.NoError(t, m.Cancel(cancelled))
+	require.Equal(t, Cancelled, wait(t, m, cancelled).State)
+
+	list := m.List()
+	require.Len(t, list, 3)
+	require.Equal(t, []string{id, failed, cancelled}, []string{list[0].ID, list[1].ID, list[2].ID})
+
+	_, err = m.Status("unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestJobsDropResults(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := New(ctx)
+
+	id := m.Start("gc", func(ctx context.Context, h *Handle) error {
+		for i := 0; i < maxResults+10; i++ {
+			h.AddResult(i)
+		}
+		return nil
+	})
+	s := wait(t, m, id)
+	require.Equal(t, maxResults+10, s.Results)
+	require.Equal(t, 10, s.DroppedResults)
+	results, err := m.Results(id)
+	require.NoError(t, err)
+	require.Len(t, results, maxResults)
+	require.Equal(t, 10, results[0])
+}
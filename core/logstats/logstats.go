@@ -0,0 +1,210 @@
+// Package logstats counts the warnings and the errors logged by each
+// subsystem over rolling windows, so that operators can tell whether a node
+// is healthy, and which of its subsystems is failing, without grepping its
+// logs.
+package logstats
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The levels of the events counted. The events above the error level, such
+// as panics, count as errors.
+const (
+	Warn  = "warn"
+	Error = "error"
+)
+
+// Windows are the rolling windows the events are counted over.
+var Windows = []time.Duration{5 * time.Minute, time.Hour, 24 * time.Hour}
+
+// hourWindow is the index of the last hour in Windows.
+const hourWindow = 1
+
+// slots is the number of the one minute slots of the counters, covering the
+// largest of Windows.
+const slots = 24 * 60
+
+// maxMessageLength bounds the last messages kept by subsystem.
+const maxMessageLength = 512
+
+var eventsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "ipfs_log_events_total",
+		Help: "warnings and errors logged, by subsystem and level",
+	},
+	[]string{"subsystem", "level"},
+)
+
+// counter counts events by minute over the last slots minutes.
+type counter struct {
+	total   uint64
+	minutes [slots]int64
+	counts  [slots]uint64
+}
+
+func (c *counter) add(minute int64) {
+	c.total++
+	i := minute % slots
+	if c.minutes[i] != minute {
+		c.minutes[i] = minute
+		c.counts[i] = 0
+	}
+	c.counts[i]++
+}
+
+// since returns the events of the minutes after minute.
+func (c *counter) since(minute int64) uint64 {
+	var n uint64
+	for i, m := range c.minutes {
+		if m > minute {
+			n += c.counts[i]
+		}
+	}
+	return n
+}
+
+type subsystem struct {
+	warnings, errors counter
+	lastError        string
+	lastErrorTime    time.Time
+}
+
+// Counts are the events of a level over each of Windows, and since the
+// start of the node.
+type Counts struct {
+	Windows []uint64
+	Total   uint64
+}
+
+// SubsystemStats are the events logged by a subsystem.
+type SubsystemStats struct {
+	Subsystem     string
+	Warnings      Counts
+	Errors        Counts
+	LastError     string    `json:",omitempty"`
+	LastErrorTime time.Time `json:",omitempty"`
+}
+
+// Stats counts the events recorded with Observe.
+type Stats struct {
+	mu         sync.Mutex
+	subsystems map[string]*subsystem
+}
+
+// New returns empty Stats, and registers the Prometheus metrics of the
+// events.
+func New() (*Stats, error) {
+	if err := prometheus.Register(eventsTotal); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return nil, err
+	}
+	return &Stats{subsystems: make(map[string]*subsystem)}, nil
+}
+
+// Observe records an event of level logged by name at t. The levels other
+// than Warn count as Error.
+func (s *Stats) Observe(name, level, msg string, t time.Time) {
+	if level != Warn {
+		level = Error
+	}
+	eventsTotal.WithLabelValues(name, level).Inc()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subsystems[name]
+	if !ok {
+		sub = new(subsystem)
+		s.subsystems[name] = sub
+	}
+	minute := t.Unix() / 60
+	if level == Warn {
+		sub.warnings.add(minute)
+		return
+	}
+	sub.errors.add(minute)
+	if t.Before(sub.lastErrorTime) {
+		return
+	}
+	if len(msg) > maxMessageLength {
+		msg = msg[:maxMessageLength] + "…"
+	}
+	sub.lastError, sub.lastErrorTime = msg, t
+}
+
+// Snapshot returns the events of the subsystems which logged some, as of
+// now, sorted by decreasing errors over the last hour, then by name.
+func (s *Stats) Snapshot(now time.Time) []SubsystemStats {
+	minute := now.Unix() / 60
+	counts := func(c *counter) Counts {
+		out := Counts{Windows: make([]uint64, len(Windows)), Total: c.total}
+		for i, w := range Windows {
+			out.Windows[i] = c.since(minute - int64(w/time.Minute))
+		}
+		return out
+	}
+
+	s.mu.Lock()
+	out := make([]SubsystemStats, 0, len(s.subsystems))
+	for name, sub := range s.subsystems {
+		out = append(out, SubsystemStats{
+			Subsystem:     name,
+			Warnings:      counts(&sub.warnings),
+			Errors:        counts(&sub.errors),
+			LastError:     sub.lastError,
+			LastErrorTime: sub.lastErrorTime,
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		if a, b := out[i].Errors.Windows[hourWindow], out[j].Errors.Windows[hourWindow]; a != b {
+			return a > b
+		}
+		return out[i].Subsystem < out[j].Subsystem
+	})
+	return out
+}
+
+// logEntry is a line of the JSON output of go-log.
+type logEntry struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger"`
+	Msg    string `json:"msg"`
+}
+
+// Read records the events of r, the JSON lines of a go-log pipe, until it
+// is closed.
+func (s *Stats) Read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			var e logEntry
+			if json.Unmarshal(line, &e) == nil && e.Logger != "" {
+				s.Observe(e.Logger, e.Level, e.Msg, time.Now())
+			}
+		}
+		if err == io.EOF || errors.Is(err, io.ErrClosedPipe) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Pipe returns a pipe of the warnings and the errors of all the
+// subsystems, to pass to Read. Only the events of the levels enabled for
+// their subsystem are seen, see 'ipfs log level'.
+func Pipe() *logging.PipeReader {
+	return logging.NewPipeReader(logging.PipeFormat(logging.JSONOutput), logging.PipeLevel(logging.LevelWarn))
+}
@@ -0,0 +1,67 @@
+package logstats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	s.Observe("dht", Error, "old", now.Add(-2*time.Hour))
+	s.Observe("dht", Error, "recent", now.Add(-10*time.Minute))
+	s.Observe("dht", Warn, "slow", now)
+	s.Observe("bitswap", "dpanic", "panic", now)
+	s.Observe("bitswap", Error, "failed", now.Add(-30*time.Second))
+	s.Observe("bitswap", Error, "too old", now.Add(-25*time.Hour))
+
+	snap := s.Snapshot(now)
+	if len(snap) != 2 {
+		t.Fatalf("expected 2 subsystems, got %d", len(snap))
+	}
+	// sorted by errors over the last hour
+	bs, dht := snap[0], snap[1]
+	if bs.Subsystem != "bitswap" || dht.Subsystem != "dht" {
+		t.Fatalf("unexpected order: %s, %s", bs.Subsystem, dht.Subsystem)
+	}
+	check := func(name string, c Counts, windows []uint64, total uint64) {
+		t.Helper()
+		for i, n := range windows {
+			if c.Windows[i] != n {
+				t.Errorf("%s: expected %d events over %s, got %d", name, n, Windows[i], c.Windows[i])
+			}
+		}
+		if c.Total != total {
+			t.Errorf("%s: expected %d events, got %d", name, total, c.Total)
+		}
+	}
+	check("bitswap errors", bs.Errors, []uint64{2, 2, 2}, 3)
+	check("dht errors", dht.Errors, []uint64{0, 1, 2}, 2)
+	check("dht warnings", dht.Warnings, []uint64{1, 1, 1}, 1)
+	if dht.LastError != "recent" {
+		t.Errorf("unexpected last error %q", dht.LastError)
+	}
+}
+
+func TestRead(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := strings.NewReader(`{"level":"error","ts":"2026-10-16T12:00:00.000Z","logger":"core","msg":"failed"}
+not json
+{"level":"warn","ts":"2026-10-16T12:00:00.000Z","logger":"core","msg":"slow"}
+`)
+	if err := s.Read(r); err != nil {
+		t.Fatal(err)
+	}
+	snap := s.Snapshot(time.Now())
+	if len(snap) != 1 || snap[0].Errors.Total != 1 || snap[0].Warnings.Total != 1 || snap[0].LastError != "failed" {
+		t.Fatalf("unexpected stats: %+v", snap)
+	}
+}
@@ -0,0 +1,83 @@
+// Package mfsflush flushes the MFS root in the background, batching the
+// updates of the root of the writes made without flushing, such as
+// 'ipfs files write --flush=false'.
+package mfsflush
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	mfs "github.com/ipfs/go-mfs"
+)
+
+var log = logging.Logger("mfsflush")
+
+// Flusher flushes the MFS root every interval, when it was changed without
+// flushing since the last flush.
+type Flusher struct {
+	root     *mfs.Root
+	interval time.Duration
+
+	dirty int32
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Flusher of root, which flushes it every interval once
+// started.
+func New(root *mfs.Root, interval time.Duration) *Flusher {
+	return &Flusher{
+		root:     root,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// MarkDirty records a change of MFS made without flushing, flushed within
+// an interval.
+func (f *Flusher) MarkDirty() {
+	atomic.StoreInt32(&f.dirty, 1)
+}
+
+// Start flushes the root in the background until Stop is called.
+func (f *Flusher) Start() {
+	go func() {
+		defer close(f.done)
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.stop:
+				return
+			case <-ticker.C:
+				if err := f.Flush(context.Background()); err != nil {
+					log.Errorf("failed to flush MFS: %s", err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops flushing the root, and flushes the last changes.
+func (f *Flusher) Stop(ctx context.Context) error {
+	close(f.stop)
+	<-f.done
+	return f.Flush(ctx)
+}
+
+// Flush flushes the root if it was changed since the last flush.
+func (f *Flusher) Flush(ctx context.Context) error {
+	if atomic.SwapInt32(&f.dirty, 0) == 0 {
+		return nil
+	}
+	_, err := mfs.FlushPath(ctx, f.root, "/")
+	if err != nil {
+		// try again on the next tick
+		f.MarkDirty()
+	}
+	return err
+}
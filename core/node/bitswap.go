@@ -9,6 +9,8 @@ import (
 	"github.com/ipfs/go-libipfs/bitswap"
 	"github.com/ipfs/go-libipfs/bitswap/network"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/bsbroadcast"
+	"github.com/ipfs/kubo/core/haveprobe"
 	irouting "github.com/ipfs/kubo/routing"
 	"github.com/libp2p/go-libp2p/core/host"
 	"go.uber.org/fx"
@@ -23,6 +25,8 @@ const (
 	DefaultEngineTaskWorkerCount       = 8
 	DefaultMaxOutstandingBytesPerPeer  = 1 << 20
 	DefaultProviderSearchDelay         = 1000 * time.Millisecond
+	DefaultBroadcastFanOut             = 0
+	DefaultUnresponsivePeerThreshold   = 0
 )
 
 type bitswapOptionsOut struct {
@@ -63,12 +67,27 @@ type onlineExchangeIn struct {
 	BitswapOpts []bitswap.Option `group:"bitswap-options"`
 }
 
+type onlineExchangeOut struct {
+	fx.Out
+
+	Exchange  exchange.Interface
+	Broadcast *bsbroadcast.Network
+}
+
 // OnlineExchange creates new LibP2P backed block exchange (BitSwap).
 // Additional options to bitswap.New can be provided via the "bitswap-options"
 // group.
-func OnlineExchange() interface{} {
-	return func(in onlineExchangeIn, lc fx.Lifecycle) exchange.Interface {
-		bitswapNetwork := network.NewFromIpfsHost(in.Host, in.Rt)
+func OnlineExchange(cfg *config.Config) interface{} {
+	return func(in onlineExchangeIn, lc fx.Lifecycle) onlineExchangeOut {
+		var internalBsCfg config.InternalBitswap
+		if cfg.Internal.Bitswap != nil {
+			internalBsCfg = *cfg.Internal.Bitswap
+		}
+		bitswapNetwork := bsbroadcast.Wrap(
+			network.NewFromIpfsHost(in.Host, in.Rt),
+			int(internalBsCfg.BroadcastFanOut.WithDefault(DefaultBroadcastFanOut)),
+			uint64(internalBsCfg.UnresponsivePeerThreshold.WithDefault(DefaultUnresponsivePeerThreshold)),
+		)
 
 		exch := bitswap.New(helpers.LifecycleCtx(in.Mctx, lc), bitswapNetwork, in.Bs, in.BitswapOpts...)
 		lc.Append(fx.Hook{
@@ -76,6 +95,23 @@ func OnlineExchange() interface{} {
 				return exch.Close()
 			},
 		})
-		return exch
+		return onlineExchangeOut{Exchange: exch, Broadcast: bitswapNetwork}
+	}
+}
+
+type haveProberOut struct {
+	fx.Out
+
+	Prober      *haveprobe.Prober
+	BitswapOpts []bitswap.Option `group:"bitswap-options,flatten"`
+}
+
+// HaveProber creates the prober asking peers whether they have blocks. It
+// is installed as the tracer of bitswap, which receives the answers.
+func HaveProber(h host.Host) haveProberOut {
+	p := haveprobe.New(h)
+	return haveProberOut{
+		Prober:      p,
+		BitswapOpts: []bitswap.Option{bitswap.WithTracer(p)},
 	}
 }
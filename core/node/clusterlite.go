@@ -0,0 +1,43 @@
+package node
+
+import (
+	"context"
+
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/clusterlite"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/repo"
+)
+
+// ClusterLite creates the cluster-lite service sharing a pinset with the
+// configured trusted peers
+func ClusterLite(cfg config.ClusterLite) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, id peer.ID, ps *pubsub.PubSub, repo repo.Repo, pinner pin.Pinner, dag ipld.DAGService, locker bstore.GCLocker) (*clusterlite.Service, error) {
+		s, err := clusterlite.New(helpers.LifecycleCtx(mctx, lc), id, ps, repo.Datastore(), pinner, dag, locker, clusterlite.Options{
+			Name:                cfg.Name.WithDefault(config.DefaultClusterLiteName),
+			Peers:               cfg.Peers,
+			ReplicationFactor:   int(cfg.ReplicationFactor.WithDefault(config.DefaultClusterLiteReplicationFactor)),
+			RebroadcastInterval: cfg.RebroadcastInterval.WithDefault(config.DefaultClusterLiteRebroadcastInterval),
+			PinTimeout:          cfg.PinTimeout.WithDefault(config.DefaultClusterLitePinTimeout),
+		})
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				return s.Start()
+			},
+			OnStop: func(context.Context) error {
+				return s.Stop()
+			},
+		})
+		return s, nil
+	}
+}
@@ -2,8 +2,11 @@ package node
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
@@ -12,6 +15,7 @@ import (
 	"github.com/ipfs/go-filestore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
 	pin "github.com/ipfs/go-ipfs-pinner"
 	"github.com/ipfs/go-ipfs-pinner/dspinner"
 	format "github.com/ipfs/go-ipld-format"
@@ -25,7 +29,17 @@ import (
 	"github.com/ipld/go-ipld-prime/schema"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/jobs"
+	"github.com/ipfs/kubo/core/mfsflush"
 	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/pinlabels"
+	"github.com/ipfs/kubo/core/popularity"
+	"github.com/ipfs/kubo/core/prefetch"
+	"github.com/ipfs/kubo/core/quota"
+	"github.com/ipfs/kubo/core/scrub"
+	"github.com/ipfs/kubo/core/spaces"
 	"github.com/ipfs/kubo/repo"
 )
 
@@ -108,6 +122,23 @@ func Dag(bs blockservice.BlockService) format.DAGService {
 	return merkledag.NewDAGService(bs)
 }
 
+// Prefetcher runs the background jobs warming the blockstore with DAGs
+func Prefetcher(mctx helpers.MetricsCtx, lc fx.Lifecycle) *prefetch.Manager {
+	return prefetch.New(helpers.LifecycleCtx(mctx, lc), prefetch.DefaultMaxActive)
+}
+
+// Jobs runs the background RPC operations
+func Jobs(mctx helpers.MetricsCtx, lc fx.Lifecycle) *jobs.Manager {
+	return jobs.New(helpers.LifecycleCtx(mctx, lc))
+}
+
+// GatewayPopularity counts the most requested gateway paths
+func GatewayPopularity(topN int) interface{} {
+	return func() *popularity.Tracker {
+		return popularity.New(topN)
+	}
+}
+
 // Files loads persisted MFS root
 func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.DAGService) (*mfs.Root, error) {
 	dsk := datastore.NewKey("/local/filesroot")
@@ -168,3 +199,177 @@ func Files(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, dag format.
 
 	return root, err
 }
+
+// MFSFlusher flushes the MFS root every interval, when it was changed without
+// flushing
+func MFSFlusher(interval time.Duration) interface{} {
+	return func(lc fx.Lifecycle, root *mfs.Root) *mfsflush.Flusher {
+		f := mfsflush.New(root, interval)
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				f.Start()
+				return nil
+			},
+			OnStop: f.Stop,
+		})
+		return f
+	}
+}
+
+// PinLabels stores the labels of the pinned roots
+func PinLabels(repo repo.Repo) *pinlabels.Store {
+	return pinlabels.NewStore(repo.Datastore())
+}
+
+// PrivateScope tells the content of the roots with one of the private labels,
+// only provided on the LAN DHT. It walks their DAGs in the blockstore only.
+func PrivateScope(labels []string) interface{} {
+	return func(lc fx.Lifecycle, bs blockstore.Blockstore, store *pinlabels.Store) *pinlabels.Scope {
+		local := merkledag.NewDAGService(blockservice.New(bs, offline.Exchange(bs)))
+		s := pinlabels.NewScope(store, local, labels)
+		lc.Append(fx.Hook{
+			OnStart: s.Start,
+			OnStop:  s.Stop,
+		})
+		return s
+	}
+}
+
+// Spaces stores the named spaces of the node, each with its own pinset, MFS
+// root and quota
+func Spaces(lc fx.Lifecycle, repo repo.Repo, dag format.DAGService, bs blockstore.Blockstore, locker blockstore.GCLocker) *spaces.Manager {
+	m := spaces.New(repo.Datastore(), dag, bs, locker)
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			return m.Close()
+		},
+	})
+	return m
+}
+
+// Quotas accounts the repo blocks of pins, MFS and the cache, and enforces
+// their quotas. The pins and the MFS roots of the spaces count as pins and
+// MFS.
+func Quotas(cfg config.DatastoreQuotas) interface{} {
+	return func(bs BaseBlocks, pinning pin.Pinner, files *mfs.Root, sm *spaces.Manager) (*quota.Accountant, error) {
+		a, err := quota.New(cfg, bs, sm.Pinner(pinning), files)
+		if err != nil {
+			return nil, err
+		}
+		a.ProtectFiles(sm.FilesRoots)
+		return a, nil
+	}
+}
+
+// CacheEviction periodically evicts the least recently used blocks of the
+// cache to keep it under its quota
+func CacheEviction(cfg config.Datastore) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, accountant *quota.Accountant, locker blockstore.GCLocker, access *quota.AccessTimes) error {
+		policy := cfg.CacheEviction.Policy.WithDefault(config.DefaultCacheEvictionPolicy)
+		if policy != config.CacheEvictionLRU {
+			return fmt.Errorf("unknown Datastore.CacheEviction.Policy %q, expected %q or %q", policy, config.CacheEvictionNone, config.CacheEvictionLRU)
+		}
+		if accountant.Quota(quota.Cache) == quota.NoLimit {
+			return errors.New("cache eviction requires Datastore.Quotas.Cache to be set")
+		}
+		interval := cfg.CacheEviction.Interval.WithDefault(config.DefaultCacheEvictionInterval)
+
+		ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+		done := make(chan struct{})
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					defer close(done)
+					ticker := time.NewTicker(interval)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-ticker.C:
+						}
+						res, err := accountant.Evict(ctx, locker, access)
+						if err != nil {
+							if ctx.Err() == nil {
+								logger.Errorf("cache eviction failed: %s", err)
+							}
+							continue
+						}
+						if res.Removed > 0 {
+							logger.Infof("evicted %d blocks (%d bytes) from a %d bytes cache", res.Removed, res.Freed, res.CacheSize)
+						}
+					}
+				}()
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				cancel()
+				<-done
+				return nil
+			},
+		})
+		return nil
+	}
+}
+
+// scrubStartDelay is the delay before the first scrub, so that it doesn't
+// slow the start of the daemon down.
+const scrubStartDelay = time.Minute
+
+// Scrub periodically verifies the blocks of the repo and records the health
+// of its shards
+func Scrub(cfg config.DatastoreScrub) interface{} {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, bus *events.Bus) error {
+		interval := cfg.Interval.WithDefault(config.DefaultScrubInterval)
+		if interval <= 0 {
+			return fmt.Errorf("invalid Datastore.Scrub.Interval %s", interval)
+		}
+		rate, err := humanize.ParseBytes(cfg.Rate.WithDefault(config.DefaultScrubRate))
+		if err != nil {
+			return fmt.Errorf("invalid Datastore.Scrub.Rate: %w", err)
+		}
+		scrubber := scrub.New(repo.Datastore(), bus, interval, rate)
+
+		ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+		done := make(chan struct{})
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					defer close(done)
+					timer := time.NewTimer(scrubStartDelay)
+					defer timer.Stop()
+					for {
+						select {
+						case <-ctx.Done():
+							return
+						case <-timer.C:
+						}
+						next := scrubStartDelay
+						res, err := scrubber.Scrub(ctx)
+						if err != nil {
+							if ctx.Err() == nil {
+								logger.Errorf("scrub failed: %s", err)
+							}
+						} else {
+							if res.Blocks > 0 {
+								logger.Infof("scrubbed %d blocks (%d bytes), %d corrupted", res.Blocks, res.Size, res.Corrupted)
+							}
+							next = time.Until(res.Next)
+							if next < scrubStartDelay {
+								next = scrubStartDelay
+							}
+						}
+						timer.Reset(next)
+					}
+				}()
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				cancel()
+				<-done
+				return nil
+			},
+		})
+		return nil
+	}
+}
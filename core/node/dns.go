@@ -3,6 +3,7 @@ package node
 import (
 	"fmt"
 	"math"
+	"net"
 	"strings"
 	"time"
 
@@ -23,13 +24,21 @@ func newResolver(url string, opts ...doh.Option) (madns.BasicResolver, error) {
 		return nil, fmt.Errorf("invalid resolver url: %s", url)
 	}
 
-	return doh.NewResolver(url, opts...)
+	rslv, err := doh.NewResolver(url, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedResolver{name: resolverLabel(url), BasicResolver: rslv}, nil
 }
 
 func DNSResolver(cfg *config.Config) (*madns.Resolver, error) {
 	var opts []madns.Option
 	var err error
 
+	if err := registerDNSMetrics(); err != nil {
+		return nil, err
+	}
+
 	var dohOpts []doh.Option
 	if !cfg.DNS.MaxCacheTTL.IsDefault() {
 		dohOpts = append(dohOpts, doh.WithMaxCacheTTL(cfg.DNS.MaxCacheTTL.WithDefault(time.Duration(math.MaxUint32)*time.Second)))
@@ -74,7 +83,7 @@ func DNSResolver(cfg *config.Config) (*madns.Resolver, error) {
 
 		rslv, ok := rslvrs[url]
 		if !ok {
-			rslv, err = newResolver(url)
+			rslv, err = newResolver(url, dohOpts...)
 			if err != nil {
 				return nil, fmt.Errorf("bad resolver for %s: %w", domain, err)
 			}
@@ -84,5 +93,10 @@ func DNSResolver(cfg *config.Config) (*madns.Resolver, error) {
 		opts = append(opts, madns.WithDomainResolver(domain, rslv))
 	}
 
+	// the operating system resolves the domains without a DoH resolver
+	if cfg.DNS.Resolvers["."] == "" {
+		opts = append(opts, madns.WithDefaultResolver(&instrumentedResolver{name: "system", BasicResolver: net.DefaultResolver}))
+	}
+
 	return madns.NewResolver(opts...)
 }
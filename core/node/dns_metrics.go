@@ -0,0 +1,81 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"time"
+
+	madns "github.com/multiformats/go-multiaddr-dns"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	dnsLookupDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "ipfs_dns_lookup_duration_seconds",
+			Help:    "duration of DNS lookups, by resolver and record type",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		},
+		[]string{"resolver", "type"},
+	)
+	dnsLookupFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "ipfs_dns_lookup_failures_total",
+			Help: "failed DNS lookups, by resolver and record type, not counting names not found",
+		},
+		[]string{"resolver", "type"},
+	)
+)
+
+func registerDNSMetrics() error {
+	for _, c := range []prometheus.Collector{dnsLookupDuration, dnsLookupFailures} {
+		if err := prometheus.Register(c); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolverLabel returns the label of the metrics of the DoH resolver at
+// rawURL: its host only, since the path, query or userinfo of the URL may
+// carry an access token.
+func resolverLabel(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "invalid"
+	}
+	return u.Host
+}
+
+// instrumentedResolver records the latency and the failures of the lookups
+// of a resolver, labeled with its name: the host of its URL, or "system" for
+// the resolver of the operating system.
+type instrumentedResolver struct {
+	name string
+	madns.BasicResolver
+}
+
+func (r *instrumentedResolver) LookupIPAddr(ctx context.Context, domain string) ([]net.IPAddr, error) {
+	begin := time.Now()
+	addrs, err := r.BasicResolver.LookupIPAddr(ctx, domain)
+	r.observe("ip", begin, err)
+	return addrs, err
+}
+
+func (r *instrumentedResolver) LookupTXT(ctx context.Context, txt string) ([]string, error) {
+	begin := time.Now()
+	records, err := r.BasicResolver.LookupTXT(ctx, txt)
+	r.observe("txt", begin, err)
+	return records, err
+}
+
+func (r *instrumentedResolver) observe(typ string, begin time.Time, err error) {
+	dnsLookupDuration.WithLabelValues(r.name, typ).Observe(time.Since(begin).Seconds())
+	var dnsErr *net.DNSError
+	if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		dnsLookupFailures.WithLabelValues(r.name, typ).Inc()
+	}
+}
@@ -0,0 +1,101 @@
+package node
+
+import (
+	"context"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-provider/simple"
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/webhooks"
+)
+
+// PeerEvents forwards libp2p connectedness changes to the event bus
+func PeerEvents(lc fx.Lifecycle, h host.Host, bus *events.Bus) error {
+	sub, err := h.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
+	if err != nil {
+		return err
+	}
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			go func() {
+				for e := range sub.Out() {
+					evt := e.(event.EvtPeerConnectednessChanged)
+					data := map[string]interface{}{"Peer": evt.Peer.String()}
+					switch evt.Connectedness {
+					case network.Connected:
+						bus.Emit(events.PeerConnected, data)
+					case network.NotConnected:
+						bus.Emit(events.PeerDisconnected, data)
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return sub.Close()
+		},
+	})
+	return nil
+}
+
+// reprovideEvents wraps the reprovider key provider so that each reprovide
+// cycle is reported on the event bus
+func reprovideEvents(bus *events.Bus, keyProvider simple.KeyChanFunc) simple.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		start := time.Now()
+		bus.Emit(events.ReprovideStarted, nil)
+
+		in, err := keyProvider(ctx)
+		if err != nil {
+			bus.Emit(events.ReprovideFinished, map[string]interface{}{"Error": err.Error()})
+			return nil, err
+		}
+
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			var count int
+			defer func() {
+				bus.Emit(events.ReprovideFinished, map[string]interface{}{
+					"Keys":     count,
+					"Duration": time.Since(start).String(),
+				})
+			}()
+			for c := range in {
+				select {
+				case out <- c:
+					count++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// Webhooks delivers events from the bus to the configured webhook endpoints
+func Webhooks(cfg config.Webhooks) interface{} {
+	return func(lc fx.Lifecycle, bus *events.Bus) error {
+		d, err := webhooks.New(bus, cfg)
+		if err != nil {
+			return err
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(ctx context.Context) error {
+				d.Start()
+				return nil
+			},
+			OnStop: d.Close,
+		})
+		return nil
+	}
+}
@@ -10,6 +10,8 @@ import (
 	util "github.com/ipfs/go-ipfs-util"
 	"github.com/ipfs/go-log"
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/quota"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"github.com/libp2p/go-libp2p-pubsub/timecache"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -156,7 +158,8 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		fx.Provide(libp2p.SmuxTransport(cfg.Swarm.Transports)),
 		fx.Provide(libp2p.RelayTransport(enableRelayTransport)),
 		fx.Provide(libp2p.RelayService(enableRelayService, cfg.Swarm.RelayService)),
-		fx.Provide(libp2p.Transports(cfg.Swarm.Transports)),
+		fx.Provide(libp2p.DialStats),
+		fx.Provide(libp2p.Transports(cfg.Swarm.Transports, cfg.Swarm.Dialing)),
 		fx.Invoke(libp2p.StartListening(cfg.Addresses.Swarm)),
 		fx.Invoke(libp2p.SetupDiscovery(cfg.Discovery.MDNS.Enabled)),
 		fx.Provide(libp2p.ForceReachability(cfg.Internal.Libp2pForceReachability)),
@@ -166,12 +169,18 @@ func LibP2P(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
+		maybeProvide(libp2p.HTTPAnnouncer(cfg.Identity, cfg.Addresses.Swarm, cfg.Routing.Announce), len(cfg.Routing.Announce.Endpoints) > 0),
+		maybeProvide(libp2p.IPNIPublisher(cfg.Routing.IPNI), cfg.Routing.IPNI.Enabled.WithDefault(false)),
 
 		fx.Provide(libp2p.BaseRouting(cfg.Experimental.AcceleratedDHTClient)),
 		maybeProvide(libp2p.PubsubRouter, bcfg.getOpt("ipnsps")),
 
 		maybeProvide(libp2p.BandwidthCounter, !cfg.Swarm.DisableBandwidthMetrics),
+		maybeProvide(libp2p.BandwidthHistory(cfg.Swarm.BandwidthHistory), !cfg.Swarm.DisableBandwidthMetrics && cfg.Swarm.BandwidthHistory.Retention.WithDefault(config.DefaultBandwidthHistoryRetention) > 0),
 		maybeProvide(libp2p.NatPortMap, !cfg.Swarm.DisableNatPortMap),
+		maybeProvide(libp2p.AutoNATV2Results, cfg.AutoNAT.V2.WithDefault(false)),
+		maybeProvide(libp2p.AutoNATV2(cfg.AutoNAT.ServiceMode != config.AutoNATServiceDisabled), cfg.AutoNAT.V2.WithDefault(false)),
+		maybeInvoke(libp2p.PersistentPeerstore(cfg.Swarm.PersistentPeerstore), cfg.Swarm.PersistentPeerstore.Enabled.WithDefault(false)),
 		libp2p.MaybeAutoRelay(cfg.Swarm.RelayClient.StaticRelays, cfg.Peering, enableRelayClient),
 		autonat,
 		connmgr,
@@ -189,16 +198,28 @@ func Storage(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 	if !bcfg.Permanent {
 		cacheOpts.HasBloomFilterSize = 0
 	}
+	if cfg.Internal.MemoryBudget != nil {
+		// the memory goes to the blocks in flight rather than to caches
+		cacheOpts.HasARCCacheSize = 0
+		cacheOpts.HasBloomFilterSize = 0
+	}
 
 	finalBstore := fx.Provide(GcBlockstoreCtor)
 	if cfg.Experimental.FilestoreEnabled || cfg.Experimental.UrlstoreEnabled {
 		finalBstore = fx.Provide(FilestoreBlockstoreCtor)
 	}
 
+	// access times are only needed to evict the least recently used blocks
+	var access *quota.AccessTimes
+	if cfg.Datastore.CacheEviction.Policy.WithDefault(config.DefaultCacheEvictionPolicy) == config.CacheEvictionLRU {
+		access = quota.NewAccessTimes()
+	}
+
 	return fx.Options(
 		fx.Provide(RepoConfig),
 		fx.Provide(Datastore),
-		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead)),
+		fx.Provide(func() *quota.AccessTimes { return access }),
+		fx.Provide(BaseBlockstoreCtor(cacheOpts, bcfg.NilRepo, cfg.Datastore.HashOnRead, access)),
 		finalBstore,
 	)
 }
@@ -290,9 +311,18 @@ func Online(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 	/* don't provide from bitswap when the strategic provider service is active */
 	shouldBitswapProvide := !cfg.Experimental.StrategicProviding
 
+	enableClusterLite := cfg.ClusterLite.Enabled.WithDefault(false)
+	if enableClusterLite && !bcfg.getOpt("pubsub") {
+		return fx.Error(errors.New("ClusterLite requires pubsub: set Pubsub.Enabled or run the daemon with --enable-pubsub-experiment"))
+	}
+	if enableClusterLite && cfg.Pubsub.DisableSigning {
+		return fx.Error(errors.New("ClusterLite requires signed pubsub messages, Pubsub.DisableSigning must be false"))
+	}
+
 	return fx.Options(
 		fx.Provide(BitswapOptions(cfg, shouldBitswapProvide)),
-		fx.Provide(OnlineExchange()),
+		fx.Provide(OnlineExchange(cfg)),
+		fx.Provide(HaveProber),
 		maybeProvide(Graphsync, cfg.Experimental.GraphsyncEnabled),
 		fx.Provide(DNSResolver),
 		fx.Provide(Namesys(ipnsCacheSize)),
@@ -302,6 +332,8 @@ func Online(bcfg *BuildCfg, cfg *config.Config) fx.Option {
 		fx.Invoke(IpnsRepublisher(repubPeriod, recordLifetime)),
 
 		fx.Provide(p2p.New),
+		fx.Invoke(PeerEvents),
+		maybeProvide(ClusterLite(cfg.ClusterLite), enableClusterLite),
 
 		LibP2P(bcfg, cfg),
 		OnlineProviders(
@@ -322,6 +354,7 @@ func Offline(cfg *config.Config) fx.Option {
 		fx.Provide(libp2p.Routing),
 		fx.Provide(libp2p.ContentRouting),
 		fx.Provide(libp2p.OfflineRouting),
+		maybeProvide(libp2p.OfflineDelegatedRouting(cfg), cfg.Offline.DelegatedRouting.WithDefault(false)),
 		OfflineProviders(
 			cfg.Experimental.StrategicProviding,
 			cfg.Experimental.AcceleratedDHTClient,
@@ -338,6 +371,12 @@ var Core = fx.Options(
 	fx.Provide(FetcherConfig),
 	fx.Provide(Pinning),
 	fx.Provide(Files),
+	fx.Provide(events.NewBus),
+	fx.Provide(Prefetcher),
+	fx.Provide(PinQueue),
+	fx.Provide(Jobs),
+	fx.Provide(PinLabels),
+	fx.Provide(Spaces),
 )
 
 func Networked(bcfg *BuildCfg, cfg *config.Config) fx.Option {
@@ -373,6 +412,18 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 			"If you need to restore the old behavior (sharding everything) set `Internal.UnixFSShardingSizeThreshold` to `1B`.\n")
 	}
 
+	topN := cfg.Gateway.PopularityTopN.WithDefault(config.DefaultPopularityTopN)
+
+	var memoryBudget uint64
+	if budget := cfg.Internal.MemoryBudget.WithDefault(""); budget != "" {
+		memoryBudget, err = humanize.ParseBytes(budget)
+		if err != nil {
+			return fx.Error(fmt.Errorf("invalid Internal.MemoryBudget: %w", err))
+		}
+	}
+
+	mfsFlushInterval := cfg.Internal.MFSFlushInterval.WithDefault(0)
+
 	return fx.Options(
 		bcfgOpts,
 
@@ -384,5 +435,15 @@ func IPFS(ctx context.Context, bcfg *BuildCfg) fx.Option {
 		Networked(bcfg, cfg),
 
 		Core,
+		fx.Provide(Quotas(cfg.Datastore.Quotas)),
+		maybeProvide(GatewayPopularity(int(topN)), topN > 0),
+		maybeInvoke(CacheEviction(cfg.Datastore), cfg.Datastore.CacheEviction.Policy.WithDefault(config.DefaultCacheEvictionPolicy) != config.CacheEvictionNone),
+		maybeInvoke(Scrub(cfg.Datastore.Scrub), cfg.Datastore.Scrub.Enabled.WithDefault(false)),
+		maybeInvoke(RepoGrowth, bcfg.Permanent),
+		maybeProvide(LogStats, bcfg.Permanent),
+		maybeInvoke(Webhooks(cfg.Webhooks), len(cfg.Webhooks.Endpoints) > 0),
+		maybeInvoke(MemoryBudget(memoryBudget), memoryBudget > 0),
+		maybeProvide(MFSFlusher(mfsFlushInterval), mfsFlushInterval > 0),
+		maybeProvide(PrivateScope(cfg.Routing.PrivateLabels), len(cfg.Routing.PrivateLabels) > 0),
 	)
 }
@@ -0,0 +1,126 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/quota"
+	"github.com/ipfs/kubo/repo"
+)
+
+// repoGrowthInterval is the period of the checks for a missing sample of the
+// day.
+const repoGrowthInterval = time.Hour
+
+var (
+	repoGrowthMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ipfs_repo_growth_bytes_per_day",
+			Help: "daily growth of the repo and of its pins, MFS and cache, over the last 30 days",
+		},
+		[]string{"namespace"},
+	)
+	repoDaysUntilFullMetric = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "ipfs_repo_days_until_full",
+			Help: "days before the repo reaches Datastore.StorageMax at its current growth, -1 if it isn't growing or has no StorageMax",
+		},
+	)
+)
+
+// RepoGrowth records the size of the repo and of its namespaces once a day,
+// and exports the forecast of its growth as metrics
+func RepoGrowth(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, accountant *quota.Accountant) error {
+	for _, c := range []prometheus.Collector{repoGrowthMetric, repoDaysUntilFullMetric} {
+		if err := prometheus.Register(c); err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(helpers.LifecycleCtx(mctx, lc))
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(repoGrowthInterval)
+				defer ticker.Stop()
+				for {
+					if err := sampleRepoGrowth(ctx, repo, accountant); err != nil && ctx.Err() == nil {
+						logger.Errorf("recording the repo size failed: %s", err)
+					}
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			cancel()
+			<-done
+			return nil
+		},
+	})
+	return nil
+}
+
+// sampleRepoGrowth records the sample of the day if it is missing, and
+// updates the metrics.
+func sampleRepoGrowth(ctx context.Context, r repo.Repo, accountant *quota.Accountant) error {
+	d := r.Datastore()
+	samples, err := quota.Samples(ctx, d)
+	if err != nil {
+		return err
+	}
+	repoSize, err := r.GetStorageUsage(ctx)
+	if err != nil {
+		return err
+	}
+
+	if today := quota.Day(time.Now()); len(samples) == 0 || samples[len(samples)-1].Day != today {
+		report, err := accountant.Report(ctx)
+		if err != nil {
+			return err
+		}
+		s := quota.Sample{
+			Day:      today,
+			RepoSize: repoSize,
+			Pins:     report.Pins.Size,
+			MFS:      report.MFS.Size,
+			Cache:    report.Cache.Size,
+		}
+		if err := quota.RecordSample(ctx, d, s); err != nil {
+			return err
+		}
+		samples = append(samples, s)
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+	storageMax := quota.NoLimit
+	if cfg.Datastore.StorageMax != "" {
+		storageMax, err = humanize.ParseBytes(cfg.Datastore.StorageMax)
+		if err != nil {
+			return err
+		}
+	}
+
+	f := quota.NewForecast(samples, repoSize, storageMax)
+	repoGrowthMetric.WithLabelValues("repo").Set(float64(f.RepoGrowth))
+	repoGrowthMetric.WithLabelValues(string(quota.Pins)).Set(float64(f.PinsGrowth))
+	repoGrowthMetric.WithLabelValues(string(quota.MFS)).Set(float64(f.MFSGrowth))
+	repoGrowthMetric.WithLabelValues(string(quota.Cache)).Set(float64(f.CacheGrowth))
+	repoDaysUntilFullMetric.Set(float64(f.DaysUntilFull))
+	return nil
+}
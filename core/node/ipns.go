@@ -1,13 +1,18 @@
 package node
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
+	ds "github.com/ipfs/go-datastore"
+	keystore "github.com/ipfs/go-ipfs-keystore"
 	util "github.com/ipfs/go-ipfs-util"
 	"github.com/ipfs/go-ipns"
 	record "github.com/libp2p/go-libp2p-record"
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	madns "github.com/multiformats/go-multiaddr-dns"
 
@@ -39,14 +44,19 @@ func Namesys(cacheSize int) func(rt irouting.ProvideManyRouter, rslv *madns.Reso
 			opts = append(opts, namesys.WithCache(cacheSize))
 		}
 
-		return namesys.NewNameSystem(rt, opts...)
+		ns, err := namesys.NewNameSystem(rt, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return WithNameResolvers(ns), nil
 	}
 }
 
 // IpnsRepublisher runs new IPNS republisher service
 func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) func(lcProcess, namesys.NameSystem, repo.Repo, crypto.PrivKey) error {
 	return func(lc lcProcess, namesys namesys.NameSystem, repo repo.Repo, privKey crypto.PrivKey) error {
-		repub := republisher.NewRepublisher(namesys, repo.Datastore(), privKey, repo.Keystore())
+		ks := &retiringKeystore{Keystore: repo.Keystore(), ds: repo.Datastore()}
+		repub := republisher.NewRepublisher(namesys, repo.Datastore(), privKey, ks)
 
 		if repubPeriod != 0 {
 			if !util.Debug && (repubPeriod < time.Minute || repubPeriod > (time.Hour*24)) {
@@ -64,3 +74,61 @@ func IpnsRepublisher(repubPeriod time.Duration, recordLifetime time.Duration) fu
 		return nil
 	}
 }
+
+// retiredKeysPrefix is the prefix of the keys retired by 'ipfs key rotate', by
+// key name, in the datastore of the repo.
+var retiredKeysPrefix = ds.NewKey("/ipns-retired")
+
+type retiredKey struct {
+	ID    peer.ID
+	Until time.Time
+}
+
+// RetireKey stops the republishing of the records of a key after the given
+// time, e.g. the forwarding record of a rotated key. A new key given the same
+// name isn't retired.
+func RetireKey(ctx context.Context, d ds.Datastore, name string, id peer.ID, until time.Time) error {
+	b, err := json.Marshal(&retiredKey{ID: id, Until: until})
+	if err != nil {
+		return err
+	}
+	return d.Put(ctx, retiredKeysPrefix.ChildString(name), b)
+}
+
+// retiringKeystore hides the keys retired and past their grace period from
+// the republisher, so that their records expire.
+type retiringKeystore struct {
+	keystore.Keystore
+	ds ds.Datastore
+}
+
+func (ks *retiringKeystore) List() ([]string, error) {
+	names, err := ks.Keystore.List()
+	if err != nil {
+		return nil, err
+	}
+	out := names[:0]
+	for _, name := range names {
+		if !ks.retired(name) {
+			out = append(out, name)
+		}
+	}
+	return out, nil
+}
+
+func (ks *retiringKeystore) retired(name string) bool {
+	b, err := ks.ds.Get(context.Background(), retiredKeysPrefix.ChildString(name))
+	if err != nil {
+		return false
+	}
+	var r retiredKey
+	if err := json.Unmarshal(b, &r); err != nil || time.Now().Before(r.Until) {
+		return false
+	}
+	sk, err := ks.Keystore.Get(name)
+	if err != nil {
+		return false
+	}
+	id, err := peer.IDFromPrivateKey(sk)
+	return err == nil && id == r.ID
+}
@@ -3,11 +3,13 @@ package libp2p
 import (
 	"fmt"
 
+	"github.com/ipfs/kubo/core/autonatv2"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
 	p2pbhost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	ma "github.com/multiformats/go-multiaddr"
 	mamask "github.com/whyrusleeping/multiaddr-filter"
+	"go.uber.org/fx"
 )
 
 func AddrFilters(filters []string) func() (*ma.Filters, Libp2pOpts, error) {
@@ -88,12 +90,22 @@ func makeAddrsFactory(announce []string, appendAnnouce []string, noAnnounce []st
 	}, nil
 }
 
-func AddrsFactory(announce []string, appendAnnouce []string, noAnnounce []string) func() (opts Libp2pOpts, err error) {
-	return func() (opts Libp2pOpts, err error) {
+func AddrsFactory(announce []string, appendAnnouce []string, noAnnounce []string) interface{} {
+	return func(params struct {
+		fx.In
+		// Reachability drops the addresses AutoNAT v2 found private
+		Reachability *autonatv2.Results `optional:"true"`
+	}) (opts Libp2pOpts, err error) {
 		addrsFactory, err := makeAddrsFactory(announce, appendAnnouce, noAnnounce)
 		if err != nil {
 			return opts, err
 		}
+		if params.Reachability != nil {
+			announced := addrsFactory
+			addrsFactory = func(addrs []ma.Multiaddr) []ma.Multiaddr {
+				return announced(params.Reachability.Filter(addrs))
+			}
+		}
 		opts.Opts = append(opts.Opts, libp2p.AddrsFactory(addrsFactory))
 		return
 	}
@@ -0,0 +1,122 @@
+package libp2p
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/dialstats"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/transport"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// dialPolicy delays the dials of the fallback addresses of a peer, giving its
+// preferred addresses a head start, in the manner of happy eyeballs
+// (RFC 8305). The dials still pending once a dial succeeded are canceled by
+// the swarm.
+type dialPolicy struct {
+	ps         peerstore.Peerstore
+	preferQUIC bool
+	preferIPv4 bool
+	delay      time.Duration
+}
+
+// newDialPolicy returns the policy of cfg, or nil if it has no preference.
+func newDialPolicy(ps peerstore.Peerstore, cfg config.Dialing) *dialPolicy {
+	p := &dialPolicy{
+		ps:         ps,
+		preferQUIC: cfg.PreferQUIC.WithDefault(false),
+		preferIPv4: cfg.PreferIPv4.WithDefault(false),
+		delay:      cfg.FallbackDelay.WithDefault(config.DefaultDialingFallbackDelay),
+	}
+	if (!p.preferQUIC && !p.preferIPv4) || p.delay <= 0 {
+		return nil
+	}
+	return p
+}
+
+// delayOf returns the delay of the dial of addr, one FallbackDelay for each
+// preferred kind of address p has and addr isn't of.
+func (dp *dialPolicy) delayOf(p peer.ID, addr ma.Multiaddr) time.Duration {
+	isQUIC, isIPv6 := addrKinds(addr)
+	if !(dp.preferQUIC && !isQUIC) && !(dp.preferIPv4 && isIPv6) {
+		return 0
+	}
+	var hasQUIC, hasIPv4 bool
+	for _, a := range dp.ps.Addrs(p) {
+		q, v6 := addrKinds(a)
+		hasQUIC = hasQUIC || q
+		hasIPv4 = hasIPv4 || !v6
+	}
+	var d time.Duration
+	if dp.preferQUIC && !isQUIC && hasQUIC {
+		d += dp.delay
+	}
+	if dp.preferIPv4 && isIPv6 && hasIPv4 {
+		d += dp.delay
+	}
+	return d
+}
+
+// addrKinds returns whether addr is a direct QUIC address, and an IPv6 one.
+func addrKinds(addr ma.Multiaddr) (isQUIC bool, isIPv6 bool) {
+	for i, p := range addr.Protocols() {
+		switch p.Code {
+		case ma.P_IP6, ma.P_DNS6:
+			isIPv6 = isIPv6 || i == 0
+		case ma.P_QUIC, ma.P_QUIC_V1:
+			isQUIC = true
+		case ma.P_WEBTRANSPORT, ma.P_CIRCUIT:
+			return false, isIPv6
+		}
+	}
+	return isQUIC, isIPv6
+}
+
+// dialingTransport applies the dial policy to the dials of a transport, and
+// records their outcomes in the dial statistics. Either may be nil.
+type dialingTransport struct {
+	transport.Transport
+	policy *dialPolicy
+	stats  *dialstats.Stats
+}
+
+func (t *dialingTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p peer.ID) (transport.CapableConn, error) {
+	if t.policy != nil {
+		if d := t.policy.delayOf(p, raddr); d > 0 {
+			timer := time.NewTimer(d)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	start := time.Now()
+	c, err := t.Transport.Dial(ctx, raddr, p)
+	if t.stats != nil {
+		t.stats.Observe(ctx, raddr, time.Since(start), err)
+	}
+	return c, err
+}
+
+// Resolve resolves the addresses of the transports implementing
+// transport.Resolver, e.g. websocket, and returns the others as is.
+func (t *dialingTransport) Resolve(ctx context.Context, maddr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	if r, ok := t.Transport.(transport.Resolver); ok {
+		return r.Resolve(ctx, maddr)
+	}
+	return []ma.Multiaddr{maddr}, nil
+}
+
+// Close closes the transports implementing io.Closer, e.g. QUIC.
+func (t *dialingTransport) Close() error {
+	if c, ok := t.Transport.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
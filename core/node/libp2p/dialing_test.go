@@ -0,0 +1,51 @@
+package libp2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialPolicy(t *testing.T) {
+	require.Nil(t, newDialPolicy(nil, config.Dialing{}))
+	require.Nil(t, newDialPolicy(nil, config.Dialing{
+		PreferQUIC:    config.True,
+		FallbackDelay: config.NewOptionalDuration(0),
+	}))
+
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	const delay = 100 * time.Millisecond
+	dp := newDialPolicy(ps, config.Dialing{
+		PreferQUIC:    config.True,
+		PreferIPv4:    config.True,
+		FallbackDelay: config.NewOptionalDuration(delay),
+	})
+	require.NotNil(t, dp)
+
+	var (
+		quic4 = ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+		tcp4  = ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+		tcp6  = ma.StringCast("/ip6/2001:db8::1/tcp/4001")
+		quic6 = ma.StringCast("/ip6/2001:db8::1/udp/4001/quic-v1")
+	)
+	both := peer.ID("both")
+	ps.AddAddrs(both, []ma.Multiaddr{quic4, tcp4, tcp6, quic6}, peerstore.PermanentAddrTTL)
+	require.Equal(t, time.Duration(0), dp.delayOf(both, quic4))
+	require.Equal(t, delay, dp.delayOf(both, tcp4))
+	require.Equal(t, delay, dp.delayOf(both, quic6))
+	require.Equal(t, 2*delay, dp.delayOf(both, tcp6))
+
+	// the addresses of the peers without a preferred address aren't delayed
+	tcpOnly := peer.ID("tcp")
+	ps.AddAddrs(tcpOnly, []ma.Multiaddr{tcp6}, peerstore.PermanentAddrTTL)
+	require.Equal(t, time.Duration(0), dp.delayOf(tcpOnly, tcp6))
+}
@@ -0,0 +1,190 @@
+package libp2p
+
+import (
+	"context"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HolePunchCounters counts the attempts to upgrade relayed connections to
+// direct ones.
+type HolePunchCounters struct {
+	// direct dials attempted before hole punching
+	DirectDials         uint64
+	DirectDialSuccesses uint64
+	// hole punches (DCUtR) attempted once direct dials failed
+	HolePunches        uint64
+	HolePunchSuccesses uint64
+	// hole punches by transport of the addresses punched
+	Transports map[string]HolePunchTransportCounters `json:",omitempty"`
+}
+
+// HolePunchTransportCounters counts the hole punches over one transport.
+type HolePunchTransportCounters struct {
+	HolePunches        uint64
+	HolePunchSuccesses uint64
+}
+
+var (
+	holePunchesMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "libp2p_holepunch_attempts_total",
+			Help: "hole punches attempted, by transport",
+		},
+		[]string{"transport"},
+	)
+	holePunchSuccessesMetric = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "libp2p_holepunch_successes_total",
+			Help: "successful hole punches, by transport",
+		},
+		[]string{"transport"},
+	)
+)
+
+// HolePunchTracer is a holepunch.EventTracer counting the outcome of hole
+// punching.
+type HolePunchTracer struct {
+	net network.Network
+
+	mu       sync.Mutex
+	counters HolePunchCounters
+	// transports being punched, by remote peer
+	pending map[peer.ID][]string
+}
+
+var _ holepunch.EventTracer = (*HolePunchTracer)(nil)
+
+func newHolePunchTracer(net network.Network) *HolePunchTracer {
+	mustRegister(holePunchesMetric)
+	mustRegister(holePunchSuccessesMetric)
+	return &HolePunchTracer{
+		net:      net,
+		counters: HolePunchCounters{Transports: make(map[string]HolePunchTransportCounters)},
+		pending:  make(map[peer.ID][]string),
+	}
+}
+
+// Trace implements holepunch.EventTracer.
+func (t *HolePunchTracer) Trace(evt *holepunch.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch e := evt.Evt.(type) {
+	case *holepunch.DirectDialEvt:
+		t.counters.DirectDials++
+		if e.Success {
+			t.counters.DirectDialSuccesses++
+		}
+	case *holepunch.StartHolePunchEvt:
+		var transports []string
+		seen := make(map[string]struct{})
+		for _, s := range e.RemoteAddrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				continue
+			}
+			name := TransportName(a)
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				transports = append(transports, name)
+			}
+		}
+		t.pending[evt.Remote] = transports
+	case *holepunch.EndHolePunchEvt:
+		t.counters.HolePunches++
+		for _, name := range t.pending[evt.Remote] {
+			c := t.counters.Transports[name]
+			c.HolePunches++
+			t.counters.Transports[name] = c
+			holePunchesMetric.WithLabelValues(name).Inc()
+		}
+		delete(t.pending, evt.Remote)
+		if e.Success {
+			t.counters.HolePunchSuccesses++
+			// credit the transport of the connection that was punched
+			for _, conn := range t.net.ConnsToPeer(evt.Remote) {
+				name := TransportName(conn.RemoteMultiaddr())
+				if name == "relay" {
+					continue
+				}
+				c := t.counters.Transports[name]
+				c.HolePunchSuccesses++
+				t.counters.Transports[name] = c
+				holePunchSuccessesMetric.WithLabelValues(name).Inc()
+				break
+			}
+		}
+	}
+}
+
+// Counters returns a snapshot of the counters.
+func (t *HolePunchTracer) Counters() HolePunchCounters {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.counters
+	c.Transports = make(map[string]HolePunchTransportCounters, len(t.counters.Transports))
+	for name, tc := range t.counters.Transports {
+		c.Transports[name] = tc
+	}
+	return c
+}
+
+// HolePuncher runs the hole punching (DCUtR) service of the node, so that
+// hole punching can also be retried on demand.
+type HolePuncher struct {
+	svc    *holepunch.Service
+	tracer *HolePunchTracer
+}
+
+// Counters returns a snapshot of the hole punching counters.
+func (hp *HolePuncher) Counters() HolePunchCounters {
+	return hp.tracer.Counters()
+}
+
+// DirectConnect tries to upgrade the relayed connection to p to a direct
+// one, dialing p directly first and hole punching if that fails. It returns
+// immediately if a direct connection to p already exists.
+func (hp *HolePuncher) DirectConnect(ctx context.Context, p peer.ID) error {
+	// the service can't be cancelled and waits for the node to know its
+	// public addresses, the goroutine exits when it gives up
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hp.svc.DirectConnect(p)
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TransportName returns the name of the transport used by a: relay,
+// webtransport, quic, websocket, tcp or other.
+func TransportName(a ma.Multiaddr) string {
+	has := func(code int) bool {
+		_, err := a.ValueForProtocol(code)
+		return err == nil
+	}
+	switch {
+	case has(ma.P_CIRCUIT):
+		return "relay"
+	case has(ma.P_WEBTRANSPORT):
+		return "webtransport"
+	case has(ma.P_QUIC_V1), has(ma.P_QUIC):
+		return "quic"
+	case has(ma.P_WS), has(ma.P_WSS):
+		return "websocket"
+	case has(ma.P_TCP):
+		return "tcp"
+	default:
+		return "other"
+	}
+}
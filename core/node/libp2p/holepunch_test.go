@@ -0,0 +1,77 @@
+package libp2p
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+// connsNetwork is a network whose connections to any peer are conns.
+type connsNetwork struct {
+	network.Network
+	conns []network.Conn
+}
+
+func (n *connsNetwork) ConnsToPeer(peer.ID) []network.Conn { return n.conns }
+
+type addrConn struct {
+	network.Conn
+	addr ma.Multiaddr
+}
+
+func (c *addrConn) RemoteMultiaddr() ma.Multiaddr { return c.addr }
+
+func TestTransportName(t *testing.T) {
+	for addr, name := range map[string]string{
+		"/ip4/1.2.3.4/tcp/4001":                      "tcp",
+		"/ip4/1.2.3.4/udp/4001/quic":                 "quic",
+		"/ip4/1.2.3.4/udp/4001/quic-v1":              "quic",
+		"/ip4/1.2.3.4/udp/4001/quic-v1/webtransport": "webtransport",
+		"/ip4/1.2.3.4/tcp/4001/ws":                   "websocket",
+		"/ip4/1.2.3.4/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit": "relay",
+		"/ip4/1.2.3.4/udp/4001": "other",
+	} {
+		require.Equal(t, name, TransportName(ma.StringCast(addr)), addr)
+	}
+}
+
+func TestHolePunchTracer(t *testing.T) {
+	net := &connsNetwork{}
+	tr := newHolePunchTracer(net)
+	const remote = peer.ID("remote")
+
+	tr.Trace(&holepunch.Event{Remote: remote, Evt: &holepunch.DirectDialEvt{Success: false}})
+	tr.Trace(&holepunch.Event{Remote: remote, Evt: &holepunch.StartHolePunchEvt{
+		RemoteAddrs: []string{"/ip4/1.2.3.4/tcp/4001", "/ip4/1.2.3.4/udp/4001/quic-v1", "/ip4/5.6.7.8/tcp/4001"},
+	}})
+	// the punched connection is the QUIC one, next to the relayed one
+	net.conns = []network.Conn{
+		&addrConn{addr: ma.StringCast("/ip4/9.9.9.9/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ/p2p-circuit")},
+		&addrConn{addr: ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")},
+	}
+	tr.Trace(&holepunch.Event{Remote: remote, Evt: &holepunch.EndHolePunchEvt{Success: true}})
+
+	// a failed hole punch over TCP only
+	tr.Trace(&holepunch.Event{Remote: remote, Evt: &holepunch.StartHolePunchEvt{
+		RemoteAddrs: []string{"/ip4/1.2.3.4/tcp/4001", "not a multiaddr"},
+	}})
+	tr.Trace(&holepunch.Event{Remote: remote, Evt: &holepunch.EndHolePunchEvt{Success: false}})
+
+	c := tr.Counters()
+	require.Equal(t, uint64(1), c.DirectDials)
+	require.Equal(t, uint64(0), c.DirectDialSuccesses)
+	require.Equal(t, uint64(2), c.HolePunches)
+	require.Equal(t, uint64(1), c.HolePunchSuccesses)
+	require.Equal(t, map[string]HolePunchTransportCounters{
+		"tcp":  {HolePunches: 2},
+		"quic": {HolePunches: 1, HolePunchSuccesses: 1},
+	}, c.Transports)
+
+	// the snapshot doesn't share the counters of the tracer
+	c.Transports["tcp"] = HolePunchTransportCounters{}
+	require.Equal(t, uint64(2), tr.Counters().Transports["tcp"].HolePunches)
+}
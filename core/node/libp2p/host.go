@@ -10,9 +10,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/routing"
 	routedhost "github.com/libp2p/go-libp2p/p2p/host/routed"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 
 	"github.com/ipfs/kubo/core/node/helpers"
 	"github.com/ipfs/kubo/repo"
+	"github.com/ipfs/kubo/shaping"
 
 	"go.uber.org/fx"
 )
@@ -35,6 +37,20 @@ type P2PHostOut struct {
 
 	Host    host.Host
 	Routing routing.Routing `name:"initialrouting"`
+	// IDService is the identify service of the underlying basic host, nil
+	// if the host doesn't expose one.
+	IDService identify.IDService
+	// Shaper holds the traffic shaping rules of the host, nil unless
+	// Experimental.TrafficShaping is enabled.
+	Shaper *shaping.Shaper
+}
+
+// idService returns the identify service of h, if it exposes one.
+func idService(h host.Host) identify.IDService {
+	if h, ok := h.(interface{ IDService() identify.IDService }); ok {
+		return h.IDService()
+	}
+	return nil
 }
 
 func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHostOut, err error) {
@@ -61,6 +77,8 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 			bootstrappers...,
 		)
 		out.Routing = r
+		// h is the basic host, before it gets wrapped by the routed host
+		out.IDService = idService(h)
 		return r, err
 	}))
 
@@ -77,9 +95,16 @@ func Host(mctx helpers.MetricsCtx, lc fx.Lifecycle, params P2PHostIn) (out P2PHo
 			return P2PHostOut{}, err
 		}
 		out.Routing = r
+		out.IDService = idService(out.Host)
 		out.Host = routedhost.Wrap(out.Host, out.Routing)
 	}
 
+	if cfg.Experimental.TrafficShaping {
+		log.Warn("traffic shaping is enabled, the streams of the node may be delayed on purpose")
+		out.Shaper = shaping.New()
+		out.Host = shaping.Wrap(out.Host, out.Shaper)
+	}
+
 	lc.Append(fx.Hook{
 		OnStop: func(ctx context.Context) error {
 			return out.Host.Close()
@@ -0,0 +1,78 @@
+package libp2p
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
+	"go.uber.org/fx"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/ipni"
+	"github.com/ipfs/kubo/repo"
+)
+
+// IPNIPublisher publishes the content provided by the node to the indexers
+// of Routing.IPNI, as advertisements signed with the identity of the node.
+func IPNIPublisher(cfg config.RoutingIPNI) interface{} {
+	return func(lc fx.Lifecycle, r repo.Repo, sk crypto.PrivKey, h host.Host) (*ipni.Publisher, error) {
+		var addrs []ma.Multiaddr
+		for _, s := range cfg.PublisherAddrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Routing.IPNI.PublisherAddrs: %w", err)
+			}
+			addrs = append(addrs, a)
+		}
+		indexers := cfg.Indexers
+		if len(indexers) == 0 {
+			indexers = config.DefaultRoutingIPNIIndexers
+		}
+
+		p, err := ipni.New(r.Datastore(), sk, ipni.Params{
+			Indexers:       indexers,
+			PublisherAddrs: addrs,
+			Addrs:          h.Addrs,
+			ChunkSize:      int(cfg.EntriesChunkSize.WithDefault(config.DefaultRoutingIPNIEntriesChunkSize)),
+			Interval:       cfg.Interval.WithDefault(config.DefaultRoutingIPNIInterval),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid Routing.IPNI: %w", err)
+		}
+		lc.Append(fx.Hook{
+			OnStart: func(context.Context) error {
+				p.Start()
+				return nil
+			},
+			OnStop: p.Stop,
+		})
+		return p, nil
+	}
+}
+
+// ipniRouter adds the provider records to the advertisements of an IPNI
+// publisher.
+type ipniRouter struct {
+	routinghelpers.Null
+	p *ipni.Publisher
+}
+
+func (r ipniRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if !announce {
+		return nil
+	}
+	return r.p.Add(ctx, c.Hash())
+}
+
+func (r ipniRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	return r.p.Add(ctx, keys...)
+}
+
+func (r ipniRouter) Ready() bool {
+	return true
+}
@@ -1,10 +1,14 @@
 package libp2p
 
 import (
+	"context"
 	"time"
 
 	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/autonatv2"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"go.uber.org/fx"
 )
 
 var NatPortMap = simpleOpt(libp2p.NATPortMap())
@@ -24,3 +28,50 @@ func AutoNATService(throttle *config.AutoNATThrottleConfig) func() Libp2pOpts {
 		return opts
 	}
 }
+
+// AutoNATV2Results holds the reachability of the addresses of the node found
+// by AutoNAT v2, which filters the addresses advertised, see AddrsFactory.
+func AutoNATV2Results() *autonatv2.Results {
+	return autonatv2.NewResults()
+}
+
+// AutoNATV2 checks the reachability of the addresses of the node with
+// AutoNAT v2, and serves the AutoNAT v2 requests of other peers if service
+// is enabled and the network is public.
+func AutoNATV2(service bool) interface{} {
+	return func(params struct {
+		fx.In
+		LC      fx.Lifecycle
+		Host    host.Host
+		Results *autonatv2.Results
+		Fprint  PNetFingerprint `optional:"true"`
+	}) (*autonatv2.Client, error) {
+		client := autonatv2.NewClient(params.Host, params.Results, config.DefaultAutoNATV2Interval)
+		var server *autonatv2.Server
+		if service && params.Fprint == nil {
+			// the dials back are made by another peer, on new connections
+			dialer, err := libp2p.New(libp2p.NoListenAddrs, libp2p.DisableRelay())
+			if err != nil {
+				return nil, err
+			}
+			server = autonatv2.NewServer(params.Host, dialer)
+		}
+		params.LC.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				client.Start()
+				if server != nil {
+					server.Start()
+				}
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				client.Stop()
+				if server != nil {
+					return server.Close()
+				}
+				return nil
+			},
+		})
+		return client, nil
+	}
+}
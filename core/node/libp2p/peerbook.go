@@ -0,0 +1,132 @@
+package libp2p
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/peerbook"
+	"github.com/ipfs/kubo/repo"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	ma "github.com/multiformats/go-multiaddr"
+	"go.uber.org/fx"
+)
+
+const (
+	peerBookFlushInterval  = time.Minute
+	peerBookReconnectDials = 8
+	peerBookDialTimeout    = 30 * time.Second
+)
+
+// PersistentPeerstore records the outcome of the dials of the node in a
+// peerbook.Book persisted to the repo, and redials the best peers of the
+// book on startup, with their proven addresses only.
+func PersistentPeerstore(cfg config.PersistentPeerstore) func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, h host.Host) error {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, h host.Host) error {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		book := peerbook.New(repo.Datastore(), int(cfg.MaxPeers.WithDefault(config.DefaultPersistentPeerstoreMaxPeers)))
+		if err := book.Load(ctx); err != nil {
+			return err
+		}
+		h.Network().Notify(&network.NotifyBundle{
+			ConnectedF: func(_ network.Network, c network.Conn) {
+				if dialable(c) {
+					book.Succeeded(c.RemotePeer(), c.RemoteMultiaddr(), time.Now())
+				}
+			},
+			DisconnectedF: func(_ network.Network, c network.Conn) {
+				if !dialable(c) {
+					return
+				}
+				if d := h.Peerstore().LatencyEWMA(c.RemotePeer()); d > 0 {
+					book.RecordLatency(c.RemotePeer(), c.RemoteMultiaddr(), d)
+				}
+			},
+		})
+
+		reconnect := book.Peers(int(cfg.ReconnectPeers.WithDefault(config.DefaultPersistentPeerstoreReconnectPeers)))
+		done := make(chan struct{})
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				go func() {
+					defer close(done)
+					reconnectPeers(ctx, h, book, reconnect)
+					ticker := time.NewTicker(peerBookFlushInterval)
+					defer ticker.Stop()
+					for {
+						select {
+						case <-ticker.C:
+							if err := book.Flush(ctx); err != nil {
+								log.Errorf("persisting the peerstore: %s", err)
+							}
+						case <-ctx.Done():
+							return
+						}
+					}
+				}()
+				return nil
+			},
+			OnStop: func(stopCtx context.Context) error {
+				<-done
+				return book.Flush(stopCtx)
+			},
+		})
+		return nil
+	}
+}
+
+// dialable returns true if the remote address of c can be dialed back: the
+// remote address of an inbound connection is usually an ephemeral port.
+func dialable(c network.Conn) bool {
+	if c.Stat().Direction != network.DirOutbound {
+		return false
+	}
+	_, err := c.RemoteMultiaddr().ValueForProtocol(ma.P_CIRCUIT)
+	return err != nil
+}
+
+// reconnectPeers dials peers with the addresses of the book only, recording
+// the addresses that failed.
+func reconnectPeers(ctx context.Context, h host.Host, book *peerbook.Book, peers []peer.AddrInfo) {
+	sem := make(chan struct{}, peerBookReconnectDials)
+	var wg sync.WaitGroup
+	for _, pi := range peers {
+		if h.Network().Connectedness(pi.ID) == network.Connected {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func(pi peer.AddrInfo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// the peerstore is empty on startup: these become the only
+			// addresses dialed until the peer is rediscovered
+			h.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.AddressTTL)
+			dctx, cancel := context.WithTimeout(ctx, peerBookDialTimeout)
+			defer cancel()
+			err := h.Connect(dctx, pi)
+			var derr *swarm.DialError
+			if errors.As(err, &derr) {
+				for _, te := range derr.DialErrors {
+					book.Failed(pi.ID, te.Address)
+				}
+			}
+		}(pi)
+	}
+	wg.Wait()
+}
@@ -1,7 +1,6 @@
 package libp2p
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"time"
@@ -18,20 +17,27 @@ import (
 
 type PNetFingerprint []byte
 
-func PNet(repo repo.Repo) (opts Libp2pOpts, fp PNetFingerprint, err error) {
+func PNet(repo repo.Repo) (opts Libp2pOpts, fp PNetFingerprint, keys *PNetKeys, err error) {
 	swarmkey, err := repo.SwarmKey()
 	if err != nil || swarmkey == nil {
-		return opts, nil, err
+		return opts, nil, nil, err
 	}
 
-	psk, err := pnet.DecodeV1PSK(bytes.NewReader(swarmkey))
+	psks, err := parseSwarmKeys(swarmkey)
 	if err != nil {
-		return opts, nil, fmt.Errorf("failed to configure private network: %s", err)
+		return opts, nil, nil, fmt.Errorf("failed to configure private network: %s", err)
+	}
+	keys = &PNetKeys{psks: psks, conns: make(map[string]int)}
+
+	if len(psks) == 1 {
+		opts.Opts = append(opts.Opts, libp2p.PrivateNetwork(psks[0]))
+	} else if pnet.ForcePrivateNetwork {
+		// the transports protect their connections themselves, libp2p
+		// doesn't know about it
+		return opts, nil, nil, fmt.Errorf("failed to configure private network: %s is not supported with several swarm keys", pnet.EnvKey)
 	}
 
-	opts.Opts = append(opts.Opts, libp2p.PrivateNetwork(psk))
-
-	return opts, pnetFingerprint(psk), nil
+	return opts, pnetFingerprint(psks[0]), keys, nil
 }
 
 func PNetChecker(repo repo.Repo, ph host.Host, lc fx.Lifecycle) error {
@@ -0,0 +1,215 @@
+package libp2p
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/davidlazar/go-crypto/salsa20"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/core/transport"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const swarmKeyHeader = "/key/swarm/psk/1.0.0/"
+
+// PNetKeys are the pre-shared keys of the private network. The first key is
+// the active one: it protects the connections opened by the node. The other
+// keys are accepted: connections protected by any of the keys are accepted,
+// so that the key of a fleet can be rotated without a flag day.
+type PNetKeys struct {
+	psks []pnet.PSK
+
+	mu sync.Mutex
+	// index of the key of the connections, by local and remote address
+	conns map[string]int
+}
+
+// parseSwarmKeys decodes the keys of a swarm.key file, which holds one or
+// more keys, each starting with the swarm key header.
+func parseSwarmKeys(swarmkey []byte) ([]pnet.PSK, error) {
+	var blocks [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(swarmkey))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if string(line) == swarmKeyHeader || len(blocks) == 0 {
+			blocks = append(blocks, nil)
+		}
+		i := len(blocks) - 1
+		blocks[i] = append(append(blocks[i], line...), '\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	psks := make([]pnet.PSK, 0, len(blocks))
+	for i, b := range blocks {
+		psk, err := pnet.DecodeV1PSK(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("swarm key %d: %w", i+1, err)
+		}
+		psks = append(psks, psk)
+	}
+	if len(psks) == 0 {
+		return nil, errors.New("no swarm key")
+	}
+	return psks, nil
+}
+
+// Fingerprints returns the fingerprints of the keys, the active one first.
+func (k *PNetKeys) Fingerprints() []PNetFingerprint {
+	fps := make([]PNetFingerprint, len(k.psks))
+	for i, psk := range k.psks {
+		fps[i] = pnetFingerprint(psk)
+	}
+	return fps
+}
+
+// ConnKey returns the fingerprint of the key used by the remote side of c.
+func (k *PNetKeys) ConnKey(c network.Conn) (PNetFingerprint, bool) {
+	if len(k.psks) == 1 {
+		return pnetFingerprint(k.psks[0]), true
+	}
+	k.mu.Lock()
+	i, ok := k.conns[connID(c)]
+	k.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return pnetFingerprint(k.psks[i]), true
+}
+
+func connID(c interface {
+	LocalMultiaddr() ma.Multiaddr
+	RemoteMultiaddr() ma.Multiaddr
+}) string {
+	return c.LocalMultiaddr().String() + " " + c.RemoteMultiaddr().String()
+}
+
+// upgrader protects the raw connections of a transport before upgrading
+// them with u, which must not have a PSK.
+func (k *PNetKeys) upgrader(u transport.Upgrader) transport.Upgrader {
+	return &pnetUpgrader{Upgrader: u, keys: k}
+}
+
+type pnetUpgrader struct {
+	transport.Upgrader
+	keys *PNetKeys
+}
+
+func (u *pnetUpgrader) UpgradeListener(t transport.Transport, l manet.Listener) transport.Listener {
+	return u.Upgrader.UpgradeListener(t, &pnetListener{Listener: l, keys: u.keys})
+}
+
+func (u *pnetUpgrader) Upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, scope network.ConnManagementScope) (transport.CapableConn, error) {
+	return u.Upgrader.Upgrade(ctx, t, u.keys.protect(maconn), dir, p, scope)
+}
+
+type pnetListener struct {
+	manet.Listener
+	keys *PNetKeys
+}
+
+func (l *pnetListener) Accept() (manet.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return l.keys.protect(c), nil
+}
+
+// mssHeader is the multistream-select header, the first message sent by
+// both sides of a libp2p connection: it tells which key the remote side uses.
+var mssHeader = []byte("\x13/multistream/1.0.0\n")
+
+// pskConn implements the libp2p private network protocol (XSalsa20 with a
+// 24 bytes nonce sent by each side) with several keys.
+type pskConn struct {
+	manet.Conn
+	keys *PNetKeys
+
+	readS20  cipher.Stream
+	readBuf  []byte // decrypted bytes read while detecting the key
+	writeS20 cipher.Stream
+}
+
+func (k *PNetKeys) protect(c manet.Conn) manet.Conn {
+	return &pskConn{Conn: c, keys: k}
+}
+
+func (c *pskConn) Read(out []byte) (int, error) {
+	if c.readS20 == nil {
+		if err := c.detectKey(); err != nil {
+			return 0, err
+		}
+	}
+	if len(c.readBuf) > 0 {
+		n := copy(out, c.readBuf)
+		c.readBuf = c.readBuf[n:]
+		return n, nil
+	}
+	n, err := c.Conn.Read(out)
+	if n > 0 {
+		c.readS20.XORKeyStream(out[:n], out[:n])
+	}
+	return n, err
+}
+
+// detectKey reads the nonce and the first message of the remote side, and
+// finds the key that decrypts it.
+func (c *pskConn) detectKey() error {
+	buf := make([]byte, 24+len(mssHeader))
+	if _, err := io.ReadFull(c.Conn, buf); err != nil {
+		return err
+	}
+	nonce, msg := buf[:24], buf[24:]
+	plain := make([]byte, len(msg))
+	for i, psk := range c.keys.psks {
+		s := salsa20.New((*[32]byte)(psk), nonce)
+		s.XORKeyStream(plain, msg)
+		if bytes.Equal(plain, mssHeader) {
+			c.readS20 = s
+			c.readBuf = plain
+			c.keys.mu.Lock()
+			c.keys.conns[connID(c.Conn)] = i
+			c.keys.mu.Unlock()
+			return nil
+		}
+	}
+	return errors.New("remote peer uses none of the swarm keys")
+}
+
+func (c *pskConn) Write(in []byte) (int, error) {
+	if c.writeS20 == nil {
+		nonce := make([]byte, 24)
+		if _, err := rand.Read(nonce); err != nil {
+			return 0, err
+		}
+		if _, err := c.Conn.Write(nonce); err != nil {
+			return 0, err
+		}
+		c.writeS20 = salsa20.New((*[32]byte)(c.keys.psks[0]), nonce)
+	}
+	out := make([]byte, len(in))
+	c.writeS20.XORKeyStream(out, in)
+	return c.Conn.Write(out)
+}
+
+func (c *pskConn) Close() error {
+	c.keys.mu.Lock()
+	delete(c.keys.conns, connID(c.Conn))
+	c.keys.mu.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,80 @@
+package libp2p
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+	ma "github.com/multiformats/go-multiaddr"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSwarmKey(t *testing.T) (pnet.PSK, string) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key, fmt.Sprintf("%s\n/base16/\n%s\n", swarmKeyHeader, hex.EncodeToString(key))
+}
+
+type pipeConn struct {
+	net.Conn
+	local, remote ma.Multiaddr
+}
+
+func (c *pipeConn) LocalMultiaddr() ma.Multiaddr  { return c.local }
+func (c *pipeConn) RemoteMultiaddr() ma.Multiaddr { return c.remote }
+
+func TestPNetKeys(t *testing.T) {
+	oldKey, oldFile := newSwarmKey(t)
+	newKey, newFile := newSwarmKey(t)
+	_, otherFile := newSwarmKey(t)
+
+	psks, err := parseSwarmKeys([]byte(newFile + "\n" + oldFile))
+	require.NoError(t, err)
+	require.Equal(t, []pnet.PSK{newKey, oldKey}, psks)
+
+	// a node that rotated to the new key, and one that didn't yet
+	rotated := &PNetKeys{psks: []pnet.PSK{newKey, oldKey}, conns: make(map[string]int)}
+	notRotated := &PNetKeys{psks: []pnet.PSK{oldKey, newKey}, conns: make(map[string]int)}
+
+	a, b := net.Pipe()
+	addrA, addrB := ma.StringCast("/ip4/1.1.1.1/tcp/1"), ma.StringCast("/ip4/2.2.2.2/tcp/2")
+	connA := rotated.protect(&pipeConn{a, addrA, addrB})
+	connB := notRotated.protect(&pipeConn{b, addrB, addrA})
+
+	exchange := func(from, to io.ReadWriter, msg []byte) {
+		errCh := make(chan error, 1)
+		go func() {
+			_, err := from.Write(msg)
+			errCh <- err
+		}()
+		buf := make([]byte, len(msg))
+		_, err := io.ReadFull(to, buf)
+		require.NoError(t, err)
+		require.NoError(t, <-errCh)
+		require.Equal(t, msg, buf)
+	}
+	msg := append(append([]byte(nil), mssHeader...), "/noise\n"...)
+	exchange(connA, connB, msg)
+	exchange(connB, connA, msg)
+
+	require.Equal(t, map[string]int{"/ip4/1.1.1.1/tcp/1 /ip4/2.2.2.2/tcp/2": 1}, rotated.conns)
+	require.Equal(t, map[string]int{"/ip4/2.2.2.2/tcp/2 /ip4/1.1.1.1/tcp/1": 1}, notRotated.conns)
+
+	// a node of another network
+	otherPsks, err := parseSwarmKeys([]byte(otherFile))
+	require.NoError(t, err)
+	other := &PNetKeys{psks: otherPsks, conns: make(map[string]int)}
+	a, b = net.Pipe()
+	connA = rotated.protect(&pipeConn{a, addrA, addrB})
+	connB = other.protect(&pipeConn{b, addrB, addrA})
+	go connB.Write(msg)
+	_, err = connA.Read(make([]byte, len(msg)))
+	require.Error(t, err)
+	require.NoError(t, connA.Close())
+}
@@ -2,12 +2,16 @@ package libp2p
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/ipfs/kubo/config"
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/p2p/host/autorelay"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"go.uber.org/fx"
 )
 
@@ -105,21 +109,37 @@ func MaybeAutoRelay(staticRelays []string, cfgPeering config.Peering, enabled bo
 	)
 }
 
-func HolePunching(flag config.Flag, hasRelayClient bool) func() (opts Libp2pOpts, err error) {
-	return func() (opts Libp2pOpts, err error) {
-		if flag.WithDefault(true) {
-			if !hasRelayClient {
-				// If hole punching is explicitly enabled but the relay client is disabled then panic,
-				// otherwise just silently disable hole punching
-				if flag != config.Default {
-					log.Fatal("Failed to enable `Swarm.EnableHolePunching`, it requires `Swarm.RelayClient.Enabled` to be true.")
-				} else {
-					log.Info("HolePunching has been disabled due to the RelayClient being disabled.")
-				}
-				return
+func HolePunching(flag config.Flag, hasRelayClient bool) func(lc fx.Lifecycle, h host.Host, ids identify.IDService) (*HolePuncher, error) {
+	return func(lc fx.Lifecycle, h host.Host, ids identify.IDService) (*HolePuncher, error) {
+		if !flag.WithDefault(true) {
+			return nil, nil
+		}
+		if !hasRelayClient {
+			// If hole punching is explicitly enabled but the relay client is disabled then panic,
+			// otherwise just silently disable hole punching
+			if flag != config.Default {
+				log.Fatal("Failed to enable `Swarm.EnableHolePunching`, it requires `Swarm.RelayClient.Enabled` to be true.")
+			} else {
+				log.Info("HolePunching has been disabled due to the RelayClient being disabled.")
 			}
-			opts.Opts = append(opts.Opts, libp2p.EnableHolePunching())
+			return nil, nil
 		}
-		return
+		if ids == nil {
+			return nil, fmt.Errorf("hole punching requires the identify service of the host")
+		}
+
+		// The service is run here rather than through libp2p.EnableHolePunching
+		// so that it can be asked to upgrade relayed connections on demand.
+		tracer := newHolePunchTracer(h.Network())
+		svc, err := holepunch.NewService(h, ids, holepunch.WithTracer(tracer))
+		if err != nil {
+			return nil, err
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(_ context.Context) error {
+				return svc.Close()
+			},
+		})
+		return &HolePuncher{svc: svc, tracer: tracer}, nil
 	}
 }
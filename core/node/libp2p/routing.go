@@ -8,7 +8,12 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-cid"
 	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
+	"github.com/ipfs/go-datastore/query"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	offroute "github.com/ipfs/go-ipfs-routing/offline"
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	ddht "github.com/libp2p/go-libp2p-kad-dht/dual"
@@ -20,10 +25,13 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
 	"go.uber.org/fx"
 
 	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/ipni"
 	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/pinlabels"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 )
@@ -171,6 +179,10 @@ type p2pOnlineRoutingIn struct {
 
 	Routers   []Router `group:"routers"`
 	Validator record.Validator
+	Announcer *irouting.Announcer `optional:"true"`
+	IPNI      *ipni.Publisher     `optional:"true"`
+	Scope     *pinlabels.Scope    `optional:"true"`
+	DHT       *ddht.DHT           `optional:"true"`
 }
 
 // Routing will get all routers obtained from different methods
@@ -185,16 +197,160 @@ func Routing(in p2pOnlineRoutingIn) irouting.ProvideManyRouter {
 
 	var cRouters []*routinghelpers.ParallelRouter
 	for _, v := range routers {
+		r := v.Routing
+		if in.Scope != nil {
+			r = scopeRouter(r, in.Scope, lanDHT(r, in.DHT))
+		}
+		if in.Announcer != nil && in.Announcer.Exclusive() {
+			// the provider records only go to the HTTP routers
+			r = noProvideRouter{r}
+		}
 		cRouters = append(cRouters, &routinghelpers.ParallelRouter{
 			Timeout:     5 * time.Minute,
 			IgnoreError: true,
-			Router:      v.Routing,
+			Router:      r,
+		})
+	}
+	var providers []routing.Routing
+	if in.Announcer != nil {
+		providers = append(providers, in.Announcer)
+	}
+	if in.IPNI != nil {
+		providers = append(providers, ipniRouter{p: in.IPNI})
+	}
+	for _, r := range providers {
+		if in.Scope != nil {
+			r = scopeRouter(r, in.Scope, nil)
+		}
+		cRouters = append(cRouters, &routinghelpers.ParallelRouter{
+			Timeout:     5 * time.Minute,
+			IgnoreError: true,
+			Router:      r,
 		})
 	}
 
 	return routinghelpers.NewComposableParallel(cRouters)
 }
 
+// noProvideRouter is a router which doesn't provide.
+type noProvideRouter struct {
+	routing.Routing
+}
+
+func (noProvideRouter) Provide(context.Context, cid.Cid, bool) error {
+	return nil
+}
+
+func (noProvideRouter) ProvideMany(context.Context, []multihash.Multihash) error {
+	return nil
+}
+
+func (noProvideRouter) Ready() bool {
+	return true
+}
+
+// scopedRouter doesn't provide the private content of its scope, which is
+// only provided on the LAN DHT, when the router has one.
+type scopedRouter struct {
+	routing.Routing
+	scope *pinlabels.Scope
+	lan   routing.ContentRouting
+}
+
+func (r scopedRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if !r.scope.Private(c.Hash()) {
+		return r.Routing.Provide(ctx, c, announce)
+	}
+	if r.lan != nil {
+		return r.lan.Provide(ctx, c, announce)
+	}
+	return nil
+}
+
+// scopedManyRouter is the scopedRouter of a router providing many keys at
+// once.
+type scopedManyRouter struct {
+	scopedRouter
+	many routinghelpers.ProvideManyRouter
+}
+
+func (r scopedManyRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	var errs error
+	public := make([]multihash.Multihash, 0, len(keys))
+	for _, k := range keys {
+		if !r.scope.Private(k) {
+			public = append(public, k)
+			continue
+		}
+		if r.lan != nil {
+			if err := r.lan.Provide(ctx, cid.NewCidV1(cid.Raw, k), true); err != nil {
+				errs = multierror.Append(errs, err)
+			}
+		}
+	}
+	if err := r.many.ProvideMany(ctx, public); err != nil {
+		errs = multierror.Append(errs, err)
+	}
+	return errs
+}
+
+func (r scopedManyRouter) Ready() bool {
+	return r.many.Ready()
+}
+
+// scopeRouter returns r not providing the private content of scope, provided
+// on lan instead unless nil.
+func scopeRouter(r routing.Routing, scope *pinlabels.Scope, lan routing.ContentRouting) routing.Routing {
+	sr := scopedRouter{Routing: r, scope: scope, lan: lan}
+	if many, ok := r.(routinghelpers.ProvideManyRouter); ok {
+		return scopedManyRouter{scopedRouter: sr, many: many}
+	}
+	return sr
+}
+
+// lanDHT returns the LAN DHT of the DHT router r, nil if r isn't a DHT.
+func lanDHT(r routing.Routing, dual *ddht.DHT) routing.ContentRouting {
+	switch r := r.(type) {
+	case *ddht.DHT:
+		dual = r
+	case *fullrt.FullRT:
+		// the accelerated client only runs on the WAN, along the dual DHT
+	default:
+		return nil
+	}
+	if dual == nil || dual.LAN == nil {
+		return nil
+	}
+	return dual.LAN
+}
+
+// HTTPAnnouncer announces the provider records to the HTTP routers of
+// Routing.Announce, signed with the identity of the node.
+func HTTPAnnouncer(identity config.Identity, addrs []string, cfg config.RoutingAnnounce) interface{} {
+	return func(lc fx.Lifecycle) (*irouting.Announcer, error) {
+		a, err := irouting.NewAnnouncer(irouting.AnnounceParams{
+			Endpoints:  cfg.Endpoints,
+			Exclusive:  cfg.Exclusive.WithDefault(false),
+			BatchSize:  int(cfg.BatchSize.WithDefault(config.DefaultRoutingAnnounceBatchSize)),
+			BatchDelay: cfg.BatchDelay.WithDefault(config.DefaultRoutingAnnounceBatchDelay),
+			MaxRetries: int(cfg.MaxRetries.WithDefault(config.DefaultRoutingAnnounceMaxRetries)),
+		}, &irouting.ExtraHTTPParams{
+			PeerID:     identity.PeerID,
+			Addrs:      addrs,
+			PrivKeyB64: identity.PrivKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid Routing.Announce: %w", err)
+		}
+		lc.Append(fx.Hook{
+			OnStop: func(ctx context.Context) error {
+				return a.Close()
+			},
+		})
+		return a, nil
+	}
+}
+
 // OfflineRouting provides a special Router to the routers list when we are creating a offline node.
 func OfflineRouting(dstore ds.Datastore, validator record.Validator) p2pRouterOut {
 	return p2pRouterOut{
@@ -205,27 +361,102 @@ func OfflineRouting(dstore ds.Datastore, validator record.Validator) p2pRouterOu
 	}
 }
 
+// OfflineDelegatedRouting provides HTTP delegated routers to the routers list
+// of an offline node. No libp2p host is required, so only HTTP routers are
+// used: either the ones defined in Routing.Routers when Routing.Type is
+// "custom", or the default ones. Only the provider lookups are delegated: the
+// exchange stays offline, so the providers found can't be fetched from.
+func OfflineDelegatedRouting(cfg *config.Config) interface{} {
+	return func() (p2pRouterOut, error) {
+		var routers []*routinghelpers.ParallelRouter
+		for _, endpoint := range offlineDelegatedEndpoints(cfg) {
+			httpRouter, err := irouting.ConstructHTTPRouter(endpoint, cfg.Identity.PeerID, cfg.Addresses.Swarm, cfg.Identity.PrivKey)
+			if err != nil {
+				return p2pRouterOut{}, err
+			}
+			routers = append(routers, &routinghelpers.ParallelRouter{
+				Router: &irouting.Composer{
+					GetValueRouter:      routinghelpers.Null{},
+					PutValueRouter:      routinghelpers.Null{},
+					ProvideRouter:       routinghelpers.Null{},
+					FindPeersRouter:     routinghelpers.Null{},
+					FindProvidersRouter: httpRouter,
+				},
+				IgnoreError: true,
+				Timeout:     15 * time.Second,
+			})
+		}
+
+		return p2pRouterOut{
+			Router: Router{
+				Routing:  routinghelpers.NewComposableParallel(routers),
+				Priority: 100,
+			},
+		}, nil
+	}
+}
+
+// offlineDelegatedEndpoints returns the endpoints of the HTTP routers used
+// while offline.
+func offlineDelegatedEndpoints(cfg *config.Config) []string {
+	if cfg.Routing.Type.WithDefault("auto") != "custom" {
+		return defaultHTTPRouters
+	}
+	var endpoints []string
+	for _, r := range cfg.Routing.Routers {
+		if r.Type != config.RouterTypeHTTP {
+			continue
+		}
+		if params, ok := r.Parameters.(*config.HTTPRouterParams); ok && params.Endpoint != "" {
+			endpoints = append(endpoints, params.Endpoint)
+		}
+	}
+	return endpoints
+}
+
 type p2pPSRoutingIn struct {
 	fx.In
 
 	Validator record.Validator
 	Host      host.Host
+	Repo      repo.Repo
 	PubSub    *pubsub.PubSub `optional:"true"`
 }
 
+// pubsubRecordsPrefix is the prefix of the records of the IPNS pubsub router
+// in the datastore of the repo.
+var pubsubRecordsPrefix = ds.NewKey("/namesys-pubsub")
+
+// PubsubRecordsDatastore returns the datastore of the records of the IPNS
+// pubsub router, by record key, in the datastore of a repo.
+func PubsubRecordsDatastore(d ds.Datastore) ds.Datastore {
+	return namespace.Wrap(d, pubsubRecordsPrefix)
+}
+
 func PubsubRouter(mctx helpers.MetricsCtx, lc fx.Lifecycle, in p2pPSRoutingIn) (p2pRouterOut, *namesys.PubsubValueStore, error) {
+	// the latest records are kept across restarts, to serve them to the
+	// peers joining their topics right away
+	records := PubsubRecordsDatastore(in.Repo.Datastore())
 	psRouter, err := namesys.NewPubsubValueStore(
 		helpers.LifecycleCtx(mctx, lc),
 		in.Host,
 		in.PubSub,
 		in.Validator,
 		namesys.WithRebroadcastInterval(time.Minute),
+		namesys.WithDatastore(records),
 	)
 
 	if err != nil {
 		return p2pRouterOut{}, nil, err
 	}
 
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			resubscribePubsubRecords(ctx, psRouter, records, in.Validator)
+			return nil
+		},
+	})
+
 	return p2pRouterOut{
 		Router: Router{
 			Routing: &routinghelpers.Compose{
@@ -239,6 +470,38 @@ func PubsubRouter(mctx helpers.MetricsCtx, lc fx.Lifecycle, in p2pPSRoutingIn) (
 	}, psRouter, nil
 }
 
+// resubscribePubsubRecords subscribes again to the topics of the records
+// kept by the pubsub router before a restart, and drops the expired ones.
+func resubscribePubsubRecords(ctx context.Context, psRouter *namesys.PubsubValueStore, records ds.Datastore, validator record.Validator) {
+	results, err := records.Query(ctx, query.Query{})
+	if err != nil {
+		log.Errorf("listing the IPNS pubsub records: %s", err)
+		return
+	}
+	defer results.Close()
+
+	for r := range results.Next() {
+		if r.Error != nil {
+			log.Errorf("listing the IPNS pubsub records: %s", r.Error)
+			return
+		}
+		dsKey := ds.RawKey(r.Key)
+		key, err := dshelp.BinaryFromDsKey(dsKey)
+		if err != nil {
+			continue
+		}
+		if err := validator.Validate(string(key), r.Value); err != nil {
+			if err := records.Delete(ctx, dsKey); err != nil {
+				log.Errorf("dropping the expired IPNS pubsub record: %s", err)
+			}
+			continue
+		}
+		if err := psRouter.Subscribe(string(key)); err != nil {
+			log.Errorf("subscribing to the IPNS pubsub record: %s", err)
+		}
+	}
+}
+
 func autoRelayFeeder(cfgPeering config.Peering, peerChan chan<- peer.AddrInfo) fx.Option {
 	return fx.Invoke(func(lc fx.Lifecycle, h host.Host, dht *ddht.DHT) {
 		ctx, cancel := context.WithCancel(context.Background())
@@ -0,0 +1,35 @@
+package libp2p
+
+import (
+	"testing"
+
+	config "github.com/ipfs/kubo/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineDelegatedEndpoints(t *testing.T) {
+	cfg := &config.Config{}
+	require.Equal(t, defaultHTTPRouters, offlineDelegatedEndpoints(cfg))
+
+	// only the HTTP routers of custom routing are used, the DHT needs the
+	// libp2p host
+	cfg.Routing.Type = config.NewOptionalString("custom")
+	cfg.Routing.Routers = config.Routers{
+		"indexer": {Router: config.Router{
+			Type:       config.RouterTypeHTTP,
+			Parameters: &config.HTTPRouterParams{Endpoint: "https://indexer.example.net"},
+		}},
+		"dht": {Router: config.Router{
+			Type:       config.RouterTypeDHT,
+			Parameters: &config.DHTRouterParams{Mode: "client"},
+		}},
+		"empty": {Router: config.Router{
+			Type:       config.RouterTypeHTTP,
+			Parameters: &config.HTTPRouterParams{},
+		}},
+	}
+	require.Equal(t, []string{"https://indexer.example.net"}, offlineDelegatedEndpoints(cfg))
+
+	cfg.Routing.Routers = nil
+	require.Empty(t, offlineDelegatedEndpoints(cfg))
+}
@@ -99,7 +99,7 @@ func ConstructDefaultRouting(peerID string, addrs []string, privKey string) func
 }
 
 // constructDHTRouting is used when Routing.Type = "dht"
-func constructDHTRouting(mode dht.ModeOpt) func(
+func constructDHTRouting(mode dht.ModeOpt, opts ...dht.Option) func(
 	ctx context.Context,
 	host host.Host,
 	dstore datastore.Batching,
@@ -113,13 +113,15 @@ func constructDHTRouting(mode dht.ModeOpt) func(
 		validator record.Validator,
 		bootstrapPeers ...peer.AddrInfo,
 	) (routing.Routing, error) {
+		dhtOpts := append([]dht.Option{
+			dht.Concurrency(10),
+			dht.Mode(mode),
+			dht.Datastore(dstore),
+			dht.Validator(validator),
+		}, opts...)
 		return dual.New(
 			ctx, host,
-			dual.DHTOption(
-				dht.Concurrency(10),
-				dht.Mode(mode),
-				dht.Datastore(dstore),
-				dht.Validator(validator)),
+			dual.DHTOption(dhtOpts...),
 			dual.WanDHTOption(dht.BootstrapPeers(bootstrapPeers...)),
 		)
 	}
@@ -171,4 +173,8 @@ var (
 	DHTClientOption               = constructDHTRouting(dht.ModeClient)
 	DHTServerOption               = constructDHTRouting(dht.ModeServer)
 	NilRouterOption               = constructNilRouting
+
+	// BoundedDHTClientOption is the DHT client of the nodes with a memory
+	// budget, keeping half the peers in its routing table.
+	BoundedDHTClientOption = constructDHTRouting(dht.ModeClient, dht.BucketSize(10))
 )
@@ -1,11 +1,19 @@
 package libp2p
 
 import (
+	"context"
 	"fmt"
+	"reflect"
 
 	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/bwhistory"
+	"github.com/ipfs/kubo/core/dialstats"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/metrics"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	quic "github.com/libp2p/go-libp2p/p2p/transport/quic"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 	"github.com/libp2p/go-libp2p/p2p/transport/websocket"
@@ -14,20 +22,56 @@ import (
 	"go.uber.org/fx"
 )
 
-func Transports(tptConfig config.Transports) interface{} {
-	return func(pnet struct {
+func Transports(tptConfig config.Transports, dialing config.Dialing) interface{} {
+	return func(params struct {
 		fx.In
-		Fprint PNetFingerprint `optional:"true"`
+		Peerstore peerstore.Peerstore
+		Fprint    PNetFingerprint  `optional:"true"`
+		Keys      *PNetKeys        `optional:"true"`
+		Dials     *dialstats.Stats `optional:"true"`
 	}) (opts Libp2pOpts, err error) {
-		privateNetworkEnabled := pnet.Fprint != nil
+		privateNetworkEnabled := params.Fprint != nil
+		// with several keys, the connections are protected by the transports
+		// rather than by libp2p
+		multiKeys := params.Keys != nil && len(params.Keys.psks) > 1
+
+		// the limit of go-libp2p is global to the process
+		swarm.DefaultPerPeerRateLimit = int(dialing.MaxParallelDials.WithDefault(config.DefaultDialingMaxParallelDials))
+		var wrap func(transport.Transport) transport.Transport
+		if policy := newDialPolicy(params.Peerstore, dialing); policy != nil || params.Dials != nil {
+			wrap = func(t transport.Transport) transport.Transport {
+				return &dialingTransport{Transport: t, policy: policy, stats: params.Dials}
+			}
+		}
+		transportOpt := func(constructor interface{}, tptOpts ...interface{}) libp2p.Option {
+			return libp2p.Transport(wrapTransport(constructor, wrap), tptOpts...)
+		}
+
+		if multiKeys && tptConfig.Network.Relay.WithDefault(true) {
+			return opts, fmt.Errorf(
+				"Relay transport does not support several swarm keys, please disable Swarm.Transports.Network.Relay",
+			)
+		}
 
 		if tptConfig.Network.TCP.WithDefault(true) {
 			// TODO(9290): Make WithMetrics configurable
-			opts.Opts = append(opts.Opts, libp2p.Transport(tcp.NewTCPTransport, tcp.WithMetrics()))
+			if multiKeys {
+				opts.Opts = append(opts.Opts, transportOpt(func(u transport.Upgrader, rcmgr network.ResourceManager) (*tcp.TcpTransport, error) {
+					return tcp.NewTCPTransport(params.Keys.upgrader(u), rcmgr, tcp.WithMetrics())
+				}))
+			} else {
+				opts.Opts = append(opts.Opts, transportOpt(tcp.NewTCPTransport, tcp.WithMetrics()))
+			}
 		}
 
 		if tptConfig.Network.Websocket.WithDefault(true) {
-			opts.Opts = append(opts.Opts, libp2p.Transport(websocket.New))
+			if multiKeys {
+				opts.Opts = append(opts.Opts, transportOpt(func(u transport.Upgrader, rcmgr network.ResourceManager) (*websocket.WebsocketTransport, error) {
+					return websocket.New(params.Keys.upgrader(u), rcmgr)
+				}))
+			} else {
+				opts.Opts = append(opts.Opts, transportOpt(websocket.New))
+			}
 		}
 
 		if tptConfig.Network.QUIC.WithDefault(!privateNetworkEnabled) {
@@ -36,7 +80,7 @@ func Transports(tptConfig config.Transports) interface{} {
 					"QUIC transport does not support private networks, please disable Swarm.Transports.Network.QUIC",
 				)
 			}
-			opts.Opts = append(opts.Opts, libp2p.Transport(quic.NewTransport))
+			opts.Opts = append(opts.Opts, transportOpt(quic.NewTransport))
 		}
 
 		if tptConfig.Network.WebTransport.WithDefault(!privateNetworkEnabled) {
@@ -45,15 +89,75 @@ func Transports(tptConfig config.Transports) interface{} {
 					"WebTransport transport does not support private networks, please disable Swarm.Transports.Network.WebTransport",
 				)
 			}
-			opts.Opts = append(opts.Opts, libp2p.Transport(webtransport.New))
+			opts.Opts = append(opts.Opts, transportOpt(webtransport.New))
 		}
 
 		return opts, nil
 	}
 }
 
+// DialStats counts the outcomes and the latencies of the dials of the
+// transports, see dialingTransport.
+func DialStats() (*dialstats.Stats, error) {
+	return dialstats.New()
+}
+
+// wrapTransport wraps constructor, a transport constructor of
+// libp2p.Transport, so that its transport is wrapped with wrap. The wrapper
+// takes the parameters of constructor, for fx to inject them.
+func wrapTransport(constructor interface{}, wrap func(transport.Transport) transport.Transport) interface{} {
+	if wrap == nil {
+		return constructor
+	}
+	fn := reflect.ValueOf(constructor)
+	typ := fn.Type()
+	in := make([]reflect.Type, typ.NumIn())
+	for i := range in {
+		in[i] = typ.In(i)
+	}
+	tptType := reflect.TypeOf((*transport.Transport)(nil)).Elem()
+	errType := reflect.TypeOf((*error)(nil)).Elem()
+	wrapper := reflect.FuncOf(in, []reflect.Type{tptType, errType}, typ.IsVariadic())
+	return reflect.MakeFunc(wrapper, func(args []reflect.Value) []reflect.Value {
+		var res []reflect.Value
+		if typ.IsVariadic() {
+			res = fn.CallSlice(args)
+		} else {
+			res = fn.Call(args)
+		}
+		if len(res) > 1 && !res[1].IsNil() {
+			return []reflect.Value{reflect.Zero(tptType), res[1]}
+		}
+		tpt := wrap(res[0].Interface().(transport.Transport))
+		return []reflect.Value{reflect.ValueOf(&tpt).Elem(), reflect.Zero(errType)}
+	}).Interface()
+}
+
 func BandwidthCounter() (opts Libp2pOpts, reporter *metrics.BandwidthCounter) {
 	reporter = metrics.NewBandwidthCounter()
 	opts.Opts = append(opts.Opts, libp2p.BandwidthReporter(reporter))
 	return opts, reporter
 }
+
+// BandwidthHistory keeps a rolling history of the bandwidth metrics
+func BandwidthHistory(cfg config.BandwidthHistory) interface{} {
+	return func(lc fx.Lifecycle, reporter *metrics.BandwidthCounter) *bwhistory.History {
+		h := bwhistory.New(
+			reporter,
+			cfg.Interval.WithDefault(config.DefaultBandwidthHistoryInterval),
+			cfg.Retention.WithDefault(config.DefaultBandwidthHistoryRetention),
+			int(cfg.MaxPeers.WithDefault(config.DefaultBandwidthHistoryMaxPeers)),
+		)
+		lc.Append(fx.Hook{
+			OnStart: func(_ context.Context) error {
+				h.Start()
+				return nil
+			},
+			OnStop: func(_ context.Context) error {
+				h.Stop()
+				return nil
+			},
+		})
+		return h
+	}
+}
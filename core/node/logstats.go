@@ -0,0 +1,38 @@
+package node
+
+import (
+	"context"
+
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/logstats"
+)
+
+// LogStats counts the warnings and the errors logged by each subsystem, for
+// 'ipfs diag errors' and the metrics
+func LogStats(lc fx.Lifecycle) (*logstats.Stats, error) {
+	s, err := logstats.New()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := logstats.Pipe()
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(done)
+				if err := s.Read(pipe); err != nil {
+					logger.Errorf("reading the log events failed: %s", err)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			err := pipe.Close()
+			<-done
+			return err
+		},
+	})
+	return s, nil
+}
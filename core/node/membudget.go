@@ -0,0 +1,79 @@
+package node
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/node/helpers"
+)
+
+const (
+	// memoryBudgetInterval is the period of the checks of the memory used
+	// against the budget.
+	memoryBudgetInterval = 10 * time.Second
+
+	// memoryBudgetHeapFraction is the fraction of the budget the heap may
+	// grow to before it is collected.
+	memoryBudgetHeapFraction = 0.75
+
+	minBudgetGCPercent = 10
+	maxBudgetGCPercent = 100
+)
+
+// MemoryBudget keeps the daemon within the memory budget, in bytes. The
+// garbage collector is periodically tuned to collect the heap before it
+// grows past a fraction of the budget, and the memory is returned to the
+// operating system when the budget is exceeded.
+func MemoryBudget(budget uint64) func(helpers.MetricsCtx, fx.Lifecycle) {
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle) {
+		ctx := helpers.LifecycleCtx(mctx, lc)
+		setMemoryLimit(budget)
+		go enforceMemoryBudget(ctx, budget)
+	}
+}
+
+func enforceMemoryBudget(ctx context.Context, budget uint64) {
+	gcPercent := debug.SetGCPercent(maxBudgetGCPercent)
+	defer debug.SetGCPercent(gcPercent)
+
+	ticker := time.NewTicker(memoryBudgetInterval)
+	defer ticker.Stop()
+
+	percent := maxBudgetGCPercent
+	var ms runtime.MemStats
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		runtime.ReadMemStats(&ms)
+		if used := ms.Sys - ms.HeapReleased; used > budget {
+			logger.Warnf("using %s of memory, over the budget of %s, freeing memory", humanize.IBytes(used), humanize.IBytes(budget))
+			debug.FreeOSMemory()
+			runtime.ReadMemStats(&ms)
+		}
+
+		// the live heap after the last collection, from its next goal
+		live := float64(ms.NextGC) / (1 + float64(percent)/100)
+		if live <= 0 {
+			continue
+		}
+		p := int((memoryBudgetHeapFraction*float64(budget)/live - 1) * 100)
+		if p < minBudgetGCPercent {
+			p = minBudgetGCPercent
+		} else if p > maxBudgetGCPercent {
+			p = maxBudgetGCPercent
+		}
+		if p != percent {
+			percent = p
+			debug.SetGCPercent(percent)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !go1.19
+
+package node
+
+// setMemoryLimit does nothing before Go 1.19, which has no soft memory limit:
+// the budget is only enforced by tuning the garbage collector.
+func setMemoryLimit(budget uint64) {}
@@ -0,0 +1,10 @@
+//go:build go1.19
+
+package node
+
+import "runtime/debug"
+
+// setMemoryLimit sets the soft memory limit of the runtime to the budget.
+func setMemoryLimit(budget uint64) {
+	debug.SetMemoryLimit(int64(budget))
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-namesys"
+	path "github.com/ipfs/go-path"
+	opts "github.com/ipfs/interface-go-ipfs-core/options/namesys"
+)
+
+// NameResolver resolves the names of an alternative naming system, such as
+// ENS or Handshake, in /ipns paths and in the Host header of gateway
+// requests.
+type NameResolver interface {
+	// Resolve resolves a name, e.g. "vitalik.eth", to an /ipfs or /ipns
+	// path. An /ipns path is resolved further by the name system.
+	Resolve(ctx context.Context, name string) (path.Path, error)
+}
+
+var (
+	nameResolversLk sync.RWMutex
+	nameResolvers   = make(map[string]NameResolver)
+)
+
+// AddNameResolver registers the resolver of the names with the domain suffix,
+// e.g. "eth" for "vitalik.eth". The resolver with the longest suffix matching
+// a name resolves it, instead of DNSLink.
+func AddNameResolver(suffix string, r NameResolver) error {
+	suffix = strings.Trim(strings.ToLower(suffix), ".")
+	if suffix == "" {
+		return fmt.Errorf("empty name resolver suffix")
+	}
+
+	nameResolversLk.Lock()
+	defer nameResolversLk.Unlock()
+	if _, ok := nameResolvers[suffix]; ok {
+		return fmt.Errorf("name resolver for %q already registered", suffix)
+	}
+	nameResolvers[suffix] = r
+	return nil
+}
+
+// nameResolver returns the resolver registered for the name, if any.
+func nameResolver(name string) (NameResolver, bool) {
+	nameResolversLk.RLock()
+	defer nameResolversLk.RUnlock()
+	if len(nameResolvers) == 0 {
+		return nil, false
+	}
+
+	// try the suffixes from the longest
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for {
+		if r, ok := nameResolvers[name]; ok {
+			return r, true
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return nil, false
+		}
+		name = name[i+1:]
+	}
+}
+
+// WithNameResolvers returns a name system resolving the names with a
+// registered NameResolver, and the others with ns. The names resolved by a
+// NameResolver aren't cached by ns.
+func WithNameResolvers(ns namesys.NameSystem) namesys.NameSystem {
+	return &pluggableNameSystem{NameSystem: ns}
+}
+
+type pluggableNameSystem struct {
+	namesys.NameSystem
+}
+
+func (ns *pluggableNameSystem) Resolve(ctx context.Context, name string, options ...opts.ResolveOpt) (path.Path, error) {
+	// "/ipns/<name>/<rest>", or "<name>/<rest>"
+	segments := strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)
+	r, ok := nameResolver(segments[0])
+	if !ok {
+		return ns.NameSystem.Resolve(ctx, name, options...)
+	}
+
+	p, err := r.Resolve(ctx, segments[0])
+	if err != nil {
+		return "", fmt.Errorf("could not resolve name %q: %w", segments[0], err)
+	}
+	if len(segments) == 2 {
+		p = path.Path(strings.TrimSuffix(p.String(), "/") + "/" + segments[1])
+	}
+	// the name system resolves /ipns names, without the resolvers to avoid
+	// loops between them
+	if strings.HasPrefix(p.String(), "/ipns/") {
+		return ns.NameSystem.Resolve(ctx, p.String(), options...)
+	}
+	return p, nil
+}
+
+func (ns *pluggableNameSystem) ResolveAsync(ctx context.Context, name string, options ...opts.ResolveOpt) <-chan namesys.Result {
+	segments := strings.SplitN(strings.TrimPrefix(name, "/ipns/"), "/", 2)
+	if _, ok := nameResolver(segments[0]); !ok {
+		return ns.NameSystem.ResolveAsync(ctx, name, options...)
+	}
+
+	out := make(chan namesys.Result, 1)
+	go func() {
+		defer close(out)
+		p, err := ns.Resolve(ctx, name, options...)
+		out <- namesys.Result{Path: p, Err: err}
+	}()
+	return out
+}
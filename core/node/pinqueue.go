@@ -0,0 +1,68 @@
+package node
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	provider "github.com/ipfs/go-ipfs-provider"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"go.uber.org/fx"
+
+	"github.com/ipfs/kubo/core/events"
+	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/pinqueue"
+	"github.com/ipfs/kubo/core/quota"
+	"github.com/ipfs/kubo/repo"
+)
+
+// PinQueue loads the background pin jobs, which are started by the daemon.
+// The DAG of a job is fetched before taking the pin lock, so that the GC isn't
+// blocked for the whole fetch.
+func PinQueue(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo, pinner pin.Pinner, dag ipld.DAGService, locker bstore.GCLocker, prov provider.System, quotas *quota.Accountant, bus *events.Bus) (*pinqueue.Queue, error) {
+	pinFn := func(ctx context.Context, c cid.Cid, recursive bool, progress *merkledag.ProgressTracker) (err error) {
+		defer func() {
+			if err != nil && ctx.Err() == nil {
+				bus.Emit(events.PinFailed, map[string]interface{}{
+					"Path":  "/ipfs/" + c.String(),
+					"Error": err.Error(),
+				})
+			}
+		}()
+
+		if err := quotas.Check(ctx, quota.Pins); err != nil {
+			return fmt.Errorf("pin: %w", err)
+		}
+		if recursive {
+			if err := merkledag.FetchGraph(progress.DeriveContext(ctx), c, dag); err != nil {
+				return fmt.Errorf("pin: %w", err)
+			}
+		}
+		nd, err := dag.Get(ctx, c)
+		if err != nil {
+			return fmt.Errorf("pin: %w", err)
+		}
+
+		defer locker.PinLock(ctx).Unlock(ctx)
+		if err := pinner.Pin(ctx, nd, recursive); err != nil {
+			return fmt.Errorf("pin: %w", err)
+		}
+		if err := prov.Provide(c); err != nil {
+			return err
+		}
+		if err := pinner.Flush(ctx); err != nil {
+			return err
+		}
+
+		bus.Emit(events.PinAdded, map[string]interface{}{
+			"Cid":       c.String(),
+			"Recursive": recursive,
+		})
+		return nil
+	}
+
+	return pinqueue.New(helpers.LifecycleCtx(mctx, lc), repo.Datastore(), pinFn, pinqueue.DefaultMaxActive)
+}
@@ -5,15 +5,19 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-fetcher"
 	pin "github.com/ipfs/go-ipfs-pinner"
 	provider "github.com/ipfs/go-ipfs-provider"
 	"github.com/ipfs/go-ipfs-provider/batched"
 	q "github.com/ipfs/go-ipfs-provider/queue"
 	"github.com/ipfs/go-ipfs-provider/simple"
+	"github.com/multiformats/go-multihash"
 	"go.uber.org/fx"
 
+	"github.com/ipfs/kubo/core/events"
 	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/providestats"
 	"github.com/ipfs/kubo/repo"
 	irouting "github.com/ipfs/kubo/routing"
 )
@@ -26,14 +30,15 @@ func ProviderQueue(mctx helpers.MetricsCtx, lc fx.Lifecycle, repo repo.Repo) (*q
 }
 
 // SimpleProvider creates new record provider
-func SimpleProvider(mctx helpers.MetricsCtx, lc fx.Lifecycle, queue *q.Queue, rt irouting.ProvideManyRouter) provider.Provider {
-	return simple.NewProvider(helpers.LifecycleCtx(mctx, lc), queue, rt)
+func SimpleProvider(mctx helpers.MetricsCtx, lc fx.Lifecycle, queue *q.Queue, rt irouting.ProvideManyRouter, stats *providestats.Tracker) provider.Provider {
+	return simple.NewProvider(helpers.LifecycleCtx(mctx, lc), queue, trackedRouter{rt, stats})
 }
 
 // SimpleReprovider creates new reprovider
 func SimpleReprovider(reproviderInterval time.Duration) interface{} {
-	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt irouting.ProvideManyRouter, keyProvider simple.KeyChanFunc) (provider.Reprovider, error) {
-		return simple.NewReprovider(helpers.LifecycleCtx(mctx, lc), reproviderInterval, rt, keyProvider), nil
+	return func(mctx helpers.MetricsCtx, lc fx.Lifecycle, rt irouting.ProvideManyRouter, keyProvider simple.KeyChanFunc, bus *events.Bus, stats *providestats.Tracker, pinning pin.Pinner) (provider.Reprovider, error) {
+		keyProvider = reprovideEvents(bus, trackReprovides(stats, pinning, keyProvider))
+		return simple.NewReprovider(helpers.LifecycleCtx(mctx, lc), reproviderInterval, trackedRouter{rt, stats}, keyProvider), nil
 	}
 }
 
@@ -60,11 +65,11 @@ func SimpleProviderSys(isOnline bool) interface{} {
 
 // BatchedProviderSys creates new provider system
 func BatchedProviderSys(isOnline bool, reprovideInterval time.Duration) interface{} {
-	return func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, q *q.Queue, keyProvider simple.KeyChanFunc, repo repo.Repo) (provider.System, error) {
-		sys, err := batched.New(cr, q,
+	return func(lc fx.Lifecycle, cr irouting.ProvideManyRouter, q *q.Queue, keyProvider simple.KeyChanFunc, repo repo.Repo, bus *events.Bus, stats *providestats.Tracker, pinning pin.Pinner) (provider.System, error) {
+		sys, err := batched.New(trackedRouter{cr, stats}, q,
 			batched.ReproviderInterval(reprovideInterval),
 			batched.Datastore(repo.Datastore()),
-			batched.KeyProvider(keyProvider))
+			batched.KeyProvider(reprovideEvents(bus, trackReprovides(stats, pinning, keyProvider))))
 		if err != nil {
 			return nil, err
 		}
@@ -131,6 +136,7 @@ func SimpleProviders(reprovideStrategy string, reproviderInterval time.Duration)
 
 	return fx.Options(
 		fx.Provide(ProviderQueue),
+		fx.Provide(ProvideStats(reproviderInterval)),
 		fx.Provide(SimpleProvider),
 		keyProvider,
 		fx.Provide(SimpleReprovider(reproviderInterval)),
@@ -147,3 +153,90 @@ func pinnedProviderStrategy(onlyRoots bool) interface{} {
 		return simple.NewPinnedProvider(onlyRoots, in.Pinner, in.IPLDFetcher)
 	}
 }
+
+// ProvideStats tracks the announcements of the pinned roots, reprovided every
+// reprovideInterval
+func ProvideStats(reprovideInterval time.Duration) func() *providestats.Tracker {
+	return func() *providestats.Tracker {
+		return providestats.New(reprovideInterval)
+	}
+}
+
+// trackedRouter records the announcements of its router in a tracker.
+type trackedRouter struct {
+	irouting.ProvideManyRouter
+	stats *providestats.Tracker
+}
+
+func (r trackedRouter) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	start := time.Now()
+	err := r.ProvideManyRouter.Provide(ctx, c, announce)
+	if announce {
+		r.stats.Record([]multihash.Multihash{c.Hash()}, start, err)
+	}
+	return err
+}
+
+func (r trackedRouter) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	start := time.Now()
+	err := r.ProvideManyRouter.ProvideMany(ctx, keys)
+	r.stats.Record(keys, start, err)
+	return err
+}
+
+// trackReprovides records the runs of the reprovider in the tracker, which
+// watches the pinned roots at the start of each.
+func trackReprovides(stats *providestats.Tracker, pinning pin.Pinner, keyProvider simple.KeyChanFunc) simple.KeyChanFunc {
+	return func(ctx context.Context) (<-chan cid.Cid, error) {
+		start := time.Now()
+		if err := watchPinnedRoots(ctx, stats, pinning); err != nil {
+			logger.Warnf("failed to list the pinned roots of the provide stats: %s", err)
+		}
+
+		in, err := keyProvider(ctx)
+		if err != nil {
+			stats.Reprovided(providestats.Reprovide{Start: start, Duration: time.Since(start), Error: err.Error()})
+			return nil, err
+		}
+
+		out := make(chan cid.Cid)
+		go func() {
+			defer close(out)
+			var count int
+			defer func() {
+				r := providestats.Reprovide{Start: start, Duration: time.Since(start), Keys: count}
+				if err := ctx.Err(); err != nil {
+					r.Error = err.Error()
+				}
+				stats.Reprovided(r)
+			}()
+			for c := range in {
+				select {
+				case out <- c:
+					count++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// watchPinnedRoots makes the tracker watch the recursive and direct pins.
+func watchPinnedRoots(ctx context.Context, stats *providestats.Tracker, pinning pin.Pinner) error {
+	recursive, err := pinning.RecursiveKeys(ctx)
+	if err != nil {
+		return err
+	}
+	direct, err := pinning.DirectKeys(ctx)
+	if err != nil {
+		return err
+	}
+	roots := make([]multihash.Multihash, 0, len(recursive)+len(direct))
+	for _, c := range append(recursive, direct...) {
+		roots = append(roots, c.Hash())
+	}
+	stats.Watch(roots)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/ipfs/go-filestore"
 	"github.com/ipfs/kubo/core/node/helpers"
+	"github.com/ipfs/kubo/core/quota"
 	"github.com/ipfs/kubo/repo"
 	"github.com/ipfs/kubo/thirdparty/verifbs"
 )
@@ -25,8 +26,9 @@ func Datastore(repo repo.Repo) datastore.Datastore {
 // BaseBlocks is the lower level blockstore without GC or Filestore layers
 type BaseBlocks blockstore.Blockstore
 
-// BaseBlockstoreCtor creates cached blockstore backed by the provided datastore
-func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
+// BaseBlockstoreCtor creates cached blockstore backed by the provided datastore.
+// Accesses to the blocks are recorded in access unless nil.
+func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead bool, access *quota.AccessTimes) func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
 	return func(mctx helpers.MetricsCtx, repo repo.Repo, lc fx.Lifecycle) (bs BaseBlocks, err error) {
 		// hash security
 		bs = blockstore.NewBlockstore(repo.Datastore())
@@ -45,6 +47,10 @@ func BaseBlockstoreCtor(cacheOpts blockstore.CacheOpts, nilRepo bool, hashOnRead
 			bs.HashOnRead(true)
 		}
 
+		if access != nil {
+			bs = quota.TrackAccess(bs, access)
+		}
+
 		return
 	}
 }
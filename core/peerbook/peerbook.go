@@ -0,0 +1,297 @@
+// Package peerbook remembers the addresses of the peers the node dialed,
+// along with the history of their dials, so that they can be redialed with
+// their proven addresses after a restart.
+package peerbook
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p/core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+var log = logging.Logger("peerbook")
+
+// Prefix is the datastore key under which the records are persisted, one
+// key per peer.
+var Prefix = datastore.NewKey("/local/peerbook")
+
+// scoreHalfLife is the time after which the score of an address that wasn't
+// dialed successfully is halved.
+const scoreHalfLife = 7 * 24 * time.Hour
+
+// AddrRecord is the dial history of one address of a peer.
+type AddrRecord struct {
+	Addr        string
+	Successes   uint64
+	Failures    uint64
+	Latency     time.Duration `json:",omitempty"` // moving average
+	LastSuccess time.Time
+}
+
+// Score rates the address: higher is better. Addresses that were dialed
+// successfully, often, recently and with a low latency score higher.
+func (r *AddrRecord) Score(now time.Time) float64 {
+	if r.Successes == 0 {
+		return 0
+	}
+	// a failure is assumed, so that a single success isn't a perfect score
+	s := float64(r.Successes) / float64(r.Successes+r.Failures+1)
+	if r.Latency > 0 {
+		s /= 1 + r.Latency.Seconds()
+	}
+	age := now.Sub(r.LastSuccess)
+	return s * math.Exp2(-float64(age)/float64(scoreHalfLife))
+}
+
+type peerRecord struct {
+	Addrs []*AddrRecord
+}
+
+// Book holds the address records of up to maxPeers peers, and persists them
+// to a datastore.
+type Book struct {
+	ds       datastore.Datastore
+	maxPeers int
+
+	mu      sync.Mutex
+	peers   map[peer.ID]map[string]*AddrRecord
+	dirty   map[peer.ID]struct{}
+	removed map[peer.ID]struct{}
+}
+
+// New returns an empty Book persisted to ds. Call Load to read the records
+// persisted earlier.
+func New(ds datastore.Datastore, maxPeers int) *Book {
+	return &Book{
+		ds:       ds,
+		maxPeers: maxPeers,
+		peers:    make(map[peer.ID]map[string]*AddrRecord),
+		dirty:    make(map[peer.ID]struct{}),
+		removed:  make(map[peer.ID]struct{}),
+	}
+}
+
+// Load reads the persisted records.
+func (b *Book) Load(ctx context.Context) error {
+	res, err := b.ds.Query(ctx, query.Query{Prefix: Prefix.String()})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for r := range res.Next() {
+		if r.Error != nil {
+			return r.Error
+		}
+		p, err := peer.Decode(datastore.RawKey(r.Key).BaseNamespace())
+		if err != nil {
+			log.Warnf("ignoring record %s: %s", r.Key, err)
+			continue
+		}
+		var rec peerRecord
+		if err := json.Unmarshal(r.Value, &rec); err != nil {
+			log.Warnf("ignoring record of %s: %s", p, err)
+			continue
+		}
+		addrs := make(map[string]*AddrRecord, len(rec.Addrs))
+		for _, a := range rec.Addrs {
+			addrs[a.Addr] = a
+		}
+		b.peers[p] = addrs
+	}
+	return nil
+}
+
+func (b *Book) record(p peer.ID, a ma.Multiaddr) *AddrRecord {
+	addrs, ok := b.peers[p]
+	if !ok {
+		addrs = make(map[string]*AddrRecord)
+		b.peers[p] = addrs
+	}
+	r, ok := addrs[a.String()]
+	if !ok {
+		r = &AddrRecord{Addr: a.String()}
+		addrs[r.Addr] = r
+	}
+	b.dirty[p] = struct{}{}
+	delete(b.removed, p)
+	return r
+}
+
+// Succeeded records a successful dial of p at a.
+func (b *Book) Succeeded(p peer.ID, a ma.Multiaddr, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	r := b.record(p, a)
+	r.Successes++
+	r.LastSuccess = now
+}
+
+// Failed records a failed dial of p at a. Failures are only recorded for
+// known addresses: addresses that never worked aren't worth remembering.
+func (b *Book) Failed(p peer.ID, a ma.Multiaddr) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.peers[p][a.String()]; !ok {
+		return
+	}
+	b.record(p, a).Failures++
+}
+
+// RecordLatency records the latency of a connection to p at a.
+func (b *Book) RecordLatency(p peer.ID, a ma.Multiaddr, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.peers[p][a.String()]; !ok {
+		return
+	}
+	r := b.record(p, a)
+	if r.Latency == 0 {
+		r.Latency = d
+	} else {
+		r.Latency = (7*r.Latency + d) / 8
+	}
+}
+
+// sortedAddrs returns the addresses of p with a positive score, best first.
+func (b *Book) sortedAddrs(p peer.ID, now time.Time) ([]*AddrRecord, float64) {
+	var addrs []*AddrRecord
+	for _, r := range b.peers[p] {
+		if r.Score(now) > 0 {
+			addrs = append(addrs, r)
+		}
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].Score(now) > addrs[j].Score(now)
+	})
+	if len(addrs) == 0 {
+		return nil, 0
+	}
+	return addrs, addrs[0].Score(now)
+}
+
+// Addrs returns the proven addresses of p, best first.
+func (b *Book) Addrs(p peer.ID) []ma.Multiaddr {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	recs, _ := b.sortedAddrs(p, time.Now())
+	return toMultiaddrs(recs)
+}
+
+// Peers returns up to n peers with their proven addresses, best peers first.
+// A peer scores as its best address. n < 0 returns all the peers.
+func (b *Book) Peers(n int) []peer.AddrInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+
+	type scored struct {
+		info  peer.AddrInfo
+		score float64
+	}
+	var peers []scored
+	for p := range b.peers {
+		recs, score := b.sortedAddrs(p, now)
+		if score > 0 {
+			peers = append(peers, scored{peer.AddrInfo{ID: p, Addrs: toMultiaddrs(recs)}, score})
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].score > peers[j].score
+	})
+	if n >= 0 && len(peers) > n {
+		peers = peers[:n]
+	}
+	out := make([]peer.AddrInfo, len(peers))
+	for i, s := range peers {
+		out[i] = s.info
+	}
+	return out
+}
+
+func toMultiaddrs(recs []*AddrRecord) []ma.Multiaddr {
+	addrs := make([]ma.Multiaddr, 0, len(recs))
+	for _, r := range recs {
+		a, err := ma.NewMultiaddr(r.Addr)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, a)
+	}
+	return addrs
+}
+
+// prune forgets the worst peers beyond maxPeers.
+func (b *Book) prune(now time.Time) {
+	if len(b.peers) <= b.maxPeers {
+		return
+	}
+	type scored struct {
+		id    peer.ID
+		score float64
+	}
+	peers := make([]scored, 0, len(b.peers))
+	for p := range b.peers {
+		_, score := b.sortedAddrs(p, now)
+		peers = append(peers, scored{p, score})
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].score > peers[j].score
+	})
+	for _, s := range peers[b.maxPeers:] {
+		delete(b.peers, s.id)
+		delete(b.dirty, s.id)
+		b.removed[s.id] = struct{}{}
+	}
+}
+
+// Flush persists the records changed since the last flush, forgetting the
+// worst peers beyond the maximum number of peers.
+func (b *Book) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	b.prune(time.Now())
+	puts := make(map[datastore.Key][]byte, len(b.dirty))
+	for p := range b.dirty {
+		var rec peerRecord
+		for _, r := range b.peers[p] {
+			r := *r
+			rec.Addrs = append(rec.Addrs, &r)
+		}
+		v, err := json.Marshal(&rec)
+		if err != nil {
+			b.mu.Unlock()
+			return err
+		}
+		puts[Prefix.ChildString(p.String())] = v
+	}
+	var deletes []datastore.Key
+	for p := range b.removed {
+		deletes = append(deletes, Prefix.ChildString(p.String()))
+	}
+	b.dirty = make(map[peer.ID]struct{})
+	b.removed = make(map[peer.ID]struct{})
+	b.mu.Unlock()
+
+	for k, v := range puts {
+		if err := b.ds.Put(ctx, k, v); err != nil {
+			return err
+		}
+	}
+	for _, k := range deletes {
+		if err := b.ds.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return b.ds.Sync(ctx, Prefix)
+}
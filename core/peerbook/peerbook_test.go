@@ -0,0 +1,67 @@
+package peerbook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"github.com/libp2p/go-libp2p/core/test"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestBook(t *testing.T) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	now := time.Now()
+
+	fast := ma.StringCast("/ip4/1.2.3.4/udp/4001/quic-v1")
+	slow := ma.StringCast("/ip4/1.2.3.4/tcp/4001")
+	flaky := ma.StringCast("/ip4/5.6.7.8/tcp/4001")
+	unproven := ma.StringCast("/ip4/9.9.9.9/tcp/4001")
+
+	b := New(ds, 1)
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	b.Succeeded(p1, fast, now)
+	b.RecordLatency(p1, fast, 10*time.Millisecond)
+	b.Succeeded(p1, slow, now)
+	b.RecordLatency(p1, slow, time.Second)
+	b.Failed(p1, unproven)
+	b.Succeeded(p2, flaky, now.Add(-30*24*time.Hour))
+	b.Failed(p2, flaky)
+
+	addrs := b.Addrs(p1)
+	if len(addrs) != 2 || !addrs[0].Equal(fast) || !addrs[1].Equal(slow) {
+		t.Fatalf("expected the fast then slow address, got %s", addrs)
+	}
+
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the best peer is kept
+	b = New(ds, 1)
+	if err := b.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	peers := b.Peers(-1)
+	if len(peers) != 1 || peers[0].ID != p1 {
+		t.Fatalf("expected peer1 to be kept, got %v", peers)
+	}
+	if len(peers[0].Addrs) != 2 || !peers[0].Addrs[0].Equal(fast) {
+		t.Fatalf("expected the persisted addresses of peer1, got %s", peers[0].Addrs)
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Now()
+	recent := &AddrRecord{Successes: 3, LastSuccess: now}
+	old := &AddrRecord{Successes: 3, LastSuccess: now.Add(-scoreHalfLife)}
+	if got, want := old.Score(now), recent.Score(now)/2; got != want {
+		t.Fatalf("expected the score to halve after %s, got %f, want %f", scoreHalfLife, got, want)
+	}
+	if (&AddrRecord{Failures: 3}).Score(now) != 0 {
+		t.Fatal("expected addresses that never worked to score 0")
+	}
+}
@@ -0,0 +1,103 @@
+// Package pinlabels labels the pinned roots, and scopes the providing of the
+// content labeled private to the LAN DHT, so that the existence of internal
+// content doesn't leak to the public DHT nor the indexers.
+package pinlabels
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+)
+
+var dsPrefix = datastore.NewKey("/local/pinlabels")
+
+// Label is the label of a root.
+type Label struct {
+	Cid   cid.Cid
+	Label string
+}
+
+// Store stores the labels of the roots in the datastore.
+type Store struct {
+	ds datastore.Datastore
+}
+
+// NewStore returns the Store of the labels in ds.
+func NewStore(ds datastore.Datastore) *Store {
+	return &Store{ds: ds}
+}
+
+func labelKey(c cid.Cid) datastore.Key {
+	return dsPrefix.Child(dshelp.NewKeyFromBinary(c.Bytes()))
+}
+
+// ValidateLabel checks the label is a non-empty word.
+func ValidateLabel(label string) error {
+	if label == "" || strings.ContainsAny(label, " \t\r\n/") {
+		return fmt.Errorf("invalid pin label %q: must be a non-empty word without '/'", label)
+	}
+	return nil
+}
+
+// Set labels the root c.
+func (s *Store) Set(ctx context.Context, c cid.Cid, label string) error {
+	if err := ValidateLabel(label); err != nil {
+		return err
+	}
+	return s.ds.Put(ctx, labelKey(c), []byte(label))
+}
+
+// Remove removes the label of the root c, if any.
+func (s *Store) Remove(ctx context.Context, c cid.Cid) error {
+	return s.ds.Delete(ctx, labelKey(c))
+}
+
+// Get returns the label of the root c, "" if none.
+func (s *Store) Get(ctx context.Context, c cid.Cid) (string, error) {
+	v, err := s.ds.Get(ctx, labelKey(c))
+	if err == datastore.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// List returns the labels of the roots, sorted by label.
+func (s *Store) List(ctx context.Context) ([]Label, error) {
+	res, err := s.ds.Query(ctx, query.Query{Prefix: dsPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var labels []Label
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		b, err := dshelp.BinaryFromDsKey(datastore.NewKey(datastore.RawKey(r.Key).BaseNamespace()))
+		if err != nil {
+			return nil, err
+		}
+		c, err := cid.Cast(b)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, Label{Cid: c, Label: string(r.Value)})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Label != labels[j].Label {
+			return labels[i].Label < labels[j].Label
+		}
+		return labels[i].Cid.KeyString() < labels[j].Cid.KeyString()
+	})
+	return labels, nil
+}
@@ -0,0 +1,76 @@
+package pinlabels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+)
+
+func TestScope(t *testing.T) {
+	ctx := context.Background()
+	dserv := mdtest.Mock()
+
+	shared := dag.NodeWithData([]byte("shared"))
+	leaf := dag.NodeWithData([]byte("internal leaf"))
+	internal := dag.NodeWithData([]byte("internal"))
+	for _, child := range []*dag.ProtoNode{shared, leaf} {
+		if err := internal.AddNodeLink(string(child.RawData()), child); err != nil {
+			t.Fatal(err)
+		}
+	}
+	public := dag.NodeWithData([]byte("public"))
+	if err := public.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	// missing is labeled before being fetched
+	missing := dag.NodeWithData([]byte("missing"))
+	for _, nd := range []*dag.ProtoNode{shared, leaf, internal, public} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := NewStore(dssync.MutexWrap(datastore.NewMapDatastore()))
+	for c, label := range map[*dag.ProtoNode]string{internal: "internal", public: "docs", missing: "internal"} {
+		if err := store.Set(ctx, c.Cid(), label); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Set(ctx, public.Cid(), "in valid"); err == nil {
+		t.Error("expected an invalid label to be rejected")
+	}
+	if label, err := store.Get(ctx, public.Cid()); err != nil || label != "docs" {
+		t.Errorf("expected the label docs, got %q, %v", label, err)
+	}
+	labels, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels[0].Label != "docs" || !labels[0].Cid.Equals(public.Cid()) {
+		t.Errorf("unexpected labels %v", labels)
+	}
+
+	s := NewScope(store, dserv, []string{"internal"})
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for nd, private := range map[*dag.ProtoNode]bool{internal: true, leaf: true, shared: true, missing: true, public: false} {
+		if s.Private(nd.Cid().Hash()) != private {
+			t.Errorf("expected %q private to be %v", nd.Data(), private)
+		}
+	}
+
+	if err := store.Remove(ctx, internal.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if s.Private(leaf.Cid().Hash()) || s.Len() != 1 {
+		t.Errorf("expected only the missing root to stay private, got %d", s.Len())
+	}
+}
@@ -0,0 +1,145 @@
+package pinlabels
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/multiformats/go-multihash"
+)
+
+var log = logging.Logger("pinlabels")
+
+// refreshInterval is how often the private content is listed again, to
+// include the blocks fetched since under the private roots.
+const refreshInterval = 10 * time.Minute
+
+// Scope tells the private content: the roots with a private label, and the
+// blocks of their DAGs in the blockstore. The blocks shared with public DAGs
+// are private too.
+type Scope struct {
+	store  *Store
+	ng     ipld.NodeGetter
+	labels map[string]struct{}
+
+	mu      sync.RWMutex
+	private map[string]struct{} // multihashes
+
+	refresh sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewScope returns the Scope of the roots of store with one of the private
+// labels, walking their DAGs with the local node getter ng.
+func NewScope(store *Store, ng ipld.NodeGetter, labels []string) *Scope {
+	s := &Scope{
+		store:   store,
+		ng:      ng,
+		labels:  make(map[string]struct{}, len(labels)),
+		private: make(map[string]struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, l := range labels {
+		s.labels[l] = struct{}{}
+	}
+	return s
+}
+
+// IsPrivateLabel tells whether the content of the roots labeled label is
+// private.
+func (s *Scope) IsPrivateLabel(label string) bool {
+	_, ok := s.labels[label]
+	return ok
+}
+
+// Private tells whether the multihash is of private content.
+func (s *Scope) Private(mh multihash.Multihash) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.private[string(mh)]
+	return ok
+}
+
+// Len returns the number of the private multihashes.
+func (s *Scope) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.private)
+}
+
+// Refresh lists the private content again. The private roots are private
+// even when their blocks aren't in the blockstore yet.
+func (s *Scope) Refresh(ctx context.Context) error {
+	s.refresh.Lock()
+	defer s.refresh.Unlock()
+
+	labels, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	private := make(map[string]struct{})
+	getLinks := dag.GetLinksWithDAG(s.ng)
+	for _, l := range labels {
+		if !s.IsPrivateLabel(l.Label) {
+			continue
+		}
+		private[string(l.Cid.Hash())] = struct{}{}
+		err := dag.Walk(ctx, getLinks, l.Cid, func(c cid.Cid) bool {
+			k := string(c.Hash())
+			if _, ok := private[k]; ok && !c.Equals(l.Cid) {
+				return false
+			}
+			private[k] = struct{}{}
+			return true
+		}, dag.IgnoreErrors())
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.private = private
+	s.mu.Unlock()
+	return nil
+}
+
+// Start lists the private content, then lists it again every
+// refreshInterval in the background until Stop is called.
+func (s *Scope) Start(ctx context.Context) error {
+	if err := s.Refresh(ctx); err != nil {
+		return err
+	}
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := s.Refresh(context.Background()); err != nil {
+					log.Errorf("failed to list the private content: %s", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops the background refreshes.
+func (s *Scope) Stop(ctx context.Context) error {
+	close(s.stop)
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,144 @@
+// Package pinmanifest implements the portable manifests of pinsets written by
+// 'ipfs pin export' and replayed by 'ipfs pin import'.
+//
+// A manifest is a JSON document listing pinned roots, signed by the key of
+// the node that exported it, so that pinsets can move between nodes or be
+// replayed after rebuilding a repo.
+package pinmanifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Version is the version of the manifest format.
+const Version = 1
+
+// Pin modes of the manifest.
+const (
+	Recursive = "recursive"
+	Direct    = "direct"
+)
+
+var (
+	// ErrUnsigned is returned when verifying a manifest without signature.
+	ErrUnsigned = errors.New("the pinset manifest is not signed")
+	// ErrBadSignature is returned when the signature of a manifest doesn't
+	// match its content or its signer.
+	ErrBadSignature = errors.New("invalid signature of the pinset manifest")
+)
+
+// Pin is a pinned root of a manifest.
+type Pin struct {
+	Cid  cid.Cid
+	Mode string
+	// Name and Meta label the pin, they're free-form
+	Name string            `json:",omitempty"`
+	Meta map[string]string `json:",omitempty"`
+}
+
+// Manifest is a portable pinset.
+type Manifest struct {
+	Version int
+	Created time.Time
+	// Meta labels the pinset, e.g. with its origin
+	Meta map[string]string `json:",omitempty"`
+	Pins []Pin
+
+	// Signer is the peer ID of the signing key, and PublicKey its protobuf
+	// encoded public key. Signature covers the JSON encoding of the
+	// manifest without the signature.
+	Signer    string `json:",omitempty"`
+	PublicKey []byte `json:",omitempty"`
+	Signature []byte `json:",omitempty"`
+}
+
+// New returns an unsigned manifest of pins.
+func New(pins []Pin, meta map[string]string) *Manifest {
+	return &Manifest{
+		Version: Version,
+		Created: time.Now().UTC().Truncate(time.Second),
+		Meta:    meta,
+		Pins:    pins,
+	}
+}
+
+// signedBytes returns the bytes covered by the signature.
+func (m *Manifest) signedBytes() ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	return json.Marshal(&unsigned)
+}
+
+// Sign signs the manifest with k.
+func (m *Manifest) Sign(k crypto.PrivKey) error {
+	id, err := peer.IDFromPrivateKey(k)
+	if err != nil {
+		return err
+	}
+	pub, err := crypto.MarshalPublicKey(k.GetPublic())
+	if err != nil {
+		return err
+	}
+	m.Signer = id.String()
+	m.PublicKey = pub
+	data, err := m.signedBytes()
+	if err != nil {
+		return err
+	}
+	m.Signature, err = k.Sign(data)
+	return err
+}
+
+// Verify checks the signature of the manifest, and returns its signer.
+func (m *Manifest) Verify() (peer.ID, error) {
+	if len(m.Signature) == 0 {
+		return "", ErrUnsigned
+	}
+	pub, err := crypto.UnmarshalPublicKey(m.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrBadSignature, err)
+	}
+	id, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	if id.String() != m.Signer {
+		return "", ErrBadSignature
+	}
+	data, err := m.signedBytes()
+	if err != nil {
+		return "", err
+	}
+	if ok, err := pub.Verify(data, m.Signature); err != nil || !ok {
+		return "", ErrBadSignature
+	}
+	return id, nil
+}
+
+// Read decodes and validates a manifest, without checking its signature.
+func Read(r io.Reader) (*Manifest, error) {
+	m := new(Manifest)
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, fmt.Errorf("invalid pinset manifest: %w", err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("unsupported pinset manifest version %d", m.Version)
+	}
+	for _, p := range m.Pins {
+		if !p.Cid.Defined() {
+			return nil, errors.New("invalid pinset manifest: pin without CID")
+		}
+		if p.Mode != Recursive && p.Mode != Direct {
+			return nil, fmt.Errorf("invalid pinset manifest: unknown mode %q of %s", p.Mode, p.Cid)
+		}
+	}
+	return m, nil
+}
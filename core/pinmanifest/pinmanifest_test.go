@@ -0,0 +1,66 @@
+package pinmanifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+	peer "github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestSignVerify(t *testing.T) {
+	k, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := cid.Decode("bafkqaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New([]Pin{{Cid: c, Mode: Recursive, Name: "empty", Meta: map[string]string{"app": "test"}}}, map[string]string{"origin": "test"})
+	if _, err := m.Verify(); !errors.Is(err, ErrUnsigned) {
+		t.Fatalf("expected %s, got %v", ErrUnsigned, err)
+	}
+	if err := m.Sign(k); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := read.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := peer.IDFromPrivateKey(k); signer != id {
+		t.Fatalf("expected signer %s, got %s", id, signer)
+	}
+	if len(read.Pins) != 1 || read.Pins[0].Cid != c || read.Pins[0].Name != "empty" {
+		t.Fatalf("unexpected pins %v", read.Pins)
+	}
+
+	read.Pins[0].Mode = Direct
+	if _, err := read.Verify(); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("expected %s, got %v", ErrBadSignature, err)
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"Version":2,"Pins":[]}`,
+		`{"Version":1,"Pins":[{"Mode":"recursive"}]}`,
+		`{"Version":1,"Pins":[{"Cid":{"/":"bafkqaaa"},"Mode":"indirect"}]}`,
+	} {
+		if _, err := Read(bytes.NewReader([]byte(data))); err == nil {
+			t.Errorf("expected an error reading %s", data)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package pinqueue runs the pins requested with 'ipfs pin add --background':
+// the pin jobs are queued by priority, run a few at a time, and persisted in
+// the datastore so that they resume after a restart of the daemon.
+package pinqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	logging "github.com/ipfs/go-log"
+	dag "github.com/ipfs/go-merkledag"
+
+	"github.com/ipfs/kubo/core/prefetch"
+)
+
+var log = logging.Logger("pinqueue")
+
+const (
+	// DefaultMaxActive is the default number of pins running at once.
+	DefaultMaxActive = 2
+
+	// keepFinished is the number of finished jobs kept for their state to
+	// be queried.
+	keepFinished = 100
+)
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("pin job not found")
+
+// jobsKey is the datastore prefix of the jobs.
+var jobsKey = ds.NewKey("/local/pinjobs")
+
+// PinFunc fetches the DAG under c, counting the nodes fetched with progress,
+// and pins it.
+type PinFunc func(ctx context.Context, c cid.Cid, recursive bool, progress *dag.ProgressTracker) error
+
+// Job is a pin job. The priorities and states are the ones of the prefetch
+// jobs.
+type Job struct {
+	ID        string
+	Cid       cid.Cid
+	Recursive bool
+	Priority  prefetch.Priority
+	State     prefetch.State
+	// Fetched is the number of nodes fetched
+	Fetched  int
+	Err      string `json:",omitempty"`
+	Created  time.Time
+	Started  time.Time
+	Finished time.Time
+}
+
+type job struct {
+	Job
+	seq      uint64
+	progress *dag.ProgressTracker
+	cancel   context.CancelFunc
+}
+
+func (j *job) snapshot() Job {
+	out := j.Job
+	if j.progress != nil {
+		out.Fetched = j.progress.Value()
+	}
+	return out
+}
+
+// Queue runs the pin jobs.
+type Queue struct {
+	ctx       context.Context
+	ds        ds.Datastore
+	pin       PinFunc
+	maxActive int
+
+	mu       sync.Mutex
+	started  bool
+	seq      uint64
+	jobs     map[string]*job
+	queue    []*job
+	active   int
+	finished []*job
+}
+
+// New loads the jobs persisted in d. The jobs interrupted by the last
+// shutdown are queued again. No job runs until Start is called, and they are
+// interrupted when ctx is done.
+func New(ctx context.Context, d ds.Datastore, pin PinFunc, maxActive int) (*Queue, error) {
+	if maxActive < 1 {
+		maxActive = DefaultMaxActive
+	}
+	q := &Queue{
+		ctx:       ctx,
+		ds:        d,
+		pin:       pin,
+		maxActive: maxActive,
+		jobs:      make(map[string]*job),
+	}
+
+	res, err := d.Query(ctx, query.Query{Prefix: jobsKey.String()})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		j := new(job)
+		if err := json.Unmarshal(e.Value, &j.Job); err != nil {
+			log.Errorf("skipping invalid pin job %s: %s", e.Key, err)
+			continue
+		}
+		if j.seq, err = strconv.ParseUint(j.ID, 10, 64); err != nil {
+			log.Errorf("skipping invalid pin job %s: %s", e.Key, err)
+			continue
+		}
+		if j.seq > q.seq {
+			q.seq = j.seq
+		}
+		q.jobs[j.ID] = j
+		switch j.State {
+		case prefetch.Queued, prefetch.Running:
+			j.State = prefetch.Queued
+			q.queue = append(q.queue, j)
+		default:
+			q.finished = append(q.finished, j)
+		}
+	}
+	sort.Slice(q.queue, func(a, b int) bool { return q.queue[a].seq < q.queue[b].seq })
+	q.sortQueue()
+	sort.Slice(q.finished, func(a, b int) bool { return q.finished[a].seq < q.finished[b].seq })
+	return q, nil
+}
+
+// Start runs the queued jobs.
+func (q *Queue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.started = true
+	q.schedule()
+}
+
+// Add queues a job pinning c and returns its ID.
+func (q *Queue) Add(c cid.Cid, recursive bool, priority prefetch.Priority) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.seq++
+	j := &job{
+		Job: Job{
+			ID:        strconv.FormatUint(q.seq, 10),
+			Cid:       c,
+			Recursive: recursive,
+			Priority:  priority,
+			State:     prefetch.Queued,
+			Created:   time.Now(),
+		},
+		seq: q.seq,
+	}
+	if err := q.persist(j); err != nil {
+		return "", err
+	}
+	q.jobs[j.ID] = j
+	q.queue = append(q.queue, j)
+	q.sortQueue()
+	q.schedule()
+	return j.ID, nil
+}
+
+// Job returns a job.
+func (q *Queue) Job(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return j.snapshot(), nil
+}
+
+// Jobs returns the jobs, the oldest first.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := make([]*job, 0, len(q.jobs))
+	for _, j := range q.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].seq < jobs[b].seq })
+	out := make([]Job, len(jobs))
+	for i, j := range jobs {
+		out[i] = j.snapshot()
+	}
+	return out
+}
+
+// Cancel cancels a queued or running job. The blocks already fetched are
+// kept, but not pinned.
+func (q *Queue) Cancel(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch j.State {
+	case prefetch.Queued:
+		for i, qj := range q.queue {
+			if qj == j {
+				q.queue = append(q.queue[:i], q.queue[i+1:]...)
+				break
+			}
+		}
+		q.finish(j, prefetch.Cancelled)
+	case prefetch.Running:
+		// the job is finished by its goroutine
+		j.cancel()
+	}
+	return nil
+}
+
+// sortQueue orders the queue by priority, then by age. q.mu must be held.
+func (q *Queue) sortQueue() {
+	sort.SliceStable(q.queue, func(a, b int) bool {
+		return q.queue[a].Priority > q.queue[b].Priority
+	})
+}
+
+// schedule starts the queued jobs while there is room. q.mu must be held.
+func (q *Queue) schedule() {
+	for q.started && q.active < q.maxActive && len(q.queue) > 0 {
+		j := q.queue[0]
+		q.queue = q.queue[1:]
+		ctx, cancel := context.WithCancel(q.ctx)
+		j.State = prefetch.Running
+		j.Started = time.Now()
+		j.progress = new(dag.ProgressTracker)
+		j.cancel = cancel
+		if err := q.persist(j); err != nil {
+			log.Errorf("persisting pin job %s: %s", j.ID, err)
+		}
+		q.active++
+		go q.run(ctx, j)
+	}
+}
+
+// finish moves j to a final state and forgets the oldest finished jobs. q.mu
+// must be held.
+func (q *Queue) finish(j *job, state prefetch.State) {
+	if j.progress != nil {
+		j.Fetched = j.progress.Value()
+		j.progress = nil
+	}
+	j.State = state
+	j.Finished = time.Now()
+	if err := q.persist(j); err != nil {
+		log.Errorf("persisting pin job %s: %s", j.ID, err)
+	}
+	q.finished = append(q.finished, j)
+	if len(q.finished) > keepFinished {
+		old := q.finished[0]
+		q.finished = q.finished[1:]
+		delete(q.jobs, old.ID)
+		if err := q.ds.Delete(q.ctx, jobsKey.ChildString(old.ID)); err != nil {
+			log.Errorf("deleting pin job %s: %s", old.ID, err)
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, j *job) {
+	err := q.pin(ctx, j.Cid, j.Recursive, j.progress)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j.cancel()
+	q.active--
+	switch {
+	case q.ctx.Err() != nil:
+		// shutting down: the job stays persisted as running, and is queued
+		// again on the next start
+		return
+	case err == nil:
+		q.finish(j, prefetch.Done)
+	case ctx.Err() != nil:
+		q.finish(j, prefetch.Cancelled)
+	default:
+		log.Warnf("pin job %s of %s: %s", j.ID, j.Cid, err)
+		j.Err = err.Error()
+		q.finish(j, prefetch.Failed)
+	}
+	q.schedule()
+}
+
+// persist stores j. q.mu must be held.
+func (q *Queue) persist(j *job) error {
+	b, err := json.Marshal(&j.Job)
+	if err != nil {
+		return err
+	}
+	return q.ds.Put(q.ctx, jobsKey.ChildString(j.ID), b)
+}
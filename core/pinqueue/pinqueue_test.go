@@ -0,0 +1,124 @@
+package pinqueue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ipfs/kubo/core/prefetch"
+)
+
+func testCid(s string) cid.Cid {
+	return dag.NodeWithData([]byte(s)).Cid()
+}
+
+func waitState(t *testing.T, q *Queue, id string, state prefetch.State) Job {
+	var j Job
+	require.Eventually(t, func() bool {
+		var err error
+		j, err = q.Job(id)
+		require.NoError(t, err)
+		return j.State == state
+	}, 5*time.Second, 10*time.Millisecond)
+	return j
+}
+
+func TestQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+
+	failing := testCid("failing")
+	blocking := testCid("blocking")
+	pinned := make(chan cid.Cid, 10)
+	pin := func(ctx context.Context, c cid.Cid, recursive bool, progress *dag.ProgressTracker) error {
+		progress.Increment()
+		switch c {
+		case failing:
+			return errors.New("boom")
+		case blocking:
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		pinned <- c
+		return nil
+	}
+
+	q, err := New(ctx, d, pin, 1)
+	require.NoError(t, err)
+	ok, err := q.Add(testCid("ok"), true, prefetch.PriorityNormal)
+	require.NoError(t, err)
+	require.Equal(t, prefetch.Queued, waitState(t, q, ok, prefetch.Queued).State)
+
+	q.Start()
+	j := waitState(t, q, ok, prefetch.Done)
+	require.Equal(t, 1, j.Fetched)
+	require.Equal(t, testCid("ok"), <-pinned)
+
+	id, err := q.Add(failing, true, prefetch.PriorityNormal)
+	require.NoError(t, err)
+	require.Equal(t, "boom", waitState(t, q, id, prefetch.Failed).Err)
+
+	id, err = q.Add(blocking, true, prefetch.PriorityNormal)
+	require.NoError(t, err)
+	waitState(t, q, id, prefetch.Running)
+	require.NoError(t, q.Cancel(id))
+	waitState(t, q, id, prefetch.Cancelled)
+
+	require.ErrorIs(t, q.Cancel("unknown"), ErrNotFound)
+	require.Len(t, q.Jobs(), 3)
+}
+
+func TestQueuePersistence(t *testing.T) {
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	blocking := testCid("blocking")
+	pin := func(ctx context.Context, c cid.Cid, recursive bool, progress *dag.ProgressTracker) error {
+		if c == blocking {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	// a daemon stopped while pinning, with a job queued behind
+	ctx, cancel := context.WithCancel(context.Background())
+	q, err := New(ctx, d, pin, 1)
+	require.NoError(t, err)
+	q.Start()
+	interrupted, err := q.Add(blocking, true, prefetch.PriorityNormal)
+	require.NoError(t, err)
+	waitState(t, q, interrupted, prefetch.Running)
+	queued, err := q.Add(testCid("queued"), false, prefetch.PriorityHigh)
+	require.NoError(t, err)
+	cancel()
+	require.Eventually(t, func() bool {
+		q.mu.Lock()
+		defer q.mu.Unlock()
+		return q.active == 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the next start resumes both, by priority
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	q, err = New(ctx, d, pin, 1)
+	require.NoError(t, err)
+	require.Len(t, q.queue, 2)
+	require.Equal(t, queued, q.queue[0].ID)
+	require.False(t, q.queue[0].Recursive)
+	require.Equal(t, interrupted, q.queue[1].ID)
+
+	q.Start()
+	waitState(t, q, queued, prefetch.Done)
+	waitState(t, q, interrupted, prefetch.Running)
+
+	id, err := q.Add(testCid("new"), true, prefetch.PriorityNormal)
+	require.NoError(t, err)
+	require.Equal(t, "3", id)
+}
@@ -0,0 +1,144 @@
+// Package popularity counts the most requested keys, e.g. the paths served by
+// the gateway, in bounded memory.
+//
+// It implements the Space-Saving algorithm: at most capacity keys are
+// counted, and a new key replaces the least requested one, inheriting its
+// count. The counts of the keys in the top are overestimated by at most their
+// Error, and a key requested more than 1/capacity of the time is always
+// counted.
+package popularity
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is the count of a key.
+type Entry struct {
+	Key      string
+	Requests uint64
+	// Bytes served since the key was last added to the top
+	Bytes uint64
+	// Error is the maximum overestimation of Requests
+	Error uint64
+}
+
+// Tracker counts the most requested keys. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	capacity int
+	byKey    map[string]*item
+	// the counted keys, least requested first
+	heap  itemHeap
+	since time.Time
+}
+
+type item struct {
+	Entry
+	index int
+}
+
+// New returns a Tracker counting at most capacity keys.
+func New(capacity int) *Tracker {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Tracker{
+		capacity: capacity,
+		byKey:    make(map[string]*item, capacity),
+		since:    time.Now(),
+	}
+}
+
+// Record counts a request for key, which served bytes.
+func (t *Tracker) Record(key string, bytes uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if it, ok := t.byKey[key]; ok {
+		it.Requests++
+		it.Bytes += bytes
+		heap.Fix(&t.heap, it.index)
+		return
+	}
+	if len(t.heap) < t.capacity {
+		it := &item{Entry: Entry{Key: key, Requests: 1, Bytes: bytes}}
+		t.byKey[key] = it
+		heap.Push(&t.heap, it)
+		return
+	}
+
+	// replace the least requested key
+	it := t.heap[0]
+	delete(t.byKey, it.Key)
+	it.Entry = Entry{Key: key, Requests: it.Requests + 1, Bytes: bytes, Error: it.Requests}
+	t.byKey[key] = it
+	heap.Fix(&t.heap, 0)
+}
+
+// Top returns the n most requested keys, all of them if n <= 0, sorted by
+// requests, or by bytes if byBytes is set.
+func (t *Tracker) Top(n int, byBytes bool) []Entry {
+	t.mu.Lock()
+	out := make([]Entry, 0, len(t.heap))
+	for _, it := range t.heap {
+		out = append(out, it.Entry)
+	}
+	t.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if byBytes && a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		return a.Key < b.Key
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// Since returns the time the counting started.
+func (t *Tracker) Since() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.since
+}
+
+// Reset forgets all the counts.
+func (t *Tracker) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byKey = make(map[string]*item, t.capacity)
+	t.heap = nil
+	t.since = time.Now()
+}
+
+type itemHeap []*item
+
+func (h itemHeap) Len() int           { return len(h) }
+func (h itemHeap) Less(i, j int) bool { return h[i].Requests < h[j].Requests }
+func (h itemHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *itemHeap) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *itemHeap) Pop() interface{} {
+	old := *h
+	it := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,51 @@
+package popularity
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTop(t *testing.T) {
+	tr := New(3)
+	for i := 0; i < 10; i++ {
+		tr.Record("hot", 10)
+	}
+	for i := 0; i < 5; i++ {
+		tr.Record("warm", 1000)
+	}
+	tr.Record("cold", 1)
+
+	require.Equal(t, []Entry{
+		{Key: "hot", Requests: 10, Bytes: 100},
+		{Key: "warm", Requests: 5, Bytes: 5000},
+		{Key: "cold", Requests: 1, Bytes: 1},
+	}, tr.Top(0, false))
+	require.Equal(t, []Entry{{Key: "warm", Requests: 5, Bytes: 5000}}, tr.Top(1, true))
+
+	// a new key replaces the least requested one
+	tr.Record("new", 7)
+	require.Equal(t, []Entry{
+		{Key: "hot", Requests: 10, Bytes: 100},
+		{Key: "warm", Requests: 5, Bytes: 5000},
+		{Key: "new", Requests: 2, Bytes: 7, Error: 1},
+	}, tr.Top(0, false))
+
+	tr.Reset()
+	require.Empty(t, tr.Top(0, false))
+}
+
+func TestHeavyHitters(t *testing.T) {
+	tr := New(10)
+	for i := 0; i < 10000; i++ {
+		tr.Record(fmt.Sprintf("noise-%d", i), 1)
+		if i%5 == 0 {
+			tr.Record("heavy", 1)
+		}
+	}
+	top := tr.Top(1, false)
+	require.Equal(t, "heavy", top[0].Key)
+	require.GreaterOrEqual(t, top[0].Requests, uint64(2000))
+	require.LessOrEqual(t, top[0].Requests-top[0].Error, uint64(2000))
+}
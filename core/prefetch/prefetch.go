@@ -0,0 +1,332 @@
+// Package prefetch warms the local blockstore with the blocks of DAGs in the
+// background, so that content about to be requested, e.g. announced on a
+// gateway, is served from the local blockstore.
+//
+// The prefetch jobs are queued by priority and run a few at a time. They are
+// kept in memory only: they don't survive a restart of the node.
+package prefetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+var log = logging.Logger("prefetch")
+
+const (
+	// DefaultMaxActive is the default number of jobs running at once.
+	DefaultMaxActive = 2
+
+	// keepFinished is the number of finished jobs kept for their progress
+	// to be queried.
+	keepFinished = 100
+
+	// fetchConcurrency is the number of blocks fetched at once by a job.
+	fetchConcurrency = 16
+)
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("prefetch job not found")
+
+var errMaxBytes = errors.New("byte limit reached")
+
+// Priority orders the queued jobs: higher priority jobs start first.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+// ParsePriority parses "low", "normal" or "high".
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "low":
+		return PriorityLow, nil
+	case "normal", "":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	default:
+		return 0, fmt.Errorf("unknown prefetch priority %q, expected low, normal or high", s)
+	}
+}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// State is the state of a job.
+type State string
+
+const (
+	Queued    State = "queued"
+	Running   State = "running"
+	Done      State = "done"
+	Failed    State = "failed"
+	Cancelled State = "cancelled"
+)
+
+// Options are the limits of a job.
+type Options struct {
+	// MaxDepth is the depth of the DAG fetched, 0 for the root only and -1
+	// for no limit.
+	MaxDepth int
+	// MaxBytes stops the job once that many bytes are fetched, 0 for no
+	// limit.
+	MaxBytes uint64
+	Priority Priority
+}
+
+// Progress is a snapshot of a job.
+type Progress struct {
+	ID       string
+	Root     cid.Cid
+	Priority Priority
+	State    State
+	// Blocks and Bytes count the blocks walked, whether they were already in
+	// the blockstore or not.
+	Blocks uint64
+	Bytes  uint64
+	// Truncated is true if the job stopped at MaxBytes.
+	Truncated bool
+	Err       string
+	Created   time.Time
+	Started   time.Time
+	Finished  time.Time
+}
+
+type job struct {
+	// accessed atomically, first for 64-bit alignment
+	blocks uint64
+	bytes  uint64
+
+	dag     ipld.NodeGetter
+	id      string
+	root    cid.Cid
+	opts    Options
+	seq     uint64
+	created time.Time
+
+	// protected by the lock of the manager
+	state     State
+	truncated bool
+	err       error
+	started   time.Time
+	finished  time.Time
+	cancel    context.CancelFunc
+}
+
+// Manager runs the prefetch jobs.
+type Manager struct {
+	ctx       context.Context
+	maxActive int
+
+	mu       sync.Mutex
+	seq      uint64
+	jobs     map[string]*job
+	queue    []*job
+	active   int
+	finished []*job
+}
+
+// New returns a Manager running at most maxActive jobs at once. The jobs are
+// cancelled when ctx is done.
+func New(ctx context.Context, maxActive int) *Manager {
+	if maxActive < 1 {
+		maxActive = DefaultMaxActive
+	}
+	return &Manager{
+		ctx:       ctx,
+		maxActive: maxActive,
+		jobs:      make(map[string]*job),
+	}
+}
+
+// Start queues a job fetching the DAG under root from ng and returns its ID.
+func (m *Manager) Start(ng ipld.NodeGetter, root cid.Cid, opts Options) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	j := &job{
+		dag:     ng,
+		id:      strconv.FormatUint(m.seq, 10),
+		root:    root,
+		opts:    opts,
+		seq:     m.seq,
+		created: time.Now(),
+		state:   Queued,
+	}
+	m.jobs[j.id] = j
+	m.queue = append(m.queue, j)
+	sort.SliceStable(m.queue, func(i, k int) bool {
+		return m.queue[i].opts.Priority > m.queue[k].opts.Priority
+	})
+	m.schedule()
+	return j.id
+}
+
+// Progress returns the progress of a job.
+func (m *Manager) Progress(id string) (Progress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Progress{}, ErrNotFound
+	}
+	return j.progress(), nil
+}
+
+// List returns the progress of the jobs, the oldest first.
+func (m *Manager) List() []Progress {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		jobs = append(jobs, j)
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].seq < jobs[k].seq })
+	out := make([]Progress, len(jobs))
+	for i, j := range jobs {
+		out[i] = j.progress()
+	}
+	return out
+}
+
+// Cancel cancels a queued or running job.
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return ErrNotFound
+	}
+	switch j.state {
+	case Queued:
+		for i, q := range m.queue {
+			if q == j {
+				m.queue = append(m.queue[:i], m.queue[i+1:]...)
+				break
+			}
+		}
+		m.finish(j, Cancelled)
+	case Running:
+		// the job is finished by its goroutine
+		j.cancel()
+	}
+	return nil
+}
+
+// schedule starts the queued jobs while there is room. m.mu must be held.
+func (m *Manager) schedule() {
+	for m.active < m.maxActive && len(m.queue) > 0 {
+		j := m.queue[0]
+		m.queue = m.queue[1:]
+		ctx, cancel := context.WithCancel(m.ctx)
+		j.state = Running
+		j.started = time.Now()
+		j.cancel = cancel
+		m.active++
+		go m.run(ctx, j)
+	}
+}
+
+// finish moves j to a final state and forgets the oldest finished jobs. m.mu
+// must be held.
+func (m *Manager) finish(j *job, state State) {
+	j.state = state
+	j.finished = time.Now()
+	m.finished = append(m.finished, j)
+	if len(m.finished) > keepFinished {
+		delete(m.jobs, m.finished[0].id)
+		m.finished = m.finished[1:]
+	}
+}
+
+func (m *Manager) run(ctx context.Context, j *job) {
+	err := j.walk(ctx)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j.cancel()
+	m.active--
+	switch {
+	case err == nil:
+		m.finish(j, Done)
+	case errors.Is(err, errMaxBytes):
+		j.truncated = true
+		m.finish(j, Done)
+	case ctx.Err() != nil:
+		m.finish(j, Cancelled)
+	default:
+		log.Debugf("prefetch job %s of %s: %s", j.id, j.root, err)
+		j.err = err
+		m.finish(j, Failed)
+	}
+	m.schedule()
+}
+
+func (j *job) walk(ctx context.Context) error {
+	session := dag.NewSession(ctx, j.dag)
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		nd, err := session.Get(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(&j.blocks, 1)
+		bytes := atomic.AddUint64(&j.bytes, uint64(len(nd.RawData())))
+		if j.opts.MaxBytes > 0 && bytes >= j.opts.MaxBytes {
+			// stops the walk
+			return nil, errMaxBytes
+		}
+		return nd.Links(), nil
+	}
+
+	seen := cid.NewSet()
+	visit := func(c cid.Cid, depth int) bool {
+		if j.opts.MaxDepth >= 0 && depth > j.opts.MaxDepth {
+			return false
+		}
+		return seen.Visit(c)
+	}
+
+	return dag.WalkDepth(ctx, getLinks, j.root, visit, dag.Concurrency(fetchConcurrency))
+}
+
+func (j *job) progress() Progress {
+	p := Progress{
+		ID:        j.id,
+		Root:      j.root,
+		Priority:  j.opts.Priority,
+		State:     j.state,
+		Blocks:    atomic.LoadUint64(&j.blocks),
+		Bytes:     atomic.LoadUint64(&j.bytes),
+		Truncated: j.truncated,
+		Created:   j.created,
+		Started:   j.started,
+		Finished:  j.finished,
+	}
+	if j.err != nil {
+		p.Err = j.err.Error()
+	}
+	return p
+}
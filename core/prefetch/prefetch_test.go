@@ -0,0 +1,102 @@
+package prefetch
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	"github.com/stretchr/testify/require"
+)
+
+var nodes int
+
+// buildDAG adds a tree of distinct blocks of the given depth and fan-out, and
+// returns its root.
+func buildDAG(t *testing.T, ds ipld.DAGService, depth, fanOut int) *dag.ProtoNode {
+	nodes++
+	nd := dag.NodeWithData([]byte(strconv.Itoa(nodes)))
+	if depth > 0 {
+		for i := 0; i < fanOut; i++ {
+			require.NoError(t, nd.AddNodeLink("", buildDAG(t, ds, depth-1, fanOut)))
+		}
+	}
+	require.NoError(t, ds.Add(context.Background(), nd))
+	return nd
+}
+
+func wait(t *testing.T, m *Manager, id string) Progress {
+	var p Progress
+	require.Eventually(t, func() bool {
+		var err error
+		p, err = m.Progress(id)
+		require.NoError(t, err)
+		return p.State != Queued && p.State != Running
+	}, 5*time.Second, 10*time.Millisecond)
+	return p
+}
+
+func TestPrefetch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := mdtest.Mock()
+	root := buildDAG(t, ds, 3, 3)
+	m := New(ctx, 1)
+
+	p := wait(t, m, m.Start(ds, root.Cid(), Options{MaxDepth: -1}))
+	require.Equal(t, Done, p.State)
+	require.Equal(t, uint64(1+3+9+27), p.Blocks)
+	require.False(t, p.Truncated)
+
+	p = wait(t, m, m.Start(ds, root.Cid(), Options{MaxDepth: 1}))
+	require.Equal(t, Done, p.State)
+	require.Equal(t, uint64(1+3), p.Blocks)
+
+	p = wait(t, m, m.Start(ds, root.Cid(), Options{MaxDepth: -1, MaxBytes: 1}))
+	require.Equal(t, Done, p.State)
+	require.True(t, p.Truncated)
+	require.Equal(t, uint64(1), p.Blocks)
+
+	missing := dag.NodeWithData([]byte("missing"))
+	p = wait(t, m, m.Start(ds, missing.Cid(), Options{MaxDepth: -1}))
+	require.Equal(t, Failed, p.State)
+	require.NotEmpty(t, p.Err)
+
+	require.Len(t, m.List(), 4)
+	_, err := m.Progress("unknown")
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestPrefetchQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ds := mdtest.Mock()
+	root := buildDAG(t, ds, 1, 1)
+
+	// no job runs until the manager has room
+	m := New(ctx, 1)
+	m.mu.Lock()
+	m.active = 1
+	m.mu.Unlock()
+	low := m.Start(ds, root.Cid(), Options{Priority: PriorityLow})
+	normal := m.Start(ds, root.Cid(), Options{})
+	high := m.Start(ds, root.Cid(), Options{Priority: PriorityHigh})
+	m.mu.Lock()
+	require.Equal(t, []string{high, normal, low}, []string{m.queue[0].id, m.queue[1].id, m.queue[2].id})
+	m.mu.Unlock()
+
+	require.NoError(t, m.Cancel(normal))
+	p, err := m.Progress(normal)
+	require.NoError(t, err)
+	require.Equal(t, Cancelled, p.State)
+
+	m.mu.Lock()
+	m.active = 0
+	m.schedule()
+	m.mu.Unlock()
+	require.Equal(t, Done, wait(t, m, high).State)
+	require.Equal(t, Done, wait(t, m, low).State)
+}
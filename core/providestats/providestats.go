@@ -0,0 +1,179 @@
+// Package providestats tracks the announcements of the provider records of
+// the pinned roots, and the reprovide runs, telling the roots which weren't
+// announced within the reprovide interval.
+package providestats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/multiformats/go-multihash"
+)
+
+// recentSize is the number of the keys provided one by one which are
+// tracked before being watched as roots, e.g. the root of a file just added.
+const recentSize = 4096
+
+// Statuses of a root.
+const (
+	StatusAnnounced = "announced"
+	StatusFailed    = "failed"
+	StatusOverdue   = "overdue"
+	StatusPending   = "pending"
+)
+
+// Announce is the last announcement of a key.
+type Announce struct {
+	Time     time.Time
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+// Reprovide is a run of the reprovider.
+type Reprovide struct {
+	Start    time.Time
+	Duration time.Duration
+	Keys     int
+	Error    string `json:",omitempty"`
+}
+
+// Tracker tracks the announcements of the roots it watches, and of the keys
+// recently provided one by one.
+type Tracker struct {
+	interval time.Duration
+	started  time.Time
+
+	mu        sync.Mutex
+	roots     map[string]struct{}
+	last      map[string]Announce
+	recent    map[string]Announce
+	recentLRU []string // keys of recent, oldest first
+	reprovide *Reprovide
+}
+
+// New returns a Tracker of the announcements reprovided every interval, 0
+// when the reprovider is disabled.
+func New(interval time.Duration) *Tracker {
+	return &Tracker{
+		interval: interval,
+		started:  time.Now(),
+		roots:    make(map[string]struct{}),
+		last:     make(map[string]Announce),
+		recent:   make(map[string]Announce),
+	}
+}
+
+// Interval returns the reprovide interval.
+func (t *Tracker) Interval() time.Duration {
+	return t.interval
+}
+
+// Watch replaces the roots tracked, keeping the last announcement of the
+// ones already tracked.
+func (t *Tracker) Watch(roots []multihash.Multihash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.roots = make(map[string]struct{}, len(roots))
+	for _, r := range roots {
+		k := string(r)
+		t.roots[k] = struct{}{}
+		if a, ok := t.recent[k]; ok {
+			if _, ok := t.last[k]; !ok {
+				t.last[k] = a
+			}
+		}
+	}
+	for k := range t.last {
+		if _, ok := t.roots[k]; !ok {
+			delete(t.last, k)
+		}
+	}
+}
+
+// Record records the announcement of keys started at start, which failed
+// with err unless nil.
+func (t *Tracker) Record(keys []multihash.Multihash, start time.Time, err error) {
+	a := Announce{Time: start, Duration: time.Since(start)}
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, key := range keys {
+		k := string(key)
+		if _, ok := t.roots[k]; ok {
+			t.last[k] = a
+		} else if len(keys) == 1 {
+			t.addRecent(k, a)
+		}
+	}
+}
+
+func (t *Tracker) addRecent(k string, a Announce) {
+	if _, ok := t.recent[k]; !ok {
+		if len(t.recentLRU) >= recentSize {
+			delete(t.recent, t.recentLRU[0])
+			t.recentLRU = t.recentLRU[1:]
+		}
+		t.recentLRU = append(t.recentLRU, k)
+	}
+	t.recent[k] = a
+}
+
+// Reprovided records a run of the reprovider.
+func (t *Tracker) Reprovided(r Reprovide) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reprovide = &r
+}
+
+// LastReprovide returns the last run of the reprovider, nil if none ran.
+func (t *Tracker) LastReprovide() *Reprovide {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reprovide == nil {
+		return nil
+	}
+	r := *t.reprovide
+	return &r
+}
+
+// Root returns the last announcement of the root, nil if not announced since
+// the tracker started, and its status.
+func (t *Tracker) Root(root multihash.Multihash, now time.Time) (*Announce, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a, ok := t.last[string(root)]
+	if !ok {
+		a, ok = t.recent[string(root)]
+	}
+	if !ok {
+		if t.overdue(t.started, now) {
+			return nil, StatusOverdue
+		}
+		return nil, StatusPending
+	}
+	if a.Error != "" {
+		return &a, StatusFailed
+	}
+	if t.overdue(a.Time, now) {
+		return &a, StatusOverdue
+	}
+	return &a, StatusAnnounced
+}
+
+// overdue tells whether a root announced at last is due at now: a reprovide
+// interval, plus the time the last reprovide took to get to it, passed.
+func (t *Tracker) overdue(last, now time.Time) bool {
+	if t.interval <= 0 {
+		return false
+	}
+	due := last.Add(t.interval)
+	if t.reprovide != nil {
+		due = due.Add(t.reprovide.Duration)
+	}
+	return now.After(due)
+}
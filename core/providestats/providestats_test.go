@@ -0,0 +1,64 @@
+package providestats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/multiformats/go-multihash"
+)
+
+func testKey(t *testing.T, s string) multihash.Multihash {
+	mh, err := multihash.Sum([]byte(s), multihash.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mh
+}
+
+func TestTracker(t *testing.T) {
+	tr := New(time.Hour)
+	a, b, c, added := testKey(t, "a"), testKey(t, "b"), testKey(t, "c"), testKey(t, "added")
+
+	// provided one by one before being watched
+	tr.Record([]multihash.Multihash{added}, time.Now(), nil)
+
+	tr.Watch([]multihash.Multihash{a, b, c, added})
+	start := time.Now()
+	tr.Record([]multihash.Multihash{a, testKey(t, "child")}, start, nil)
+	tr.Record([]multihash.Multihash{b}, start, errors.New("unreachable"))
+	tr.Reprovided(Reprovide{Start: start, Duration: time.Minute, Keys: 2})
+
+	now := time.Now()
+	cases := []struct {
+		key    multihash.Multihash
+		status string
+	}{
+		{a, StatusAnnounced},
+		{b, StatusFailed},
+		{c, StatusPending},
+		{added, StatusAnnounced},
+	}
+	for _, cs := range cases {
+		if _, status := tr.Root(cs.key, now); status != cs.status {
+			t.Errorf("expected %s, got %s", cs.status, status)
+		}
+	}
+
+	// due after the interval and the duration of the last reprovide
+	if _, status := tr.Root(a, start.Add(time.Hour+30*time.Second)); status != StatusAnnounced {
+		t.Errorf("expected the root announced within the reprovide, got %s", status)
+	}
+	if _, status := tr.Root(a, start.Add(2*time.Hour)); status != StatusOverdue {
+		t.Errorf("expected the root overdue, got %s", status)
+	}
+	if _, status := tr.Root(c, now.Add(2*time.Hour)); status != StatusOverdue {
+		t.Errorf("expected the root never announced overdue, got %s", status)
+	}
+
+	// the roots unpinned aren't tracked anymore
+	tr.Watch([]multihash.Multihash{b})
+	if ann, _ := tr.Root(a, now); ann != nil {
+		t.Errorf("expected the unwatched root to be dropped, got %v", ann)
+	}
+}
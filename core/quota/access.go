@@ -0,0 +1,100 @@
+package quota
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+)
+
+// AccessTimes records when blocks were last read or written, so that the
+// least recently used blocks of the cache can be evicted first. Times are
+// only kept in memory: blocks not accessed since the node started are
+// considered the oldest. A nil *AccessTimes is valid and records nothing.
+type AccessTimes struct {
+	mu    sync.Mutex
+	times map[string]time.Time
+}
+
+// NewAccessTimes returns an empty AccessTimes.
+func NewAccessTimes() *AccessTimes {
+	return &AccessTimes{times: make(map[string]time.Time)}
+}
+
+// Touch records an access to c.
+func (t *AccessTimes) Touch(c cid.Cid) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	t.times[string(c.Hash())] = now
+	t.mu.Unlock()
+}
+
+// Get returns the last access to c, the zero time if unknown.
+func (t *AccessTimes) Get(c cid.Cid) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.times[string(c.Hash())]
+}
+
+// Forget drops the access time of c.
+func (t *AccessTimes) Forget(c cid.Cid) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	delete(t.times, string(c.Hash()))
+	t.mu.Unlock()
+}
+
+// TrackAccess wraps bs to record the blocks read from and written to it in t.
+func TrackAccess(bs bstore.Blockstore, t *AccessTimes) bstore.Blockstore {
+	return &accessBlockstore{Blockstore: bs, t: t}
+}
+
+type accessBlockstore struct {
+	bstore.Blockstore
+	t *AccessTimes
+}
+
+func (bs *accessBlockstore) Get(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+	b, err := bs.Blockstore.Get(ctx, c)
+	if err == nil {
+		bs.t.Touch(c)
+	}
+	return b, err
+}
+
+func (bs *accessBlockstore) Put(ctx context.Context, b blocks.Block) error {
+	err := bs.Blockstore.Put(ctx, b)
+	if err == nil {
+		bs.t.Touch(b.Cid())
+	}
+	return err
+}
+
+func (bs *accessBlockstore) PutMany(ctx context.Context, blks []blocks.Block) error {
+	err := bs.Blockstore.PutMany(ctx, blks)
+	if err == nil {
+		for _, b := range blks {
+			bs.t.Touch(b.Cid())
+		}
+	}
+	return err
+}
+
+func (bs *accessBlockstore) DeleteBlock(ctx context.Context, c cid.Cid) error {
+	err := bs.Blockstore.DeleteBlock(ctx, c)
+	if err == nil {
+		bs.t.Forget(c)
+	}
+	return err
+}
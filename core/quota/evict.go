@@ -0,0 +1,88 @@
+package quota
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// EvictResult summarizes an eviction run.
+type EvictResult struct {
+	CacheSize uint64 // size of the cache before eviction, in bytes
+	Removed   uint64 // number of blocks removed
+	Freed     uint64 // size of the blocks removed, in bytes
+}
+
+type evictCandidate struct {
+	c          cid.Cid
+	size       uint64
+	accessTime time.Time
+}
+
+// Evict removes the least recently used blocks of the cache until it fits
+// in its quota. Blocks reachable from pins or MFS are never removed. The GC
+// lock is held for the whole run, so that blocks being added and not yet
+// pinned are not removed.
+func (a *Accountant) Evict(ctx context.Context, locker bstore.GCLocker, access *AccessTimes) (EvictResult, error) {
+	var res EvictResult
+	budget := a.Quota(Cache)
+	if budget == NoLimit {
+		return res, nil
+	}
+
+	unlocker := locker.GCLock(ctx)
+	defer unlocker.Unlock(ctx)
+
+	pinnedHashes, filesHashes, err := a.protected(ctx)
+	if err != nil {
+		return res, err
+	}
+
+	keys, err := a.bs.AllKeysChan(ctx)
+	if err != nil {
+		return res, err
+	}
+	var candidates []evictCandidate
+	for k := range keys {
+		if _, ok := pinnedHashes[string(k.Hash())]; ok {
+			continue
+		}
+		if _, ok := filesHashes[string(k.Hash())]; ok {
+			continue
+		}
+		size, err := a.bs.GetSize(ctx, k)
+		if ipld.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return res, err
+		}
+		candidates = append(candidates, evictCandidate{c: k, size: uint64(size), accessTime: access.Get(k)})
+		res.CacheSize += uint64(size)
+	}
+	if err := ctx.Err(); err != nil {
+		return res, err
+	}
+	if res.CacheSize <= budget {
+		return res, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].accessTime.Before(candidates[j].accessTime)
+	})
+	for _, cand := range candidates {
+		if res.CacheSize-res.Freed <= budget {
+			break
+		}
+		if err := a.bs.DeleteBlock(ctx, cand.c); err != nil && !ipld.IsNotFound(err) {
+			return res, err
+		}
+		access.Forget(cand.c)
+		res.Removed++
+		res.Freed += cand.size
+	}
+	return res, nil
+}
@@ -0,0 +1,178 @@
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+)
+
+// GrowthPrefix is the prefix of the daily samples of the repo size in the
+// datastore.
+var GrowthPrefix = ds.NewKey("/local/growth")
+
+const (
+	// MaxGrowthSamples is the number of daily samples kept.
+	MaxGrowthSamples = 90
+
+	// ForecastWindow is the number of days of samples the growth rate is
+	// computed over.
+	ForecastWindow = 30
+
+	// NeverFull is the DaysUntilFull of repos which aren't growing or have
+	// no StorageMax.
+	NeverFull = -1
+
+	dayLayout = "2006-01-02"
+)
+
+// Sample is the size of the repo and of its namespaces on a day.
+type Sample struct {
+	Day      string // in UTC, as YYYY-MM-DD
+	RepoSize uint64
+	Pins     uint64
+	MFS      uint64
+	Cache    uint64
+}
+
+// Day returns the day of t, as used in samples.
+func Day(t time.Time) string {
+	return t.UTC().Format(dayLayout)
+}
+
+// RecordSample stores the sample of a day, replacing the previous sample of
+// the same day, and removes the oldest samples beyond MaxGrowthSamples.
+func RecordSample(ctx context.Context, d ds.Datastore, s Sample) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := d.Put(ctx, GrowthPrefix.ChildString(s.Day), data); err != nil {
+		return err
+	}
+
+	samples, err := Samples(ctx, d)
+	if err != nil {
+		return err
+	}
+	for len(samples) > MaxGrowthSamples {
+		if err := d.Delete(ctx, GrowthPrefix.ChildString(samples[0].Day)); err != nil {
+			return err
+		}
+		samples = samples[1:]
+	}
+	return nil
+}
+
+// Samples returns the stored samples, from the oldest to the newest.
+func Samples(ctx context.Context, d ds.Datastore) ([]Sample, error) {
+	res, err := d.Query(ctx, query.Query{Prefix: GrowthPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var samples []Sample
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var s Sample
+		if err := json.Unmarshal(r.Value, &s); err != nil {
+			return nil, fmt.Errorf("invalid growth sample %s: %w", r.Key, err)
+		}
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Day < samples[j].Day })
+	return samples, nil
+}
+
+// Forecast is the daily growth of the repo and of its namespaces, in bytes
+// per day, and when the repo reaches its StorageMax at this rate.
+type Forecast struct {
+	// Days is the number of days of samples the growth is computed over.
+	Days        int
+	RepoGrowth  int64
+	PinsGrowth  int64
+	MFSGrowth   int64
+	CacheGrowth int64
+	// DaysUntilFull is NeverFull if the repo isn't growing or has no
+	// StorageMax.
+	DaysUntilFull int64
+}
+
+// NewForecast fits the samples of the last ForecastWindow days with a line,
+// and forecasts when a repo of repoSize bytes reaches storageMax, which is
+// NoLimit if the repo has no StorageMax.
+func NewForecast(samples []Sample, repoSize, storageMax uint64) *Forecast {
+	f := &Forecast{DaysUntilFull: NeverFull}
+	if len(samples) == 0 {
+		return f
+	}
+
+	last, err := time.Parse(dayLayout, samples[len(samples)-1].Day)
+	if err != nil {
+		return f
+	}
+	var days []float64
+	var window []Sample
+	for _, s := range samples {
+		t, err := time.Parse(dayLayout, s.Day)
+		if err != nil {
+			continue
+		}
+		d := t.Sub(last).Hours() / 24
+		if d <= -ForecastWindow {
+			continue
+		}
+		days = append(days, d)
+		window = append(window, s)
+	}
+	if len(window) < 2 {
+		return f
+	}
+	f.Days = int(days[len(days)-1]-days[0]) + 1
+
+	growth := func(size func(Sample) uint64) int64 {
+		ys := make([]float64, len(window))
+		for i, s := range window {
+			ys[i] = float64(size(s))
+		}
+		return int64(math.Round(slope(days, ys)))
+	}
+	f.RepoGrowth = growth(func(s Sample) uint64 { return s.RepoSize })
+	f.PinsGrowth = growth(func(s Sample) uint64 { return s.Pins })
+	f.MFSGrowth = growth(func(s Sample) uint64 { return s.MFS })
+	f.CacheGrowth = growth(func(s Sample) uint64 { return s.Cache })
+
+	switch {
+	case storageMax == NoLimit:
+	case repoSize >= storageMax:
+		f.DaysUntilFull = 0
+	case f.RepoGrowth > 0:
+		f.DaysUntilFull = int64((storageMax - repoSize) / uint64(f.RepoGrowth))
+	}
+	return f
+}
+
+// slope returns the slope of the least squares line through the points.
+func slope(xs, ys []float64) float64 {
+	n := float64(len(xs))
+	var sx, sy, sxx, sxy float64
+	for i := range xs {
+		sx += xs[i]
+		sy += ys[i]
+		sxx += xs[i] * xs[i]
+		sxy += xs[i] * ys[i]
+	}
+	d := n*sxx - sx*sx
+	if d == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / d
+}
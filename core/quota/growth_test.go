@@ -0,0 +1,80 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestRecordSample(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxGrowthSamples+5; i++ {
+		s := Sample{Day: Day(start.AddDate(0, 0, i)), RepoSize: uint64(i)}
+		if err := RecordSample(ctx, d, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a new sample of the same day replaces the previous one
+	if err := RecordSample(ctx, d, Sample{Day: Day(start.AddDate(0, 0, MaxGrowthSamples+4)), RepoSize: 1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	samples, err := Samples(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != MaxGrowthSamples {
+		t.Fatalf("expected %d samples, got %d", MaxGrowthSamples, len(samples))
+	}
+	if samples[0].Day != Day(start.AddDate(0, 0, 5)) {
+		t.Errorf("the oldest samples should be removed, first sample is %s", samples[0].Day)
+	}
+	if samples[len(samples)-1].RepoSize != 1000 {
+		t.Errorf("the last sample should be replaced, got %+v", samples[len(samples)-1])
+	}
+}
+
+func TestForecast(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	var samples []Sample
+	// an old sample out of the window
+	samples = append(samples, Sample{Day: Day(start.AddDate(0, 0, -60)), RepoSize: 1 << 40})
+	for i := 0; i < 10; i++ {
+		samples = append(samples, Sample{
+			Day:      Day(start.AddDate(0, 0, i)),
+			RepoSize: 1000 + uint64(i)*100,
+			Pins:     1000 + uint64(i)*80,
+			MFS:      500,
+			Cache:    1000 - uint64(i)*10,
+		})
+	}
+
+	f := NewForecast(samples, 2000, 5000)
+	expected := Forecast{
+		Days:          10,
+		RepoGrowth:    100,
+		PinsGrowth:    80,
+		MFSGrowth:     0,
+		CacheGrowth:   -10,
+		DaysUntilFull: 30,
+	}
+	if *f != expected {
+		t.Errorf("expected %+v, got %+v", expected, *f)
+	}
+
+	if f := NewForecast(samples, 2000, NoLimit); f.DaysUntilFull != NeverFull {
+		t.Errorf("a repo without StorageMax should never be full, got %d days", f.DaysUntilFull)
+	}
+	if f := NewForecast(samples, 6000, 5000); f.DaysUntilFull != 0 {
+		t.Errorf("a repo over StorageMax should be full, got %d days", f.DaysUntilFull)
+	}
+	if f := NewForecast(samples[len(samples)-1:], 2000, 5000); f.Days != 0 || f.DaysUntilFull != NeverFull {
+		t.Errorf("a single sample should not forecast anything, got %+v", *f)
+	}
+}
@@ -0,0 +1,279 @@
+// Package quota attributes the blocks of the repo to pins, MFS and the cache,
+// and enforces a separate quota on each of them, so that a growing cache can
+// not take the room needed for pinned data.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-mfs"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/gc"
+)
+
+// Namespace is a part of the repo blocks are attributed to.
+type Namespace string
+
+// Namespaces of the repo, by order of precedence: a block reachable from both
+// a pin and MFS is attributed to the pins.
+const (
+	Pins  Namespace = "pins"
+	MFS   Namespace = "mfs"
+	Cache Namespace = "cache"
+)
+
+// NoLimit is the quota of namespaces without one.
+const NoLimit uint64 = math.MaxUint64
+
+// MaxReportAge is how long a report is reused by Check before the blocks are
+// accounted again.
+const MaxReportAge = time.Minute
+
+// Usage is the space taken by the blocks of a namespace.
+type Usage struct {
+	Size       uint64 // size of the blocks in bytes
+	NumObjects uint64
+	Quota      uint64 // in bytes
+}
+
+// Report breaks down the blocks of the repo by namespace.
+type Report struct {
+	Pins  Usage
+	MFS   Usage
+	Cache Usage
+}
+
+// Get returns the usage of namespace ns.
+func (r *Report) Get(ns Namespace) Usage {
+	switch ns {
+	case Pins:
+		return r.Pins
+	case MFS:
+		return r.MFS
+	default:
+		return r.Cache
+	}
+}
+
+// ExceededError is returned by Check when a namespace is over quota.
+type ExceededError struct {
+	Namespace Namespace
+	Size      uint64
+	Quota     uint64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("%s quota exceeded: %s used out of %s (see Datastore.Quotas)",
+		e.Namespace, humanize.Bytes(e.Size), humanize.Bytes(e.Quota))
+}
+
+// Accountant attributes the blocks of the repo to namespaces and checks them
+// against their quotas. A nil *Accountant is valid and enforces no quota.
+type Accountant struct {
+	bs     bstore.Blockstore
+	pinner pin.Pinner
+	files  *mfs.Root
+	quotas map[Namespace]uint64
+
+	// extraFiles lists more MFS roots, such as the ones of the spaces
+	extraFiles func(context.Context) ([]cid.Cid, error)
+
+	mu       sync.Mutex
+	last     *Report
+	lastTime time.Time
+}
+
+// New returns an Accountant for the given blockstore, pinner and MFS root,
+// enforcing the quotas from cfg.
+func New(cfg config.DatastoreQuotas, bs bstore.Blockstore, pinner pin.Pinner, files *mfs.Root) (*Accountant, error) {
+	quotas := make(map[Namespace]uint64)
+	for ns, opt := range map[Namespace]*config.OptionalString{
+		Pins:  cfg.Pins,
+		MFS:   cfg.MFS,
+		Cache: cfg.Cache,
+	} {
+		quotas[ns] = NoLimit
+		if s := opt.WithDefault(""); s != "" {
+			q, err := humanize.ParseBytes(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Datastore.Quotas value for %s: %w", ns, err)
+			}
+			quotas[ns] = q
+		}
+	}
+	return &Accountant{bs: bs, pinner: pinner, files: files, quotas: quotas}, nil
+}
+
+// ProtectFiles attributes the blocks reachable from the roots listed by
+// roots to MFS as well. It must be called before the Accountant is used.
+func (a *Accountant) ProtectFiles(roots func(context.Context) ([]cid.Cid, error)) {
+	a.extraFiles = roots
+}
+
+// Quota returns the quota of namespace ns, NoLimit if it has none.
+func (a *Accountant) Quota(ns Namespace) uint64 {
+	if a == nil {
+		return NoLimit
+	}
+	return a.quotas[ns]
+}
+
+// Limited reports whether any namespace has a quota.
+func (a *Accountant) Limited() bool {
+	if a == nil {
+		return false
+	}
+	for _, q := range a.quotas {
+		if q != NoLimit {
+			return true
+		}
+	}
+	return false
+}
+
+// protected returns the multihashes of the blocks reachable from the pins and
+// from MFS.
+func (a *Accountant) protected(ctx context.Context) (pinned, files map[string]struct{}, err error) {
+	ng := dag.NewDAGService(bserv.New(a.bs, offline.Exchange(a.bs)))
+	// blocks missing locally take no space, skip them
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		links, err := ipld.GetLinks(ctx, ng, c)
+		if ipld.IsNotFound(err) {
+			return nil, nil
+		}
+		return links, err
+	}
+
+	pinnedSet := cid.NewSet()
+	rkeys, err := a.pinner.RecursiveKeys(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := gc.Descendants(ctx, getLinks, pinnedSet, rkeys); err != nil {
+		return nil, nil, err
+	}
+	dkeys, err := a.pinner.DirectKeys(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range dkeys {
+		pinnedSet.Add(k)
+	}
+
+	filesSet := cid.NewSet()
+	var filesRoots []cid.Cid
+	if a.files != nil {
+		root, err := a.files.GetDirectory().GetNode()
+		if err != nil {
+			return nil, nil, err
+		}
+		filesRoots = append(filesRoots, root.Cid())
+	}
+	if a.extraFiles != nil {
+		extra, err := a.extraFiles(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		filesRoots = append(filesRoots, extra...)
+	}
+	if err := gc.Descendants(ctx, getLinks, filesSet, filesRoots); err != nil {
+		return nil, nil, err
+	}
+
+	// the blockstore lists blocks by multihash, whatever their codec
+	byHash := func(set *cid.Set) map[string]struct{} {
+		m := make(map[string]struct{}, set.Len())
+		_ = set.ForEach(func(c cid.Cid) error {
+			m[string(c.Hash())] = struct{}{}
+			return nil
+		})
+		return m
+	}
+	return byHash(pinnedSet), byHash(filesSet), nil
+}
+
+// Report walks the pins and MFS, and attributes every block of the repo to
+// a namespace. It may take a while on large repos.
+func (a *Accountant) Report(ctx context.Context) (*Report, error) {
+	pinnedHashes, filesHashes, err := a.protected(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := a.bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{
+		Pins:  Usage{Quota: a.quotas[Pins]},
+		MFS:   Usage{Quota: a.quotas[MFS]},
+		Cache: Usage{Quota: a.quotas[Cache]},
+	}
+	for k := range keys {
+		size, err := a.bs.GetSize(ctx, k)
+		if ipld.IsNotFound(err) {
+			continue // removed in the meantime
+		} else if err != nil {
+			return nil, err
+		}
+
+		u := &r.Cache
+		if _, ok := pinnedHashes[string(k.Hash())]; ok {
+			u = &r.Pins
+		} else if _, ok := filesHashes[string(k.Hash())]; ok {
+			u = &r.MFS
+		}
+		u.Size += uint64(size)
+		u.NumObjects++
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	a.last, a.lastTime = r, time.Now()
+	a.mu.Unlock()
+	return r, nil
+}
+
+// Check returns an *ExceededError if namespace ns is over quota. It reuses
+// the last report if it is recent enough, so new data is refused once a
+// namespace went over quota, possibly a little late.
+func (a *Accountant) Check(ctx context.Context, ns Namespace) error {
+	if a.Quota(ns) == NoLimit {
+		return nil
+	}
+
+	a.mu.Lock()
+	r := a.last
+	if time.Since(a.lastTime) > MaxReportAge {
+		r = nil
+	}
+	a.mu.Unlock()
+
+	if r == nil {
+		var err error
+		r, err = a.Report(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if u := r.Get(ns); u.Size >= u.Quota {
+		return &ExceededError{Namespace: ns, Size: u.Size, Quota: u.Quota}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-mfs"
+	"github.com/ipfs/go-unixfs"
+
+	"github.com/ipfs/kubo/config"
+)
+
+func TestAccountant(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	pinner, err := dspinner.New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootNode := unixfs.EmptyDirNode()
+	if err := dserv.Add(ctx, rootNode); err != nil {
+		t.Fatal(err)
+	}
+	root, err := mfs.NewRoot(ctx, dserv, rootNode, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := dag.NodeWithData([]byte("pinned"))
+	shared := dag.NodeWithData([]byte("shared"))
+	cached := dag.NodeWithData([]byte("cached"))
+	if err := pinned.AddNodeLink("shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*dag.ProtoNode{pinned, shared, cached} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pinner.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfs.PutNode(root, "/shared", shared); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(config.DatastoreQuotas{
+		Pins:  config.NewOptionalString("1B"),
+		Cache: config.NewOptionalString("1MB"),
+	}, bs, pinner, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := a.Report(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the shared block is attributed to the pins
+	if r.Pins.NumObjects != 2 {
+		t.Fatalf("expected 2 pinned blocks, got %d", r.Pins.NumObjects)
+	}
+	if r.MFS.NumObjects == 0 || r.MFS.Quota != NoLimit {
+		t.Fatalf("unexpected MFS usage %+v", r.MFS)
+	}
+	if r.Cache.Size < uint64(len(cached.RawData())) || r.Cache.Quota != 1000*1000 {
+		t.Fatalf("unexpected cache usage %+v", r.Cache)
+	}
+
+	var exceeded *ExceededError
+	if err := a.Check(ctx, Pins); !errors.As(err, &exceeded) || exceeded.Namespace != Pins {
+		t.Fatalf("expected the pins quota to be exceeded, got %v", err)
+	}
+	if err := a.Check(ctx, Cache); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Check(ctx, MFS); err != nil {
+		t.Fatal(err)
+	}
+
+	var nilAccountant *Accountant
+	if err := nilAccountant.Check(ctx, Pins); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	access := NewAccessTimes()
+	bs := TrackAccess(bstore.NewBlockstore(dstore), access)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	pinner, err := dspinner.New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := dag.NodeWithData(make([]byte, 100))
+	if err := dserv.Add(ctx, pinned); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var cached []*dag.ProtoNode
+	for i := 0; i < 3; i++ {
+		nd := dag.NodeWithData([]byte{byte(i), 1, 2, 3, 4, 5, 6, 7, 8, 9})
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+		cached = append(cached, nd)
+	}
+	// make the first block the most recently used
+	if _, err := bs.Get(ctx, cached[0].Cid()); err != nil {
+		t.Fatal(err)
+	}
+
+	size := uint64(len(cached[0].RawData()))
+	a, err := New(config.DatastoreQuotas{
+		Cache: config.NewOptionalString(fmt.Sprintf("%dB", size)),
+	}, bs, pinner, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := a.Evict(ctx, bstore.NewGCLocker(), access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 2 || res.CacheSize != 3*size {
+		t.Fatalf("unexpected eviction result %+v", res)
+	}
+	for i, nd := range cached {
+		has, err := bs.Has(ctx, nd.Cid())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if has != (i == 0) {
+			t.Fatalf("unexpected presence of block %d: %t", i, has)
+		}
+	}
+	if has, _ := bs.Has(ctx, pinned.Cid()); !has {
+		t.Fatal("pinned block was evicted")
+	}
+}
@@ -0,0 +1,54 @@
+package repobackup
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	"github.com/ipfs/kubo/core/encryption"
+)
+
+// ErrPassphrase is returned when decrypting a backup with the wrong
+// passphrase, or a corrupted backup.
+var ErrPassphrase = errors.New("wrong passphrase or corrupted backup")
+
+// IsEncrypted returns whether the backup read by r is encrypted, without
+// consuming it.
+func IsEncrypted(r *bufio.Reader) bool {
+	return encryption.IsEncrypted(r)
+}
+
+// Encrypt returns a writer encrypting a backup with the passphrase to w, in
+// the age format. It must be closed to write the last chunk.
+func Encrypt(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	return encryption.EncryptPassphrase(w, passphrase)
+}
+
+// Decrypt returns a reader decrypting the backup read by r with the
+// passphrase.
+func Decrypt(r io.Reader, passphrase []byte) (io.Reader, error) {
+	dr, err := encryption.DecryptPassphrase(r, passphrase)
+	if err != nil {
+		return nil, passphraseErr(err)
+	}
+	return &decryptReader{dr}, nil
+}
+
+type decryptReader struct {
+	r io.Reader
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	return n, passphraseErr(err)
+}
+
+func passphraseErr(err error) error {
+	switch err {
+	case encryption.ErrDecrypt:
+		return ErrPassphrase
+	case encryption.ErrNotEncrypted:
+		return errors.New("not an encrypted backup")
+	}
+	return err
+}
@@ -0,0 +1,372 @@
+// Package repobackup writes and restores backups of a repo: its config, keys,
+// pins and MFS root, and optionally the blocks they reference.
+//
+// A backup is a tar archive holding, in this order:
+//
+//	manifest.json   the Manifest
+//	config          the config of the repo, including the identity key
+//	keystore/<name> the keys of the keystore, protobuf encoded
+//	blocks.car      the blocks of the pins and the MFS root, if included
+//
+// The archive can be encrypted with a passphrase, see Encrypt.
+package repobackup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	keystore "github.com/ipfs/go-ipfs-keystore"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	config "github.com/ipfs/kubo/config"
+	gocar "github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// FormatVersion is the version of the backup format.
+const FormatVersion = 1
+
+const (
+	manifestFile   = "manifest.json"
+	configFile     = "config"
+	keystorePrefix = "keystore/"
+	blocksFile     = "blocks.car"
+)
+
+// Manifest describes a backup.
+type Manifest struct {
+	Version int
+	Created time.Time
+	PeerID  string
+	// MFSRoot is the root of the files API, undefined if not backed up
+	MFSRoot cid.Cid
+	// Recursive and Direct are the pinned CIDs
+	Recursive []cid.Cid
+	Direct    []cid.Cid
+	// Blocks is the number of blocks in blocks.car, 0 if the blocks are not
+	// backed up
+	Blocks int
+}
+
+// Source is the state of the repo to back up.
+type Source struct {
+	Config   *config.Config
+	Keystore keystore.Keystore
+	Pinner   pin.Pinner
+	// MFSRoot is the flushed root of the files API.
+	MFSRoot cid.Cid
+	// Blocks is the blockstore to back up the blocks of the pins and MFS
+	// from, nil to leave out the blocks.
+	Blocks blockstore.Blockstore
+}
+
+// Snapshot is the state of a repo taken by Take, written by its Write method.
+type Snapshot struct {
+	manifest *Manifest
+	config   []byte
+	keys     []snapshotKey
+	blocks   *carBlocks
+}
+
+type snapshotKey struct {
+	name string
+	data []byte
+}
+
+// Take takes a snapshot of src: its config, keys, pins and MFS root, and the
+// list of the blocks to back up. The caller holds the pin lock meanwhile, so
+// that the pins and the MFS root are consistent, and releases it before the
+// snapshot is written. The blocks listed must not be garbage collected until
+// then.
+func Take(ctx context.Context, src Source) (*Snapshot, error) {
+	m := &Manifest{
+		Version: FormatVersion,
+		Created: time.Now().UTC(),
+		PeerID:  src.Config.Identity.PeerID,
+		MFSRoot: src.MFSRoot,
+	}
+	var err error
+	if m.Recursive, err = src.Pinner.RecursiveKeys(ctx); err != nil {
+		return nil, err
+	}
+	if m.Direct, err = src.Pinner.DirectKeys(ctx); err != nil {
+		return nil, err
+	}
+	s := &Snapshot{manifest: m}
+
+	// the blocks are listed first, to count them in the manifest and to
+	// size the CAR file in the archive
+	if src.Blocks != nil {
+		if s.blocks, err = listBlocks(ctx, src.Blocks, m); err != nil {
+			return nil, err
+		}
+		if s.blocks != nil {
+			m.Blocks = len(s.blocks.cids)
+		}
+	}
+
+	if s.config, err = config.Marshal(src.Config); err != nil {
+		return nil, err
+	}
+	names, err := src.Keystore.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		k, err := src.Keystore.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		data, err := crypto.MarshalPrivateKey(k)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, snapshotKey{name: name, data: data})
+	}
+	return s, nil
+}
+
+// Write writes the backup of the snapshot to w.
+func (s *Snapshot) Write(ctx context.Context, w io.Writer) (*Manifest, error) {
+	tw := tar.NewWriter(w)
+	add := func(name string, data []byte) error {
+		if err := tw.WriteHeader(entryHeader(name, int64(len(data)))); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+
+	manifest, err := json.MarshalIndent(s.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := add(manifestFile, manifest); err != nil {
+		return nil, err
+	}
+	if err := add(configFile, s.config); err != nil {
+		return nil, err
+	}
+	for _, k := range s.keys {
+		if err := add(keystorePrefix+k.name, k.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.blocks != nil {
+		if err := tw.WriteHeader(entryHeader(blocksFile, int64(s.blocks.size))); err != nil {
+			return nil, err
+		}
+		if err := s.blocks.write(ctx, tw); err != nil {
+			return nil, err
+		}
+	}
+	return s.manifest, tw.Close()
+}
+
+// Write writes a backup of src to w, see Take. The caller holds the pin lock,
+// so that the blocks aren't garbage collected meanwhile.
+func Write(ctx context.Context, w io.Writer, src Source) (*Manifest, error) {
+	s, err := Take(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return s.Write(ctx, w)
+}
+
+func entryHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Name:     name,
+		Mode:     0o600,
+		Size:     size,
+		ModTime:  time.Unix(0, 0),
+		Typeflag: tar.TypeReg,
+	}
+}
+
+// carBlocks are the blocks of the CAR file of a backup.
+type carBlocks struct {
+	bs     blockstore.Blockstore
+	header *gocar.CarHeader
+	cids   []cid.Cid
+	// size of the CAR file
+	size uint64
+}
+
+// listBlocks lists the local blocks of the DAGs of the recursive pins and the
+// MFS root, and the blocks of the direct pins. The blocks of the pins must be
+// local, while the MFS DAG may reference blocks which aren't.
+func listBlocks(ctx context.Context, bs blockstore.Blockstore, m *Manifest) (*carBlocks, error) {
+	roots := append(append([]cid.Cid(nil), m.Recursive...), m.Direct...)
+	if m.MFSRoot.Defined() {
+		roots = append(roots, m.MFSRoot)
+	}
+	if len(roots) == 0 {
+		// CAR files have at least one root
+		return nil, nil
+	}
+	cb := &carBlocks{bs: bs, header: &gocar.CarHeader{Roots: roots, Version: 1}}
+	headerSize, err := gocar.HeaderSize(cb.header)
+	if err != nil {
+		return nil, err
+	}
+	cb.size = headerSize
+
+	visited := cid.NewSet()
+	add := func(c cid.Cid) error {
+		size, err := bs.GetSize(ctx, c)
+		if err != nil {
+			return err
+		}
+		cb.cids = append(cb.cids, c)
+		cb.size += ldSize(uint64(len(c.Bytes()) + size))
+		return nil
+	}
+	ng := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	walk := func(root cid.Cid, required bool) error {
+		getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+			if err := add(c); err != nil {
+				if !required && ipld.IsNotFound(err) {
+					return nil, nil
+				}
+				return nil, fmt.Errorf("block %s: %w", c, err)
+			}
+			if c.Type() == cid.Raw {
+				return nil, nil
+			}
+			return ipld.GetLinks(ctx, ng, c)
+		}
+		return dag.Walk(ctx, getLinks, root, visited.Visit)
+	}
+
+	for _, c := range m.Recursive {
+		if err := walk(c, true); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range m.Direct {
+		if visited.Visit(c) {
+			if err := add(c); err != nil {
+				return nil, fmt.Errorf("block %s: %w", c, err)
+			}
+		}
+	}
+	if m.MFSRoot.Defined() {
+		if err := walk(m.MFSRoot, false); err != nil {
+			return nil, err
+		}
+	}
+	return cb, nil
+}
+
+// ldSize is the size of a length-delimited section of n bytes, prefixed by
+// its varint length.
+func ldSize(n uint64) uint64 {
+	size := n + 1
+	for ; n >= 0x80; n >>= 7 {
+		size++
+	}
+	return size
+}
+
+func (cb *carBlocks) write(ctx context.Context, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := gocar.WriteHeader(cb.header, bw); err != nil {
+		return err
+	}
+	for _, c := range cb.cids {
+		b, err := cb.bs.Get(ctx, c)
+		if err != nil {
+			return fmt.Errorf("block %s: %w", c, err)
+		}
+		if err := carutil.LdWrite(bw, c.Bytes(), b.RawData()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Target is where a backup is restored.
+type Target interface {
+	// Init initializes the repo with the config of the backup.
+	Init(cfg *config.Config) error
+	// PutKey adds a key to the keystore.
+	PutKey(name string, k crypto.PrivKey) error
+	// PutBlocks restores the blocks of blocks.car.
+	PutBlocks(ctx context.Context, r io.Reader) error
+}
+
+// Read reads the backup in r, restoring it to t, and returns its manifest.
+// The pins and the MFS root of the manifest are restored by the caller, once
+// the blocks are.
+func Read(ctx context.Context, r io.Reader, t Target) (*Manifest, error) {
+	tr := tar.NewReader(r)
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if m == nil && hdr.Name != manifestFile {
+			return nil, errors.New("not a repo backup: missing manifest")
+		}
+
+		switch name := hdr.Name; {
+		case name == manifestFile:
+			m = new(Manifest)
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("invalid manifest: %w", err)
+			}
+			if m.Version != FormatVersion {
+				return nil, fmt.Errorf("unsupported backup version %d", m.Version)
+			}
+		case name == configFile:
+			cfg := new(config.Config)
+			if err := json.NewDecoder(tr).Decode(cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+			if err := t.Init(cfg); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(name, keystorePrefix):
+			var data bytes.Buffer
+			if _, err := io.Copy(&data, tr); err != nil {
+				return nil, err
+			}
+			k, err := crypto.UnmarshalPrivateKey(data.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("invalid key %s: %w", name, err)
+			}
+			if err := t.PutKey(strings.TrimPrefix(name, keystorePrefix), k); err != nil {
+				return nil, err
+			}
+		case name == blocksFile:
+			if err := t.PutBlocks(ctx, tr); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unexpected file %s in the backup", name)
+		}
+	}
+	if m == nil {
+		return nil, errors.New("not a repo backup: missing manifest")
+	}
+	return m, nil
+}
@@ -0,0 +1,151 @@
+package repobackup
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	keystore "github.com/ipfs/go-ipfs-keystore"
+	"github.com/ipfs/go-ipfs-pinner/dspinner"
+	dag "github.com/ipfs/go-merkledag"
+	gocar "github.com/ipld/go-car"
+	crypto "github.com/libp2p/go-libp2p/core/crypto"
+
+	"github.com/ipfs/kubo/config"
+)
+
+type testTarget struct {
+	cfg  *config.Config
+	keys keystore.Keystore
+	bs   bstore.Blockstore
+}
+
+func (t *testTarget) Init(cfg *config.Config) error {
+	t.cfg = cfg
+	return nil
+}
+
+func (t *testTarget) PutKey(name string, k crypto.PrivKey) error {
+	return t.keys.Put(name, k)
+}
+
+func (t *testTarget) PutBlocks(ctx context.Context, r io.Reader) error {
+	_, err := gocar.LoadCar(ctx, t.bs, r)
+	return err
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	dstore := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(dstore)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+	pinner, err := dspinner.New(ctx, dstore, dserv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := dag.NodeWithData([]byte("pinned"))
+	child := dag.NodeWithData([]byte("child"))
+	direct := dag.NodeWithData([]byte("direct"))
+	mfsRoot := dag.NodeWithData([]byte("mfs"))
+	cached := dag.NodeWithData([]byte("cached"))
+	if err := pinned.AddNodeLink("child", child); err != nil {
+		t.Fatal(err)
+	}
+	// the MFS root may reference blocks which aren't local
+	if err := mfsRoot.AddNodeLink("missing", dag.NodeWithData([]byte("missing"))); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*dag.ProtoNode{pinned, child, direct, mfsRoot, cached} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pinner.Pin(ctx, pinned, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := pinner.Pin(ctx, direct, false); err != nil {
+		t.Fatal(err)
+	}
+
+	ks := keystore.NewMemKeystore()
+	k, _, err := crypto.GenerateEd25519Key(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Put("mykey", k); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Identity: config.Identity{PeerID: "QmTFauExutTsy4XP6JbMFcw2Wa9645HJt2bTqL6qYDCKfe"}}
+
+	var buf bytes.Buffer
+	enc, err := Encrypt(&buf, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := Write(ctx, enc, Source{
+		Config:   cfg,
+		Keystore: ks,
+		Pinner:   pinner,
+		MFSRoot:  mfsRoot.Cid(),
+		Blocks:   bs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if written.Blocks != 4 {
+		t.Fatalf("expected 4 blocks in the backup, got %d", written.Blocks)
+	}
+
+	if _, err := Decrypt(bytes.NewReader(buf.Bytes()), []byte("wrong")); err != ErrPassphrase {
+		t.Fatalf("expected %s, got %v", ErrPassphrase, err)
+	}
+	if !IsEncrypted(bufio.NewReader(bytes.NewReader(buf.Bytes()))) {
+		t.Fatal("expected the backup to be encrypted")
+	}
+
+	r, err := Decrypt(bytes.NewReader(buf.Bytes()), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &testTarget{
+		keys: keystore.NewMemKeystore(),
+		bs:   bstore.NewBlockstore(dssync.MutexWrap(ds.NewMapDatastore())),
+	}
+	m, err := Read(ctx, r, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if target.cfg == nil || target.cfg.Identity.PeerID != cfg.Identity.PeerID {
+		t.Fatal("config not restored")
+	}
+	if _, err := target.keys.Get("mykey"); err != nil {
+		t.Fatal(err)
+	}
+	if m.MFSRoot != mfsRoot.Cid() {
+		t.Fatalf("expected MFS root %s, got %s", mfsRoot.Cid(), m.MFSRoot)
+	}
+	if len(m.Recursive) != 1 || m.Recursive[0] != pinned.Cid() || len(m.Direct) != 1 || m.Direct[0] != direct.Cid() {
+		t.Fatalf("unexpected pins %v %v", m.Recursive, m.Direct)
+	}
+	for _, c := range []cid.Cid{pinned.Cid(), child.Cid(), direct.Cid(), mfsRoot.Cid()} {
+		if has, _ := target.bs.Has(ctx, c); !has {
+			t.Fatalf("block %s not restored", c)
+		}
+	}
+	if has, _ := target.bs.Has(ctx, cached.Cid()); has {
+		t.Fatal("unpinned block restored")
+	}
+}
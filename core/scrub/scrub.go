@@ -0,0 +1,250 @@
+// Package scrub slowly re-reads the blocks of the repo in the background,
+// verifies their hash and records the health of each shard of the blockstore,
+// so that the silent corruption of consumer drives is noticed before the
+// blocks are needed.
+package scrub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+
+	"github.com/ipfs/kubo/core/events"
+)
+
+var log = logging.Logger("core/scrub")
+
+// HealthPrefix is the prefix of the health records of the shards in the
+// datastore.
+var HealthPrefix = ds.NewKey("/local/scrub")
+
+// maxCorruptedBlocks is the number of corrupted blocks listed in the health
+// record of a shard.
+const maxCorruptedBlocks = 32
+
+// ShardHealth is the health record of a shard of the blockstore.
+type ShardHealth struct {
+	Shard string
+	// Checked is the time the shard was last checked.
+	Checked time.Time
+	Blocks  uint64
+	Size    uint64
+	// Corrupted is the number of blocks which failed to read or whose hash
+	// didn't match.
+	Corrupted uint64
+	// CorruptedBlocks lists the first corrupted blocks.
+	CorruptedBlocks []string `json:",omitempty"`
+}
+
+// Shard returns the shard of the blockstore a block is in. The shards are the
+// directories of the default flatfs datastore: the next to last two
+// characters of the datastore key.
+func Shard(c cid.Cid) string {
+	k := dshelp.MultihashToDsKey(c.Hash()).String()[1:]
+	for len(k) < 3 {
+		k = "_" + k
+	}
+	return k[len(k)-3 : len(k)-1]
+}
+
+// Health returns the health records of the shards, by shard.
+func Health(ctx context.Context, d ds.Datastore) (map[string]*ShardHealth, error) {
+	res, err := d.Query(ctx, query.Query{Prefix: HealthPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	health := make(map[string]*ShardHealth)
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		h := new(ShardHealth)
+		if err := json.Unmarshal(r.Value, h); err != nil {
+			return nil, fmt.Errorf("invalid health record %s: %w", r.Key, err)
+		}
+		health[h.Shard] = h
+	}
+	return health, nil
+}
+
+// Result summarizes a scrub.
+type Result struct {
+	Blocks    uint64
+	Size      uint64
+	Corrupted uint64
+	// Next is when the next shard is due to be checked.
+	Next time.Time
+}
+
+// Scrubber verifies the blocks of a datastore.
+type Scrubber struct {
+	bs       bstore.Blockstore
+	db       ds.Datastore
+	bus      *events.Bus
+	interval time.Duration
+	rate     uint64
+}
+
+// New returns a scrubber of the blocks of d, which checks each shard at most
+// once per interval, reading at most rate bytes per second. The health
+// records are stored in d. Corrupted blocks are reported on bus.
+func New(d ds.Batching, bus *events.Bus, interval time.Duration, rate uint64) *Scrubber {
+	bs := bstore.NewBlockstore(d)
+	bs.HashOnRead(true)
+	return &Scrubber{
+		bs:       bs,
+		db:       d,
+		bus:      bus,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+// Scrub checks the blocks of the shards not checked for the interval. The
+// health record of a shard is written once the keys of the next shard are
+// listed, so with flatfs, which lists the keys shard by shard, an
+// interrupted scrub resumes at the first shard it didn't check. Corrupted
+// blocks are reported, not removed.
+func (s *Scrubber) Scrub(ctx context.Context) (*Result, error) {
+	health, err := Health(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := s.bs.AllKeysChan(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		start   = time.Now()
+		due     = start.Add(-s.interval)
+		res     = new(Result)
+		checked = make(map[string]*ShardHealth)
+		last    *ShardHealth
+	)
+	for c := range keys {
+		shard := Shard(c)
+		h, ok := checked[shard]
+		if !ok {
+			if prev, ok := health[shard]; ok && prev.Checked.After(due) {
+				continue
+			}
+			h = &ShardHealth{Shard: shard}
+			checked[shard] = h
+		}
+		if last != nil && last != h {
+			if err := s.record(ctx, last); err != nil {
+				return nil, err
+			}
+		}
+		last = h
+
+		size, err := s.check(ctx, c, h)
+		if err != nil {
+			return nil, err
+		}
+		res.Blocks++
+		res.Size += size
+
+		// keep the average read rate under the limit
+		if s.rate > 0 {
+			wait := time.Duration(float64(res.Size)/float64(s.rate)*float64(time.Second)) - time.Since(start)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	res.Next = time.Now().Add(s.interval)
+	for shard, h := range checked {
+		if err := s.record(ctx, h); err != nil {
+			return nil, err
+		}
+		res.Corrupted += h.Corrupted
+		delete(health, shard)
+	}
+	for shard, h := range health {
+		if !h.Checked.After(due) {
+			// the blocks of the shard were all removed
+			if err := s.db.Delete(ctx, HealthPrefix.ChildString(shard)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if next := h.Checked.Add(s.interval); next.Before(res.Next) {
+			res.Next = next
+		}
+	}
+
+	s.bus.Emit(events.ScrubFinished, map[string]interface{}{
+		"Blocks":    res.Blocks,
+		"Size":      res.Size,
+		"Corrupted": res.Corrupted,
+		"Duration":  time.Since(start).String(),
+	})
+	return res, nil
+}
+
+// check reads a block, accounting it in the health record of its shard, and
+// returns its size.
+func (s *Scrubber) check(ctx context.Context, c cid.Cid, h *ShardHealth) (uint64, error) {
+	b, err := s.bs.Get(ctx, c)
+	switch {
+	case err == nil:
+		h.Blocks++
+		h.Size += uint64(len(b.RawData()))
+		return uint64(len(b.RawData())), nil
+	case ipld.IsNotFound(err):
+		// removed since it was listed
+		return 0, nil
+	case ctx.Err() != nil:
+		return 0, ctx.Err()
+	}
+
+	reason := "unreadable"
+	if errors.Is(err, bstore.ErrHashMismatch) {
+		reason = "hash mismatch"
+	}
+	log.Errorf("block %s in shard %s is corrupted (%s): %s", c, h.Shard, reason, err)
+	s.bus.Emit(events.BlockCorrupted, map[string]interface{}{
+		"Cid":   c.String(),
+		"Shard": h.Shard,
+		"Error": err.Error(),
+	})
+
+	h.Blocks++
+	h.Corrupted++
+	if len(h.CorruptedBlocks) < maxCorruptedBlocks {
+		h.CorruptedBlocks = append(h.CorruptedBlocks, c.String())
+	}
+	return 0, nil
+}
+
+func (s *Scrubber) record(ctx context.Context, h *ShardHealth) error {
+	h.Checked = time.Now()
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(ctx, HealthPrefix.ChildString(h.Shard), data)
+}
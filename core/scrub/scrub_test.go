@@ -0,0 +1,91 @@
+package scrub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	blocks "github.com/ipfs/go-block-format"
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+
+	"github.com/ipfs/kubo/core/events"
+)
+
+func TestScrub(t *testing.T) {
+	ctx := context.Background()
+	d := dssync.MutexWrap(ds.NewMapDatastore())
+	bs := bstore.NewBlockstore(d)
+
+	var blks []blocks.Block
+	for i := 0; i < 20; i++ {
+		b := blocks.NewBlock([]byte(fmt.Sprintf("block %d", i)))
+		if err := bs.Put(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+		blks = append(blks, b)
+	}
+	// the blocks are listed by their multihash, as raw CIDs
+	corrupted := cid.NewCidV1(cid.Raw, blks[3].Cid().Hash())
+	key := bstore.BlockPrefix.Child(dshelp.MultihashToDsKey(corrupted.Hash()))
+	if err := d.Put(ctx, key, []byte("bit rot")); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := events.NewBus()
+	sub := bus.Subscribe(0, events.BlockCorrupted)
+	defer sub.Close()
+
+	s := New(d, bus, time.Hour, 0)
+	res, err := s.Scrub(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 20 || res.Corrupted != 1 {
+		t.Errorf("expected 20 blocks checked and 1 corrupted, got %d and %d", res.Blocks, res.Corrupted)
+	}
+
+	select {
+	case evt := <-sub.Out():
+		if evt.Data["Cid"] != corrupted.String() {
+			t.Errorf("unexpected corrupted block %v", evt.Data["Cid"])
+		}
+	default:
+		t.Error("the corrupted block should be reported")
+	}
+
+	health, err := Health(ctx, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total uint64
+	for shard, h := range health {
+		total += h.Blocks
+		if shard == Shard(corrupted) {
+			if h.Corrupted != 1 || h.CorruptedBlocks[0] != corrupted.String() {
+				t.Errorf("the corrupted block should be recorded in its shard, got %+v", h)
+			}
+		} else if h.Corrupted != 0 {
+			t.Errorf("unexpected corrupted block in shard %s", shard)
+		}
+	}
+	if total != 20 {
+		t.Errorf("expected 20 blocks in the health records, got %d", total)
+	}
+
+	// the shards are not checked again before the interval
+	res, err = s.Scrub(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Blocks != 0 {
+		t.Errorf("expected no block checked, got %d", res.Blocks)
+	}
+	if res.Next.Before(time.Now().Add(time.Hour - time.Minute)) {
+		t.Errorf("the next check is too early: %s", res.Next)
+	}
+}
@@ -0,0 +1,242 @@
+package spaces
+
+import (
+	"context"
+	"fmt"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	pin "github.com/ipfs/go-ipfs-pinner"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-mfs"
+	"github.com/ipfs/go-unixfs"
+
+	"github.com/ipfs/kubo/gc"
+)
+
+// FilesRoot returns the MFS root of the space name, an empty directory for
+// the spaces that never used MFS.
+func (m *Manager) FilesRoot(ctx context.Context, name string) (*mfs.Root, error) {
+	if _, err := m.Get(ctx, name); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if root, ok := m.roots[name]; ok {
+		return root, nil
+	}
+
+	var nd *dag.ProtoNode
+	val, err := m.ds.Get(ctx, filesKey(name))
+	switch {
+	case err == datastore.ErrNotFound:
+		nd = unixfs.EmptyDirNode()
+		if err := m.dag.Add(ctx, nd); err != nil {
+			return nil, fmt.Errorf("failure writing to dagstore: %s", err)
+		}
+	case err == nil:
+		c, err := cid.Cast(val)
+		if err != nil {
+			return nil, err
+		}
+		rnd, err := m.dag.Get(ctx, c)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the MFS root of space %q: %s", name, err)
+		}
+		pbnd, ok := rnd.(*dag.ProtoNode)
+		if !ok {
+			return nil, dag.ErrNotProtobuf
+		}
+		nd = pbnd
+	default:
+		return nil, err
+	}
+
+	dsk := filesKey(name)
+	pf := func(ctx context.Context, c cid.Cid) error {
+		if err := m.ds.Put(ctx, dsk, c.Bytes()); err != nil {
+			return err
+		}
+		return m.ds.Sync(ctx, dsk)
+	}
+	root, err := mfs.NewRoot(m.ctx, m.dag, nd, pf)
+	if err != nil {
+		return nil, err
+	}
+	m.roots[name] = root
+	return root, nil
+}
+
+// FilesRoots returns the MFS roots of all the spaces, kept by the garbage
+// collector as best effort roots.
+func (m *Manager) FilesRoots(ctx context.Context) ([]cid.Cid, error) {
+	spaces, err := m.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []cid.Cid
+	for _, info := range spaces {
+		c, ok, err := m.filesRoot(ctx, info.Name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			roots = append(roots, c)
+		}
+	}
+	return roots, nil
+}
+
+// filesRoot returns the current MFS root of the space name, if any.
+func (m *Manager) filesRoot(ctx context.Context, name string) (cid.Cid, bool, error) {
+	m.mu.Lock()
+	root, open := m.roots[name]
+	m.mu.Unlock()
+
+	if open {
+		nd, err := root.GetDirectory().GetNode()
+		if err != nil {
+			return cid.Undef, false, err
+		}
+		return nd.Cid(), true, nil
+	}
+
+	val, err := m.ds.Get(ctx, filesKey(name))
+	if err == datastore.ErrNotFound {
+		return cid.Undef, false, nil
+	}
+	if err != nil {
+		return cid.Undef, false, err
+	}
+	c, err := cid.Cast(val)
+	if err != nil {
+		return cid.Undef, false, err
+	}
+	return c, true, nil
+}
+
+// Usage returns the size in bytes of the local blocks reachable from the
+// pins and the MFS root of the space name. A block shared with other spaces
+// counts in each of them.
+func (m *Manager) Usage(ctx context.Context, name string) (uint64, error) {
+	if _, err := m.Get(ctx, name); err != nil {
+		return 0, err
+	}
+	return m.usage(ctx, name, nil)
+}
+
+// usage is Usage, counting the extra pins as well.
+func (m *Manager) usage(ctx context.Context, name string, extra []Pin) (uint64, error) {
+	pins, err := m.pins(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	pins = append(pins, extra...)
+
+	// blocks missing locally take no space, skip them
+	getLinks := func(ctx context.Context, c cid.Cid) ([]*ipld.Link, error) {
+		links, err := ipld.GetLinks(ctx, m.local, c)
+		if ipld.IsNotFound(err) {
+			return nil, nil
+		}
+		return links, err
+	}
+
+	set := cid.NewSet()
+	var recursive []cid.Cid
+	for _, p := range pins {
+		if p.Recursive {
+			recursive = append(recursive, p.Cid)
+		} else {
+			set.Add(p.Cid)
+		}
+	}
+	if c, ok, err := m.filesRoot(ctx, name); err != nil {
+		return 0, err
+	} else if ok {
+		recursive = append(recursive, c)
+	}
+	if err := gc.Descendants(ctx, getLinks, set, recursive); err != nil {
+		return 0, err
+	}
+
+	// the same block may be linked with different CIDs
+	seen := make(map[string]struct{}, set.Len())
+	var size uint64
+	err = set.ForEach(func(c cid.Cid) error {
+		if _, ok := seen[string(c.Hash())]; ok {
+			return nil
+		}
+		seen[string(c.Hash())] = struct{}{}
+		s, err := m.bs.GetSize(ctx, c)
+		if ipld.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size += uint64(s)
+		return nil
+	})
+	return size, err
+}
+
+// Check returns an *ExceededError if the space name is over quota.
+func (m *Manager) Check(ctx context.Context, name string) error {
+	info, err := m.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	if info.Quota == NoLimit {
+		return nil
+	}
+	size, err := m.usage(ctx, name, nil)
+	if err != nil {
+		return err
+	}
+	if size >= info.Quota {
+		return &ExceededError{Space: name, Size: size, Quota: info.Quota}
+	}
+	return nil
+}
+
+// Pinner returns pn, also listing the roots pinned in the spaces, so that
+// the garbage collector and the quotas of the repo keep them.
+func (m *Manager) Pinner(pn pin.Pinner) pin.Pinner {
+	if m == nil {
+		return pn
+	}
+	return &spacesPinner{Pinner: pn, m: m}
+}
+
+type spacesPinner struct {
+	pin.Pinner
+	m *Manager
+}
+
+func (p *spacesPinner) RecursiveKeys(ctx context.Context) ([]cid.Cid, error) {
+	keys, err := p.Pinner.RecursiveKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := p.m.Roots(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, roots...), nil
+}
+
+func (p *spacesPinner) DirectKeys(ctx context.Context) ([]cid.Cid, error) {
+	keys, err := p.Pinner.DirectKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := p.m.Roots(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, roots...), nil
+}
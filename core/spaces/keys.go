@@ -0,0 +1,22 @@
+package spaces
+
+import "strings"
+
+// keySeparator separates the name of a space from the names of its keys in
+// the keystore.
+const keySeparator = "."
+
+// KeyName returns the name in the keystore of the key name of space.
+func KeyName(space, name string) string {
+	return space + keySeparator + name
+}
+
+// KeyInSpace returns the name in space of the key of the keystore named key,
+// false if it is not a key of space.
+func KeyInSpace(space, key string) (string, bool) {
+	prefix := space + keySeparator
+	if !strings.HasPrefix(key, prefix) || len(key) == len(prefix) {
+		return "", false
+	}
+	return key[len(prefix):], true
+}
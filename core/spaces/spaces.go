@@ -0,0 +1,459 @@
+// Package spaces splits a node into named spaces, each with its own pinset,
+// MFS root and quota, so that one node can serve several projects in
+// isolation. The spaces share the blockstore of the repo: the roots pinned in
+// the spaces are refcounted, and kept by the garbage collector as long as one
+// space pins them.
+package spaces
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	multierror "github.com/hashicorp/go-multierror"
+	bserv "github.com/ipfs/go-blockservice"
+	cid "github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	ipld "github.com/ipfs/go-ipld-format"
+	logging "github.com/ipfs/go-log"
+	dag "github.com/ipfs/go-merkledag"
+	"github.com/ipfs/go-mfs"
+)
+
+var log = logging.Logger("spaces")
+
+var (
+	dsPrefix      = datastore.NewKey("/local/spaces")
+	infoPrefix    = dsPrefix.ChildString("info")
+	pinsPrefix    = dsPrefix.ChildString("pins")
+	filesPrefix   = dsPrefix.ChildString("files")
+	refsRecursive = dsPrefix.ChildString("refs").ChildString("recursive")
+	refsDirect    = dsPrefix.ChildString("refs").ChildString("direct")
+)
+
+// NoLimit is the quota of the spaces without one.
+const NoLimit uint64 = 0
+
+// ErrNotFound is returned for the spaces that don't exist.
+var ErrNotFound = errors.New("space not found")
+
+// Info describes a space.
+type Info struct {
+	Name    string
+	Quota   uint64 // in bytes, NoLimit if none
+	Created time.Time
+}
+
+// Pin is a root pinned in a space.
+type Pin struct {
+	Cid       cid.Cid
+	Recursive bool
+}
+
+// ExceededError is returned when a space is over quota.
+type ExceededError struct {
+	Space string
+	Size  uint64
+	Quota uint64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("space %q quota exceeded: %d bytes used out of %d", e.Space, e.Size, e.Quota)
+}
+
+// Manager stores the spaces of the node in the datastore.
+type Manager struct {
+	ds     datastore.Datastore
+	dag    ipld.DAGService // fetches the pinned DAGs
+	local  ipld.DAGService // the blockstore only, to account the spaces
+	bs     bstore.Blockstore
+	locker bstore.GCLocker
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu serializes the updates of the pinsets and the refcounts, and
+	// guards roots
+	mu    sync.Mutex
+	roots map[string]*mfs.Root
+}
+
+// New returns the Manager of the spaces stored in ds. The pinned DAGs are
+// fetched with dserv, and accounted in bs.
+func New(ds datastore.Datastore, dserv ipld.DAGService, bs bstore.Blockstore, locker bstore.GCLocker) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		ds:     ds,
+		dag:    dserv,
+		local:  dag.NewDAGService(bserv.New(bs, offline.Exchange(bs))),
+		bs:     bs,
+		locker: locker,
+		ctx:    ctx,
+		cancel: cancel,
+		roots:  make(map[string]*mfs.Root),
+	}
+}
+
+// Close closes the MFS roots of the spaces.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs error
+	for name, root := range m.roots {
+		if err := root.Close(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("space %q: %w", name, err))
+		}
+		delete(m.roots, name)
+	}
+	m.cancel()
+	return errs
+}
+
+// ValidateName checks the name of a space is a non-empty word. The names
+// prefix the keys of the spaces, see KeyName.
+func ValidateName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n/"+keySeparator) {
+		return fmt.Errorf("invalid space name %q: must be a non-empty word without '/' or %q", name, keySeparator)
+	}
+	return nil
+}
+
+func infoKey(name string) datastore.Key {
+	return infoPrefix.ChildString(name)
+}
+
+func spacePinsPrefix(name string) datastore.Key {
+	return pinsPrefix.ChildString(name)
+}
+
+func pinKey(name string, c cid.Cid) datastore.Key {
+	return spacePinsPrefix(name).Child(dshelp.NewKeyFromBinary(c.Bytes()))
+}
+
+func filesKey(name string) datastore.Key {
+	return filesPrefix.ChildString(name)
+}
+
+func refKey(c cid.Cid, recursive bool) datastore.Key {
+	prefix := refsDirect
+	if recursive {
+		prefix = refsRecursive
+	}
+	return prefix.Child(dshelp.NewKeyFromBinary(c.Bytes()))
+}
+
+func cidFromKey(k string) (cid.Cid, error) {
+	b, err := dshelp.BinaryFromDsKey(datastore.NewKey(datastore.RawKey(k).BaseNamespace()))
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Cast(b)
+}
+
+// Create creates the space name with the given quota.
+func (m *Manager) Create(ctx context.Context, name string, quota uint64) (Info, error) {
+	if err := ValidateName(name); err != nil {
+		return Info{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if has, err := m.ds.Has(ctx, infoKey(name)); err != nil {
+		return Info{}, err
+	} else if has {
+		return Info{}, fmt.Errorf("space %q already exists", name)
+	}
+	info := Info{Name: name, Quota: quota, Created: time.Now().UTC()}
+	return info, m.putInfo(ctx, info)
+}
+
+func (m *Manager) putInfo(ctx context.Context, info Info) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return m.ds.Put(ctx, infoKey(info.Name), b)
+}
+
+// Get returns the space name, ErrNotFound if it doesn't exist.
+func (m *Manager) Get(ctx context.Context, name string) (Info, error) {
+	b, err := m.ds.Get(ctx, infoKey(name))
+	if err == datastore.ErrNotFound {
+		return Info{}, fmt.Errorf("%w: %q, see 'ipfs space create'", ErrNotFound, name)
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	var info Info
+	if err := json.Unmarshal(b, &info); err != nil {
+		return Info{}, fmt.Errorf("space %q: %w", name, err)
+	}
+	return info, nil
+}
+
+// List returns the spaces, sorted by name.
+func (m *Manager) List(ctx context.Context) ([]Info, error) {
+	res, err := m.ds.Query(ctx, query.Query{Prefix: infoPrefix.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var spaces []Info
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		var info Info
+		if err := json.Unmarshal(r.Value, &info); err != nil {
+			return nil, fmt.Errorf("space %q: %w", datastore.RawKey(r.Key).BaseNamespace(), err)
+		}
+		spaces = append(spaces, info)
+	}
+	sort.Slice(spaces, func(i, j int) bool { return spaces[i].Name < spaces[j].Name })
+	return spaces, nil
+}
+
+// SetQuota replaces the quota of the space name.
+func (m *Manager) SetQuota(ctx context.Context, name string, quota uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	info.Quota = quota
+	return m.putInfo(ctx, info)
+}
+
+// Remove removes the space name, along with its pins and its MFS root. The
+// blocks only referenced by the space are removed by the next garbage
+// collection.
+func (m *Manager) Remove(ctx context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.Get(ctx, name); err != nil {
+		return err
+	}
+	pins, err := m.pins(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, p := range pins {
+		if err := m.unref(ctx, p.Cid, p.Recursive); err != nil {
+			return err
+		}
+		if err := m.ds.Delete(ctx, pinKey(name, p.Cid)); err != nil {
+			return err
+		}
+	}
+	if root, ok := m.roots[name]; ok {
+		if err := root.Close(); err != nil {
+			log.Errorf("failed to close the MFS root of space %q: %s", name, err)
+		}
+		delete(m.roots, name)
+	}
+	if err := m.ds.Delete(ctx, filesKey(name)); err != nil {
+		return err
+	}
+	return m.ds.Delete(ctx, infoKey(name))
+}
+
+// Pin fetches the DAG of c, the root only unless recursive, and pins it in
+// the space name. It fails with an *ExceededError when the DAG doesn't fit
+// in the quota of the space.
+func (m *Manager) Pin(ctx context.Context, name string, c cid.Cid, recursive bool) error {
+	info, err := m.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	// the fetched blocks must not be collected before being pinned
+	unlocker := m.locker.PinLock(ctx)
+	defer unlocker.Unlock(ctx)
+
+	if recursive {
+		err = dag.FetchGraph(ctx, c, m.dag)
+	} else {
+		_, err = m.dag.Get(ctx, c)
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Quota != NoLimit {
+		size, err := m.usage(ctx, name, []Pin{{Cid: c, Recursive: recursive}})
+		if err != nil {
+			return err
+		}
+		if size > info.Quota {
+			return &ExceededError{Space: name, Size: size, Quota: info.Quota}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, pinned, err := m.pinMode(ctx, name, c)
+	if err != nil {
+		return err
+	}
+	if pinned {
+		if current || !recursive {
+			// already pinned, recursive pins cover direct ones
+			return nil
+		}
+		if err := m.unref(ctx, c, false); err != nil {
+			return err
+		}
+	}
+	if err := m.ds.Put(ctx, pinKey(name, c), []byte(modeString(recursive))); err != nil {
+		return err
+	}
+	return m.ref(ctx, c, recursive)
+}
+
+// Unpin removes the pin of c from the space name.
+func (m *Manager) Unpin(ctx context.Context, name string, c cid.Cid, recursive bool) error {
+	if _, err := m.Get(ctx, name); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, pinned, err := m.pinMode(ctx, name, c)
+	if err != nil {
+		return err
+	}
+	if !pinned {
+		return fmt.Errorf("%s is not pinned in space %q", c, name)
+	}
+	if current && !recursive {
+		return fmt.Errorf("%s is pinned recursively in space %q", c, name)
+	}
+	if err := m.ds.Delete(ctx, pinKey(name, c)); err != nil {
+		return err
+	}
+	return m.unref(ctx, c, current)
+}
+
+func modeString(recursive bool) string {
+	if recursive {
+		return "recursive"
+	}
+	return "direct"
+}
+
+// pinMode tells whether c is pinned in the space name, and if recursively.
+func (m *Manager) pinMode(ctx context.Context, name string, c cid.Cid) (recursive, pinned bool, err error) {
+	v, err := m.ds.Get(ctx, pinKey(name, c))
+	if err == datastore.ErrNotFound {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	return string(v) == "recursive", true, nil
+}
+
+// Pins returns the roots pinned in the space name.
+func (m *Manager) Pins(ctx context.Context, name string) ([]Pin, error) {
+	if _, err := m.Get(ctx, name); err != nil {
+		return nil, err
+	}
+	return m.pins(ctx, name)
+}
+
+func (m *Manager) pins(ctx context.Context, name string) ([]Pin, error) {
+	res, err := m.ds.Query(ctx, query.Query{Prefix: spacePinsPrefix(name).String()})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var pins []Pin
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		c, err := cidFromKey(r.Key)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, Pin{Cid: c, Recursive: string(r.Value) == "recursive"})
+	}
+	return pins, nil
+}
+
+// RefCount returns the number of spaces pinning c, recursively or directly.
+func (m *Manager) RefCount(ctx context.Context, c cid.Cid, recursive bool) (int, error) {
+	v, err := m.ds.Get(ctx, refKey(c, recursive))
+	if err == datastore.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(v))
+}
+
+func (m *Manager) ref(ctx context.Context, c cid.Cid, recursive bool) error {
+	n, err := m.RefCount(ctx, c, recursive)
+	if err != nil {
+		return err
+	}
+	return m.ds.Put(ctx, refKey(c, recursive), []byte(strconv.Itoa(n+1)))
+}
+
+func (m *Manager) unref(ctx context.Context, c cid.Cid, recursive bool) error {
+	n, err := m.RefCount(ctx, c, recursive)
+	if err != nil {
+		return err
+	}
+	if n <= 1 {
+		return m.ds.Delete(ctx, refKey(c, recursive))
+	}
+	return m.ds.Put(ctx, refKey(c, recursive), []byte(strconv.Itoa(n-1)))
+}
+
+// Roots returns the roots pinned by at least one space, recursively or
+// directly.
+func (m *Manager) Roots(ctx context.Context, recursive bool) ([]cid.Cid, error) {
+	prefix := refsDirect
+	if recursive {
+		prefix = refsRecursive
+	}
+	res, err := m.ds.Query(ctx, query.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var roots []cid.Cid
+	for r := range res.Next() {
+		if r.Error != nil {
+			return nil, r.Error
+		}
+		c, err := cidFromKey(r.Key)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, c)
+	}
+	return roots, nil
+}
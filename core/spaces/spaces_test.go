@@ -0,0 +1,146 @@
+package spaces
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	bserv "github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	offline "github.com/ipfs/go-ipfs-exchange-offline"
+	dag "github.com/ipfs/go-merkledag"
+)
+
+func newManager(t *testing.T) (*Manager, *dag.ProtoNode) {
+	ctx := context.Background()
+	ds := dssync.MutexWrap(datastore.NewMapDatastore())
+	bs := bstore.NewBlockstore(ds)
+	dserv := dag.NewDAGService(bserv.New(bs, offline.Exchange(bs)))
+
+	leaf := dag.NodeWithData([]byte("leaf"))
+	root := dag.NodeWithData([]byte("root"))
+	if err := root.AddNodeLink("leaf", leaf); err != nil {
+		t.Fatal(err)
+	}
+	for _, nd := range []*dag.ProtoNode{leaf, root} {
+		if err := dserv.Add(ctx, nd); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := New(ds, dserv, bs, bstore.NewGCLocker())
+	t.Cleanup(func() { m.Close() })
+	return m, root
+}
+
+func TestRefCount(t *testing.T) {
+	ctx := context.Background()
+	m, root := newManager(t)
+
+	for _, name := range []string{"a", "b"} {
+		if _, err := m.Create(ctx, name, NoLimit); err != nil {
+			t.Fatal(err)
+		}
+		if err := m.Pin(ctx, name, root.Cid(), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.Create(ctx, "a", NoLimit); err == nil {
+		t.Fatal("expected spaces to be created once")
+	}
+
+	count := func(expected int) {
+		t.Helper()
+		n, err := m.RefCount(ctx, root.Cid(), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != expected {
+			t.Fatalf("expected a refcount of %d, got %d", expected, n)
+		}
+		roots, err := m.Roots(ctx, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(roots) > 0) != (expected > 0) {
+			t.Fatalf("expected the root to be kept only while referenced, got %v", roots)
+		}
+	}
+	count(2)
+
+	// pinning again doesn't count twice
+	if err := m.Pin(ctx, "a", root.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	count(2)
+
+	if err := m.Unpin(ctx, "a", root.Cid(), false); err == nil {
+		t.Fatal("expected recursive pins to be removed recursively")
+	}
+	if err := m.Unpin(ctx, "a", root.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	count(1)
+
+	if err := m.Remove(ctx, "b"); err != nil {
+		t.Fatal(err)
+	}
+	count(0)
+	if _, err := m.Get(ctx, "b"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected the space to be removed, got %v", err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	m, root := newManager(t)
+
+	if _, err := m.Create(ctx, "small", 1); err != nil {
+		t.Fatal(err)
+	}
+	var exceeded *ExceededError
+	if err := m.Pin(ctx, "small", root.Cid(), true); !errors.As(err, &exceeded) {
+		t.Fatalf("expected the quota to be exceeded, got %v", err)
+	}
+	pins, err := m.Pins(ctx, "small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 0 {
+		t.Fatalf("expected nothing pinned over quota, got %v", pins)
+	}
+
+	if err := m.SetQuota(ctx, "small", 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Pin(ctx, "small", root.Cid(), true); err != nil {
+		t.Fatal(err)
+	}
+	size, err := m.Usage(ctx, "small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size < uint64(len(root.RawData())) {
+		t.Fatalf("expected the pinned blocks to be accounted, got %d bytes", size)
+	}
+	if err := m.Check(ctx, "small"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyName(t *testing.T) {
+	key := KeyName("docs", "site")
+	if name, ok := KeyInSpace("docs", key); !ok || name != "site" {
+		t.Fatalf("got %q, %v", name, ok)
+	}
+	for _, key := range []string{"site", "docs.", "docsite", "doc.site", "docs2.site"} {
+		if _, ok := KeyInSpace("docs", key); ok {
+			t.Fatalf("%q is not a key of the space", key)
+		}
+	}
+	if err := ValidateName("a.b"); err == nil {
+		t.Fatal("names with the key separator must be refused")
+	}
+}
@@ -0,0 +1,332 @@
+// Package unixfspatch edits UnixFS DAGs under arbitrary roots. It is the
+// immutable counterpart of MFS: every edit returns a new root, which shares
+// the unchanged subtrees with the old one.
+//
+// Unlike the deprecated object patch API, directories are edited as UnixFS
+// directories, so HAMT-sharded directories work, and directories are sharded
+// or unsharded as needed.
+package unixfspatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	ft "github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	ErrNotDir    = errors.New("not a unixfs directory")
+	ErrNotFile   = errors.New("not a unixfs file")
+	ErrNotUnixFS = errors.New("not a unixfs node")
+	ErrRootPath  = errors.New("path must name an entry under the root")
+)
+
+// The fields of the UnixFS Data message added by UnixFS 1.5, which the
+// go-unixfs protobuf doesn't know about. They are kept as unknown fields.
+const (
+	fieldMode  protowire.Number = 7
+	fieldMtime protowire.Number = 8
+)
+
+// Editor edits the DAGs of a DAGService. The new nodes are added to it, but
+// not pinned.
+type Editor struct {
+	dag ipld.DAGService
+}
+
+// New returns an Editor of the DAGs of ds.
+func New(ds ipld.DAGService) *Editor {
+	return &Editor{dag: ds}
+}
+
+// AddLink sets the entry at p under root to child, which must already be in
+// the DAGService, replacing any existing entry. The missing directories of p
+// are created if parents is set.
+func (e *Editor) AddLink(ctx context.Context, root ipld.Node, p string, child ipld.Node, parents bool) (ipld.Node, error) {
+	segs := split(p)
+	if len(segs) == 0 {
+		return nil, ErrRootPath
+	}
+	name := segs[len(segs)-1]
+	return e.edit(ctx, root, segs[:len(segs)-1], parents, func(nd ipld.Node) (ipld.Node, error) {
+		dir, err := uio.NewDirectoryFromNode(e.dag, nd)
+		if err != nil {
+			return nil, ErrNotDir
+		}
+		if err := dir.AddChild(ctx, name, child); err != nil {
+			return nil, err
+		}
+		return e.dirNode(nd, dir)
+	})
+}
+
+// RmLink removes the entry at p under root.
+func (e *Editor) RmLink(ctx context.Context, root ipld.Node, p string) (ipld.Node, error) {
+	segs := split(p)
+	if len(segs) == 0 {
+		return nil, ErrRootPath
+	}
+	name := segs[len(segs)-1]
+	return e.edit(ctx, root, segs[:len(segs)-1], false, func(nd ipld.Node) (ipld.Node, error) {
+		dir, err := uio.NewDirectoryFromNode(e.dag, nd)
+		if err != nil {
+			return nil, ErrNotDir
+		}
+		if err := dir.RemoveChild(ctx, name); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("%q: %w", p, err)
+			}
+			return nil, err
+		}
+		return e.dirNode(nd, dir)
+	})
+}
+
+// SetData replaces the file at p under root, "" for root itself, with file,
+// which must already be in the DAGService. The mode and mtime of the old file
+// are kept.
+func (e *Editor) SetData(ctx context.Context, root ipld.Node, p string, file ipld.Node) (ipld.Node, error) {
+	return e.edit(ctx, root, split(p), false, func(nd ipld.Node) (ipld.Node, error) {
+		if err := checkFile(nd); err != nil {
+			return nil, err
+		}
+		if err := checkFile(file); err != nil {
+			return nil, err
+		}
+		return e.copyMeta(nd, file)
+	})
+}
+
+// Chmod sets the UnixFS mode of the node at p under root, "" for root itself,
+// to the permission bits of mode.
+func (e *Editor) Chmod(ctx context.Context, root ipld.Node, p string, mode os.FileMode) (ipld.Node, error) {
+	field := protowire.AppendTag(nil, fieldMode, protowire.VarintType)
+	field = protowire.AppendVarint(field, uint64(mode&07777))
+	return e.edit(ctx, root, split(p), false, func(nd ipld.Node) (ipld.Node, error) {
+		return e.setField(nd, fieldMode, field)
+	})
+}
+
+// Touch sets the UnixFS mtime of the node at p under root, "" for root
+// itself.
+func (e *Editor) Touch(ctx context.Context, root ipld.Node, p string, mtime time.Time) (ipld.Node, error) {
+	var ts []byte
+	ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+	ts = protowire.AppendVarint(ts, uint64(mtime.Unix()))
+	if ns := mtime.Nanosecond(); ns != 0 {
+		ts = protowire.AppendTag(ts, 2, protowire.Fixed32Type)
+		ts = protowire.AppendFixed32(ts, uint32(ns))
+	}
+	field := protowire.AppendTag(nil, fieldMtime, protowire.BytesType)
+	field = protowire.AppendBytes(field, ts)
+	return e.edit(ctx, root, split(p), false, func(nd ipld.Node) (ipld.Node, error) {
+		return e.setField(nd, fieldMtime, field)
+	})
+}
+
+// edit replaces the node at segs under nd with the result of fn, and returns
+// the new nd. The missing directories are created if parents is set.
+func (e *Editor) edit(ctx context.Context, nd ipld.Node, segs []string, parents bool, fn func(ipld.Node) (ipld.Node, error)) (ipld.Node, error) {
+	if len(segs) == 0 {
+		out, err := fn(nd)
+		if err != nil {
+			return nil, err
+		}
+		return out, e.dag.Add(ctx, out)
+	}
+
+	dir, err := uio.NewDirectoryFromNode(e.dag, nd)
+	if err != nil {
+		return nil, fmt.Errorf("%q: %w", segs[0], ErrNotDir)
+	}
+	child, err := dir.Find(ctx, segs[0])
+	switch {
+	case errors.Is(err, os.ErrNotExist) && parents:
+		empty := ft.EmptyDirNode()
+		if err := empty.SetCidBuilder(nd.Cid().Prefix()); err != nil {
+			return nil, err
+		}
+		child = empty
+	case errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("%q: %w", segs[0], err)
+	case err != nil:
+		return nil, err
+	}
+
+	child, err = e.edit(ctx, child, segs[1:], parents, fn)
+	if err != nil {
+		return nil, err
+	}
+	if err := dir.AddChild(ctx, segs[0], child); err != nil {
+		return nil, err
+	}
+	out, err := e.dirNode(nd, dir)
+	if err != nil {
+		return nil, err
+	}
+	return out, e.dag.Add(ctx, out)
+}
+
+// dirNode returns the node of the edited dir, with the mode and mtime of its
+// old node: the root of a HAMT is rebuilt from scratch.
+func (e *Editor) dirNode(old ipld.Node, dir uio.Directory) (ipld.Node, error) {
+	nd, err := dir.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	return e.copyMeta(old, nd)
+}
+
+// copyMeta returns to with the mode and mtime of from, if any.
+func (e *Editor) copyMeta(from, to ipld.Node) (ipld.Node, error) {
+	pn, ok := from.(*dag.ProtoNode)
+	if !ok {
+		return to, nil
+	}
+	out := to
+	for _, num := range []protowire.Number{fieldMode, fieldMtime} {
+		field, err := getField(pn.Data(), num)
+		if err != nil {
+			return nil, err
+		}
+		if field == nil {
+			continue
+		}
+		if out, err = e.setField(out, num, field); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// setField returns a copy of the UnixFS node nd, with the field num of its
+// data replaced by field. Raw leaves are wrapped in a dag-pb file node.
+func (e *Editor) setField(nd ipld.Node, num protowire.Number, field []byte) (ipld.Node, error) {
+	var pn *dag.ProtoNode
+	switch n := nd.(type) {
+	case *dag.ProtoNode:
+		if _, err := ft.FSNodeFromBytes(n.Data()); err != nil {
+			return nil, ErrNotUnixFS
+		}
+		pn = n.Copy().(*dag.ProtoNode)
+	case *dag.RawNode:
+		var err error
+		if pn, err = wrapRaw(n); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrNotUnixFS
+	}
+
+	data, err := replaceField(pn.Data(), num, field)
+	if err != nil {
+		return nil, err
+	}
+	pn.SetData(data)
+	return pn, nil
+}
+
+// wrapRaw returns a file node with the raw leaf as its only block, which can
+// hold a mode and mtime.
+func wrapRaw(raw *dag.RawNode) (*dag.ProtoNode, error) {
+	fsn := ft.NewFSNode(ft.TFile)
+	fsn.AddBlockSize(uint64(len(raw.RawData())))
+	data, err := fsn.GetBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := raw.Cid().Prefix()
+	prefix.Codec = cid.DagProtobuf
+	pn := dag.NodeWithData(data)
+	if err := pn.SetCidBuilder(prefix); err != nil {
+		return nil, err
+	}
+	if err := pn.AddNodeLink("", raw); err != nil {
+		return nil, err
+	}
+	return pn, nil
+}
+
+func checkFile(nd ipld.Node) error {
+	switch n := nd.(type) {
+	case *dag.RawNode:
+		return nil
+	case *dag.ProtoNode:
+		fsn, err := ft.FSNodeFromBytes(n.Data())
+		if err != nil {
+			return ErrNotUnixFS
+		}
+		if t := fsn.Type(); t != ft.TFile && t != ft.TRaw {
+			return ErrNotFile
+		}
+		return nil
+	default:
+		return ErrNotUnixFS
+	}
+}
+
+// getField returns the encoded field num of the protobuf message data, nil if
+// it is absent.
+func getField(data []byte, num protowire.Number) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		n, l, err := consumeField(data)
+		if err != nil {
+			return nil, err
+		}
+		if n == num {
+			out = data[:l]
+		}
+		data = data[l:]
+	}
+	return out, nil
+}
+
+// replaceField returns the protobuf message data with the field num replaced
+// by the encoded field.
+func replaceField(data []byte, num protowire.Number, field []byte) ([]byte, error) {
+	out := make([]byte, 0, len(data)+len(field))
+	for len(data) > 0 {
+		n, l, err := consumeField(data)
+		if err != nil {
+			return nil, err
+		}
+		if n != num {
+			out = append(out, data[:l]...)
+		}
+		data = data[l:]
+	}
+	return append(out, field...), nil
+}
+
+func consumeField(data []byte) (protowire.Number, int, error) {
+	num, typ, l := protowire.ConsumeTag(data)
+	if l < 0 {
+		return 0, 0, protowire.ParseError(l)
+	}
+	m := protowire.ConsumeFieldValue(num, typ, data[l:])
+	if m < 0 {
+		return 0, 0, protowire.ParseError(m)
+	}
+	return num, l + m, nil
+}
+
+func split(p string) []string {
+	var segs []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segs = append(segs, s)
+		}
+	}
+	return segs
+}
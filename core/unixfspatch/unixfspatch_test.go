@@ -0,0 +1,143 @@
+package unixfspatch
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	ipld "github.com/ipfs/go-ipld-format"
+	dag "github.com/ipfs/go-merkledag"
+	mdtest "github.com/ipfs/go-merkledag/test"
+	ft "github.com/ipfs/go-unixfs"
+	uio "github.com/ipfs/go-unixfs/io"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func find(t *testing.T, ds ipld.DAGService, nd ipld.Node, segs ...string) ipld.Node {
+	ctx := context.Background()
+	for _, s := range segs {
+		dir, err := uio.NewDirectoryFromNode(ds, nd)
+		require.NoError(t, err)
+		nd, err = dir.Find(ctx, s)
+		require.NoError(t, err)
+	}
+	return nd
+}
+
+func TestLinks(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	e := New(ds)
+	file := dag.NewRawNode([]byte("file"))
+	require.NoError(t, ds.Add(ctx, file))
+	root := ft.EmptyDirNode()
+	require.NoError(t, ds.Add(ctx, root))
+
+	_, err := e.AddLink(ctx, root, "a/b/file", file, false)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	out, err := e.AddLink(ctx, root, "a/b/file", file, true)
+	require.NoError(t, err)
+	require.Equal(t, file.Cid(), find(t, ds, out, "a", "b", "file").Cid())
+	// the old root is unchanged
+	require.Empty(t, root.Links())
+
+	_, err = e.AddLink(ctx, out, "a/b/file/x", file, true)
+	require.ErrorIs(t, err, ErrNotDir)
+
+	out, err = e.RmLink(ctx, out, "a/b/file")
+	require.NoError(t, err)
+	require.Empty(t, find(t, ds, out, "a", "b").Links())
+
+	_, err = e.RmLink(ctx, out, "a/b/file")
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = e.RmLink(ctx, out, "/")
+	require.ErrorIs(t, err, ErrRootPath)
+}
+
+func TestSharded(t *testing.T) {
+	defer func(size int) { uio.HAMTShardingSize = size }(uio.HAMTShardingSize)
+	uio.HAMTShardingSize = 1
+
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	e := New(ds)
+	file := dag.NewRawNode([]byte("file"))
+	require.NoError(t, ds.Add(ctx, file))
+	root := ft.EmptyDirNode()
+	require.NoError(t, ds.Add(ctx, root))
+
+	out, err := e.AddLink(ctx, root, "dir/a", file, true)
+	require.NoError(t, err)
+	out, err = e.AddLink(ctx, out, "dir/b", file, false)
+	require.NoError(t, err)
+	dir := find(t, ds, out, "dir")
+	fsn, err := ft.FSNodeFromBytes(dir.(*dag.ProtoNode).Data())
+	require.NoError(t, err)
+	require.Equal(t, ft.THAMTShard, fsn.Type())
+
+	out, err = e.RmLink(ctx, out, "dir/a")
+	require.NoError(t, err)
+	require.Equal(t, file.Cid(), find(t, ds, out, "dir", "b").Cid())
+	_, err = e.RmLink(ctx, out, "dir/a")
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	ds := mdtest.Mock()
+	e := New(ds)
+	file := dag.NewRawNode([]byte("file"))
+	require.NoError(t, ds.Add(ctx, file))
+	root, err := e.AddLink(ctx, ft.EmptyDirNode(), "file", file, false)
+	require.NoError(t, err)
+
+	out, err := e.Chmod(ctx, root, "file", 0o755)
+	require.NoError(t, err)
+	mtime := time.Unix(1000, 5)
+	out, err = e.Touch(ctx, out, "file", mtime)
+	require.NoError(t, err)
+
+	// the raw leaf is wrapped in a file node holding the metadata
+	wrapped := find(t, ds, out, "file").(*dag.ProtoNode)
+	require.Equal(t, file.Cid(), wrapped.Links()[0].Cid)
+	fsn, err := ft.FSNodeFromBytes(wrapped.Data())
+	require.NoError(t, err)
+	require.Equal(t, ft.TFile, fsn.Type())
+	require.Equal(t, uint64(len("file")), fsn.FileSize())
+
+	field, err := getField(wrapped.Data(), fieldMode)
+	require.NoError(t, err)
+	_, _, l := protowire.ConsumeTag(field)
+	mode, _ := protowire.ConsumeVarint(field[l:])
+	require.Equal(t, uint64(0o755), mode)
+
+	// the metadata is kept when the content is replaced
+	other := dag.NewRawNode([]byte("other"))
+	require.NoError(t, ds.Add(ctx, other))
+	out, err = e.SetData(ctx, out, "file", other)
+	require.NoError(t, err)
+	replaced := find(t, ds, out, "file").(*dag.ProtoNode)
+	require.Equal(t, other.Cid(), replaced.Links()[0].Cid)
+	for _, num := range []protowire.Number{fieldMode, fieldMtime} {
+		before, err := getField(wrapped.Data(), num)
+		require.NoError(t, err)
+		after, err := getField(replaced.Data(), num)
+		require.NoError(t, err)
+		require.Equal(t, before, after)
+	}
+
+	_, err = e.SetData(ctx, out, "", other)
+	require.ErrorIs(t, err, ErrNotFile)
+
+	// directories keep their metadata when edited
+	out, err = e.Chmod(ctx, out, "", 0o700)
+	require.NoError(t, err)
+	out, err = e.RmLink(ctx, out, "file")
+	require.NoError(t, err)
+	field, err = getField(out.(*dag.ProtoNode).Data(), fieldMode)
+	require.NoError(t, err)
+	require.NotNil(t, field)
+}
@@ -0,0 +1,192 @@
+// Package webhooks delivers node events to HTTP endpoints configured in
+// Webhooks.Endpoints.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log"
+	version "github.com/ipfs/kubo"
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/events"
+)
+
+var log = logging.Logger("core/webhooks")
+
+// maxBackoff caps the delay between two delivery attempts.
+const maxBackoff = time.Minute
+
+// Dispatcher subscribes to the event bus and POSTs events to webhook
+// endpoints. Events are delivered in order, one at a time per endpoint.
+type Dispatcher struct {
+	endpoints []*endpoint
+	client    *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type endpoint struct {
+	url        string
+	headers    map[string]string
+	maxRetries int
+	backoff    time.Duration
+	timeout    time.Duration
+
+	sub *events.Subscription
+}
+
+// New validates the webhook configuration and subscribes each endpoint to
+// the bus. Call Start to begin delivering events.
+func New(bus *events.Bus, cfg config.Webhooks) (*Dispatcher, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		client: &http.Client{},
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for i, ep := range cfg.Endpoints {
+		u, err := url.Parse(ep.URL)
+		if err != nil {
+			d.closeSubs()
+			return nil, fmt.Errorf("Webhooks.Endpoints[%d]: invalid URL: %w", i, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			d.closeSubs()
+			return nil, fmt.Errorf("Webhooks.Endpoints[%d]: URL scheme must be http or https, got %q", i, u.Scheme)
+		}
+		maxRetries := ep.MaxRetries.WithDefault(config.DefaultWebhookMaxRetries)
+		if maxRetries < 0 {
+			d.closeSubs()
+			return nil, fmt.Errorf("Webhooks.Endpoints[%d]: MaxRetries must not be negative", i)
+		}
+
+		d.endpoints = append(d.endpoints, &endpoint{
+			url:        ep.URL,
+			headers:    ep.Headers,
+			maxRetries: int(maxRetries),
+			backoff:    ep.RetryBackoff.WithDefault(config.DefaultWebhookRetryBackoff),
+			timeout:    ep.Timeout.WithDefault(config.DefaultWebhookTimeout),
+			sub:        bus.Subscribe(events.DefaultBufferSize, ep.Events...),
+		})
+	}
+	return d, nil
+}
+
+// Start launches one delivery worker per endpoint.
+func (d *Dispatcher) Start() {
+	for _, ep := range d.endpoints {
+		d.wg.Add(1)
+		go d.run(ep)
+	}
+}
+
+// Close stops accepting new events and waits for already queued events to
+// be delivered. Pending retries are abandoned when ctx is done.
+func (d *Dispatcher) Close(ctx context.Context) error {
+	d.closeSubs()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	d.cancel()
+	<-done
+	return nil
+}
+
+func (d *Dispatcher) closeSubs() {
+	for _, ep := range d.endpoints {
+		ep.sub.Close()
+	}
+}
+
+func (d *Dispatcher) run(ep *endpoint) {
+	defer d.wg.Done()
+	for evt := range ep.sub.Out() {
+		body, err := json.Marshal(evt)
+		if err != nil {
+			log.Errorf("webhook %s: encoding %s event: %s", ep.url, evt.Type, err)
+			continue
+		}
+		if err := d.deliver(ep, body); err != nil {
+			log.Errorf("webhook %s: dropping %s event: %s", ep.url, evt.Type, err)
+		}
+	}
+}
+
+// deliver POSTs the body, retrying with exponential backoff on network
+// errors, 429 and 5xx responses.
+func (d *Dispatcher) deliver(ep *endpoint, body []byte) error {
+	backoff := ep.backoff
+	var err error
+	for attempt := 0; attempt <= ep.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-d.ctx.Done():
+				return d.ctx.Err()
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+
+		var retry bool
+		retry, err = d.post(ep, body)
+		if err == nil {
+			return nil
+		}
+		if !retry {
+			return err
+		}
+		log.Debugf("webhook %s: attempt %d failed: %s", ep.url, attempt+1, err)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", ep.maxRetries+1, err)
+}
+
+func (d *Dispatcher) post(ep *endpoint, body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(d.ctx, ep.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.GetUserAgentVersion())
+	for k, v := range ep.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected response: %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+}
@@ -0,0 +1,102 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core/events"
+)
+
+func TestDeliveryWithRetry(t *testing.T) {
+	var calls int32
+	received := make(chan events.Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("missing Authorization header")
+		}
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var evt events.Event
+		if err := json.NewDecoder(r.Body).Decode(&evt); err != nil {
+			t.Error(err)
+		}
+		received <- evt
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus()
+	d, err := New(bus, config.Webhooks{
+		Endpoints: []config.WebhookEndpoint{{
+			URL:          srv.URL,
+			Events:       []string{"pin"},
+			Headers:      map[string]string{"Authorization": "Bearer secret"},
+			RetryBackoff: config.NewOptionalDuration(time.Millisecond),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Close(context.Background())
+
+	bus.Emit(events.GCStarted, nil)
+	bus.Emit(events.PinAdded, map[string]interface{}{"Cid": "bafy"})
+
+	select {
+	case evt := <-received:
+		if evt.Type != events.PinAdded || evt.Data["Cid"] != "bafy" {
+			t.Fatalf("unexpected event %+v", evt)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+	}
+	if n := atomic.LoadInt32(&calls); n != 3 {
+		t.Fatalf("expected 3 attempts, got %d", n)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	bus := events.NewBus()
+	d, err := New(bus, config.Webhooks{
+		Endpoints: []config.WebhookEndpoint{{
+			URL:          srv.URL,
+			RetryBackoff: config.NewOptionalDuration(time.Millisecond),
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+
+	bus.Emit(events.GCFinished, nil)
+	if err := d.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("expected 1 attempt, got %d", n)
+	}
+}
+
+func TestInvalidURL(t *testing.T) {
+	_, err := New(events.NewBus(), config.Webhooks{
+		Endpoints: []config.WebhookEndpoint{{URL: "ftp://example.com"}},
+	})
+	if err == nil {
+		t.Fatal("expected error for non-http URL")
+	}
+}
@@ -0,0 +1,33 @@
+package plugin
+
+import (
+	"github.com/ipfs/kubo/core/gatewayauth"
+	"github.com/ipfs/kubo/core/gatewaytransform"
+)
+
+// PluginGatewayTransform is an interface for plugins transforming the files
+// served by the gateway, e.g. to serve thumbnails of images or to decompress
+// archives on the fly.
+//
+// A transform is applied to a UnixFS file requested with its name in the
+// "transform" query parameter, e.g. "?transform=thumbnail&width=200", and
+// is given the other query parameters.
+type PluginGatewayTransform interface {
+	Plugin
+
+	// GatewayTransforms returns the transforms of the plugin, by name.
+	GatewayTransforms() map[string]gatewaytransform.Transform
+}
+
+// PluginGatewayAuthorizer is an interface for plugins deciding whether the
+// gateway serves a request, denies it or requires a payment, e.g. to meter
+// the requests or put content behind a paywall.
+//
+// The authorizers are only applied to the requests when their name is in
+// Gateway.Authorizers.
+type PluginGatewayAuthorizer interface {
+	Plugin
+
+	// GatewayAuthorizers returns the authorizers of the plugin, by name.
+	GatewayAuthorizers() map[string]gatewayauth.Authorizer
+}
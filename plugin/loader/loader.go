@@ -14,7 +14,11 @@ import (
 
 	"github.com/ipfs/kubo/core"
 	"github.com/ipfs/kubo/core/coreapi"
+	"github.com/ipfs/kubo/core/gatewayauth"
+	"github.com/ipfs/kubo/core/gatewaytransform"
+	"github.com/ipfs/kubo/core/node"
 	plugin "github.com/ipfs/kubo/plugin"
+	"github.com/ipfs/kubo/plugin/remote"
 	fsrepo "github.com/ipfs/kubo/repo/fsrepo"
 
 	logging "github.com/ipfs/go-log"
@@ -115,6 +119,16 @@ func NewPluginLoader(repo string) (*PluginLoader, error) {
 	if err := loader.LoadDirectory(filepath.Join(repo, "plugins")); err != nil {
 		return nil, err
 	}
+
+	for name, cfg := range loader.config.Remote {
+		pl, err := remote.New(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		if err := loader.Load(pl); err != nil {
+			return nil, err
+		}
+	}
 	return loader, nil
 }
 
@@ -268,6 +282,27 @@ func (loader *PluginLoader) Inject() error {
 				return err
 			}
 		}
+		if pl, ok := pl.(plugin.PluginGatewayTransform); ok {
+			err := injectGatewayTransformPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
+		if pl, ok := pl.(plugin.PluginGatewayAuthorizer); ok {
+			err := injectGatewayAuthorizerPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
+		if pl, ok := pl.(plugin.PluginNameResolver); ok {
+			err := injectNameResolverPlugin(pl)
+			if err != nil {
+				loader.state = loaderFailed
+				return err
+			}
+		}
 	}
 
 	return loader.transition(loaderInjecting, loaderInjected)
@@ -354,6 +389,33 @@ func injectTracerPlugin(pl plugin.PluginTracer) error {
 	return nil
 }
 
+func injectGatewayTransformPlugin(pl plugin.PluginGatewayTransform) error {
+	for name, t := range pl.GatewayTransforms() {
+		if err := gatewaytransform.Add(name, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func injectGatewayAuthorizerPlugin(pl plugin.PluginGatewayAuthorizer) error {
+	for name, a := range pl.GatewayAuthorizers() {
+		if err := gatewayauth.Add(name, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func injectNameResolverPlugin(pl plugin.PluginNameResolver) error {
+	for suffix, r := range pl.NameResolvers() {
+		if err := node.AddNameResolver(suffix, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func injectFxPlugin(pl plugin.PluginFx) error {
 	core.RegisterFXOptionFunc(pl.Options)
 	return nil
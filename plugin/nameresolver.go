@@ -0,0 +1,17 @@
+package plugin
+
+import (
+	"github.com/ipfs/kubo/core/node"
+)
+
+// PluginNameResolver is an interface for plugins resolving the names of
+// alternative naming systems, such as ENS, Handshake or a corporate naming
+// system, in /ipns paths and in the Host header of gateway requests, instead
+// of DNSLink.
+type PluginNameResolver interface {
+	Plugin
+
+	// NameResolvers returns the resolvers of the plugin, by the domain
+	// suffix of the names they resolve, e.g. "eth" for "vitalik.eth".
+	NameResolvers() map[string]node.NameResolver
+}
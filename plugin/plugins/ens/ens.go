@@ -0,0 +1,231 @@
+// Package ens resolves the .eth names of the Ethereum Name Service to the
+// content hash of their records, through an Ethereum JSON-RPC endpoint.
+package ens
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	cid "github.com/ipfs/go-cid"
+	path "github.com/ipfs/go-path"
+	"github.com/ipfs/kubo/core/node"
+	plugin "github.com/ipfs/kubo/plugin"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// registry is the address of the ENS registry on the Ethereum mainnet.
+	registry = "0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"
+
+	// multicodecs of the content hashes, EIP-1577
+	ipfsNS = 0xe3
+	ipnsNS = 0xe5
+
+	rpcTimeout = 30 * time.Second
+)
+
+// ErrNoContentHash is returned for the names without a content hash.
+var ErrNoContentHash = errors.New("no content hash for the name")
+
+// Plugins is exported list of plugins that will be loaded
+var Plugins = []plugin.Plugin{
+	&ensPlugin{},
+}
+
+// ensPlugin resolves the .eth names instead of DNSLink, when the EthRPC field
+// of its config is set to the URL of an Ethereum JSON-RPC endpoint.
+type ensPlugin struct {
+	rpc string
+}
+
+var _ plugin.PluginNameResolver = (*ensPlugin)(nil)
+
+// Name returns the plugin's name, satisfying the plugin.Plugin interface.
+func (*ensPlugin) Name() string {
+	return "ens"
+}
+
+// Version returns the plugin's version, satisfying the plugin.Plugin interface.
+func (*ensPlugin) Version() string {
+	return "0.1.0"
+}
+
+// Init reads the EthRPC field of the plugin config.
+func (p *ensPlugin) Init(env *plugin.Environment) error {
+	cfg, ok := env.Config.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	rpc, ok := cfg["EthRPC"].(string)
+	if cfg["EthRPC"] != nil && !ok {
+		return fmt.Errorf("ens plugin: EthRPC must be a URL")
+	}
+	p.rpc = rpc
+	return nil
+}
+
+// NameResolvers returns the resolver of the .eth names, if the plugin is
+// configured with an Ethereum JSON-RPC endpoint.
+func (p *ensPlugin) NameResolvers() map[string]node.NameResolver {
+	if p.rpc == "" {
+		return nil
+	}
+	return map[string]node.NameResolver{
+		"eth": &Resolver{RPC: p.rpc, Client: &http.Client{Timeout: rpcTimeout}},
+	}
+}
+
+// Resolver resolves ENS names to the content hash of their resolver.
+type Resolver struct {
+	// RPC is the URL of an Ethereum JSON-RPC endpoint.
+	RPC    string
+	Client *http.Client
+}
+
+var _ node.NameResolver = (*Resolver)(nil)
+
+// Resolve resolves the name to an /ipfs or /ipns path.
+func (r *Resolver) Resolve(ctx context.Context, name string) (path.Path, error) {
+	nh := namehash(name)
+
+	out, err := r.call(ctx, registry, "resolver(bytes32)", nh[:])
+	if err != nil {
+		return "", err
+	}
+	if len(out) != 32 || bytes.Equal(out, make([]byte, 32)) {
+		return "", ErrNoContentHash
+	}
+	resolver := "0x" + hex.EncodeToString(out[12:])
+
+	out, err = r.call(ctx, resolver, "contenthash(bytes32)", nh[:])
+	if err != nil {
+		return "", err
+	}
+	hash, err := decodeBytes(out)
+	if err != nil {
+		return "", err
+	}
+	if len(hash) == 0 {
+		return "", ErrNoContentHash
+	}
+	return contentHashPath(hash)
+}
+
+// call calls the function of the contract with the arguments, and returns its
+// output.
+func (r *Resolver) call(ctx context.Context, to, function string, args ...[]byte) ([]byte, error) {
+	data := keccak256([]byte(function))[:4]
+	for _, a := range args {
+		data = append(data, a...)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params": []interface{}{
+			map[string]string{"to": to, "data": "0x" + hex.EncodeToString(data)},
+			"latest",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.RPC, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("eth_call %s: %s", function, resp.Status)
+	}
+
+	var res struct {
+		Result string
+		Error  *struct {
+			Code    int
+			Message string
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if res.Error != nil {
+		return nil, fmt.Errorf("eth_call %s: %s (%d)", function, res.Error.Message, res.Error.Code)
+	}
+	return hex.DecodeString(strings.TrimPrefix(res.Result, "0x"))
+}
+
+// namehash returns the ENS node of the name, EIP-137.
+func namehash(name string) [32]byte {
+	var node [32]byte
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(name, ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		copy(node[:], keccak256(node[:], keccak256([]byte(labels[i]))))
+	}
+	return node
+}
+
+func keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// decodeBytes decodes the ABI encoding of a single bytes value: its offset,
+// its length, and its data padded to 32 bytes.
+func decodeBytes(out []byte) ([]byte, error) {
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if len(out) < 64 {
+		return nil, fmt.Errorf("invalid bytes of %d bytes", len(out))
+	}
+	offset := binary.BigEndian.Uint64(out[24:32])
+	if offset > uint64(len(out))-32 {
+		return nil, fmt.Errorf("invalid bytes offset %d", offset)
+	}
+	length := binary.BigEndian.Uint64(out[offset+24 : offset+32])
+	if length > uint64(len(out))-offset-32 {
+		return nil, fmt.Errorf("invalid bytes length %d", length)
+	}
+	return out[offset+32 : offset+32+length], nil
+}
+
+// contentHashPath returns the path of an EIP-1577 content hash: the varint
+// multicodec of the namespace and a CID.
+func contentHashPath(hash []byte) (path.Path, error) {
+	codec, n := binary.Uvarint(hash)
+	if n <= 0 {
+		return "", errors.New("invalid content hash")
+	}
+	c, err := cid.Cast(hash[n:])
+	if err != nil {
+		return "", fmt.Errorf("invalid content hash: %w", err)
+	}
+	switch codec {
+	case ipfsNS:
+		return path.FromCid(c), nil
+	case ipnsNS:
+		return path.FromString("/ipns/" + c.String()), nil
+	}
+	return "", fmt.Errorf("unsupported content hash namespace 0x%x", codec)
+}
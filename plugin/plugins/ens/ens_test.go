@@ -0,0 +1,55 @@
+package ens
+
+import (
+	"encoding/hex"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestNamehash(t *testing.T) {
+	for name, expected := range map[string]string{
+		"":        "0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+		"Foo.eth": "de9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	} {
+		nh := namehash(name)
+		if actual := hex.EncodeToString(nh[:]); actual != expected {
+			t.Errorf("namehash(%q) = %s, expected %s", name, actual, expected)
+		}
+	}
+}
+
+func TestContentHashPath(t *testing.T) {
+	c, err := cid.Decode("bafybeigdyrzt5sfp7udm7hu76uh7y26nf3efuylqabf3oclgtqy55fbzdi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ABI encoding of the content hash: offset, length, and padded data
+	hash := append([]byte{0xe3, 0x01}, c.Bytes()...)
+	out := make([]byte, 64+(len(hash)+31)/32*32)
+	out[31] = 32
+	out[63] = byte(len(hash))
+	copy(out[64:], hash)
+
+	decoded, err := decodeBytes(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := contentHashPath(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.String() != "/ipfs/"+c.String() {
+		t.Errorf("unexpected path %s", p)
+	}
+
+	if _, err := decodeBytes(out[:40]); err == nil {
+		t.Error("expected an error for truncated bytes")
+	}
+	if _, err := contentHashPath([]byte{0xe4, 0x01}); err == nil {
+		t.Error("expected an error for an unsupported namespace")
+	}
+}
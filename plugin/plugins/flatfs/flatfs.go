@@ -38,6 +38,7 @@ func (*flatfsPlugin) DatastoreTypeName() string {
 
 type datastoreConfig struct {
 	path      string
+	paths     []mountPoint
 	shardFun  *flatfs.ShardIdV1
 	syncField bool
 }
@@ -50,9 +51,22 @@ func (*flatfsPlugin) DatastoreConfigParser() fsrepo.ConfigFromMap {
 		var ok bool
 		var err error
 
-		c.path, ok = params["path"].(string)
-		if !ok {
-			return nil, fmt.Errorf("'path' field is missing or not boolean")
+		if rawPaths, ok := params["paths"]; ok {
+			if _, ok := params["path"]; ok {
+				return nil, fmt.Errorf("'path' and 'paths' fields can not be used together")
+			}
+			c.paths, err = parsePaths(rawPaths)
+			if err != nil {
+				return nil, err
+			}
+			// the first path is the one recorded in the datastore spec, so
+			// that paths can be added and reweighted without changing it
+			c.path = c.paths[0].Path
+		} else {
+			c.path, ok = params["path"].(string)
+			if !ok {
+				return nil, fmt.Errorf("'path' field is missing or not boolean")
+			}
 		}
 
 		sshardFun, ok := params["shardFunc"].(string)
@@ -72,6 +86,44 @@ func (*flatfsPlugin) DatastoreConfigParser() fsrepo.ConfigFromMap {
 	}
 }
 
+func parsePaths(raw interface{}) ([]mountPoint, error) {
+	list, ok := raw.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("'paths' field is not a non-empty list")
+	}
+
+	var positive bool
+	paths := make([]mountPoint, 0, len(list))
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("'paths[%d]' is not an object", i)
+		}
+		mp := mountPoint{Weight: 1}
+		mp.Path, ok = m["path"].(string)
+		if !ok || mp.Path == "" {
+			return nil, fmt.Errorf("'paths[%d].path' field is missing or not a string", i)
+		}
+		if w, ok := m["weight"]; ok {
+			mp.Weight, ok = w.(float64)
+			if !ok || mp.Weight < 0 {
+				return nil, fmt.Errorf("'paths[%d].weight' field is not a number or is negative", i)
+			}
+		}
+		for _, other := range paths {
+			if filepath.Clean(other.Path) == filepath.Clean(mp.Path) {
+				return nil, fmt.Errorf("'paths[%d].path' is listed twice", i)
+			}
+		}
+		positive = positive || mp.Weight > 0
+		paths = append(paths, mp)
+	}
+	if !positive {
+		return nil, fmt.Errorf("at least one of 'paths' must have a positive weight")
+	}
+	return paths, nil
+}
+
 func (c *datastoreConfig) DiskSpec() fsrepo.DiskSpec {
 	return map[string]interface{}{
 		"type":      "flatfs",
@@ -81,10 +133,16 @@ func (c *datastoreConfig) DiskSpec() fsrepo.DiskSpec {
 }
 
 func (c *datastoreConfig) Create(path string) (repo.Datastore, error) {
-	p := c.path
-	if !filepath.IsAbs(p) {
-		p = filepath.Join(path, p)
+	if len(c.paths) > 1 {
+		return openMulti(path, c.paths, c.shardFun, c.syncField)
 	}
 
-	return flatfs.CreateOrOpen(p, c.shardFun, c.syncField)
+	return flatfs.CreateOrOpen(absPath(path, c.path), c.shardFun, c.syncField)
+}
+
+func absPath(repoPath, p string) string {
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(repoPath, p)
+	}
+	return p
 }
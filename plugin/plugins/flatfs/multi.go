@@ -0,0 +1,395 @@
+package flatfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	logging "github.com/ipfs/go-log"
+	"github.com/jbenet/goprocess"
+)
+
+var log = logging.Logger("plugin/flatfs")
+
+// layoutFile records, in the first path of a multi-path flatfs, the paths and
+// weights the blocks were last balanced for.
+const layoutFile = "LAYOUT"
+
+type mountPoint struct {
+	Path   string  `json:"path"`
+	Weight float64 `json:"weight"`
+}
+
+type flatfsMount struct {
+	mountPoint
+	dir string
+	ds  *flatfs.Datastore
+}
+
+// multiDatastore spreads the shards of a flatfs across several directories,
+// typically on different disks. Each directory is a regular flatfs using the
+// same shard function, and whole shards are placed on a directory using
+// weighted rendezvous hashing, so that changing the weight of a directory or
+// adding one only moves the shards that need to.
+//
+// Blocks are always written to the directory of their shard but are looked up
+// in all directories, so that the datastore keeps working while blocks are
+// being moved around by the background rebalancing.
+type multiDatastore struct {
+	mounts   []flatfsMount
+	shardFun flatfs.ShardFunc
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var (
+	_ ds.Batching            = (*multiDatastore)(nil)
+	_ ds.PersistentDatastore = (*multiDatastore)(nil)
+)
+
+// openMulti opens a flatfs in each of the given paths, relative to repoPath
+// unless absolute. Shards are placed according to the paths as configured, so
+// that moving the repo does not move the blocks around.
+func openMulti(repoPath string, mounts []mountPoint, shardFun *flatfs.ShardIdV1, sync bool) (*multiDatastore, error) {
+	m := &multiDatastore{shardFun: shardFun.Func()}
+	for _, mp := range mounts {
+		dir := absPath(repoPath, mp.Path)
+		d, err := flatfs.CreateOrOpen(dir, shardFun, sync)
+		if err != nil {
+			m.closeMounts()
+			return nil, err
+		}
+		m.mounts = append(m.mounts, flatfsMount{mountPoint: mp, dir: dir, ds: d})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	if !m.balanced() {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.rebalance(ctx)
+		}()
+	}
+	return m, nil
+}
+
+// mountFor returns the index of the directory holding the shard of key.
+func (m *multiDatastore) mountFor(key ds.Key) int {
+	shard := m.shardFun(key.String()[1:])
+
+	best, bestScore := 0, -1.0
+	for i, mnt := range m.mounts {
+		if mnt.Weight <= 0 {
+			continue
+		}
+		h := fnv.New64a()
+		h.Write([]byte(mnt.Path))
+		h.Write([]byte{0})
+		h.Write([]byte(shard))
+		// map the hash to a uniform value in (0, 1)
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		if score := mnt.Weight / -math.Log(u); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+func (m *multiDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return m.mounts[m.mountFor(key)].ds.Put(ctx, key, value)
+}
+
+// Delete removes key from all directories, as it may not have been moved to
+// the directory of its shard yet.
+func (m *multiDatastore) Delete(ctx context.Context, key ds.Key) error {
+	for _, mnt := range m.mounts {
+		if err := mnt.ds.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup calls fn on the directory of the shard of key first, then on the
+// other ones, until it does not return ds.ErrNotFound.
+func (m *multiDatastore) lookup(key ds.Key, fn func(d *flatfs.Datastore) error) error {
+	target := m.mountFor(key)
+	err := fn(m.mounts[target].ds)
+	for i := 0; err == ds.ErrNotFound && i < len(m.mounts); i++ {
+		if i != target {
+			err = fn(m.mounts[i].ds)
+		}
+	}
+	return err
+}
+
+func (m *multiDatastore) Get(ctx context.Context, key ds.Key) (value []byte, err error) {
+	err = m.lookup(key, func(d *flatfs.Datastore) (err error) {
+		value, err = d.Get(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+func (m *multiDatastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	err := m.lookup(key, func(d *flatfs.Datastore) error {
+		has, err := d.Has(ctx, key)
+		if err == nil && !has {
+			return ds.ErrNotFound
+		}
+		return err
+	})
+	if err == ds.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *multiDatastore) GetSize(ctx context.Context, key ds.Key) (size int, err error) {
+	err = m.lookup(key, func(d *flatfs.Datastore) (err error) {
+		size, err = d.GetSize(ctx, key)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return size, nil
+}
+
+// Query merges the results of all directories. Keys found outside of the
+// directory of their shard are skipped if they were also found there.
+func (m *multiDatastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	base := query.Query{Prefix: q.Prefix, KeysOnly: q.KeysOnly, ReturnsSizes: q.ReturnsSizes}
+	results := query.ResultsWithProcess(base, func(p goprocess.Process, out chan<- query.Result) {
+		for i, mnt := range m.mounts {
+			res, err := mnt.ds.Query(ctx, base)
+			if err != nil {
+				select {
+				case out <- query.Result{Error: err}:
+				case <-p.Closing():
+				}
+				return
+			}
+			for r := range res.Next() {
+				if r.Error == nil {
+					key := ds.RawKey(r.Key)
+					if target := m.mountFor(key); target != i {
+						if has, _ := m.mounts[target].ds.Has(ctx, key); has {
+							continue
+						}
+					}
+				}
+				select {
+				case out <- r:
+				case <-p.Closing():
+					res.Close()
+					return
+				}
+			}
+			res.Close()
+		}
+	})
+	return query.NaiveQueryApply(q, results), nil
+}
+
+func (m *multiDatastore) Sync(ctx context.Context, prefix ds.Key) error {
+	for _, mnt := range m.mounts {
+		if err := mnt.ds.Sync(ctx, prefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *multiDatastore) DiskUsage(ctx context.Context) (uint64, error) {
+	var total uint64
+	for _, mnt := range m.mounts {
+		du, err := mnt.ds.DiskUsage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		total += du
+	}
+	return total, nil
+}
+
+func (m *multiDatastore) Batch(ctx context.Context) (ds.Batch, error) {
+	return &multiBatch{m: m, batches: make(map[int]ds.Batch)}, nil
+}
+
+func (m *multiDatastore) Close() error {
+	m.cancel()
+	m.wg.Wait()
+	return m.closeMounts()
+}
+
+func (m *multiDatastore) closeMounts() error {
+	var firstErr error
+	for _, mnt := range m.mounts {
+		if err := mnt.ds.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// balanced reports whether the blocks were balanced for the current paths and
+// weights.
+func (m *multiDatastore) balanced() bool {
+	data, err := os.ReadFile(filepath.Join(m.mounts[0].dir, layoutFile))
+	if err != nil {
+		return false
+	}
+	var layout []mountPoint
+	if err := json.Unmarshal(data, &layout); err != nil || len(layout) != len(m.mounts) {
+		return false
+	}
+	for i, mp := range layout {
+		if mp != m.mounts[i].mountPoint {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *multiDatastore) writeLayout() error {
+	layout := make([]mountPoint, len(m.mounts))
+	for i, mnt := range m.mounts {
+		layout[i] = mnt.mountPoint
+	}
+	data, err := json.MarshalIndent(layout, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.mounts[0].dir, layoutFile), data, 0o600)
+}
+
+// rebalance moves the blocks stored outside of the directory of their shard
+// while the datastore is in use. The layout is only recorded once all blocks
+// were moved, so that an interrupted rebalancing resumes on the next start.
+func (m *multiDatastore) rebalance(ctx context.Context) {
+	log.Infow("rebalancing flatfs shards", "paths", len(m.mounts))
+
+	var moved int
+	for i := range m.mounts {
+		n, err := m.rebalanceMount(ctx, i)
+		moved += n
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				log.Errorw("flatfs rebalancing failed", "path", m.mounts[i].dir, "error", err)
+			}
+			return
+		}
+	}
+	if err := m.writeLayout(); err != nil {
+		log.Errorw("failed to record flatfs layout", "error", err)
+		return
+	}
+	log.Infow("flatfs rebalancing done", "moved", moved)
+}
+
+func (m *multiDatastore) rebalanceMount(ctx context.Context, i int) (int, error) {
+	src := m.mounts[i].ds
+	res, err := src.Query(ctx, query.Query{KeysOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	defer res.Close()
+
+	var moved int
+	for r := range res.Next() {
+		if r.Error != nil {
+			return moved, r.Error
+		}
+		if err := ctx.Err(); err != nil {
+			return moved, err
+		}
+		key := ds.RawKey(r.Key)
+		target := m.mountFor(key)
+		if target == i {
+			continue
+		}
+		dst := m.mounts[target].ds
+
+		// A concurrent delete between the copy and the removal may leave
+		// the block behind in the target directory, to be collected by the
+		// next GC.
+		has, err := dst.Has(ctx, key)
+		if err != nil {
+			return moved, err
+		}
+		if !has {
+			value, err := src.Get(ctx, key)
+			if err == ds.ErrNotFound {
+				continue
+			} else if err != nil {
+				return moved, err
+			}
+			if err := dst.Put(ctx, key, value); err != nil {
+				return moved, err
+			}
+		}
+		if err := src.Delete(ctx, key); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+type multiBatch struct {
+	m       *multiDatastore
+	batches map[int]ds.Batch
+}
+
+func (b *multiBatch) batch(ctx context.Context, i int) (ds.Batch, error) {
+	if bt, ok := b.batches[i]; ok {
+		return bt, nil
+	}
+	bt, err := b.m.mounts[i].ds.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b.batches[i] = bt
+	return bt, nil
+}
+
+func (b *multiBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	bt, err := b.batch(ctx, b.m.mountFor(key))
+	if err != nil {
+		return err
+	}
+	return bt.Put(ctx, key, value)
+}
+
+func (b *multiBatch) Delete(ctx context.Context, key ds.Key) error {
+	for i := range b.m.mounts {
+		bt, err := b.batch(ctx, i)
+		if err != nil {
+			return err
+		}
+		if err := bt.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *multiBatch) Commit(ctx context.Context) error {
+	for _, bt := range b.batches {
+		if err := bt.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package flatfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+)
+
+func countKeys(t *testing.T, d ds.Datastore) int {
+	t.Helper()
+	res, err := d.Query(context.Background(), query.Query{KeysOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entries)
+}
+
+func TestMultiDatastore(t *testing.T) {
+	ctx := context.Background()
+	repo := t.TempDir()
+	disk2 := filepath.Join(t.TempDir(), "blocks")
+
+	const n = 300
+	keys := make([]ds.Key, n)
+	for i := range keys {
+		keys[i] = ds.NewKey(fmt.Sprintf("BLOCK%03d", i))
+	}
+
+	m, err := openMulti(repo, []mountPoint{
+		{Path: "blocks", Weight: 1},
+		{Path: disk2, Weight: 1},
+	}, flatfs.IPFS_DEF_SHARD, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.wg.Wait()
+	for _, k := range keys {
+		if err := m.Put(ctx, k, []byte(k.String())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, second := countKeys(t, m.mounts[0].ds), countKeys(t, m.mounts[1].ds)
+	if first == 0 || second == 0 || first+second != n {
+		t.Fatalf("expected keys to be spread across both paths, got %d and %d", first, second)
+	}
+	if c := countKeys(t, m); c != n {
+		t.Fatalf("expected %d keys, got %d", n, c)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// drain the second path
+	m, err = openMulti(repo, []mountPoint{
+		{Path: "blocks", Weight: 1},
+		{Path: disk2, Weight: 0},
+	}, flatfs.IPFS_DEF_SHARD, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	m.wg.Wait()
+
+	if c := countKeys(t, m.mounts[1].ds); c != 0 {
+		t.Fatalf("expected the drained path to be empty, got %d keys", c)
+	}
+	for _, k := range keys {
+		v, err := m.Get(ctx, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != k.String() {
+			t.Fatalf("unexpected value for %s", k)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(repo, "blocks", layoutFile)); err != nil {
+		t.Fatalf("expected the layout to be recorded: %s", err)
+	}
+	if !m.balanced() {
+		t.Fatal("expected the datastore to be balanced")
+	}
+}
+
+func TestParsePaths(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		raw  interface{}
+		ok   bool
+	}{
+		{"valid", []interface{}{
+			map[string]interface{}{"path": "blocks"},
+			map[string]interface{}{"path": "/mnt/disk2/blocks", "weight": 2.0},
+		}, true},
+		{"empty", []interface{}{}, false},
+		{"not a list", "blocks", false},
+		{"missing path", []interface{}{map[string]interface{}{"weight": 1.0}}, false},
+		{"negative weight", []interface{}{map[string]interface{}{"path": "blocks", "weight": -1.0}}, false},
+		{"no positive weight", []interface{}{map[string]interface{}{"path": "blocks", "weight": 0.0}}, false},
+		{"duplicate", []interface{}{
+			map[string]interface{}{"path": "blocks"},
+			map[string]interface{}{"path": "blocks/"},
+		}, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := parsePaths(c.raw)
+			if (err == nil) != c.ok {
+				t.Fatalf("unexpected result: %v", err)
+			}
+		})
+	}
+}
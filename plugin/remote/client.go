@@ -0,0 +1,180 @@
+package remote
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// handshakeTimeout is how long Kubo waits for a plugin process to announce
+// its address.
+const handshakeTimeout = 10 * time.Second
+
+// client is a running remote plugin process and the gRPC connection to it.
+type client struct {
+	name  string
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	conn  *grpc.ClientConn
+}
+
+// launch starts the plugin executable and connects to it.
+func launch(name, path string, args []string) (*client, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Env = append(os.Environ(), MagicCookieKey+"="+MagicCookieValue)
+	cmd.Stderr = os.Stderr
+
+	// Plugins exit when their stdin is closed, which makes sure they do not
+	// outlive Kubo.
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting remote plugin %s: %w", name, err)
+	}
+
+	c := &client{name: name, cmd: cmd, stdin: stdin}
+
+	network, addr, err := readHandshake(stdout)
+	if err != nil {
+		c.abort()
+		return nil, fmt.Errorf("remote plugin %s: %w", name, err)
+	}
+	// Keep draining stdout so the plugin never blocks on writes.
+	go io.Copy(io.Discard, stdout) //nolint:errcheck
+
+	target := addr
+	if network == "unix" {
+		target = "unix://" + addr
+	}
+	conn, err := grpc.Dial(target,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	)
+	if err != nil {
+		c.abort()
+		return nil, fmt.Errorf("connecting to remote plugin %s: %w", name, err)
+	}
+	c.conn = conn
+	return c, nil
+}
+
+func readHandshake(stdout io.Reader) (network, addr string, err error) {
+	type result struct {
+		line string
+		err  error
+	}
+	lines := make(chan result, 1)
+	go func() {
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		lines <- result{line, err}
+	}()
+
+	var res result
+	select {
+	case res = <-lines:
+	case <-time.After(handshakeTimeout):
+		return "", "", fmt.Errorf("no handshake after %s", handshakeTimeout)
+	}
+	if res.err != nil {
+		return "", "", fmt.Errorf("reading handshake: %w", res.err)
+	}
+
+	parts := strings.Split(strings.TrimSpace(res.line), "|")
+	if len(parts) != 4 || parts[0] != handshakePrefix {
+		return "", "", fmt.Errorf("invalid handshake %q", res.line)
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil || version != ProtocolVersion {
+		return "", "", fmt.Errorf("unsupported protocol version %q, expected %d", parts[1], ProtocolVersion)
+	}
+	switch parts[2] {
+	case "unix", "tcp":
+	default:
+		return "", "", fmt.Errorf("unsupported network %q", parts[2])
+	}
+	return parts[2], parts[3], nil
+}
+
+func (c *client) invoke(ctx context.Context, service, method string, req, resp interface{}) error {
+	return c.conn.Invoke(ctx, "/"+service+"/"+method, req, resp)
+}
+
+// stream calls a server-streaming method. Responses are read with RecvMsg
+// until it returns io.EOF.
+func (c *client) stream(ctx context.Context, service, method string, req interface{}) (grpc.ClientStream, error) {
+	s, err := c.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/"+service+"/"+method)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := s.CloseSend(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *client) init(ctx context.Context, repo string, cfg interface{}) error {
+	return c.invoke(ctx, pluginService, "Init", &initRequest{
+		Name:   c.name,
+		Repo:   repo,
+		Config: cfg,
+	}, &empty{})
+}
+
+func (c *client) info(ctx context.Context) (*infoResponse, error) {
+	var resp infoResponse
+	if err := c.invoke(ctx, pluginService, "Info", &empty{}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Close closes the connection and stops the plugin process.
+func (c *client) Close() error {
+	var err error
+	if c.conn != nil {
+		err = c.conn.Close()
+	}
+	// closing stdin asks the plugin to exit
+	c.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		_ = c.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		c.kill()
+	}
+	return err
+}
+
+func (c *client) kill() {
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+}
+
+// abort kills a plugin process that failed to start properly.
+func (c *client) abort() {
+	c.kill()
+	_ = c.cmd.Wait()
+}
@@ -0,0 +1,161 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/repo"
+	"github.com/ipfs/kubo/repo/fsrepo"
+)
+
+// datastoreConfig implements fsrepo.DatastoreConfig for a datastore provided
+// by a remote plugin.
+type datastoreConfig struct {
+	client   *client
+	params   map[string]interface{}
+	diskSpec fsrepo.DiskSpec
+}
+
+func datastoreConfigParser(c *client) fsrepo.ConfigFromMap {
+	return func(params map[string]interface{}) (fsrepo.DatastoreConfig, error) {
+		var resp diskSpecResponse
+		err := c.invoke(context.Background(), datastoreService, "DiskSpec", &diskSpecRequest{Params: params}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		return &datastoreConfig{client: c, params: params, diskSpec: resp.DiskSpec}, nil
+	}
+}
+
+func (c *datastoreConfig) DiskSpec() fsrepo.DiskSpec {
+	return c.diskSpec
+}
+
+func (c *datastoreConfig) Create(path string) (repo.Datastore, error) {
+	var resp handleRequest
+	err := c.client.invoke(context.Background(), datastoreService, "Open", &openRequest{Path: path, Params: c.params}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &datastore{client: c.client, handle: resp.Handle}, nil
+}
+
+// datastore is a ds.Batching backed by a remote plugin.
+type datastore struct {
+	client *client
+	handle uint64
+}
+
+var _ ds.Batching = (*datastore)(nil)
+
+func (d *datastore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	var resp valueResponse
+	err := d.client.invoke(ctx, datastoreService, "Get", &keyRequest{Handle: d.handle, Key: key.String()}, &resp)
+	if isNotFound(err) {
+		return nil, ds.ErrNotFound
+	}
+	return resp.Value, err
+}
+
+func (d *datastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	var resp hasResponse
+	err := d.client.invoke(ctx, datastoreService, "Has", &keyRequest{Handle: d.handle, Key: key.String()}, &resp)
+	return resp.Has, err
+}
+
+func (d *datastore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	var resp sizeResponse
+	err := d.client.invoke(ctx, datastoreService, "GetSize", &keyRequest{Handle: d.handle, Key: key.String()}, &resp)
+	if isNotFound(err) {
+		return -1, ds.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+	return resp.Size, nil
+}
+
+func (d *datastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return d.client.invoke(ctx, datastoreService, "Put", &putRequest{Handle: d.handle, Key: key.String(), Value: value}, &empty{})
+}
+
+func (d *datastore) Delete(ctx context.Context, key ds.Key) error {
+	return d.client.invoke(ctx, datastoreService, "Delete", &keyRequest{Handle: d.handle, Key: key.String()}, &empty{})
+}
+
+func (d *datastore) Sync(ctx context.Context, prefix ds.Key) error {
+	return d.client.invoke(ctx, datastoreService, "Sync", &keyRequest{Handle: d.handle, Key: prefix.String()}, &empty{})
+}
+
+// Query only sends the prefix to the plugin. Filters, orders, limit and
+// offset are applied locally.
+func (d *datastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	s, err := d.client.stream(ctx, datastoreService, "Query", &queryRequest{
+		Handle:       d.handle,
+		Prefix:       q.Prefix,
+		KeysOnly:     q.KeysOnly,
+		ReturnsSizes: q.ReturnsSizes,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res := query.ResultsFromIterator(query.Query{Prefix: q.Prefix, KeysOnly: q.KeysOnly, ReturnsSizes: q.ReturnsSizes}, query.Iterator{
+		Next: func() (query.Result, bool) {
+			var r queryResult
+			err := s.RecvMsg(&r)
+			if errors.Is(err, io.EOF) {
+				return query.Result{}, false
+			}
+			if err != nil {
+				return query.Result{Error: err}, true
+			}
+			return query.Result{Entry: query.Entry{Key: r.Key, Value: r.Value, Size: r.Size}}, true
+		},
+		Close: func() error {
+			cancel()
+			return nil
+		},
+	})
+	return query.NaiveQueryApply(query.Query{
+		Filters: q.Filters,
+		Orders:  q.Orders,
+		Limit:   q.Limit,
+		Offset:  q.Offset,
+	}, res), nil
+}
+
+func (d *datastore) Batch(ctx context.Context) (ds.Batch, error) {
+	return &batch{d: d}, nil
+}
+
+func (d *datastore) Close() error {
+	return d.client.invoke(context.Background(), datastoreService, "Close", &handleRequest{Handle: d.handle}, &empty{})
+}
+
+// batch buffers operations locally and sends them in a single call on Commit.
+type batch struct {
+	d   *datastore
+	ops []batchOp
+}
+
+func (b *batch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	b.ops = append(b.ops, batchOp{Key: key.String(), Value: value})
+	return nil
+}
+
+func (b *batch) Delete(ctx context.Context, key ds.Key) error {
+	b.ops = append(b.ops, batchOp{Key: key.String(), Delete: true})
+	return nil
+}
+
+func (b *batch) Commit(ctx context.Context) error {
+	ops := b.ops
+	b.ops = nil
+	return b.d.client.invoke(ctx, datastoreService, "Batch", &batchRequest{Handle: b.d.handle, Ops: ops}, &empty{})
+}
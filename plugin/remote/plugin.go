@@ -0,0 +1,162 @@
+// Package remote implements plugins running in their own process, outside of
+// Kubo.
+//
+// Remote plugins are executables listed in Plugins.Remote. Kubo starts them
+// on first use and talks to them over gRPC, so they do not have to be
+// compiled against the exact version of Kubo that loads them, unlike Go
+// plugins. Plugin executables are implemented with Serve.
+//
+// Datastore and routing plugins are supported.
+package remote
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	logging "github.com/ipfs/go-log"
+	config "github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/core"
+	"github.com/ipfs/kubo/core/node/libp2p"
+	"github.com/ipfs/kubo/plugin"
+	"github.com/ipfs/kubo/repo/fsrepo"
+	"go.uber.org/fx"
+)
+
+var log = logging.Logger("plugin/remote")
+
+// routerPriority is the priority of routers provided by remote plugins, see
+// libp2p.Router.
+const routerPriority = 2000
+
+// New returns a plugin running the executable described by cfg.
+func New(name string, cfg config.RemotePlugin) (plugin.Plugin, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("remote plugin %s: missing Path", name)
+	}
+	base := &remotePlugin{name: name, cfg: cfg}
+	switch cfg.Type {
+	case config.RemotePluginDatastore:
+		return &datastorePlugin{base}, nil
+	case config.RemotePluginRouting:
+		return &routingPlugin{base}, nil
+	default:
+		return nil, fmt.Errorf("remote plugin %s: unsupported type %q", name, cfg.Type)
+	}
+}
+
+type remotePlugin struct {
+	name string
+	cfg  config.RemotePlugin
+	env  *plugin.Environment
+
+	once    sync.Once
+	client  *client
+	version string
+	err     error
+}
+
+func (p *remotePlugin) Name() string {
+	return p.name
+}
+
+func (p *remotePlugin) Version() string {
+	if p.version == "" {
+		return "remote"
+	}
+	return p.version
+}
+
+// Init only records the environment: the plugin process is started the first
+// time it is needed, so commands that do not use it do not pay for it.
+func (p *remotePlugin) Init(env *plugin.Environment) error {
+	p.env = env
+	return nil
+}
+
+func (p *remotePlugin) connect() (*client, error) {
+	p.once.Do(func() {
+		c, err := launch(p.name, p.cfg.Path, p.cfg.Args)
+		if err != nil {
+			p.err = err
+			return
+		}
+		ctx := context.Background()
+		info, err := c.info(ctx)
+		if err != nil {
+			c.Close()
+			p.err = fmt.Errorf("remote plugin %s: %w", p.name, err)
+			return
+		}
+		var repo string
+		var pcfg interface{}
+		if p.env != nil {
+			repo, pcfg = p.env.Repo, p.env.Config
+		}
+		if err := c.init(ctx, repo, pcfg); err != nil {
+			c.Close()
+			p.err = fmt.Errorf("remote plugin %s: init: %w", p.name, err)
+			return
+		}
+		p.version = info.Version
+		p.client = c
+		log.Infof("started remote plugin %s (%s %s)", p.name, info.Name, info.Version)
+	})
+	return p.client, p.err
+}
+
+// Close stops the plugin process, if it was started.
+func (p *remotePlugin) Close() error {
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
+}
+
+type datastorePlugin struct {
+	*remotePlugin
+}
+
+var _ plugin.PluginDatastore = (*datastorePlugin)(nil)
+
+// DatastoreTypeName returns the name of the plugin, which is used as the
+// "type" in Datastore.Spec.
+func (p *datastorePlugin) DatastoreTypeName() string {
+	return p.name
+}
+
+func (p *datastorePlugin) DatastoreConfigParser() fsrepo.ConfigFromMap {
+	return func(params map[string]interface{}) (fsrepo.DatastoreConfig, error) {
+		c, err := p.connect()
+		if err != nil {
+			return nil, err
+		}
+		return datastoreConfigParser(c)(params)
+	}
+}
+
+type routingPlugin struct {
+	*remotePlugin
+}
+
+var _ plugin.PluginFx = (*routingPlugin)(nil)
+
+// Options adds the remote router to the routers used by the node.
+func (p *routingPlugin) Options(info core.FXNodeInfo) ([]fx.Option, error) {
+	provide := fx.Provide(fx.Annotate(
+		func(lc fx.Lifecycle) (libp2p.Router, error) {
+			c, err := p.connect()
+			if err != nil {
+				return libp2p.Router{}, err
+			}
+			lc.Append(fx.Hook{
+				OnStop: func(context.Context) error {
+					return p.Close()
+				},
+			})
+			return libp2p.Router{Routing: &router{client: c}, Priority: routerPriority}, nil
+		},
+		fx.ResultTags(`group:"routers"`),
+	))
+	return append(info.FXOptions, provide), nil
+}
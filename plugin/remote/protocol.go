@@ -0,0 +1,166 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProtocolVersion is the version of the protocol spoken between Kubo and
+// remote plugins. It is part of the handshake and must match on both sides.
+const ProtocolVersion = 1
+
+// MagicCookieKey and MagicCookieValue are set in the environment of remote
+// plugin processes. They are not a security measure, they only let plugin
+// executables detect that they were not started by Kubo.
+const (
+	MagicCookieKey   = "KUBO_PLUGIN_MAGIC_COOKIE"
+	MagicCookieValue = "d9b1f0e5a2c14c6e9f3b7a8e4c2d1f06"
+)
+
+// handshakePrefix starts the line remote plugins print on stdout once they
+// are ready to accept connections:
+//
+//	kubo-plugin|{protocol version}|{network}|{address}
+const handshakePrefix = "kubo-plugin"
+
+const (
+	pluginService    = "kubo.plugin.v1.Plugin"
+	datastoreService = "kubo.plugin.v1.Datastore"
+	routingService   = "kubo.plugin.v1.Routing"
+)
+
+// codecName is the name of the gRPC codec used by remote plugins. Messages
+// are encoded as JSON, which keeps plugins easy to write in any language.
+const codecName = "kubo-plugin-json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return codecName }
+
+type empty struct{}
+
+type initRequest struct {
+	Name   string
+	Repo   string
+	Config interface{}
+}
+
+type infoResponse struct {
+	Name    string
+	Version string
+}
+
+type diskSpecRequest struct {
+	Params map[string]interface{}
+}
+
+type diskSpecResponse struct {
+	DiskSpec map[string]interface{}
+}
+
+type openRequest struct {
+	Path   string
+	Params map[string]interface{}
+}
+
+type handleRequest struct {
+	Handle uint64
+}
+
+type keyRequest struct {
+	Handle uint64
+	Key    string
+}
+
+type putRequest struct {
+	Handle uint64
+	Key    string
+	Value  []byte
+}
+
+type valueResponse struct {
+	Value []byte
+}
+
+type hasResponse struct {
+	Has bool
+}
+
+type sizeResponse struct {
+	Size int
+}
+
+type queryRequest struct {
+	Handle       uint64
+	Prefix       string
+	KeysOnly     bool
+	ReturnsSizes bool
+}
+
+type queryResult struct {
+	Key   string
+	Value []byte `json:",omitempty"`
+	Size  int
+}
+
+type batchOp struct {
+	Key    string
+	Value  []byte `json:",omitempty"`
+	Delete bool   `json:",omitempty"`
+}
+
+type batchRequest struct {
+	Handle uint64
+	Ops    []batchOp
+}
+
+type provideRequest struct {
+	Cid      string
+	Announce bool
+}
+
+type findProvidersRequest struct {
+	Cid   string
+	Count int
+}
+
+type findPeerRequest struct {
+	ID string
+}
+
+type addrInfo struct {
+	ID    string
+	Addrs []string
+}
+
+type getValueRequest struct {
+	Key string
+}
+
+type putValueRequest struct {
+	Key   string
+	Value []byte
+}
+
+// toStatus converts well-known "not found" errors to a gRPC status so they
+// can be recognized by the other side.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ds.ErrNotFound) || errors.Is(err, routing.ErrNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return err
+}
+
+func isNotFound(err error) bool {
+	return status.Code(err) == codes.NotFound
+}
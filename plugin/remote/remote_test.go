@@ -0,0 +1,139 @@
+package remote
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type memProvider struct{}
+
+func (memProvider) DiskSpec(params map[string]interface{}) (map[string]interface{}, error) {
+	return map[string]interface{}{"type": "mem"}, nil
+}
+
+func (memProvider) Open(path string, params map[string]interface{}) (ds.Batching, error) {
+	return dssync.MutexWrap(ds.NewMapDatastore()), nil
+}
+
+func TestReadHandshake(t *testing.T) {
+	for _, test := range []struct {
+		line    string
+		network string
+		addr    string
+		ok      bool
+	}{
+		{"kubo-plugin|1|unix|/tmp/plugin.sock\n", "unix", "/tmp/plugin.sock", true},
+		{"kubo-plugin|1|tcp|127.0.0.1:1234\n", "tcp", "127.0.0.1:1234", true},
+		{"kubo-plugin|2|unix|/tmp/plugin.sock\n", "", "", false},
+		{"kubo-plugin|1|udp|127.0.0.1:1234\n", "", "", false},
+		{"hello\n", "", "", false},
+	} {
+		network, addr, err := readHandshake(strings.NewReader(test.line))
+		if (err == nil) != test.ok {
+			t.Errorf("readHandshake(%q): unexpected error %v", test.line, err)
+			continue
+		}
+		if network != test.network || addr != test.addr {
+			t.Errorf("readHandshake(%q) = (%s, %s), expected (%s, %s)", test.line, network, addr, test.network, test.addr)
+		}
+	}
+}
+
+func TestRemoteDatastore(t *testing.T) {
+	ctx := context.Background()
+
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "plugin.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{opts: ServeOptions{Name: "mem", Datastore: memProvider{}}, stores: make(map[uint64]ds.Batching)}
+	srv := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	srv.RegisterService(&pluginServiceDesc, s)
+	srv.RegisterService(&datastoreServiceDesc, s)
+	go srv.Serve(l) //nolint:errcheck
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("unix://"+l.Addr().String(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(jsonCodec{})),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := &client{name: "mem", conn: conn}
+
+	info, err := c.info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "mem" {
+		t.Fatalf("unexpected plugin name %q", info.Name)
+	}
+
+	cfg, err := datastoreConfigParser(c)(map[string]interface{}{"type": "mem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DiskSpec()["type"] != "mem" {
+		t.Fatalf("unexpected disk spec %v", cfg.DiskSpec())
+	}
+	d, err := cfg.Create("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Get(ctx, ds.NewKey("/missing")); err != ds.ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := d.Put(ctx, ds.NewKey("/a/1"), []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := d.Batch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(ctx, ds.NewKey("/a/2"), []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Put(ctx, ds.NewKey("/b/1"), []byte("three")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := d.Get(ctx, ds.NewKey("/a/2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(value) != "two" {
+		t.Fatalf("unexpected value %q", value)
+	}
+
+	res, err := d.Query(ctx, query.Query{Prefix: "/a", Orders: []query.Order{query.OrderByKey{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "/a/1" || entries[1].Key != "/a/2" {
+		t.Fatalf("unexpected query results %v", entries)
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// router is a routing.Routing backed by a remote plugin.
+type router struct {
+	client *client
+}
+
+var _ routing.Routing = (*router)(nil)
+
+func (r *router) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	return r.client.invoke(ctx, routingService, "Provide", &provideRequest{Cid: c.String(), Announce: announce}, &empty{})
+}
+
+func (r *router) FindProvidersAsync(ctx context.Context, c cid.Cid, count int) <-chan peer.AddrInfo {
+	out := make(chan peer.AddrInfo)
+	go func() {
+		defer close(out)
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		s, err := r.client.stream(ctx, routingService, "FindProviders", &findProvidersRequest{Cid: c.String(), Count: count})
+		if err != nil {
+			log.Debugf("remote plugin %s: FindProviders: %s", r.client.name, err)
+			return
+		}
+		for {
+			var ai addrInfo
+			err := s.RecvMsg(&ai)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				log.Debugf("remote plugin %s: FindProviders: %s", r.client.name, err)
+				return
+			}
+			info, err := ai.decode()
+			if err != nil {
+				log.Debugf("remote plugin %s: FindProviders: %s", r.client.name, err)
+				continue
+			}
+			select {
+			case out <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (r *router) FindPeer(ctx context.Context, id peer.ID) (peer.AddrInfo, error) {
+	var ai addrInfo
+	err := r.client.invoke(ctx, routingService, "FindPeer", &findPeerRequest{ID: id.String()}, &ai)
+	if isNotFound(err) {
+		return peer.AddrInfo{}, routing.ErrNotFound
+	}
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	return ai.decode()
+}
+
+func (r *router) PutValue(ctx context.Context, key string, value []byte, _ ...routing.Option) error {
+	return r.client.invoke(ctx, routingService, "PutValue", &putValueRequest{Key: key, Value: value}, &empty{})
+}
+
+func (r *router) GetValue(ctx context.Context, key string, _ ...routing.Option) ([]byte, error) {
+	var resp valueResponse
+	err := r.client.invoke(ctx, routingService, "GetValue", &getValueRequest{Key: key}, &resp)
+	if isNotFound(err) {
+		return nil, routing.ErrNotFound
+	}
+	return resp.Value, err
+}
+
+// SearchValue returns the single value returned by GetValue.
+func (r *router) SearchValue(ctx context.Context, key string, opts ...routing.Option) (<-chan []byte, error) {
+	value, err := r.GetValue(ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan []byte, 1)
+	out <- value
+	close(out)
+	return out, nil
+}
+
+func (r *router) Bootstrap(ctx context.Context) error {
+	return nil
+}
+
+func encodeAddrInfo(ai peer.AddrInfo) addrInfo {
+	out := addrInfo{ID: ai.ID.String()}
+	for _, a := range ai.Addrs {
+		out.Addrs = append(out.Addrs, a.String())
+	}
+	return out
+}
+
+func (ai addrInfo) decode() (peer.AddrInfo, error) {
+	id, err := peer.Decode(ai.ID)
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	out := peer.AddrInfo{ID: id}
+	for _, s := range ai.Addrs {
+		a, err := ma.NewMultiaddr(s)
+		if err != nil {
+			return peer.AddrInfo{}, err
+		}
+		out.Addrs = append(out.Addrs, a)
+	}
+	return out, nil
+}
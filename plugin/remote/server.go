@@ -0,0 +1,374 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+
+	cid "github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/ipfs/kubo/plugin"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServeOptions describes what a remote plugin process provides.
+type ServeOptions struct {
+	Name    string
+	Version string
+
+	// Init is called once with the plugin config from Kubo, before any
+	// other call. Optional.
+	Init func(env *plugin.Environment) error
+
+	// Datastore must be set by plugins of type "datastore".
+	Datastore DatastoreProvider
+
+	// Routing must be set by plugins of type "routing".
+	Routing routing.Routing
+}
+
+// DatastoreProvider is implemented by remote plugins providing a datastore.
+type DatastoreProvider interface {
+	// DiskSpec returns a minimal configuration of the datastore representing
+	// what is stored on disk. See fsrepo.DatastoreConfig.
+	DiskSpec(params map[string]interface{}) (map[string]interface{}, error)
+
+	// Open opens the datastore for the repository at path.
+	Open(path string, params map[string]interface{}) (ds.Batching, error)
+}
+
+// Serve runs a remote plugin. It is meant to be called from the main function
+// of the plugin executable, and returns once Kubo closes the plugin.
+func Serve(opts ServeOptions) error {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return errors.New("this executable is a Kubo plugin, add it to Plugins.Remote in the Kubo config instead of running it directly")
+	}
+
+	network, addr := "unix", ""
+	if runtime.GOOS == "windows" {
+		network, addr = "tcp", "127.0.0.1:0"
+	} else {
+		dir, err := os.MkdirTemp("", "kubo-plugin")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		addr = filepath.Join(dir, "plugin.sock")
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	s := &server{opts: opts, stores: make(map[uint64]ds.Batching)}
+	srv := grpc.NewServer(grpc.ForceServerCodec(jsonCodec{}))
+	srv.RegisterService(&pluginServiceDesc, s)
+	if opts.Datastore != nil {
+		srv.RegisterService(&datastoreServiceDesc, s)
+	}
+	if opts.Routing != nil {
+		srv.RegisterService(&routingServiceDesc, s)
+	}
+
+	// Kubo closes our stdin when it no longer needs the plugin.
+	go func() {
+		_, _ = io.Copy(io.Discard, os.Stdin)
+		srv.Stop()
+	}()
+
+	fmt.Printf("%s|%d|%s|%s\n", handshakePrefix, ProtocolVersion, network, l.Addr().String())
+
+	err = srv.Serve(l)
+	s.closeStores()
+	return err
+}
+
+type server struct {
+	opts ServeOptions
+
+	mu     sync.Mutex
+	next   uint64
+	stores map[uint64]ds.Batching
+}
+
+func (s *server) store(handle uint64) (ds.Batching, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.stores[handle]
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown datastore handle %d", handle)
+	}
+	return d, nil
+}
+
+func (s *server) closeStores() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for h, d := range s.stores {
+		_ = d.Close()
+		delete(s.stores, h)
+	}
+}
+
+type unaryFunc func(ctx context.Context, s *server, req interface{}) (interface{}, error)
+
+func unary(name string, newReq func() interface{}, fn unaryFunc) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := newReq()
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			resp, err := fn(ctx, srv.(*server), req)
+			return resp, toStatus(err)
+		},
+	}
+}
+
+type streamFunc func(s *server, req interface{}, stream grpc.ServerStream) error
+
+func serverStream(name string, newReq func() interface{}, fn streamFunc) grpc.StreamDesc {
+	return grpc.StreamDesc{
+		StreamName:    name,
+		ServerStreams: true,
+		Handler: func(srv interface{}, stream grpc.ServerStream) error {
+			req := newReq()
+			if err := stream.RecvMsg(req); err != nil {
+				return err
+			}
+			return toStatus(fn(srv.(*server), req, stream))
+		},
+	}
+}
+
+var pluginServiceDesc = grpc.ServiceDesc{
+	ServiceName: pluginService,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Info", func() interface{} { return new(empty) }, func(ctx context.Context, s *server, _ interface{}) (interface{}, error) {
+			return &infoResponse{Name: s.opts.Name, Version: s.opts.Version}, nil
+		}),
+		unary("Init", func() interface{} { return new(initRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*initRequest)
+			if s.opts.Init != nil {
+				if err := s.opts.Init(&plugin.Environment{Repo: r.Repo, Config: r.Config}); err != nil {
+					return nil, err
+				}
+			}
+			return &empty{}, nil
+		}),
+	},
+}
+
+var datastoreServiceDesc = grpc.ServiceDesc{
+	ServiceName: datastoreService,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("DiskSpec", func() interface{} { return new(diskSpecRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			spec, err := s.opts.Datastore.DiskSpec(req.(*diskSpecRequest).Params)
+			if err != nil {
+				return nil, err
+			}
+			return &diskSpecResponse{DiskSpec: spec}, nil
+		}),
+		unary("Open", func() interface{} { return new(openRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*openRequest)
+			d, err := s.opts.Datastore.Open(r.Path, r.Params)
+			if err != nil {
+				return nil, err
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.next++
+			s.stores[s.next] = d
+			return &handleRequest{Handle: s.next}, nil
+		}),
+		unary("Get", func() interface{} { return new(keyRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*keyRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.Get(ctx, ds.NewKey(r.Key))
+			if err != nil {
+				return nil, err
+			}
+			return &valueResponse{Value: value}, nil
+		}),
+		unary("Has", func() interface{} { return new(keyRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*keyRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			has, err := d.Has(ctx, ds.NewKey(r.Key))
+			if err != nil {
+				return nil, err
+			}
+			return &hasResponse{Has: has}, nil
+		}),
+		unary("GetSize", func() interface{} { return new(keyRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*keyRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			size, err := d.GetSize(ctx, ds.NewKey(r.Key))
+			if err != nil {
+				return nil, err
+			}
+			return &sizeResponse{Size: size}, nil
+		}),
+		unary("Put", func() interface{} { return new(putRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*putRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			return &empty{}, d.Put(ctx, ds.NewKey(r.Key), r.Value)
+		}),
+		unary("Delete", func() interface{} { return new(keyRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*keyRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			return &empty{}, d.Delete(ctx, ds.NewKey(r.Key))
+		}),
+		unary("Sync", func() interface{} { return new(keyRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*keyRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			return &empty{}, d.Sync(ctx, ds.NewKey(r.Key))
+		}),
+		unary("Batch", func() interface{} { return new(batchRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*batchRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return nil, err
+			}
+			b, err := d.Batch(ctx)
+			if err != nil {
+				return nil, err
+			}
+			for _, op := range r.Ops {
+				if op.Delete {
+					err = b.Delete(ctx, ds.NewKey(op.Key))
+				} else {
+					err = b.Put(ctx, ds.NewKey(op.Key), op.Value)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			return &empty{}, b.Commit(ctx)
+		}),
+		unary("Close", func() interface{} { return new(handleRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*handleRequest)
+			s.mu.Lock()
+			d, ok := s.stores[r.Handle]
+			delete(s.stores, r.Handle)
+			s.mu.Unlock()
+			if !ok {
+				return &empty{}, nil
+			}
+			return &empty{}, d.Close()
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		serverStream("Query", func() interface{} { return new(queryRequest) }, func(s *server, req interface{}, stream grpc.ServerStream) error {
+			r := req.(*queryRequest)
+			d, err := s.store(r.Handle)
+			if err != nil {
+				return err
+			}
+			res, err := d.Query(stream.Context(), query.Query{
+				Prefix:       r.Prefix,
+				KeysOnly:     r.KeysOnly,
+				ReturnsSizes: r.ReturnsSizes,
+			})
+			if err != nil {
+				return err
+			}
+			defer res.Close()
+			for e := range res.Next() {
+				if e.Error != nil {
+					return e.Error
+				}
+				if err := stream.SendMsg(&queryResult{Key: e.Key, Value: e.Value, Size: e.Size}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
+
+var routingServiceDesc = grpc.ServiceDesc{
+	ServiceName: routingService,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		unary("Provide", func() interface{} { return new(provideRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*provideRequest)
+			c, err := cid.Decode(r.Cid)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			return &empty{}, s.opts.Routing.Provide(ctx, c, r.Announce)
+		}),
+		unary("FindPeer", func() interface{} { return new(findPeerRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			id, err := peer.Decode(req.(*findPeerRequest).ID)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			ai, err := s.opts.Routing.FindPeer(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			out := encodeAddrInfo(ai)
+			return &out, nil
+		}),
+		unary("PutValue", func() interface{} { return new(putValueRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			r := req.(*putValueRequest)
+			return &empty{}, s.opts.Routing.PutValue(ctx, r.Key, r.Value)
+		}),
+		unary("GetValue", func() interface{} { return new(getValueRequest) }, func(ctx context.Context, s *server, req interface{}) (interface{}, error) {
+			value, err := s.opts.Routing.GetValue(ctx, req.(*getValueRequest).Key)
+			if err != nil {
+				return nil, err
+			}
+			return &valueResponse{Value: value}, nil
+		}),
+	},
+	Streams: []grpc.StreamDesc{
+		serverStream("FindProviders", func() interface{} { return new(findProvidersRequest) }, func(s *server, req interface{}, stream grpc.ServerStream) error {
+			r := req.(*findProvidersRequest)
+			c, err := cid.Decode(r.Cid)
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			for ai := range s.opts.Routing.FindProvidersAsync(stream.Context(), c, r.Count) {
+				out := encodeAddrInfo(ai)
+				if err := stream.SendMsg(&out); err != nil {
+					return err
+				}
+			}
+			return nil
+		}),
+	},
+}
@@ -107,6 +107,10 @@ type FSRepo struct {
 	ds       repo.Datastore
 	keystore keystore.Keystore
 	filemgr  *filestore.FileManager
+
+	// readReplica is set for the repos opened with OpenReadReplica, holding
+	// a replica lock in lockfile
+	readReplica bool
 }
 
 var _ repo.Repo = (*FSRepo)(nil)
@@ -156,21 +160,10 @@ func open(repoPath string, userConfigFilePath string) (repo.Repo, error) {
 	}()
 
 	// Check version, and error out if not matching
-	ver, err := migrations.RepoVersion(r.path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, ErrNoVersion
-		}
+	if err := checkRepoVersion(r.path); err != nil {
 		return nil, err
 	}
 
-	if RepoVersion > ver {
-		return nil, ErrNeedMigration
-	} else if ver > RepoVersion {
-		// program version too low for existing repo
-		return nil, fmt.Errorf(programTooLowMessage, RepoVersion, ver)
-	}
-
 	// check repo path, then check all constituent parts.
 	if err := dir.Writable(r.path); err != nil {
 		return nil, err
@@ -198,6 +191,26 @@ func open(repoPath string, userConfigFilePath string) (repo.Repo, error) {
 	return r, nil
 }
 
+// checkRepoVersion returns an error if the version of the repo at path isn't
+// RepoVersion.
+func checkRepoVersion(path string) error {
+	ver, err := migrations.RepoVersion(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNoVersion
+		}
+		return err
+	}
+
+	if RepoVersion > ver {
+		return ErrNeedMigration
+	} else if ver > RepoVersion {
+		// program version too low for existing repo
+		return fmt.Errorf(programTooLowMessage, RepoVersion, ver)
+	}
+	return nil
+}
+
 func newFSRepo(rpath string, userConfigFilePath string) (*FSRepo, error) {
 	expPath, err := homedir.Expand(filepath.Clean(rpath))
 	if err != nil {
@@ -360,8 +373,13 @@ func (r *FSRepo) Path() string {
 	return r.path
 }
 
-// SetAPIAddr writes the API Addr to the /api file.
+// SetAPIAddr writes the API Addr to the /api file. The read replicas don't
+// write it, so that the commands keep using the API of the writer.
 func (r *FSRepo) SetAPIAddr(addr ma.Multiaddr) error {
+	if r.readReplica {
+		return nil
+	}
+
 	// Create a temp file to write the address, so that we don't leave empty file when the
 	// program crashes after creating the file.
 	f, err := os.Create(filepath.Join(r.path, "."+apiFile+".tmp"))
@@ -389,8 +407,13 @@ func (r *FSRepo) SetAPIAddr(addr ma.Multiaddr) error {
 	return err
 }
 
-// SetGatewayAddr writes the Gateway Addr to the /gateway file.
+// SetGatewayAddr writes the Gateway Addr to the /gateway file, except for
+// the read replicas.
 func (r *FSRepo) SetGatewayAddr(addr net.Addr) error {
+	if r.readReplica {
+		return nil
+	}
+
 	// Create a temp file to write the address, so that we don't leave empty file when the
 	// program crashes after creating the file.
 	tmpPath := filepath.Join(r.path, "."+gatewayFile+".tmp")
@@ -451,10 +474,37 @@ func (r *FSRepo) openKeystore() error {
 
 // openDatastore returns an error if the config file is not present.
 func (r *FSRepo) openDatastore() error {
+	dsc, err := r.datastoreConfig()
+	if err != nil {
+		return err
+	}
+
+	d, err := dsc.Create(r.path)
+	if err != nil {
+		return err
+	}
+
+	// Update the pin set and the MFS root through the journal
+	j, err := openJournal(d, filepath.Join(r.path, journalFile))
+	if err != nil {
+		d.Close()
+		return err
+	}
+
+	// Wrap it with metrics gathering
+	prefix := "ipfs.fsrepo.datastore"
+	r.ds = measure.New(prefix, j)
+
+	return nil
+}
+
+// datastoreConfig returns the config of the datastore, checking that it
+// matches the datastore on disk.
+func (r *FSRepo) datastoreConfig() (DatastoreConfig, error) {
 	if r.config.Datastore.Type != "" || r.config.Datastore.Path != "" {
-		return fmt.Errorf("old style datatstore config detected")
+		return nil, fmt.Errorf("old style datatstore config detected")
 	} else if r.config.Datastore.Spec == nil {
-		return fmt.Errorf("required Datastore.Spec entry missing from config file")
+		return nil, fmt.Errorf("required Datastore.Spec entry missing from config file")
 	}
 	if r.config.Datastore.NoSync {
 		log.Warn("NoSync is now deprecated in favor of datastore specific settings. If you want to disable fsync on flatfs set 'sync' to false. See https://github.com/ipfs/kubo/blob/master/docs/datastores.md#flatfs.")
@@ -462,30 +512,19 @@ func (r *FSRepo) openDatastore() error {
 
 	dsc, err := AnyDatastoreConfig(r.config.Datastore.Spec)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	spec := dsc.DiskSpec()
 
 	oldSpec, err := r.readSpec()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if oldSpec != spec.String() {
-		return fmt.Errorf("datastore configuration of '%s' does not match what is on disk '%s'",
+		return nil, fmt.Errorf("datastore configuration of '%s' does not match what is on disk '%s'",
 			oldSpec, spec.String())
 	}
-
-	d, err := dsc.Create(r.path)
-	if err != nil {
-		return err
-	}
-	r.ds = d
-
-	// Wrap it with metrics gathering
-	prefix := "ipfs.fsrepo.datastore"
-	r.ds = measure.New(prefix, r.ds)
-
-	return nil
+	return dsc, nil
 }
 
 func (r *FSRepo) readSpec() (string, error) {
@@ -509,14 +548,17 @@ func (r *FSRepo) Close() error {
 		return errors.New("repo is closed")
 	}
 
-	err := os.Remove(filepath.Join(r.path, apiFile))
-	if err != nil && !os.IsNotExist(err) {
-		log.Warn("error removing api file: ", err)
-	}
+	// the api and gateway files are the writer's
+	if !r.readReplica {
+		err := os.Remove(filepath.Join(r.path, apiFile))
+		if err != nil && !os.IsNotExist(err) {
+			log.Warn("error removing api file: ", err)
+		}
 
-	err = os.Remove(filepath.Join(r.path, gatewayFile))
-	if err != nil && !os.IsNotExist(err) {
-		log.Warn("error removing gateway file: ", err)
+		err = os.Remove(filepath.Join(r.path, gatewayFile))
+		if err != nil && !os.IsNotExist(err) {
+			log.Warn("error removing gateway file: ", err)
+		}
 	}
 
 	if err := r.ds.Close(); err != nil {
@@ -559,6 +601,10 @@ func (r *FSRepo) FileManager() *filestore.FileManager {
 }
 
 func (r *FSRepo) BackupConfig(prefix string) (string, error) {
+	if r.readReplica {
+		return "", ErrReadReplica
+	}
+
 	temp, err := os.CreateTemp(r.path, "config-"+prefix)
 	if err != nil {
 		return "", err
@@ -599,6 +645,10 @@ func (r *FSRepo) SetConfig(updated *config.Config) error {
 	packageLock.Lock()
 	defer packageLock.Unlock()
 
+	if r.readReplica {
+		return ErrReadReplica
+	}
+
 	// to avoid clobbering user-provided keys, must read the config from disk
 	// as a map, write the updated struct values to the map and write the map
 	// to disk.
@@ -644,6 +694,9 @@ func (r *FSRepo) SetConfigKey(key string, value interface{}) error {
 	if r.closed {
 		return errors.New("repo is closed")
 	}
+	if r.readReplica {
+		return ErrReadReplica
+	}
 
 	// Load into a map so we don't end up writing any additional defaults to the config file.
 	var mapconf map[string]interface{}
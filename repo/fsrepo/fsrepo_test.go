@@ -116,3 +116,42 @@ func TestOpenMoreThanOnceInSameProcess(t *testing.T) {
 	assert.Nil(r1.Close(), t)
 	assert.Nil(r2.Close(), t)
 }
+
+func TestReadReplica(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	path := testRepoPath("", t)
+	assert.Nil(Init(path, &config.Config{Datastore: config.DefaultDatastoreConfig()}), t)
+
+	writer, err := Open(path)
+	assert.Nil(err, t, "writer should open successfully")
+	block := datastore.NewKey("/blocks/CIQREPLICATEDBLOCK")
+	local := datastore.NewKey("/local/key")
+	assert.Nil(writer.Datastore().Put(ctx, block, []byte("block")), t)
+	assert.Nil(writer.Datastore().Put(ctx, local, []byte("local")), t)
+
+	replica, err := OpenReadReplica(path)
+	assert.Nil(err, t, "replica should open while the writer is open")
+	replicas, err := ReadReplicas(path)
+	assert.Nil(err, t)
+	assert.True(replicas == 1, t, "one replica should be open")
+
+	data, err := replica.Datastore().Get(ctx, block)
+	assert.Nil(err, t, "replica should read the blocks of the writer")
+	assert.True(bytes.Equal(data, []byte("block")), t, "data should match")
+	_, err = replica.Datastore().Get(ctx, local)
+	assert.True(err == datastore.ErrNotFound, t, "replica should not read the state of the writer")
+	assert.True(replica.SetConfigKey("Foo", "bar") == ErrReadReplica, t, "replica should not write the config")
+
+	replicaCfg, err := replica.Config()
+	assert.Nil(err, t)
+	writerCfg, err := writer.Config()
+	assert.Nil(err, t)
+	assert.True(replicaCfg.Identity.PeerID != writerCfg.Identity.PeerID, t, "replica should have its own identity")
+
+	assert.Nil(replica.Close(), t)
+	replicas, err = ReadReplicas(path)
+	assert.Nil(err, t)
+	assert.True(replicas == 0, t, "no replica should be open")
+	assert.Nil(writer.Close(), t)
+}
@@ -0,0 +1,464 @@
+package fsrepo
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	repo "github.com/ipfs/kubo/repo"
+)
+
+// journalFile is the filename of the write-ahead journal of the datastore,
+// relative to the repo path.
+const journalFile = "datastore.journal"
+
+// journaledPrefixes are the keys updated through the journal: the pin set and
+// the MFS root, which are rewritten in place and can't be rebuilt when an
+// update is interrupted.
+var journaledPrefixes = []ds.Key{
+	ds.NewKey("/pins"),
+	ds.NewKey("/local/filesroot"),
+}
+
+// types of the journal records
+const (
+	journalPut byte = iota + 1
+	journalDelete
+	journalCommit
+)
+
+// journalHeaderSize is the size of the header of a record: the CRC-32 and the
+// size of the payload.
+const journalHeaderSize = 8
+
+var errTornRecord = errors.New("torn journal record")
+
+type journalOp struct {
+	key    ds.Key
+	value  []byte
+	delete bool
+}
+
+func isJournaled(key ds.Key) bool {
+	for _, p := range journaledPrefixes {
+		if key.Equal(p) || p.IsAncestorOf(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// journaledDatastore writes the updates of the journaled keys to a
+// write-ahead journal, and applies them to the datastore when they are
+// synced: the updates between two syncs, such as the pins added by a command
+// or a new MFS root, are applied entirely or not at all, even on power loss.
+// The updates not applied yet are read from memory.
+type journaledDatastore struct {
+	child repo.Datastore
+
+	mu sync.RWMutex
+	f  *os.File
+	// pending are the last updates of the keys since the last commit
+	pending map[ds.Key]journalOp
+}
+
+var _ repo.Datastore = (*journaledDatastore)(nil)
+var _ ds.PersistentDatastore = (*journaledDatastore)(nil)
+
+// openJournal opens the journal at path for the datastore, and replays the
+// updates it holds which were committed but maybe not applied before a crash.
+func openJournal(child repo.Datastore, path string) (*journaledDatastore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	j := &journaledDatastore{
+		child:   child,
+		f:       f,
+		pending: make(map[ds.Key]journalOp),
+	}
+	if err := j.recover(context.Background()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("recovering datastore journal: %w", err)
+	}
+	return j, nil
+}
+
+func (j *journaledDatastore) recover(ctx context.Context) error {
+	var committed, uncommitted []journalOp
+	r := bufio.NewReader(j.f)
+	for {
+		typ, op, err := readJournalRecord(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the end of the journal was being written
+			log.Warnf("ignoring the end of the datastore journal: %s", err)
+			break
+		}
+		if typ == journalCommit {
+			committed = append(committed, uncommitted...)
+			uncommitted = nil
+			continue
+		}
+		uncommitted = append(uncommitted, op)
+	}
+
+	if len(uncommitted) > 0 {
+		log.Warnf("discarding %d uncommitted updates of the datastore journal", len(uncommitted))
+	}
+	if len(committed) > 0 {
+		log.Infof("replaying %d updates of the datastore journal", len(committed))
+		if err := j.apply(ctx, committed); err != nil {
+			return err
+		}
+	}
+	return j.truncate()
+}
+
+// readJournalRecord reads a record, returning io.EOF at the end of the
+// journal.
+func readJournalRecord(r io.Reader) (typ byte, op journalOp, err error) {
+	var header [journalHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.EOF {
+			return 0, op, io.EOF
+		}
+		return 0, op, errTornRecord
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[4:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, op, errTornRecord
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[:4]) || len(payload) == 0 {
+		return 0, op, errTornRecord
+	}
+
+	typ = payload[0]
+	switch typ {
+	case journalCommit:
+		return typ, op, nil
+	case journalPut, journalDelete:
+	default:
+		return 0, op, fmt.Errorf("unknown journal record type %d", typ)
+	}
+	keyLen, n := binary.Uvarint(payload[1:])
+	if n <= 0 || keyLen > uint64(len(payload)-1-n) {
+		return 0, op, errTornRecord
+	}
+	key := payload[1+n : 1+n+int(keyLen)]
+	op = journalOp{
+		key:    ds.RawKey(string(key)),
+		value:  payload[1+n+int(keyLen):],
+		delete: typ == journalDelete,
+	}
+	return typ, op, nil
+}
+
+// appendJournalRecord appends the record of the update, or of a commit when
+// op is nil, to buf.
+func appendJournalRecord(buf []byte, op *journalOp) []byte {
+	payload := []byte{journalCommit}
+	if op != nil {
+		payload[0] = journalPut
+		if op.delete {
+			payload[0] = journalDelete
+		}
+		key := op.key.String()
+		var keyLen [binary.MaxVarintLen64]byte
+		payload = append(payload, keyLen[:binary.PutUvarint(keyLen[:], uint64(len(key)))]...)
+		payload = append(payload, key...)
+		payload = append(payload, op.value...)
+	}
+
+	var header [journalHeaderSize]byte
+	binary.BigEndian.PutUint32(header[:4], crc32.ChecksumIEEE(payload))
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	buf = append(buf, header[:]...)
+	return append(buf, payload...)
+}
+
+// record writes the updates to the journal, without syncing it.
+func (j *journaledDatastore) record(ops ...journalOp) error {
+	var buf []byte
+	for i := range ops {
+		buf = appendJournalRecord(buf, &ops[i])
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(buf); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		j.pending[op.key] = op
+	}
+	return nil
+}
+
+// commit syncs the journal with a commit record, then applies the pending
+// updates to the datastore.
+func (j *journaledDatastore) commit(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pending) == 0 {
+		return nil
+	}
+
+	if _, err := j.f.Write(appendJournalRecord(nil, nil)); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+
+	ops := make([]journalOp, 0, len(j.pending))
+	for _, op := range j.pending {
+		ops = append(ops, op)
+	}
+	if err := j.apply(ctx, ops); err != nil {
+		return err
+	}
+	j.pending = make(map[ds.Key]journalOp)
+	return j.truncate()
+}
+
+// apply applies the updates to the datastore, and syncs them.
+func (j *journaledDatastore) apply(ctx context.Context, ops []journalOp) error {
+	b, err := j.child.Batch(ctx)
+	if err != nil {
+		return err
+	}
+	for _, op := range ops {
+		if op.delete {
+			err = b.Delete(ctx, op.key)
+		} else {
+			err = b.Put(ctx, op.key, op.value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := b.Commit(ctx); err != nil {
+		return err
+	}
+	for _, p := range journaledPrefixes {
+		if err := j.child.Sync(ctx, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate empties the journal once its updates are applied. It is synced,
+// so that no stale record follows the next ones after a crash.
+func (j *journaledDatastore) truncate() error {
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := j.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return j.f.Sync()
+}
+
+func (j *journaledDatastore) lookup(key ds.Key) (journalOp, bool) {
+	if !isJournaled(key) {
+		return journalOp{}, false
+	}
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	op, ok := j.pending[key]
+	return op, ok
+}
+
+func (j *journaledDatastore) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	if op, ok := j.lookup(key); ok {
+		if op.delete {
+			return nil, ds.ErrNotFound
+		}
+		return op.value, nil
+	}
+	return j.child.Get(ctx, key)
+}
+
+func (j *journaledDatastore) Has(ctx context.Context, key ds.Key) (bool, error) {
+	if op, ok := j.lookup(key); ok {
+		return !op.delete, nil
+	}
+	return j.child.Has(ctx, key)
+}
+
+func (j *journaledDatastore) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	if op, ok := j.lookup(key); ok {
+		if op.delete {
+			return -1, ds.ErrNotFound
+		}
+		return len(op.value), nil
+	}
+	return j.child.GetSize(ctx, key)
+}
+
+func (j *journaledDatastore) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	prefix := ds.NewKey(q.Prefix)
+	j.mu.RLock()
+	var pending []journalOp
+	for k, op := range j.pending {
+		if k.Equal(prefix) || prefix.IsAncestorOf(k) {
+			pending = append(pending, op)
+		}
+	}
+	j.mu.RUnlock()
+	if len(pending) == 0 {
+		return j.child.Query(ctx, q)
+	}
+
+	// merge the pending updates with the entries of the datastore, and
+	// then filter and order them
+	res, err := j.child.Query(ctx, query.Query{
+		Prefix:       q.Prefix,
+		KeysOnly:     q.KeysOnly,
+		ReturnsSizes: q.ReturnsSizes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		return nil, err
+	}
+	updated := make(map[string]bool, len(pending))
+	for _, op := range pending {
+		updated[op.key.String()] = true
+	}
+	merged := entries[:0]
+	for _, e := range entries {
+		if !updated[e.Key] {
+			merged = append(merged, e)
+		}
+	}
+	for _, op := range pending {
+		if op.delete {
+			continue
+		}
+		e := query.Entry{Key: op.key.String(), Size: len(op.value)}
+		if !q.KeysOnly {
+			e.Value = op.value
+		}
+		merged = append(merged, e)
+	}
+	return query.NaiveQueryApply(q, query.ResultsWithEntries(q, merged)), nil
+}
+
+func (j *journaledDatastore) Put(ctx context.Context, key ds.Key, value []byte) error {
+	if !isJournaled(key) {
+		return j.child.Put(ctx, key, value)
+	}
+	return j.record(journalOp{key: key, value: value})
+}
+
+func (j *journaledDatastore) Delete(ctx context.Context, key ds.Key) error {
+	if !isJournaled(key) {
+		return j.child.Delete(ctx, key)
+	}
+	return j.record(journalOp{key: key, delete: true})
+}
+
+// Sync commits the pending updates when the prefix covers journaled keys.
+func (j *journaledDatastore) Sync(ctx context.Context, prefix ds.Key) error {
+	for _, p := range journaledPrefixes {
+		if prefix.Equal(p) || prefix.IsAncestorOf(p) || p.IsAncestorOf(prefix) {
+			if err := j.commit(ctx); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return j.child.Sync(ctx, prefix)
+}
+
+func (j *journaledDatastore) Batch(ctx context.Context) (ds.Batch, error) {
+	b, err := j.child.Batch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &journaledBatch{j: j, child: b}, nil
+}
+
+func (j *journaledDatastore) Close() error {
+	if err := j.commit(context.Background()); err != nil {
+		return err
+	}
+	if err := j.f.Close(); err != nil {
+		return err
+	}
+	return j.child.Close()
+}
+
+func (j *journaledDatastore) DiskUsage(ctx context.Context) (uint64, error) {
+	return ds.DiskUsage(ctx, j.child)
+}
+
+func (j *journaledDatastore) Check(ctx context.Context) error {
+	if c, ok := j.child.(ds.CheckedDatastore); ok {
+		return c.Check(ctx)
+	}
+	return nil
+}
+
+func (j *journaledDatastore) Scrub(ctx context.Context) error {
+	if c, ok := j.child.(ds.ScrubbedDatastore); ok {
+		return c.Scrub(ctx)
+	}
+	return nil
+}
+
+func (j *journaledDatastore) CollectGarbage(ctx context.Context) error {
+	if c, ok := j.child.(ds.GCDatastore); ok {
+		return c.CollectGarbage(ctx)
+	}
+	return nil
+}
+
+// journaledBatch records the updates of the journaled keys when committed,
+// and batches the others.
+type journaledBatch struct {
+	j     *journaledDatastore
+	child ds.Batch
+	ops   []journalOp
+}
+
+func (b *journaledBatch) Put(ctx context.Context, key ds.Key, value []byte) error {
+	if !isJournaled(key) {
+		return b.child.Put(ctx, key, value)
+	}
+	b.ops = append(b.ops, journalOp{key: key, value: value})
+	return nil
+}
+
+func (b *journaledBatch) Delete(ctx context.Context, key ds.Key) error {
+	if !isJournaled(key) {
+		return b.child.Delete(ctx, key)
+	}
+	b.ops = append(b.ops, journalOp{key: key, delete: true})
+	return nil
+}
+
+func (b *journaledBatch) Commit(ctx context.Context) error {
+	if len(b.ops) > 0 {
+		if err := b.j.record(b.ops...); err != nil {
+			return err
+		}
+		b.ops = nil
+	}
+	return b.child.Commit(ctx)
+}
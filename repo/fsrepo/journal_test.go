@@ -0,0 +1,108 @@
+package fsrepo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+)
+
+func TestJournalCommit(t *testing.T) {
+	ctx := context.Background()
+	child := dssync.MutexWrap(ds.NewMapDatastore())
+	j, err := openJournal(child, filepath.Join(t.TempDir(), journalFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	pin := ds.NewKey("/pins/pin")
+	block := ds.NewKey("/blocks/block")
+	if err := j.Put(ctx, pin, []byte("pin")); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Put(ctx, block, []byte("block")); err != nil {
+		t.Fatal(err)
+	}
+
+	if has, _ := child.Has(ctx, block); !has {
+		t.Error("the keys not journaled should be written directly")
+	}
+	if has, _ := child.Has(ctx, pin); has {
+		t.Error("the journaled keys should not be written before a sync")
+	}
+	if v, err := j.Get(ctx, pin); err != nil || string(v) != "pin" {
+		t.Errorf("the pending updates should be read, got %q, %v", v, err)
+	}
+	res, err := j.Query(ctx, query.Query{Prefix: "/pins"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := res.Rest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Key != pin.String() {
+		t.Errorf("the pending updates should be queried, got %v", entries)
+	}
+
+	if err := j.Sync(ctx, ds.NewKey("/pins")); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := child.Get(ctx, pin); err != nil || string(v) != "pin" {
+		t.Errorf("the journaled keys should be written after a sync, got %q, %v", v, err)
+	}
+	if fi, err := j.f.Stat(); err != nil || fi.Size() != 0 {
+		t.Errorf("the journal should be emptied after a sync")
+	}
+
+	if err := j.Delete(ctx, pin); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := j.Has(ctx, pin); has {
+		t.Error("the pending deletes should be read")
+	}
+}
+
+func TestJournalRecovery(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), journalFile)
+
+	var journal []byte
+	journal = appendJournalRecord(journal, &journalOp{key: ds.NewKey("/pins/committed"), value: []byte("1")})
+	journal = appendJournalRecord(journal, &journalOp{key: ds.NewKey("/pins/deleted"), delete: true})
+	journal = appendJournalRecord(journal, nil)
+	journal = appendJournalRecord(journal, &journalOp{key: ds.NewKey("/pins/uncommitted"), value: []byte("2")})
+	torn := appendJournalRecord(nil, nil)
+	journal = append(journal, torn[:len(torn)-1]...)
+	if err := os.WriteFile(path, journal, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	child := dssync.MutexWrap(ds.NewMapDatastore())
+	if err := child.Put(ctx, ds.NewKey("/pins/deleted"), []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+	j, err := openJournal(child, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	if v, err := child.Get(ctx, ds.NewKey("/pins/committed")); err != nil || string(v) != "1" {
+		t.Errorf("the committed updates should be replayed, got %q, %v", v, err)
+	}
+	if has, _ := child.Has(ctx, ds.NewKey("/pins/deleted")); has {
+		t.Error("the committed deletes should be replayed")
+	}
+	if has, _ := child.Has(ctx, ds.NewKey("/pins/uncommitted")); has {
+		t.Error("the uncommitted updates should be discarded")
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() != 0 {
+		t.Errorf("the journal should be emptied after the recovery")
+	}
+}
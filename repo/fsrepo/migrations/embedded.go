@@ -0,0 +1,204 @@
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	serialize "github.com/ipfs/kubo/config/serialize"
+)
+
+// embeddedMigration is a migration built into kubo, run in-process instead of
+// downloading its binary.
+type embeddedMigration struct {
+	// apply migrates the repo in ipfsDir, and revert migrates it back. They
+	// update the repo version.
+	apply  func(ipfsDir string) error
+	revert func(ipfsDir string) error
+}
+
+// embeddedMigrations are the migrations built into kubo, by name. Migrations
+// which aren't embedded, e.g. the ones rewriting the datastore, are
+// downloaded.
+var embeddedMigrations = map[string]embeddedMigration{
+	migrationName(12, 13): {
+		apply:  func(ipfsDir string) error { return migrate12to13(ipfsDir, false) },
+		revert: func(ipfsDir string) error { return migrate12to13(ipfsDir, true) },
+	},
+}
+
+// IsEmbedded returns whether the named migration is built into kubo.
+func IsEmbedded(name string) bool {
+	_, ok := embeddedMigrations[name]
+	return ok
+}
+
+func (m embeddedMigration) run(ipfsDir string, revert bool) error {
+	if revert {
+		return m.revert(ipfsDir)
+	}
+	return m.apply(ipfsDir)
+}
+
+// editConfig edits the config file of the repo as JSON, keeping the fields
+// the edit doesn't know about.
+func editConfig(ipfsDir string, edit func(cfg map[string]interface{}) error) error {
+	p := filepath.Join(ipfsDir, "config")
+	cfg := make(map[string]interface{})
+	if err := serialize.ReadConfigFile(p, &cfg); err != nil {
+		return err
+	}
+	if err := edit(cfg); err != nil {
+		return err
+	}
+	return serialize.WriteConfigFile(p, cfg)
+}
+
+// migrate12to13 adds the QUIC v1 and WebTransport addresses next to the QUIC
+// draft-29 addresses of the config, or removes them when reverting.
+func migrate12to13(ipfsDir string, revert bool) error {
+	from, to := 12, 13
+	if revert {
+		from, to = to, from
+	}
+	ver, err := repoVersion(ipfsDir)
+	if err != nil {
+		return err
+	}
+	if ver != from {
+		return fmt.Errorf("repo version is %d, expected %d", ver, from)
+	}
+
+	err = editConfig(ipfsDir, func(cfg map[string]interface{}) error {
+		addresses, _ := cfg["Addresses"].(map[string]interface{})
+		if addresses == nil {
+			return nil
+		}
+		for _, field := range []string{"Swarm", "Announce", "AppendAnnounce", "NoAnnounce"} {
+			addrs, ok := addresses[field].([]interface{})
+			if !ok {
+				continue
+			}
+			if revert {
+				addresses[field] = removeQuicV1(addrs)
+			} else {
+				addresses[field] = addQuicV1(addrs)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return WriteRepoVersion(ipfsDir, to)
+}
+
+func addQuicV1(addrs []interface{}) []interface{} {
+	seen := make(map[string]bool, len(addrs))
+	for _, a := range addrs {
+		if s, ok := a.(string); ok {
+			seen[s] = true
+		}
+	}
+	out := make([]interface{}, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a)
+		s, ok := a.(string)
+		if !ok || !strings.HasSuffix(s, "/quic") {
+			continue
+		}
+		for _, added := range []string{s + "-v1", s + "-v1/webtransport"} {
+			if !seen[added] {
+				seen[added] = true
+				out = append(out, added)
+			}
+		}
+	}
+	return out
+}
+
+func removeQuicV1(addrs []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(addrs))
+	for _, a := range addrs {
+		if s, ok := a.(string); ok && (strings.HasSuffix(s, "/quic-v1") || strings.HasSuffix(s, "/quic-v1/webtransport")) {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// backupFiles are the files and directories of the repo saved before each
+// migration, and restored if it fails. The datastore is too large to be
+// saved.
+var backupFiles = []string{versionFile, "config", "datastore_spec", "keystore"}
+
+// backupRepo saves the config, keys and version of the repo in
+// <ipfsDir>/migration-backup/<migration>, and returns the backup directory.
+func backupRepo(ipfsDir, migration string) (string, error) {
+	dir := filepath.Join(ipfsDir, "migration-backup", migration)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	for _, name := range backupFiles {
+		if err := copyPath(filepath.Join(ipfsDir, name), filepath.Join(dir, name)); err != nil {
+			return "", fmt.Errorf("backing up %s: %w", name, err)
+		}
+	}
+	return dir, nil
+}
+
+// restoreRepo restores the files saved by backupRepo.
+func restoreRepo(ipfsDir, backupDir string) error {
+	for _, name := range backupFiles {
+		src := filepath.Join(backupDir, name)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		dst := filepath.Join(ipfsDir, name)
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := copyPath(src, dst); err != nil {
+			return fmt.Errorf("restoring %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// copyPath copies the file or directory at src to dst, and ignores a missing
+// src.
+func copyPath(src, dst string) error {
+	fi, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		data, err := os.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dst, data, fi.Mode().Perm())
+	}
+
+	if err := os.MkdirAll(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := copyPath(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestRepo(t *testing.T, version int, config string) string {
+	dir := t.TempDir()
+	if err := WriteRepoVersion(dir, version); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEmbeddedMigration(t *testing.T) {
+	dir := writeTestRepo(t, 12, `{"Addresses": {"Swarm": ["/ip4/0.0.0.0/tcp/4001", "/ip4/0.0.0.0/udp/4001/quic"]}, "Unknown": 1}`)
+	ctx := context.Background()
+
+	steps, err := PlanMigration(ctx, 13, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || !steps[0].Embedded {
+		t.Fatalf("expected one embedded migration, got %v", steps)
+	}
+
+	// embedded migrations don't need a fetcher
+	if err := RunMigration(ctx, nil, 13, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	addrs := func() []interface{} {
+		cfg := make(map[string]interface{})
+		if err := editConfig(dir, func(c map[string]interface{}) error { cfg = c; return nil }); err != nil {
+			t.Fatal(err)
+		}
+		if cfg["Unknown"] != float64(1) {
+			t.Fatal("unknown config field not kept")
+		}
+		return cfg["Addresses"].(map[string]interface{})["Swarm"].([]interface{})
+	}
+	expected := []interface{}{
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic",
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+		"/ip4/0.0.0.0/udp/4001/quic-v1/webtransport",
+	}
+	if got := addrs(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if ver, _ := RepoVersion(dir); ver != 13 {
+		t.Fatalf("expected version 13, got %d", ver)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "migration-backup", "fs-repo-12-to-13", "config")); err != nil {
+		t.Fatal("expected a backup of the config")
+	}
+
+	if err := RunMigration(ctx, nil, 12, dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := addrs(); !reflect.DeepEqual(got, expected[:2]) {
+		t.Fatalf("expected %v, got %v", expected[:2], got)
+	}
+}
+
+func TestMigrationRollback(t *testing.T) {
+	dir := writeTestRepo(t, 12, `{"Addresses": {"Swarm": ["/ip4/0.0.0.0/udp/4001/quic"]}}`)
+	ctx := context.Background()
+
+	embeddedMigrations["fs-repo-12-to-13"] = embeddedMigration{
+		apply: func(ipfsDir string) error {
+			// fail after a partial edit
+			if err := os.WriteFile(filepath.Join(ipfsDir, "config"), []byte("{}"), 0o600); err != nil {
+				return err
+			}
+			if err := WriteRepoVersion(ipfsDir, 13); err != nil {
+				return err
+			}
+			return os.ErrInvalid
+		},
+	}
+	defer func() {
+		embeddedMigrations["fs-repo-12-to-13"] = embeddedMigration{
+			apply:  func(ipfsDir string) error { return migrate12to13(ipfsDir, false) },
+			revert: func(ipfsDir string) error { return migrate12to13(ipfsDir, true) },
+		}
+	}()
+
+	if err := RunMigration(ctx, nil, 13, dir, false); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+	if ver, _ := RepoVersion(dir); ver != 12 {
+		t.Fatalf("expected version 12 to be restored, got %d", ver)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"Addresses": {"Swarm": ["/ip4/0.0.0.0/udp/4001/quic"]}}` {
+		t.Fatalf("config not restored: %s", data)
+	}
+}
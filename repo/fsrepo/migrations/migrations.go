@@ -51,15 +51,14 @@ func RunMigration(ctx context.Context, fetcher Fetcher, targetVer int, ipfsDir s
 		return err
 	}
 
-	// Download migrations that were not found
-	if len(binPaths) < len(migrations) {
-		missing := make([]string, 0, len(migrations)-len(binPaths))
-		for _, mig := range migrations {
-			if _, ok := binPaths[mig]; !ok {
-				missing = append(missing, mig)
-			}
+	// Download migrations that were neither found nor embedded
+	missing := make([]string, 0, len(migrations)-len(binPaths))
+	for _, mig := range migrations {
+		if _, ok := binPaths[mig]; !ok && !IsEmbedded(mig) {
+			missing = append(missing, mig)
 		}
-
+	}
+	if len(missing) != 0 {
 		logger.Println("Need", len(missing), "migrations, downloading.")
 
 		tmpDir, err := os.MkdirTemp("", "migrations")
@@ -85,8 +84,22 @@ func RunMigration(ctx context.Context, fetcher Fetcher, targetVer int, ipfsDir s
 	}
 	for _, migration := range migrations {
 		logger.Println("Running migration", migration, "...")
-		err = runMigration(ctx, binPaths[migration], ipfsDir, revert, logger)
+		backupDir, err := backupRepo(ipfsDir, migration)
 		if err != nil {
+			return fmt.Errorf("backup before migration %s failed: %s", migration, err)
+		}
+		if m, ok := embeddedMigrations[migration]; ok {
+			logger.Println("  => Running embedded migration")
+			err = m.run(ipfsDir, revert)
+		} else {
+			err = runMigration(ctx, binPaths[migration], ipfsDir, revert, logger)
+		}
+		if err != nil {
+			// the config, keys and version are restored, not the datastore
+			logger.Println("Restoring the config and keys from", backupDir)
+			if rerr := restoreRepo(ipfsDir, backupDir); rerr != nil {
+				logger.Println("Failed to restore the backup:", rerr)
+			}
 			return fmt.Errorf("migration %s failed: %s", migration, err)
 		}
 	}
@@ -95,6 +108,53 @@ func RunMigration(ctx context.Context, fetcher Fetcher, targetVer int, ipfsDir s
 	return nil
 }
 
+// Step is a migration run by RunMigration.
+type Step struct {
+	Name string
+	// Embedded is set for the migrations built into kubo.
+	Embedded bool
+	// Path is the migration binary found in PATH, empty if it must be
+	// downloaded.
+	Path string
+}
+
+func (s Step) String() string {
+	switch {
+	case s.Embedded:
+		return s.Name + " (embedded)"
+	case s.Path != "":
+		return s.Name + " (" + s.Path + ")"
+	default:
+		return s.Name + " (download)"
+	}
+}
+
+// PlanMigration returns the migrations RunMigration would run to migrate the
+// repo to the target version, without running them.
+func PlanMigration(ctx context.Context, targetVer int, ipfsDir string, allowDowngrade bool) ([]Step, error) {
+	ipfsDir, err := CheckIpfsDir(ipfsDir)
+	if err != nil {
+		return nil, err
+	}
+	fromVer, err := RepoVersion(ipfsDir)
+	if err != nil {
+		return nil, fmt.Errorf("could not get repo version: %s", err)
+	}
+	if fromVer > targetVer && !allowDowngrade {
+		return nil, fmt.Errorf("downgrade not allowed from %d to %d", fromVer, targetVer)
+	}
+
+	migrations, binPaths, err := findMigrations(ctx, fromVer, targetVer)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]Step, len(migrations))
+	for i, mig := range migrations {
+		steps[i] = Step{Name: mig, Embedded: IsEmbedded(mig), Path: binPaths[mig]}
+	}
+	return steps, nil
+}
+
 func NeedMigration(target int) (bool, error) {
 	vnum, err := RepoVersion("")
 	if err != nil {
@@ -224,6 +284,9 @@ func findMigrations(ctx context.Context, from, to int) ([]string, map[string]str
 			migName = migrationName(cur, cur+step)
 		}
 		migrations = append(migrations, migName)
+		if IsEmbedded(migName) {
+			continue
+		}
 		bin, err := exec.LookPath(migName)
 		if err != nil {
 			continue
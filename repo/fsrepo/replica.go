@@ -0,0 +1,372 @@
+package fsrepo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/mount"
+	"github.com/ipfs/go-datastore/query"
+	dssync "github.com/ipfs/go-datastore/sync"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+	measure "github.com/ipfs/go-ds-measure"
+	filestore "github.com/ipfs/go-filestore"
+	lockfile "github.com/ipfs/go-fs-lock"
+	keystore "github.com/ipfs/go-ipfs-keystore"
+	options "github.com/ipfs/interface-go-ipfs-core/options"
+	config "github.com/ipfs/kubo/config"
+	repo "github.com/ipfs/kubo/repo"
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// ReplicasDir is the directory of the locks of the read replicas, relative to
+// the repo path.
+const ReplicasDir = "replicas"
+
+// ErrReadReplica is returned when modifying a repo opened as a read replica.
+var ErrReadReplica = errors.New("repo is opened as a read replica")
+
+// OpenReadReplica opens the FSRepo at path as a read replica. Replicas don't
+// take the repo lock: any number of them read the blocks of the repo while
+// the daemon holding the lock writes them, e.g. gateways serving a repo on
+// shared storage.
+//
+// A replica holds a lock in the replicas directory instead, so that the
+// writer knows it is open, see ReadReplicas. It reads the flatfs blocks in
+// place, verifying their hash, and keeps the rest of its state, such as its
+// pins and its MFS root, in memory, as the other datastores can't be opened
+// by two processes. The blocks it fetches from the network aren't stored. It
+// runs with its own ephemeral identity, and never writes the config, the
+// keystore or the api file of the repo.
+func OpenReadReplica(repoPath string) (repo.Repo, error) {
+	packageLock.Lock()
+	defer packageLock.Unlock()
+
+	r, err := newFSRepo(repoPath, "")
+	if err != nil {
+		return nil, err
+	}
+	r.readReplica = true
+
+	if err := checkInitialized(r.path); err != nil {
+		return nil, err
+	}
+	if err := checkRepoVersion(r.path); err != nil {
+		return nil, err
+	}
+
+	r.lockfile, err = lockReplica(r.path)
+	if err != nil {
+		return nil, err
+	}
+	keepLocked := false
+	defer func() {
+		if !keepLocked {
+			r.lockfile.Close()
+		}
+	}()
+
+	if err := r.openConfig(); err != nil {
+		return nil, err
+	}
+	conf, err := r.config.Clone()
+	if err != nil {
+		return nil, err
+	}
+	// the replicas can't share the peer ID of the writer
+	conf.Identity, err = config.CreateIdentity(io.Discard, []options.KeyGenerateOption{
+		options.Key.Type(options.Ed25519Key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	conf.Datastore.HashOnRead = true
+	r.config = conf
+
+	if err := r.openReplicaDatastore(); err != nil {
+		return nil, err
+	}
+
+	if err := r.openKeystore(); err != nil {
+		return nil, err
+	}
+	r.keystore = readOnlyKeystore{r.keystore}
+
+	if r.config.Experimental.FilestoreEnabled || r.config.Experimental.UrlstoreEnabled {
+		r.filemgr = filestore.NewFileManager(r.ds, filepath.Dir(r.path))
+		r.filemgr.AllowFiles = r.config.Experimental.FilestoreEnabled
+		r.filemgr.AllowUrls = r.config.Experimental.UrlstoreEnabled
+	}
+
+	keepLocked = true
+	return r, nil
+}
+
+// lockReplica takes a new lock in the replicas directory of the repo.
+func lockReplica(repoPath string) (io.Closer, error) {
+	dir := filepath.Join(repoPath, ReplicasDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return lockfile.Lock(dir, hex.EncodeToString(id)+".lock")
+}
+
+// ReadReplicas returns the number of read replicas of the repo at path which
+// are open, on this host or another one sharing the storage.
+func ReadReplicas(repoPath string) (int, error) {
+	dir := filepath.Join(repoPath, ReplicasDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var n int
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".lock") {
+			continue
+		}
+		// the locks of the replicas which crashed are left unlocked
+		locked, err := lockfile.Locked(dir, e.Name())
+		if err != nil {
+			return 0, err
+		}
+		if locked {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *FSRepo) openReplicaDatastore() error {
+	if _, err := r.datastoreConfig(); err != nil {
+		return err
+	}
+
+	d, shared, err := replicaDatastore(r.config.Datastore.Spec, r.path)
+	if err != nil {
+		return err
+	}
+	if !shared {
+		d.Close()
+		return fmt.Errorf("read replicas need the blocks in a flatfs datastore")
+	}
+	r.ds = measure.New("ipfs.fsrepo.datastore", d)
+	return nil
+}
+
+var blocksKey = ds.NewKey("/blocks")
+
+// replicaDatastore returns the datastore of a read replica for the spec. The
+// flatfs datastores are read in place, and the others are replaced with
+// memory datastores. shared reports whether the blocks are read in place.
+func replicaDatastore(spec map[string]interface{}, repoPath string) (d repo.Datastore, shared bool, err error) {
+	switch spec["type"] {
+	case "mount":
+		mounts, ok := spec["mounts"].([]interface{})
+		if !ok {
+			return nil, false, fmt.Errorf("'mounts' field is missing or not an array")
+		}
+		var (
+			ms []mount.Mount
+			// prefix of the mount of the blocks
+			blocksPrefix *ds.Key
+		)
+		for _, iface := range mounts {
+			cfg, ok := iface.(map[string]interface{})
+			if !ok {
+				return nil, false, fmt.Errorf("expected map for mountpoint")
+			}
+			prefix, ok := cfg["mountpoint"].(string)
+			if !ok {
+				return nil, false, fmt.Errorf("no 'mountpoint' on mount")
+			}
+			child, childShared, err := replicaDatastore(cfg, repoPath)
+			if err != nil {
+				for _, m := range ms {
+					m.Datastore.Close()
+				}
+				return nil, false, err
+			}
+
+			// the blocks are in the mount with the longest prefix
+			p := ds.NewKey(prefix)
+			if (p.Equal(blocksKey) || p.IsAncestorOf(blocksKey)) && (blocksPrefix == nil || blocksPrefix.IsAncestorOf(p)) {
+				blocksPrefix, shared = &p, childShared
+			}
+			ms = append(ms, mount.Mount{Prefix: p, Datastore: child})
+		}
+		return mount.New(ms), shared, nil
+	case "measure", "log":
+		childField, ok := spec["child"].(map[string]interface{})
+		if !ok {
+			return nil, false, fmt.Errorf("'child' field is missing or not a map")
+		}
+		child, shared, err := replicaDatastore(childField, repoPath)
+		if err != nil {
+			return nil, false, err
+		}
+		if prefix, ok := spec["prefix"].(string); ok {
+			child = measure.New(prefix, child)
+		}
+		return child, shared, nil
+	case "flatfs":
+		f, err := openFlatfsReplica(spec, repoPath)
+		if err != nil {
+			return nil, false, err
+		}
+		return f, true, nil
+	}
+	return dssync.MutexWrap(ds.NewMapDatastore()), false, nil
+}
+
+// flatfsReplica reads the blocks of a flatfs datastore written by another
+// process. flatfs.Open can't be used, as it removes the temporary files of
+// the writer. The blocks put by the replica aren't stored.
+type flatfsReplica struct {
+	path   string
+	getDir flatfs.ShardFunc
+}
+
+var _ repo.Datastore = (*flatfsReplica)(nil)
+
+func openFlatfsReplica(spec map[string]interface{}, repoPath string) (*flatfsReplica, error) {
+	p, ok := spec["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("'path' field is missing or not a string")
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(repoPath, p)
+	}
+	shard, err := flatfs.ReadShardFunc(p)
+	if err != nil {
+		return nil, err
+	}
+	return &flatfsReplica{path: p, getDir: shard.Func()}, nil
+}
+
+func (f *flatfsReplica) file(key ds.Key) string {
+	noslash := key.String()[1:]
+	return filepath.Join(f.path, f.getDir(noslash), noslash+".data")
+}
+
+func (f *flatfsReplica) Get(ctx context.Context, key ds.Key) ([]byte, error) {
+	data, err := os.ReadFile(f.file(key))
+	if os.IsNotExist(err) {
+		return nil, ds.ErrNotFound
+	}
+	return data, err
+}
+
+func (f *flatfsReplica) Has(ctx context.Context, key ds.Key) (bool, error) {
+	_, err := os.Stat(f.file(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (f *flatfsReplica) GetSize(ctx context.Context, key ds.Key) (int, error) {
+	fi, err := os.Stat(f.file(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return -1, ds.ErrNotFound
+		}
+		return -1, err
+	}
+	return int(fi.Size()), nil
+}
+
+func (f *flatfsReplica) Query(ctx context.Context, q query.Query) (query.Results, error) {
+	dirs, err := os.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []query.Entry
+	for _, d := range dirs {
+		if !d.IsDir() || strings.HasPrefix(d.Name(), ".") {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(f.path, d.Name()))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed by the writer
+				continue
+			}
+			return nil, err
+		}
+		for _, file := range files {
+			if !strings.HasSuffix(file.Name(), ".data") {
+				continue
+			}
+			e := query.Entry{Key: "/" + strings.TrimSuffix(file.Name(), ".data")}
+			switch {
+			case !q.KeysOnly:
+				e.Value, err = os.ReadFile(filepath.Join(f.path, d.Name(), file.Name()))
+				e.Size = len(e.Value)
+			case q.ReturnsSizes:
+				var fi os.FileInfo
+				if fi, err = file.Info(); err == nil {
+					e.Size = int(fi.Size())
+				}
+			}
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+	return query.NaiveQueryApply(q, query.ResultsWithEntries(q, entries)), nil
+}
+
+// Put discards the value: the writer is the only one storing blocks.
+func (f *flatfsReplica) Put(ctx context.Context, key ds.Key, value []byte) error {
+	return nil
+}
+
+func (f *flatfsReplica) Delete(ctx context.Context, key ds.Key) error {
+	return ErrReadReplica
+}
+
+func (f *flatfsReplica) Sync(ctx context.Context, prefix ds.Key) error {
+	return nil
+}
+
+func (f *flatfsReplica) Batch(ctx context.Context) (ds.Batch, error) {
+	return ds.NewBasicBatch(f), nil
+}
+
+func (f *flatfsReplica) Close() error {
+	return nil
+}
+
+// readOnlyKeystore is the keystore of a read replica, which can't add or
+// remove the keys of the repo.
+type readOnlyKeystore struct {
+	keystore.Keystore
+}
+
+func (readOnlyKeystore) Put(string, ci.PrivKey) error {
+	return ErrReadReplica
+}
+
+func (readOnlyKeystore) Delete(string) error {
+	return ErrReadReplica
+}
@@ -0,0 +1,224 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/routing/http/contentrouter"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/multiformats/go-multihash"
+)
+
+var _ routing.Routing = &Announcer{}
+var _ routinghelpers.ProvideManyRouter = &Announcer{}
+
+// announceTTL is the advisory TTL of the provider records announced, the one
+// of the provider records of the other HTTP routers.
+const announceTTL = 24 * time.Hour
+
+// ErrAnnouncerClosed is returned by the Announcer once closed.
+var ErrAnnouncerClosed = errors.New("the announcer of the provider records is closed")
+
+// AnnounceParams are the parameters of an Announcer.
+type AnnounceParams struct {
+	// Endpoints are the base URLs of the HTTP routers.
+	Endpoints []string
+	// Exclusive tells the provider records are only announced to the
+	// endpoints.
+	Exclusive bool
+	// BatchSize is the maximum number of CIDs announced per request.
+	BatchSize int
+	// BatchDelay is how long the CIDs provided one by one are collected
+	// before being announced.
+	BatchDelay time.Duration
+	// MaxRetries is the number of times a failed announcement is retried.
+	MaxRetries int
+}
+
+// Announcer announces provider records to HTTP routers: the CIDs provided
+// one by one are collected in batches, the CIDs provided at once are split in
+// batches, and the announcements failing are retried with an exponential
+// backoff. It only provides: it finds neither providers nor peers.
+type Announcer struct {
+	routinghelpers.Null
+
+	params        AnnounceParams
+	clients       map[string]contentrouter.Client
+	retryInterval time.Duration
+
+	pending chan cid.Cid
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewAnnouncer returns an Announcer to the endpoints of params, signing the
+// provider records with the identity of extraHTTP. It runs until closed.
+func NewAnnouncer(params AnnounceParams, extraHTTP *ExtraHTTPParams) (*Announcer, error) {
+	clients := make(map[string]contentrouter.Client, len(params.Endpoints))
+	for _, endpoint := range params.Endpoints {
+		cli, err := newHTTPClient(endpoint, extraHTTP)
+		if err != nil {
+			return nil, err
+		}
+		clients[endpoint] = cli
+	}
+	return newAnnouncer(params, clients, time.Second), nil
+}
+
+func newAnnouncer(params AnnounceParams, clients map[string]contentrouter.Client, retryInterval time.Duration) *Announcer {
+	if params.BatchSize < 1 {
+		params.BatchSize = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Announcer{
+		params:        params,
+		clients:       clients,
+		retryInterval: retryInterval,
+		pending:       make(chan cid.Cid, params.BatchSize),
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Exclusive tells whether the provider records are only announced to the
+// HTTP routers.
+func (a *Announcer) Exclusive() bool {
+	return a.params.Exclusive
+}
+
+// Provide queues c to be announced in the next batch.
+func (a *Announcer) Provide(ctx context.Context, c cid.Cid, announce bool) error {
+	if !announce {
+		return nil
+	}
+	if a.ctx.Err() != nil {
+		return ErrAnnouncerClosed
+	}
+	select {
+	case a.pending <- c:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.ctx.Done():
+		return ErrAnnouncerClosed
+	}
+}
+
+// ProvideMany announces the keys, in batches.
+func (a *Announcer) ProvideMany(ctx context.Context, keys []multihash.Multihash) error {
+	cids := make([]cid.Cid, 0, len(keys))
+	for _, k := range keys {
+		cids = append(cids, cid.NewCidV1(cid.Raw, k))
+	}
+
+	var errs error
+	for len(cids) > 0 {
+		n := a.params.BatchSize
+		if n > len(cids) {
+			n = len(cids)
+		}
+		if err := a.announce(ctx, cids[:n]); err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		cids = cids[n:]
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+	return errs
+}
+
+// Ready is part of the ProvideManyRouter interface.
+func (a *Announcer) Ready() bool {
+	return true
+}
+
+// Close stops the announcer. The CIDs queued and not yet announced are
+// dropped, until the next reprovide.
+func (a *Announcer) Close() error {
+	a.cancel()
+	<-a.done
+	return nil
+}
+
+func (a *Announcer) run() {
+	defer close(a.done)
+
+	var batch []cid.Cid
+	timer := time.NewTimer(a.params.BatchDelay)
+	timer.Stop()
+	defer timer.Stop()
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := a.announce(a.ctx, batch); err != nil {
+			log.Warnw("failed to announce provider records", "cids", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case c := <-a.pending:
+			if len(batch) == 0 {
+				timer.Reset(a.params.BatchDelay)
+			}
+			batch = append(batch, c)
+			if len(batch) >= a.params.BatchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// announce announces the batch to all the endpoints, in parallel.
+func (a *Announcer) announce(ctx context.Context, batch []cid.Cid) error {
+	var (
+		mu   sync.Mutex
+		errs error
+		wg   sync.WaitGroup
+	)
+	for endpoint, cli := range a.clients {
+		wg.Add(1)
+		go func(endpoint string, cli contentrouter.Client) {
+			defer wg.Done()
+			if err := a.announceTo(ctx, endpoint, cli, batch); err != nil {
+				mu.Lock()
+				errs = multierror.Append(errs, err)
+				mu.Unlock()
+			}
+		}(endpoint, cli)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (a *Announcer) announceTo(ctx context.Context, endpoint string, cli contentrouter.Client, batch []cid.Cid) error {
+	bo := backoff.NewExponentialBackOff()
+	bo.InitialInterval = a.retryInterval
+	b := backoff.WithContext(backoff.WithMaxRetries(bo, uint64(a.params.MaxRetries)), ctx)
+
+	return backoff.RetryNotify(func() error {
+		_, err := cli.ProvideBitswap(ctx, batch, announceTTL)
+		return err
+	}, b, func(err error, next time.Duration) {
+		log.Debugw("retrying the announcement of provider records", "endpoint", endpoint, "cids", len(batch), "next", next, "error", err)
+	})
+}
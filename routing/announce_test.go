@@ -0,0 +1,88 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-libipfs/routing/http/contentrouter"
+	"github.com/ipfs/go-libipfs/routing/http/types"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type announceClient struct {
+	mu       sync.Mutex
+	failures int // number of calls failing before the others succeed
+	batches  [][]cid.Cid
+	calls    int
+}
+
+func (c *announceClient) ProvideBitswap(ctx context.Context, keys []cid.Cid, ttl time.Duration) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.calls <= c.failures {
+		return 0, errors.New("unavailable")
+	}
+	c.batches = append(c.batches, keys)
+	return ttl, nil
+}
+
+func (c *announceClient) FindProviders(ctx context.Context, key cid.Cid) ([]types.ProviderResponse, error) {
+	return nil, nil
+}
+
+func (c *announceClient) announced() [][]cid.Cid {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([][]cid.Cid(nil), c.batches...)
+}
+
+func announceKeys(t *testing.T, n int) []multihash.Multihash {
+	keys := make([]multihash.Multihash, n)
+	for i := range keys {
+		mh, err := multihash.Sum([]byte{byte(i)}, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		keys[i] = mh
+	}
+	return keys
+}
+
+func TestAnnouncerProvideMany(t *testing.T) {
+	cli := &announceClient{failures: 2}
+	a := newAnnouncer(AnnounceParams{BatchSize: 3, MaxRetries: 2}, map[string]contentrouter.Client{"a": cli}, time.Millisecond)
+	defer a.Close()
+
+	require.NoError(t, a.ProvideMany(context.Background(), announceKeys(t, 7)))
+	batches := cli.announced()
+	require.Len(t, batches, 3)
+	require.Len(t, batches[0], 3)
+	require.Len(t, batches[2], 1)
+
+	// out of retries
+	cli.failures = cli.calls + 3
+	require.Error(t, a.ProvideMany(context.Background(), announceKeys(t, 1)))
+}
+
+func TestAnnouncerProvideBatches(t *testing.T) {
+	cli := &announceClient{}
+	a := newAnnouncer(AnnounceParams{BatchSize: 2, BatchDelay: 50 * time.Millisecond}, map[string]contentrouter.Client{"a": cli}, time.Millisecond)
+	defer a.Close()
+
+	ctx := context.Background()
+	for _, mh := range announceKeys(t, 3) {
+		require.NoError(t, a.Provide(ctx, cid.NewCidV1(cid.Raw, mh), true))
+	}
+	// a full batch is announced at once, the rest after the delay
+	require.Eventually(t, func() bool { return len(cli.announced()) == 2 }, time.Second, 10*time.Millisecond)
+	batches := cli.announced()
+	require.Len(t, batches[0], 2)
+	require.Len(t, batches[1], 1)
+
+	require.NoError(t, a.Close())
+	require.ErrorIs(t, a.Provide(ctx, cid.NewCidV1(cid.Raw, announceKeys(t, 1)[0]), true), ErrAnnouncerClosed)
+}
@@ -182,6 +182,27 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 
 	params.FillDefaults()
 
+	cli, err := newHTTPClient(params.Endpoint, extraHTTP)
+	if err != nil {
+		return nil, err
+	}
+
+	cr := contentrouter.NewContentRoutingClient(
+		cli,
+		contentrouter.WithMaxProvideBatchSize(params.MaxProvideBatchSize),
+		contentrouter.WithMaxProvideConcurrency(params.MaxProvideConcurrency),
+	)
+
+	return &httpRoutingWrapper{
+		ContentRouting:    cr,
+		ProvideManyRouter: cr,
+		endpoint:          params.Endpoint,
+	}, nil
+}
+
+// newHTTPClient returns the client of the HTTP router at endpoint, which
+// signs the provider records with the identity of extraHTTP.
+func newHTTPClient(endpoint string, extraHTTP *ExtraHTTPParams) (contentrouter.Client, error) {
 	// Increase per-host connection pool since we are making lots of concurrent requests.
 	transport := http.DefaultTransport.(*http.Transport).Clone()
 	transport.MaxIdleConns = 500
@@ -205,7 +226,7 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 	}
 
 	cli, err := drclient.New(
-		params.Endpoint,
+		endpoint,
 		drclient.WithHTTPClient(delegateHTTPClient),
 		drclient.WithIdentity(key),
 		drclient.WithProviderInfo(addrInfo.ID, addrInfo.Addrs),
@@ -214,17 +235,7 @@ func httpRoutingFromConfig(conf config.Router, extraHTTP *ExtraHTTPParams) (rout
 	if err != nil {
 		return nil, err
 	}
-
-	cr := contentrouter.NewContentRoutingClient(
-		cli,
-		contentrouter.WithMaxProvideBatchSize(params.MaxProvideBatchSize),
-		contentrouter.WithMaxProvideConcurrency(params.MaxProvideConcurrency),
-	)
-
-	return &httpRoutingWrapper{
-		ContentRouting:    cr,
-		ProvideManyRouter: cr,
-	}, nil
+	return cli, nil
 }
 
 func reframeRoutingFromConfig(conf config.Router, extraReframe *ExtraHTTPParams) (routing.Routing, error) {
@@ -285,6 +296,7 @@ func reframeRoutingFromConfig(conf config.Router, extraReframe *ExtraHTTPParams)
 	return &reframeRoutingWrapper{
 		Client:               c,
 		ContentRoutingClient: crc,
+		endpoint:             params.Endpoint,
 	}, nil
 }
 
@@ -0,0 +1,93 @@
+package routing
+
+import (
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/dual"
+	"github.com/libp2p/go-libp2p-kad-dht/fullrt"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/routing"
+)
+
+// IsDHT returns whether r is a DHT router.
+func IsDHT(r routing.Routing) bool {
+	switch r.(type) {
+	case *dht.IpfsDHT, *dual.DHT, *fullrt.FullRT:
+		return true
+	}
+	return false
+}
+
+// IsHTTP returns whether r is a delegated HTTP or Reframe router.
+func IsHTTP(r routing.Routing) bool {
+	switch r.(type) {
+	case *httpRoutingWrapper, *reframeRoutingWrapper:
+		return true
+	}
+	return false
+}
+
+// Endpoint returns the endpoint of a delegated HTTP or Reframe router, empty
+// for other routers.
+func Endpoint(r routing.Routing) string {
+	switch r := r.(type) {
+	case *httpRoutingWrapper:
+		return r.endpoint
+	case *reframeRoutingWrapper:
+		return r.endpoint
+	}
+	return ""
+}
+
+// Select returns the routers composed in r for which keep returns true, in
+// parallel, or nil if there are none. The kept routers must be comparable,
+// e.g. pointers, as a router can be composed several times.
+func Select(r routing.Routing, keep func(routing.Routing) bool) routing.Routing {
+	selected := Routers(r, keep)
+	switch len(selected) {
+	case 0:
+		return nil
+	case 1:
+		return selected[0]
+	}
+	return routinghelpers.Parallel{Routers: selected}
+}
+
+// Routers returns the routers composed in r for which keep returns true,
+// once each.
+func Routers(r routing.Routing, keep func(routing.Routing) bool) []routing.Routing {
+	var selected []routing.Routing
+	seen := make(map[routing.Routing]bool)
+	var walk func(r routing.Routing)
+	walk = func(r routing.Routing) {
+		if r == nil {
+			return
+		}
+		if keep(r) {
+			if !seen[r] {
+				seen[r] = true
+				selected = append(selected, r)
+			}
+			return
+		}
+		switch r := r.(type) {
+		case routinghelpers.ComposableRouter:
+			for _, cr := range r.Routers() {
+				walk(cr)
+			}
+		case *Composer:
+			for _, cr := range []routing.Routing{r.GetValueRouter, r.PutValueRouter, r.FindPeersRouter, r.FindProvidersRouter, r.ProvideRouter} {
+				walk(cr)
+			}
+		case routinghelpers.Tiered:
+			for _, cr := range r.Routers {
+				walk(cr)
+			}
+		case routinghelpers.Parallel:
+			for _, cr := range r.Routers {
+				walk(cr)
+			}
+		}
+	}
+	walk(r)
+	return selected
+}
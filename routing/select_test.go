@@ -0,0 +1,62 @@
+package routing
+
+import (
+	"testing"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p-kad-dht/dual"
+	routinghelpers "github.com/libp2p/go-libp2p-routing-helpers"
+	"github.com/libp2p/go-libp2p/core/routing"
+	"github.com/stretchr/testify/require"
+)
+
+// selectRouter is a router Select can keep.
+type selectRouter struct {
+	routinghelpers.Null
+	kept bool
+}
+
+func isKept(r routing.Routing) bool {
+	sr, ok := r.(*selectRouter)
+	return ok && sr.kept
+}
+
+func TestSelect(t *testing.T) {
+	a := &selectRouter{kept: true}
+	b := &selectRouter{kept: true}
+	other := &selectRouter{}
+
+	require.Nil(t, Select(nil, isKept))
+	require.Nil(t, Select(other, isKept))
+	require.Equal(t, a, Select(a, isKept))
+
+	// a router composed several times is kept once
+	composed := &Composer{
+		GetValueRouter:      a,
+		PutValueRouter:      a,
+		FindPeersRouter:     other,
+		FindProvidersRouter: routinghelpers.Parallel{Routers: []routing.Routing{other, b}},
+		ProvideRouter:       routinghelpers.Tiered{Routers: []routing.Routing{b, a}},
+	}
+	require.Equal(t, routinghelpers.Parallel{Routers: []routing.Routing{a, b}}, Select(composed, isKept))
+
+	nested := routinghelpers.NewComposableParallel([]*routinghelpers.ParallelRouter{
+		{Router: other},
+		{Router: routinghelpers.Tiered{Routers: []routing.Routing{other, b}}},
+	})
+	require.Equal(t, b, Select(nested, isKept))
+	require.Nil(t, Select(routinghelpers.Tiered{Routers: []routing.Routing{other}}, isKept))
+}
+
+func TestIsDHTIsHTTP(t *testing.T) {
+	for _, r := range []routing.Routing{&dht.IpfsDHT{}, &dual.DHT{}} {
+		require.True(t, IsDHT(r))
+		require.False(t, IsHTTP(r))
+	}
+	for _, r := range []routing.Routing{&httpRoutingWrapper{}, &reframeRoutingWrapper{}} {
+		require.True(t, IsHTTP(r))
+		require.False(t, IsDHT(r))
+	}
+	require.False(t, IsDHT(routinghelpers.Null{}))
+	require.False(t, IsHTTP(routinghelpers.Null{}))
+}
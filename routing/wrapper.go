@@ -18,6 +18,7 @@ var _ routinghelpers.ProvideManyRouter = &reframeRoutingWrapper{}
 type reframeRoutingWrapper struct {
 	*drc.Client
 	*drc.ContentRoutingClient
+	endpoint string
 }
 
 func (c *reframeRoutingWrapper) Provide(ctx context.Context, id cid.Cid, announce bool) error {
@@ -49,6 +50,7 @@ var _ routinghelpers.ProvideManyRouter = &httpRoutingWrapper{}
 type httpRoutingWrapper struct {
 	routing.ContentRouting
 	routinghelpers.ProvideManyRouter
+	endpoint string
 }
 
 func (c *httpRoutingWrapper) Bootstrap(ctx context.Context) error {
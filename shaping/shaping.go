@@ -0,0 +1,165 @@
+// Package shaping injects artificial latency and loss into the libp2p streams
+// of a running node, so that its resilience can be tested without tc/netem
+// privileges on the host.
+package shaping
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// All is the peer of the rule shaping the streams with the peers without
+// their own rule.
+const All peer.ID = ""
+
+// minRetransmitDelay is the minimum delay of a lost write, like the minimum
+// retransmission timeout of TCP.
+const minRetransmitDelay = 200 * time.Millisecond
+
+// Rule is the shaping of the streams with a peer. It applies to the data
+// sent to the peer.
+type Rule struct {
+	// Latency is added to each write.
+	Latency time.Duration
+	// Jitter is a random latency, up to Jitter, added to each write.
+	Jitter time.Duration
+	// Loss is the probability, between 0 and 1, that a write is lost. As
+	// streams are reliable, lost writes are delayed as if retransmitted.
+	Loss float64
+}
+
+// Shaper holds the shaping rules of a node, which can be changed while the
+// node runs.
+type Shaper struct {
+	mu    sync.Mutex
+	rules map[peer.ID]Rule
+	rand  *rand.Rand
+}
+
+// New returns a Shaper without rules.
+func New() *Shaper {
+	return &Shaper{
+		rules: make(map[peer.ID]Rule),
+		rand:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Set shapes the streams with p, or with all the peers without their own
+// rule if p is All.
+func (s *Shaper) Set(p peer.ID, r Rule) error {
+	if r.Latency < 0 || r.Jitter < 0 {
+		return errors.New("latency and jitter must be positive")
+	}
+	if r.Loss < 0 || r.Loss > 1 {
+		return errors.New("loss must be between 0 and 1")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[p] = r
+	return nil
+}
+
+// Remove removes the rule of p, and returns whether there was one.
+func (s *Shaper) Remove(p peer.ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.rules[p]
+	delete(s.rules, p)
+	return ok
+}
+
+// Clear removes all the rules.
+func (s *Shaper) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = make(map[peer.ID]Rule)
+}
+
+// Rules returns the rules, by peer.
+func (s *Shaper) Rules() map[peer.ID]Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make(map[peer.ID]Rule, len(s.rules))
+	for p, r := range s.rules {
+		rules[p] = r
+	}
+	return rules
+}
+
+// delay returns the delay of the next write to p.
+func (s *Shaper) delay(p peer.ID) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.rules[p]
+	if !ok {
+		if r, ok = s.rules[All]; !ok {
+			return 0
+		}
+	}
+	d := r.Latency
+	if r.Jitter > 0 {
+		d += time.Duration(s.rand.Int63n(int64(r.Jitter)))
+	}
+	if r.Loss > 0 && s.rand.Float64() < r.Loss {
+		retransmit := 3 * r.Latency
+		if retransmit < minRetransmitDelay {
+			retransmit = minRetransmitDelay
+		}
+		d += retransmit
+	}
+	return d
+}
+
+// Host shapes the streams opened and accepted by the host it wraps.
+type Host struct {
+	host.Host
+	shaper *Shaper
+}
+
+// Wrap returns h shaping its streams with s.
+func Wrap(h host.Host, s *Shaper) *Host {
+	return &Host{Host: h, shaper: s}
+}
+
+func (h *Host) NewStream(ctx context.Context, p peer.ID, pids ...protocol.ID) (network.Stream, error) {
+	s, err := h.Host.NewStream(ctx, p, pids...)
+	if err != nil {
+		return nil, err
+	}
+	return &stream{Stream: s, shaper: h.shaper}, nil
+}
+
+func (h *Host) SetStreamHandler(pid protocol.ID, handler network.StreamHandler) {
+	h.Host.SetStreamHandler(pid, h.wrapHandler(handler))
+}
+
+func (h *Host) SetStreamHandlerMatch(pid protocol.ID, match func(string) bool, handler network.StreamHandler) {
+	h.Host.SetStreamHandlerMatch(pid, match, h.wrapHandler(handler))
+}
+
+func (h *Host) wrapHandler(handler network.StreamHandler) network.StreamHandler {
+	return func(s network.Stream) {
+		handler(&stream{Stream: s, shaper: h.shaper})
+	}
+}
+
+// stream delays the writes to the remote peer by the rule of the peer.
+type stream struct {
+	network.Stream
+	shaper *Shaper
+}
+
+func (s *stream) Write(b []byte) (int, error) {
+	if d := s.shaper.delay(s.Conn().RemotePeer()); d > 0 {
+		time.Sleep(d)
+	}
+	return s.Stream.Write(b)
+}
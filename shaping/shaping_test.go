@@ -0,0 +1,54 @@
+package shaping
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestShaperDelay(t *testing.T) {
+	s := New()
+	p1, p2 := peer.ID("peer1"), peer.ID("peer2")
+	if d := s.delay(p1); d != 0 {
+		t.Fatalf("unexpected delay %s without rules", d)
+	}
+
+	if err := s.Set(All, Rule{Latency: 10 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(p1, Rule{Latency: 50 * time.Millisecond, Jitter: 5 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if d := s.delay(p1); d < 50*time.Millisecond || d >= 55*time.Millisecond {
+			t.Fatalf("unexpected delay %s of the peer rule", d)
+		}
+	}
+	if d := s.delay(p2); d != 10*time.Millisecond {
+		t.Fatalf("unexpected delay %s of the default rule", d)
+	}
+
+	// every write is lost
+	if err := s.Set(p2, Rule{Latency: 100 * time.Millisecond, Loss: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if d := s.delay(p2); d != 400*time.Millisecond {
+		t.Fatalf("unexpected delay %s of a lost write", d)
+	}
+
+	if !s.Remove(p1) || s.Remove(p1) {
+		t.Fatal("the rule should be removed once")
+	}
+	if len(s.Rules()) != 2 {
+		t.Fatalf("unexpected rules %v", s.Rules())
+	}
+	s.Clear()
+	if d := s.delay(p2); d != 0 {
+		t.Fatalf("unexpected delay %s once cleared", d)
+	}
+
+	if err := s.Set(p1, Rule{Loss: 2}); err == nil {
+		t.Fatal("expected an error for an invalid loss")
+	}
+}
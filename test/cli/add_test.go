@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddProgressEvents(t *testing.T) {
+	t.Parallel()
+	h := harness.NewT(t)
+	node := h.NewNode().Init()
+	h.WriteFile("photos/a.jpg", strings.Repeat("a", 300000))
+	h.WriteFile("photos/b.jpg", strings.Repeat("b", 100000))
+	const total = 400000
+
+	res := node.IPFS("add", "-r", "--progress-events", filepath.Join(h.Dir, "photos"))
+
+	type addEvent struct {
+		Name, Hash, Event            string
+		Bytes, TotalBytes, TotalSize int64
+	}
+	var events []addEvent
+	for _, line := range res.Stdout.Lines() {
+		var ev addEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &ev), line)
+		events = append(events, ev)
+	}
+	require.NotEmpty(t, events)
+
+	added := make(map[string]string)
+	var lastTotal int64
+	for _, ev := range events {
+		assert.Equal(t, int64(total), ev.TotalSize)
+		assert.GreaterOrEqual(t, ev.TotalBytes, lastTotal, "the bytes hashed over all the files only grow")
+		lastTotal = ev.TotalBytes
+		switch ev.Event {
+		case "progress":
+			assert.Empty(t, ev.Hash)
+		case "added":
+			assert.NotEmpty(t, ev.Hash)
+			added[ev.Name] = ev.Hash
+		default:
+			t.Fatalf("unexpected event %q", ev.Event)
+		}
+	}
+	assert.Equal(t, int64(total), lastTotal)
+	assert.Len(t, added, 3)
+	assert.Equal(t, "added", events[len(events)-1].Event)
+
+	// the CIDs are the ones of a plain add
+	res = node.IPFS("add", "-r", "-Q", filepath.Join(h.Dir, "photos"))
+	assert.Equal(t, res.Stdout.Trimmed(), added["photos"])
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/kubo/config"
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigProfileExport(t *testing.T) {
+	t.Parallel()
+	node := harness.NewT(t).NewNode().Init()
+	node.UpdateConfig(func(cfg *config.Config) {
+		cfg.Gateway.NoFetch = true
+		cfg.API.Authorizations = map[string]*config.RPCAuthScope{"user": {AuthSecret: "bearer:rpc-secret", AllowedPaths: []string{"/api/v0"}}}
+		cfg.Webhooks.Endpoints = []config.WebhookEndpoint{{URL: "https://hooks.example.com", Headers: map[string]string{"Authorization": "webhook-secret"}}}
+	})
+
+	profile := node.IPFS("config", "profile", "export", "fleet").Stdout.String()
+	assert.NotContains(t, profile, "rpc-secret")
+	assert.NotContains(t, profile, "webhook-secret")
+	assert.Contains(t, profile, "https://hooks.example.com")
+	path := filepath.Join(t.TempDir(), "fleet.json")
+	require.NoError(t, os.WriteFile(path, []byte(profile), 0o600))
+
+	other := harness.NewT(t).NewNode().Init().StartDaemon("--offline")
+	// the daemon doesn't read profile files
+	res := other.RunIPFS("config", "profile", "apply", path)
+	assert.Equal(t, 1, res.Cmd.ProcessState.ExitCode())
+	assert.Contains(t, res.Stderr.String(), "stop the daemon to apply them")
+	assert.Equal(t, "false", other.IPFS("config", "Gateway.NoFetch").Stdout.Trimmed())
+
+	other.StopDaemon()
+	other.IPFS("config", "profile", "apply", path)
+	assert.Equal(t, "true", other.IPFS("config", "Gateway.NoFetch").Stdout.Trimmed())
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIDProbe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("probes the protocols and transports of a remote peer", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
+		node1, node2 := nodes[0], nodes[1]
+
+		res := node1.IPFS("id", "--probe", "--enc=json", node2.PeerID().String())
+		var out struct {
+			Capabilities struct {
+				Protocols []struct {
+					Protocol, Category   string
+					Announced, Supported bool
+				}
+				Transports []struct {
+					Transport string
+					Addresses int
+					Connected bool
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+
+		protocols := make(map[string]bool)
+		for _, p := range out.Capabilities.Protocols {
+			protocols[p.Protocol] = p.Supported
+			if p.Protocol == "/ipfs/bitswap/1.2.0" {
+				assert.Equal(t, "bitswap", p.Category)
+				assert.True(t, p.Announced)
+			}
+		}
+		assert.True(t, protocols["/ipfs/bitswap/1.2.0"])
+		assert.True(t, protocols["/ipfs/id/1.0.0"])
+		assert.True(t, protocols["/ipfs/ping/1.0.0"])
+		assert.False(t, protocols["/ipfs/graphsync/2.0.0"])
+
+		var connected bool
+		for _, tr := range out.Capabilities.Transports {
+			assert.Greater(t, tr.Addresses, 0)
+			connected = connected || tr.Connected
+		}
+		assert.True(t, connected)
+
+		res = node1.IPFS("id", "--probe", "-f=<capabilities>", node2.PeerID().String())
+		assert.Contains(t, res.Stdout.Lines(), "/ipfs/bitswap/1.2.0\tbitswap\tannounced=true\tsupported=true")
+	})
+
+	t.Run("refuses to probe itself", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon()
+
+		res := node.RunIPFS("id", "--probe")
+		assert.Equal(t, 1, res.Cmd.ProcessState.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "--probe needs a remote peer")
+	})
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ipfs/kubo/test/cli/harness"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyRotateIPNSKey(t *testing.T) {
+	t.Parallel()
+
+	t.Run("forwards the old name to the new key", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon("--offline")
+		cid := node.IPFSAddStr("rotated content")
+		node.IPFS("key", "gen", "mykey")
+		node.IPFS("name", "publish", "--allow-offline", "--key=mykey", "/ipfs/"+cid)
+
+		res := node.IPFS("key", "rotate", "--allow-offline", "--grace=1h", "--enc=json", "mykey")
+		var out struct {
+			Name, Old, OldId, NewId string
+			Until                   time.Time
+		}
+		require.NoError(t, json.Unmarshal(res.Stdout.Bytes(), &out))
+		assert.Equal(t, "mykey", out.Name)
+		assert.Equal(t, "mykey-retired", out.Old)
+		assert.NotEqual(t, out.OldId, out.NewId)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), out.Until, time.Minute)
+
+		keys := node.IPFS("key", "list").Stdout.Lines()
+		assert.Contains(t, keys, "mykey")
+		assert.Contains(t, keys, "mykey-retired")
+
+		// the old name forwards to the new one, which has the current value
+		res = node.IPFS("name", "resolve", "--recursive=false", "/ipns/"+out.OldId)
+		assert.Equal(t, "/ipns/"+out.NewId, res.Stdout.Trimmed())
+		res = node.IPFS("name", "resolve", "/ipns/"+out.OldId)
+		assert.Equal(t, "/ipfs/"+cid, res.Stdout.Trimmed())
+		res = node.IPFS("name", "resolve", "/ipns/"+out.NewId)
+		assert.Equal(t, "/ipfs/"+cid, res.Stdout.Trimmed())
+	})
+
+	t.Run("refuses invalid grace periods and the identity", func(t *testing.T) {
+		t.Parallel()
+		node := harness.NewT(t).NewNode().Init().StartDaemon("--offline")
+		node.IPFS("key", "gen", "mykey")
+
+		res := node.RunIPFS("key", "rotate", "--allow-offline", "--grace=0s", "mykey")
+		assert.Equal(t, 1, res.Cmd.ProcessState.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "the grace period must be positive")
+
+		res = node.RunIPFS("key", "rotate", "self")
+		assert.Equal(t, 1, res.Cmd.ProcessState.ExitCode())
+		assert.Contains(t, res.Stderr.String(), "with the daemon stopped")
+
+		// the key wasn't rotated
+		assert.NotContains(t, node.IPFS("key", "list").Stdout.Lines(), "mykey-retired")
+	})
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/ipfs/kubo/test/cli/harness"
@@ -21,6 +22,26 @@ func TestPing(t *testing.T) {
 		node2.IPFS("ping", "-n", "2", "--", node1.PeerID().String())
 	})
 
+	t.Run("per path", func(t *testing.T) {
+		t.Parallel()
+		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
+		node1 := nodes[0]
+		node2 := nodes[1]
+
+		res := node1.IPFS("ping", "--per-path", "-n", "2", "--", node2.PeerID().String())
+		var pongs, paths int
+		for _, line := range res.Stdout.Lines() {
+			if strings.HasPrefix(line, "Pong received over /") {
+				pongs++
+			}
+			if strings.Contains(line, ": 2/2 pongs received, 0% loss, time min/avg/max=") {
+				paths++
+			}
+		}
+		assert.GreaterOrEqual(t, pongs, 2)
+		assert.GreaterOrEqual(t, paths, 1)
+	})
+
 	t.Run("ping unreachable peer", func(t *testing.T) {
 		t.Parallel()
 		nodes := harness.NewT(t).NewNodes(2).Init().StartDaemons().Connect()
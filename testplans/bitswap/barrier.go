@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+)
+
+// barrierDiagnosticsTimeout bounds the collection of the states of the
+// instances after a barrier timed out.
+const barrierDiagnosticsTimeout = 5 * time.Second
+
+// barrierEntry is a state entered by an instance.
+type barrierEntry struct {
+	Seq   int64
+	Group string
+	State string
+}
+
+// barrierTopic records the states entered by the instances, so that an
+// instance timing out on a barrier can report the ones missing.
+var barrierTopic = sync.NewTopic("barrier-entries", &barrierEntry{})
+
+// signalEntry signals that the instance entered the state.
+func signalEntry(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, state sync.State) error {
+	client := initCtx.SyncClient
+	entry := &barrierEntry{Seq: initCtx.GlobalSeq, Group: runenv.TestGroupID, State: string(state)}
+	if _, err := client.Publish(ctx, barrierTopic, entry); err != nil {
+		return err
+	}
+	_, err := client.SignalEntry(ctx, state)
+	return err
+}
+
+// signalAndWait signals that the instance entered the state and waits for
+// the target number of instances to enter it, as with waitBarrier.
+func signalAndWait(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, state sync.State, target int) error {
+	if err := signalEntry(ctx, runenv, initCtx, state); err != nil {
+		return err
+	}
+	return waitBarrier(ctx, runenv, initCtx, state, target)
+}
+
+// waitBarrier waits for the target number of instances to enter the state,
+// for at most barrier_timeout seconds, 0 waiting forever. On a timeout, it
+// records the instances which didn't enter the state, with the last state
+// they entered, instead of hanging on an instance which died.
+func waitBarrier(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, state sync.State, target int) error {
+	wctx := ctx
+	timeout := time.Duration(runenv.IntParam("barrier_timeout")) * time.Second
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		wctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	b, err := initCtx.SyncClient.Barrier(wctx, state, target)
+	if err != nil {
+		return err
+	}
+	err = <-b.C
+	if err == nil || ctx.Err() != nil || !errors.Is(wctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+
+	missing, err := missingInstances(ctx, runenv, initCtx, state)
+	if err != nil {
+		runenv.RecordMessage("could not collect the states of the instances: %s", err)
+	}
+	for _, m := range missing {
+		runenv.RecordMessage("missing from %q: instance %s", state, m)
+	}
+	return fmt.Errorf("timed out after %s waiting for %d instances to enter %q, %d instances didn't", timeout, target, state, len(missing))
+}
+
+// missingInstances returns a description of the instances which didn't enter
+// the state, by their sequence number, with their group and last state.
+func missingInstances(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, state sync.State) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, barrierDiagnosticsTimeout)
+	defer cancel()
+	entries := make(chan *barrierEntry)
+	sub, err := initCtx.SyncClient.Subscribe(ctx, barrierTopic, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	// the topic is replayed from the start, and never closed: read it until
+	// the diagnostics time out
+	last := make(map[int64]*barrierEntry)
+	entered := make(map[int64]bool)
+collect:
+	for {
+		select {
+		case e := <-entries:
+			last[e.Seq] = e
+			if e.State == string(state) {
+				entered[e.Seq] = true
+			}
+		case <-sub.Done():
+			break collect
+		}
+	}
+
+	var missing []string
+	for seq := int64(1); seq <= int64(runenv.TestInstanceCount); seq++ {
+		if entered[seq] {
+			continue
+		}
+		e, ok := last[seq]
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%d entered no state", seq))
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%d of group %s last entered %q", seq, e.Group, e.State))
+	}
+	return missing, nil
+}
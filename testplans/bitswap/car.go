@@ -0,0 +1,544 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testground/sdk-go/network"
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	block "github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multihash"
+)
+
+// carDAG is the DAG of a provider of the car testcase: a DAG-CBOR root
+// listing raw leaves.
+type carDAG struct {
+	AddrInfo peer.AddrInfo
+	Root     string
+	Leaves   int
+}
+
+var (
+	carDAGTopic      = sync.NewTopic("car-dag", &carDAG{})
+	carGatewayTopic  = sync.NewTopic("car-gateway", new(string))
+	carShapedState   = sync.State("car-network-shaped")
+	carGatewayState  = sync.State("car-gateway-ready")
+	carDoneState     = sync.State("car-done")
+	errCARIncomplete = errors.New("incomplete CAR")
+)
+
+const (
+	carGatewayPort = 8080
+	carContentType = "application/vnd.ipld.car"
+	// carMaxSectionSize bounds the sections read, so that a corrupted
+	// length fails instead of allocating.
+	carMaxSectionSize = 4 << 20
+)
+
+// runCAR fetches CARs from a gateway running in the plan over a lossy
+// network. The gateways fetch the DAGs of the providers with bitswap and
+// stream them as CARs, and the requestors check that each CAR is complete
+// and that its blocks match their CIDs.
+func runCAR(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	ctx := context.Background()
+
+	h, bstore, ex, err := newNode(ctx, runenv, initCtx)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	initCtx.NetClient.MustConfigureNetwork(ctx, &network.Config{
+		Network: "default",
+		Enable:  true,
+		Default: network.LinkShape{
+			Latency:   time.Duration(runenv.IntParam("latency_ms")) * time.Millisecond,
+			Jitter:    time.Duration(runenv.IntParam("jitter_ms")) * time.Millisecond,
+			Bandwidth: runenv.SizeParam("bandwidth") * 8, // in bits per second
+			Loss:      float32(runenv.FloatParam("loss")),
+		},
+		CallbackState:  carShapedState,
+		CallbackTarget: runenv.TestInstanceCount,
+		RoutingPolicy:  network.AllowAll,
+	})
+
+	stopWatching := watchResources(ctx, runenv)
+	switch runenv.TestGroupID {
+	case "providers":
+		runenv.RecordMessage("running provider")
+		err = runCARProvider(ctx, runenv, initCtx, h, bstore)
+	case "gateways":
+		runenv.RecordMessage("running gateway")
+		err = runCARGateway(ctx, runenv, initCtx, h, ex)
+	case "requestors":
+		runenv.RecordMessage("running requestor")
+		err = runCARRequestor(ctx, runenv, initCtx)
+	default:
+		err = errors.New("unknown test group id")
+	}
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	if werr := signalAndWait(ctx, runenv, initCtx, carDoneState, runenv.TestInstanceCount); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func runCARProvider(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, bstore blockstore.Blockstore) error {
+	size := runenv.SizeParam("size")
+	leaves := runenv.IntParam("count")
+
+	var links []cid.Cid
+	for i := 0; i < leaves; i++ {
+		buf := make([]byte, size)
+		rand.Read(buf)
+		c, err := sha256Cid(cid.Raw, buf)
+		if err != nil {
+			return err
+		}
+		blk, err := block.NewBlockWithCid(buf, c)
+		if err != nil {
+			return err
+		}
+		if err := bstore.Put(ctx, blk); err != nil {
+			return err
+		}
+		links = append(links, c)
+	}
+	data := encodeCBORLinks(links)
+	c, err := sha256Cid(cid.DagCBOR, data)
+	if err != nil {
+		return err
+	}
+	root, err := block.NewBlockWithCid(data, c)
+	if err != nil {
+		return err
+	}
+	if err := bstore.Put(ctx, root); err != nil {
+		return err
+	}
+
+	initCtx.SyncClient.MustPublish(ctx, carDAGTopic, &carDAG{
+		AddrInfo: peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()},
+		Root:     root.Cid().String(),
+		Leaves:   leaves,
+	})
+	runenv.RecordMessage("providing DAG %s of %d leaves", root.Cid(), leaves)
+	return nil
+}
+
+func runCARGateway(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, ex exchange.Interface) error {
+	client := initCtx.SyncClient
+
+	dags := make(chan *carDAG)
+	sub, err := client.Subscribe(ctx, carDAGTopic, dags)
+	if err != nil {
+		return err
+	}
+	providers := runenv.IntParam("providers")
+	for i := 0; i < providers; i++ {
+		var d *carDAG
+		select {
+		case d = <-dags:
+		case err := <-sub.Done():
+			return err
+		}
+		if err := h.Connect(ctx, d.AddrInfo); err != nil {
+			return fmt.Errorf("could not connect to provider: %w", err)
+		}
+	}
+
+	addr := fmt.Sprintf("%s:%d", initCtx.NetClient.MustGetDataNetworkIP(), carGatewayPort)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: carGatewayHandler(runenv, ex)}
+	go srv.Serve(l) //nolint:errcheck
+	defer srv.Close()
+
+	url := "http://" + addr
+	client.MustPublish(ctx, carGatewayTopic, &url)
+	runenv.RecordMessage("serving CARs on %s", url)
+	if err := signalEntry(ctx, runenv, initCtx, carGatewayState); err != nil {
+		return err
+	}
+
+	// serve until the requestors are done
+	if err := signalAndWait(ctx, runenv, initCtx, carDoneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	return nil
+}
+
+// carGatewayHandler serves /ipfs/<cid>?format=car, streaming the blocks as
+// they are fetched. As the status is sent before the blocks, a failure
+// aborts the response, which the client must notice.
+func carGatewayHandler(runenv *runtime.RunEnv, ex exchange.Interface) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") != "car" {
+			http.Error(w, "only ?format=car is supported", http.StatusBadRequest)
+			return
+		}
+		root, err := cid.Decode(strings.TrimPrefix(r.URL.Path, "/ipfs/"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rootBlk, err := ex.GetBlock(r.Context(), root)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		links, err := decodeCBORLinks(rootBlk.RawData())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", carContentType)
+		w.WriteHeader(http.StatusOK)
+		bw := bufio.NewWriter(w)
+		writeSection(bw, encodeCARHeader(root))
+		writeSection(bw, append(root.Bytes(), rootBlk.RawData()...))
+		for _, c := range links {
+			blk, err := ex.GetBlock(r.Context(), c)
+			if err != nil {
+				runenv.RecordMessage("aborting the CAR of %s: %s", root, err)
+				panic(http.ErrAbortHandler)
+			}
+			writeSection(bw, append(c.Bytes(), blk.RawData()...))
+		}
+		if err := bw.Flush(); err != nil {
+			runenv.RecordMessage("could not send the CAR of %s: %s", root, err)
+		}
+	})
+}
+
+func runCARRequestor(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	client := initCtx.SyncClient
+
+	urls := make(chan *string)
+	urlSub, err := client.Subscribe(ctx, carGatewayTopic, urls)
+	if err != nil {
+		return err
+	}
+	var url *string
+	select {
+	case url = <-urls:
+	case err := <-urlSub.Done():
+		return err
+	}
+	dags := make(chan *carDAG)
+	dagSub, err := client.Subscribe(ctx, carDAGTopic, dags)
+	if err != nil {
+		return err
+	}
+	var roots []*carDAG
+	for i := 0; i < runenv.IntParam("providers"); i++ {
+		select {
+		case d := <-dags:
+			roots = append(roots, d)
+		case err := <-dagSub.Done():
+			return err
+		}
+	}
+	if err := waitBarrier(ctx, runenv, initCtx, carGatewayState, 1); err != nil {
+		return err
+	}
+
+	var failed []error
+	for _, d := range roots {
+		begin := time.Now()
+		n, err := fetchCAR(ctx, *url, d)
+		if err != nil {
+			runenv.RecordMessage("invalid CAR of %s: %s", d.Root, err)
+			failed = append(failed, fmt.Errorf("%s: %w", d.Root, err))
+			continue
+		}
+		duration := time.Since(begin)
+		runenv.R().RecordPoint("car_fetch_ms", float64(duration.Milliseconds()))
+		runenv.RecordMessage("fetched a complete CAR of %s, %d bytes in %s", d.Root, n, duration)
+	}
+	runenv.R().RecordPoint("car_invalid", float64(len(failed)))
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d CARs were invalid, first: %w", len(failed), len(roots), failed[0])
+	}
+	return nil
+}
+
+// fetchCAR fetches the CAR of the DAG from the gateway and checks that it
+// has the root, followed by all the leaves and only them, and that every
+// block matches its CID. It returns the size of the CAR.
+func fetchCAR(ctx context.Context, gateway string, d *carDAG) (int64, error) {
+	root, err := cid.Decode(d.Root)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/ipfs/%s?format=car", gateway, root), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("unexpected status %s: %s", resp.Status, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != carContentType {
+		return 0, fmt.Errorf("unexpected content type %q", ct)
+	}
+
+	cr := &countingReader{r: resp.Body}
+	br := bufio.NewReader(cr)
+	header, err := readSection(br)
+	if err != nil {
+		return cr.n, fmt.Errorf("could not read the header: %w", err)
+	}
+	if !bytes.Equal(header, encodeCARHeader(root)) {
+		return cr.n, errors.New("unexpected header")
+	}
+
+	var expected map[cid.Cid]bool
+	for {
+		section, err := readSection(br)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cr.n, err
+		}
+		n, c, err := cid.CidFromBytes(section)
+		if err != nil {
+			return cr.n, fmt.Errorf("invalid CID: %w", err)
+		}
+		data := section[n:]
+		sum, err := c.Prefix().Sum(data)
+		if err != nil {
+			return cr.n, err
+		}
+		if !sum.Equals(c) {
+			return cr.n, fmt.Errorf("block %s doesn't match its CID", c)
+		}
+
+		if expected == nil {
+			if !c.Equals(root) {
+				return cr.n, fmt.Errorf("the CAR starts with %s instead of the root", c)
+			}
+			links, err := decodeCBORLinks(data)
+			if err != nil {
+				return cr.n, err
+			}
+			if len(links) != d.Leaves {
+				return cr.n, fmt.Errorf("the root has %d links instead of %d", len(links), d.Leaves)
+			}
+			expected = make(map[cid.Cid]bool, len(links))
+			for _, l := range links {
+				expected[l] = true
+			}
+			continue
+		}
+		if !expected[c] {
+			return cr.n, fmt.Errorf("unexpected block %s", c)
+		}
+		delete(expected, c)
+	}
+	if expected == nil || len(expected) > 0 {
+		return cr.n, errCARIncomplete
+	}
+	return cr.n, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	return n, err
+}
+
+// sha256Cid returns the CIDv1 of the data with the codec, hashed with
+// SHA2-256.
+func sha256Cid(codec uint64, data []byte) (cid.Cid, error) {
+	mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.NewCidV1(codec, mh), nil
+}
+
+// writeSection writes a CARv1 section: the varint length of the data and the
+// data.
+func writeSection(w io.Writer, data []byte) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(data)))
+	_, _ = w.Write(buf[:n])
+	_, _ = w.Write(data)
+}
+
+// readSection reads a CARv1 section, and returns io.EOF at the end of the
+// CAR and io.ErrUnexpectedEOF if the section is truncated.
+func readSection(r *bufio.Reader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("could not read the section length: %w", err)
+	}
+	if l == 0 || l > carMaxSectionSize {
+		return nil, fmt.Errorf("invalid section length %d", l)
+	}
+	data := make([]byte, l)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// encodeCARHeader encodes the DAG-CBOR header of a CARv1 with a single root:
+// {"roots": [root], "version": 1}.
+func encodeCARHeader(root cid.Cid) []byte {
+	var b []byte
+	b = appendCBORHead(b, 5, 2)
+	b = appendCBORHead(b, 3, 5)
+	b = append(b, "roots"...)
+	b = appendCBORHead(b, 4, 1)
+	b = appendCBORLink(b, root)
+	b = appendCBORHead(b, 3, 7)
+	b = append(b, "version"...)
+	return appendCBORHead(b, 0, 1)
+}
+
+// encodeCBORLinks encodes the links as a DAG-CBOR list.
+func encodeCBORLinks(links []cid.Cid) []byte {
+	b := appendCBORHead(nil, 4, uint64(len(links)))
+	for _, c := range links {
+		b = appendCBORLink(b, c)
+	}
+	return b
+}
+
+// decodeCBORLinks decodes a DAG-CBOR list of links.
+func decodeCBORLinks(b []byte) ([]cid.Cid, error) {
+	major, n, b, err := readCBORHead(b)
+	if err != nil {
+		return nil, err
+	}
+	if major != 4 {
+		return nil, errors.New("not a list of links")
+	}
+	links := make([]cid.Cid, 0, n)
+	for i := uint64(0); i < n; i++ {
+		var tag, l uint64
+		if major, tag, b, err = readCBORHead(b); err != nil {
+			return nil, err
+		}
+		if major != 6 || tag != 42 {
+			return nil, errors.New("not a link")
+		}
+		if major, l, b, err = readCBORHead(b); err != nil {
+			return nil, err
+		}
+		if major != 2 || l < 1 || uint64(len(b)) < l || b[0] != 0 {
+			return nil, errors.New("invalid link")
+		}
+		c, err := cid.Cast(b[1:l])
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, c)
+		b = b[l:]
+	}
+	if len(b) > 0 {
+		return nil, errors.New("trailing data after the links")
+	}
+	return links, nil
+}
+
+// appendCBORLink appends a CID as a tag 42 byte string, prefixed with the
+// identity multibase.
+func appendCBORLink(b []byte, c cid.Cid) []byte {
+	b = appendCBORHead(b, 6, 42)
+	raw := c.Bytes()
+	b = appendCBORHead(b, 2, uint64(len(raw)+1))
+	b = append(b, 0)
+	return append(b, raw...)
+}
+
+// appendCBORHead appends the head of a CBOR data item of the given major
+// type, in its shortest form.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(b, m|byte(n))
+	case n <= 0xff:
+		return append(b, m|24, byte(n))
+	case n <= 0xffff:
+		return append(b, m|25, byte(n>>8), byte(n))
+	case n <= 0xffffffff:
+		return append(b, m|26, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	b = append(b, m|27)
+	return binary.BigEndian.AppendUint64(b, n)
+}
+
+func readCBORHead(b []byte) (byte, uint64, []byte, error) {
+	if len(b) == 0 {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+	b = b[1:]
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), b, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, 0, nil, fmt.Errorf("unsupported CBOR item %#x", info)
+	}
+	if len(b) < size {
+		return 0, 0, nil, io.ErrUnexpectedEOF
+	}
+	var n uint64
+	for _, c := range b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	return major, n, b[size:], nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/testground/sdk-go/runtime"
+
+	datastore "github.com/ipfs/go-datastore"
+	badgerds "github.com/ipfs/go-ds-badger"
+	flatfs "github.com/ipfs/go-ds-flatfs"
+)
+
+// newDatastore returns the datastore backing the blockstore of the instance,
+// set by the datastore param: "memory", or "flatfs" or "badger" in a
+// temporary directory, which sync their writes to disk with the fsync param.
+// The directory is left behind, as the instance doesn't outlive the run.
+func newDatastore(runenv *runtime.RunEnv) (datastore.Batching, error) {
+	kind := runenv.StringParam("datastore")
+	if kind == "memory" {
+		return datastore.NewMapDatastore(), nil
+	}
+
+	dir, err := os.MkdirTemp("", "bitswap-"+kind)
+	if err != nil {
+		return nil, err
+	}
+	fsync := runenv.BooleanParam("fsync")
+	runenv.RecordMessage("using a %s datastore in %s, fsync=%t", kind, dir, fsync)
+	switch kind {
+	case "flatfs":
+		return flatfs.CreateOrOpen(dir, flatfs.NextToLast(2), fsync)
+	case "badger":
+		opts := badgerds.DefaultOptions
+		opts.SyncWrites = fsync
+		return badgerds.NewDatastore(dir, &opts)
+	}
+	return nil, fmt.Errorf("unknown datastore %q", kind)
+}
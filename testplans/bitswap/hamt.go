@@ -0,0 +1,435 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/spaolacci/murmur3"
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	block "github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// hamtDir is the sharded directory of the provider of the hamt testcase.
+type hamtDir struct {
+	AddrInfo peer.AddrInfo
+	Root     string
+	Entries  int
+}
+
+var (
+	hamtDirTopic  = sync.NewTopic("hamt-dir", &hamtDir{})
+	hamtDoneState = sync.State("hamt-done")
+)
+
+const (
+	// hamtFanout is the fanout of the shards, the default of kubo.
+	hamtFanout = 256
+	// hamtMurmur3 is the multicodec of the hash function of the shards.
+	hamtMurmur3 = 0x22
+	// hamtPutBatch is the number of blocks put at once in the blockstore.
+	hamtPutBatch = 4096
+	// unixfsHAMT is the UnixFS data type of the shards.
+	unixfsHAMT = 5
+)
+
+// runHAMT resolves entries of a sharded UnixFS directory of many small
+// files, the workload of gateways serving large directories. The provider
+// generates the directory, encoded like the HAMT shards of go-unixfs, and the
+// requestors list it and resolve and fetch random entries, measuring the
+// latency of each.
+func runHAMT(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	ctx := context.Background()
+
+	h, bstore, ex, err := newNode(ctx, runenv, initCtx)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	stopWatching := watchResources(ctx, runenv)
+	switch runenv.TestGroupID {
+	case "providers":
+		runenv.RecordMessage("running provider")
+		err = runHAMTProvider(ctx, runenv, initCtx, h, bstore)
+	case "requestors":
+		runenv.RecordMessage("running requestor")
+		err = runHAMTRequestor(ctx, runenv, initCtx, h, ex)
+	default:
+		err = errors.New("unknown test group id")
+	}
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	if werr := signalAndWait(ctx, runenv, initCtx, hamtDoneState, runenv.TestInstanceCount); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// hamtEntryName is the name of the i-th file of the directory.
+func hamtEntryName(i int) string {
+	return fmt.Sprintf("file-%07d", i)
+}
+
+// hamtEntry is a file of the directory.
+type hamtEntry struct {
+	name string
+	hash uint64
+	cid  cid.Cid
+	size uint64
+}
+
+func runHAMTProvider(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, bstore blockstore.Blockstore) error {
+	count := runenv.IntParam("entries")
+	size := runenv.SizeParam("size")
+
+	begin := time.Now()
+	r := rand.New(rand.NewSource(initCtx.GlobalSeq))
+	entries := make([]*hamtEntry, count)
+	batch := make([]block.Block, 0, hamtPutBatch)
+	for i := range entries {
+		name := hamtEntryName(i)
+		data := make([]byte, size)
+		r.Read(data)
+		c, err := sha256Cid(cid.Raw, data)
+		if err != nil {
+			return err
+		}
+		blk, err := block.NewBlockWithCid(data, c)
+		if err != nil {
+			return err
+		}
+		if batch = append(batch, blk); len(batch) == cap(batch) {
+			if err := bstore.PutMany(ctx, batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+		entries[i] = &hamtEntry{name: name, hash: murmur3.Sum64([]byte(name)), cid: c, size: size}
+	}
+	if err := bstore.PutMany(ctx, batch); err != nil {
+		return err
+	}
+
+	var shards int
+	root, _, err := putHAMTShard(ctx, bstore, entries, 0, &shards)
+	if err != nil {
+		return err
+	}
+	runenv.RecordMessage("generated directory %s of %d entries in %d shards in %s", root, count, shards, time.Since(begin))
+
+	initCtx.SyncClient.MustPublish(ctx, hamtDirTopic, &hamtDir{
+		AddrInfo: peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()},
+		Root:     root.String(),
+		Entries:  count,
+	})
+	return nil
+}
+
+// putHAMTShard puts the shard of the entries at the given depth, with its
+// child shards, and returns its CID and cumulative size. As in go-unixfs, a
+// slot holds an entry, or a child shard when several entries share it.
+func putHAMTShard(ctx context.Context, bstore blockstore.Blockstore, entries []*hamtEntry, depth int, shards *int) (cid.Cid, uint64, error) {
+	if depth == 8 {
+		return cid.Undef, 0, errors.New("hash collision")
+	}
+	var slots [hamtFanout][]*hamtEntry
+	for _, e := range entries {
+		i := hamtIndex(e.hash, depth)
+		slots[i] = append(slots[i], e)
+	}
+
+	var (
+		links    []pbLink
+		bitfield [hamtFanout / 8]byte
+		tsize    uint64
+	)
+	for i, slot := range slots {
+		switch len(slot) {
+		case 0:
+			continue
+		case 1:
+			e := slot[0]
+			links = append(links, pbLink{Hash: e.cid, Name: fmt.Sprintf("%02X", i) + e.name, Tsize: e.size})
+		default:
+			c, size, err := putHAMTShard(ctx, bstore, slot, depth+1, shards)
+			if err != nil {
+				return cid.Undef, 0, err
+			}
+			links = append(links, pbLink{Hash: c, Name: fmt.Sprintf("%02X", i), Tsize: size})
+		}
+		bitfield[len(bitfield)-1-i/8] |= 1 << (i % 8)
+		tsize += links[len(links)-1].Tsize
+	}
+
+	node := encodePBNode(links, encodeHAMTData(bitfield[:]))
+	c, err := sha256Cid(cid.DagProtobuf, node)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	blk, err := block.NewBlockWithCid(node, c)
+	if err != nil {
+		return cid.Undef, 0, err
+	}
+	if err := bstore.Put(ctx, blk); err != nil {
+		return cid.Undef, 0, err
+	}
+	*shards++
+	return c, tsize + uint64(len(node)), nil
+}
+
+// hamtIndex returns the slot of the hash at the depth, taking 8 bits of the
+// hash per level, from the most significant ones.
+func hamtIndex(hash uint64, depth int) int {
+	return int(hash >> (56 - 8*depth) & 0xff)
+}
+
+func runHAMTRequestor(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, ex exchange.Interface) error {
+	client := initCtx.SyncClient
+
+	dirs := make(chan *hamtDir)
+	sub, err := client.Subscribe(ctx, hamtDirTopic, dirs)
+	if err != nil {
+		return err
+	}
+	var dir *hamtDir
+	select {
+	case dir = <-dirs:
+	case err := <-sub.Done():
+		return err
+	}
+	root, err := cid.Decode(dir.Root)
+	if err != nil {
+		return err
+	}
+	if err := h.Connect(ctx, dir.AddrInfo); err != nil {
+		return fmt.Errorf("could not connect to provider: %w", err)
+	}
+
+	// resolve before listing, which fetches the whole directory
+	r := rand.New(rand.NewSource(initCtx.GlobalSeq))
+	samples := runenv.IntParam("samples")
+	for i := 0; i < samples; i++ {
+		name := hamtEntryName(r.Intn(dir.Entries))
+		begin := time.Now()
+		c, hops, err := resolveHAMT(ctx, ex, root, name)
+		if err != nil {
+			return fmt.Errorf("could not resolve %s: %w", name, err)
+		}
+		resolved := time.Since(begin)
+		blk, err := ex.GetBlock(ctx, c)
+		if err != nil {
+			return fmt.Errorf("could not fetch %s: %w", name, err)
+		}
+		if uint64(len(blk.RawData())) != runenv.SizeParam("size") {
+			return fmt.Errorf("%s has %d bytes", name, len(blk.RawData()))
+		}
+		runenv.R().RecordPoint("hamt_resolve_ms", float64(resolved.Milliseconds()))
+		runenv.R().RecordPoint("hamt_resolve_shards", float64(hops))
+		runenv.R().RecordPoint("hamt_fetch_ms", float64(time.Since(begin).Milliseconds()))
+	}
+
+	if runenv.BooleanParam("list") {
+		begin := time.Now()
+		n, err := listHAMT(ctx, ex, root)
+		if err != nil {
+			return fmt.Errorf("could not list the directory: %w", err)
+		}
+		if n != dir.Entries {
+			return fmt.Errorf("listed %d entries instead of %d", n, dir.Entries)
+		}
+		runenv.R().RecordPoint("hamt_list_ms", float64(time.Since(begin).Milliseconds()))
+		runenv.RecordMessage("listed %d entries in %s", n, time.Since(begin))
+	}
+	return nil
+}
+
+// resolveHAMT resolves the name in the sharded directory, and returns the CID
+// of the entry and the number of shards walked.
+func resolveHAMT(ctx context.Context, ex exchange.Interface, root cid.Cid, name string) (cid.Cid, int, error) {
+	hash := murmur3.Sum64([]byte(name))
+	shard := root
+	for depth := 0; depth < 8; depth++ {
+		blk, err := ex.GetBlock(ctx, shard)
+		if err != nil {
+			return cid.Undef, depth, err
+		}
+		links, err := decodePBLinks(blk.RawData())
+		if err != nil {
+			return cid.Undef, depth, err
+		}
+		prefix := fmt.Sprintf("%02X", hamtIndex(hash, depth))
+		var next *pbLink
+		for i := range links {
+			if strings.HasPrefix(links[i].Name, prefix) {
+				next = &links[i]
+				break
+			}
+		}
+		switch {
+		case next == nil:
+			return cid.Undef, depth + 1, errors.New("no such entry")
+		case len(next.Name) == len(prefix):
+			shard = next.Hash
+		case next.Name == prefix+name:
+			return next.Hash, depth + 1, nil
+		default:
+			return cid.Undef, depth + 1, errors.New("no such entry")
+		}
+	}
+	return cid.Undef, 8, errors.New("directory too deep")
+}
+
+// listHAMT walks the sharded directory level by level, fetching the shards
+// of a level at once, and returns the number of entries.
+func listHAMT(ctx context.Context, ex exchange.Interface, root cid.Cid) (int, error) {
+	var entries int
+	level := []cid.Cid{root}
+	for len(level) > 0 {
+		blks, err := ex.GetBlocks(ctx, level)
+		if err != nil {
+			return 0, err
+		}
+		var next []cid.Cid
+		var received int
+		for blk := range blks {
+			received++
+			links, err := decodePBLinks(blk.RawData())
+			if err != nil {
+				return 0, err
+			}
+			for _, l := range links {
+				if len(l.Name) == 2 {
+					next = append(next, l.Hash)
+				} else {
+					entries++
+				}
+			}
+		}
+		if received != len(level) {
+			if ctx.Err() != nil {
+				return 0, ctx.Err()
+			}
+			return 0, fmt.Errorf("fetched %d of %d shards", received, len(level))
+		}
+		level = next
+	}
+	return entries, nil
+}
+
+// pbLink is a link of a dag-pb node.
+type pbLink struct {
+	Hash  cid.Cid
+	Name  string
+	Tsize uint64
+}
+
+// encodePBNode encodes a dag-pb node, with its links before its data as in
+// the canonical form.
+func encodePBNode(links []pbLink, data []byte) []byte {
+	var b []byte
+	for _, l := range links {
+		var lb []byte
+		lb = appendPBBytes(lb, 1, l.Hash.Bytes())
+		lb = appendPBBytes(lb, 2, []byte(l.Name))
+		lb = appendPBVarint(lb, 3, l.Tsize)
+		b = appendPBBytes(b, 2, lb)
+	}
+	return appendPBBytes(b, 1, data)
+}
+
+// encodeHAMTData encodes the UnixFS data of a HAMT shard.
+func encodeHAMTData(bitfield []byte) []byte {
+	// the bitfield is sent without its leading zero bytes
+	for len(bitfield) > 0 && bitfield[0] == 0 {
+		bitfield = bitfield[1:]
+	}
+	var b []byte
+	b = appendPBVarint(b, 1, unixfsHAMT)
+	b = appendPBBytes(b, 2, bitfield)
+	b = appendPBVarint(b, 5, hamtMurmur3)
+	return appendPBVarint(b, 6, hamtFanout)
+}
+
+// decodePBLinks decodes the links of a dag-pb node.
+func decodePBLinks(b []byte) ([]pbLink, error) {
+	var links []pbLink
+	for len(b) > 0 {
+		field, value, rest, err := readPBField(b)
+		if err != nil {
+			return nil, err
+		}
+		b = rest
+		if field != 2 {
+			continue
+		}
+		var l pbLink
+		for len(value) > 0 {
+			lfield, lvalue, lrest, err := readPBField(value)
+			if err != nil {
+				return nil, err
+			}
+			value = lrest
+			switch lfield {
+			case 1:
+				if l.Hash, err = cid.Cast(lvalue); err != nil {
+					return nil, err
+				}
+			case 2:
+				l.Name = string(lvalue)
+			}
+		}
+		links = append(links, l)
+	}
+	return links, nil
+}
+
+func appendPBVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3))
+	return binary.AppendUvarint(b, v)
+}
+
+func appendPBBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// readPBField reads a protobuf field, and returns its number and, for the
+// length-delimited ones, its value.
+func readPBField(b []byte) (int, []byte, []byte, error) {
+	key, n := binary.Uvarint(b)
+	if n <= 0 {
+		return 0, nil, nil, io.ErrUnexpectedEOF
+	}
+	b = b[n:]
+	field := int(key >> 3)
+	switch key & 7 {
+	case 0:
+		if _, n = binary.Uvarint(b); n <= 0 {
+			return 0, nil, nil, io.ErrUnexpectedEOF
+		}
+		return field, nil, b[n:], nil
+	case 2:
+		l, n := binary.Uvarint(b)
+		if n <= 0 || uint64(len(b)-n) < l {
+			return 0, nil, nil, io.ErrUnexpectedEOF
+		}
+		return field, b[n : n+int(l)], b[n+int(l):], nil
+	}
+	return 0, nil, nil, fmt.Errorf("unsupported wire type %d", key&7)
+}
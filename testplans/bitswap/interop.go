@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	block "github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// interopMember is published by every instance of the interop testcase.
+type interopMember struct {
+	AddrInfo peer.AddrInfo
+	// Version labels the build of the instance, e.g. the go-libipfs version
+	// of its group.
+	Version  string
+	Provider bool
+	// Seed generates the blocks of a provider, so that requestors can check
+	// their content.
+	Seed   int64
+	Blocks []string
+}
+
+var (
+	interopMemberTopic = sync.NewTopic("interop-member", &interopMember{})
+	interopDoneState   = sync.State("interop-done")
+)
+
+// runInterop transfers blocks between instances built from different
+// versions, set per group with the build dependencies or the artifact of the
+// group. Every requestor downloads the blocks of every provider and checks
+// them, and records the speed of each pair of versions.
+func runInterop(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	ctx := context.Background()
+
+	version := runenv.StringParam("version")
+	role := runenv.StringParam("role")
+	if role != "provider" && role != "requestor" {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	runenv.RecordMessage("running interop as %s of version %s", role, version)
+
+	h, bstore, ex, err := newNode(ctx, runenv, initCtx)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	stopWatching := watchResources(ctx, runenv)
+	err = runInteropRole(ctx, runenv, initCtx, h, bstore, ex, version, role)
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func runInteropRole(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, bstore blockstore.Blockstore, ex exchange.Interface, version string, role string) error {
+	client := initCtx.SyncClient
+	var err error
+
+	me := interopMember{
+		AddrInfo: peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()},
+		Version:  version,
+		Provider: role == "provider",
+		Seed:     initCtx.GlobalSeq,
+	}
+	if me.Provider {
+		if me.Blocks, err = putInteropBlocks(ctx, runenv, bstore, me.Seed); err != nil {
+			return err
+		}
+	}
+	client.MustPublish(ctx, interopMemberTopic, &me)
+
+	if me.Provider {
+		// serve the requestors until they are all done
+		if err := signalAndWait(ctx, runenv, initCtx, interopDoneState, runenv.TestInstanceCount); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	members := make(chan *interopMember)
+	sub, err := client.Subscribe(ctx, interopMemberTopic, members)
+	if err != nil {
+		return err
+	}
+	var providers []*interopMember
+	for i := 0; i < runenv.TestInstanceCount; i++ {
+		select {
+		case m := <-members:
+			if m.Provider {
+				providers = append(providers, m)
+			}
+		case err := <-sub.Done():
+			return err
+		}
+	}
+	if len(providers) == 0 {
+		return errors.New("no provider in the composition")
+	}
+
+	var failed []error
+	for _, p := range providers {
+		if err := fetchInteropBlocks(ctx, runenv, h, ex, version, p); err != nil {
+			runenv.RecordMessage("version %s failed to fetch from version %s: %s", version, p.Version, err)
+			failed = append(failed, fmt.Errorf("from %s (%s): %w", p.AddrInfo.ID, p.Version, err))
+		}
+	}
+	// let the providers go even if a transfer failed
+	if err := signalAndWait(ctx, runenv, initCtx, interopDoneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d transfers failed, first: %w", len(failed), len(providers), failed[0])
+	}
+	return nil
+}
+
+// interopBlockData generates the data of the blocks of a provider from its
+// seed.
+func interopBlockData(seed int64, count int, size int) [][]byte {
+	r := rand.New(rand.NewSource(seed))
+	data := make([][]byte, count)
+	for i := range data {
+		data[i] = make([]byte, size)
+		r.Read(data[i])
+	}
+	return data
+}
+
+func putInteropBlocks(ctx context.Context, runenv *runtime.RunEnv, bstore blockstore.Blockstore, seed int64) ([]string, error) {
+	size := int(runenv.SizeParam("size"))
+	count := runenv.IntParam("count")
+	var cids []string
+	for _, buf := range interopBlockData(seed, count, size) {
+		blk := block.NewBlock(buf)
+		if err := bstore.Put(ctx, blk); err != nil {
+			return nil, err
+		}
+		cids = append(cids, blk.Cid().String())
+	}
+	runenv.RecordMessage("providing %d blocks of %d bytes", count, size)
+	return cids, nil
+}
+
+// fetchInteropBlocks downloads the blocks of a provider, checks that they
+// are the ones it generated, and records the duration of the transfer.
+func fetchInteropBlocks(ctx context.Context, runenv *runtime.RunEnv, h host.Host, ex exchange.Interface, version string, p *interopMember) error {
+	if err := h.Connect(ctx, p.AddrInfo); err != nil {
+		return fmt.Errorf("could not connect to provider: %w", err)
+	}
+	expected := interopBlockData(p.Seed, len(p.Blocks), int(runenv.SizeParam("size")))
+
+	begin := time.Now()
+	for i, s := range p.Blocks {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return err
+		}
+		dlBegin := time.Now()
+		blk, err := ex.GetBlock(ctx, c)
+		if err != nil {
+			return fmt.Errorf("could not get block %s: %w", c, err)
+		}
+		runenv.R().RecordPoint(fmt.Sprintf("interop_block_ms,provider=%s,requestor=%s", p.Version, version), float64(time.Since(dlBegin).Milliseconds()))
+		if !blk.Cid().Equals(c) {
+			return fmt.Errorf("got block %s instead of %s", blk.Cid(), c)
+		}
+		if !bytes.Equal(blk.RawData(), expected[i]) {
+			return fmt.Errorf("block %s has unexpected data", c)
+		}
+	}
+	duration := time.Since(begin)
+	runenv.R().RecordPoint(fmt.Sprintf("interop_total_ms,provider=%s,requestor=%s", p.Version, version), float64(duration.Milliseconds()))
+	runenv.RecordMessage("version %s fetched %d blocks from version %s in %s", version, len(p.Blocks), p.Version, duration)
+	return nil
+}
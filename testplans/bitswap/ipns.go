@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/rand"
+	gosync "sync"
+	"time"
+
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/ipfs/go-ipns"
+	ipns_pb "github.com/ipfs/go-ipns/pb"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	record "github.com/libp2p/go-libp2p-record"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// ipnsUpdate announces a record published by the publisher of the ipns
+// testcase.
+type ipnsUpdate struct {
+	Sequence uint64
+	Start    time.Time
+}
+
+// ipnsName is the name updated by the publisher of the ipns testcase.
+type ipnsName struct {
+	Name peer.ID
+}
+
+var (
+	ipnsPeerTopic         = sync.NewTopic("ipns-peer", &peer.AddrInfo{})
+	ipnsNameTopic         = sync.NewTopic("ipns-name", &ipnsName{})
+	ipnsUpdateTopic       = sync.NewTopic("ipns-update", &ipnsUpdate{})
+	ipnsBootstrappedState = sync.State("ipns-bootstrapped")
+	ipnsSubscribedState   = sync.State("ipns-subscribed")
+	ipnsResolvedState     = sync.State("ipns-resolved")
+	ipnsDoneState         = sync.State("ipns-done")
+)
+
+const (
+	// ipnsConnections is the number of random peers each instance connects
+	// to before bootstrapping its DHT.
+	ipnsConnections = 8
+	// ipnsPollInterval is the interval between the resolutions of a name
+	// until it resolves to the last update.
+	ipnsPollInterval = 250 * time.Millisecond
+)
+
+// runIPNS measures how fast IPNS records propagate. The single publisher
+// publishes a sequence of updates of a name, over the DHT or pubsub with the
+// router param, and the other instances measure the time until they resolve
+// each update and how many times they resolved a stale record meanwhile. The
+// network size is the number of instances of the composition.
+func runIPNS(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	ctx := context.Background()
+
+	router := runenv.StringParam("router")
+	if router != "dht" && router != "pubsub" {
+		return fmt.Errorf("unknown router %q", router)
+	}
+
+	h, err := newHost(ctx, runenv, initCtx)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	validator := record.NamespacedValidator{
+		"pk":   record.PublicKeyValidator{},
+		"ipns": ipns.Validator{KeyBook: h.Peerstore()},
+	}
+	kad, err := dht.New(ctx, h, dht.Mode(dht.ModeServer), dht.Validator(validator))
+	if err != nil {
+		return err
+	}
+	defer kad.Close()
+	var ps *pubsub.PubSub
+	if router == "pubsub" {
+		if ps, err = pubsub.NewGossipSub(ctx, h); err != nil {
+			return err
+		}
+	}
+
+	if err := bootstrapIPNS(ctx, runenv, initCtx, h, kad); err != nil {
+		return err
+	}
+
+	stopWatching := watchResources(ctx, runenv)
+	switch runenv.TestGroupID {
+	case "publishers":
+		runenv.RecordMessage("running publisher over %s", router)
+		err = runIPNSPublisher(ctx, runenv, initCtx, kad, ps)
+	default:
+		runenv.RecordMessage("running resolver over %s", router)
+		err = runIPNSResolver(ctx, runenv, initCtx, kad, ps)
+	}
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	if werr := signalAndWait(ctx, runenv, initCtx, ipnsDoneState, runenv.TestInstanceCount); err == nil {
+		err = werr
+	}
+	return err
+}
+
+// bootstrapIPNS connects to random instances and fills the routing table of
+// the DHT.
+func bootstrapIPNS(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, kad *dht.IpfsDHT) error {
+	client := initCtx.SyncClient
+
+	client.MustPublish(ctx, ipnsPeerTopic, &peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	peers := make(chan *peer.AddrInfo)
+	sub, err := client.Subscribe(ctx, ipnsPeerTopic, peers)
+	if err != nil {
+		return err
+	}
+	var others []peer.AddrInfo
+	for i := 0; i < runenv.TestInstanceCount; i++ {
+		select {
+		case ai := <-peers:
+			if ai.ID != h.ID() {
+				others = append(others, *ai)
+			}
+		case err := <-sub.Done():
+			return err
+		}
+	}
+
+	rand.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+	if len(others) > ipnsConnections {
+		others = others[:ipnsConnections]
+	}
+	for _, ai := range others {
+		if err := h.Connect(ctx, ai); err != nil {
+			return fmt.Errorf("could not connect to %s: %w", ai.ID, err)
+		}
+	}
+	if err := <-kad.RefreshRoutingTable(); err != nil {
+		return fmt.Errorf("could not bootstrap the DHT: %w", err)
+	}
+	if err := signalAndWait(ctx, runenv, initCtx, ipnsBootstrappedState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ipnsTopic is the pubsub topic of the records of an IPNS key, as named by
+// the pubsub router of kubo.
+func ipnsTopic(key string) string {
+	return "/record/" + base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func runIPNSPublisher(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, kad *dht.IpfsDHT, ps *pubsub.PubSub) error {
+	client := initCtx.SyncClient
+
+	sk, _, err := crypto.GenerateEd25519Key(crand.Reader)
+	if err != nil {
+		return err
+	}
+	name, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return err
+	}
+	key := ipns.RecordKey(name)
+	var topic *pubsub.Topic
+	if ps != nil {
+		if topic, err = ps.Join(ipnsTopic(key)); err != nil {
+			return err
+		}
+		defer topic.Close()
+	}
+	client.MustPublish(ctx, ipnsNameTopic, &ipnsName{Name: name})
+	// the resolvers over pubsub only receive the records published once
+	// they are subscribed
+	if err := signalAndWait(ctx, runenv, initCtx, ipnsSubscribedState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+
+	updates := runenv.IntParam("updates")
+	interval := time.Duration(runenv.IntParam("update_interval")) * time.Second
+	for seq := uint64(1); seq <= uint64(updates); seq++ {
+		if seq > 1 {
+			time.Sleep(interval)
+		}
+		entry, err := ipns.Create(sk, []byte(fmt.Sprintf("/ipfs/update-%d", seq)), seq, time.Now().Add(24*time.Hour), time.Minute)
+		if err != nil {
+			return err
+		}
+		data, err := entry.Marshal()
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		client.MustPublish(ctx, ipnsUpdateTopic, &ipnsUpdate{Sequence: seq, Start: start})
+		if topic != nil {
+			err = topic.Publish(ctx, data)
+		} else {
+			err = kad.PutValue(ctx, key, data)
+		}
+		if err != nil {
+			return fmt.Errorf("could not publish update %d: %w", seq, err)
+		}
+		runenv.R().RecordPoint("ipns_publish_ms", float64(time.Since(start).Milliseconds()))
+		runenv.RecordMessage("published update %d in %s", seq, time.Since(start))
+
+		// wait for the resolvers before the next update, so that each
+		// update is measured on its own
+		if err := signalAndWait(ctx, runenv, initCtx, sync.State(fmt.Sprintf("%s-%d", ipnsResolvedState, seq)), runenv.TestInstanceCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runIPNSResolver(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, kad *dht.IpfsDHT, ps *pubsub.PubSub) error {
+	client := initCtx.SyncClient
+	router := runenv.StringParam("router")
+	timeout := time.Duration(runenv.IntParam("resolve_timeout")) * time.Second
+
+	names := make(chan *ipnsName)
+	nameSub, err := client.Subscribe(ctx, ipnsNameTopic, names)
+	if err != nil {
+		return err
+	}
+	var name *ipnsName
+	select {
+	case name = <-names:
+	case err := <-nameSub.Done():
+		return err
+	}
+	key := ipns.RecordKey(name.Name)
+
+	resolve := func(ctx context.Context) ([]byte, error) {
+		return kad.GetValue(ctx, key)
+	}
+	if ps != nil {
+		latest, err := subscribeRecords(ctx, ps, key, kad.Validator)
+		if err != nil {
+			return err
+		}
+		defer latest.close()
+		resolve = latest.get
+	}
+	if err := signalAndWait(ctx, runenv, initCtx, ipnsSubscribedState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+
+	updates := make(chan *ipnsUpdate)
+	sub, err := client.Subscribe(ctx, ipnsUpdateTopic, updates)
+	if err != nil {
+		return err
+	}
+
+	updateN := runenv.IntParam("updates")
+	var failed int
+	for i := 0; i < updateN; i++ {
+		var u *ipnsUpdate
+		select {
+		case u = <-updates:
+		case err := <-sub.Done():
+			return err
+		}
+
+		elapsed, stale, err := resolveUpdate(ctx, u, key, kad.Validator, resolve, timeout)
+		if err != nil {
+			failed++
+			runenv.RecordMessage("could not resolve update %d: %s", u.Sequence, err)
+		} else {
+			runenv.R().RecordPoint(fmt.Sprintf("ipns_resolve_ms,router=%s", router), float64(elapsed.Milliseconds()))
+			runenv.RecordMessage("resolved update %d in %s after %d stale resolutions", u.Sequence, elapsed, stale)
+		}
+		runenv.R().RecordPoint(fmt.Sprintf("ipns_stale_resolutions,router=%s", router), float64(stale))
+
+		if err := signalAndWait(ctx, runenv, initCtx, sync.State(fmt.Sprintf("%s-%d", ipnsResolvedState, u.Sequence)), runenv.TestInstanceCount); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d updates weren't resolved", failed, updateN)
+	}
+	return nil
+}
+
+// resolveUpdate resolves the name until it resolves to the update, and
+// returns the time since the update was published and the number of stale
+// records resolved meanwhile.
+func resolveUpdate(ctx context.Context, u *ipnsUpdate, key string, validator record.Validator, resolve func(context.Context) ([]byte, error), timeout time.Duration) (time.Duration, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stale int
+	for {
+		data, err := resolve(ctx)
+		if err == nil {
+			if err := validator.Validate(key, data); err != nil {
+				return 0, stale, fmt.Errorf("invalid record: %w", err)
+			}
+			var entry ipns_pb.IpnsEntry
+			if err := entry.Unmarshal(data); err != nil {
+				return 0, stale, err
+			}
+			if entry.GetSequence() >= u.Sequence {
+				return time.Since(u.Start), stale, nil
+			}
+			stale++
+		}
+
+		select {
+		case <-time.After(ipnsPollInterval):
+		case <-ctx.Done():
+			return 0, stale, ctx.Err()
+		}
+	}
+}
+
+// pubsubRecord keeps the latest valid record received on the pubsub topic of
+// a name.
+type pubsubRecord struct {
+	mu     gosync.Mutex
+	data   []byte
+	cancel func()
+}
+
+func subscribeRecords(ctx context.Context, ps *pubsub.PubSub, key string, validator record.Validator) (*pubsubRecord, error) {
+	topic, err := ps.Join(ipnsTopic(key))
+	if err != nil {
+		return nil, err
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		topic.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	r := &pubsubRecord{cancel: func() {
+		cancel()
+		sub.Cancel()
+		topic.Close()
+	}}
+	go func() {
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+			if validator.Validate(key, msg.Data) != nil {
+				continue
+			}
+			r.mu.Lock()
+			if r.data == nil {
+				r.data = msg.Data
+			} else if i, err := validator.Select(key, [][]byte{r.data, msg.Data}); err == nil && i == 1 {
+				r.data = msg.Data
+			}
+			r.mu.Unlock()
+		}
+	}()
+	return r, nil
+}
+
+func (r *pubsubRecord) get(context.Context) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.data == nil {
+		return nil, errors.New("no record received")
+	}
+	return r.data, nil
+}
+
+func (r *pubsubRecord) close() {
+	r.cancel()
+}
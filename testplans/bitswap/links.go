@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net"
+
+	"github.com/testground/sdk-go/network"
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+)
+
+// linkPeer is the data network address of an instance and the downlink of
+// its group.
+type linkPeer struct {
+	IP       net.IP
+	Downlink uint64
+}
+
+var linkPeerTopic = sync.NewTopic("link-peers", &linkPeer{})
+
+// asymmetricLinks shapes the links of the instance after the uplink and
+// downlink params of its group, in bytes per second, 0 for no limit, to model
+// asymmetric home links. The uplink limits the egress of the instance. As the
+// network only shapes egress, the downlink of an instance limits each link of
+// the other instances to it, not their sum. It returns the shape of the
+// egress and the rules of the links to the instances with a downlink.
+func asymmetricLinks(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, ip net.IP, shape network.LinkShape) (network.LinkShape, []network.LinkRule, error) {
+	client := initCtx.SyncClient
+
+	// the bandwidth of the network is in bits per second
+	uplink := runenv.SizeParam("uplink") * 8
+	downlink := runenv.SizeParam("downlink") * 8
+	if uplink > 0 {
+		shape.Bandwidth = uplink
+	}
+
+	if _, err := client.Publish(ctx, linkPeerTopic, &linkPeer{IP: ip, Downlink: downlink}); err != nil {
+		return shape, nil, err
+	}
+	peers := make(chan *linkPeer)
+	sub, err := client.Subscribe(ctx, linkPeerTopic, peers)
+	if err != nil {
+		return shape, nil, err
+	}
+	var rules []network.LinkRule
+	for i := 0; i < runenv.TestInstanceCount; i++ {
+		var p *linkPeer
+		select {
+		case p = <-peers:
+		case err := <-sub.Done():
+			return shape, nil, err
+		}
+		if p.Downlink == 0 || p.IP.Equal(ip) || (uplink > 0 && uplink <= p.Downlink) {
+			continue
+		}
+		// the data network is IPv4
+		rule := network.LinkRule{
+			LinkShape: shape,
+			Subnet:    net.IPNet{IP: p.IP.To4(), Mask: net.CIDRMask(32, 32)},
+		}
+		rule.Bandwidth = p.Downlink
+		rules = append(rules, rule)
+	}
+	return shape, rules, nil
+}
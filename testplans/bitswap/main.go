@@ -16,7 +16,6 @@ import (
 	bsnet "github.com/ipfs/go-libipfs/bitswap/network"
 	block "github.com/ipfs/go-libipfs/blocks"
 	"github.com/ipfs/go-cid"
-	datastore "github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	exchange "github.com/ipfs/go-ipfs-exchange-interface"
 	bstats "github.com/ipfs/go-ipfs-regression/bitswap"
@@ -31,6 +30,11 @@ import (
 var (
 	testcases = map[string]interface{}{
 		"speed-test": run.InitializedTestCaseFn(runSpeedTest),
+		"interop":    run.InitializedTestCaseFn(runInterop),
+		"nat":        run.InitializedTestCaseFn(runNAT),
+		"ipns":       run.InitializedTestCaseFn(runIPNS),
+		"car":        run.InitializedTestCaseFn(runCAR),
+		"hamt":       run.InitializedTestCaseFn(runHAMT),
 	}
 	networkState  = sync.State("network-configured")
 	readyState    = sync.State("ready-to-publish")
@@ -48,6 +52,77 @@ func runSpeedTest(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
 	runenv.RecordMessage("running speed-test")
 	ctx := context.Background()
 
+	h, bstore, ex, err := newNode(ctx, runenv, initCtx)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+	stopWatching := watchResources(ctx, runenv)
+	res := newResults(runenv)
+	switch runenv.TestGroupID {
+	case "providers":
+		runenv.RecordMessage("running provider")
+		err = runProvide(ctx, runenv, h, bstore, ex, initCtx)
+	case "requestors":
+		runenv.RecordMessage("running requestor")
+		err = runRequest(ctx, runenv, h, bstore, ex, initCtx, res)
+	case "collectors":
+		runenv.RecordMessage("running collector")
+		err = runSpeedTestCollector(ctx, runenv, initCtx)
+	default:
+		runenv.RecordMessage("not part of a group")
+		err = errors.New("unknown test group id")
+	}
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	// the collector waits for the results of every other instance, even
+	// failed ones
+	if runenv.TestGroupID != "collectors" {
+		if perr := res.publish(ctx, initCtx); err == nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+// runSpeedTestCollector enters the states the providers and requestors wait
+// for, and collects their results.
+func runSpeedTestCollector(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	for _, state := range []sync.State{readyState, readyDLState, doneState} {
+		if err := signalEntry(ctx, runenv, initCtx, state); err != nil {
+			return err
+		}
+	}
+	return runCollector(ctx, runenv, initCtx)
+}
+
+// newNode configures the network of the instance and starts a libp2p host,
+// with the given options, and a bitswap exchange over the blockstore set by
+// the datastore param.
+func newNode(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, opts ...libp2p.Option) (host.Host, blockstore.Blockstore, exchange.Interface, error) {
+	h, err := newHost(ctx, runenv, initCtx, opts...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	kad, err := dht.New(ctx, h)
+	if err != nil {
+		h.Close()
+		return nil, nil, nil, err
+	}
+	ds, err := newDatastore(runenv)
+	if err != nil {
+		h.Close()
+		return nil, nil, nil, err
+	}
+	bstore := blockstore.NewBlockstore(ds)
+	ex := bitswap.New(ctx, bsnet.NewFromIpfsHost(h, kad), bstore)
+	return h, bstore, ex, nil
+}
+
+// newHost configures the network of the instance and starts a libp2p host
+// listening on the data network, with the given options.
+func newHost(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, opts ...libp2p.Option) (host.Host, error) {
 	netclient := initCtx.NetClient
 
 	linkShape := network.LinkShape{}
@@ -64,44 +139,32 @@ func runSpeedTest(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
 	// 	Duplicate:     0.02,
 	// 	DuplicateCorr: 0.1,
 	// }
+	ip := netclient.MustGetDataNetworkIP()
+	linkShape, rules, err := asymmetricLinks(ctx, runenv, initCtx, ip, linkShape)
+	if err != nil {
+		return nil, err
+	}
 	netclient.MustConfigureNetwork(ctx, &network.Config{
 		Network:        "default",
 		Enable:         true,
 		Default:        linkShape,
+		Rules:          rules,
 		CallbackState:  networkState,
 		CallbackTarget: runenv.TestGroupInstanceCount,
 		RoutingPolicy:  network.AllowAll,
 	})
-	listen, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/3333", netclient.MustGetDataNetworkIP().String()))
+	listen, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/3333", ip.String()))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	h, err := libp2p.New(libp2p.ListenAddrs(listen))
+	h, err := libp2p.New(append([]libp2p.Option{libp2p.ListenAddrs(listen)}, opts...)...)
 	if err != nil {
-		return err
-	}
-	defer h.Close()
-	kad, err := dht.New(ctx, h)
-	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, a := range h.Addrs() {
 		runenv.RecordMessage("listening on addr: %s", a.String())
 	}
-	bstore := blockstore.NewBlockstore(datastore.NewMapDatastore())
-	ex := bitswap.New(ctx, bsnet.NewFromIpfsHost(h, kad), bstore)
-	switch runenv.TestGroupID {
-	case "providers":
-		runenv.RecordMessage("running provider")
-		err = runProvide(ctx, runenv, h, bstore, ex, initCtx)
-	case "requestors":
-		runenv.RecordMessage("running requestor")
-		err = runRequest(ctx, runenv, h, bstore, ex, initCtx)
-	default:
-		runenv.RecordMessage("not part of a group")
-		err = errors.New("unknown test group id")
-	}
-	return err
+	return h, nil
 }
 
 func runProvide(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore blockstore.Blockstore, ex exchange.Interface, initCtx *run.InitContext) error {
@@ -112,7 +175,9 @@ func runProvide(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 		Addrs: h.Addrs(),
 	}
 	client.MustPublish(ctx, providerTopic, &ai)
-	_ = client.MustSignalAndWait(ctx, readyState, runenv.TestInstanceCount)
+	if err := signalAndWait(ctx, runenv, initCtx, readyState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
 
 	size := runenv.SizeParam("size")
 	count := runenv.IntParam("count")
@@ -129,12 +194,16 @@ func runProvide(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 		runenv.RecordMessage("publishing block %s", mh.String())
 		client.MustPublish(ctx, blockTopic, &mh)
 	}
-	_ = client.MustSignalAndWait(ctx, readyDLState, runenv.TestInstanceCount)
-	_ = client.MustSignalAndWait(ctx, doneState, runenv.TestInstanceCount)
+	if err := signalAndWait(ctx, runenv, initCtx, readyDLState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	if err := signalAndWait(ctx, runenv, initCtx, doneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
 	return nil
 }
 
-func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore blockstore.Blockstore, ex exchange.Interface, initCtx *run.InitContext) error {
+func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore blockstore.Blockstore, ex exchange.Interface, initCtx *run.InitContext, res *results) error {
 	client := initCtx.SyncClient
 
 	providers := make(chan *peer.AddrInfo)
@@ -156,9 +225,13 @@ func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 	runenv.RecordMessage("connected to provider")
 
 	// tell the provider that we're ready for it to publish blocks
-	_ = client.MustSignalAndWait(ctx, readyState, runenv.TestInstanceCount)
+	if err := signalAndWait(ctx, runenv, initCtx, readyState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
 	// wait until the provider is ready for us to start downloading
-	_ = client.MustSignalAndWait(ctx, readyDLState, runenv.TestInstanceCount)
+	if err := signalAndWait(ctx, runenv, initCtx, readyDLState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
 
 	blockmhSub, err := client.Subscribe(ctx, blockTopic, blkmhs)
 	if err != nil {
@@ -177,6 +250,7 @@ func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 			return fmt.Errorf("could not download get block %s: %w", mh.String(), err)
 		}
 		dlDuration := time.Since(dlBegin)
+		res.record("block_fetch_ms", float64(dlDuration.Milliseconds()))
 		s := &bstats.BitswapStat{
 			SingleDownloadSpeed: &bstats.SingleDownloadSpeed{
 				Cid:              blk.Cid().String(),
@@ -186,6 +260,7 @@ func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 		runenv.RecordMessage(bstats.Marshal(s))
 	}
 	duration := time.Since(begin)
+	res.record("download_completion_ms", float64(duration.Milliseconds()))
 	s := &bstats.BitswapStat{
 		MultipleDownloadSpeed: &bstats.MultipleDownloadSpeed{
 			BlockCount:    count,
@@ -193,6 +268,8 @@ func runRequest(ctx context.Context, runenv *runtime.RunEnv, h host.Host, bstore
 		},
 	}
 	runenv.RecordMessage(bstats.Marshal(s))
-	_ = client.MustSignalEntry(ctx, doneState)
+	if err := signalEntry(ctx, runenv, initCtx, doneState); err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	exchange "github.com/ipfs/go-ipfs-exchange-interface"
+	block "github.com/ipfs/go-libipfs/blocks"
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/control"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	relayclient "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// natBlocks are the blocks published by the provider of the nat testcase.
+type natBlocks struct {
+	Provider peer.ID
+	Cids     []string
+}
+
+var (
+	natRelayTopic     = sync.NewTopic("nat-relay", &peer.AddrInfo{})
+	natRequestorTopic = sync.NewTopic("nat-requestor", &peer.AddrInfo{})
+	natBlocksTopic    = sync.NewTopic("nat-blocks", &natBlocks{})
+	natReservedState  = sync.State("nat-reserved")
+	natConnectedState = sync.State("nat-connected")
+	natDoneState      = sync.State("nat-done")
+)
+
+// runNAT transfers blocks to requestors behind a simulated NAT, which can't
+// accept direct connections. The provider reaches each requestor through a
+// relay and, when the path param is "upgraded", waits for hole punching to
+// upgrade the relayed connection to a direct one. The time to establish the
+// connections and the throughput of the transfer are recorded by path. The
+// composition has a single relay and a single provider.
+func runNAT(runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	ctx := context.Background()
+
+	path := runenv.StringParam("path")
+	if path != "relayed" && path != "upgraded" {
+		return fmt.Errorf("unknown path %q", path)
+	}
+
+	var opts []libp2p.Option
+	switch runenv.TestGroupID {
+	case "relays":
+		opts = append(opts,
+			libp2p.ForceReachabilityPublic(),
+			// the transfers go through the relay, without the limits of a
+			// public relay
+			libp2p.EnableRelayService(relay.WithInfiniteLimits()),
+		)
+	case "providers":
+		opts = append(opts, libp2p.ForceReachabilityPublic())
+	case "requestors":
+		opts = append(opts,
+			libp2p.ForceReachabilityPrivate(),
+			libp2p.ConnectionGater(natGater{}),
+		)
+	default:
+		return errors.New("unknown test group id")
+	}
+	if path == "upgraded" && runenv.TestGroupID != "relays" {
+		opts = append(opts, libp2p.EnableHolePunching())
+	}
+
+	h, bstore, ex, err := newNode(ctx, runenv, initCtx, opts...)
+	if err != nil {
+		return err
+	}
+	defer h.Close()
+
+	stopWatching := watchResources(ctx, runenv)
+	switch runenv.TestGroupID {
+	case "relays":
+		runenv.RecordMessage("running relay")
+		err = runNATRelay(ctx, runenv, initCtx, h)
+	case "providers":
+		runenv.RecordMessage("running provider")
+		err = runNATProvider(ctx, runenv, initCtx, h, bstore, path)
+	case "requestors":
+		runenv.RecordMessage("running requestor")
+		err = runNATRequestor(ctx, runenv, initCtx, h, ex, path)
+	}
+	if werr := stopWatching(); err == nil {
+		err = werr
+	}
+	return err
+}
+
+func runNATRelay(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host) error {
+	client := initCtx.SyncClient
+
+	client.MustPublish(ctx, natRelayTopic, &peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	if err := signalAndWait(ctx, runenv, initCtx, natDoneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	return nil
+}
+
+func runNATProvider(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, bstore blockstore.Blockstore, path string) error {
+	client := initCtx.SyncClient
+
+	size := runenv.SizeParam("size")
+	count := runenv.IntParam("count")
+	blocks := natBlocks{Provider: h.ID()}
+	for i := 0; i < count; i++ {
+		buf := make([]byte, size)
+		rand.Read(buf)
+		blk := block.NewBlock(buf)
+		if err := bstore.Put(ctx, blk); err != nil {
+			return err
+		}
+		blocks.Cids = append(blocks.Cids, blk.Cid().String())
+	}
+	client.MustPublish(ctx, natBlocksTopic, &blocks)
+
+	// all the instances but the relay
+	requestorCount := runenv.TestInstanceCount - 2
+	if err := signalAndWait(ctx, runenv, initCtx, natReservedState, runenv.TestInstanceCount-1); err != nil {
+		return err
+	}
+	requestors := make(chan *peer.AddrInfo)
+	sub, err := client.Subscribe(ctx, natRequestorTopic, requestors)
+	if err != nil {
+		return err
+	}
+
+	upgradeTimeout := runenv.IntParam("upgrade_timeout")
+	var failed error
+	for i := 0; i < requestorCount; i++ {
+		var ai *peer.AddrInfo
+		select {
+		case ai = <-requestors:
+		case err := <-sub.Done():
+			return err
+		}
+
+		begin := time.Now()
+		if err := h.Connect(ctx, *ai); err != nil {
+			failed = fmt.Errorf("could not connect to requestor %s through the relay: %w", ai.ID, err)
+			break
+		}
+		runenv.R().RecordPoint(fmt.Sprintf("nat_connect_ms,path=%s", path), float64(time.Since(begin).Milliseconds()))
+		runenv.RecordMessage("connected to requestor %s through the relay in %s", ai.ID, time.Since(begin))
+
+		if path == "upgraded" {
+			if err := waitDirectConn(ctx, h, ai.ID, time.Duration(upgradeTimeout)*time.Second); err != nil {
+				failed = fmt.Errorf("connection to requestor %s wasn't upgraded: %w", ai.ID, err)
+				break
+			}
+			runenv.R().RecordPoint("nat_upgrade_ms", float64(time.Since(begin).Milliseconds()))
+			runenv.RecordMessage("upgraded the connection to requestor %s in %s", ai.ID, time.Since(begin))
+		}
+	}
+
+	// release the requestors even if a connection failed, they fail when
+	// fetching the blocks
+	if err := signalEntry(ctx, runenv, initCtx, natConnectedState); err != nil {
+		return err
+	}
+	if err := signalAndWait(ctx, runenv, initCtx, natDoneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	return failed
+}
+
+func runNATRequestor(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext, h host.Host, ex exchange.Interface, path string) error {
+	client := initCtx.SyncClient
+
+	relays := make(chan *peer.AddrInfo)
+	relaySub, err := client.Subscribe(ctx, natRelayTopic, relays)
+	if err != nil {
+		return err
+	}
+	ri := <-relays
+	relaySub.Done()
+
+	if err := h.Connect(ctx, *ri); err != nil {
+		return fmt.Errorf("could not connect to relay: %w", err)
+	}
+	if _, err := relayclient.Reserve(ctx, h, *ri); err != nil {
+		return fmt.Errorf("could not reserve a slot on the relay: %w", err)
+	}
+	var circuitAddrs []multiaddr.Multiaddr
+	for _, a := range ri.Addrs {
+		circuitAddrs = append(circuitAddrs, a.Encapsulate(multiaddr.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit", ri.ID))))
+	}
+	client.MustPublish(ctx, natRequestorTopic, &peer.AddrInfo{ID: h.ID(), Addrs: circuitAddrs})
+
+	blocksCh := make(chan *natBlocks)
+	blocksSub, err := client.Subscribe(ctx, natBlocksTopic, blocksCh)
+	if err != nil {
+		return err
+	}
+	blocks := <-blocksCh
+	blocksSub.Done()
+
+	if err := signalAndWait(ctx, runenv, initCtx, natReservedState, runenv.TestInstanceCount-1); err != nil {
+		return err
+	}
+	if err := waitBarrier(ctx, runenv, initCtx, natConnectedState, 1); err != nil {
+		return err
+	}
+
+	fetchErr := fetchNATBlocks(ctx, runenv, h, ex, path, blocks)
+	if err := signalAndWait(ctx, runenv, initCtx, natDoneState, runenv.TestInstanceCount); err != nil {
+		return err
+	}
+	return fetchErr
+}
+
+// fetchNATBlocks downloads the blocks from the provider, checks that they
+// went through the expected path, and records the throughput.
+func fetchNATBlocks(ctx context.Context, runenv *runtime.RunEnv, h host.Host, ex exchange.Interface, path string, blocks *natBlocks) error {
+	var received int
+	begin := time.Now()
+	for _, s := range blocks.Cids {
+		c, err := cid.Decode(s)
+		if err != nil {
+			return err
+		}
+		blk, err := ex.GetBlock(ctx, c)
+		if err != nil {
+			return fmt.Errorf("could not get block %s: %w", c, err)
+		}
+		received += len(blk.RawData())
+	}
+	duration := time.Since(begin)
+
+	var direct int
+	for _, c := range h.Network().ConnsToPeer(blocks.Provider) {
+		if !isRelayed(c.RemoteMultiaddr()) {
+			direct++
+		}
+	}
+	switch {
+	case path == "relayed" && direct > 0:
+		return errors.New("the provider connected directly despite the NAT")
+	case path == "upgraded" && direct == 0:
+		return errors.New("the connection to the provider wasn't upgraded")
+	}
+
+	throughput := float64(received) / duration.Seconds()
+	runenv.R().RecordPoint(fmt.Sprintf("nat_throughput_bytes_per_sec,path=%s", path), throughput)
+	runenv.RecordMessage("fetched %d bytes over a %s path in %s (%.0f B/s)", received, path, duration, throughput)
+	return nil
+}
+
+// waitDirectConn waits until the host has a direct connection to the peer.
+func waitDirectConn(ctx context.Context, h host.Host, p peer.ID, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		for _, c := range h.Network().ConnsToPeer(p) {
+			if !isRelayed(c.RemoteMultiaddr()) {
+				return nil
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func isRelayed(a multiaddr.Multiaddr) bool {
+	_, err := a.ValueForProtocol(multiaddr.P_CIRCUIT)
+	return err == nil
+}
+
+// natGater simulates a NAT: it accepts the connections the host dials and the
+// ones relayed to it, but not direct inbound ones.
+type natGater struct{}
+
+func (natGater) InterceptPeerDial(peer.ID) bool                      { return true }
+func (natGater) InterceptAddrDial(peer.ID, multiaddr.Multiaddr) bool { return true }
+func (natGater) InterceptSecured(network.Direction, peer.ID, network.ConnMultiaddrs) bool {
+	return true
+}
+func (natGater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) { return true, 0 }
+
+func (natGater) InterceptAccept(addrs network.ConnMultiaddrs) bool {
+	return isRelayed(addrs.RemoteMultiaddr())
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	tgruntime "github.com/testground/sdk-go/runtime"
+)
+
+// resourceSampleInterval is the interval between samples of the memory and
+// goroutines of an instance.
+const resourceSampleInterval = 500 * time.Millisecond
+
+// watchResources samples the heap and the goroutines of the instance until
+// the returned function is called, which records the peaks and fails if they
+// exceeded the max_heap or max_goroutines params. A ceiling of 0 is
+// unlimited.
+func watchResources(ctx context.Context, runenv *tgruntime.RunEnv) func() error {
+	maxHeap := runenv.SizeParam("max_heap")
+	maxGoroutines := runenv.IntParam("max_goroutines")
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var peakHeap uint64
+	var peakGoroutines int
+	sample := func() {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		if m.HeapAlloc > peakHeap {
+			if maxHeap > 0 && peakHeap <= maxHeap && m.HeapAlloc > maxHeap {
+				runenv.RecordMessage("heap of %d bytes exceeds the ceiling of %d bytes", m.HeapAlloc, maxHeap)
+			}
+			peakHeap = m.HeapAlloc
+		}
+		if n := runtime.NumGoroutine(); n > peakGoroutines {
+			if maxGoroutines > 0 && peakGoroutines <= maxGoroutines && n > maxGoroutines {
+				runenv.RecordMessage("%d goroutines exceed the ceiling of %d", n, maxGoroutines)
+			}
+			peakGoroutines = n
+		}
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(resourceSampleInterval)
+		defer ticker.Stop()
+		for {
+			sample()
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() error {
+		cancel()
+		<-done
+		runenv.R().RecordPoint("peak_heap_bytes", float64(peakHeap))
+		runenv.R().RecordPoint("peak_goroutines", float64(peakGoroutines))
+		if maxHeap > 0 && peakHeap > maxHeap {
+			return fmt.Errorf("peak heap of %d bytes exceeded the ceiling of %d bytes", peakHeap, maxHeap)
+		}
+		if maxGoroutines > 0 && peakGoroutines > maxGoroutines {
+			return fmt.Errorf("peak of %d goroutines exceeded the ceiling of %d", peakGoroutines, maxGoroutines)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/testground/sdk-go/run"
+	"github.com/testground/sdk-go/runtime"
+	"github.com/testground/sdk-go/sync"
+)
+
+// resultBatch is the results of an instance, published once it is done.
+type resultBatch struct {
+	Seq    int64
+	Group  string
+	Values map[string][]float64
+}
+
+var resultTopic = sync.NewTopic("results", &resultBatch{})
+
+// results records the points of an instance and keeps their values, to
+// publish them to the collector.
+type results struct {
+	runenv *runtime.RunEnv
+	values map[string][]float64
+}
+
+func newResults(runenv *runtime.RunEnv) *results {
+	return &results{runenv: runenv, values: make(map[string][]float64)}
+}
+
+// record records a point of the metric.
+func (r *results) record(metric string, v float64) {
+	r.runenv.R().RecordPoint(metric, v)
+	r.values[metric] = append(r.values[metric], v)
+}
+
+// publish publishes the values recorded to the collector.
+func (r *results) publish(ctx context.Context, initCtx *run.InitContext) error {
+	_, err := initCtx.SyncClient.Publish(ctx, resultTopic, &resultBatch{
+		Seq:    initCtx.GlobalSeq,
+		Group:  r.runenv.TestGroupID,
+		Values: r.values,
+	})
+	return err
+}
+
+// threshold is a ceiling of a percentile of a metric, parsed from
+// "<metric>:p<percentile><<ceiling>", e.g. "block_fetch_ms:p95<500".
+type threshold struct {
+	metric     string
+	percentile float64
+	ceiling    float64
+}
+
+func (t threshold) String() string {
+	return fmt.Sprintf("%s:p%g<%g", t.metric, t.percentile, t.ceiling)
+}
+
+// parseThresholds parses the comma separated thresholds of the thresholds
+// param.
+func parseThresholds(s string) ([]threshold, error) {
+	var thresholds []threshold
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		metric, rest, ok := strings.Cut(f, ":p")
+		if !ok {
+			return nil, fmt.Errorf("invalid threshold %q", f)
+		}
+		p, c, ok := strings.Cut(rest, "<")
+		if !ok {
+			return nil, fmt.Errorf("invalid threshold %q", f)
+		}
+		percentile, err := strconv.ParseFloat(p, 64)
+		if err != nil || percentile <= 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid percentile in threshold %q", f)
+		}
+		ceiling, err := strconv.ParseFloat(c, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ceiling in threshold %q: %w", f, err)
+		}
+		thresholds = append(thresholds, threshold{metric: metric, percentile: percentile, ceiling: ceiling})
+	}
+	return thresholds, nil
+}
+
+// percentile returns the nearest-rank percentile of the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// runCollector aggregates the results of the instances of the other groups,
+// records the p50, p95 and p99 of each metric, and fails if a percentile
+// exceeds its ceiling in the thresholds param, to gate on performance.
+func runCollector(ctx context.Context, runenv *runtime.RunEnv, initCtx *run.InitContext) error {
+	thresholds, err := parseThresholds(runenv.StringParam("thresholds"))
+	if err != nil {
+		return err
+	}
+
+	batches := make(chan *resultBatch)
+	sub, err := initCtx.SyncClient.Subscribe(ctx, resultTopic, batches)
+	if err != nil {
+		return err
+	}
+	values := make(map[string][]float64)
+	for n := runenv.TestInstanceCount - runenv.TestGroupInstanceCount; n > 0; n-- {
+		select {
+		case b := <-batches:
+			for metric, vs := range b.Values {
+				values[metric] = append(values[metric], vs...)
+			}
+		case err := <-sub.Done():
+			return err
+		}
+	}
+
+	for metric, vs := range values {
+		sort.Float64s(vs)
+		for _, p := range []float64{50, 95, 99} {
+			runenv.R().RecordPoint(fmt.Sprintf("%s_p%g", metric, p), percentile(vs, p))
+		}
+		runenv.RecordMessage("%s: %d values, p50 %g, p95 %g, p99 %g", metric, len(vs), percentile(vs, 50), percentile(vs, 95), percentile(vs, 99))
+	}
+
+	var violated []string
+	for _, t := range thresholds {
+		vs, ok := values[t.metric]
+		if !ok {
+			violated = append(violated, fmt.Sprintf("%s: no values", t))
+			continue
+		}
+		if v := percentile(vs, t.percentile); v >= t.ceiling {
+			violated = append(violated, fmt.Sprintf("%s: %g", t, v))
+		}
+	}
+	if len(violated) > 0 {
+		return fmt.Errorf("%d of %d thresholds violated: %s", len(violated), len(thresholds), strings.Join(violated, ", "))
+	}
+	return nil
+}
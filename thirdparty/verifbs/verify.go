@@ -0,0 +1,61 @@
+package verifbs
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+
+	cid "github.com/ipfs/go-cid"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+	"github.com/ipfs/go-verifcid"
+	mh "github.com/multiformats/go-multihash"
+	mhcore "github.com/multiformats/go-multihash/core"
+)
+
+// ErrHashMismatch is returned when data does not match the multihash of its
+// CID.
+var ErrHashMismatch = errors.New("data does not match the multihash of its CID")
+
+type verifyingReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected []byte
+}
+
+// NewVerifyingReader returns a reader of r that hashes the data as it is
+// read and fails at EOF if it does not match the multihash of c. The data is
+// not buffered, so that large payloads can be verified while streaming.
+func NewVerifyingReader(r io.Reader, c cid.Cid) (io.Reader, error) {
+	if err := verifcid.ValidateCid(c); err != nil {
+		return nil, err
+	}
+	dmh, err := mh.Decode(c.Hash())
+	if err != nil {
+		return nil, err
+	}
+	h, err := mhcore.GetVariableHasher(dmh.Code, dmh.Length)
+	if err != nil {
+		return nil, err
+	}
+	return &verifyingReader{r: r, h: h, expected: dmh.Digest}, nil
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(v.h.Sum(nil), v.expected) {
+		return n, ErrHashMismatch
+	}
+	return n, err
+}
+
+// VerifyBlock checks that the data of b matches its CID.
+func VerifyBlock(b blocks.Block) error {
+	r, err := NewVerifyingReader(bytes.NewReader(b.RawData()), b.Cid())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(io.Discard, r)
+	return err
+}
@@ -0,0 +1,36 @@
+package verifbs
+
+import (
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	blocks "github.com/ipfs/go-libipfs/blocks"
+	mh "github.com/multiformats/go-multihash"
+)
+
+func TestVerifyBlock(t *testing.T) {
+	data := []byte("verified streaming")
+	for _, code := range []uint64{mh.SHA2_256, mh.BLAKE3} {
+		h, err := mh.Sum(data, code, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := cid.NewCidV1(cid.Raw, h)
+
+		b, err := blocks.NewBlockWithCid(data, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyBlock(b); err != nil {
+			t.Fatalf("%s: %s", mh.Codes[code], err)
+		}
+
+		b, err = blocks.NewBlockWithCid([]byte("tampered"), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyBlock(b); err != ErrHashMismatch {
+			t.Fatalf("%s: expected hash mismatch, got %v", mh.Codes[code], err)
+		}
+	}
+}